@@ -0,0 +1,386 @@
+// Package alerts sends alerts of security relevant events (new binaries
+// connecting to the network, binaries modified, firewall rules deleted) to
+// webhooks and email, regardless of whether a GUI is connected or not.
+package alerts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+// Events that trigger an alert.
+const (
+	// a binary never seen before has opened a connection.
+	EventNewBinary = "new-binary"
+	// the checksum of a binary has changed since the last time it was seen.
+	EventChecksumMismatch = "checksum-mismatch"
+	// the connections of a binary are denied because its checksum doesn't
+	// match the one of its rule (Rules.ChecksumQuarantine).
+	EventChecksumQuarantine = "checksum-quarantine"
+	// the firewall rules to intercept connections have been deleted.
+	EventFirewallWiped = "firewall-wiped"
+	// a connection has been tagged by a rule with one of the Tags configured.
+	EventTaggedConnection = "tagged-connection"
+	// a policy audit has found problems in the rules or the firewall.
+	EventPolicyAudit = "policy-audit"
+	// a process is listening for connections for the first time.
+	EventNewListener = "new-listener"
+	// a process has bound a port lower than 1024.
+	EventPrivilegedPort = "privileged-port"
+	// a new version of the daemon has been staged by the updater.
+	EventUpdateAvailable = "update-available"
+)
+
+var (
+	defaultThrottle = 10 * time.Minute
+	sendTimeout     = 15 * time.Second
+	maxQueuedAlerts = 64
+)
+
+// Config holds the configuration of the alerts.
+type Config struct {
+	Webhooks []WebhookConfig `json:"Webhooks"`
+	Email    EmailConfig     `json:"Email"`
+
+	// Events to alert about. All of them if it's empty.
+	Events []string `json:"Events"`
+
+	// Tags of the connections to alert about, with the event tagged-connection.
+	Tags []string `json:"Tags"`
+
+	// BinariesFile where the binaries seen and their checksums are saved, to
+	// detect new and modified binaries across restarts.
+	// If it's empty, they're only kept in memory.
+	BinariesFile string `json:"BinariesFile"`
+
+	// Throttle is the minimum interval between alerts of the same event and
+	// subject (10m by default).
+	Throttle string `json:"Throttle"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+// Alert is the message sent to the destinations.
+type Alert struct {
+	Time     time.Time         `json:"time"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Event    string            `json:"event"`
+	Hostname string            `json:"hostname"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text"`
+	// the ID and parameters of the title and the text, to translate them.
+	TitleMessage i18n.Message `json:"title_message"`
+	Message      i18n.Message `json:"message"`
+}
+
+// sender is the interface that every destination of the alerts must met.
+type sender interface {
+	Name() string
+	Send(ctx context.Context, a *Alert) error
+}
+
+// Manager sends the alerts to the configured destinations.
+type Manager struct {
+	cancel   context.CancelFunc
+	queue    chan *Alert
+	events   map[string]bool
+	tags     map[string]bool
+	lastSent map[string]time.Time
+	// binaries seen: path -> md5 (empty if unknown)
+	binaries map[string]string
+	cfg      Config
+	throttle time.Duration
+	hostname string
+
+	sync.Mutex
+}
+
+// Default is the manager of the daemon alerts.
+var Default = NewManager()
+
+// NewManager returns a new manager, disabled until it's configured.
+func NewManager() *Manager {
+	hostname, _ := os.Hostname()
+	return &Manager{
+		hostname: hostname,
+		binaries: make(map[string]string),
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// SetConfig applies a new configuration, stopping the current destinations.
+func (m *Manager) SetConfig(cfg Config) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.cfg = cfg
+	m.binaries = make(map[string]string)
+	m.lastSent = make(map[string]time.Time)
+	if !cfg.Enabled {
+		m.cfg.Enabled = false
+		return nil
+	}
+	m.cfg.Enabled = false
+
+	m.throttle = defaultThrottle
+	if cfg.Throttle != "" {
+		throttle, err := time.ParseDuration(cfg.Throttle)
+		if err != nil {
+			return fmt.Errorf("invalid alerts throttle: %s", err)
+		}
+		m.throttle = throttle
+	}
+	m.events = make(map[string]bool, len(cfg.Events))
+	for _, ev := range cfg.Events {
+		switch ev {
+		case EventNewBinary, EventChecksumMismatch, EventChecksumQuarantine, EventFirewallWiped,
+			EventTaggedConnection, EventPolicyAudit, EventNewListener, EventPrivilegedPort, EventUpdateAvailable:
+			m.events[ev] = true
+		default:
+			return fmt.Errorf("unknown alert event: %s", ev)
+		}
+	}
+	m.tags = make(map[string]bool, len(cfg.Tags))
+	for _, tag := range cfg.Tags {
+		m.tags[tag] = true
+	}
+
+	senders := []sender{}
+	for _, wcfg := range cfg.Webhooks {
+		w, err := newWebhook(wcfg)
+		if err != nil {
+			return err
+		}
+		senders = append(senders, w)
+	}
+	if cfg.Email.Server != "" {
+		e, err := newEmail(cfg.Email)
+		if err != nil {
+			return err
+		}
+		senders = append(senders, e)
+	}
+	if len(senders) == 0 {
+		return fmt.Errorf("alerts enabled, but there're no webhooks or email configured")
+	}
+	if err := m.loadBinaries(); err != nil {
+		log.Warning("[alerts] error loading binaries seen from %s: %s", cfg.BinariesFile, err)
+	}
+
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.queue = make(chan *Alert, maxQueuedAlerts)
+	go m.worker(ctx, m.queue, senders)
+	m.cfg.Enabled = true
+	log.Info("[alerts] sending alerts to %d destinations", len(senders))
+
+	return nil
+}
+
+// Send queues an alert, unless the event is not enabled, or an alert of the
+// same event and subject has been sent recently.
+func (m *Manager) Send(event, subject string, title, text i18n.Message, fields map[string]string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.cfg.Enabled || (len(m.events) > 0 && !m.events[event]) {
+		return
+	}
+	now := time.Now()
+	key := event + subject
+	if last, found := m.lastSent[key]; found && now.Sub(last) < m.throttle {
+		log.Debug("[alerts] alert throttled: %s, %s", event, subject)
+		return
+	}
+	m.lastSent[key] = now
+
+	a := &Alert{
+		Time:         now,
+		Event:        event,
+		Hostname:     m.hostname,
+		Title:        title.String(),
+		Text:         text.String(),
+		TitleMessage: title,
+		Message:      text,
+		Fields:       fields,
+	}
+	select {
+	case m.queue <- a:
+	default:
+		log.Warning("[alerts] queue full, alert discarded: %s", a.Title)
+	}
+}
+
+// OnConnection alerts if the binary of a connection has never been seen
+// before, or if its checksum has changed since it was seen.
+// The checksum is only verified if the checksums of the binaries are enabled.
+func (m *Manager) OnConnection(con *conman.Connection) {
+	if con == nil || con.Process == nil || con.Process.Path == "" {
+		return
+	}
+	path := con.Process.Path
+	con.Process.RLock()
+	sum := con.Process.Checksums[procmon.HashMD5]
+	con.Process.RUnlock()
+
+	m.Lock()
+	if !m.cfg.Enabled {
+		m.Unlock()
+		return
+	}
+	oldSum, seen := m.binaries[path]
+	if seen && (sum == "" || sum == oldSum) {
+		m.Unlock()
+		return
+	}
+	m.binaries[path] = sum
+	m.saveBinary(path, sum)
+	m.Unlock()
+
+	fields := map[string]string{
+		"pid":         fmt.Sprint(con.Process.ID),
+		"uid":         fmt.Sprint(con.Entry.UserId),
+		"path":        path,
+		"destination": fmt.Sprintf("%s:%d (%s)", con.DstIP, con.DstPort, con.DstHost),
+	}
+	if sum != "" {
+		fields["md5"] = sum
+	}
+	switch {
+	case !seen:
+		m.Send(EventNewBinary, path,
+			i18n.New(i18n.NewBinaryTitle),
+			i18n.New(i18n.NewBinary, "path", path, "ip", con.DstIP, "port", con.DstPort),
+			fields)
+	case oldSum != "":
+		fields["previous_md5"] = oldSum
+		m.Send(EventChecksumMismatch, path,
+			i18n.New(i18n.ChecksumMismatchTitle),
+			i18n.New(i18n.ChecksumMismatch, "path", path),
+			fields)
+	}
+}
+
+// OnTaggedConnection alerts if a connection has been tagged with one of the
+// configured tags. The alerts are throttled by tag and binary.
+func (m *Manager) OnTaggedConnection(con *conman.Connection, ruleName string) {
+	if con == nil || len(con.Tags) == 0 {
+		return
+	}
+	m.Lock()
+	if !m.cfg.Enabled {
+		m.Unlock()
+		return
+	}
+	tag := ""
+	for _, t := range con.Tags {
+		if m.tags[t] {
+			tag = t
+			break
+		}
+	}
+	m.Unlock()
+	if tag == "" {
+		return
+	}
+
+	path := ""
+	if con.Process != nil {
+		path = con.Process.Path
+	}
+	m.Send(EventTaggedConnection, tag+path,
+		i18n.New(i18n.TaggedConnectionTitle, "tag", tag),
+		i18n.New(i18n.TaggedConnection, "path", path, "ip", con.DstIP, "port", con.DstPort, "tag", tag, "rule", ruleName),
+		map[string]string{
+			"path":        path,
+			"tags":        strings.Join(con.Tags, ","),
+			"rule":        ruleName,
+			"destination": fmt.Sprintf("%s:%d (%s)", con.DstIP, con.DstPort, con.DstHost),
+		})
+}
+
+// OnFirewallWiped alerts that the firewall rules to intercept connections
+// have been deleted, by other program or by the user.
+func (m *Manager) OnFirewallWiped() {
+	m.Send(EventFirewallWiped, "",
+		i18n.New(i18n.FirewallWipedTitle),
+		i18n.New(i18n.FirewallWiped),
+		nil)
+}
+
+func (m *Manager) worker(ctx context.Context, queue <-chan *Alert, senders []sender) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-queue:
+			for _, s := range senders {
+				sctx, cancel := context.WithTimeout(ctx, sendTimeout)
+				if err := s.Send(sctx, a); err != nil {
+					log.Warning("[alerts] error sending alert to %s: %s", s.Name(), err)
+				}
+				cancel()
+			}
+		}
+	}
+}
+
+// loadBinaries reads the binaries seen. Every line contains the md5 of the
+// binary ("-" if unknown) and its path. The last line of a binary prevails.
+func (m *Manager) loadBinaries() error {
+	if m.cfg.BinariesFile == "" {
+		return nil
+	}
+	f, err := os.Open(m.cfg.BinariesFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, path, found := strings.Cut(scanner.Text(), " ")
+		if !found || path == "" {
+			continue
+		}
+		if sum == "-" {
+			sum = ""
+		}
+		m.binaries[path] = sum
+	}
+	log.Debug("[alerts] %d binaries loaded from %s", len(m.binaries), m.cfg.BinariesFile)
+	return scanner.Err()
+}
+
+func (m *Manager) saveBinary(path, sum string) {
+	if m.cfg.BinariesFile == "" {
+		return
+	}
+	f, err := os.OpenFile(m.cfg.BinariesFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Warning("[alerts] error saving binary seen: %s", err)
+		return
+	}
+	defer f.Close()
+	if sum == "" {
+		sum = "-"
+	}
+	fmt.Fprintf(f, "%s %s\n", sum, path)
+}
@@ -0,0 +1,164 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, chan map[string]interface{}) {
+	received := make(chan map[string]interface{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error("invalid alert received:", err)
+		}
+		received <- body
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func waitAlert(t *testing.T, received chan map[string]interface{}) map[string]interface{} {
+	select {
+	case body := <-received:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert not received")
+	}
+	return nil
+}
+
+func noAlert(t *testing.T, received chan map[string]interface{}) {
+	select {
+	case body := <-received:
+		t.Error("unexpected alert received:", body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func newTestConnection(path, md5 string) *conman.Connection {
+	p := procmon.NewProcessEmpty(1234, "curl")
+	p.Path = path
+	if md5 != "" {
+		p.Checksums[procmon.HashMD5] = md5
+	}
+	return &conman.Connection{
+		Process: p,
+		Entry:   &netstat.Entry{UserId: 1000},
+		DstIP:   net.ParseIP("185.53.178.14"),
+		DstPort: 443,
+		DstHost: "opensnitch.io",
+	}
+}
+
+func TestAlertsBinaries(t *testing.T) {
+	srv, received := newTestServer(t)
+	binariesFile := filepath.Join(t.TempDir(), "binaries.list")
+	cfg := Config{
+		Enabled:      true,
+		BinariesFile: binariesFile,
+		Webhooks:     []WebhookConfig{{URL: srv.URL}},
+	}
+	m := NewManager()
+	if err := m.SetConfig(cfg); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer m.SetConfig(Config{})
+
+	m.OnConnection(newTestConnection("/usr/bin/curl", "aaa"))
+	alert := waitAlert(t, received)
+	if alert["event"] != EventNewBinary || alert["fields"].(map[string]interface{})["path"] != "/usr/bin/curl" {
+		t.Error("invalid alert:", alert)
+	}
+	m.OnConnection(newTestConnection("/usr/bin/curl", "aaa"))
+	noAlert(t, received)
+
+	// the binaries seen must be kept across reloads.
+	if err := m.SetConfig(cfg); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	m.OnConnection(newTestConnection("/usr/bin/curl", ""))
+	noAlert(t, received)
+	m.OnConnection(newTestConnection("/usr/bin/curl", "bbb"))
+	alert = waitAlert(t, received)
+	if alert["event"] != EventChecksumMismatch || alert["fields"].(map[string]interface{})["previous_md5"] != "aaa" {
+		t.Error("invalid alert:", alert)
+	}
+}
+
+func TestAlertsEventsAndThrottle(t *testing.T) {
+	srv, received := newTestServer(t)
+	m := NewManager()
+	err := m.SetConfig(Config{
+		Enabled:  true,
+		Events:   []string{EventFirewallWiped},
+		Webhooks: []WebhookConfig{{URL: srv.URL, Format: WebhookSlack}},
+	})
+	if err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer m.SetConfig(Config{})
+
+	m.OnConnection(newTestConnection("/usr/bin/curl", ""))
+	noAlert(t, received)
+
+	m.OnFirewallWiped()
+	if alert := waitAlert(t, received); alert["text"] == nil {
+		t.Error("invalid slack alert:", alert)
+	}
+	m.OnFirewallWiped()
+	noAlert(t, received)
+}
+
+func TestAlertsTaggedConnection(t *testing.T) {
+	srv, received := newTestServer(t)
+	m := NewManager()
+	err := m.SetConfig(Config{
+		Enabled:  true,
+		Events:   []string{EventTaggedConnection},
+		Tags:     []string{"telemetry"},
+		Webhooks: []WebhookConfig{{URL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer m.SetConfig(Config{})
+
+	con := newTestConnection("/usr/bin/curl", "")
+	con.Tags = []string{"updates"}
+	m.OnTaggedConnection(con, "allow-updates")
+	noAlert(t, received)
+
+	con.Tags = []string{"updates", "telemetry"}
+	m.OnTaggedConnection(con, "allow-telemetry")
+	alert := waitAlert(t, received)
+	fields, _ := alert["fields"].(map[string]interface{})
+	if alert["event"] != EventTaggedConnection || fields["rule"] != "allow-telemetry" || fields["tags"] != "updates,telemetry" {
+		t.Error("invalid alert:", alert)
+	}
+}
+
+func TestAlertsConfig(t *testing.T) {
+	m := NewManager()
+	invalid := []Config{
+		{Enabled: true},
+		{Enabled: true, Webhooks: []WebhookConfig{{URL: "ftp://example.com"}}},
+		{Enabled: true, Webhooks: []WebhookConfig{{URL: "https://example.com", Format: "irc"}}},
+		{Enabled: true, Webhooks: []WebhookConfig{{URL: "https://example.com"}}, Events: []string{"unknown"}},
+		{Enabled: true, Email: EmailConfig{Server: "smtp.example.com", From: "a@example.com"}},
+	}
+	for _, cfg := range invalid {
+		if err := m.SetConfig(cfg); err == nil {
+			t.Errorf("invalid configuration accepted: %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+)
+
+// EmailConfig holds the configuration to send the alerts by email.
+// The connection is upgraded to TLS if the server supports STARTTLS.
+type EmailConfig struct {
+	// Server: smtp.example.com:587
+	Server string   `json:"Server"`
+	From   string   `json:"From"`
+	To     []string `json:"To"`
+	// User and Password to authenticate against the server (optional).
+	User     string `json:"User"`
+	Password string `json:"Password"`
+}
+
+type email struct {
+	cfg  EmailConfig
+	auth smtp.Auth
+}
+
+func newEmail(cfg EmailConfig) (*email, error) {
+	host, _, err := net.SplitHostPort(cfg.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email server %s: %s", cfg.Server, err)
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("the email From and To fields are mandatory")
+	}
+	e := &email{cfg: cfg}
+	if cfg.User != "" {
+		e.auth = smtp.PlainAuth("", cfg.User, cfg.Password, host)
+	}
+	return e, nil
+}
+
+func (e *email) Name() string {
+	return "email " + e.cfg.Server
+}
+
+func (e *email) Send(ctx context.Context, a *Alert) error {
+	// smtp.SendMail doesn't accept a context, so the timeout is not applied.
+	return smtp.SendMail(e.cfg.Server, e.auth, e.cfg.From, e.cfg.To, e.message(a))
+}
+
+func (e *email) message(a *Alert) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [opensnitch] %s (%s)\r\n", a.Title, a.Hostname)
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.Time.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(a.Text)
+	msg.WriteString("\r\n\r\n")
+
+	keys := make([]string, 0, len(a.Fields))
+	for k := range a.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&msg, "%s: %s\r\n", k, a.Fields[k])
+	}
+	return []byte(msg.String())
+}
@@ -0,0 +1,103 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Formats of the webhooks.
+const (
+	// the Alert in json format.
+	WebhookGeneric = "generic"
+	// Slack incoming webhooks (also supported by Mattermost, Rocket.Chat, ...)
+	WebhookSlack = "slack"
+	// Matrix client API:
+	// https://<server>/_matrix/client/v3/rooms/<room id>/send/m.room.message
+	// with the access token in the header Authorization: Bearer <token>
+	WebhookMatrix = "matrix"
+)
+
+// WebhookConfig holds the configuration of a webhook.
+type WebhookConfig struct {
+	// Headers to add to the requests, i.e.: Authorization
+	Headers map[string]string `json:"Headers"`
+	URL     string            `json:"URL"`
+	// Format of the requests: generic (default), slack or matrix.
+	Format string `json:"Format"`
+}
+
+type webhook struct {
+	client *http.Client
+	cfg    WebhookConfig
+}
+
+func newWebhook(cfg WebhookConfig) (*webhook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %s", cfg.URL)
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = WebhookGeneric
+	case WebhookGeneric, WebhookSlack, WebhookMatrix:
+	default:
+		return nil, fmt.Errorf("unknown webhook format: %s", cfg.Format)
+	}
+	return &webhook{
+		client: &http.Client{},
+		cfg:    cfg,
+	}, nil
+}
+
+func (w *webhook) Name() string {
+	return w.cfg.Format + " webhook"
+}
+
+func (w *webhook) Send(ctx context.Context, a *Alert) error {
+	method, url := http.MethodPost, w.cfg.URL
+	var body interface{}
+	switch w.cfg.Format {
+	case WebhookSlack:
+		body = map[string]string{
+			"text": fmt.Sprintf("*%s* (%s)\n%s", a.Title, a.Hostname, a.Text),
+		}
+	case WebhookMatrix:
+		// every message requires a unique transaction ID
+		method = http.MethodPut
+		url = fmt.Sprint(url, "/", strconv.FormatInt(time.Now().UnixNano(), 10))
+		body = map[string]string{
+			"msgtype": "m.text",
+			"body":    fmt.Sprintf("%s (%s): %s", a.Title, a.Hostname, a.Text),
+		}
+	default:
+		body = a
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/hooks"
+	"github.com/evilsocket/opensnitch/daemon/log/loggers"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+const help = `commands:
+  rules                          list the rules, in the order they're evaluated
+  enable <rule>                  enable a rule
+  disable <rule>                 disable a rule
+  delete <rule>                  delete a rule
+  set <rule> <field> <value>     change a field of a rule:
+                                   action (allow, deny, reject, audit, proxy), duration,
+                                   precedence (true, false), nolog (true, false), priority,
+                                   proxy (upstream of the rules with action proxy),
+                                   log (level[:target[:file]], e.g. full:file:/var/log/x.log)
+                                   hook (path[:allow|deny[:timeout]], e.g. /usr/local/bin/x:deny:10s)
+  tail [on|off]                  print the connections as they're intercepted
+  panic                          block the new outbound connections, except to
+                                   the loopback interface and this UI
+  unpanic                        intercept again the new outbound connections
+  offline <path> <duration>      block a binary and its children for some time
+                                   (e.g. 15m), closing their connections
+  features                       show the kernel features supported by the
+                                   host of the daemon
+  decisions [n]                  show the last connections prompted and their
+                                   answers, including the ones not answered
+  promote <id> [action]          convert a past decision into a permanent rule
+                                   (the action is required if it wasn't answered)
+  dns [flush]                    show the cache of the domains resolved, or
+                                   delete all its records
+  updates [check]                show the new version of the daemon staged for
+                                   the next upgrade, or check it right away
+  snapshots [id]                 show the snapshots of the rules, or the rules
+                                   of a snapshot
+  rollback <id>                  restore the rules of a snapshot
+  proccache [<option> <value>]   show the settings and counters of the cache of
+                                   processes, or change them until the daemon
+                                   config changes: MaxEvents, PidTTL, ExitDelay,
+                                   TickerInterval (e.g. proccache PidTTL 1m)
+  help                           show this help
+  quit                           exit
+`
+
+// command executes a command of the user. It returns false to exit.
+func (s *server) command(line string) bool {
+	args := strings.Fields(line)
+	var err error
+
+	switch args[0] {
+	case "help", "?":
+		s.term.printf("%s", help)
+	case "quit", "exit":
+		return false
+	case "rules":
+		s.listRules()
+	case "tail":
+		on := len(args) == 1 || args[1] == "on"
+		s.tail.Store(on)
+		s.term.printf("tail %v\n", on)
+	case "panic", "unpanic":
+		action := protocol.Action_ENABLE_PANIC_MODE
+		if args[0] == "unpanic" {
+			action = protocol.Action_DISABLE_PANIC_MODE
+		}
+		err = s.notify(action)
+	case "features":
+		err = s.notify(protocol.Action_GET_FEATURES)
+	case "decisions":
+		limit := 20
+		if len(args) > 1 {
+			if limit, err = strconv.Atoi(args[1]); err != nil {
+				break
+			}
+		}
+		err = s.notifyData(protocol.Action_GET_DECISIONS, map[string]int{"limit": limit})
+	case "dns":
+		switch {
+		case len(args) == 1:
+			err = s.notify(protocol.Action_GET_DNS_CACHE)
+		case len(args) == 2 && args[1] == "flush":
+			err = s.notify(protocol.Action_FLUSH_DNS_CACHE)
+		default:
+			err = fmt.Errorf("usage: dns [flush]")
+		}
+	case "updates":
+		switch {
+		case len(args) == 1:
+			err = s.notify(protocol.Action_GET_UPDATES)
+		case len(args) == 2 && args[1] == "check":
+			err = s.notifyData(protocol.Action_GET_UPDATES, map[string]bool{"check": true})
+		default:
+			err = fmt.Errorf("usage: updates [check]")
+		}
+	case "snapshots":
+		switch len(args) {
+		case 1:
+			err = s.notify(protocol.Action_GET_RULES_SNAPSHOTS)
+		case 2:
+			var id uint64
+			if id, err = strconv.ParseUint(args[1], 10, 64); err == nil {
+				err = s.notifyData(protocol.Action_GET_RULES_SNAPSHOTS, map[string]uint64{"id": id})
+			}
+		default:
+			err = fmt.Errorf("usage: snapshots [id]")
+		}
+	case "rollback":
+		if len(args) != 2 {
+			err = fmt.Errorf("usage: rollback <id>")
+			break
+		}
+		var id uint64
+		if id, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			break
+		}
+		err = s.notifyData(protocol.Action_ROLLBACK_RULES, map[string]uint64{"id": id})
+	case "proccache":
+		if len(args)%2 == 0 {
+			err = fmt.Errorf("usage: proccache [<option> <value>]...")
+			break
+		}
+		opts := make(map[string]interface{})
+		for i := 1; i < len(args); i += 2 {
+			switch args[i] {
+			case "MaxEvents":
+				opts[args[i]], err = strconv.Atoi(args[i+1])
+			case "PidTTL", "ExitDelay", "TickerInterval":
+				opts[args[i]] = args[i+1]
+			default:
+				err = fmt.Errorf("unknown option: %s", args[i])
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+		if len(opts) == 0 {
+			err = s.notify(protocol.Action_TUNE_PROCESS_CACHE)
+			break
+		}
+		err = s.notifyData(protocol.Action_TUNE_PROCESS_CACHE, opts)
+	case "promote":
+		if len(args) < 2 || len(args) > 3 {
+			err = fmt.Errorf("usage: promote <id> [action]")
+			break
+		}
+		var id uint64
+		if id, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			break
+		}
+		opts := map[string]interface{}{"id": id}
+		if len(args) == 3 {
+			opts["action"] = args[2]
+		}
+		err = s.notifyData(protocol.Action_PROMOTE_DECISION, opts)
+	case "offline":
+		if len(args) != 3 {
+			err = fmt.Errorf("usage: offline <path> <duration>")
+			break
+		}
+		if _, err = time.ParseDuration(args[2]); err != nil {
+			break
+		}
+		err = s.notifyData(protocol.Action_BLOCK_APP, map[string]string{
+			"process_path": args[1],
+			"duration":     args[2],
+		})
+	case "enable", "disable":
+		if len(args) != 2 {
+			err = fmt.Errorf("usage: %s <rule>", args[0])
+			break
+		}
+		err = s.changeRule(args[1], func(r *protocol.Rule) (protocol.Action, error) {
+			r.Enabled = args[0] == "enable"
+			if r.Enabled {
+				return protocol.Action_ENABLE_RULE, nil
+			}
+			return protocol.Action_DISABLE_RULE, nil
+		})
+	case "delete":
+		if len(args) != 2 {
+			err = fmt.Errorf("usage: delete <rule>")
+			break
+		}
+		err = s.deleteRule(args[1])
+	case "set":
+		if len(args) != 4 {
+			err = fmt.Errorf("usage: set <rule> <field> <value>")
+			break
+		}
+		err = s.changeRule(args[1], func(r *protocol.Rule) (protocol.Action, error) {
+			return protocol.Action_CHANGE_RULE, setField(r, args[2], args[3])
+		})
+	default:
+		err = fmt.Errorf("unknown command: %s (type 'help' to list the commands)", args[0])
+	}
+
+	if err != nil {
+		s.term.printf("%s\n", err)
+	}
+	return true
+}
+
+func (s *server) listRules() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.term.Lock()
+	defer s.term.Unlock()
+	w := tabwriter.NewWriter(s.term.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tENABLED\tACTION\tDURATION\tOPERATOR\n")
+	for _, r := range s.rules {
+		fmt.Fprintf(w, "%s\t%v\t%s\t%s\t%s\n", r.Name, r.Enabled, r.Action, r.Duration, formatOperator(r.Operator))
+	}
+	w.Flush()
+}
+
+// findRule returns the index of a rule. The caller must hold the lock.
+func (s *server) findRule(name string) int {
+	for i, r := range s.rules {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// changeRule modifies a copy of a rule, and sends it to the daemon.
+func (s *server) changeRule(name string, change func(r *protocol.Rule) (protocol.Action, error)) error {
+	s.Lock()
+	defer s.Unlock()
+
+	idx := s.findRule(name)
+	if idx == -1 {
+		return fmt.Errorf("rule not found: %s", name)
+	}
+	// protocol.Rule -> rule.Rule -> protocol.Rule, to work on a copy.
+	r, err := rule.Deserialize(s.rules[idx])
+	if err != nil {
+		return err
+	}
+	pr := r.Serialize()
+	action, err := change(pr)
+	if err != nil {
+		return err
+	}
+	if err := s.notify(action, pr); err != nil {
+		return err
+	}
+	s.rules[idx] = pr
+	return nil
+}
+
+func (s *server) deleteRule(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	idx := s.findRule(name)
+	if idx == -1 {
+		return fmt.Errorf("rule not found: %s", name)
+	}
+	if err := s.notify(protocol.Action_DELETE_RULE, s.rules[idx]); err != nil {
+		return err
+	}
+	s.rules = append(s.rules[:idx], s.rules[idx+1:]...)
+	return nil
+}
+
+func setField(r *protocol.Rule, field, value string) error {
+	var err error
+	switch field {
+	case "action":
+		switch rule.Action(value) {
+		case rule.Allow, rule.Deny, rule.Reject, rule.Audit, rule.Proxy:
+			r.Action = value
+		default:
+			err = fmt.Errorf("invalid action: %s", value)
+		}
+	case "duration":
+		r.Duration = value
+	case "proxy":
+		r.Proxy = value
+	case "precedence":
+		r.Precedence, err = strconv.ParseBool(value)
+	case "nolog":
+		r.Nolog, err = strconv.ParseBool(value)
+	case "log":
+		// level[:target[:file]], empty to delete the log directive.
+		parts := strings.SplitN(value, ":", 3)
+		cfg := loggers.RuleLogConfig{Level: parts[0]}
+		if len(parts) > 1 {
+			cfg.Target = parts[1]
+		}
+		if len(parts) > 2 {
+			cfg.File = parts[2]
+		}
+		if err = cfg.Validate(); err == nil {
+			r.LogLevel, r.LogTarget, r.LogFile = cfg.Level, cfg.Target, cfg.File
+		}
+	case "hook":
+		// path[:allow|deny[:timeout]]. The executable is validated by the
+		// daemon, on its host.
+		parts := strings.SplitN(value, ":", 3)
+		hook := hooks.Hook{Path: parts[0]}
+		if len(parts) > 1 && parts[1] != "" {
+			hook.On = []string{parts[1]}
+		}
+		if len(parts) > 2 {
+			hook.Timeout = parts[2]
+		}
+		if !filepath.IsAbs(hook.Path) {
+			err = fmt.Errorf("invalid hook, it must be an absolute path: '%s'", hook.Path)
+		} else if len(hook.On) > 0 && hook.On[0] != hooks.OnAllow && hook.On[0] != hooks.OnDeny {
+			err = fmt.Errorf("invalid hook verdict: %s", hook.On[0])
+		} else {
+			r.HookPath, r.HookOn, r.HookTimeout = hook.Path, hook.On, hook.Timeout
+		}
+	case "priority":
+		var prio int64
+		prio, err = strconv.ParseInt(value, 10, 32)
+		r.Priority = int32(prio)
+	default:
+		err = fmt.Errorf("invalid field: %s", field)
+	}
+	return err
+}
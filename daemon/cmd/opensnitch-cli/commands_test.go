@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func newTestServer() (*server, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	s := newServer(newTerminal(out, time.Second))
+	s.connected.Store(true)
+	op, _ := rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/curl", make([]rule.Operator, 0))
+	s.rules = []*protocol.Rule{
+		rule.Create("000-allow-curl", "", true, false, false, rule.Allow, rule.Always, op).Serialize(),
+	}
+	return s, out
+}
+
+func TestCommands(t *testing.T) {
+	s, out := newTestServer()
+
+	s.command("rules")
+	if !strings.Contains(out.String(), "000-allow-curl") || !strings.Contains(out.String(), "process.path simple /usr/bin/curl") {
+		t.Errorf("the rules should be listed: %s", out.String())
+	}
+
+	s.command("disable 000-allow-curl")
+	ntf := <-s.notifications
+	if ntf.Type != protocol.Action_DISABLE_RULE || len(ntf.Rules) != 1 || ntf.Rules[0].Enabled {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	if s.rules[0].Enabled {
+		t.Error("the rule should be disabled")
+	}
+
+	s.command("set 000-allow-curl action deny")
+	ntf = <-s.notifications
+	if ntf.Type != protocol.Action_CHANGE_RULE || ntf.Rules[0].Action != string(rule.Deny) {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	out.Reset()
+	s.command("set 000-allow-curl action accept")
+	if !strings.Contains(out.String(), "invalid action") || len(s.notifications) != 0 {
+		t.Errorf("an invalid action should not be sent: %s", out.String())
+	}
+
+	s.command("set 000-allow-curl log full:file:/var/log/opensnitchd-curl.log")
+	ntf = <-s.notifications
+	if ntf.Type != protocol.Action_CHANGE_RULE || ntf.Rules[0].LogLevel != "full" || ntf.Rules[0].LogFile != "/var/log/opensnitchd-curl.log" {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	out.Reset()
+	s.command("set 000-allow-curl log full:journal")
+	if !strings.Contains(out.String(), "invalid log target") || len(s.notifications) != 0 {
+		t.Errorf("an invalid log directive should not be sent: %s", out.String())
+	}
+
+	s.command("set 000-allow-curl hook /usr/local/bin/ticket:deny:10s")
+	ntf = <-s.notifications
+	if ntf.Type != protocol.Action_CHANGE_RULE || ntf.Rules[0].HookPath != "/usr/local/bin/ticket" || ntf.Rules[0].HookOn[0] != "deny" || ntf.Rules[0].HookTimeout != "10s" {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	out.Reset()
+	s.command("set 000-allow-curl hook ticket")
+	if !strings.Contains(out.String(), "invalid hook") || len(s.notifications) != 0 {
+		t.Errorf("an invalid hook should not be sent: %s", out.String())
+	}
+
+	s.command("panic")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_ENABLE_PANIC_MODE {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("unpanic")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_DISABLE_PANIC_MODE {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	s.command("features")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_GET_FEATURES {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	s.command("dns")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_GET_DNS_CACHE {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("dns flush")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_FLUSH_DNS_CACHE {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("updates check")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_GET_UPDATES || ntf.Data != `{"check":true}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	s.command("decisions 5")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_GET_DECISIONS || ntf.Data != `{"limit":5}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("promote 11 allow")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_PROMOTE_DECISION || ntf.Data != `{"action":"allow","id":11}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	s.command("snapshots")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_GET_RULES_SNAPSHOTS || ntf.ClientName != clientName {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("snapshots 3")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_GET_RULES_SNAPSHOTS || ntf.Data != `{"id":3}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("rollback 3")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_ROLLBACK_RULES || ntf.Data != `{"id":3}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	s.command("proccache")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_TUNE_PROCESS_CACHE || ntf.Data != "" {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	s.command("proccache PidTTL 1m MaxEvents 16384")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_TUNE_PROCESS_CACHE || ntf.Data != `{"MaxEvents":16384,"PidTTL":"1m"}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	out.Reset()
+	s.command("proccache MaxSize 10")
+	if !strings.Contains(out.String(), "unknown option") || len(s.notifications) != 0 {
+		t.Errorf("an unknown option should not be sent: %s", out.String())
+	}
+
+	s.command("offline /usr/bin/curl 15m")
+	if ntf = <-s.notifications; ntf.Type != protocol.Action_BLOCK_APP || ntf.Data != `{"duration":"15m","process_path":"/usr/bin/curl"}` {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+	out.Reset()
+	s.command("offline /usr/bin/curl forever")
+	if !strings.Contains(out.String(), "invalid duration") || len(s.notifications) != 0 {
+		t.Errorf("an invalid duration should not be sent: %s", out.String())
+	}
+
+	s.command("delete 000-allow-curl")
+	ntf = <-s.notifications
+	if ntf.Type != protocol.Action_DELETE_RULE || ntf.Rules[0].Name != "000-allow-curl" || len(s.rules) != 0 {
+		t.Errorf("unexpected notification: %v", ntf)
+	}
+
+	s.connected.Store(false)
+	out.Reset()
+	s.command("delete 000-allow-curl")
+	if !strings.Contains(out.String(), "rule not found") {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+
+	if s.command("quit") {
+		t.Error("quit should exit")
+	}
+}
+
+func TestAskRule(t *testing.T) {
+	s, _ := newTestServer()
+
+	in, answers := io.Pipe()
+	go s.term.run(in, s.command)
+
+	go func() {
+		// wait for the prompt before answering
+		for {
+			s.term.Lock()
+			prompting := s.term.answers != nil
+			s.term.Unlock()
+			if prompting {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		io.WriteString(answers, "d\n9\n2\n")
+	}()
+
+	r, err := s.AskRule(nil, &protocol.Connection{
+		Protocol:    "tcp",
+		DstIp:       "185.53.178.14",
+		DstHost:     "opensnitch.io",
+		DstPort:     443,
+		ProcessId:   1234,
+		ProcessPath: "/usr/bin/curl",
+		UserId:      1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Action != string(rule.Deny) || r.Duration != string(rule.Always) || r.Operator.Operand != string(rule.OpDstHost) {
+		t.Errorf("unexpected rule: %v", r)
+	}
+	if len(s.rules) != 2 {
+		t.Errorf("the new rule should be listed: %d", len(s.rules))
+	}
+
+	// no answer
+	if _, err := s.AskRule(nil, &protocol.Connection{ProcessPath: "/usr/bin/curl", DstIp: "1.1.1.1"}); err == nil {
+		t.Error("the prompt should time out")
+	}
+	answers.Close()
+}
@@ -0,0 +1,125 @@
+// opensnitch-cli is a terminal client for the daemon, for servers and
+// headless hosts where the GUI can't be installed.
+//
+// It listens on the socket the GUI listens on, so the daemon connects to it
+// as if it was the GUI: the connections are prompted on the terminal, and the
+// rules can be listed and edited, and the connections followed live.
+// Only the daemons running as root can connect to the unix socket by default
+// (-allow-uids), and they can be required to know a token (-token-file).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/auth"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+	"google.golang.org/grpc"
+)
+
+var (
+	socket        = "unix:///tmp/osui.sock"
+	promptTimeout = 30 * time.Second
+	tailEvents    = false
+	tokenFile     = ""
+	allowUIDs     = "0"
+)
+
+func init() {
+	flag.StringVar(&socket, "socket", socket, "Address to listen on for the daemon (unix:///path or host:port). It must match the Server.Address option of the daemon.")
+	flag.DurationVar(&promptTimeout, "timeout", promptTimeout, "Time to wait for an answer to a prompt, before applying the default action.")
+	flag.BoolVar(&tailEvents, "tail", tailEvents, "Print the connections of the daemon as they're intercepted.")
+	flag.StringVar(&tokenFile, "token-file", tokenFile, "File with the token shared with the daemon (Server.Authentication.TokenFile). The daemons that don't know it are rejected, and the others verify that the server knows it too.")
+	flag.StringVar(&allowUIDs, "allow-uids", allowUIDs, "Comma separated list of UIDs allowed to connect to the unix socket. Empty to allow any.")
+}
+
+// parseUIDs parses a comma separated list of UIDs.
+func parseUIDs(list string) ([]uint32, error) {
+	var uids []uint32
+	for _, field := range strings.Split(list, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		uid, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uid: %s", field)
+		}
+		uids = append(uids, uint32(uid))
+	}
+	return uids, nil
+}
+
+// listen opens the socket where the daemon will connect to.
+func listen(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, "unix://") {
+		path := strings.TrimPrefix(addr, "unix://")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func main() {
+	flag.Parse()
+
+	uids, err := parseUIDs(allowUIDs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-allow-uids: %s\n", err)
+		os.Exit(1)
+	}
+	var opts []grpc.ServerOption
+	if tokenFile != "" {
+		token, err := auth.ReadToken(tokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		opts = auth.TokenServerOptions(token)
+	}
+
+	listener, err := listen(socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to listen on %s: %s\n", socket, err)
+		os.Exit(1)
+	}
+
+	term := newTerminal(os.Stdout, promptTimeout)
+	srv := newServer(term)
+	srv.tail.Store(tailEvents)
+	listener = &auth.PeerListener{
+		Listener: listener,
+		Allowed:  uids,
+		OnReject: func(err error) { term.printf("daemon rejected, %s\n", err) },
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	protocol.RegisterUIServer(grpcServer, srv)
+	go grpcServer.Serve(listener)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		term.run(os.Stdin, srv.command)
+		close(done)
+	}()
+
+	term.printf("listening on %s, waiting for the daemon to connect. Type 'help' to list the commands.\n", socket)
+	select {
+	case <-sigChan:
+	case <-done:
+	}
+	grpcServer.Stop()
+	if strings.HasPrefix(socket, "unix://") {
+		os.Remove(strings.TrimPrefix(socket, "unix://"))
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+	"golang.org/x/net/context"
+)
+
+// clientName identifies the changes of the rules made from this client.
+const clientName = "opensnitch-cli"
+
+// server implements the gRPC service of the GUI, which the daemon connects to.
+type server struct {
+	protocol.UnimplementedUIServer
+
+	term *terminal
+	// notifications to send to the daemon.
+	notifications chan *protocol.Notification
+	// rules of the daemon, in the order they're evaluated.
+	rules []*protocol.Rule
+	node  string
+	// time of the last connection printed.
+	lastEvent int64
+	tail      atomic.Bool
+	connected atomic.Bool
+
+	sync.Mutex
+}
+
+func newServer(term *terminal) *server {
+	return &server{
+		term:          term,
+		notifications: make(chan *protocol.Notification, 8),
+	}
+}
+
+// Ping receives the statistics of the daemon periodically.
+func (s *server) Ping(ctx context.Context, ping *protocol.PingRequest) (*protocol.PingReply, error) {
+	if ping.Stats != nil {
+		s.printEvents(ping.Stats.Events)
+	}
+	return &protocol.PingReply{Id: ping.Id}, nil
+}
+
+// Subscribe receives the configuration and the rules of the daemon, when it
+// connects.
+func (s *server) Subscribe(ctx context.Context, cfg *protocol.ClientConfig) (*protocol.ClientConfig, error) {
+	s.Lock()
+	s.rules = cfg.Rules
+	s.node = cfg.Name
+	s.Unlock()
+
+	s.term.printf("daemon %s connected (%s), %d rules\n", cfg.Name, cfg.Version, len(cfg.Rules))
+	return cfg, nil
+}
+
+// AskRule prompts the user what to do with a connection.
+func (s *server) AskRule(ctx context.Context, pcon *protocol.Connection) (*protocol.Rule, error) {
+	r, err := s.term.ask(deserializeConnection(pcon))
+	if err != nil {
+		return nil, err
+	}
+	pr := r.Serialize()
+	if r.Duration != rule.Once {
+		s.Lock()
+		s.rules = append(s.rules, pr)
+		s.Unlock()
+	}
+	return pr, nil
+}
+
+// PostAlert prints the alerts of the daemon.
+func (s *server) PostAlert(ctx context.Context, alert *protocol.Alert) (*protocol.MsgResponse, error) {
+	text := alert.GetText()
+	if r := alert.GetRule(); r != nil {
+		text = fmt.Sprintf("%s: %s", r.Description, formatOperator(r.Operator))
+	}
+	if text != "" {
+		s.term.printf("[%s] %s\n", alert.Type, text)
+	}
+	return &protocol.MsgResponse{Id: alert.Id}, nil
+}
+
+// Notifications sends the commands of the user to the daemon, and prints the
+// result of every command.
+func (s *server) Notifications(stream protocol.UI_NotificationsServer) error {
+	// the daemon sends a first message to open the channel.
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	s.connected.Store(true)
+	defer func() {
+		s.connected.Store(false)
+		s.term.printf("daemon disconnected\n")
+	}()
+
+	errChan := make(chan error, 1)
+	go func() {
+		for {
+			reply, err := stream.Recv()
+			if err != nil {
+				errChan <- err
+				return
+			}
+			switch {
+			case reply.Event != nil:
+				s.term.printf("%s\n", formatStateEvent(reply.Event))
+			case reply.Code == protocol.NotificationReplyCode_ERROR:
+				s.term.printf("error (%s): %s\n", reply.ErrorCode, reply.Data)
+			case reply.Data != "":
+				s.term.printf("%s\n", reply.Data)
+			default:
+				s.term.printf("ok\n")
+			}
+		}
+	}()
+
+	for {
+		select {
+		case ntf := <-s.notifications:
+			if err := stream.Send(ntf); err != nil {
+				return err
+			}
+		case err := <-errChan:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// notify sends a notification to the daemon.
+func (s *server) notify(action protocol.Action, rules ...*protocol.Rule) error {
+	return s.send(&protocol.Notification{Type: action, Rules: rules})
+}
+
+// notifyData sends a notification with the options of the action to the
+// daemon.
+func (s *server) notifyData(action protocol.Action, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.send(&protocol.Notification{Type: action, Data: string(raw)})
+}
+
+func (s *server) send(ntf *protocol.Notification) error {
+	if !s.connected.Load() {
+		return fmt.Errorf("the daemon is not connected")
+	}
+	ntf.Id = uint64(time.Now().UnixNano())
+	// the daemon records who changes the rules.
+	ntf.ClientName = clientName
+	select {
+	case s.notifications <- ntf:
+		return nil
+	default:
+		return fmt.Errorf("the daemon is not processing the commands, try again later")
+	}
+}
+
+// printEvents prints the connections not printed yet, if the user is
+// following them.
+func (s *server) printEvents(events []*protocol.Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	last := s.lastEvent
+	for _, ev := range events {
+		if ev.Unixnano <= s.lastEvent {
+			continue
+		}
+		if ev.Unixnano > last {
+			last = ev.Unixnano
+		}
+		if !s.tail.Load() || ev.Connection == nil {
+			continue
+		}
+		s.term.printf("%s\n", formatEvent(ev))
+	}
+	s.lastEvent = last
+}
+
+// formatStateEvent formats a change of the state of the daemon, sent without
+// being requested.
+func formatStateEvent(ev *protocol.StateEvent) string {
+	return fmt.Sprintf("[%s] %s (%s): %s",
+		time.Unix(0, ev.Time).Format("15:04:05"), ev.Type, ev.Source, ev.Data)
+}
+
+func formatEvent(ev *protocol.Event) string {
+	con := ev.Connection
+	dst := con.DstIp
+	if con.DstHost != "" {
+		dst = con.DstHost
+	}
+	action, name := "-", ""
+	if ev.Rule != nil {
+		action, name = ev.Rule.Action, ev.Rule.Name
+	}
+	return fmt.Sprintf("%s %-6s %s (%d) -> %s:%d %s %s",
+		ev.Time, action, con.ProcessPath, con.ProcessId, dst, con.DstPort, con.Protocol, name)
+}
+
+func formatOperator(op *protocol.Operator) string {
+	if op == nil {
+		return ""
+	}
+	if len(op.List) == 0 {
+		return fmt.Sprintf("%s %s %s", op.Operand, op.Type, op.Data)
+	}
+	ops := make([]string, len(op.List))
+	for i, o := range op.List {
+		ops[i] = formatOperator(o)
+	}
+	return strings.Join(ops, " && ")
+}
+
+// deserializeConnection builds the connection prompted to the user.
+func deserializeConnection(pcon *protocol.Connection) *conman.Connection {
+	proc := procmon.NewProcessEmpty(int(pcon.ProcessId), "")
+	proc.Path = pcon.ProcessPath
+	proc.Args = pcon.ProcessArgs
+	return &conman.Connection{
+		Protocol: pcon.Protocol,
+		SrcIP:    net.ParseIP(pcon.SrcIp),
+		SrcPort:  uint(pcon.SrcPort),
+		DstIP:    net.ParseIP(pcon.DstIp),
+		DstPort:  uint(pcon.DstPort),
+		DstHost:  pcon.DstHost,
+		Entry:    &netstat.Entry{UserId: int(pcon.UserId)},
+		Process:  proc,
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/prompt"
+)
+
+// terminal reads the commands of the user, and the answers to the prompts of
+// the connections. While a connection is being prompted, the lines read are
+// answers to the prompt.
+type terminal struct {
+	out     io.Writer
+	answers *io.PipeWriter
+	timeout time.Duration
+
+	// one prompt at a time
+	promptMu sync.Mutex
+	sync.Mutex
+}
+
+type promptResult struct {
+	rule *rule.Rule
+	err  error
+}
+
+func newTerminal(out io.Writer, timeout time.Duration) *terminal {
+	return &terminal{
+		out:     out,
+		timeout: timeout,
+	}
+}
+
+// printf writes to the terminal.
+func (t *terminal) printf(format string, args ...interface{}) {
+	t.Lock()
+	defer t.Unlock()
+	fmt.Fprintf(t.out, format, args...)
+}
+
+// Write lets the prompts write to the terminal.
+func (t *terminal) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.out.Write(p)
+}
+
+// run reads lines until the input is closed, or the command handler returns
+// false.
+func (t *terminal) run(in io.Reader, command func(line string) bool) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		t.Lock()
+		answers := t.answers
+		t.Unlock()
+		if answers != nil {
+			// the prompt has finished if the pipe is closed
+			if _, err := io.WriteString(answers, line+"\n"); err == nil {
+				continue
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !command(line) {
+			return
+		}
+	}
+}
+
+// ask prompts a connection, and returns the rule to apply to it.
+func (t *terminal) ask(con *conman.Connection) (*rule.Rule, error) {
+	t.promptMu.Lock()
+	defer t.promptMu.Unlock()
+
+	pr, pw := io.Pipe()
+	t.Lock()
+	t.answers = pw
+	t.Unlock()
+	defer func() {
+		t.Lock()
+		t.answers = nil
+		t.Unlock()
+		pr.Close()
+	}()
+
+	result := make(chan promptResult, 1)
+	go func() {
+		r, err := prompt.Ask(struct {
+			io.Reader
+			io.Writer
+		}{pr, t}, con)
+		result <- promptResult{r, err}
+	}()
+
+	select {
+	case res := <-result:
+		return res.rule, res.err
+	case <-time.After(t.timeout):
+		pw.CloseWithError(prompt.ErrTimeout)
+		<-result
+		t.printf("\n%s\n", prompt.ErrTimeout)
+		return nil, prompt.ErrTimeout
+	}
+}
@@ -0,0 +1,362 @@
+package conman
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// AccountingTableConfig holds the configuration of the accounting of the
+// connections allowed.
+type AccountingTableConfig struct {
+	// MaxFlows is the max number of connections tracked. 0 disables the
+	// accounting.
+	MaxFlows int `json:"MaxFlows"`
+	// ClosedEvents sends an event to the GUI for every connection closed.
+	ClosedEvents bool `json:"ClosedEvents"`
+	// UsageInterval is the interval to account the bytes and packets of the
+	// connections still active (30s, 1m, ...), so the long connections are
+	// accounted before they end. Empty to account them when they end.
+	UsageInterval string `json:"UsageInterval"`
+}
+
+// counters of a connection.
+type flowCounters struct {
+	bytesSent   uint64
+	bytesRecv   uint64
+	packetsSent uint64
+	packetsRecv uint64
+}
+
+// FlowUsage holds the bytes and packets carried by a connection allowed,
+// since the last time they were accounted.
+type FlowUsage struct {
+	Con         *Connection
+	Rule        string
+	BytesSent   uint64
+	BytesRecv   uint64
+	PacketsSent uint64
+	PacketsRecv uint64
+	// Ended is true if the connection has ended, and it's its last usage.
+	Ended bool
+}
+
+// newUsage returns the usage of a connection since the counters reported,
+// and updates them. The counters restart if conntrack replaced the entry.
+func newUsage(con *Connection, rule string, f *netlink.Flow, reported *flowCounters) *FlowUsage {
+	delta := func(now uint64, prev *uint64) uint64 {
+		d := now
+		if now >= *prev {
+			d = now - *prev
+		}
+		*prev = now
+		return d
+	}
+	return &FlowUsage{
+		Con:         con,
+		Rule:        rule,
+		BytesSent:   delta(f.BytesSent, &reported.bytesSent),
+		BytesRecv:   delta(f.BytesRecv, &reported.bytesRecv),
+		PacketsSent: delta(f.PacketsSent, &reported.packetsSent),
+		PacketsRecv: delta(f.PacketsRecv, &reported.packetsRecv),
+	}
+}
+
+// FlowEnd is a connection allowed that has ended, with the bytes and packets
+// it has carried.
+// The counters are 0 if the accounting of conntrack couldn't be enabled
+// (net.netfilter.nf_conntrack_acct).
+type FlowEnd struct {
+	Started     time.Time
+	Ended       time.Time
+	Con         *Connection
+	Rule        string
+	BytesSent   uint64
+	BytesRecv   uint64
+	PacketsSent uint64
+	PacketsRecv uint64
+
+	// usage of the connection not accounted yet.
+	usage *FlowUsage
+}
+
+// Usage returns the bytes and packets of the connection not accounted while
+// it was active.
+func (f *FlowEnd) Usage() *FlowUsage {
+	if f.usage == nil {
+		return &FlowUsage{Con: f.Con, Rule: f.Rule, BytesSent: f.BytesSent, BytesRecv: f.BytesRecv, PacketsSent: f.PacketsSent, PacketsRecv: f.PacketsRecv, Ended: true}
+	}
+	return f.usage
+}
+
+// Serialize returns the flow serialized.
+func (f *FlowEnd) Serialize() *protocol.Flow {
+	return &protocol.Flow{
+		Connection:  f.Con.Serialize(),
+		Rule:        f.Rule,
+		Started:     f.Started.UnixNano(),
+		Ended:       f.Ended.UnixNano(),
+		BytesSent:   f.BytesSent,
+		BytesRecv:   f.BytesRecv,
+		PacketsSent: f.PacketsSent,
+		PacketsRecv: f.PacketsRecv,
+	}
+}
+
+type accountingEntry struct {
+	started  time.Time
+	con      *Connection
+	rule     string
+	reported flowCounters
+}
+
+// AccountingTable tracks the connections allowed until they end, to account
+// the bytes and packets they've carried by rule and by process.
+//
+// The end of the connections is notified by conntrack, with the events of the
+// entries destroyed. If the events are lost, the table is purged when it's
+// full. The counters of the connections still active are read from the
+// conntrack table periodically, if it's configured.
+type AccountingTable struct {
+	flows        map[flowKey]*accountingEntry
+	onEnd        func(f *FlowEnd)
+	onUsage      func(u *FlowUsage)
+	stop         chan struct{}
+	maxFlows     int
+	closedEvents bool
+	mu           sync.Mutex
+}
+
+// Accounting is the table of the connections allowed.
+var Accounting = NewAccountingTable()
+
+// NewAccountingTable returns a new table, disabled until it's configured.
+func NewAccountingTable() *AccountingTable {
+	return &AccountingTable{
+		flows: make(map[flowKey]*accountingEntry),
+	}
+}
+
+// OnFlowEnd registers the function to call with every connection ended.
+func (a *AccountingTable) OnFlowEnd(cb func(f *FlowEnd)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onEnd = cb
+}
+
+// OnFlowUsage registers the function to call with the bytes and packets
+// carried by the connections, while they're active and when they end.
+func (a *AccountingTable) OnFlowUsage(cb func(u *FlowUsage)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onUsage = cb
+}
+
+// SetConfig configures the table, deleting the connections tracked, and
+// subscribes to the conntrack events if it's enabled.
+func (a *AccountingTable) SetConfig(cfg AccountingTableConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	a.maxFlows = cfg.MaxFlows
+	a.closedEvents = cfg.ClosedEvents
+	a.flows = make(map[flowKey]*accountingEntry)
+	log.Debug("[accounting] config, max flows: %d, closed events: %v", a.maxFlows, a.closedEvents)
+	if a.maxFlows <= 0 {
+		return
+	}
+
+	if err := netlink.EnableConntrackAcct(); err != nil {
+		log.Warning("[accounting] unable to enable conntrack accounting, the bytes and packets won't be counted: %s", err)
+	}
+	events := make(chan *netlink.Flow, 256)
+	stop := make(chan struct{})
+	if err := netlink.ConntrackDestroyEvents(events, stop); err != nil {
+		log.Warning("[accounting] unable to subscribe to conntrack events: %s", err)
+		return
+	}
+	a.stop = stop
+	go a.worker(events, stop)
+
+	if cfg.UsageInterval == "" {
+		return
+	}
+	if interval, err := time.ParseDuration(cfg.UsageInterval); err == nil && interval > 0 {
+		go a.sampler(interval, stop)
+	} else {
+		log.Warning("[accounting] invalid UsageInterval value: %s, the connections will be accounted when they end", cfg.UsageInterval)
+	}
+}
+
+// ClosedEvents returns true if the connections closed must be sent to the GUI.
+func (a *AccountingTable) ClosedEvents() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.closedEvents
+}
+
+func (a *AccountingTable) worker(events <-chan *netlink.Flow, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case f := <-events:
+			a.mu.Lock()
+			cb, usageCb := a.onEnd, a.onUsage
+			a.mu.Unlock()
+			end := a.End(f)
+			if end == nil {
+				continue
+			}
+			if usageCb != nil {
+				usageCb(end.Usage())
+			}
+			if cb != nil {
+				cb(end)
+			}
+		}
+	}
+}
+
+// sampler accounts the usage of the connections active every interval.
+func (a *AccountingTable) sampler(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if a.Len() == 0 {
+			continue
+		}
+		flows, err := netlink.ConntrackDump()
+		if err != nil {
+			log.Debug("[accounting] unable to dump the conntrack table: %s", err)
+			continue
+		}
+		a.mu.Lock()
+		cb := a.onUsage
+		a.mu.Unlock()
+		for _, u := range a.Update(flows) {
+			if cb != nil {
+				cb(u)
+			}
+		}
+	}
+}
+
+// Update returns the bytes and packets carried by the connections tracked
+// since the last time they were accounted, from the entries of conntrack.
+// The connections without new traffic are not returned.
+func (a *AccountingTable) Update(flows []*netlink.Flow) []*FlowUsage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	usage := []*FlowUsage{}
+	for _, f := range flows {
+		entry, found := a.flows[conntrackKey(f)]
+		if !found {
+			continue
+		}
+		u := newUsage(entry.con, entry.rule, f, &entry.reported)
+		if u.PacketsSent+u.PacketsRecv == 0 {
+			continue
+		}
+		usage = append(usage, u)
+	}
+	return usage
+}
+
+// Add tracks a connection allowed by a rule, or by the default action if the
+// rule is empty.
+func (a *AccountingTable) Add(con *Connection, ruleName string) {
+	key, ok := newAccountingKey(con)
+	if !ok {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.maxFlows <= 0 {
+		return
+	}
+	if _, found := a.flows[key]; !found && len(a.flows) >= a.maxFlows {
+		log.Debug("[accounting] table full (%d), purging", len(a.flows))
+		a.flows = make(map[flowKey]*accountingEntry)
+	}
+	a.flows[key] = &accountingEntry{
+		started: time.Now(),
+		con:     con,
+		rule:    ruleName,
+	}
+}
+
+// End stops tracking the connection of a conntrack entry destroyed, and
+// returns it with its counters, or nil if it wasn't tracked.
+func (a *AccountingTable) End(f *netlink.Flow) *FlowEnd {
+	key := conntrackKey(f)
+
+	a.mu.Lock()
+	entry, found := a.flows[key]
+	delete(a.flows, key)
+	a.mu.Unlock()
+	if !found {
+		return nil
+	}
+	usage := newUsage(entry.con, entry.rule, f, &entry.reported)
+	usage.Ended = true
+	return &FlowEnd{
+		usage:       usage,
+		Started:     entry.started,
+		Ended:       time.Now(),
+		Con:         entry.con,
+		Rule:        entry.rule,
+		BytesSent:   f.BytesSent,
+		BytesRecv:   f.BytesRecv,
+		PacketsSent: f.PacketsSent,
+		PacketsRecv: f.PacketsRecv,
+	}
+}
+
+// Len returns the number of connections tracked.
+func (a *AccountingTable) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.flows)
+}
+
+// conntrackKey returns the key of a conntrack entry.
+func conntrackKey(f *netlink.Flow) flowKey {
+	return flowKey{
+		proto:   f.Protocol(),
+		srcIP:   f.Src.String(),
+		dstIP:   f.Dst.String(),
+		srcPort: uint(f.SrcPort),
+		dstPort: uint(f.DstPort),
+	}
+}
+
+// newAccountingKey returns the key of a connection, with the protocol named
+// as in the conntrack entries (without the suffix 6 of IPv6).
+// ICMP is not tracked, because the conntrack entries have no ports.
+func newAccountingKey(con *Connection) (flowKey, bool) {
+	if con == nil || strings.HasPrefix(con.Protocol, "icmp") {
+		return flowKey{}, false
+	}
+	return flowKey{
+		proto:   strings.TrimSuffix(con.Protocol, "6"),
+		srcIP:   con.SrcIP.String(),
+		dstIP:   con.DstIP.String(),
+		srcPort: con.SrcPort,
+		dstPort: con.DstPort,
+	}, true
+}
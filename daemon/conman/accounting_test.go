@@ -0,0 +1,129 @@
+package conman
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+)
+
+func TestAccountingTable(t *testing.T) {
+	con := &Connection{
+		Protocol: "tcp6",
+		SrcIP:    net.ParseIP("2001:db8::10"),
+		SrcPort:  41234,
+		DstIP:    net.ParseIP("2001:db8::1"),
+		DstPort:  443,
+	}
+	icmp := &Connection{
+		Protocol: "icmp",
+		SrcIP:    net.ParseIP("192.168.1.10"),
+		DstIP:    net.ParseIP("9.9.9.9"),
+	}
+	flow := &netlink.Flow{
+		Src:         con.SrcIP,
+		Dst:         con.DstIP,
+		SrcPort:     41234,
+		DstPort:     443,
+		Proto:       syscall.IPPROTO_TCP,
+		BytesSent:   512,
+		BytesRecv:   4096,
+		PacketsSent: 4,
+		PacketsRecv: 6,
+	}
+
+	a := NewAccountingTable()
+	a.Add(con, "allow-https")
+	if a.Len() != 0 {
+		t.Error("the table should be disabled by default")
+	}
+
+	// configured without subscribing to the conntrack events.
+	a.maxFlows = 2
+	a.Add(con, "allow-https")
+	a.Add(icmp, "allow-icmp")
+	if a.Len() != 1 {
+		t.Error("only the connections with ports should be tracked:", a.Len())
+	}
+
+	end := a.End(flow)
+	if end == nil {
+		t.Fatal("connection ended not found")
+	}
+	if end.Con != con || end.Rule != "allow-https" || end.BytesRecv != 4096 || end.PacketsSent != 4 {
+		t.Errorf("invalid connection ended: %+v", end)
+	}
+	if end.Ended.Before(end.Started) {
+		t.Error("invalid duration:", end.Started, end.Ended)
+	}
+	if a.End(flow) != nil || a.Len() != 0 {
+		t.Error("connections ended should not be tracked anymore")
+	}
+
+	// the table is purged when it's full.
+	for port := uint(1); port <= 3; port++ {
+		c := *con
+		c.SrcPort = port
+		a.Add(&c, "")
+	}
+	if a.Len() != 1 {
+		t.Error("the table should have been purged:", a.Len())
+	}
+}
+
+func TestAccountingUsage(t *testing.T) {
+	con := &Connection{
+		Protocol: "tcp",
+		SrcIP:    net.ParseIP("192.168.1.10"),
+		SrcPort:  41234,
+		DstIP:    net.ParseIP("1.1.1.1"),
+		DstPort:  443,
+	}
+	flow := func(sent, recv uint64) *netlink.Flow {
+		return &netlink.Flow{
+			Src:         con.SrcIP,
+			Dst:         con.DstIP,
+			SrcPort:     41234,
+			DstPort:     443,
+			Proto:       syscall.IPPROTO_TCP,
+			BytesSent:   sent,
+			BytesRecv:   recv,
+			PacketsSent: sent / 100,
+			PacketsRecv: recv / 100,
+		}
+	}
+	other := flow(100, 100)
+	other.DstPort = 80
+
+	a := NewAccountingTable()
+	a.maxFlows = 10
+	a.Add(con, "allow-https")
+
+	usage := a.Update([]*netlink.Flow{flow(200, 1000), other})
+	if len(usage) != 1 || usage[0].Con != con || usage[0].Rule != "allow-https" || usage[0].BytesSent != 200 || usage[0].BytesRecv != 1000 || usage[0].PacketsRecv != 10 || usage[0].Ended {
+		t.Fatalf("invalid usage: %+v", usage)
+	}
+	if usage = a.Update([]*netlink.Flow{flow(200, 1000)}); len(usage) != 0 {
+		t.Errorf("connection without new traffic reported: %+v", usage)
+	}
+	if usage = a.Update([]*netlink.Flow{flow(300, 3000)}); len(usage) != 1 || usage[0].BytesSent != 100 || usage[0].BytesRecv != 2000 {
+		t.Errorf("invalid usage delta: %+v", usage)
+	}
+
+	// only the traffic not accounted yet is reported when it ends.
+	end := a.End(flow(400, 3000))
+	if end == nil || end.BytesSent != 400 {
+		t.Fatalf("invalid connection ended: %+v", end)
+	}
+	if u := end.Usage(); u.BytesSent != 100 || u.BytesRecv != 0 || u.PacketsSent != 1 || !u.Ended {
+		t.Errorf("invalid usage of the connection ended: %+v", u)
+	}
+
+	// the counters restart if conntrack replaces the entry.
+	a.Add(con, "allow-https")
+	a.Update([]*netlink.Flow{flow(500, 500)})
+	if usage = a.Update([]*netlink.Flow{flow(200, 100)}); len(usage) != 1 || usage[0].BytesSent != 200 || usage[0].BytesRecv != 100 {
+		t.Errorf("invalid usage after a reset: %+v", usage)
+	}
+}
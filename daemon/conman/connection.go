@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/dns"
@@ -26,6 +27,9 @@ type Connection struct {
 	Entry   *netstat.Entry
 	Process *procmon.Process
 
+	// scope and MAC of the destination, resolved once. See DstScope().
+	dst *dstInfo
+
 	Protocol string
 	DstHost  string
 	SrcIP    net.IP
@@ -33,6 +37,17 @@ type Connection struct {
 
 	SrcPort uint
 	DstPort uint
+
+	// Tags of the rule that matched the connection.
+	Tags []string
+
+	// Listener is true if it's a socket listening for connections instead of
+	// an outgoing connection. See NewListener().
+	Listener bool
+
+	// Origin is the app that sent the connection through a local proxy, if
+	// it's known. See ProxyTracker.
+	Origin *procmon.Process
 }
 
 var showUnknownCons = false
@@ -68,6 +83,40 @@ func Parse(nfp netfilter.Packet, interceptUnknown bool) *Connection {
 
 }
 
+// ParseHeaders returns the protocol and the addresses of a packet that
+// couldn't be parsed as a connection (i.e.: IGMP, or without process), in
+// order to apply it the default action of its protocol and destination.
+// It returns nil if it's not an IP packet.
+func ParseHeaders(nfp netfilter.Packet) *Connection {
+	if l := nfp.Packet.Layer(layers.LayerTypeIPv4); l != nil {
+		if ip, ok := l.(*layers.IPv4); ok {
+			return &Connection{
+				Protocol: strings.ToLower(ip.Protocol.String()),
+				SrcIP:    ip.SrcIP,
+				DstIP:    ip.DstIP,
+				Pkt:      &nfp,
+				dst:      &dstInfo{},
+			}
+		}
+	}
+	if l := nfp.Packet.Layer(layers.LayerTypeIPv6); l != nil {
+		if ip, ok := l.(*layers.IPv6); ok {
+			proto := strings.ToLower(ip.NextHeader.String()) + "6"
+			if ip.NextHeader == layers.IPProtocolICMPv6 {
+				proto = "icmp6"
+			}
+			return &Connection{
+				Protocol: proto,
+				SrcIP:    ip.SrcIP,
+				DstIP:    ip.DstIP,
+				Pkt:      &nfp,
+				dst:      &dstInfo{},
+			}
+		}
+	}
+	return nil
+}
+
 func newConnectionImpl(nfp *netfilter.Packet, c *Connection, protoType string) (cr *Connection, err error) {
 	// no errors but not enough info neither
 	if c.parseDirection(protoType) == false {
@@ -116,6 +165,7 @@ func newConnectionImpl(nfp *netfilter.Packet, c *Connection, protoType string) (
 				c.Process.ReadCwd()
 			}
 			c.Process.ReadEnv()
+			c.Process.ReadNamespaces()
 			c.Process.CleanPath()
 
 			procmon.EventsCache.Add(c.Process)
@@ -143,6 +193,15 @@ func newConnectionImpl(nfp *netfilter.Packet, c *Connection, protoType string) (
 		}
 
 		for n, inode := range inodeList {
+			// the owner of the socket learnt by eBPF, without scanning
+			// the descriptors of every process.
+			if procmon.MethodIsEbpf() {
+				if c.Process = ebpf.GetPidByInode(inode); c.Process != nil {
+					pid = c.Process.ID
+					c.Entry.INode = inode
+					break
+				}
+			}
 			pid = procmon.GetPIDFromINode(inode, fmt.Sprint(inode, c.SrcIP, c.SrcPort, c.DstIP, c.DstPort))
 			if pid != -1 {
 				log.Debug("[%d] PID found %d [%d]", n, pid, inode)
@@ -188,6 +247,7 @@ func NewConnection(nfp *netfilter.Packet) (c *Connection, err error) {
 		DstIP:   ip.DstIP,
 		DstHost: dns.HostOr(ip.DstIP, ""),
 		Pkt:     nfp,
+		dst:     &dstInfo{},
 	}
 
 	return newConnectionImpl(nfp, c, "")
@@ -208,6 +268,7 @@ func NewConnection6(nfp *netfilter.Packet) (c *Connection, err error) {
 		DstIP:   ip.DstIP,
 		DstHost: dns.HostOr(ip.DstIP, ""),
 		Pkt:     nfp,
+		dst:     &dstInfo{},
 	}
 	return newConnectionImpl(nfp, c, "6")
 }
@@ -234,6 +295,10 @@ func (c *Connection) parseDirection(protoType string) bool {
 
 			if udp.DstPort == 53 {
 				c.getDomains(c.Pkt, c)
+			} else if c.DstHost == "" {
+				// HTTP/3 connections are not always preceded by a DNS
+				// query (i.e.: DoH), but the SNI is sent in the first packet.
+				c.DstHost = QUICServerName(udp.Payload)
 			}
 		}
 	} else if udpliteLayer := c.Pkt.Packet.Layer(layers.LayerTypeUDPLite); udpliteLayer != nil {
@@ -323,20 +388,113 @@ func (c *Connection) String() string {
 func (c *Connection) Serialize() *protocol.Connection {
 	c.Process.RLock()
 	defer c.Process.RUnlock()
-	return &protocol.Connection{
-		Protocol:         c.Protocol,
-		SrcIp:            c.SrcIP.String(),
-		SrcPort:          uint32(c.SrcPort),
-		DstIp:            c.DstIP.String(),
-		DstHost:          c.DstHost,
-		DstPort:          uint32(c.DstPort),
-		UserId:           uint32(c.Entry.UserId),
-		ProcessId:        uint32(c.Process.ID),
-		ProcessPath:      c.Process.Path,
-		ProcessArgs:      c.Process.Args,
-		ProcessEnv:       c.Process.Env,
-		ProcessCwd:       c.Process.CWD,
-		ProcessChecksums: c.Process.Checksums,
-		ProcessTree:      c.Process.Tree,
+	var appName, appIcon string
+	if app := procmon.DesktopApps.Lookup(c.Process); app != nil {
+		appName = app.Name
+		appIcon = app.Icon
+	}
+	sandbox := procmon.DetectSandbox(c.Process)
+	if sandbox == nil {
+		sandbox = &procmon.Sandbox{}
+	}
+	pc := &protocol.Connection{
+		Protocol:             c.Protocol,
+		SrcIp:                c.SrcIP.String(),
+		SrcPort:              uint32(c.SrcPort),
+		DstIp:                c.DstIP.String(),
+		DstHost:              c.DstHost,
+		DstPort:              uint32(c.DstPort),
+		UserId:               uint32(c.Entry.UserId),
+		ProcessId:            uint32(c.Process.ID),
+		ProcessPath:          c.Process.Path,
+		ProcessArgs:          c.Process.Args,
+		ProcessEnv:           c.Process.Env,
+		ProcessCwd:           c.Process.CWD,
+		ProcessChecksums:     c.Process.Checksums,
+		ProcessTree:          c.Process.Tree,
+		ProcessAppName:       appName,
+		ProcessAppIcon:       appIcon,
+		ProcessPackageStatus: procmon.Packages.Verify(c.Process),
+		ProcessCapEff:        c.Process.CapEff,
+		ProcessMntNs:         c.Process.NS.Mnt,
+		ProcessNetNs:         c.Process.NS.Net,
+		ProcessUserNs:        c.Process.NS.User,
+		ProcessSecurityLabel: c.Process.SecurityLabel,
+		ProcessLibraries:     c.Process.GetLibraries(),
+		DstScope:             c.DstScope(),
+		DstMac:               c.DstMAC(),
+		Tags:                 c.Tags,
+		SandboxType:          sandbox.Type,
+		SandboxName:          sandbox.Name,
+		SandboxOwnerPath:     sandbox.OwnerPath,
+		SandboxOwnerPid:      uint32(sandbox.OwnerPID),
 	}
+	if c.Origin != nil {
+		pc.OriginPath = c.Origin.Path
+		pc.OriginPid = uint32(c.Origin.ID)
+	}
+	return pc
+}
+
+// Deserialize translates back a serialized connection to a Connection object.
+// The process details are not read from the system, so it can be used to
+// evaluate connections offline.
+func Deserialize(c *protocol.Connection) *Connection {
+	proc := procmon.NewProcessEmpty(int(c.ProcessId), "")
+	proc.Path = c.ProcessPath
+	proc.CWD = c.ProcessCwd
+	if c.ProcessArgs != nil {
+		proc.Args = c.ProcessArgs
+	}
+	if c.ProcessEnv != nil {
+		proc.Env = c.ProcessEnv
+	}
+	if c.ProcessChecksums != nil {
+		proc.Checksums = c.ProcessChecksums
+	}
+	if c.ProcessTree != nil {
+		proc.Tree = c.ProcessTree
+	}
+	if c.ProcessLibraries != nil {
+		proc.SetLibraries(c.ProcessLibraries)
+	}
+	// the first item of the tree is the process itself, the rest are its
+	// parents ordered from the most direct one.
+	child := proc
+	for i := 1; i < len(c.ProcessTree); i++ {
+		parent := procmon.NewProcessEmpty(int(c.ProcessTree[i].Value), "")
+		parent.Path = c.ProcessTree[i].Key
+		child.Parent = parent
+		child.PPID = parent.ID
+		child = parent
+	}
+
+	con := &Connection{
+		Pkt:      &netfilter.Packet{},
+		Process:  proc,
+		Protocol: c.Protocol,
+		DstHost:  c.DstHost,
+		SrcIP:    net.ParseIP(c.SrcIp),
+		DstIP:    net.ParseIP(c.DstIp),
+		SrcPort:  uint(c.SrcPort),
+		DstPort:  uint(c.DstPort),
+		Tags:     c.Tags,
+	}
+	if c.OriginPath != "" {
+		con.Origin = procmon.NewProcessEmpty(int(c.OriginPid), "")
+		con.Origin.Path = c.OriginPath
+	}
+	con.Entry = &netstat.Entry{
+		Proto:   con.Protocol,
+		SrcIP:   con.SrcIP,
+		SrcPort: con.SrcPort,
+		DstIP:   con.DstIP,
+		DstPort: con.DstPort,
+		UserId:  int(c.UserId),
+	}
+	// the scope of the destination is not looked up on this system, if it's
+	// known.
+	con.dst = &dstInfo{scope: c.DstScope, mac: c.DstMac}
+
+	return con
 }
@@ -125,3 +125,28 @@ func TestParseUDPDirection(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestParseHeaders(t *testing.T) {
+	// IGMPv3 membership report: 192.168.1.100 -> 224.0.0.22
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      1,
+		Protocol: layers.IPProtocolIGMP,
+		SrcIP:    net.IP{192, 168, 1, 100},
+		DstIP:    net.IP{224, 0, 0, 22},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, gopacket.Payload{0x22, 0x00, 0xf9, 0x02, 0x00, 0x00, 0x00, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	pkt := NewPacket(gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default))
+
+	c := ParseHeaders(*pkt)
+	if c == nil {
+		t.Fatal("ParseHeaders() should not be nil")
+	}
+	if c.Protocol != "igmp" || !c.DstIP.Equal(ip.DstIP) || !c.SrcIP.Equal(ip.SrcIP) {
+		t.Error("ParseHeaders() mismatch:", c)
+	}
+}
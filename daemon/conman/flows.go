@@ -0,0 +1,181 @@
+package conman
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+var defaultUDPTimeout = 30 * time.Second
+
+// FlowTableConfig holds the configuration of the UDP flows table.
+type FlowTableConfig struct {
+	// UDPTimeout is the time after which an idle UDP flow expires (30s by
+	// default, like the conntrack timeout of unreplied UDP flows).
+	UDPTimeout string `json:"UDPTimeout"`
+	// MaxFlows is the max number of flows tracked. 0 disables the table.
+	MaxFlows int `json:"MaxFlows"`
+	// InKernel applies the verdicts of the flows in kernel for UDPTimeout,
+	// without queueing their following packets (nftables only). They're
+	// deleted when the rules change.
+	InKernel bool `json:"InKernel"`
+}
+
+// flowKey identifies the packets of a UDP pseudo-connection.
+type flowKey struct {
+	proto   string
+	srcIP   string
+	dstIP   string
+	srcPort uint
+	dstPort uint
+}
+
+type flowEntry struct {
+	verdict    interface{}
+	lastSeen   time.Time
+	packets    uint64
+	generation uint64
+}
+
+// FlowTable tracks the UDP pseudo-connections already verdicted.
+//
+// Only the first packet of a flow should reach the queue, but until conntrack
+// confirms the flow, or when the packets are dropped, every packet is queued
+// as a new connection: DNS queries of the same socket, retransmissions, QUIC
+// handshakes... The packets of a known flow get the verdict of the first one,
+// without prompting the user again nor counting them as new connections.
+//
+// The flows are invalidated when they're idle for UDPTimeout, or when the
+// rules change (a different generation of the rules).
+type FlowTable struct {
+	flows    map[flowKey]*flowEntry
+	timeout  time.Duration
+	maxFlows int
+	inKernel bool
+	mu       sync.Mutex
+}
+
+// Flows is the table of the UDP flows.
+var Flows = NewFlowTable()
+
+// NewFlowTable returns a new table, disabled until it's configured.
+func NewFlowTable() *FlowTable {
+	return &FlowTable{
+		flows:   make(map[flowKey]*flowEntry),
+		timeout: defaultUDPTimeout,
+	}
+}
+
+// SetConfig configures the limits of the table, deleting the flows tracked.
+func (f *FlowTable) SetConfig(cfg FlowTableConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.maxFlows = cfg.MaxFlows
+	f.inKernel = cfg.InKernel
+	f.timeout = defaultUDPTimeout
+	if timeout, err := time.ParseDuration(cfg.UDPTimeout); err == nil && timeout > 0 {
+		f.timeout = timeout
+	} else if cfg.UDPTimeout != "" {
+		log.Warning("[flows] invalid UDPTimeout value: %s, using default (%s)", cfg.UDPTimeout, f.timeout)
+	}
+	f.flows = make(map[flowKey]*flowEntry)
+	log.Debug("[flows] config, max flows: %d, UDP timeout: %s, in kernel: %v", f.maxFlows, f.timeout, f.inKernel)
+}
+
+// InKernel returns true if the verdicts of the flows must be applied in
+// kernel, and the time during which they're applied.
+func (f *FlowTable) InKernel() (bool, time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inKernel, f.timeout
+}
+
+// Get returns the verdict of the flow of a connection, if it's been verdicted
+// with the same generation of the rules and it's not expired.
+func (f *FlowTable) Get(con *Connection, generation uint64) (interface{}, bool) {
+	key, ok := newFlowKey(con)
+	if !ok {
+		return nil, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.maxFlows <= 0 {
+		return nil, false
+	}
+	entry, found := f.flows[key]
+	if !found {
+		return nil, false
+	}
+	now := time.Now()
+	if entry.generation != generation || now.Sub(entry.lastSeen) > f.timeout {
+		delete(f.flows, key)
+		return nil, false
+	}
+	entry.lastSeen = now
+	entry.packets++
+	return entry.verdict, true
+}
+
+// Add tracks the flow of a connection, verdicted with the given generation of
+// the rules.
+func (f *FlowTable) Add(con *Connection, generation uint64, verdict interface{}) {
+	key, ok := newFlowKey(con)
+	if !ok {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.maxFlows <= 0 {
+		return
+	}
+	now := time.Now()
+	if _, found := f.flows[key]; !found && len(f.flows) >= f.maxFlows {
+		f.deleteExpired(now)
+		if len(f.flows) >= f.maxFlows {
+			log.Debug("[flows] table full (%d), purging", len(f.flows))
+			f.flows = make(map[flowKey]*flowEntry)
+		}
+	}
+	f.flows[key] = &flowEntry{
+		verdict:    verdict,
+		lastSeen:   now,
+		packets:    1,
+		generation: generation,
+	}
+}
+
+// Len returns the number of flows tracked, expired or not.
+func (f *FlowTable) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.flows)
+}
+
+// deleteExpired deletes the idle flows.
+// The caller must hold the lock.
+func (f *FlowTable) deleteExpired(now time.Time) {
+	for key, entry := range f.flows {
+		if now.Sub(entry.lastSeen) > f.timeout {
+			log.Trace("[flows] expired %s %s:%d -> %s:%d, packets: %d", key.proto, key.srcIP, key.srcPort, key.dstIP, key.dstPort, entry.packets)
+			delete(f.flows, key)
+		}
+	}
+}
+
+func newFlowKey(con *Connection) (flowKey, bool) {
+	if con == nil || !strings.HasPrefix(con.Protocol, "udp") {
+		return flowKey{}, false
+	}
+	return flowKey{
+		proto:   con.Protocol,
+		srcIP:   con.SrcIP.String(),
+		dstIP:   con.DstIP.String(),
+		srcPort: con.SrcPort,
+		dstPort: con.DstPort,
+	}, true
+}
@@ -0,0 +1,88 @@
+package conman
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFlowTable(t *testing.T) {
+	con := &Connection{
+		Protocol: "udp",
+		SrcIP:    net.ParseIP("192.168.1.10"),
+		SrcPort:  41234,
+		DstIP:    net.ParseIP("9.9.9.9"),
+		DstPort:  53,
+	}
+	other := *con
+	other.SrcPort = 41235
+	tcp := *con
+	tcp.Protocol = "tcp"
+
+	f := NewFlowTable()
+	f.Add(con, 1, "allow")
+	if _, found := f.Get(con, 1); found {
+		t.Error("the table should be disabled by default")
+	}
+
+	if inKernel, _ := f.InKernel(); inKernel {
+		t.Error("the verdicts in kernel should be disabled by default")
+	}
+
+	f.SetConfig(FlowTableConfig{MaxFlows: 2, UDPTimeout: "1h"})
+	f.Add(con, 1, "allow")
+	f.Add(&tcp, 1, "allow")
+	if verdict, found := f.Get(con, 1); !found || verdict != "allow" {
+		t.Errorf("flow not tracked: %v, %v", verdict, found)
+	}
+	if _, found := f.Get(&other, 1); found {
+		t.Error("the packets of other sockets are other flows")
+	}
+	if _, found := f.Get(&tcp, 1); found || f.Len() != 1 {
+		t.Error("only UDP flows should be tracked")
+	}
+
+	// the rules have changed
+	if _, found := f.Get(con, 2); found || f.Len() != 0 {
+		t.Error("flows verdicted with a previous generation of the rules should be deleted")
+	}
+
+	f.SetConfig(FlowTableConfig{MaxFlows: 2, UDPTimeout: "50ms"})
+	f.Add(con, 2, "deny")
+	time.Sleep(20 * time.Millisecond)
+	// every packet refreshes the flow
+	if _, found := f.Get(con, 2); !found {
+		t.Error("flow expired before the timeout")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, found := f.Get(con, 2); !found {
+		t.Error("flow expired while active")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, found := f.Get(con, 2); found {
+		t.Error("idle flows should expire")
+	}
+
+	// expired flows are deleted when the table is full
+	f.Add(con, 2, "deny")
+	f.Add(&other, 2, "deny")
+	time.Sleep(60 * time.Millisecond)
+	third := other
+	third.SrcPort = 41236
+	f.Add(&third, 2, "deny")
+	if f.Len() != 1 {
+		t.Errorf("expired flows not deleted, %d flows", f.Len())
+	}
+}
+
+func TestFlowTableInKernel(t *testing.T) {
+	f := NewFlowTable()
+	f.SetConfig(FlowTableConfig{UDPTimeout: "1m", InKernel: true})
+	if inKernel, timeout := f.InKernel(); !inKernel || timeout != time.Minute {
+		t.Errorf("invalid in kernel config: %v, %s", inKernel, timeout)
+	}
+	// the verdicts in kernel don't depend on the table.
+	if _, found := f.Get(&Connection{Protocol: "udp"}, 1); found {
+		t.Error("the table should be disabled without MaxFlows")
+	}
+}
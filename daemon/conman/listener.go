@@ -0,0 +1,35 @@
+package conman
+
+import (
+	"net"
+
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+// NewListener returns a connection representing a socket listening for
+// connections, to evaluate it against the rules. The local address is the
+// destination of the connections it accepts, so it's set as the destination
+// (dest.ip, dest.port), and the source is left empty.
+func NewListener(proc *procmon.Process, proto string, ip net.IP, port uint, uid, inode int) *Connection {
+	con := &Connection{
+		Pkt:      &netfilter.Packet{},
+		Process:  proc,
+		Protocol: proto,
+		SrcIP:    net.IPv4zero,
+		DstIP:    ip,
+		DstPort:  port,
+		Listener: true,
+		dst:      &dstInfo{},
+	}
+	con.Entry = &netstat.Entry{
+		Proto:   proto,
+		SrcIP:   con.SrcIP,
+		DstIP:   ip,
+		DstPort: port,
+		UserId:  uid,
+		INode:   inode,
+	}
+	return con
+}
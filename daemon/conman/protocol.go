@@ -0,0 +1,113 @@
+package conman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// payloadCheck returns true if the payload looks like the expected protocol.
+type payloadCheck func(payload []byte) bool
+
+// protocols expected on the well-known ports, by transport protocol.
+var wellKnownPorts = map[string]map[uint]payloadCheck{
+	"tcp": {
+		22:  isSSH,
+		53:  isDNSOverTCP,
+		80:  isHTTP,
+		443: isTLS,
+		465: isTLS,
+		853: isTLS,
+		993: isTLS,
+		995: isTLS,
+	},
+	"udp": {
+		53:  isDNS,
+		123: isNTP,
+		443: isQUIC,
+	},
+}
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("HEAD "), []byte("PUT "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+	// HTTP/2 connection preface
+	[]byte("PRI * HTTP/2.0"),
+}
+
+// Payload returns the payload of the packet of the connection.
+func (c *Connection) Payload() []byte {
+	if c.Pkt == nil || c.Pkt.Packet == nil || c.Pkt.Packet.TransportLayer() == nil {
+		return nil
+	}
+	return c.Pkt.Packet.TransportLayer().LayerPayload()
+}
+
+// ProtocolMismatch returns true if the payload of the connection doesn't look
+// like the protocol expected on the destination port (i.e.: not TLS on 443/tcp,
+// not DNS on 53/udp), which may be a sign of tunneling over innocuous ports.
+//
+// Only the first packet of a connection is inspected. For TCP it's usually a
+// SYN without payload, so it's only verified with TCP Fast Open. Connections
+// without payload, or to ports not verified, never mismatch.
+func (c *Connection) ProtocolMismatch() bool {
+	checks, found := wellKnownPorts[strings.TrimSuffix(c.Protocol, "6")]
+	if !found {
+		return false
+	}
+	check, found := checks[c.DstPort]
+	if !found {
+		return false
+	}
+	payload := c.Payload()
+	if len(payload) == 0 {
+		return false
+	}
+	return !check(payload)
+}
+
+func isTLS(p []byte) bool {
+	// handshake record, version 3.x
+	return len(p) >= 3 && p[0] == 0x16 && p[1] == 0x03 && p[2] <= 0x04
+}
+
+func isHTTP(p []byte) bool {
+	for _, m := range httpMethods {
+		if bytes.HasPrefix(p, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSSH(p []byte) bool {
+	return bytes.HasPrefix(p, []byte("SSH-"))
+}
+
+func isDNS(p []byte) bool {
+	if len(p) < 12 {
+		return false
+	}
+	// a query (QR bit unset), with a standard opcode and at least one question.
+	opcode := (p[2] >> 3) & 0x0f
+	return p[2]&0x80 == 0 && opcode <= 5 && binary.BigEndian.Uint16(p[4:6]) > 0
+}
+
+func isDNSOverTCP(p []byte) bool {
+	// prefixed by the length of the message
+	return len(p) >= 14 && binary.BigEndian.Uint16(p[:2]) >= 12 && isDNS(p[2:])
+}
+
+func isNTP(p []byte) bool {
+	if len(p) < 48 {
+		return false
+	}
+	version := (p[0] >> 3) & 0x07
+	mode := p[0] & 0x07
+	return version >= 1 && version <= 4 && mode >= 1 && mode <= 5
+}
+
+func isQUIC(p []byte) bool {
+	// the fixed bit is set in long and short headers (RFC 9000, 17).
+	return p[0]&0x40 != 0
+}
@@ -0,0 +1,76 @@
+package conman
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func newPayloadConnection(t *testing.T, proto string, port uint, payload []byte) *Connection {
+	ip := &layers.IPv4{
+		Version: 4,
+		TTL:     64,
+		SrcIP:   net.IP{192, 168, 1, 100},
+		DstIP:   net.IP{1, 1, 1, 1},
+	}
+	var transport gopacket.SerializableLayer
+	if proto == "tcp" {
+		ip.Protocol = layers.IPProtocolTCP
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: layers.TCPPort(port), SYN: true}
+		tcp.SetNetworkLayerForChecksum(ip)
+		transport = tcp
+	} else {
+		ip.Protocol = layers.IPProtocolUDP
+		udp := &layers.UDP{SrcPort: 40000, DstPort: layers.UDPPort(port)}
+		udp.SetNetworkLayerForChecksum(ip)
+		transport = udp
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip, transport, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	pkt := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)
+	return &Connection{
+		Pkt:      NewPacket(pkt),
+		Protocol: proto,
+		DstPort:  port,
+	}
+}
+
+func TestProtocolMismatch(t *testing.T) {
+	ntp := make([]byte, 48)
+	ntp[0] = 0x23 // v4, client
+	dnsQuery := NewUDPPacket().TransportLayer().LayerPayload()
+
+	tests := []struct {
+		name     string
+		proto    string
+		port     uint
+		payload  []byte
+		mismatch bool
+	}{
+		{"TLS on 443", "tcp", 443, []byte{0x16, 0x03, 0x01, 0x02, 0x00}, false},
+		{"HTTP on 443", "tcp", 443, []byte("GET / HTTP/1.1\r\n"), true},
+		{"HTTP on 80", "tcp", 80, []byte("GET / HTTP/1.1\r\n"), false},
+		{"SSH on 80", "tcp", 80, []byte("SSH-2.0-OpenSSH_9.6\r\n"), true},
+		{"SYN without payload", "tcp", 443, nil, false},
+		{"DNS on 53", "udp", 53, dnsQuery, false},
+		{"garbage on 53", "udp", 53, []byte("tunneled data, not a dns query"), true},
+		{"NTP on 123", "udp", 123, ntp, false},
+		{"DNS on 123", "udp", 123, dnsQuery, true},
+		{"QUIC on 443", "udp", 443, []byte{0xc3, 0x00, 0x00, 0x00, 0x01}, false},
+		{"not QUIC on 443", "udp", 443, []byte{0x00, 0x01, 0x02}, true},
+		{"port not verified", "udp", 5000, []byte("anything"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			con := newPayloadConnection(t, test.proto, test.port, test.payload)
+			if mismatch := con.ProtocolMismatch(); mismatch != test.mismatch {
+				t.Errorf("ProtocolMismatch() = %v, want %v", mismatch, test.mismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package conman
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+var defaultProxyWindow = 5 * time.Second
+
+// LocalProxiesConfig holds the configuration of the local proxies, whose
+// connections are attributed to the apps that connected to them.
+type LocalProxiesConfig struct {
+	// Ports where the proxies listen on the loopback addresses: 9050 (tor),
+	// 1080 (socks), 8118 (privoxy), 3128 (squid)... Empty to disable it.
+	Ports []uint `json:"Ports"`
+	// Window is the max time between the connection of an app to a proxy,
+	// and the connections of the proxy attributed to it (5s by default).
+	Window string `json:"Window"`
+}
+
+type proxyClient struct {
+	process  *procmon.Process
+	lastSeen time.Time
+}
+
+type proxyListener struct {
+	pid      int
+	lastSeen time.Time
+}
+
+// ProxyTracker attributes the outbound connections of the local proxies to
+// the apps that connected to them: app -> 127.0.0.1:9050 -> tor -> relay.
+//
+// The proxies don't tell who a connection is for, so the connections of a
+// proxy are attributed to the app that connected to it in the last Window.
+// If several apps did, the origin is ambiguous, and it's not attributed.
+// Only the TCP proxies of the loopback addresses are tracked: the connections
+// to unix sockets are not seen by netfilter.
+type ProxyTracker struct {
+	ports  map[uint]bool
+	window time.Duration
+	// apps connected to every proxy, by the PID of the proxy.
+	clients map[int][]proxyClient
+	// processes listening on the ports of the proxies.
+	listeners map[uint]proxyListener
+	mu        sync.Mutex
+}
+
+// Proxies is the tracker of the local proxies.
+var Proxies = NewProxyTracker()
+
+// lookup of the process listening on a local port, replaced by the tests.
+var listenerPID = findListenerPID
+
+// NewProxyTracker returns a new tracker, disabled until it's configured.
+func NewProxyTracker() *ProxyTracker {
+	return &ProxyTracker{
+		ports:     make(map[uint]bool),
+		window:    defaultProxyWindow,
+		clients:   make(map[int][]proxyClient),
+		listeners: make(map[uint]proxyListener),
+	}
+}
+
+// SetConfig configures the ports of the proxies, deleting the apps tracked.
+func (p *ProxyTracker) SetConfig(cfg LocalProxiesConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ports = make(map[uint]bool, len(cfg.Ports))
+	for _, port := range cfg.Ports {
+		p.ports[port] = true
+	}
+	p.window = defaultProxyWindow
+	if window, err := time.ParseDuration(cfg.Window); err == nil && window > 0 {
+		p.window = window
+	} else if cfg.Window != "" {
+		log.Warning("[proxies] invalid Window value: %s, using default (%s)", cfg.Window, p.window)
+	}
+	p.clients = make(map[int][]proxyClient)
+	p.listeners = make(map[uint]proxyListener)
+	log.Debug("[proxies] config, ports: %v, window: %s", cfg.Ports, p.window)
+}
+
+// Track records the app of a connection to a local proxy.
+func (p *ProxyTracker) Track(con *Connection) {
+	if con.Process == nil || con.DstIP == nil || !con.DstIP.IsLoopback() {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.ports[con.DstPort] {
+		return
+	}
+
+	now := time.Now()
+	lst, found := p.listeners[con.DstPort]
+	if !found || now.Sub(lst.lastSeen) > p.window {
+		lst = proxyListener{pid: listenerPID(con.DstIP, con.DstPort), lastSeen: now}
+		p.listeners[con.DstPort] = lst
+	}
+	if lst.pid <= 0 || lst.pid == con.Process.ID {
+		return
+	}
+
+	clients := p.expire(lst.pid, now)
+	for i := range clients {
+		if clients[i].process.ID == con.Process.ID {
+			clients = append(clients[:i], clients[i+1:]...)
+			break
+		}
+	}
+	p.clients[lst.pid] = append(clients, proxyClient{process: con.Process, lastSeen: now})
+	log.Debug("[proxies] %s (%d) connected to the proxy %d:%d", con.Process.Path, con.Process.ID, lst.pid, con.DstPort)
+}
+
+// Origin returns the app a connection of a local proxy is for, or nil if
+// it's not a proxy, or the origin is ambiguous.
+func (p *ProxyTracker) Origin(con *Connection) *procmon.Process {
+	if con.Process == nil || (con.DstIP != nil && con.DstIP.IsLoopback()) {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.clients) == 0 {
+		return nil
+	}
+
+	clients := p.expire(con.Process.ID, time.Now())
+	if len(clients) == 0 {
+		return nil
+	}
+	origin := clients[len(clients)-1].process
+	for _, c := range clients {
+		if c.process.Path != origin.Path {
+			log.Debug("[proxies] ambiguous origin of %s: %s, %s", con.Process.Path, c.process.Path, origin.Path)
+			return nil
+		}
+	}
+	return origin
+}
+
+// expire deletes the apps of a proxy not seen in the last window, and returns
+// the rest. The caller must hold the lock.
+func (p *ProxyTracker) expire(pid int, now time.Time) []proxyClient {
+	clients := p.clients[pid]
+	i := 0
+	for i < len(clients) && now.Sub(clients[i].lastSeen) > p.window {
+		i++
+	}
+	if i == len(clients) {
+		delete(p.clients, pid)
+		return nil
+	}
+	p.clients[pid] = clients[i:]
+	return clients[i:]
+}
+
+// findListenerPID returns the PID of the process listening on a local TCP port.
+func findListenerPID(ip net.IP, port uint) int {
+	fam := uint8(syscall.AF_INET)
+	if ip.To4() == nil {
+		fam = syscall.AF_INET6
+	}
+	socks, err := netlink.SocketsDump(fam, syscall.IPPROTO_TCP)
+	if err != nil {
+		log.Debug("[proxies] unable to dump the sockets: %s", err)
+		return -1
+	}
+	for _, s := range socks {
+		if s.State != netlink.TCP_LISTEN || uint(s.ID.SourcePort) != port || s.INode == 0 {
+			continue
+		}
+		if !s.ID.Source.IsLoopback() && !s.ID.Source.IsUnspecified() {
+			continue
+		}
+		return procmon.GetPIDFromINode(int(s.INode), fmt.Sprint(s.INode, s.ID.Source, port))
+	}
+	return -1
+}
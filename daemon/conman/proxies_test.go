@@ -0,0 +1,70 @@
+package conman
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+func newProxyConn(pid int, path, dstIP string, dstPort uint) *Connection {
+	proc := procmon.NewProcessEmpty(pid, "")
+	proc.Path = path
+	return &Connection{
+		Protocol: "tcp",
+		SrcIP:    net.ParseIP("127.0.0.1"),
+		DstIP:    net.ParseIP(dstIP),
+		DstPort:  dstPort,
+		Process:  proc,
+	}
+}
+
+func TestProxyTracker(t *testing.T) {
+	orig := listenerPID
+	defer func() { listenerPID = orig }()
+	listenerPID = func(ip net.IP, port uint) int {
+		if port == 9050 {
+			return 100
+		}
+		return -1
+	}
+
+	curl := newProxyConn(200, "/usr/bin/curl", "127.0.0.1", 9050)
+	wget := newProxyConn(300, "/usr/bin/wget", "127.0.0.1", 9050)
+	relay := newProxyConn(100, "/usr/bin/tor", "185.220.101.1", 443)
+
+	p := NewProxyTracker()
+	p.Track(curl)
+	if origin := p.Origin(relay); origin != nil {
+		t.Error("the tracker should be disabled by default:", origin.Path)
+	}
+
+	p.SetConfig(LocalProxiesConfig{Ports: []uint{9050, 1080}, Window: "50ms"})
+	p.Track(curl)
+	p.Track(newProxyConn(200, "/usr/bin/curl", "127.0.0.1", 1080))
+	if origin := p.Origin(relay); origin == nil || origin.Path != "/usr/bin/curl" {
+		t.Errorf("origin not attributed: %v", origin)
+	}
+	if origin := p.Origin(newProxyConn(400, "/usr/bin/firefox", "185.220.101.1", 443)); origin != nil {
+		t.Error("origin attributed to a process that is not a proxy:", origin.Path)
+	}
+	// the connections of the proxy to itself, or to other local services.
+	if origin := p.Origin(newProxyConn(100, "/usr/bin/tor", "127.0.0.1", 9051)); origin != nil {
+		t.Error("origin attributed to a local connection:", origin.Path)
+	}
+
+	p.Track(wget)
+	if origin := p.Origin(relay); origin != nil {
+		t.Error("ambiguous origin attributed:", origin.Path)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if origin := p.Origin(relay); origin != nil {
+		t.Error("origin attributed after the window:", origin.Path)
+	}
+	p.Track(wget)
+	if origin := p.Origin(relay); origin == nil || origin.Path != "/usr/bin/wget" {
+		t.Errorf("origin not attributed after the window: %v", origin)
+	}
+}
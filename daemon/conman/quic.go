@@ -0,0 +1,283 @@
+package conman
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// QUIC versions whose Initial packets can be decrypted (RFC 9001, RFC 9369).
+const (
+	quicV1 = 0x00000001
+	quicV2 = 0x6b3343cf
+)
+
+type quicVersion struct {
+	salt []byte
+	// labels to derive the key, iv and header protection key.
+	keyLabel, ivLabel, hpLabel string
+	// long header packet type of the Initial packets.
+	initialType byte
+}
+
+var quicVersions = map[uint32]quicVersion{
+	quicV1: {
+		salt:     []byte{0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a},
+		keyLabel: "quic key", ivLabel: "quic iv", hpLabel: "quic hp",
+		initialType: 0,
+	},
+	quicV2: {
+		salt:     []byte{0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9},
+		keyLabel: "quicv2 key", ivLabel: "quicv2 iv", hpLabel: "quicv2 hp",
+		initialType: 1,
+	},
+}
+
+var errQUICTruncated = errors.New("truncated QUIC packet")
+
+// QUICServerName returns the server name (SNI) of the TLS ClientHello sent in
+// the QUIC Initial packet of a UDP payload, or an empty string if the payload
+// is not a QUIC Initial packet, or the SNI is not in it.
+//
+// The Initial packets are encrypted with keys derived from the destination
+// connection ID, which is sent in clear text, so they can be decrypted by
+// anyone (RFC 9001, 5.2). If the ClientHello doesn't fit in the first packet,
+// only the part received is inspected.
+func QUICServerName(payload []byte) string {
+	// long header with the fixed bit set
+	if len(payload) < 7 || payload[0]&0xc0 != 0xc0 {
+		return ""
+	}
+	version, found := quicVersions[binary.BigEndian.Uint32(payload[1:5])]
+	if !found || (payload[0]>>4)&0x03 != version.initialType {
+		return ""
+	}
+	plain, err := openQUICInitial(payload, version)
+	if err != nil {
+		return ""
+	}
+	return tlsServerName(quicCryptoData(plain))
+}
+
+// openQUICInitial removes the header protection of an Initial packet and
+// decrypts its payload (RFC 9001, 5).
+func openQUICInitial(p []byte, version quicVersion) ([]byte, error) {
+	off := 5
+	dcidLen := int(p[off])
+	off++
+	if dcidLen > 20 || len(p) < off+dcidLen+1 {
+		return nil, errQUICTruncated
+	}
+	dcid := p[off : off+dcidLen]
+	off += dcidLen
+	scidLen := int(p[off])
+	off += 1 + scidLen
+	tokenLen, n := quicVarint(p, off)
+	if n == 0 {
+		return nil, errQUICTruncated
+	}
+	off += n + int(tokenLen)
+	length, n := quicVarint(p, off)
+	if n == 0 {
+		return nil, errQUICTruncated
+	}
+	off += n
+	pnOffset := off
+	// the sample for the header protection starts 4 bytes after the packet
+	// number, and length includes the packet number and the AEAD tag.
+	if length < 20 || uint64(len(p)-pnOffset) < length {
+		return nil, errQUICTruncated
+	}
+
+	key, iv, hp, err := quicClientKeys(version, dcid)
+	if err != nil {
+		return nil, err
+	}
+	hpBlock, err := aes.NewCipher(hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	hpBlock.Encrypt(mask, p[pnOffset+4:pnOffset+4+aes.BlockSize])
+
+	first := p[0] ^ (mask[0] & 0x0f)
+	pnLen := int(first&0x03) + 1
+	header := make([]byte, pnOffset+pnLen)
+	copy(header, p[:pnOffset+pnLen])
+	header[0] = first
+	var pn uint64
+	for i := 0; i < pnLen; i++ {
+		header[pnOffset+i] ^= mask[1+i]
+		pn = pn<<8 | uint64(header[pnOffset+i])
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, len(iv))
+	copy(nonce, iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+	return aead.Open(nil, nonce, p[pnOffset+pnLen:pnOffset+int(length)], header)
+}
+
+// quicClientKeys derives the keys that protect the Initial packets sent by
+// the client.
+func quicClientKeys(version quicVersion, dcid []byte) (key, iv, hp []byte, err error) {
+	initial, err := hkdf.Extract(sha256.New, dcid, version.salt)
+	if err != nil {
+		return
+	}
+	client, err := hkdfExpandLabel(initial, "client in", 32)
+	if err != nil {
+		return
+	}
+	if key, err = hkdfExpandLabel(client, version.keyLabel, 16); err != nil {
+		return
+	}
+	if iv, err = hkdfExpandLabel(client, version.ivLabel, 12); err != nil {
+		return
+	}
+	hp, err = hkdfExpandLabel(client, version.hpLabel, 16)
+	return
+}
+
+// hkdfExpandLabel implements HKDF-Expand-Label of TLS 1.3, with an empty
+// context (RFC 8446, 7.1).
+func hkdfExpandLabel(secret []byte, label string, length int) ([]byte, error) {
+	label = "tls13 " + label
+	info := make([]byte, 0, 4+len(label))
+	info = binary.BigEndian.AppendUint16(info, uint16(length))
+	info = append(info, byte(len(label)))
+	info = append(info, label...)
+	info = append(info, 0)
+	return hkdf.Expand(sha256.New, secret, string(info), length)
+}
+
+// quicCryptoData returns the data of the CRYPTO frames of a packet, from
+// offset 0 to the first gap. Clients may split the ClientHello in several
+// frames, out of order.
+func quicCryptoData(frames []byte) []byte {
+	type chunk struct {
+		offset uint64
+		data   []byte
+	}
+	var chunks []chunk
+	for off := 0; off < len(frames); {
+		switch frames[off] {
+		case 0x00, 0x01: // PADDING, PING
+			off++
+		case 0x06: // CRYPTO
+			offset, n := quicVarint(frames, off+1)
+			if n == 0 {
+				off = len(frames)
+				break
+			}
+			length, m := quicVarint(frames, off+1+n)
+			start := off + 1 + n + m
+			if m == 0 || uint64(len(frames)-start) < length {
+				off = len(frames)
+				break
+			}
+			chunks = append(chunks, chunk{offset, frames[start : start+int(length)]})
+			off = start + int(length)
+		default:
+			// other frames are not expected before the ClientHello.
+			off = len(frames)
+		}
+	}
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].offset < chunks[j].offset })
+	var data []byte
+	for _, c := range chunks {
+		end := c.offset + uint64(len(c.data))
+		if c.offset > uint64(len(data)) {
+			break
+		}
+		if end > uint64(len(data)) {
+			data = append(data, c.data[uint64(len(data))-c.offset:]...)
+		}
+	}
+	return data
+}
+
+// quicVarint decodes a variable-length integer (RFC 9000, 16). It returns the
+// number of bytes read, 0 if the buffer is too short.
+func quicVarint(b []byte, off int) (uint64, int) {
+	if off >= len(b) {
+		return 0, 0
+	}
+	n := 1 << (b[off] >> 6)
+	if off+n > len(b) {
+		return 0, 0
+	}
+	v := uint64(b[off] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(b[off+i])
+	}
+	return v, n
+}
+
+// tlsServerName returns the server name of a TLS ClientHello handshake
+// message. The message may be truncated, in which case the extensions are
+// read up to the end of the data.
+func tlsServerName(hello []byte) string {
+	// type client_hello (1), length (3), version (2), random (32)
+	if len(hello) < 38 || hello[0] != 0x01 {
+		return ""
+	}
+	off := 38
+	// session id
+	if off >= len(hello) {
+		return ""
+	}
+	off += 1 + int(hello[off])
+	// cipher suites
+	if off+2 > len(hello) {
+		return ""
+	}
+	off += 2 + int(binary.BigEndian.Uint16(hello[off:]))
+	// compression methods
+	if off >= len(hello) {
+		return ""
+	}
+	off += 1 + int(hello[off])
+	// extensions length
+	off += 2
+
+	for off+4 <= len(hello) {
+		extType := binary.BigEndian.Uint16(hello[off:])
+		extLen := int(binary.BigEndian.Uint16(hello[off+2:]))
+		off += 4
+		if extType != 0 {
+			off += extLen
+			continue
+		}
+		// server_name_list length (2), name type (1), name length (2)
+		if extLen < 5 || off+5 > len(hello) || hello[off+2] != 0 {
+			return ""
+		}
+		nameLen := int(binary.BigEndian.Uint16(hello[off+3:]))
+		if nameLen == 0 || off+5+nameLen > len(hello) {
+			return ""
+		}
+		name := hello[off+5 : off+5+nameLen]
+		for _, c := range name {
+			if c <= ' ' || c >= 0x7f {
+				return ""
+			}
+		}
+		return string(name)
+	}
+	return ""
+}
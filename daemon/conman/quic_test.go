@@ -0,0 +1,157 @@
+package conman
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// clientHello builds a ClientHello handshake message with the given SNI.
+func clientHello(sni string) []byte {
+	var ext []byte
+	// supported_versions: TLS 1.3
+	ext = append(ext, 0x00, 0x2b, 0x00, 0x03, 0x02, 0x03, 0x04)
+	if sni != "" {
+		ext = binary.BigEndian.AppendUint16(ext, 0)
+		ext = binary.BigEndian.AppendUint16(ext, uint16(len(sni)+5))
+		ext = binary.BigEndian.AppendUint16(ext, uint16(len(sni)+3))
+		ext = append(ext, 0)
+		ext = binary.BigEndian.AppendUint16(ext, uint16(len(sni)))
+		ext = append(ext, sni...)
+	}
+
+	body := []byte{0x03, 0x03}
+	body = append(body, make([]byte, 32)...)    // random
+	body = append(body, 0)                      // session id
+	body = append(body, 0x00, 0x02, 0x13, 0x01) // cipher suites
+	body = append(body, 0x01, 0x00)             // compression methods
+	body = binary.BigEndian.AppendUint16(body, uint16(len(ext)))
+	body = append(body, ext...)
+
+	msg := []byte{0x01, 0, byte(len(body) >> 8), byte(len(body))}
+	return append(msg, body...)
+}
+
+// cryptoFrame builds a CRYPTO frame with 2-byte varints.
+func cryptoFrame(offset int, data []byte) []byte {
+	f := []byte{0x06, 0x40 | byte(offset>>8), byte(offset), 0x40 | byte(len(data)>>8), byte(len(data))}
+	return append(f, data...)
+}
+
+// sealQUICInitial builds a protected client Initial packet (RFC 9001, 5).
+func sealQUICInitial(t *testing.T, version uint32, dcid, frames []byte) []byte {
+	v := quicVersions[version]
+	key, iv, hp, err := quicClientKeys(v, dcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pad to the minimum size of the datagrams of the Initial packets.
+	if pad := 1162 - len(frames); pad > 0 {
+		frames = append(frames, make([]byte, pad)...)
+	}
+	pn := []byte{0x00, 0x00, 0x00, 0x02}
+	length := len(pn) + len(frames) + 16
+
+	header := []byte{0xc0 | v.initialType<<4 | 0x03}
+	header = binary.BigEndian.AppendUint32(header, version)
+	header = append(header, byte(len(dcid)))
+	header = append(header, dcid...)
+	header = append(header, 0) // scid
+	header = append(header, 0) // token
+	header = append(header, 0x40|byte(length>>8), byte(length))
+	pnOffset := len(header)
+	header = append(header, pn...)
+
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	nonce := make([]byte, len(iv))
+	copy(nonce, iv)
+	nonce[len(nonce)-1] ^= pn[3]
+	packet := aead.Seal(append([]byte{}, header...), nonce, frames, header)
+
+	hpBlock, _ := aes.NewCipher(hp)
+	mask := make([]byte, aes.BlockSize)
+	hpBlock.Encrypt(mask, packet[pnOffset+4:pnOffset+4+aes.BlockSize])
+	packet[0] ^= mask[0] & 0x0f
+	for i := range pn {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+	return packet
+}
+
+func TestQUICClientKeys(t *testing.T) {
+	// RFC 9001, A.1
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	key, iv, hp, err := quicClientKeys(quicVersions[quicV1], dcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(key) != "1f369613dd76d5467730efcbe3b1a22d" ||
+		hex.EncodeToString(iv) != "fa044b2f42a3fd3b46fb255c" ||
+		hex.EncodeToString(hp) != "9f50449e04a0e810283a1e9933adedd2" {
+		t.Errorf("invalid QUIC v1 keys: %x, %x, %x", key, iv, hp)
+	}
+
+	// RFC 9369, A.1
+	key, iv, hp, _ = quicClientKeys(quicVersions[quicV2], dcid)
+	if hex.EncodeToString(key) != "8b1a0bc121284290a29e0971b5cd045d" ||
+		hex.EncodeToString(iv) != "91f73e2351d8fa91660e909f" ||
+		hex.EncodeToString(hp) != "45b95e15235d6f45a6b19cbcb0294ba9" {
+		t.Errorf("invalid QUIC v2 keys: %x, %x, %x", key, iv, hp)
+	}
+}
+
+func TestQUICServerName(t *testing.T) {
+	dcid, _ := hex.DecodeString("8394c8f03e515708")
+	hello := clientHello("www.example.com")
+	half := len(hello) / 2
+
+	tests := []struct {
+		name    string
+		version uint32
+		frames  []byte
+		sni     string
+	}{
+		{"v1", quicV1, cryptoFrame(0, hello), "www.example.com"},
+		{"v2", quicV2, cryptoFrame(0, hello), "www.example.com"},
+		{"frames out of order", quicV1,
+			append(append([]byte{0x01}, cryptoFrame(half, hello[half:])...), cryptoFrame(0, hello[:half])...),
+			"www.example.com"},
+		{"truncated ClientHello", quicV1, cryptoFrame(0, hello[:len(hello)-4]), ""},
+		{"ClientHello continued in the next packet", quicV1, cryptoFrame(half, hello[half:]), ""},
+		{"without SNI", quicV1, cryptoFrame(0, clientHello("")), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			packet := sealQUICInitial(t, test.version, dcid, test.frames)
+			if sni := QUICServerName(packet); sni != test.sni {
+				t.Errorf("unexpected SNI: '%s', expected '%s'", sni, test.sni)
+			}
+		})
+	}
+
+	packet := sealQUICInitial(t, quicV1, dcid, cryptoFrame(0, hello))
+	invalid := map[string][]byte{
+		"short header":    append([]byte{0x40}, packet[1:]...),
+		"unknown version": append(append([]byte{packet[0]}, 0xff, 0, 0, 0x1d), packet[5:]...),
+		"truncated":       packet[:100],
+		"empty":           nil,
+	}
+	corrupted := append([]byte{}, packet...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	invalid["corrupted"] = corrupted
+	for name, p := range invalid {
+		if sni := QUICServerName(p); sni != "" {
+			t.Errorf("%s: unexpected SNI: %s", name, sni)
+		}
+	}
+
+	// the SNI is extracted when the connection is parsed
+	con := newPayloadConnection(t, "udp", 443, packet)
+	con.parseDirection("")
+	if con.DstHost != "www.example.com" {
+		t.Errorf("the SNI has not been set as the destination host: %s", con.DstHost)
+	}
+}
@@ -0,0 +1,170 @@
+package conman
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+var defaultRetransmitTimeout = 10 * time.Second
+
+// RetransmitTableConfig holds the configuration of the table of the TCP
+// connections denied.
+type RetransmitTableConfig struct {
+	// Timeout is the time during which the SYN retransmissions of a denied
+	// connection are dropped without evaluating them (10s by default).
+	// The retransmissions are also dropped in kernel for this period, so
+	// after changing the rules the retries may be dropped for this time.
+	Timeout string `json:"Timeout"`
+	// MaxFlows is the max number of connections tracked. 0 disables the table.
+	MaxFlows int `json:"MaxFlows"`
+}
+
+type retransmitEntry struct {
+	lastSeen   time.Time
+	retries    uint64
+	generation uint64
+}
+
+// RetransmitTable tracks the TCP connections denied, in order to detect the
+// retransmissions of their SYN packets.
+//
+// When a SYN is dropped, the kernel retransmits it with the same source port
+// (1s, 3s, 7s, 15s...), and some applications reconnect endlessly. Every retry
+// would be evaluated, logged and sent to the GUI as a new connection.
+// The retries of a denied connection are dropped silently instead, as long as
+// the rules don't change (a different generation of the rules).
+type RetransmitTable struct {
+	flows    map[flowKey]*retransmitEntry
+	timeout  time.Duration
+	maxFlows int
+	mu       sync.Mutex
+}
+
+// Retransmits is the table of the TCP connections denied.
+var Retransmits = NewRetransmitTable()
+
+// NewRetransmitTable returns a new table, disabled until it's configured.
+func NewRetransmitTable() *RetransmitTable {
+	return &RetransmitTable{
+		flows:   make(map[flowKey]*retransmitEntry),
+		timeout: defaultRetransmitTimeout,
+	}
+}
+
+// SetConfig configures the limits of the table, deleting the connections tracked.
+func (r *RetransmitTable) SetConfig(cfg RetransmitTableConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.maxFlows = cfg.MaxFlows
+	r.timeout = defaultRetransmitTimeout
+	if timeout, err := time.ParseDuration(cfg.Timeout); err == nil && timeout > 0 {
+		r.timeout = timeout
+	} else if cfg.Timeout != "" {
+		log.Warning("[retransmits] invalid Timeout value: %s, using default (%s)", cfg.Timeout, r.timeout)
+	}
+	r.flows = make(map[flowKey]*retransmitEntry)
+	log.Debug("[retransmits] config, max flows: %d, timeout: %s", r.maxFlows, r.timeout)
+}
+
+// Enabled returns true if the denied connections are being tracked.
+func (r *RetransmitTable) Enabled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.maxFlows > 0
+}
+
+// Timeout returns the time during which the retransmissions are dropped.
+func (r *RetransmitTable) Timeout() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.timeout
+}
+
+// IsRetransmission returns true if the connection is a retry of a connection
+// denied with the same generation of the rules, not expired.
+// Every retry extends the period during which they're dropped.
+func (r *RetransmitTable) IsRetransmission(con *Connection, generation uint64) bool {
+	key, ok := newRetransmitKey(con)
+	if !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxFlows <= 0 {
+		return false
+	}
+	entry, found := r.flows[key]
+	if !found {
+		return false
+	}
+	now := time.Now()
+	if entry.generation != generation || now.Sub(entry.lastSeen) > r.timeout {
+		delete(r.flows, key)
+		return false
+	}
+	entry.lastSeen = now
+	entry.retries++
+	return true
+}
+
+// Add tracks a TCP connection denied with the given generation of the rules.
+func (r *RetransmitTable) Add(con *Connection, generation uint64) {
+	key, ok := newRetransmitKey(con)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxFlows <= 0 {
+		return
+	}
+	now := time.Now()
+	if _, found := r.flows[key]; !found && len(r.flows) >= r.maxFlows {
+		r.deleteExpired(now)
+		if len(r.flows) >= r.maxFlows {
+			log.Debug("[retransmits] table full (%d), purging", len(r.flows))
+			r.flows = make(map[flowKey]*retransmitEntry)
+		}
+	}
+	r.flows[key] = &retransmitEntry{
+		lastSeen:   now,
+		generation: generation,
+	}
+}
+
+// Len returns the number of connections tracked, expired or not.
+func (r *RetransmitTable) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.flows)
+}
+
+// deleteExpired deletes the connections not retried within the timeout.
+// The caller must hold the lock.
+func (r *RetransmitTable) deleteExpired(now time.Time) {
+	for key, entry := range r.flows {
+		if now.Sub(entry.lastSeen) > r.timeout {
+			log.Trace("[retransmits] expired %s %s:%d -> %s:%d, retries: %d", key.proto, key.srcIP, key.srcPort, key.dstIP, key.dstPort, entry.retries)
+			delete(r.flows, key)
+		}
+	}
+}
+
+func newRetransmitKey(con *Connection) (flowKey, bool) {
+	if con == nil || !strings.HasPrefix(con.Protocol, "tcp") {
+		return flowKey{}, false
+	}
+	return flowKey{
+		proto:   con.Protocol,
+		srcIP:   con.SrcIP.String(),
+		dstIP:   con.DstIP.String(),
+		srcPort: con.SrcPort,
+		dstPort: con.DstPort,
+	}, true
+}
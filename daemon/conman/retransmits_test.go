@@ -0,0 +1,73 @@
+package conman
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetransmitTable(t *testing.T) {
+	con := &Connection{
+		Protocol: "tcp",
+		SrcIP:    net.ParseIP("192.168.1.10"),
+		SrcPort:  41234,
+		DstIP:    net.ParseIP("1.1.1.1"),
+		DstPort:  443,
+	}
+	newCon := *con
+	newCon.SrcPort = 41235
+	udp := *con
+	udp.Protocol = "udp"
+
+	r := NewRetransmitTable()
+	r.Add(con, 1)
+	if r.IsRetransmission(con, 1) || r.Enabled() {
+		t.Error("the table should be disabled by default")
+	}
+
+	r.SetConfig(RetransmitTableConfig{MaxFlows: 2, Timeout: "1h"})
+	r.Add(con, 1)
+	r.Add(&udp, 1)
+	if !r.IsRetransmission(con, 1) {
+		t.Error("retransmission not detected")
+	}
+	if r.IsRetransmission(&newCon, 1) {
+		t.Error("connections from other ports are not retransmissions")
+	}
+	if r.IsRetransmission(&udp, 1) || r.Len() != 1 {
+		t.Error("only TCP connections should be tracked")
+	}
+
+	// the rules have changed
+	if r.IsRetransmission(con, 2) || r.Len() != 0 {
+		t.Error("connections denied with a previous generation of the rules should be deleted")
+	}
+
+	r.SetConfig(RetransmitTableConfig{MaxFlows: 2, Timeout: "50ms"})
+	if r.Timeout() != 50*time.Millisecond {
+		t.Errorf("invalid timeout: %s", r.Timeout())
+	}
+	r.Add(con, 2)
+	time.Sleep(30 * time.Millisecond)
+	if !r.IsRetransmission(con, 2) {
+		t.Error("connection expired before the timeout")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !r.IsRetransmission(con, 2) {
+		t.Error("every retry should extend the timeout")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if r.IsRetransmission(con, 2) {
+		t.Error("connections not retried should expire")
+	}
+
+	r.SetConfig(RetransmitTableConfig{MaxFlows: 1, Timeout: "wrong"})
+	if r.Timeout() != defaultRetransmitTimeout {
+		t.Errorf("invalid timeout should fallback to default: %s", r.Timeout())
+	}
+	r.Add(con, 1)
+	r.Add(&newCon, 1)
+	if r.Len() != 1 || !r.IsRetransmission(&newCon, 1) {
+		t.Error("table should be purged when full")
+	}
+}
@@ -0,0 +1,168 @@
+package conman
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+)
+
+// Scopes of the destinations of the connections.
+const (
+	ScopeLocal     = "local"
+	ScopeLAN       = "lan"
+	ScopeMulticast = "multicast"
+	ScopeBroadcast = "broadcast"
+	ScopeWAN       = "wan"
+)
+
+// lookups of the routing and neighbors tables, and of the addresses of the
+// interfaces, replaced by the tests.
+var (
+	getRoute          = netlink.GetRoute
+	getNeighborMAC    = netlink.GetNeighborMAC
+	getBroadcastAddrs = netlink.GetBroadcastAddrs
+)
+
+var (
+	// the routing and neighbors tables, and the addresses of the interfaces,
+	// change rarely. The classification of the destinations is cached for a
+	// few seconds, instead of querying them for every connection.
+	dstCacheTTL = 5 * time.Second
+	dstCacheMax = 4096
+
+	dsts = newDstCache()
+)
+
+// dstInfo is the classification of the destination of a connection, resolved
+// the first time it's needed.
+type dstInfo struct {
+	once  sync.Once
+	scope string
+	mac   string
+}
+
+// DstScope returns the scope of the destination: local, lan, multicast,
+// broadcast or wan.
+// Destinations on directly connected networks (without gateway in the
+// routing table), and private and link-local addresses, are lan.
+func (c *Connection) DstScope() string {
+	scope, _ := c.resolveDst()
+	return scope
+}
+
+// DstMAC returns the MAC address of the destination, if it's on a directly
+// connected network and it's in the neighbors table.
+func (c *Connection) DstMAC() string {
+	_, mac := c.resolveDst()
+	return mac
+}
+
+func (c *Connection) resolveDst() (string, string) {
+	// connections not created by the constructors are not classified once.
+	if c.dst == nil {
+		return dsts.classify(c.DstIP)
+	}
+	c.dst.once.Do(func() {
+		// the scope of the deserialized connections is already known.
+		if c.dst.scope == "" {
+			c.dst.scope, c.dst.mac = dsts.classify(c.DstIP)
+		}
+	})
+	return c.dst.scope, c.dst.mac
+}
+
+type dstCacheEntry struct {
+	scope string
+	mac   string
+	added time.Time
+}
+
+// dstCache caches the classification of the destinations, and the broadcast
+// addresses of the local networks.
+type dstCache struct {
+	entries   map[string]dstCacheEntry
+	bcast     []net.IP
+	bcastTime time.Time
+	sync.Mutex
+}
+
+func newDstCache() *dstCache {
+	return &dstCache{
+		entries: make(map[string]dstCacheEntry),
+	}
+}
+
+// classify returns the scope and the MAC address of a destination.
+// The tables are queried without holding the lock.
+func (d *dstCache) classify(ip net.IP) (string, string) {
+	key := ip.String()
+	now := time.Now()
+	d.Lock()
+	entry, found := d.entries[key]
+	d.Unlock()
+	if found && now.Sub(entry.added) < dstCacheTTL {
+		return entry.scope, entry.mac
+	}
+
+	scope, mac := d.classifyDst(ip)
+
+	d.Lock()
+	defer d.Unlock()
+	if len(d.entries) >= dstCacheMax {
+		for k, e := range d.entries {
+			if now.Sub(e.added) >= dstCacheTTL {
+				delete(d.entries, k)
+			}
+		}
+		if len(d.entries) >= dstCacheMax {
+			d.entries = make(map[string]dstCacheEntry)
+		}
+	}
+	d.entries[key] = dstCacheEntry{scope: scope, mac: mac, added: now}
+	return scope, mac
+}
+
+// isBroadcast checks if an IP is the broadcast address of any of the networks
+// of the local interfaces.
+func (d *dstCache) isBroadcast(ip net.IP) bool {
+	if ip.To4() == nil {
+		return false
+	}
+	d.Lock()
+	defer d.Unlock()
+	if time.Since(d.bcastTime) >= dstCacheTTL {
+		d.bcast = getBroadcastAddrs()
+		d.bcastTime = time.Now()
+	}
+	for _, bcast := range d.bcast {
+		if bcast.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *dstCache) classifyDst(ip net.IP) (scope, mac string) {
+	switch {
+	case ip == nil || ip.IsUnspecified():
+		return ScopeWAN, ""
+	case ip.IsLoopback():
+		return ScopeLocal, ""
+	case ip.Equal(net.IPv4bcast) || d.isBroadcast(ip):
+		return ScopeBroadcast, ""
+	case ip.IsMulticast():
+		return ScopeMulticast, ""
+	}
+	scope = ScopeWAN
+	gw, linkIndex, err := getRoute(ip)
+	onLink := err == nil && linkIndex > 0 && gw == nil
+	if onLink {
+		mac = getNeighborMAC(ip, linkIndex)
+	}
+	if onLink || ip.IsPrivate() || ip.IsLinkLocalUnicast() {
+		scope = ScopeLAN
+	}
+	return scope, mac
+}
@@ -0,0 +1,125 @@
+package conman
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDstScope(t *testing.T) {
+	origRoute, origMAC, origBcast := getRoute, getNeighborMAC, getBroadcastAddrs
+	defer func() {
+		getRoute, getNeighborMAC, getBroadcastAddrs = origRoute, origMAC, origBcast
+		dsts = newDstCache()
+	}()
+	dsts = newDstCache()
+
+	// 192.168.1.0/24 and 100.64.0.0/10 are directly connected, 10.8.0.0/16
+	// is routed through a VPN.
+	getRoute = func(ip net.IP) (net.IP, int, error) {
+		switch {
+		case ip.Equal(net.ParseIP("203.0.113.1")):
+			return nil, 0, fmt.Errorf("network unreachable")
+		case ip.To4() != nil && (ip.To4()[0] == 192 || ip.To4()[0] == 100):
+			return nil, 2, nil
+		}
+		return net.ParseIP("192.168.1.1"), 2, nil
+	}
+	getNeighborMAC = func(ip net.IP, linkIndex int) string {
+		if ip.Equal(net.ParseIP("192.168.1.20")) {
+			return "aa:bb:cc:dd:ee:ff"
+		}
+		return ""
+	}
+	getBroadcastAddrs = func() []net.IP {
+		return []net.IP{net.ParseIP("192.168.1.255")}
+	}
+
+	tests := []struct {
+		ip    string
+		scope string
+		mac   string
+	}{
+		{"127.0.0.1", ScopeLocal, ""},
+		{"::1", ScopeLocal, ""},
+		{"192.168.1.20", ScopeLAN, "aa:bb:cc:dd:ee:ff"},
+		{"192.168.1.30", ScopeLAN, ""},
+		{"100.64.1.1", ScopeLAN, ""},
+		{"10.8.1.1", ScopeLAN, ""},
+		{"fe80::1", ScopeLAN, ""},
+		{"255.255.255.255", ScopeBroadcast, ""},
+		{"192.168.1.255", ScopeBroadcast, ""},
+		{"224.0.0.251", ScopeMulticast, ""},
+		{"ff02::fb", ScopeMulticast, ""},
+		{"1.1.1.1", ScopeWAN, ""},
+		{"2606:4700::1111", ScopeWAN, ""},
+		{"203.0.113.1", ScopeWAN, ""},
+	}
+	for _, tt := range tests {
+		con := &Connection{DstIP: net.ParseIP(tt.ip)}
+		if scope := con.DstScope(); scope != tt.scope {
+			t.Errorf("%s scope: %s, expected: %s", tt.ip, scope, tt.scope)
+		}
+		if mac := con.DstMAC(); mac != tt.mac {
+			t.Errorf("%s MAC: %s, expected: %s", tt.ip, mac, tt.mac)
+		}
+	}
+}
+
+// Test that the tables are not queried for every connection, and that the
+// destination of a connection is classified once when it's used concurrently.
+// Run with -race.
+func TestDstScopeCache(t *testing.T) {
+	origRoute, origMAC, origBcast := getRoute, getNeighborMAC, getBroadcastAddrs
+	defer func() {
+		getRoute, getNeighborMAC, getBroadcastAddrs = origRoute, origMAC, origBcast
+		dsts = newDstCache()
+	}()
+	dsts = newDstCache()
+
+	var routes, neighbors, addrs atomic.Int32
+	getRoute = func(ip net.IP) (net.IP, int, error) {
+		routes.Add(1)
+		return nil, 2, nil
+	}
+	getNeighborMAC = func(ip net.IP, linkIndex int) string {
+		neighbors.Add(1)
+		return "aa:bb:cc:dd:ee:ff"
+	}
+	getBroadcastAddrs = func() []net.IP {
+		addrs.Add(1)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	con := &Connection{DstIP: net.ParseIP("192.168.1.20"), dst: &dstInfo{}}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if scope, mac := con.DstScope(), con.DstMAC(); scope != ScopeLAN || mac != "aa:bb:cc:dd:ee:ff" {
+				t.Errorf("invalid destination: %s, %s", scope, mac)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 10; i++ {
+		other := &Connection{DstIP: net.ParseIP("192.168.1.20"), dst: &dstInfo{}}
+		other.DstScope()
+		(&Connection{DstIP: net.ParseIP("192.168.1.30"), dst: &dstInfo{}}).DstScope()
+	}
+	if routes.Load() != 2 || neighbors.Load() != 2 || addrs.Load() != 1 {
+		t.Errorf("the tables have been queried %d, %d, %d times", routes.Load(), neighbors.Load(), addrs.Load())
+	}
+
+	// the entries expire.
+	defer func(ttl time.Duration) { dstCacheTTL = ttl }(dstCacheTTL)
+	dstCacheTTL = 0
+	(&Connection{DstIP: net.ParseIP("192.168.1.20"), dst: &dstInfo{}}).DstScope()
+	if routes.Load() != 3 || addrs.Load() != 2 {
+		t.Errorf("expired entries used: %d, %d", routes.Load(), addrs.Load())
+	}
+}
@@ -0,0 +1,216 @@
+package conman
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+var defaultVerdictTTL = 10 * time.Second
+
+// VerdictCacheConfig holds the configuration of the verdicts cache.
+type VerdictCacheConfig struct {
+	// TTL of the verdicts (10s by default).
+	TTL string `json:"TTL"`
+	// MaxEntries is the max number of verdicts cached. 0 disables the cache.
+	MaxEntries int `json:"MaxEntries"`
+}
+
+// VerdictCacheStats holds the counters of the cache.
+type VerdictCacheStats struct {
+	Entries int
+	Hits    uint64
+	Misses  uint64
+}
+
+// verdictKey identifies the connections of a process to a destination.
+type verdictKey struct {
+	path    string
+	proto   string
+	dstIP   string
+	dstHost string
+	pid     int
+	uid     int
+	dstPort uint
+}
+
+type verdictEntry struct {
+	verdict interface{}
+	expires time.Time
+}
+
+// VerdictCache memoizes the verdicts of the rules for the connections of a
+// process to the same destination. Applications that open hundreds of
+// identical connections per second don't need to evaluate the rules every time.
+//
+// The verdicts are invalidated when the rules change (a different generation
+// of the rules), when the process exits, or when the TTL expires.
+type VerdictCache struct {
+	entries map[verdictKey]verdictEntry
+	byPID   map[int][]verdictKey
+	ttl     time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	generation uint64
+	maxEntries int
+	mu         sync.Mutex
+}
+
+// Verdicts is the cache of the verdicts of the connections.
+var Verdicts = NewVerdictCache()
+
+// NewVerdictCache returns a new cache, disabled until it's configured.
+func NewVerdictCache() *VerdictCache {
+	return &VerdictCache{
+		entries: make(map[verdictKey]verdictEntry),
+		byPID:   make(map[int][]verdictKey),
+		ttl:     defaultVerdictTTL,
+	}
+}
+
+// SetConfig configures the limits of the cache, deleting the verdicts cached.
+func (v *VerdictCache) SetConfig(cfg VerdictCacheConfig) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.maxEntries = cfg.MaxEntries
+	v.ttl = defaultVerdictTTL
+	if ttl, err := time.ParseDuration(cfg.TTL); err == nil && ttl > 0 {
+		v.ttl = ttl
+	} else if cfg.TTL != "" {
+		log.Warning("[verdicts] invalid TTL value: %s, using default (%s)", cfg.TTL, v.ttl)
+	}
+	v.purge()
+	log.Debug("[verdicts] cache config, max entries: %d, TTL: %s", v.maxEntries, v.ttl)
+}
+
+// Get returns the verdict of a connection, if it's been cached with the same
+// generation of the rules.
+func (v *VerdictCache) Get(con *Connection, generation uint64) (interface{}, bool) {
+	key, ok := newVerdictKey(con)
+	if !ok {
+		return nil, false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.maxEntries <= 0 {
+		return nil, false
+	}
+	if generation != v.generation {
+		v.purge()
+		v.generation = generation
+	}
+	entry, found := v.entries[key]
+	if !found || time.Now().After(entry.expires) {
+		v.misses.Add(1)
+		return nil, false
+	}
+	v.hits.Add(1)
+	return entry.verdict, true
+}
+
+// Add caches the verdict of a connection, obtained with the given generation
+// of the rules.
+func (v *VerdictCache) Add(con *Connection, generation uint64, verdict interface{}) {
+	key, ok := newVerdictKey(con)
+	if !ok {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.maxEntries <= 0 {
+		return
+	}
+	if generation != v.generation {
+		v.purge()
+		v.generation = generation
+	}
+	now := time.Now()
+	if _, found := v.entries[key]; !found {
+		if len(v.entries) >= v.maxEntries {
+			v.deleteExpired(now)
+		}
+		if len(v.entries) >= v.maxEntries {
+			log.Debug("[verdicts] cache full (%d), purging", len(v.entries))
+			v.purge()
+		}
+		v.byPID[key.pid] = append(v.byPID[key.pid], key)
+	}
+	v.entries[key] = verdictEntry{
+		verdict: verdict,
+		expires: now.Add(v.ttl),
+	}
+}
+
+// DeleteProcess deletes the verdicts of a process, i.e.: when it exits.
+func (v *VerdictCache) DeleteProcess(pid int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, key := range v.byPID[pid] {
+		delete(v.entries, key)
+	}
+	delete(v.byPID, pid)
+}
+
+// Stats returns the counters of the cache.
+func (v *VerdictCache) Stats() VerdictCacheStats {
+	v.mu.Lock()
+	entries := len(v.entries)
+	v.mu.Unlock()
+	return VerdictCacheStats{
+		Entries: entries,
+		Hits:    v.hits.Load(),
+		Misses:  v.misses.Load(),
+	}
+}
+
+// purge deletes all the verdicts.
+// The caller must hold the lock.
+func (v *VerdictCache) purge() {
+	if len(v.entries) == 0 {
+		return
+	}
+	v.entries = make(map[verdictKey]verdictEntry)
+	v.byPID = make(map[int][]verdictKey)
+}
+
+// deleteExpired deletes the verdicts whose TTL has expired.
+// The caller must hold the lock.
+func (v *VerdictCache) deleteExpired(now time.Time) {
+	for pid, keys := range v.byPID {
+		alive := keys[:0]
+		for _, key := range keys {
+			if now.After(v.entries[key].expires) {
+				delete(v.entries, key)
+				continue
+			}
+			alive = append(alive, key)
+		}
+		if len(alive) == 0 {
+			delete(v.byPID, pid)
+		} else {
+			v.byPID[pid] = alive
+		}
+	}
+}
+
+func newVerdictKey(con *Connection) (verdictKey, bool) {
+	if con == nil || con.Process == nil || con.Entry == nil || con.Process.ID <= 0 {
+		return verdictKey{}, false
+	}
+	return verdictKey{
+		pid:     con.Process.ID,
+		path:    con.Process.Path,
+		uid:     con.Entry.UserId,
+		proto:   con.Protocol,
+		dstIP:   con.DstIP.String(),
+		dstHost: con.DstHost,
+		dstPort: con.DstPort,
+	}, true
+}
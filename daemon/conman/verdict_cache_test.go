@@ -0,0 +1,63 @@
+package conman
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+func TestVerdictCache(t *testing.T) {
+	con := &Connection{
+		Protocol: "tcp",
+		DstIP:    net.ParseIP("1.1.1.1"),
+		DstPort:  443,
+		DstHost:  "one.one.one.one",
+		Entry:    &netstat.Entry{UserId: 1000},
+		Process:  &procmon.Process{ID: 1234, Path: "/usr/bin/curl"},
+	}
+	other := *con
+	other.DstPort = 80
+
+	v := NewVerdictCache()
+	v.Add(con, 1, "allow")
+	if _, found := v.Get(con, 1); found {
+		t.Error("the cache should be disabled by default")
+	}
+
+	v.SetConfig(VerdictCacheConfig{MaxEntries: 2, TTL: "1h"})
+	v.Add(con, 1, "allow")
+	if verdict, found := v.Get(con, 1); !found || verdict != "allow" {
+		t.Errorf("verdict not cached: %v, %v", verdict, found)
+	}
+	if _, found := v.Get(&other, 1); found {
+		t.Error("connections to other destinations should not be cached")
+	}
+	if stats := v.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+
+	// the rules have changed
+	if _, found := v.Get(con, 2); found {
+		t.Error("verdicts of a previous generation should not be returned")
+	}
+
+	v.Add(con, 2, "deny")
+	v.Add(&other, 2, "deny")
+	v.DeleteProcess(con.Process.ID)
+	if _, found := v.Get(con, 2); found {
+		t.Error("verdicts of a process that has exited should not be returned")
+	}
+	if stats := v.Stats(); stats.Entries != 0 {
+		t.Errorf("expected no verdicts cached: %+v", stats)
+	}
+
+	v.SetConfig(VerdictCacheConfig{MaxEntries: 10, TTL: "1ms"})
+	v.Add(con, 2, "allow")
+	time.Sleep(5 * time.Millisecond)
+	if _, found := v.Get(con, 2); found {
+		t.Error("expired verdicts should not be returned")
+	}
+}
@@ -4,11 +4,63 @@ import (
 	"fmt"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
 	"github.com/evilsocket/opensnitch/daemon/log"
 )
 
+var (
+	// BTF of the kernel, used to relocate the CO-RE modules.
+	kernelTypes *btf.Spec
+
+	// maps of the modules to use instead of creating new ones.
+	mapReplacements = make(map[string]map[string]*ebpf.Map)
+)
+
+// SetKernelBTF loads the BTF (vmlinux) used to relocate the CO-RE (BTF)
+// eBPF modules, for kernels that don't expose it under /sys/kernel/btf/vmlinux.
+// Only opensnitch-procs.o is built as CO-RE, the rest of modules are built per
+// kernel and don't use it.
+// An empty path uses the BTF of the running kernel.
+func SetKernelBTF(path string) error {
+	if path == "" {
+		kernelTypes = nil
+		return nil
+	}
+	spec, err := btf.LoadSpec(path)
+	if err != nil {
+		kernelTypes = nil
+		return fmt.Errorf("[eBPF] unable to load BTF from %s: %s", path, err)
+	}
+	log.Info("[eBPF] using kernel BTF from %s", path)
+	kernelTypes = spec
+	return nil
+}
+
+// HasKernelBTF returns true if the running kernel exposes its BTF, needed to
+// load the CO-RE modules.
+func HasKernelBTF() bool {
+	return Exists("/sys/kernel/btf/vmlinux")
+}
+
+// SetMapReplacements configures the maps to use when loading the given module,
+// instead of creating new ones. It's used to reuse the maps of a previous
+// instance of the daemon.
+// The maps are only used once, and closed after loading the module.
+func SetMapReplacements(module string, maps map[string]*ebpf.Map) {
+	mapReplacements[module] = maps
+}
+
+func closeMapReplacements(module string) {
+	for _, m := range mapReplacements[module] {
+		m.Close()
+	}
+	delete(mapReplacements, module)
+}
+
 // LoadEbpfModule loads the given eBPF module, from the given path if specified.
 // Otherwise t'll try to load the module from several default paths.
+// The modules compiled for the architecture of the system
+// (<path>/<arch>/<module>) take precedence over the ones of <path>/<module>.
 func LoadEbpfModule(module, path string) (m *ebpf.Collection, err error) {
 	var (
 		modulesDir = "/opensnitchd/ebpf"
@@ -19,6 +71,12 @@ func LoadEbpfModule(module, path string) (m *ebpf.Collection, err error) {
 		}
 	)
 
+	arch, err := EbpfArch()
+	if err != nil {
+		setEbpfModuleStatus(EbpfModuleStatus{Name: module, Error: err.Error()})
+		return nil, err
+	}
+
 	// if path has been specified, try to load the module from there.
 	if path != "" {
 		paths = []string{path}
@@ -32,33 +90,65 @@ You may need to install the corresponding package`, module)
 	if log.GetLogLevel() == log.DEBUG {
 		logLevel = (ebpf.LogLevelBranch | ebpf.LogLevelInstruction | ebpf.LogLevelStats)
 	}
+	// CO-RE modules are relocated against the BTF of the running kernel,
+	// unless a different one has been configured.
 	collOpts := ebpf.CollectionOptions{
-		Programs: ebpf.ProgramOptions{LogLevel: logLevel},
+		Programs: ebpf.ProgramOptions{
+			LogLevel:    logLevel,
+			KernelTypes: kernelTypes,
+		},
+		MapReplacements: mapReplacements[module],
 	}
+	defer closeMapReplacements(module)
 
+	var lastErr error
 	for _, p := range paths {
-		modulePath = fmt.Sprint(p, "/", module)
-		log.Debug("[eBPF] trying to load %s", modulePath)
-		if !Exists(modulePath) {
-			continue
-		}
-		specs, err := ebpf.LoadCollectionSpec(modulePath)
-		if err != nil {
-			log.Error("[eBPF] module specs error: %s", err)
-			continue
-		}
-		m, err := ebpf.NewCollectionWithOptions(specs, collOpts)
-		if err != nil {
-			log.Error("[eBPF] module collection error: %s", err)
-			continue
-		}
+		archPath := fmt.Sprint(p, "/", arch, "/", module)
+		for _, modulePath = range []string{archPath, fmt.Sprint(p, "/", module)} {
+			log.Debug("[eBPF] trying to load %s", modulePath)
+			if !Exists(modulePath) {
+				continue
+			}
+			specs, err := ebpf.LoadCollectionSpec(modulePath)
+			if err != nil {
+				log.Error("[eBPF] module specs error: %s", err)
+				lastErr = err
+				continue
+			}
+			modArch, err := verifyEbpfModule(specs, arch)
+			if err != nil {
+				log.Error("[eBPF] %s: %s", modulePath, err)
+				lastErr = fmt.Errorf("%s: %s", modulePath, err)
+				continue
+			}
+			if modArch == "" && modulePath == archPath {
+				modArch = arch
+			}
+			m, err := ebpf.NewCollectionWithOptions(specs, collOpts)
+			if err != nil && collOpts.MapReplacements != nil {
+				// the maps may not be compatible with this version of the module.
+				log.Warning("[eBPF] unable to reuse the maps of %s: %s", module, err)
+				collOpts.MapReplacements = nil
+				m, err = ebpf.NewCollectionWithOptions(specs, collOpts)
+			}
+			if err != nil {
+				log.Error("[eBPF] module collection error: %s", err)
+				lastErr = err
+				continue
+			}
 
-		log.Info("[eBPF] module loaded: %s", modulePath)
-		return m, nil
+			log.Info("[eBPF] module loaded: %s", modulePath)
+			setEbpfModuleStatus(EbpfModuleStatus{Name: module, Path: modulePath, Arch: modArch, Loaded: true})
+			return m, nil
+		}
+	}
+	if lastErr != nil {
+		moduleError = fmt.Errorf(`
+unable to load eBPF module (%s): %s
+Your kernel version (%s, %s) might not be compatible.
+If this error persists, change process monitor method to 'proc'`, module, lastErr, GetKernelVersion(), arch)
 	}
-	moduleError = fmt.Errorf(`
-unable to load eBPF module (%s). Your kernel version (%s) might not be compatible.
-If this error persists, change process monitor method to 'proc'`, module, GetKernelVersion())
+	setEbpfModuleStatus(EbpfModuleStatus{Name: module, Error: moduleError.Error()})
 
 	return m, moduleError
 }
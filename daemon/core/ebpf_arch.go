@@ -0,0 +1,121 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// architectures for which the eBPF modules can be compiled, as named in the
+// directories of the modules: /usr/lib/opensnitchd/ebpf/<arch>/opensnitch.o
+var ebpfArchs = map[string]string{
+	"amd64":   "x86_64",
+	"386":     "i686",
+	"arm64":   "aarch64",
+	"arm":     "armv7",
+	"riscv64": "riscv64",
+	"loong64": "loongarch64",
+	"s390x":   "s390x",
+}
+
+// prefixes of the syscalls symbols of every architecture. The modules that
+// hook syscalls can only be loaded on the architecture they were compiled for.
+var ebpfSyscallPrefixes = map[string]string{
+	"__x64_sys_":   "x86_64",
+	"__ia32_sys_":  "i686",
+	"__arm64_sys_": "aarch64",
+	"__riscv_sys_": "riscv64",
+	"__s390x_sys_": "s390x",
+}
+
+// EbpfModuleStatus holds the details of an eBPF module loaded, or the error
+// loading it.
+type EbpfModuleStatus struct {
+	Name   string `json:"name"`
+	Path   string `json:"path,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Loaded bool   `json:"loaded"`
+}
+
+// EbpfStatus holds the status of the eBPF modules.
+type EbpfStatus struct {
+	Arch          string             `json:"arch"`
+	KernelVersion string             `json:"kernel_version"`
+	Modules       []EbpfModuleStatus `json:"modules"`
+	KernelBTF     bool               `json:"kernel_btf"`
+}
+
+var (
+	ebpfModules   = make(map[string]EbpfModuleStatus)
+	ebpfModulesMu sync.RWMutex
+)
+
+// EbpfArch returns the architecture of the system, as named in the
+// directories of the eBPF modules.
+func EbpfArch() (string, error) {
+	arch, found := ebpfArchs[runtime.GOARCH]
+	if !found {
+		supported := make([]string, 0, len(ebpfArchs))
+		for _, a := range ebpfArchs {
+			supported = append(supported, a)
+		}
+		sort.Strings(supported)
+		return runtime.GOARCH, fmt.Errorf("eBPF is not supported on this architecture (%s), supported: %s",
+			runtime.GOARCH, strings.Join(supported, ", "))
+	}
+	return arch, nil
+}
+
+// GetEbpfStatus returns the status of the eBPF modules loaded, or tried to load.
+func GetEbpfStatus() EbpfStatus {
+	arch, _ := EbpfArch()
+	status := EbpfStatus{
+		Arch:          arch,
+		KernelVersion: GetKernelVersion(),
+		KernelBTF:     HasKernelBTF() || kernelTypes != nil,
+	}
+	ebpfModulesMu.RLock()
+	for _, m := range ebpfModules {
+		status.Modules = append(status.Modules, m)
+	}
+	ebpfModulesMu.RUnlock()
+	sort.Slice(status.Modules, func(i, j int) bool {
+		return status.Modules[i].Name < status.Modules[j].Name
+	})
+	return status
+}
+
+func setEbpfModuleStatus(status EbpfModuleStatus) {
+	ebpfModulesMu.Lock()
+	ebpfModules[status.Name] = status
+	ebpfModulesMu.Unlock()
+}
+
+// verifyEbpfModule checks that a module can be loaded on this system: the
+// byte order must match, and the syscalls hooked must be of this architecture.
+// It returns the architecture the module was compiled for, if it can be
+// determined.
+func verifyEbpfModule(specs *ebpf.CollectionSpec, arch string) (string, error) {
+	probe := []byte{1, 0}
+	if specs.ByteOrder != nil && specs.ByteOrder.Uint16(probe) != binary.NativeEndian.Uint16(probe) {
+		return "", fmt.Errorf("the byte order of the module (%s) doesn't match the system", specs.ByteOrder)
+	}
+	for _, prog := range specs.Programs {
+		for prefix, modArch := range ebpfSyscallPrefixes {
+			if !strings.HasPrefix(prog.AttachTo, prefix) {
+				continue
+			}
+			if modArch != arch {
+				return modArch, fmt.Errorf("the module was compiled for %s, but this system is %s", modArch, arch)
+			}
+			return modArch, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestVerifyEbpfModule(t *testing.T) {
+	specs := &ebpf.CollectionSpec{
+		ByteOrder: binary.LittleEndian,
+		Programs: map[string]*ebpf.ProgramSpec{
+			"tcp_v4_connect": {AttachTo: "tcp_v4_connect"},
+			"execve":         {AttachTo: "__arm64_sys_execve"},
+		},
+	}
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		specs.ByteOrder = binary.BigEndian
+	}
+
+	if arch, err := verifyEbpfModule(specs, "aarch64"); err != nil || arch != "aarch64" {
+		t.Error("module not verified:", arch, err)
+	}
+	if _, err := verifyEbpfModule(specs, "x86_64"); err == nil {
+		t.Error("module of a different architecture verified")
+	}
+
+	delete(specs.Programs, "execve")
+	if arch, err := verifyEbpfModule(specs, "x86_64"); err != nil || arch != "" {
+		t.Error("generic module not verified:", arch, err)
+	}
+
+	if specs.ByteOrder == binary.LittleEndian {
+		specs.ByteOrder = binary.BigEndian
+	} else {
+		specs.ByteOrder = binary.LittleEndian
+	}
+	if _, err := verifyEbpfModule(specs, "x86_64"); err == nil {
+		t.Error("module with a different byte order verified")
+	}
+}
+
+func TestLoadEbpfModuleStatus(t *testing.T) {
+	if _, err := EbpfArch(); err != nil {
+		t.Skip(err)
+	}
+	if _, err := LoadEbpfModule("opensnitch-test.o", t.TempDir()); err == nil {
+		t.Fatal("module loaded from an empty directory")
+	}
+	status := GetEbpfStatus()
+	for _, m := range status.Modules {
+		if m.Name == "opensnitch-test.o" {
+			if m.Loaded || m.Error == "" {
+				t.Error("invalid module status:", m)
+			}
+			return
+		}
+	}
+	t.Error("module status not found:", status)
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Kernel lockdown modes.
+// https://man7.org/linux/man-pages/man7/kernel_lockdown.7.html
+const (
+	LockdownNone            = "none"
+	LockdownIntegrity       = "integrity"
+	LockdownConfidentiality = "confidentiality"
+)
+
+var (
+	lockdownFile   = "/sys/kernel/security/lockdown"
+	secureBootFile = "/sys/firmware/efi/efivars/SecureBoot-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+	lockdownRe = regexp.MustCompile(`\[([a-z]+)\]`)
+)
+
+// GetLockdownMode returns the active kernel lockdown mode: none, integrity or
+// confidentiality. If it can't be determined, it returns none.
+// The file content has the format: none [integrity] confidentiality
+func GetLockdownMode() string {
+	raw, err := ioutil.ReadFile(lockdownFile)
+	if err != nil {
+		return LockdownNone
+	}
+	return parseLockdownMode(string(raw))
+}
+
+func parseLockdownMode(raw string) string {
+	match := lockdownRe.FindStringSubmatch(strings.TrimSpace(raw))
+	if len(match) < 2 {
+		return LockdownNone
+	}
+	return match[1]
+}
+
+// IsSecureBootEnabled returns true if the system was booted with UEFI
+// secure boot enabled.
+func IsSecureBootEnabled() bool {
+	raw, err := ioutil.ReadFile(secureBootFile)
+	// the first 4 bytes are the attributes of the variable.
+	if err != nil || len(raw) < 5 {
+		return false
+	}
+	return raw[4] == 1
+}
+
+// EbpfAllowedByLockdown returns false if the kernel lockdown mode doesn't
+// allow to use kprobes and to read kernel memory from eBPF programs, in which
+// case the eBPF modules can't be used.
+// Under the integrity mode, eBPF is allowed (debugfs is not).
+func EbpfAllowedByLockdown() bool {
+	return GetLockdownMode() != LockdownConfidentiality
+}
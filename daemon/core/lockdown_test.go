@@ -0,0 +1,17 @@
+package core
+
+import "testing"
+
+func TestParseLockdownMode(t *testing.T) {
+	tests := map[string]string{
+		"[none] integrity confidentiality\n": LockdownNone,
+		"none [integrity] confidentiality\n": LockdownIntegrity,
+		"none integrity [confidentiality]\n": LockdownConfidentiality,
+		"":                                   LockdownNone,
+	}
+	for raw, expected := range tests {
+		if mode := parseLockdownMode(raw); mode != expected {
+			t.Errorf("parseLockdownMode(%q) = %s, expected %s", raw, mode, expected)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Policies to apply when a Queue is full.
+const (
+	// discard the oldest item queued, to make room for the new one.
+	DropOldest = "drop-oldest"
+	// discard the new item.
+	DropNewest = "drop-newest"
+)
+
+// Queue is a bounded queue that never blocks the producers: when it's full,
+// items are discarded according to the policy, and counted as dropped.
+// The items are consumed by reading from C().
+type Queue[T any] struct {
+	ch      chan T
+	policy  string
+	dropped atomic.Uint64
+	// serializes the producers while making room for a new item.
+	mu sync.Mutex
+}
+
+// NewQueue returns a new queue of the given size and policy.
+// The policy must be DropOldest or DropNewest (DropOldest if empty).
+func NewQueue[T any](size int, policy string) (*Queue[T], error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid queue size: %d", size)
+	}
+	switch policy {
+	case "":
+		policy = DropOldest
+	case DropOldest, DropNewest:
+	default:
+		return nil, fmt.Errorf("invalid queue policy: %s", policy)
+	}
+	return &Queue[T]{
+		ch:     make(chan T, size),
+		policy: policy,
+	}, nil
+}
+
+// Push adds an item to the queue, without blocking.
+// It returns false if an item has been discarded (the new one or the oldest
+// one, depending on the policy).
+func (q *Queue[T]) Push(item T) bool {
+	select {
+	case q.ch <- item:
+		return true
+	default:
+	}
+
+	if q.policy == DropNewest {
+		q.dropped.Add(1)
+		return false
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queued := true
+	for {
+		select {
+		case q.ch <- item:
+			return queued
+		default:
+		}
+		select {
+		case <-q.ch:
+			q.dropped.Add(1)
+			queued = false
+		default:
+		}
+	}
+}
+
+// C returns the channel to consume the items from.
+func (q *Queue[T]) C() <-chan T {
+	return q.ch
+}
+
+// Len returns the number of items queued.
+func (q *Queue[T]) Len() int {
+	return len(q.ch)
+}
+
+// Cap returns the size of the queue.
+func (q *Queue[T]) Cap() int {
+	return cap(q.ch)
+}
+
+// Policy returns the policy applied when the queue is full.
+func (q *Queue[T]) Policy() string {
+	return q.policy
+}
+
+// Dropped returns the number of items discarded since the queue was created.
+func (q *Queue[T]) Dropped() uint64 {
+	return q.dropped.Load()
+}
@@ -0,0 +1,60 @@
+package core
+
+import "testing"
+
+func TestQueue(t *testing.T) {
+	if _, err := NewQueue[int](0, DropOldest); err == nil {
+		t.Error("a queue of size 0 should not be allowed")
+	}
+	if _, err := NewQueue[int](1, "drop-all"); err == nil {
+		t.Error("invalid queue policy should not be allowed")
+	}
+
+	t.Run("drop-oldest", func(t *testing.T) {
+		q, err := NewQueue[int](2, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.Policy() != DropOldest {
+			t.Errorf("default policy should be %s, got %s", DropOldest, q.Policy())
+		}
+		for i := 1; i <= 2; i++ {
+			if !q.Push(i) {
+				t.Errorf("item %d should have been queued without drops", i)
+			}
+		}
+		if q.Push(3) {
+			t.Error("the oldest item should have been dropped")
+		}
+		if q.Len() != 2 || q.Dropped() != 1 {
+			t.Errorf("unexpected queue len/dropped: %d, %d", q.Len(), q.Dropped())
+		}
+		if item := <-q.C(); item != 2 {
+			t.Errorf("expected item 2, got %d", item)
+		}
+		if item := <-q.C(); item != 3 {
+			t.Errorf("expected item 3, got %d", item)
+		}
+	})
+
+	t.Run("drop-newest", func(t *testing.T) {
+		q, err := NewQueue[int](2, DropNewest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.Push(1)
+		q.Push(2)
+		if q.Push(3) {
+			t.Error("the newest item should have been dropped")
+		}
+		if q.Dropped() != 1 {
+			t.Errorf("expected 1 item dropped, got %d", q.Dropped())
+		}
+		if item := <-q.C(); item != 1 {
+			t.Errorf("expected item 1, got %d", item)
+		}
+		if item := <-q.C(); item != 2 {
+			t.Errorf("expected item 2, got %d", item)
+		}
+	})
+}
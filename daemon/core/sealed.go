@@ -0,0 +1,227 @@
+package core
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// StorageKeyCredential is the name of the systemd credential with the key to
+// encrypt the rules and the configuration at rest, used if it's passed to the
+// service (LoadCredential=, or LoadCredentialEncrypted= to seal it with the
+// TPM of the host).
+const StorageKeyCredential = "opensnitchd-storage-key"
+
+var (
+	// header of the encrypted files, followed by the nonce and the content
+	// encrypted with AES-256-GCM.
+	sealedMagic = []byte("OPENSNITCH-SEALED-1\n")
+	// prefix of the encrypted lines of the files of records (JSON lines),
+	// followed by the nonce and the line encrypted, in base64.
+	sealedLinePrefix = []byte("OPENSNITCH-SEALED-1:")
+
+	storageKey     cipher.AEAD
+	storageKeyLock sync.RWMutex
+)
+
+// LoadStorageKey loads the key to encrypt the rules and the configuration at
+// rest, from a file or from a systemd credential (a name without slashes).
+// If it's empty, the credential StorageKeyCredential is used if it's been
+// passed to the service, otherwise the files are not encrypted.
+// The key is derived from the content of the file (SHA-256), which must have
+// at least 16 bytes.
+func LoadStorageKey(path string) error {
+	credsDir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if path == "" {
+		if credsDir == "" {
+			return nil
+		}
+		path = filepath.Join(credsDir, StorageKeyCredential)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	} else if !strings.Contains(path, "/") {
+		if credsDir == "" {
+			return fmt.Errorf("storage key: credential %s not found, CREDENTIALS_DIRECTORY not set", path)
+		}
+		path = filepath.Join(credsDir, path)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("storage key: %s", err)
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 16 {
+		return fmt.Errorf("storage key %s: too short, at least 16 bytes are needed", path)
+	}
+	sum := sha256.Sum256(raw)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return fmt.Errorf("storage key: %s", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("storage key: %s", err)
+	}
+
+	storageKeyLock.Lock()
+	storageKey = aead
+	storageKeyLock.Unlock()
+	log.Info("Rules and configuration encrypted at rest, with the key %s", path)
+	return nil
+}
+
+// StorageEncrypted returns true if a storage key has been loaded.
+func StorageEncrypted() bool {
+	storageKeyLock.RLock()
+	defer storageKeyLock.RUnlock()
+	return storageKey != nil
+}
+
+// ReadSealedFile reads a file written with WriteSealedFile, decrypting it if
+// it's encrypted.
+// The plaintext files are read as they are. They're encrypted with
+// SealFiles, when the daemon starts.
+func ReadSealedFile(path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := unseal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return data, nil
+}
+
+// SealFiles encrypts the plaintext files of the list, written before
+// enabling the encryption, if a storage key has been loaded. The files that
+// don't exist are skipped.
+func SealFiles(paths []string) {
+	if !StorageEncrypted() {
+		return
+	}
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Warning("Error encrypting %s: %s", path, err)
+			continue
+		}
+		if len(raw) == 0 || bytes.HasPrefix(raw, sealedMagic) {
+			continue
+		}
+		if err := WriteSealedFile(path, raw, fi.Mode().Perm()); err != nil {
+			log.Warning("Error encrypting %s: %s", path, err)
+			continue
+		}
+		log.Info("%s encrypted with the storage key", path)
+	}
+}
+
+// WriteSealedFile writes a file, encrypted if a storage key has been loaded.
+func WriteSealedFile(path string, data []byte, perm os.FileMode) error {
+	storageKeyLock.RLock()
+	aead := storageKey
+	storageKeyLock.RUnlock()
+	if aead == nil {
+		return ioutil.WriteFile(path, data, perm)
+	}
+
+	out, err := seal(aead, sealedMagic, data)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, perm)
+}
+
+// SealLine encrypts a line of a file of records, if a storage key has been
+// loaded. The line is encoded in base64, so the records can still be appended
+// to the file one by one.
+func SealLine(line []byte) ([]byte, error) {
+	storageKeyLock.RLock()
+	aead := storageKey
+	storageKeyLock.RUnlock()
+	if aead == nil {
+		return line, nil
+	}
+	sealed, err := seal(aead, sealedLinePrefix, line)
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[len(sealedLinePrefix):]
+	out := make([]byte, len(sealedLinePrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, sealedLinePrefix)
+	base64.StdEncoding.Encode(out[len(sealedLinePrefix):], sealed)
+	return out, nil
+}
+
+// UnsealLine decrypts a line written with SealLine, if it's encrypted. sealed
+// is false for the plaintext lines.
+func UnsealLine(line []byte) (data []byte, sealed bool, err error) {
+	if !bytes.HasPrefix(line, sealedLinePrefix) {
+		return line, false, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(line[len(sealedLinePrefix):]))
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid encrypted line: %s", err)
+	}
+	data, err = open(raw, sealedLinePrefix)
+	return data, true, err
+}
+
+// seal appends the nonce and the data encrypted to prefix. The prefix is
+// authenticated too.
+func seal(aead cipher.AEAD, prefix, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(prefix)+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, prefix...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, prefix), nil
+}
+
+// unseal decrypts the content of a file, if it's encrypted.
+func unseal(raw []byte) (data []byte, sealed bool, err error) {
+	if !bytes.HasPrefix(raw, sealedMagic) {
+		return raw, false, nil
+	}
+	data, err = open(raw[len(sealedMagic):], sealedMagic)
+	return data, true, err
+}
+
+// open decrypts the nonce and the data encrypted by seal(), without the
+// prefix.
+func open(raw, prefix []byte) ([]byte, error) {
+	storageKeyLock.RLock()
+	aead := storageKey
+	storageKeyLock.RUnlock()
+	if aead == nil {
+		return nil, fmt.Errorf("encrypted, but no storage key has been loaded")
+	}
+	if len(raw) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted data")
+	}
+	data, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting, invalid storage key? %s", err)
+	}
+	return data, nil
+}
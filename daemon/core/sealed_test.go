@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func resetStorageKey(t *testing.T) {
+	t.Cleanup(func() {
+		storageKeyLock.Lock()
+		storageKey = nil
+		storageKeyLock.Unlock()
+	})
+}
+
+func TestSealedFiles(t *testing.T) {
+	resetStorageKey(t)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	ioutil.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef\n"), 0600)
+
+	plain := []byte(`{"name": "000-allow-curl"}`)
+	rule := filepath.Join(dir, "000-allow-curl.json")
+	if err := WriteSealedFile(rule, plain, 0600); err != nil {
+		t.Fatal("WriteSealedFile() error:", err)
+	}
+
+	if err := LoadStorageKey(keyFile); err != nil {
+		t.Fatal("LoadStorageKey() error:", err)
+	}
+	if !StorageEncrypted() {
+		t.Fatal("storage key not loaded")
+	}
+
+	// the files written before enabling the encryption are read, but they're
+	// only encrypted explicitly.
+	data, err := ReadSealedFile(rule)
+	if err != nil || !bytes.Equal(data, plain) {
+		t.Fatalf("plaintext file not read: %s, %v", data, err)
+	}
+	if raw, _ := ioutil.ReadFile(rule); !bytes.Equal(raw, plain) {
+		t.Errorf("plaintext file modified when read: %s", raw)
+	}
+	SealFiles([]string{rule, filepath.Join(dir, "missing.json")})
+	raw, _ := ioutil.ReadFile(rule)
+	if !bytes.HasPrefix(raw, sealedMagic) || bytes.Contains(raw, []byte("allow-curl")) {
+		t.Errorf("plaintext file not encrypted: %s", raw)
+	}
+	if fi, _ := os.Stat(rule); fi.Mode().Perm() != 0600 {
+		t.Error("permissions of the file not kept:", fi.Mode())
+	}
+
+	data, err = ReadSealedFile(rule)
+	if err != nil || !bytes.Equal(data, plain) {
+		t.Fatalf("encrypted file not decrypted: %s, %v", data, err)
+	}
+
+	// the lines of the decisions are encrypted one by one.
+	line, err := SealLine(plain)
+	if err != nil || !bytes.HasPrefix(line, sealedLinePrefix) || bytes.ContainsAny(line, "\n") {
+		t.Fatalf("invalid sealed line: %s, %v", line, err)
+	}
+	if data, sealed, err := UnsealLine(line); err != nil || !sealed || !bytes.Equal(data, plain) {
+		t.Errorf("sealed line not decrypted: %s, %v, %v", data, sealed, err)
+	}
+	if data, sealed, err := UnsealLine(plain); err != nil || sealed || !bytes.Equal(data, plain) {
+		t.Errorf("plaintext line not read: %s, %v, %v", data, sealed, err)
+	}
+
+	// another key can't decrypt them.
+	ioutil.WriteFile(keyFile, []byte("another key of the storage"), 0600)
+	if err := LoadStorageKey(keyFile); err != nil {
+		t.Fatal("LoadStorageKey() error:", err)
+	}
+	if _, err := ReadSealedFile(rule); err == nil {
+		t.Error("file decrypted with another key")
+	}
+
+	storageKeyLock.Lock()
+	storageKey = nil
+	storageKeyLock.Unlock()
+	if _, err := ReadSealedFile(rule); err == nil {
+		t.Error("encrypted file read without key")
+	}
+}
+
+func TestLoadStorageKey(t *testing.T) {
+	resetStorageKey(t)
+	dir := t.TempDir()
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+
+	// no credential passed to the service.
+	if err := LoadStorageKey(""); err != nil || StorageEncrypted() {
+		t.Fatal("storage key loaded without credential:", err)
+	}
+	if err := LoadStorageKey("custom-key"); err == nil {
+		t.Error("missing credential accepted")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, StorageKeyCredential), []byte("short"), 0600)
+	if err := LoadStorageKey(""); err == nil {
+		t.Error("short key accepted")
+	}
+	ioutil.WriteFile(filepath.Join(dir, StorageKeyCredential), []byte("a long enough key for the storage"), 0600)
+	if err := LoadStorageKey(""); err != nil || !StorageEncrypted() {
+		t.Error("credential not loaded:", err)
+	}
+}
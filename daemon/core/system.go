@@ -120,6 +120,15 @@ func CheckSysRequirements() {
         "Reason": " * UPROBES not supported. Common error => cannot open uprobe_events: open /sys/kernel/debug/tracing/uprobe_events"
     }
 },
+{
+    "Item": "btf",
+    "Checks": {
+        "Regexps": [
+            "CONFIG_DEBUG_INFO_BTF=y"
+            ],
+        "Reason": " * BTF not available. The CO-RE eBPF module opensnitch-procs.o can only be loaded configuring Ebpf.BTFPath."
+    }
+},
 {
     "Item": "ftrace",
     "Checks": {
@@ -220,6 +229,14 @@ func CheckSysRequirements() {
 		fmt.Printf("\t* %s\t %s\n\n", log.Bold(log.Red("tracefs mount not found, needed for syscalls (mount -t tracefs none /sys/kernel/tracing/)")), log.Bold(log.Red("✘")))
 	}
 
+	lockdown := GetLockdownMode()
+	if EbpfAllowedByLockdown() {
+		fmt.Printf("\t* %s\t %s\n\n", log.Bold(log.Green(fmt.Sprint("kernel lockdown: ", lockdown, ", secure boot: ", IsSecureBootEnabled()))), log.Bold(log.Green("✔")))
+	} else {
+		reqsFullfiled = false
+		fmt.Printf("\t* %s\t %s\n\n", log.Bold(log.Red(fmt.Sprint("kernel lockdown: ", lockdown, ", eBPF not allowed, use 'proc' or 'audit' as process monitor method"))), log.Bold(log.Red("✘")))
+	}
+
 	if !reqsFullfiled {
 		log.Raw("\n%sWARNING:%s Your kernel doesn't support some of the features OpenSnitch needs:\nRead more: https://github.com/evilsocket/opensnitch/issues/774\n", log.FG_WHITE+log.BG_YELLOW, log.RESET)
 	}
@@ -0,0 +1,199 @@
+// Package denypage serves a page explaining why a plaintext HTTP connection
+// has been blocked, instead of letting it time out. The firewall redirects the
+// denied connections to a local port, where the responder looks up the rule
+// that denied them by the source port of the connection.
+package denypage
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/firewall"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// only the connections to this port are redirected, the rest of the
+// protocols can't be answered with a page.
+const httpPort = 80
+
+var (
+	defaultPort = uint16(8077)
+	// time the connections redirected are remembered, waiting for their
+	// requests.
+	blockedTTL  = time.Minute
+	maxBlocked  = 4096
+	setFirewall = firewall.SetDenyPage
+
+	page = template.Must(template.New("denypage").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 4em auto;">
+<h1>{{.Title}}</h1>
+<p>{{.Text}}</p>
+</body>
+</html>
+`))
+)
+
+// Config holds the configuration of the deny page.
+type Config struct {
+	// Port where the responder listens on the loopback interface (8077 by
+	// default).
+	Port uint16 `json:"Port"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+type blocked struct {
+	time    time.Time
+	rule    string
+	process string
+	host    string
+}
+
+// Responder answers the HTTP connections denied with the deny page.
+type Responder struct {
+	servers []*http.Server
+	// connections redirected, by source port.
+	blocked map[uint16]*blocked
+
+	cfg Config
+	sync.Mutex
+}
+
+// Default is the responder of the daemon.
+var Default = New()
+
+// New returns a new responder, disabled until it's configured.
+func New() *Responder {
+	return &Responder{blocked: make(map[uint16]*blocked)}
+}
+
+// SetConfig starts or stops the responder, and the redirection of the denied
+// connections.
+func (r *Responder) SetConfig(cfg Config) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.stop()
+	if !cfg.Enabled {
+		return setFirewall(0)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	for _, ip := range []string{"127.0.0.1", "::1"} {
+		l, err := net.Listen("tcp", net.JoinHostPort(ip, strconv.Itoa(int(cfg.Port))))
+		if err != nil {
+			if ip == "::1" {
+				log.Debug("[denypage] not listening on %s: %s", ip, err)
+				continue
+			}
+			r.stop()
+			setFirewall(0)
+			return fmt.Errorf("deny page: %s", err)
+		}
+		srv := &http.Server{Handler: r, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(l)
+		r.servers = append(r.servers, srv)
+	}
+	if err := setFirewall(cfg.Port); err != nil {
+		r.stop()
+		return err
+	}
+	r.cfg = cfg
+	log.Info("[denypage] denied HTTP connections redirected to port %d", cfg.Port)
+	return nil
+}
+
+// Enabled returns true if the denied HTTP connections are redirected.
+func (r *Responder) Enabled() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.cfg.Enabled
+}
+
+// Handles returns true if a connection denied must be redirected to the
+// deny page: plaintext HTTP connections, while the responder is enabled.
+func (r *Responder) Handles(con *conman.Connection) bool {
+	return con != nil && strings.HasPrefix(con.Protocol, "tcp") && con.DstPort == httpPort && r.Enabled()
+}
+
+// Redirect remembers the rule that has denied a connection, to answer its
+// requests with the deny page. It returns false if the connection can't be
+// redirected, and it must be dropped.
+func (r *Responder) Redirect(con *conman.Connection, ruleName string) bool {
+	if !r.Handles(con) {
+		return false
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	if len(r.blocked) >= maxBlocked {
+		for port, b := range r.blocked {
+			if now.Sub(b.time) > blockedTTL {
+				delete(r.blocked, port)
+			}
+		}
+		if len(r.blocked) >= maxBlocked {
+			return false
+		}
+	}
+	b := &blocked{time: now, rule: ruleName, host: con.DstHost}
+	if b.host == "" {
+		b.host = con.DstIP.String()
+	}
+	if con.Process != nil {
+		b.process = con.Process.Path
+	}
+	r.blocked[uint16(con.SrcPort)] = b
+	return true
+}
+
+// ServeHTTP answers the requests of the connections redirected.
+func (r *Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var b *blocked
+	if _, port, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if p, err := strconv.ParseUint(port, 10, 16); err == nil {
+			r.Lock()
+			b = r.blocked[uint16(p)]
+			r.Unlock()
+		}
+	}
+	data := struct {
+		Title string
+		Text  string
+	}{Title: i18n.New(i18n.DenyPageTitle).String()}
+	if b != nil {
+		host := req.Host
+		if host == "" {
+			host = b.host
+		}
+		data.Text = i18n.New(i18n.DenyPage, "path", b.process, "host", host, "rule", b.rule).String()
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	if err := page.Execute(w, data); err != nil {
+		log.Debug("[denypage] error writing the page: %s", err)
+	}
+}
+
+// stop stops the servers. The caller must hold the lock.
+func (r *Responder) stop() {
+	for _, srv := range r.servers {
+		srv.Close()
+	}
+	r.servers = nil
+	r.cfg = Config{}
+}
@@ -0,0 +1,95 @@
+package denypage
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+func freePort(t *testing.T) uint16 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+func TestResponder(t *testing.T) {
+	fwPort := uint16(1)
+	origSetFirewall := setFirewall
+	setFirewall = func(port uint16) error {
+		fwPort = port
+		return nil
+	}
+	defer func() { setFirewall = origSetFirewall }()
+
+	r := New()
+	con := &conman.Connection{
+		Protocol: "tcp",
+		SrcIP:    net.ParseIP("192.168.1.5"),
+		SrcPort:  40000,
+		DstIP:    net.ParseIP("185.53.178.14"),
+		DstHost:  "opensnitch.io",
+		DstPort:  80,
+		Process:  &procmon.Process{Path: "/usr/bin/curl"},
+	}
+	if r.Redirect(con, "deny-curl") {
+		t.Error("connection redirected with the deny page disabled")
+	}
+
+	port := freePort(t)
+	if err := r.SetConfig(Config{Enabled: true, Port: port}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer r.SetConfig(Config{})
+	if fwPort != port {
+		t.Error("firewall not configured:", fwPort)
+	}
+
+	https := *con
+	https.DstPort = 443
+	if r.Redirect(&https, "deny-curl") {
+		t.Error("HTTPS connection redirected")
+	}
+	if !r.Redirect(con, "deny-curl") {
+		t.Fatal("HTTP connection not redirected")
+	}
+
+	req := httptest.NewRequest("GET", "http://opensnitch.io/", nil)
+	req.RemoteAddr = "192.168.1.5:40000"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	body := w.Body.String()
+	if w.Code != http.StatusForbidden || !strings.Contains(body, "deny-curl") || !strings.Contains(body, "/usr/bin/curl") {
+		t.Errorf("invalid deny page: %d, %s", w.Code, body)
+	}
+
+	// unknown connections get a generic page.
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port))
+	if err != nil {
+		t.Fatal("responder not listening:", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || strings.Contains(string(raw), "deny-curl") {
+		t.Errorf("invalid generic deny page: %d, %s", resp.StatusCode, raw)
+	}
+
+	if err := r.SetConfig(Config{}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	if fwPort != 0 || r.Redirect(con, "deny-curl") {
+		t.Error("deny page not disabled")
+	}
+	if _, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port)); err == nil {
+		t.Error("responder not stopped")
+	}
+}
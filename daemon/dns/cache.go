@@ -0,0 +1,366 @@
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+var (
+	defaultMaxEntries  = 65536
+	defaultMinTTL      = time.Hour
+	defaultMaxTTL      = 24 * time.Hour
+	defaultNegativeTTL = 5 * time.Minute
+	// interval to save the cache to disk, besides when the daemon exits.
+	saveInterval = 10 * time.Minute
+
+	timeNow = time.Now
+)
+
+// CacheConfig holds the configuration of the cache of the domains resolved.
+type CacheConfig struct {
+	// File where the cache is saved, and loaded from when the daemon starts,
+	// so the rules of domains keep matching the connections to the IPs
+	// resolved before restarting it. Empty to not persist it.
+	File string `json:"File"`
+	// MaxEntries is the maximum number of records (65536 by default). When
+	// it's full, the records closer to expire are evicted first.
+	MaxEntries int `json:"MaxEntries"`
+	// MinTTL is the minimum time the records are kept, whatever their TTL,
+	// because the applications keep connecting to the IPs resolved after
+	// they expire (1h by default).
+	MinTTL string `json:"MinTTL"`
+	// MaxTTL is the maximum time the records are kept (24h by default).
+	MaxTTL string `json:"MaxTTL"`
+	// NegativeTTL is the maximum time the domains that don't resolve are
+	// remembered, if their responses have a lower TTL (5m by default).
+	NegativeTTL string `json:"NegativeTTL"`
+}
+
+// Record is an IP or CNAME resolved, and the domain it's been resolved from.
+type Record struct {
+	Resolved string    `json:"resolved"`
+	Host     string    `json:"host"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Negative is a domain that doesn't resolve (NXDOMAIN, or no records).
+type Negative struct {
+	Host    string    `json:"host"`
+	Expires time.Time `json:"expires"`
+}
+
+// Dump holds the records of the cache, sorted.
+type Dump struct {
+	Records  []Record   `json:"records"`
+	Negative []Negative `json:"negative"`
+}
+
+// DomainCache associates the IPs (and CNAMEs) resolved with the domains they
+// have been resolved from, to match the connections against the rules of
+// domains. The records expire after their TTL, bounded by the minimum and
+// maximum TTL configured.
+type DomainCache struct {
+	records  map[string]*Record
+	negative map[string]time.Time
+
+	file        string
+	maxEntries  int
+	minTTL      time.Duration
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	stopSaving  chan struct{}
+
+	sync.RWMutex
+}
+
+// Cache is the cache of the domains resolved.
+var Cache = NewCache()
+
+// NewCache returns a new cache, with the default configuration.
+func NewCache() *DomainCache {
+	return &DomainCache{
+		records:     make(map[string]*Record),
+		negative:    make(map[string]time.Time),
+		maxEntries:  defaultMaxEntries,
+		minTTL:      defaultMinTTL,
+		maxTTL:      defaultMaxTTL,
+		negativeTTL: defaultNegativeTTL,
+	}
+}
+
+func parseTTL(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("DNS cache, invalid %s: %s", name, value)
+	}
+	return ttl, nil
+}
+
+// SetConfig applies a new configuration. The records of the file configured
+// are loaded, if it has changed.
+func (c *DomainCache) SetConfig(cfg CacheConfig) error {
+	minTTL, err := parseTTL("MinTTL", cfg.MinTTL, defaultMinTTL)
+	if err != nil {
+		return err
+	}
+	maxTTL, err := parseTTL("MaxTTL", cfg.MaxTTL, defaultMaxTTL)
+	if err != nil {
+		return err
+	}
+	negativeTTL, err := parseTTL("NegativeTTL", cfg.NegativeTTL, defaultNegativeTTL)
+	if err != nil {
+		return err
+	}
+	if maxTTL < minTTL {
+		return fmt.Errorf("DNS cache, MaxTTL %s lower than MinTTL %s", maxTTL, minTTL)
+	}
+
+	c.Lock()
+	c.minTTL, c.maxTTL, c.negativeTTL = minTTL, maxTTL, negativeTTL
+	c.maxEntries = cfg.MaxEntries
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultMaxEntries
+	}
+	if len(c.records) > c.maxEntries {
+		c.evict(timeNow())
+	}
+	fileChanged := cfg.File != c.file
+	c.file = cfg.File
+	if c.stopSaving != nil {
+		close(c.stopSaving)
+		c.stopSaving = nil
+	}
+	if c.file != "" {
+		c.stopSaving = make(chan struct{})
+		go c.saveEvery(saveInterval, c.stopSaving)
+	}
+	c.Unlock()
+
+	if fileChanged && cfg.File != "" {
+		return c.Load()
+	}
+	return nil
+}
+
+// Add adds an IP or CNAME resolved from a domain, to be kept during its TTL.
+// A TTL of 0 keeps it the minimum TTL.
+func (c *DomainCache) Add(resolved, host string, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := timeNow()
+	if ttl < c.minTTL {
+		ttl = c.minTTL
+	}
+	if ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	if r, found := c.records[resolved]; found {
+		r.Host = host
+		r.Expires = now.Add(ttl)
+	} else {
+		if len(c.records) >= c.maxEntries {
+			c.evict(now)
+		}
+		c.records[resolved] = &Record{Resolved: resolved, Host: host, Expires: now.Add(ttl)}
+	}
+	delete(c.negative, host)
+}
+
+// AddNegative adds a domain that doesn't resolve, to be remembered during
+// the TTL of the response, bounded by the negative TTL configured.
+func (c *DomainCache) AddNegative(host string, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	if ttl <= 0 || ttl > c.negativeTTL {
+		ttl = c.negativeTTL
+	}
+	if ttl == 0 {
+		return
+	}
+	now := timeNow()
+	if _, found := c.negative[host]; !found && len(c.negative) >= c.maxEntries {
+		for h, expires := range c.negative {
+			if now.After(expires) {
+				delete(c.negative, h)
+			}
+		}
+		if len(c.negative) >= c.maxEntries {
+			return
+		}
+	}
+	c.negative[host] = now.Add(ttl)
+}
+
+// Host returns the domain an IP or CNAME has been resolved from, if it has
+// not expired.
+func (c *DomainCache) Host(resolved string) (string, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	r, found := c.records[resolved]
+	if !found || timeNow().After(r.Expires) {
+		return "", false
+	}
+	return r.Host, true
+}
+
+// IsNegative returns true if a domain is known not to resolve.
+func (c *DomainCache) IsNegative(host string) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	expires, found := c.negative[host]
+	return found && !timeNow().After(expires)
+}
+
+// Dump returns the records that have not expired, sorted.
+func (c *DomainCache) Dump() *Dump {
+	c.RLock()
+	defer c.RUnlock()
+
+	now := timeNow()
+	d := &Dump{Records: make([]Record, 0, len(c.records)), Negative: make([]Negative, 0, len(c.negative))}
+	for _, r := range c.records {
+		if !now.After(r.Expires) {
+			d.Records = append(d.Records, *r)
+		}
+	}
+	for host, expires := range c.negative {
+		if !now.After(expires) {
+			d.Negative = append(d.Negative, Negative{Host: host, Expires: expires})
+		}
+	}
+	sort.Slice(d.Records, func(i, j int) bool { return d.Records[i].Resolved < d.Records[j].Resolved })
+	sort.Slice(d.Negative, func(i, j int) bool { return d.Negative[i].Host < d.Negative[j].Host })
+	return d
+}
+
+// Flush deletes all the records, and returns how many have been deleted.
+func (c *DomainCache) Flush() int {
+	c.Lock()
+	defer c.Unlock()
+
+	n := len(c.records) + len(c.negative)
+	c.records = make(map[string]*Record)
+	c.negative = make(map[string]time.Time)
+	return n
+}
+
+// Load adds the records saved to the file configured, except the expired
+// ones.
+func (c *DomainCache) Load() error {
+	c.RLock()
+	file := c.file
+	c.RUnlock()
+	if file == "" {
+		return nil
+	}
+
+	raw, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("DNS cache: %s", err)
+	}
+	var d Dump
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return fmt.Errorf("DNS cache, invalid file %s: %s", file, err)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	now := timeNow()
+	for i := range d.Records {
+		r := d.Records[i]
+		if now.After(r.Expires) {
+			continue
+		}
+		if _, found := c.records[r.Resolved]; found {
+			continue
+		}
+		if len(c.records) >= c.maxEntries {
+			c.evict(now)
+		}
+		c.records[r.Resolved] = &r
+	}
+	for _, n := range d.Negative {
+		if !now.After(n.Expires) {
+			c.negative[n.Host] = n.Expires
+		}
+	}
+	log.Info("[DNS] %d records loaded from %s", len(c.records), file)
+	return nil
+}
+
+// Save writes the records that have not expired to the file configured.
+func (c *DomainCache) Save() error {
+	c.RLock()
+	file := c.file
+	c.RUnlock()
+	if file == "" {
+		return nil
+	}
+
+	raw, err := json.Marshal(c.Dump())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("DNS cache: %s", err)
+	}
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("DNS cache: %s", err)
+	}
+	return os.Rename(tmp, file)
+}
+
+func (c *DomainCache) saveEvery(interval time.Duration, stop chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if err := c.Save(); err != nil {
+				log.Warning("[DNS] error saving the cache: %s", err)
+			}
+		}
+	}
+}
+
+// evict deletes the expired records, and if the cache is still full, the
+// records closer to expire, down to 90% of its size. The caller must hold
+// the lock.
+func (c *DomainCache) evict(now time.Time) {
+	for resolved, r := range c.records {
+		if now.After(r.Expires) {
+			delete(c.records, resolved)
+		}
+	}
+	if len(c.records) < c.maxEntries {
+		return
+	}
+	list := make([]*Record, 0, len(c.records))
+	for _, r := range c.records {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Expires.Before(list[j].Expires) })
+	for _, r := range list[:len(list)-c.maxEntries*9/10] {
+		delete(c.records, r.Resolved)
+	}
+}
@@ -0,0 +1,165 @@
+package dns
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+func setNow(t *testing.T, now time.Time) {
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+}
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Now()
+	setNow(t, now)
+
+	c := NewCache()
+	if err := c.SetConfig(CacheConfig{MinTTL: "1m", MaxTTL: "1h"}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	c.Add("1.1.1.1", "one.one.one.one", 10*time.Second)
+	c.Add("9.9.9.9", "dns.quad9.net", 10*time.Minute)
+	c.Add("8.8.8.8", "dns.google", 48*time.Hour)
+
+	tests := []struct {
+		after    time.Duration
+		resolved string
+		found    bool
+	}{
+		// the TTL is raised to the minimum.
+		{30 * time.Second, "1.1.1.1", true},
+		{2 * time.Minute, "1.1.1.1", false},
+		{5 * time.Minute, "9.9.9.9", true},
+		{11 * time.Minute, "9.9.9.9", false},
+		// and lowered to the maximum.
+		{59 * time.Minute, "8.8.8.8", true},
+		{61 * time.Minute, "8.8.8.8", false},
+	}
+	for _, test := range tests {
+		setNow(t, now.Add(test.after))
+		if _, found := c.Host(test.resolved); found != test.found {
+			t.Errorf("%s after %s: found %v, expected %v", test.resolved, test.after, found, test.found)
+		}
+	}
+
+	if err := c.SetConfig(CacheConfig{MinTTL: "2h", MaxTTL: "1h"}); err == nil {
+		t.Error("MaxTTL lower than MinTTL accepted")
+	}
+	if err := c.SetConfig(CacheConfig{NegativeTTL: "5x"}); err == nil {
+		t.Error("invalid NegativeTTL accepted")
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	now := time.Now()
+	setNow(t, now)
+
+	c := NewCache()
+	c.AddNegative("nonexistent.example.com", 30*time.Second)
+	// bounded by the negative TTL.
+	c.AddNegative("typo.example.com", time.Hour)
+	if !c.IsNegative("nonexistent.example.com") || !c.IsNegative("typo.example.com") {
+		t.Error("negative records not found")
+	}
+	setNow(t, now.Add(time.Minute))
+	if c.IsNegative("nonexistent.example.com") || !c.IsNegative("typo.example.com") {
+		t.Error("negative records not expired after their TTL")
+	}
+	setNow(t, now.Add(6*time.Minute))
+	if c.IsNegative("typo.example.com") {
+		t.Error("negative record not expired after the negative TTL")
+	}
+
+	// resolving a domain deletes its negative record.
+	c.AddNegative("new.example.com", 0)
+	c.Add("93.184.215.14", "new.example.com", 0)
+	if c.IsNegative("new.example.com") {
+		t.Error("negative record of a domain resolved")
+	}
+
+	origCache := Cache
+	Cache = c
+	defer func() { Cache = origCache }()
+	trackNegative(&layers.DNS{
+		ResponseCode: layers.DNSResponseCodeNXDomain,
+		Questions:    []layers.DNSQuestion{{Name: []byte("nx.example.org"), Type: layers.DNSTypeA}},
+		Authorities:  []layers.DNSResourceRecord{{Type: layers.DNSTypeSOA, TTL: 3600, SOA: layers.DNSSOA{Minimum: 60}}},
+	})
+	if !c.IsNegative("nx.example.org") {
+		t.Fatal("NXDOMAIN response not cached")
+	}
+	setNow(t, now.Add(6*time.Minute+2*time.Minute))
+	if c.IsNegative("nx.example.org") {
+		t.Error("NXDOMAIN response cached longer than the SOA minimum")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	now := time.Now()
+	setNow(t, now)
+
+	c := NewCache()
+	c.SetConfig(CacheConfig{MaxEntries: 10, MinTTL: "1m", MaxTTL: "1h"})
+	for i := 0; i < 10; i++ {
+		ip := net.IPv4(10, 0, 0, byte(i)).String()
+		c.Add(ip, "host.example.com", time.Duration(i+1)*time.Minute)
+	}
+	c.Add("10.0.1.1", "new.example.com", time.Hour)
+	if len(c.records) > 10 {
+		t.Fatal("cache not evicted:", len(c.records))
+	}
+	if _, found := c.Host("10.0.0.0"); found {
+		t.Error("the record closest to expire should have been evicted")
+	}
+	if _, found := c.Host("10.0.0.9"); !found {
+		t.Error("the record furthest to expire should have been kept")
+	}
+	if _, found := c.Host("10.0.1.1"); !found {
+		t.Error("new record not added")
+	}
+
+	if n := c.Flush(); n == 0 || len(c.Dump().Records) != 0 {
+		t.Error("cache not flushed:", n)
+	}
+}
+
+func TestCachePersistence(t *testing.T) {
+	now := time.Now()
+	setNow(t, now)
+
+	file := filepath.Join(t.TempDir(), "cache", "dns.json")
+	c := NewCache()
+	if err := c.SetConfig(CacheConfig{File: file}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer c.SetConfig(CacheConfig{})
+	c.Add("93.184.215.14", "example.com", 0)
+	c.Add("1.1.1.1", "one.one.one.one", 2*time.Hour)
+	c.AddNegative("nx.example.com", 0)
+	if err := c.Save(); err != nil {
+		t.Fatal("Save() error:", err)
+	}
+
+	// the records expired while the daemon was stopped are not loaded.
+	setNow(t, now.Add(90*time.Minute))
+	loaded := NewCache()
+	if err := loaded.SetConfig(CacheConfig{File: file}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer loaded.SetConfig(CacheConfig{})
+	if host, found := loaded.Host("1.1.1.1"); !found || host != "one.one.one.one" {
+		t.Error("record not loaded:", host)
+	}
+	if _, found := loaded.Host("93.184.215.14"); found {
+		t.Error("expired record loaded")
+	}
+	if len(loaded.Dump().Negative) != 0 {
+		t.Error("expired negative record loaded")
+	}
+}
@@ -0,0 +1,203 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+
+	"golang.org/x/sys/unix"
+)
+
+// Config holds the configuration of the DNS options.
+type Config struct {
+	// PushSocket is the path of a unix socket where local resolvers (dnsmasq,
+	// unbound, ...) can push the domains they resolve, i.e.:
+	// /run/opensnitchd/dns.sock
+	// Empty to disable it.
+	PushSocket string `json:"PushSocket"`
+
+	// PushAllowedUsers are the users (names or UIDs) allowed to push domains,
+	// besides root. Usually the user the resolver runs as (dnsmasq, unbound).
+	PushAllowedUsers []string `json:"PushAllowedUsers"`
+
+	// Cache is the configuration of the cache of the domains resolved.
+	Cache CacheConfig `json:"Cache"`
+}
+
+// PushListener receives the domains resolved by local resolvers.
+//
+// When all the DNS queries of the system go through a local caching resolver,
+// the DNS responses intercepted are sent to the resolver, and the domains
+// resolved from its cache are never seen by the daemon. The resolver can send
+// the domains to the daemon instead.
+//
+// The protocol is line-based, one record per line, with the IP (or CNAME)
+// resolved and the domain name separated by spaces:
+//
+//	93.184.215.14 example.com
+//	cdn.example.net www.example.net
+type PushListener struct {
+	listener net.Listener
+	sync.Mutex
+}
+
+// Pusher is the listener of the domains pushed by local resolvers.
+var Pusher = &PushListener{}
+
+// SetConfig stops the current listener, and starts listening on the
+// configured socket.
+func (p *PushListener) SetConfig(cfg Config) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.listener != nil {
+		p.listener.Close()
+		p.listener = nil
+	}
+	if cfg.PushSocket == "" {
+		return nil
+	}
+
+	allowed := map[uint32]bool{0: true}
+	for _, name := range cfg.PushAllowedUsers {
+		u, err := user.Lookup(name)
+		if err != nil {
+			u, err = user.LookupId(name)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid DNS push user %s: %s", name, err)
+		}
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		allowed[uint32(uid)] = true
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.PushSocket), 0755); err != nil {
+		return err
+	}
+	os.Remove(cfg.PushSocket)
+	listener, err := net.Listen("unix", cfg.PushSocket)
+	if err != nil {
+		return err
+	}
+	// the users allowed are verified on every connection.
+	if err := os.Chmod(cfg.PushSocket, 0666); err != nil {
+		listener.Close()
+		return err
+	}
+	p.listener = listener
+	log.Info("[DNS] listening for domains pushed by local resolvers on %s", cfg.PushSocket)
+
+	go p.accept(listener, allowed)
+	return nil
+}
+
+func (p *PushListener) accept(listener net.Listener, allowed map[uint32]bool) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Debug("[DNS] push listener closed: %s", err)
+			return
+		}
+		uid, err := peerUID(conn)
+		if err != nil {
+			log.Warning("[DNS] unable to get the credentials of the resolver: %s", err)
+			conn.Close()
+			continue
+		}
+		if !allowed[uid] {
+			log.Warning("[DNS] user %d not allowed to push domains", uid)
+			conn.Close()
+			continue
+		}
+		go p.read(conn)
+	}
+}
+
+func (p *PushListener) read(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		resolved, hostname, err := parsePushRecord(scanner.Text())
+		if err != nil {
+			log.Debug("[DNS] invalid record pushed: %s", err)
+			continue
+		}
+		if resolved == "" {
+			continue
+		}
+		Track(resolved, hostname)
+	}
+}
+
+// parsePushRecord parses a line with the format: <IP or CNAME> <hostname>
+// Empty lines and comments are ignored.
+func parsePushRecord(line string) (resolved, hostname string, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return "", "", nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("expected '<resolved> <hostname>': %q", line)
+	}
+	resolved = strings.TrimSuffix(fields[0], ".")
+	hostname = strings.TrimSuffix(fields[1], ".")
+	if !isDomainName(hostname) {
+		return "", "", fmt.Errorf("invalid hostname: %q", hostname)
+	}
+	if ip := net.ParseIP(resolved); ip != nil {
+		return ip.String(), hostname, nil
+	}
+	if !isDomainName(resolved) {
+		return "", "", fmt.Errorf("invalid IP or CNAME: %q", resolved)
+	}
+	return resolved, hostname, nil
+}
+
+func isDomainName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// peerUID returns the UID of the process connected to the socket.
+func peerUID(conn net.Conn) (uint32, error) {
+	uconn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, fmt.Errorf("not a unix socket")
+	}
+	raw, err := uconn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return cred.Uid, nil
+}
@@ -0,0 +1,67 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePushRecord(t *testing.T) {
+	tests := []struct {
+		line, resolved, hostname string
+		fails                    bool
+	}{
+		{"93.184.215.14 example.com", "93.184.215.14", "example.com", false},
+		{"  2606:2800::1   example.com.  ", "2606:2800::1", "example.com", false},
+		{"cdn.example.net. www.example.net.", "cdn.example.net", "www.example.net", false},
+		{"# comment", "", "", false},
+		{"", "", "", false},
+		{"1.1.1.1", "", "", true},
+		{"1.1.1.1 example.com extra", "", "", true},
+		{"1.1.1.1 exa$mple.com", "", "", true},
+		{"in/valid example.com", "", "", true},
+	}
+	for _, test := range tests {
+		resolved, hostname, err := parsePushRecord(test.line)
+		if (err != nil) != test.fails {
+			t.Errorf("%q: unexpected error: %v", test.line, err)
+		}
+		if resolved != test.resolved || hostname != test.hostname {
+			t.Errorf("%q: expected %q %q, got %q %q", test.line, test.resolved, test.hostname, resolved, hostname)
+		}
+	}
+}
+
+func TestPushListener(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "dns.sock")
+	p := &PushListener{}
+	err := p.SetConfig(Config{
+		PushSocket:       sock,
+		PushAllowedUsers: []string{fmt.Sprint(os.Getuid())},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.SetConfig(Config{})
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(conn, "198.51.100.7 pushed.example.org\ninvalid line\n")
+	conn.Close()
+
+	for i := 0; i < 50; i++ {
+		if host, found := Host("198.51.100.7"); found {
+			if host != "pushed.example.org" {
+				t.Errorf("unexpected host pushed: %s", host)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("the domain pushed has not been tracked")
+}
@@ -2,7 +2,7 @@ package dns
 
 import (
 	"net"
-	"sync"
+	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/log"
 
@@ -10,11 +10,6 @@ import (
 	"github.com/google/gopacket/layers"
 )
 
-var (
-	responses = make(map[string]string, 0)
-	lock      = sync.RWMutex{}
-)
-
 // TrackAnswers obtains the resolved domains of a DNS query.
 // If the packet is UDP DNS, the domain names are added to the list of resolved domains.
 func TrackAnswers(packet gopacket.Packet) bool {
@@ -41,42 +36,87 @@ func TrackAnswers(packet gopacket.Packet) bool {
 		return false
 	}
 
+	resolved := false
 	for _, ans := range dnsAns.Answers {
 		if ans.Name != nil {
+			ttl := time.Duration(ans.TTL) * time.Second
 			if ans.IP != nil {
-				Track(ans.IP.String(), string(ans.Name))
+				TrackTTL(ans.IP.String(), string(ans.Name), ttl)
+				resolved = true
 			} else if ans.CNAME != nil {
-				Track(string(ans.CNAME), string(ans.Name))
+				TrackTTL(string(ans.CNAME), string(ans.Name), ttl)
+				resolved = true
 			}
 		}
 	}
+	// the responses without records of a type (NODATA) only tell that the
+	// domain has no records of that type, only NXDOMAIN is cached.
+	if !resolved && dnsAns.ResponseCode == layers.DNSResponseCodeNXDomain {
+		trackNegative(dnsAns)
+	}
 
 	return true
 }
 
+// trackNegative adds the domains of a response that don't exist to the cache,
+// during the TTL of the SOA record of the response (RFC 2308).
+func trackNegative(dnsAns *layers.DNS) {
+	var ttl time.Duration
+	for _, auth := range dnsAns.Authorities {
+		if auth.Type == layers.DNSTypeSOA {
+			ttl = time.Duration(auth.TTL) * time.Second
+			if min := time.Duration(auth.SOA.Minimum) * time.Second; min < ttl {
+				ttl = min
+			}
+			break
+		}
+	}
+	for _, q := range dnsAns.Questions {
+		Cache.AddNegative(string(q.Name), ttl)
+		log.Debug("New negative DNS record: %s", q.Name)
+	}
+}
+
 // Track adds a resolved domain to the list.
 func Track(resolved string, hostname string) {
-	lock.Lock()
-	defer lock.Unlock()
+	TrackTTL(resolved, hostname, 0)
+}
 
+// TrackTTL adds a resolved domain to the list, to be kept during the given
+// TTL, bounded by the minimum and maximum TTL of the cache.
+func TrackTTL(resolved string, hostname string, ttl time.Duration) {
 	if len(resolved) > 3 && resolved[0:4] == "127." {
 		return
 	}
 	if resolved == "::1" || resolved == hostname {
 		return
 	}
-	responses[resolved] = hostname
+	Cache.Add(resolved, hostname, ttl)
 
 	log.Debug("New DNS record: %s -> %s", resolved, hostname)
 }
 
+// GetAll returns a copy of the list of resolved domains.
+func GetAll() map[string]string {
+	d := Cache.Dump()
+	all := make(map[string]string, len(d.Records))
+	for _, r := range d.Records {
+		all[r.Resolved] = r.Host
+	}
+	return all
+}
+
+// Restore adds a list of resolved domains, usually received from a previous
+// instance of the daemon.
+func Restore(all map[string]string) {
+	for resolved, hostname := range all {
+		Cache.Add(resolved, hostname, 0)
+	}
+}
+
 // Host returns if a resolved domain is in the list.
 func Host(resolved string) (host string, found bool) {
-	lock.RLock()
-	defer lock.RUnlock()
-
-	host, found = responses[resolved]
-	return
+	return Cache.Host(resolved)
 }
 
 // HostOr checks if an IP has a domain name already resolved.
@@ -0,0 +1,235 @@
+// Package features probes the kernel features used by the daemon (eBPF
+// program types, BTF, nftables, audit, cgroup v2, queue bypass), so the bug
+// reports and the GUIs can show what the host supports.
+package features
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cilium/ebpf"
+	ebpffeatures "github.com/cilium/ebpf/features"
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/godbus/dbus/v5"
+	"github.com/google/nftables"
+)
+
+// Feature is the result of probing a feature of the kernel.
+type Feature struct {
+	Name      string `json:"name"`
+	Supported bool   `json:"supported"`
+	// why it's not supported, or details of the support.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report holds the features supported by the host.
+type Report struct {
+	Time          time.Time `json:"time"`
+	KernelVersion string    `json:"kernel_version"`
+	Arch          string    `json:"arch"`
+	Features      []Feature `json:"features"`
+}
+
+type probe struct {
+	name string
+	fn   func() (bool, string)
+}
+
+var (
+	procPath     = "/proc"
+	cgroupRoot   = "/sys/fs/cgroup"
+	audispSocket = "/var/run/audispd_events"
+
+	probes = []probe{
+		{"ebpf.kprobe", probeProgramType(ebpf.Kprobe)},
+		{"ebpf.tracepoint", probeProgramType(ebpf.TracePoint)},
+		{"ebpf.socket_filter", probeProgramType(ebpf.SocketFilter)},
+		{"ebpf.btf", probeBTF},
+		{"tracefs", probeTraceFS},
+		{"nftables", probeNftables},
+		{"iptables", probeIptables},
+		{"firewalld", probeFirewalld},
+		{"queue.bypass", probeQueueBypass},
+		{"audit", probeAudit},
+		{"cgroup.v2", probeCgroupV2},
+	}
+
+	last   *Report
+	lastMu sync.RWMutex
+)
+
+// Probe probes the features of the kernel, and keeps the report.
+func Probe() *Report {
+	report := &Report{
+		Time:          time.Now(),
+		KernelVersion: core.GetKernelVersion(),
+		Arch:          runtime.GOARCH,
+		Features:      make([]Feature, 0, len(probes)),
+	}
+	for _, p := range probes {
+		supported, detail := p.fn()
+		report.Features = append(report.Features, Feature{Name: p.name, Supported: supported, Detail: detail})
+		if !supported {
+			log.Debug("[features] %s not supported: %s", p.name, detail)
+		}
+	}
+	lastMu.Lock()
+	last = report
+	lastMu.Unlock()
+	return report
+}
+
+// Last returns the last report, probing the features if they haven't been
+// probed yet.
+func Last() *Report {
+	lastMu.RLock()
+	report := last
+	lastMu.RUnlock()
+	if report == nil {
+		return Probe()
+	}
+	return report
+}
+
+// Unsupported returns the names of the features not supported.
+func (r *Report) Unsupported() []string {
+	names := make([]string, 0)
+	for _, f := range r.Features {
+		if !f.Supported {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// String returns the report as a table.
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "kernel %s, %s\n", r.KernelVersion, r.Arch)
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "FEATURE\tSUPPORTED\tDETAIL\n")
+	for _, f := range r.Features {
+		fmt.Fprintf(w, "%s\t%v\t%s\n", f.Name, f.Supported, f.Detail)
+	}
+	w.Flush()
+	return b.String()
+}
+
+func probeProgramType(pt ebpf.ProgramType) func() (bool, string) {
+	return func() (bool, string) {
+		if err := ebpffeatures.HaveProgramType(pt); err != nil {
+			return false, err.Error()
+		}
+		return true, ""
+	}
+}
+
+func probeBTF() (bool, string) {
+	if core.HasKernelBTF() {
+		return true, "/sys/kernel/btf/vmlinux"
+	}
+	return false, "/sys/kernel/btf/vmlinux not found, the CO-RE modules need Ebpf.BTFPath"
+}
+
+func probeTraceFS() (bool, string) {
+	if core.IsTraceFSMounted() {
+		return true, ""
+	}
+	return false, "tracefs not mounted"
+}
+
+func probeNftables() (bool, string) {
+	conn, err := nftables.New()
+	if err != nil {
+		return false, err.Error()
+	}
+	tables, err := conn.ListTables()
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, fmt.Sprintf("%d tables", len(tables))
+}
+
+func probeIptables() (bool, string) {
+	path, err := exec.LookPath("iptables")
+	if err != nil {
+		return false, "iptables not found"
+	}
+	return true, path
+}
+
+// probeFirewalld checks if firewalld is running, to use it as firewall.
+func probeFirewalld() (bool, string) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false, "system bus not available"
+	}
+	version, err := conn.Object("org.fedoraproject.FirewallD1", "/org/fedoraproject/FirewallD1").GetProperty("org.fedoraproject.FirewallD1.version")
+	if err != nil {
+		return false, "firewalld not running"
+	}
+	return true, fmt.Sprint(version.Value())
+}
+
+// probeQueueBypass checks if the packets can be accepted while the daemon is
+// not reading the queue (nft queue bypass, kernel >= 3.14).
+func probeQueueBypass() (bool, string) {
+	if !kernelAtLeast(core.GetKernelVersion(), 3, 14) {
+		return false, "kernel older than 3.14"
+	}
+	if !core.Exists(procPath + "/net/netfilter/nfnetlink_queue") {
+		return true, "nfnetlink_queue not loaded yet"
+	}
+	return true, ""
+}
+
+func probeAudit() (bool, string) {
+	if !core.Exists(procPath + "/self/loginuid") {
+		return false, "kernel built without audit support"
+	}
+	if _, err := exec.LookPath("auditctl"); err != nil {
+		return false, "auditctl not found, auditd not installed"
+	}
+	if !core.Exists(audispSocket) {
+		return false, audispSocket + " not found, the af_unix plugin of auditd is not enabled"
+	}
+	return true, ""
+}
+
+func probeCgroupV2() (bool, string) {
+	if _, err := os.Stat(cgroupRoot + "/cgroup.controllers"); err != nil {
+		return false, "cgroup v2 not mounted on " + cgroupRoot
+	}
+	return true, cgroupRoot
+}
+
+// kernelAtLeast returns true if a kernel release (5.10.0-8-amd64) is equal or
+// newer than the given version.
+func kernelAtLeast(release string, major, minor int) bool {
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	maj, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+	// 3.14-rc1
+	digits := strings.IndexFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' })
+	if digits != -1 {
+		parts[1] = parts[1][:digits]
+	}
+	min, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	return maj > major || (maj == major && min >= minor)
+}
@@ -0,0 +1,64 @@
+package features
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	origProc, origCgroup := procPath, cgroupRoot
+	procPath, cgroupRoot = t.TempDir(), t.TempDir()
+	defer func() {
+		procPath, cgroupRoot = origProc, origCgroup
+	}()
+	os.WriteFile(filepath.Join(cgroupRoot, "cgroup.controllers"), []byte{}, 0644)
+
+	report := Probe()
+	if len(report.Features) != len(probes) || report.KernelVersion == "" {
+		t.Fatalf("invalid report: %+v", report)
+	}
+	if Last() != report {
+		t.Error("the last report should be kept")
+	}
+	for _, f := range report.Features {
+		switch f.Name {
+		case "cgroup.v2":
+			if !f.Supported {
+				t.Error("cgroup v2 not detected:", f.Detail)
+			}
+		case "audit":
+			if f.Supported {
+				t.Error("audit detected without kernel support")
+			}
+		}
+	}
+	for _, name := range report.Unsupported() {
+		if name == "cgroup.v2" {
+			t.Error("supported feature reported as unsupported")
+		}
+	}
+	if !strings.Contains(report.String(), "cgroup.v2") {
+		t.Error("feature not printed:", report.String())
+	}
+}
+
+func TestKernelAtLeast(t *testing.T) {
+	tests := []struct {
+		release string
+		want    bool
+	}{
+		{"5.10.0-8-amd64", true},
+		{"3.14-rc1", true},
+		{"3.14.0", true},
+		{"3.13.11", false},
+		{"2.6.32-754.el6.x86_64", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := kernelAtLeast(tt.release, 3, 14); got != tt.want {
+			t.Errorf("kernelAtLeast(%s) = %v, want %v", tt.release, got, tt.want)
+		}
+	}
+}
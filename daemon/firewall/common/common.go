@@ -17,8 +17,20 @@ var (
 	BackupChains   = true
 	ReloadConf     = true
 
+	// KeepRules is set when the interception rules have been handed over by a
+	// previous instance of the daemon, in order not to delete them on start.
+	KeepRules = false
+
 	DefaultCheckInterval = 10 * time.Second
 	RulesCheckerDisabled = "0s"
+
+	// CheckSettleTime is the time to wait after a firewall change before
+	// checking the rules, to check them once after a burst of changes.
+	CheckSettleTime = time.Second
+
+	// OnRulesMissing is called when the interception rules have been deleted,
+	// before adding them again.
+	OnRulesMissing = func() {}
 )
 
 type (
@@ -31,11 +43,20 @@ type (
 		RulesChecker       *time.Ticker
 		ErrChan            chan string
 		stopChecker        chan struct{}
+		checkNow           chan struct{}
 		RulesCheckInterval time.Duration
 		QueueNum           uint16
 		Running            bool
 		Intercepting       bool
 		FwEnabled          bool
+
+		// number of queues from QueueNum where the connections are
+		// balanced to (fanout). 0 or 1 to use only QueueNum.
+		QueueCount uint16
+
+		// queue where the DNS responses are sent to. 0 to use QueueNum.
+		DNSQueueNum    uint16
+		DNSQueueBypass bool
 		sync.RWMutex
 	}
 )
@@ -90,6 +111,45 @@ func (c *Common) SetQueueNum(qNum uint16) {
 	c.QueueNum = qNum
 }
 
+// SetQueueCount sets the number of queues, from the queue number, where the
+// connections are balanced to.
+func (c *Common) SetQueueCount(count uint16) {
+	c.Lock()
+	defer c.Unlock()
+	c.QueueCount = count
+}
+
+// GetQueueCount returns the number of queues of the connections, at least 1.
+func (c *Common) GetQueueCount() uint16 {
+	c.RLock()
+	defer c.RUnlock()
+	if c.QueueCount == 0 {
+		return 1
+	}
+	return c.QueueCount
+}
+
+// SetDNSQueue sets the queue number where the DNS responses will be sent to,
+// and if the packets must be accepted when no one is listening on it.
+// If qNum is 0, the DNS responses are sent to the queue of the connections.
+func (c *Common) SetDNSQueue(qNum uint16, bypass bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.DNSQueueNum = qNum
+	c.DNSQueueBypass = bypass
+}
+
+// GetDNSQueue returns the queue number and the bypass policy of the DNS
+// responses, falling back to the queue of the connections and the given policy.
+func (c *Common) GetDNSQueue(bypass bool) (uint16, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.DNSQueueNum == 0 {
+		return c.QueueNum, bypass
+	}
+	return c.DNSQueueNum, c.DNSQueueBypass
+}
+
 // IsRunning returns if the firewall is running or not.
 func (c *Common) IsRunning() bool {
 	c.RLock()
@@ -134,27 +194,56 @@ func (c *Common) NewRulesChecker(areRulesLoaded callbackBool, reloadRules callba
 		}
 	}
 	c.stopChecker = make(chan struct{}, 1)
+	c.checkNow = make(chan struct{}, 1)
 	log.Info("Starting new fw checker every %s ...", c.RulesCheckInterval)
 	c.RulesChecker = time.NewTicker(c.RulesCheckInterval)
 
-	go startCheckingRules(c.stopChecker, c.RulesChecker, areRulesLoaded, reloadRules)
+	go startCheckingRules(c.stopChecker, c.checkNow, c.RulesChecker, areRulesLoaded, reloadRules)
+}
+
+// CheckRulesNow asks the rules checker to check the rules without waiting
+// for the next interval, i.e.: when the firewall has been modified.
+func (c *Common) CheckRulesNow() {
+	c.RLock()
+	defer c.RUnlock()
+	if c.checkNow == nil {
+		return
+	}
+	select {
+	case c.checkNow <- struct{}{}:
+	default:
+		// there's already a check pending
+	}
 }
 
 // StartCheckingRules monitors if our rules are loaded.
 // If the rules to intercept traffic are not loaded, we'll try to insert them again.
-func startCheckingRules(exitChan <-chan struct{}, rulesChecker *time.Ticker, areRulesLoaded callbackBool, reloadRules callback) {
+func startCheckingRules(exitChan <-chan struct{}, checkNow chan struct{}, rulesChecker *time.Ticker, areRulesLoaded callbackBool, reloadRules callback) {
 	for {
 		select {
 		case <-exitChan:
 			goto Exit
+		case <-checkNow:
+			// wait for the rest of the changes, and discard the checks
+			// requested meanwhile.
+			select {
+			case <-exitChan:
+				goto Exit
+			case <-time.After(CheckSettleTime):
+			}
+			select {
+			case <-checkNow:
+			default:
+			}
 		case _, active := <-rulesChecker.C:
 			if !active {
 				goto Exit
 			}
+		}
 
-			if areRulesLoaded() == false {
-				reloadRules()
-			}
+		if areRulesLoaded() == false {
+			OnRulesMissing()
+			reloadRules()
 		}
 	}
 
@@ -178,6 +267,7 @@ func (c *Common) StopCheckingRules() {
 
 		c.RulesChecker.Stop()
 		c.RulesChecker = nil
+		c.checkNow = nil
 	}
 }
 
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckRulesNow(t *testing.T) {
+	CheckSettleTime = 50 * time.Millisecond
+	defer func() { CheckSettleTime = time.Second }()
+
+	var checks, missing atomic.Int32
+	OnRulesMissing = func() { missing.Add(1) }
+	defer func() { OnRulesMissing = func() {} }()
+
+	reloaded := make(chan struct{}, 10)
+	c := &Common{}
+	c.SetRulesCheckerInterval("1h")
+	c.NewRulesChecker(func() bool {
+		return checks.Add(1) > 1
+	}, func() {
+		reloaded <- struct{}{}
+	})
+	defer c.StopCheckingRules()
+
+	// a burst of changes is checked once
+	for i := 0; i < 5; i++ {
+		c.CheckRulesNow()
+	}
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		t.Fatal("the rules were not reloaded after a change")
+	}
+	if missing.Load() != 1 {
+		t.Errorf("OnRulesMissing should be called once, called %d times", missing.Load())
+	}
+
+	c.CheckRulesNow()
+	time.Sleep(200 * time.Millisecond)
+	if n := checks.Load(); n != 2 {
+		t.Errorf("expected 2 checks, got %d", n)
+	}
+	select {
+	case <-reloaded:
+		t.Error("the rules should not be reloaded if they're loaded")
+	default:
+	}
+
+	c.StopCheckingRules()
+	// no-op without the checker
+	c.CheckRulesNow()
+}
+
+func TestQueueCount(t *testing.T) {
+	c := &Common{}
+	if c.GetQueueCount() != 1 {
+		t.Errorf("at least one queue should be used: %d", c.GetQueueCount())
+	}
+	c.SetQueueCount(4)
+	if c.GetQueueCount() != 4 {
+		t.Errorf("invalid queue count: %d", c.GetQueueCount())
+	}
+}
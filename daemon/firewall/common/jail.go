@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+)
+
+// Jail restricts the network of a binary: its processes are moved to a
+// cgroup, and the new connections of the cgroup are filtered in kernel by a
+// dedicated chain, which only allows the loopback interface and the allowed
+// destinations.
+type Jail struct {
+	// Path of the binary jailed.
+	Path string
+
+	// Cgroup is the path of the cgroup of the jail, relative to the root of
+	// the cgroup2 hierarchy, and CgroupID its inode number.
+	Cgroup   string
+	CgroupID uint64
+
+	// Allowed destinations, besides the loopback interface.
+	Allowed []*net.IPNet
+}
+
+// JailID returns the identifier of the jail of a binary, used to name its
+// cgroup and its chain.
+func JailID(path string) string {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return fmt.Sprintf("jail-%08x", h.Sum32())
+}
+
+// ID returns the identifier of the jail.
+func (j *Jail) ID() string {
+	return JailID(j.Path)
+}
+
+// Level returns the level of the cgroup of the jail in the hierarchy.
+func (j *Jail) Level() uint32 {
+	return uint32(strings.Count(strings.Trim(j.Cgroup, "/"), "/") + 1)
+}
+
+// Allows returns true if the jail lets the connections to an IP through.
+func (j *Jail) Allows(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, dst := range j.Allowed {
+		if dst.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Equal returns true if both jails apply the same restrictions.
+func (j *Jail) Equal(other *Jail) bool {
+	if other == nil || j.Path != other.Path || j.Cgroup != other.Cgroup || j.CgroupID != other.CgroupID || len(j.Allowed) != len(other.Allowed) {
+		return false
+	}
+	for i := range j.Allowed {
+		if j.Allowed[i].String() != other.Allowed[i].String() {
+			return false
+		}
+	}
+	return true
+}
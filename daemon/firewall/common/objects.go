@@ -0,0 +1,15 @@
+package common
+
+// ObjectValues holds the current values of a named object of the firewall
+// (counter, quota, limit).
+type ObjectValues struct {
+	Name   string `json:"name"`
+	Table  string `json:"table"`
+	Family string `json:"family"`
+	Type   string `json:"type"`
+	// packets and bytes of the counters, bytes of the quotas.
+	Packets  uint64 `json:"packets,omitempty"`
+	Bytes    uint64 `json:"bytes,omitempty"`
+	Consumed uint64 `json:"consumed,omitempty"`
+	Over     bool   `json:"over,omitempty"`
+}
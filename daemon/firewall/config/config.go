@@ -10,6 +10,7 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"sync"
@@ -99,13 +100,71 @@ func (fc *FwChain) IsInvalid() bool {
 	return fc.Name == "" || fc.Family == "" || fc.Table == ""
 }
 
+// FwMap holds the definition of a named map or verdict map, to be used by the
+// rules of the chains of the same table:
+//{
+//	"Name": "input_ports",
+//	"Table": "opensnitch",
+//	"Family": "inet",
+//	"KeyType": "inet_service",
+//	"DataType": "verdict",
+//	"Elements": [
+//		{ "Key": "22", "Value": "accept" },
+//		{ "Key": "8080", "Value": "jump filter_web" }
+//	]
+//}
+type FwMap struct {
+	Name        string
+	Table       string
+	Family      string
+	Description string
+	KeyType     string        // inet_service, ipv4_addr, ipv6_addr, inet_proto, ifname, mark
+	DataType    string        // verdict, or any of the key types
+	Elements    []*ExprValues // Key -> Value
+}
+
+// IsInvalid checks if the map has been correctly configured.
+func (fm *FwMap) IsInvalid() bool {
+	return fm.Name == "" || fm.Family == "" || fm.Table == "" || fm.KeyType == "" || fm.DataType == ""
+}
+
+// FwObject holds the definition of a named object (counter, quota or limit),
+// to be referenced by name from the rules of the chains of the same table, so
+// the rules share its state:
+//{
+//	"Name": "http_quota",
+//	"Table": "opensnitch",
+//	"Family": "inet",
+//	"Type": "quota",
+//	"Values": [
+//		{ "Key": "over", "Value": "" },
+//		{ "Key": "mbytes", "Value": "500" }
+//	]
+//}
+// The Values are the options of the statements of the same type.
+type FwObject struct {
+	Name        string
+	Table       string
+	Family      string
+	Description string
+	Type        string // counter, quota, limit
+	Values      []*ExprValues
+}
+
+// IsInvalid checks if the object has been correctly configured.
+func (fo *FwObject) IsInvalid() bool {
+	return fo.Name == "" || fo.Family == "" || fo.Table == "" || fo.Type == ""
+}
+
 type rulesList struct {
 	Rule *FwRule
 }
 
 type chainsList struct {
 	Rule   *FwRule // TODO: deprecated, remove
-	Chains []*FwChain
+	Chains  []*FwChain
+	Maps    []*FwMap
+	Objects []*FwObject
 }
 
 // SystemConfig holds the list of rules to be added to the system
@@ -196,20 +255,45 @@ func (c *Config) LoadDiskConfiguration(reload bool) error {
 	return nil
 }
 
+// ValidateConfiguration checks that the firewall configuration on disk can be
+// loaded, without applying it.
+func (c *Config) ValidateConfiguration() error {
+	c.Lock()
+	file := c.file
+	c.Unlock()
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var sysConfig SystemConfig
+	if err := json.Unmarshal(raw, &sysConfig); err != nil {
+		return fmt.Errorf("parsing %s: %s", file, err)
+	}
+	return nil
+}
+
 // loadConfigutation reads the system firewall rules from disk.
 // Then the rules are added based on the configuration defined.
 func (c *Config) loadConfiguration(rawConfig []byte) error {
 	c.SysConfig.Lock()
 	defer c.SysConfig.Unlock()
 
-	// delete old system rules, that may be different from the new ones
-	c.preloadCallback()
-
-	if err := json.Unmarshal(rawConfig, &c.SysConfig); err != nil {
+	// parse the new configuration before deleting the old rules, to keep
+	// them if it's not valid.
+	var sysConfig SystemConfig
+	if err := json.Unmarshal(rawConfig, &sysConfig); err != nil {
 		// we only log the parser error, giving the user a chance to write a valid config
 		log.Error("Error parsing firewall configuration %s: %s", c.file, err)
 		return err
 	}
+
+	// delete old system rules, that may be different from the new ones
+	c.preloadCallback()
+
+	c.SysConfig.SystemRules = sysConfig.SystemRules
+	c.SysConfig.Version = sysConfig.Version
+	c.SysConfig.Enabled = sysConfig.Enabled
 	log.Info("fw configuration loaded")
 
 	return nil
@@ -24,3 +24,22 @@ func TestNftLoadFromDisk(t *testing.T) {
 		t.Errorf("Error loading config from disk: %s", err)
 	}
 }
+
+func TestLoadObjects(t *testing.T) {
+	cfg := &Config{}
+	cfg.NewSystemFwConfig("", preloadConfCallback, reloadConfCallback)
+	cfg.SetConfigFile("../nftables/testdata/test-sysfw-objects.json")
+	if err := cfg.LoadDiskConfiguration(false); err != nil {
+		t.Fatalf("Error loading config from disk: %s", err)
+	}
+	objs := cfg.SysConfig.SystemRules[0].Objects
+	if len(objs) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(objs))
+	}
+	if objs[1].Name != "web_quota" || objs[1].Type != "quota" || len(objs[1].Values) != 2 || objs[1].IsInvalid() {
+		t.Errorf("unexpected object: %+v", objs[1])
+	}
+	if (&FwObject{Name: "x", Table: "opensnitch", Family: "inet"}).IsInvalid() == false {
+		t.Error("objects without type should be invalid")
+	}
+}
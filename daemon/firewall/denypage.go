@@ -0,0 +1,46 @@
+package firewall
+
+import (
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// DenyPageMark is the mark of the denied connections redirected to the deny
+// page.
+const DenyPageMark = 0x101d
+
+// port of the deny page, kept to add its rules again when the firewall is
+// reloaded. 0 if it's disabled.
+var (
+	denyPagePort uint16
+	denyPageLock sync.RWMutex
+)
+
+// SetDenyPage redirects the connections marked with DenyPageMark to the given
+// local port. 0 disables it.
+func SetDenyPage(port uint16) error {
+	denyPageLock.Lock()
+	defer denyPageLock.Unlock()
+	denyPagePort = port
+	if fw == nil {
+		return nil
+	}
+	if port == 0 {
+		return fw.DisableDenyPage()
+	}
+	return fw.EnableDenyPage(port, DenyPageMark)
+}
+
+// restoreDenyPage adds the rules of the deny page to a new firewall, if it's
+// enabled.
+func restoreDenyPage() {
+	denyPageLock.RLock()
+	defer denyPageLock.RUnlock()
+	if denyPagePort == 0 {
+		return
+	}
+	if err := fw.EnableDenyPage(denyPagePort, DenyPageMark); err != nil {
+		log.Error("Error enabling the deny page: %s", err)
+	}
+}
@@ -0,0 +1,132 @@
+package firewalld
+
+import (
+	"strings"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/iptables"
+)
+
+const (
+	ipv4 = "ipv4"
+	ipv6 = "ipv6"
+
+	// errors returned by firewalld when a passthrough already exists, or
+	// when it doesn't exist.
+	errAlreadyEnabled = "ALREADY_ENABLED"
+	errNotEnabled     = "NOT_ENABLED"
+
+	defaultTable = "filter"
+)
+
+// exec runs the iptables commands through the direct interface of firewalld.
+//
+// The new rules and chains are added as tracked passthroughs, which are
+// restored by firewalld after reloading. Deleting a rule or a chain removes the
+// tracked passthrough that added it, and flushing a chain removes the
+// passthroughs of its rules, otherwise they'd be restored on the next reload.
+// The rest of the commands (list, policy) are not tracked.
+func (fwd *Firewalld) exec(bin string, args []string) (string, error) {
+	ipv := ipv4
+	if bin == "ip6tables" {
+		ipv = ipv6
+	}
+	if len(args) < 2 {
+		return fwd.passthrough(ipv, args)
+	}
+
+	switch iptables.Action(args[0]) {
+	case iptables.ADD, iptables.INSERT, iptables.NEWCHAIN:
+		return "", fwd.addPassthrough(ipv, args)
+	case iptables.DELETE:
+		removed, err := fwd.removePassthroughs(ipv, func(pt []string) bool {
+			return isRule(pt) && equalArgs(pt[1:], args[1:])
+		})
+		if err != nil || removed > 0 {
+			return "", err
+		}
+	case iptables.DELCHAIN:
+		removed, err := fwd.removePassthroughs(ipv, func(pt []string) bool {
+			return pt[0] == string(iptables.NEWCHAIN) && equalArgs(pt[1:], args[1:])
+		})
+		if err != nil || removed > 0 {
+			return "", err
+		}
+	case iptables.FLUSH:
+		chain, table := args[1], getTable(args)
+		if _, err := fwd.removePassthroughs(ipv, func(pt []string) bool {
+			return isRule(pt) && pt[1] == chain && getTable(pt) == table
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	// not added by us (i.e.: by a previous iptables firewall), or not
+	// tracked.
+	return fwd.passthrough(ipv, args)
+}
+
+// addPassthrough adds a rule or a chain tracked by firewalld.
+// Adding it again is not an error, it may have been added by a previous
+// instance of the daemon.
+func (fwd *Firewalld) addPassthrough(ipv string, args []string) error {
+	err := fwd.obj.Call(dbusDirect+".addPassthrough", 0, ipv, args).Err
+	if err != nil && strings.HasPrefix(err.Error(), errAlreadyEnabled) {
+		return nil
+	}
+	return err
+}
+
+// removePassthroughs removes the tracked passthroughs that match, and returns
+// how many have been removed.
+func (fwd *Firewalld) removePassthroughs(ipv string, match func([]string) bool) (int, error) {
+	var list [][]string
+	if err := fwd.obj.Call(dbusDirect+".getPassthroughs", 0, ipv).Store(&list); err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, pt := range list {
+		if len(pt) < 2 || !match(pt) {
+			continue
+		}
+		err := fwd.obj.Call(dbusDirect+".removePassthrough", 0, ipv, pt).Err
+		if err != nil && !strings.HasPrefix(err.Error(), errNotEnabled) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// passthrough runs a command not tracked by firewalld, and returns its output.
+func (fwd *Firewalld) passthrough(ipv string, args []string) (string, error) {
+	var out string
+	err := fwd.obj.Call(dbusDirect+".passthrough", 0, ipv, args).Store(&out)
+	return strings.TrimSpace(out), err
+}
+
+// isRule returns true if the passthrough adds a rule.
+func isRule(args []string) bool {
+	return args[0] == string(iptables.ADD) || args[0] == string(iptables.INSERT)
+}
+
+// getTable returns the table of the arguments of a command.
+func getTable(args []string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == "-t" {
+			return args[i+1]
+		}
+	}
+	return defaultTable
+}
+
+func equalArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package firewalld
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/iptables"
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeDirect emulates the passthroughs of the direct interface of firewalld.
+type fakeDirect struct {
+	dbus.BusObject
+	tracked map[string][][]string
+	// untracked commands run.
+	run []string
+}
+
+func newFakeDirect() *fakeDirect {
+	return &fakeDirect{tracked: make(map[string][][]string)}
+}
+
+func (f *fakeDirect) index(ipv string, args []string) int {
+	for i, pt := range f.tracked[ipv] {
+		if equalArgs(pt, args) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (f *fakeDirect) Call(method string, flags dbus.Flags, args ...interface{}) *dbus.Call {
+	ipv := args[0].(string)
+	call := &dbus.Call{}
+	switch strings.TrimPrefix(method, dbusDirect+".") {
+	case "addPassthrough":
+		pt := args[1].([]string)
+		if f.index(ipv, pt) != -1 {
+			call.Err = dbus.NewError(dbusDest+".Exception", []interface{}{errAlreadyEnabled + ": " + strings.Join(pt, " ")})
+			break
+		}
+		f.tracked[ipv] = append(f.tracked[ipv], pt)
+	case "removePassthrough":
+		pt := args[1].([]string)
+		i := f.index(ipv, pt)
+		if i == -1 {
+			call.Err = dbus.NewError(dbusDest+".Exception", []interface{}{errNotEnabled})
+			break
+		}
+		f.tracked[ipv] = append(f.tracked[ipv][:i], f.tracked[ipv][i+1:]...)
+	case "getPassthroughs":
+		call.Body = []interface{}{f.tracked[ipv]}
+	case "passthrough":
+		f.run = append(f.run, ipv+" "+strings.Join(args[1].([]string), " "))
+		call.Body = []interface{}{"output\n"}
+	}
+	return call
+}
+
+func newTestFirewalld() (*Firewalld, *fakeDirect) {
+	fake := newFakeDirect()
+	fwd := &Firewalld{obj: fake}
+	fwd.Iptables = iptables.New(fwd.exec)
+	return fwd, fake
+}
+
+func TestExecTracked(t *testing.T) {
+	fwd, fake := newTestFirewalld()
+
+	rule := []string{"OUTPUT", "-t", "mangle", "-j", "NFQUEUE", "--queue-num", "0"}
+	fwd.exec("iptables", []string{"-N", "opensnitch-panic", "-t", "mangle"})
+	fwd.exec("iptables", []string{"-A", "opensnitch-panic", "-t", "mangle", "-j", "DROP"})
+	fwd.exec("iptables", append([]string{"-I"}, rule...))
+	fwd.exec("ip6tables", append([]string{"-I"}, rule...))
+	// added by a previous instance
+	if _, err := fwd.exec("iptables", append([]string{"-I"}, rule...)); err != nil {
+		t.Error("adding a rule twice should not fail:", err)
+	}
+	if len(fake.tracked[ipv4]) != 3 || len(fake.tracked[ipv6]) != 1 {
+		t.Fatalf("rules not tracked: %v", fake.tracked)
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		if _, err := fwd.exec("iptables", append([]string{"-D"}, rule...)); err != nil {
+			t.Error("delete error:", err)
+		}
+		if fake.index(ipv4, append([]string{"-I"}, rule...)) != -1 || len(fake.tracked[ipv6]) != 1 {
+			t.Errorf("rule not deleted: %v", fake.tracked)
+		}
+		if len(fake.run) != 0 {
+			t.Errorf("tracked rules should not be deleted with a passthrough: %v", fake.run)
+		}
+	})
+
+	t.Run("flush and delete chain", func(t *testing.T) {
+		fwd.exec("iptables", []string{"-F", "opensnitch-panic", "-t", "mangle"})
+		fwd.exec("iptables", []string{"-X", "opensnitch-panic", "-t", "mangle"})
+		if len(fake.tracked[ipv4]) != 0 {
+			t.Errorf("chain not deleted: %v", fake.tracked)
+		}
+		// the flush is also applied to the rules not tracked.
+		if len(fake.run) != 1 || fake.run[0] != "ipv4 -F opensnitch-panic -t mangle" {
+			t.Errorf("unexpected passthroughs: %v", fake.run)
+		}
+	})
+}
+
+func TestExecUntracked(t *testing.T) {
+	fwd, fake := newTestFirewalld()
+
+	out, err := fwd.exec("iptables", []string{"-n", "-L", "OUTPUT", "-t", "mangle"})
+	if err != nil || out != "output" {
+		t.Errorf("unexpected list output: %s, %v", out, err)
+	}
+	// not added by us
+	fwd.exec("ip6tables", []string{"-D", "INPUT", "-p", "udp", "--sport", "53", "-j", "NFQUEUE"})
+	fwd.exec("iptables", []string{"-P", "OUTPUT", "ACCEPT"})
+
+	expected := []string{
+		"ipv4 -n -L OUTPUT -t mangle",
+		"ipv6 -D INPUT -p udp --sport 53 -j NFQUEUE",
+		"ipv4 -P OUTPUT ACCEPT",
+	}
+	if strings.Join(fake.run, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected passthroughs:\n%s", strings.Join(fake.run, "\n"))
+	}
+}
+
+func TestIsReload(t *testing.T) {
+	tests := []struct {
+		sig      *dbus.Signal
+		expected bool
+	}{
+		{&dbus.Signal{Name: dbusDest + ".Reloaded"}, true},
+		{&dbus.Signal{Name: "org.freedesktop.DBus.NameOwnerChanged", Body: []interface{}{dbusDest, "", ":1.20"}}, true},
+		{&dbus.Signal{Name: "org.freedesktop.DBus.NameOwnerChanged", Body: []interface{}{dbusDest, ":1.20", ""}}, false},
+		{&dbus.Signal{Name: dbusDest + ".zone.ZoneChanged"}, false},
+	}
+	for _, test := range tests {
+		if isReload(test.sig) != test.expected {
+			t.Errorf("isReload(%s, %v) should be %v", test.sig.Name, test.sig.Body, test.expected)
+		}
+	}
+}
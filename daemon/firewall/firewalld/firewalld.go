@@ -0,0 +1,180 @@
+package firewalld
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/iptables"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// Name is the name that identifies this firewall
+	Name = "firewalld"
+
+	logTag = "firewalld:"
+
+	dbusDest   = "org.fedoraproject.FirewallD1"
+	dbusPath   = "/org/fedoraproject/FirewallD1"
+	dbusDirect = dbusDest + ".direct"
+)
+
+// Firewalld adds the rules through the D-Bus API of firewalld, instead of
+// modifying iptables directly.
+// The rules are added as passthroughs of the direct interface, which firewalld
+// tracks and restores every time it's reloaded, so the interception and the
+// system rules are not deleted by firewall-cmd --reload.
+//
+// The rules and chains are the same ones of the iptables firewall.
+type Firewalld struct {
+	*iptables.Iptables
+
+	conn *dbus.Conn
+	obj  dbus.BusObject
+
+	signals     chan *dbus.Signal
+	stopWatcher chan struct{}
+	watchLock   sync.Mutex
+}
+
+// Fw initializes a new Firewalld object.
+func Fw() (*Firewalld, error) {
+	// shared connection, it must not be closed.
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("%s unable to connect to the system bus: %s", logTag, err)
+	}
+	obj := conn.Object(dbusDest, dbusPath)
+	if err := IsAvailable(obj); err != nil {
+		return nil, err
+	}
+
+	fwd := &Firewalld{
+		conn: conn,
+		obj:  obj,
+	}
+	fwd.Iptables = iptables.New(fwd.exec)
+	return fwd, nil
+}
+
+// IsAvailable checks if firewalld is running.
+func IsAvailable(obj dbus.BusObject) error {
+	state, err := obj.GetProperty(dbusDest + ".state")
+	if err != nil {
+		return fmt.Errorf("%s not available: %s", logTag, err)
+	}
+	if s, _ := state.Value().(string); s != "RUNNING" {
+		return fmt.Errorf("%s not running, state: %v", logTag, state.Value())
+	}
+	return nil
+}
+
+// Name returns the firewall name
+func (fwd *Firewalld) Name() string {
+	return Name
+}
+
+// Init inserts the firewall rules, and starts listening for the reloads of
+// firewalld.
+func (fwd *Firewalld) Init(qNum uint16, configPath, monitorInterval string, bypassQueue bool) {
+	if fwd.IsRunning() {
+		return
+	}
+	fwd.Iptables.Init(qNum, configPath, monitorInterval, bypassQueue)
+	fwd.watchReloads()
+}
+
+// Stop deletes the firewall rules, and stops listening for the reloads of
+// firewalld.
+func (fwd *Firewalld) Stop() {
+	fwd.stopWatchingReloads()
+	fwd.Iptables.Stop()
+}
+
+// watchReloads checks the rules as soon as firewalld is reloaded or
+// restarted, without waiting for the next interval of the rules checker.
+// On reload our rules are restored by firewalld, but on restart the runtime
+// configuration is lost, and they must be added again.
+func (fwd *Firewalld) watchReloads() {
+	fwd.watchLock.Lock()
+	defer fwd.watchLock.Unlock()
+	if fwd.signals != nil {
+		return
+	}
+
+	if err := fwd.conn.AddMatchSignal(fwd.reloadedMatch()...); err != nil {
+		log.Warning("%s unable to listen for reloads: %s", logTag, err)
+		return
+	}
+	if err := fwd.conn.AddMatchSignal(fwd.restartedMatch()...); err != nil {
+		log.Warning("%s unable to listen for restarts: %s", logTag, err)
+	}
+	fwd.signals = make(chan *dbus.Signal, 10)
+	fwd.stopWatcher = make(chan struct{})
+	fwd.conn.Signal(fwd.signals)
+
+	go func(signals <-chan *dbus.Signal, stop <-chan struct{}) {
+		for {
+			select {
+			case <-stop:
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				if !isReload(sig) {
+					continue
+				}
+				log.Info("%s %s, checking the rules", logTag, sig.Name)
+				fwd.CheckRulesNow()
+			}
+		}
+	}(fwd.signals, fwd.stopWatcher)
+}
+
+func (fwd *Firewalld) stopWatchingReloads() {
+	fwd.watchLock.Lock()
+	defer fwd.watchLock.Unlock()
+	if fwd.signals == nil {
+		return
+	}
+	fwd.conn.RemoveSignal(fwd.signals)
+	fwd.conn.RemoveMatchSignal(fwd.reloadedMatch()...)
+	fwd.conn.RemoveMatchSignal(fwd.restartedMatch()...)
+	close(fwd.stopWatcher)
+	fwd.signals = nil
+	fwd.stopWatcher = nil
+}
+
+func (fwd *Firewalld) reloadedMatch() []dbus.MatchOption {
+	return []dbus.MatchOption{
+		dbus.WithMatchInterface(dbusDest),
+		dbus.WithMatchMember("Reloaded"),
+	}
+}
+
+func (fwd *Firewalld) restartedMatch() []dbus.MatchOption {
+	return []dbus.MatchOption{
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, dbusDest),
+	}
+}
+
+// isReload returns true if the signal is a reload of firewalld, or a new
+// instance of it (NameOwnerChanged with a new owner).
+func isReload(sig *dbus.Signal) bool {
+	switch sig.Name {
+	case dbusDest + ".Reloaded":
+		return true
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		if len(sig.Body) < 3 {
+			return false
+		}
+		name, _ := sig.Body[0].(string)
+		newOwner, _ := sig.Body[2].(string)
+		return name == dbusDest && newOwner != ""
+	}
+	return false
+}
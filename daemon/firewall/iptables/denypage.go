@@ -0,0 +1,80 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+)
+
+// The denied HTTP connections, marked by the daemon when they're verdicted,
+// are redirected to the local responder of the deny page:
+//
+// -t nat -I OUTPUT -p tcp -m mark --mark 0x101d -j REDIRECT --to-ports 8077
+
+func denyPageRule(port uint16, mark uint32) []string {
+	return []string{
+		"OUTPUT",
+		"-t", "nat",
+		"-p", "tcp",
+		"-m", "mark",
+		"--mark", fmt.Sprintf("0x%x", mark),
+		"-j", "REDIRECT",
+		"--to-ports", strconv.Itoa(int(port)),
+	}
+}
+
+// EnableDenyPage redirects the connections accepted with the given mark to
+// the local port of the deny page.
+func (ipt *Iptables) EnableDenyPage(port uint16, mark uint32) error {
+	ipt.denyPageLock.Lock()
+	defer ipt.denyPageLock.Unlock()
+	ipt.delDenyPageRules()
+	ipt.denyPagePort = port
+	ipt.denyPageMark = mark
+	return ipt.addDenyPageRules()
+}
+
+// DisableDenyPage deletes the rules of the deny page.
+func (ipt *Iptables) DisableDenyPage() error {
+	ipt.denyPageLock.Lock()
+	defer ipt.denyPageLock.Unlock()
+	ipt.delDenyPageRules()
+	ipt.denyPagePort = 0
+	return nil
+}
+
+// restoreDenyPageRules adds again the rules of the deny page, if it's
+// enabled, after adding the interception rules.
+func (ipt *Iptables) restoreDenyPageRules() error {
+	ipt.denyPageLock.Lock()
+	defer ipt.denyPageLock.Unlock()
+	if ipt.denyPagePort == 0 {
+		return nil
+	}
+	ipt.delDenyPageRules()
+	return ipt.addDenyPageRules()
+}
+
+func (ipt *Iptables) addDenyPageRules() error {
+	if err4, err6 := ipt.RunRule(INSERT, common.EnableRule, true, denyPageRule(ipt.denyPagePort, ipt.denyPageMark)); err4 != nil || err6 != nil {
+		return fmt.Errorf("iptables: error adding the deny page rules: %v, %v", err4, err6)
+	}
+	return nil
+}
+
+// delDenyPageRules deletes the rules of the deny page.
+func (ipt *Iptables) delDenyPageRules() {
+	if ipt.denyPagePort == 0 {
+		return
+	}
+	ipt.RunRule(DELETE, !common.EnableRule, false, denyPageRule(ipt.denyPagePort, ipt.denyPageMark))
+}
+
+// cleanDenyPageRules deletes the rules of the deny page, keeping its port to
+// add them again.
+func (ipt *Iptables) cleanDenyPageRules() {
+	ipt.denyPageLock.Lock()
+	defer ipt.denyPageLock.Unlock()
+	ipt.delDenyPageRules()
+}
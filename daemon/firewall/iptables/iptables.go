@@ -3,11 +3,13 @@ package iptables
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"os/exec"
 	"regexp"
 	"strings"
 	"sync"
 
+	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/firewall/common"
 	"github.com/evilsocket/opensnitch/daemon/firewall/config"
 	"github.com/evilsocket/opensnitch/daemon/log"
@@ -39,6 +41,10 @@ const (
 	ACCEPT = Action("ACCEPT")
 )
 
+// Executor runs an iptables command, and returns its output.
+// bin is the binary of the family of the rule: iptables or ip6tables.
+type Executor func(bin string, args []string) (string, error)
+
 // SystemRule blabla
 type SystemRule struct {
 	Rule  *config.FwRule
@@ -58,8 +64,31 @@ type Iptables struct {
 	regexSystemRulesQuery *regexp.Regexp
 	bin                   string
 	bin6                  string
+	exec                  Executor
 	chains                SystemChains
 	bypassQueue           bool
+
+	// destinations allowed in panic mode.
+	panicAllowed []*net.IPNet
+	panicMode    bool
+	panicLock    sync.Mutex
+
+	// jails of the binaries, by ID.
+	jails     map[string]*common.Jail
+	jailsLock sync.Mutex
+
+	// port of the deny page, 0 if it's disabled, and mark of the connections
+	// redirected to it.
+	denyPagePort uint16
+	denyPageMark uint32
+	denyPageLock sync.Mutex
+
+	// port of the forwarder of the proxy rules, 0 if it's disabled, and mark
+	// of the connections redirected to it.
+	proxyPort uint16
+	proxyMark uint32
+	proxyLock sync.Mutex
+
 	common.Common
 	config.Config
 
@@ -71,20 +100,26 @@ func Fw() (*Iptables, error) {
 	if err := IsAvailable(); err != nil {
 		return nil, err
 	}
+	return New(core.Exec), nil
+}
 
-	reRulesQuery, _ := regexp.Compile(`NFQUEUE.*ctstate NEW,RELATED.*NFQUEUE num.*`)
+// New returns an Iptables object which runs the commands with the given
+// Executor, i.e.: to delegate the rules to a firewall manager.
+func New(exec Executor) *Iptables {
+	reRulesQuery, _ := regexp.Compile(`NFQUEUE.*ctstate NEW,RELATED.*NFQUEUE (num|balance).*`)
 	reSystemRulesQuery, _ := regexp.Compile(SystemRulePrefix + ".*")
 
 	ipt := &Iptables{
 		bin:                   "iptables",
 		bin6:                  "ip6tables",
+		exec:                  exec,
 		regexRulesQuery:       reRulesQuery,
 		regexSystemRulesQuery: reSystemRulesQuery,
 		chains: SystemChains{
 			Rules: make(map[string]*SystemRule),
 		},
 	}
-	return ipt, nil
+	return ipt
 }
 
 // Name returns the firewall name
@@ -109,6 +144,15 @@ func (ipt *Iptables) Init(qNum uint16, configPath, monitorInterval string, bypas
 	ipt.NewSystemFwConfig(configPath, ipt.preloadConfCallback, ipt.reloadRulesCallback)
 	ipt.LoadDiskConfiguration(!common.ReloadConf)
 
+	// the rules of the previous instance of the daemon are still loaded,
+	// reuse them to not stop intercepting connections while upgrading.
+	if common.KeepRules && ipt.AreRulesLoaded() {
+		log.Info("iptables: reusing the interception rules of the previous instance")
+		ipt.NewRulesChecker(ipt.AreRulesLoaded, ipt.reloadRulesCallback)
+		ipt.Running = true
+		return
+	}
+
 	// start from a clean state
 	ipt.CleanRules(false)
 	ipt.EnableInterception()
@@ -147,6 +191,18 @@ func (ipt *Iptables) EnableInterception() {
 	} else if err4, err6 = ipt.QueueDNSResponses(common.EnableRule, true); err4 != nil || err6 != nil {
 		log.Error("Error while running DNS firewall rule: %s %s", err4, err6)
 	}
+	if err := ipt.restorePanicRules(); err != nil {
+		log.Error("Error while adding panic mode rules: %s", err)
+	}
+	if err := ipt.restoreJailRules(); err != nil {
+		log.Error("Error while adding jails rules: %s", err)
+	}
+	if err := ipt.restoreDenyPageRules(); err != nil {
+		log.Error("Error while adding deny page rules: %s", err)
+	}
+	if err := ipt.restoreProxyRules(); err != nil {
+		log.Error("Error while adding proxy rules: %s", err)
+	}
 	// start monitoring firewall rules to intercept network traffic
 	ipt.NewRulesChecker(ipt.AreRulesLoaded, ipt.reloadRulesCallback)
 }
@@ -161,6 +217,10 @@ func (ipt *Iptables) DisableInterception(logErrors bool) {
 // CleanRules deletes the rules we added.
 func (ipt *Iptables) CleanRules(logErrors bool) {
 	ipt.DisableInterception(logErrors)
+	ipt.delPanicRules()
+	ipt.cleanJailRules()
+	ipt.cleanDenyPageRules()
+	ipt.cleanProxyRules()
 	ipt.DeleteSystemRules(common.ForcedDelRules, common.BackupChains, logErrors)
 }
 
@@ -0,0 +1,109 @@
+package iptables
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+)
+
+// The new connections of the cgroup of a jail are filtered by a chain of the
+// jail, before queueing them. Only the ones to the loopback interface and to
+// the allowed destinations are let through:
+//
+// -t mangle -N opensnitch-jail-1a2b3c4d
+// -t mangle -A opensnitch-jail-1a2b3c4d -o lo -j RETURN
+// -t mangle -A opensnitch-jail-1a2b3c4d -d 192.168.1.0/24 -j RETURN
+// -t mangle -A opensnitch-jail-1a2b3c4d -j DROP
+// -t mangle -I OUTPUT -m cgroup --path opensnitch/jail-1a2b3c4d -m conntrack --ctstate NEW -j opensnitch-jail-1a2b3c4d
+
+func jailChain(id string) string {
+	return "opensnitch-" + id
+}
+
+func jailJumpRule(j *common.Jail) []string {
+	return []string{
+		"OUTPUT",
+		"-t", "mangle",
+		"-m", "cgroup",
+		"--path", j.Cgroup,
+		"-m", "conntrack",
+		"--ctstate", "NEW",
+		"-j", jailChain(j.ID()),
+	}
+}
+
+// AddJail adds the chain of a jail, replacing it if it already exists.
+func (ipt *Iptables) AddJail(j *common.Jail) error {
+	ipt.jailsLock.Lock()
+	defer ipt.jailsLock.Unlock()
+	if ipt.jails == nil {
+		ipt.jails = make(map[string]*common.Jail)
+	}
+	if old, found := ipt.jails[j.ID()]; found {
+		ipt.delJailRules(old)
+	}
+	ipt.jails[j.ID()] = j
+	return ipt.addJailRules(j)
+}
+
+// DelJail deletes the chain of a jail.
+func (ipt *Iptables) DelJail(j *common.Jail) error {
+	ipt.jailsLock.Lock()
+	defer ipt.jailsLock.Unlock()
+	if old, found := ipt.jails[j.ID()]; found {
+		j = old
+	}
+	delete(ipt.jails, j.ID())
+	ipt.delJailRules(j)
+	return nil
+}
+
+// restoreJailRules adds again the chains of the jails, after adding the
+// interception rules.
+func (ipt *Iptables) restoreJailRules() error {
+	ipt.jailsLock.Lock()
+	defer ipt.jailsLock.Unlock()
+	for _, j := range ipt.jails {
+		ipt.delJailRules(j)
+		if err := ipt.addJailRules(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanJailRules deletes the chains of all the jails, keeping them to add
+// them again.
+func (ipt *Iptables) cleanJailRules() {
+	ipt.jailsLock.Lock()
+	defer ipt.jailsLock.Unlock()
+	for _, j := range ipt.jails {
+		ipt.delJailRules(j)
+	}
+}
+
+func (ipt *Iptables) addJailRules(j *common.Jail) error {
+	chain := jailChain(j.ID())
+	ipt.RunRule(NEWCHAIN, common.EnableRule, true, []string{chain, "-t", "mangle"})
+	ipt.RunRule(ADD, common.EnableRule, true, []string{chain, "-t", "mangle", "-o", "lo", "-j", "RETURN"})
+	for _, dst := range j.Allowed {
+		if err := ipt.runFamilyRule(dst.IP.To4() == nil, ADD, []string{chain, "-t", "mangle", "-d", dst.String(), "-j", "RETURN"}); err != nil {
+			return fmt.Errorf("iptables: error allowing %s in the jail %s: %s", dst, j.Path, err)
+		}
+	}
+	if err4, err6 := ipt.RunRule(ADD, common.EnableRule, true, []string{chain, "-t", "mangle", "-j", string(DROP)}); err4 != nil || err6 != nil {
+		return fmt.Errorf("iptables: error adding the rules of the jail %s: %v, %v", j.Path, err4, err6)
+	}
+	if err4, err6 := ipt.RunRule(INSERT, common.EnableRule, true, jailJumpRule(j)); err4 != nil || err6 != nil {
+		return fmt.Errorf("iptables: error adding the rules of the jail %s: %v, %v", j.Path, err4, err6)
+	}
+	return nil
+}
+
+// delJailRules deletes the rules of a jail, and its chain.
+func (ipt *Iptables) delJailRules(j *common.Jail) {
+	chain := jailChain(j.ID())
+	ipt.RunRule(DELETE, !common.EnableRule, false, jailJumpRule(j))
+	ipt.RunRule(FLUSH, common.EnableRule, false, []string{chain, "-t", "mangle"})
+	ipt.RunRule(DELCHAIN, common.EnableRule, false, []string{chain, "-t", "mangle"})
+}
@@ -10,13 +10,13 @@ import (
 func (ipt *Iptables) AreRulesLoaded() bool {
 	var outMangle6 string
 
-	outMangle, err := core.Exec("iptables", []string{"-n", "-L", "OUTPUT", "-t", "mangle"})
+	outMangle, err := ipt.exec(ipt.bin, []string{"-n", "-L", "OUTPUT", "-t", "mangle"})
 	if err != nil {
 		return false
 	}
 
 	if core.IPv6Enabled {
-		outMangle6, err = core.Exec("ip6tables", []string{"-n", "-L", "OUTPUT", "-t", "mangle"})
+		outMangle6, err = ipt.exec(ipt.bin6, []string{"-n", "-L", "OUTPUT", "-t", "mangle"})
 		if err != nil {
 			return false
 		}
@@ -26,14 +26,14 @@ func (ipt *Iptables) AreRulesLoaded() bool {
 	ipt.chains.RLock()
 	if len(ipt.chains.Rules) > 0 {
 		for _, rule := range ipt.chains.Rules {
-			if chainOut4, err4 := core.Exec("iptables", []string{"-n", "-L", rule.Chain, "-t", rule.Table}); err4 == nil {
+			if chainOut4, err4 := ipt.exec(ipt.bin, []string{"-n", "-L", rule.Chain, "-t", rule.Table}); err4 == nil {
 				if ipt.regexSystemRulesQuery.FindString(chainOut4) == "" {
 					systemRulesLoaded = false
 					break
 				}
 			}
 			if core.IPv6Enabled {
-				if chainOut6, err6 := core.Exec("ip6tables", []string{"-n", "-L", rule.Chain, "-t", rule.Table}); err6 == nil {
+				if chainOut6, err6 := ipt.exec(ipt.bin6, []string{"-n", "-L", rule.Chain, "-t", rule.Table}); err6 == nil {
 					if ipt.regexSystemRulesQuery.FindString(chainOut6) == "" {
 						systemRulesLoaded = false
 						break
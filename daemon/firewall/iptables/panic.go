@@ -0,0 +1,104 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+)
+
+// PanicChain is the chain where the new outbound connections are dropped in
+// panic mode, before queueing them, except the ones to the loopback interface
+// and to the allowed destinations:
+//
+// -t mangle -N opensnitch-panic
+// -t mangle -A opensnitch-panic -o lo -j RETURN
+// -t mangle -A opensnitch-panic -d 192.168.1.0/24 -j RETURN
+// -t mangle -A opensnitch-panic -j DROP
+// -t mangle -I OUTPUT -m conntrack --ctstate NEW -j opensnitch-panic
+const PanicChain = "opensnitch-panic"
+
+var panicJumpRule = []string{
+	"OUTPUT",
+	"-t", "mangle",
+	"-m", "conntrack",
+	"--ctstate", "NEW",
+	"-j", PanicChain,
+}
+
+// EnablePanicMode drops the new outbound connections, except the ones to the
+// loopback interface and to the allowed destinations. The rules already
+// loaded and the established connections are not modified.
+func (ipt *Iptables) EnablePanicMode(allowed []*net.IPNet) error {
+	ipt.panicLock.Lock()
+	defer ipt.panicLock.Unlock()
+	ipt.panicMode = true
+	ipt.panicAllowed = allowed
+	return ipt.addPanicRules()
+}
+
+// DisablePanicMode deletes the rules of the panic mode.
+func (ipt *Iptables) DisablePanicMode() error {
+	ipt.panicLock.Lock()
+	defer ipt.panicLock.Unlock()
+	ipt.panicMode = false
+	ipt.panicAllowed = nil
+	ipt.delPanicRules()
+	return nil
+}
+
+// restorePanicRules adds again the rules of the panic mode, if it's enabled,
+// after adding the interception rules.
+func (ipt *Iptables) restorePanicRules() error {
+	ipt.panicLock.Lock()
+	defer ipt.panicLock.Unlock()
+	if !ipt.panicMode {
+		return nil
+	}
+	return ipt.addPanicRules()
+}
+
+func (ipt *Iptables) addPanicRules() error {
+	// the allowed destinations may have changed.
+	ipt.delPanicRules()
+
+	ipt.RunRule(NEWCHAIN, common.EnableRule, true, []string{PanicChain, "-t", "mangle"})
+	ipt.RunRule(ADD, common.EnableRule, true, []string{PanicChain, "-t", "mangle", "-o", "lo", "-j", "RETURN"})
+	for _, dst := range ipt.panicAllowed {
+		if err := ipt.runFamilyRule(dst.IP.To4() == nil, ADD, []string{PanicChain, "-t", "mangle", "-d", dst.String(), "-j", "RETURN"}); err != nil {
+			return fmt.Errorf("iptables: error allowing %s in panic mode: %s", dst, err)
+		}
+	}
+	if err4, err6 := ipt.RunRule(ADD, common.EnableRule, true, []string{PanicChain, "-t", "mangle", "-j", string(DROP)}); err4 != nil || err6 != nil {
+		return fmt.Errorf("iptables: error adding the panic mode rules: %v, %v", err4, err6)
+	}
+	// on top of the interception rules, the connections dropped are not
+	// queued.
+	if err4, err6 := ipt.RunRule(INSERT, common.EnableRule, true, panicJumpRule); err4 != nil || err6 != nil {
+		return fmt.Errorf("iptables: error adding the panic mode rules: %v, %v", err4, err6)
+	}
+	return nil
+}
+
+// delPanicRules deletes the rules of the panic mode, and its chain.
+func (ipt *Iptables) delPanicRules() {
+	ipt.RunRule(DELETE, !common.EnableRule, false, panicJumpRule)
+	ipt.RunRule(FLUSH, common.EnableRule, false, []string{PanicChain, "-t", "mangle"})
+	ipt.RunRule(DELCHAIN, common.EnableRule, false, []string{PanicChain, "-t", "mangle"})
+}
+
+// runFamilyRule runs a rule for IPv4 or IPv6 only.
+func (ipt *Iptables) runFamilyRule(ipv6 bool, action Action, rule []string) error {
+	bin := ipt.bin
+	if ipv6 {
+		if !core.IPv6Enabled {
+			return nil
+		}
+		bin = ipt.bin6
+	}
+	ipt.Lock()
+	defer ipt.Unlock()
+	_, err := ipt.exec(bin, append([]string{string(action)}, rule...))
+	return err
+}
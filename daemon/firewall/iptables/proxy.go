@@ -0,0 +1,84 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+)
+
+// The connections of the proxy rules, marked by the daemon when they're
+// verdicted, are redirected to the local forwarder, which sends them through
+// the upstream proxy of their rule:
+//
+// -t nat -I OUTPUT -p tcp -m mark --mark 0x101e -j REDIRECT --to-ports 8078
+//
+// The connections are originated on this host, so they're redirected with
+// REDIRECT, since TPROXY only applies to the packets in PREROUTING.
+
+func proxyRule(port uint16, mark uint32) []string {
+	return []string{
+		"OUTPUT",
+		"-t", "nat",
+		"-p", "tcp",
+		"-m", "mark",
+		"--mark", fmt.Sprintf("0x%x", mark),
+		"-j", "REDIRECT",
+		"--to-ports", strconv.Itoa(int(port)),
+	}
+}
+
+// EnableProxy redirects the connections accepted with the given mark to the
+// local port of the forwarder.
+func (ipt *Iptables) EnableProxy(port uint16, mark uint32) error {
+	ipt.proxyLock.Lock()
+	defer ipt.proxyLock.Unlock()
+	ipt.delProxyRules()
+	ipt.proxyPort = port
+	ipt.proxyMark = mark
+	return ipt.addProxyRules()
+}
+
+// DisableProxy deletes the rules of the forwarder.
+func (ipt *Iptables) DisableProxy() error {
+	ipt.proxyLock.Lock()
+	defer ipt.proxyLock.Unlock()
+	ipt.delProxyRules()
+	ipt.proxyPort = 0
+	return nil
+}
+
+// restoreProxyRules adds again the rules of the forwarder, if it's enabled,
+// after adding the interception rules.
+func (ipt *Iptables) restoreProxyRules() error {
+	ipt.proxyLock.Lock()
+	defer ipt.proxyLock.Unlock()
+	if ipt.proxyPort == 0 {
+		return nil
+	}
+	ipt.delProxyRules()
+	return ipt.addProxyRules()
+}
+
+func (ipt *Iptables) addProxyRules() error {
+	if err4, err6 := ipt.RunRule(INSERT, common.EnableRule, true, proxyRule(ipt.proxyPort, ipt.proxyMark)); err4 != nil || err6 != nil {
+		return fmt.Errorf("iptables: error adding the proxy rules: %v, %v", err4, err6)
+	}
+	return nil
+}
+
+// delProxyRules deletes the rules of the forwarder.
+func (ipt *Iptables) delProxyRules() {
+	if ipt.proxyPort == 0 {
+		return
+	}
+	ipt.RunRule(DELETE, !common.EnableRule, false, proxyRule(ipt.proxyPort, ipt.proxyMark))
+}
+
+// cleanProxyRules deletes the rules of the forwarder, keeping its port to
+// add them again.
+func (ipt *Iptables) cleanProxyRules() {
+	ipt.proxyLock.Lock()
+	defer ipt.proxyLock.Unlock()
+	ipt.delProxyRules()
+}
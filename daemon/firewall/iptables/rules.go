@@ -2,8 +2,11 @@ package iptables
 
 import (
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/vishvananda/netlink"
 )
@@ -33,13 +36,25 @@ func BuildQueueDNSRule(queueNum uint16, bypass bool) []string {
 
 // BuildQueueConnectionsRule returns the iptables rule arguments for queueing connections.
 func BuildQueueConnectionsRule(queueNum uint16, bypass bool) []string {
+	return BuildQueueConnectionsRangeRule(queueNum, 1, bypass)
+}
+
+// BuildQueueConnectionsRangeRule returns the iptables rule arguments for
+// balancing the connections between count queues, by the CPU that sends them.
+func BuildQueueConnectionsRangeRule(queueNum, count uint16, bypass bool) []string {
 	rule := []string{
 		"OUTPUT",
 		"-t", "mangle",
 		"-m", "conntrack",
 		"--ctstate", "NEW,RELATED",
 		"-j", "NFQUEUE",
-		"--queue-num", fmt.Sprintf("%d", queueNum),
+	}
+	if count > 1 {
+		rule = append(rule,
+			"--queue-balance", fmt.Sprintf("%d:%d", queueNum, queueNum+count-1),
+			"--queue-cpu-fanout")
+	} else {
+		rule = append(rule, "--queue-num", fmt.Sprintf("%d", queueNum))
 	}
 	if bypass {
 		rule = append(rule, "--queue-bypass")
@@ -65,7 +80,7 @@ func (ipt *Iptables) RunRule(action Action, enable bool, logError bool, rule []s
 	ipt.Lock()
 	defer ipt.Unlock()
 
-	if _, err4 = core.Exec(ipt.bin, rule); err4 != nil {
+	if _, err4 = ipt.exec(ipt.bin, rule); err4 != nil {
 		if logError {
 			log.Error("Error while running firewall rule, ipv4 err: %s", err4)
 			log.Error("rule: %s", rule)
@@ -74,7 +89,7 @@ func (ipt *Iptables) RunRule(action Action, enable bool, logError bool, rule []s
 
 	// On some systems IPv6 is disabled
 	if core.IPv6Enabled {
-		if _, err6 = core.Exec(ipt.bin6, rule); err6 != nil {
+		if _, err6 = ipt.exec(ipt.bin6, rule); err6 != nil {
 			if logError {
 				log.Error("Error while running firewall rule, ipv6 err: %s", err6)
 				log.Error("rule: %s", rule)
@@ -89,14 +104,17 @@ func (ipt *Iptables) RunRule(action Action, enable bool, logError bool, rule []s
 // of resolved domains.
 // INPUT --protocol udp --sport 53 -j NFQUEUE --queue-num 0 --queue-bypass
 func (ipt *Iptables) QueueDNSResponses(enable bool, logError bool) (err4, err6 error) {
-	return ipt.RunRule(INSERT, enable, logError, BuildQueueDNSRule(ipt.QueueNum, ipt.bypassQueue))
+	qNum, bypass := ipt.GetDNSQueue(ipt.bypassQueue)
+	return ipt.RunRule(INSERT, enable, logError, BuildQueueDNSRule(qNum, bypass))
 }
 
 // QueueConnections inserts the firewall rule which redirects connections to us.
 // Connections are queued until the user denies/accept them, or reaches a timeout.
 // OUTPUT -t mangle -m conntrack --ctstate NEW,RELATED -j NFQUEUE --queue-num 0 --queue-bypass
+// OUTPUT -t mangle -m conntrack --ctstate NEW,RELATED -j NFQUEUE --queue-balance 0:3 --queue-cpu-fanout --queue-bypass
 func (ipt *Iptables) QueueConnections(enable bool, logError bool) (error, error) {
-	err4, err6 := ipt.RunRule(ADD, enable, logError, BuildQueueConnectionsRule(ipt.QueueNum, ipt.bypassQueue))
+	rule := BuildQueueConnectionsRangeRule(ipt.QueueNum, ipt.GetQueueCount(), ipt.bypassQueue)
+	err4, err6 := ipt.RunRule(ADD, enable, logError, rule)
 	if enable {
 		// flush conntrack as soon as netfilter rule is set. This ensures that already-established
 		// connections will go to netfilter queue.
@@ -106,3 +124,26 @@ func (ipt *Iptables) QueueConnections(enable bool, logError bool) (error, error)
 	}
 	return err4, err6
 }
+
+// GetObjects is not supported by iptables, the named objects (counters,
+// quotas, limits) are only added by nftables.
+func (ipt *Iptables) GetObjects() ([]*common.ObjectValues, error) {
+	return nil, fmt.Errorf("iptables: named objects are not supported")
+}
+
+// DropRetransmissions is not supported by iptables, the retransmissions of the
+// connections denied are dropped in userspace.
+func (ipt *Iptables) DropRetransmissions(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint, timeout time.Duration) error {
+	return fmt.Errorf("iptables: dropping retransmissions in kernel is not supported")
+}
+
+// AddFlow is not supported by iptables, the packets of the UDP flows already
+// verdicted are queued and get the verdict of the table of flows.
+func (ipt *Iptables) AddFlow(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint, accept bool, timeout time.Duration) error {
+	return fmt.Errorf("iptables: verdicts of flows in kernel are not supported")
+}
+
+// FlushFlows is not supported by iptables.
+func (ipt *Iptables) FlushFlows() error {
+	return nil
+}
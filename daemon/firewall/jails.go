@@ -0,0 +1,220 @@
+package firewall
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// maxJailOrigins is the number of processes jailed after which the cgroups
+// of the processes that have exited are forgotten.
+const maxJailOrigins = 1024
+
+var (
+	// CgroupRoot is the mount point of the cgroup2 hierarchy. The cgroups of
+	// the jails are created under JailsCgroup.
+	CgroupRoot  = "/sys/fs/cgroup"
+	JailsCgroup = "opensnitch"
+
+	procPath = "/proc"
+
+	// jails by the path of the binary, kept to add them again when the
+	// firewall is reloaded.
+	jails     = make(map[string]*jailState)
+	jailsLock sync.Mutex
+)
+
+type jailState struct {
+	*common.Jail
+
+	// error creating the cgroup of the jail. The connections of the binary
+	// are denied while it can't be jailed.
+	err error
+
+	// cgroups where the processes jailed were, to move them back when the
+	// jail is deleted.
+	origins map[int]string
+}
+
+// SetJails jails the given binaries: their processes only can connect to the
+// loopback interface and to the allowed destinations. The processes of the
+// binaries not jailed anymore are moved back to their cgroups.
+func SetJails(allowed map[string][]*net.IPNet) error {
+	jailsLock.Lock()
+	defer jailsLock.Unlock()
+
+	for path, s := range jails {
+		if _, found := allowed[path]; !found {
+			deleteJail(s)
+			delete(jails, path)
+		}
+	}
+
+	var firstErr error
+	for path, dsts := range allowed {
+		j := &common.Jail{
+			Path:    path,
+			Cgroup:  JailsCgroup + "/" + common.JailID(path),
+			Allowed: dsts,
+		}
+		s, found := jails[path]
+		if !found {
+			s = &jailState{origins: make(map[int]string)}
+			jails[path] = s
+		}
+		j.CgroupID, s.err = newCgroup(j.Cgroup)
+		if s.err != nil {
+			log.Warning("[jails] unable to jail %s, its connections will be denied: %s", path, s.err)
+			if firstErr == nil {
+				firstErr = s.err
+			}
+		}
+		if found && j.Equal(s.Jail) {
+			continue
+		}
+		s.Jail = j
+		if s.err != nil || fw == nil {
+			continue
+		}
+		if err := fw.AddJail(j); err != nil {
+			log.Warning("[jails] error adding the jail of %s: %s", path, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// JailProcess moves a process to the jail of its binary, if it's jailed, and
+// returns the jail. The connections of the process must be denied if it
+// returns an error.
+func JailProcess(path string, pid int) (*common.Jail, error) {
+	jailsLock.Lock()
+	defer jailsLock.Unlock()
+
+	s, found := jails[path]
+	if !found {
+		return nil, nil
+	}
+	if s.err != nil {
+		return s.Jail, s.err
+	}
+	cgroup, err := processCgroup(pid)
+	if err != nil {
+		return s.Jail, err
+	}
+	if cgroup == "/"+s.Cgroup {
+		return s.Jail, nil
+	}
+	if err := writeCgroupProcs(s.Cgroup, pid); err != nil {
+		return s.Jail, err
+	}
+	if len(s.origins) >= maxJailOrigins {
+		for p := range s.origins {
+			if _, err := os.Stat(filepath.Join(procPath, strconv.Itoa(p))); err != nil {
+				delete(s.origins, p)
+			}
+		}
+	}
+	s.origins[pid] = cgroup
+	log.Debug("[jails] process %s (%d) moved from %s to %s", path, pid, cgroup, s.Cgroup)
+	return s.Jail, nil
+}
+
+// restoreJails adds the jails to a new firewall.
+func restoreJails() {
+	jailsLock.Lock()
+	defer jailsLock.Unlock()
+	for _, s := range jails {
+		if s.err != nil {
+			continue
+		}
+		if err := fw.AddJail(s.Jail); err != nil {
+			log.Error("Error adding the jail of %s: %s", s.Path, err)
+		}
+	}
+}
+
+// deleteJail deletes the rules of a jail, moves its processes back to their
+// cgroups, and deletes its cgroup.
+func deleteJail(s *jailState) {
+	if s.err != nil {
+		return
+	}
+	if fw != nil {
+		if err := fw.DelJail(s.Jail); err != nil {
+			log.Warning("[jails] error deleting the jail of %s: %s", s.Path, err)
+		}
+	}
+	procs, err := os.ReadFile(filepath.Join(CgroupRoot, s.Cgroup, "cgroup.procs"))
+	if err != nil {
+		log.Warning("[jails] error reading the processes of the jail of %s: %s", s.Path, err)
+	}
+	for _, line := range strings.Fields(string(procs)) {
+		pid, err := strconv.Atoi(line)
+		if err != nil {
+			continue
+		}
+		// the children of the processes jailed are moved to the root cgroup.
+		origin, found := s.origins[pid]
+		if !found {
+			origin = "/"
+		}
+		if err := writeCgroupProcs(origin, pid); err != nil {
+			log.Warning("[jails] error releasing the process %d of the jail of %s: %s", pid, s.Path, err)
+		}
+	}
+	if err := os.Remove(filepath.Join(CgroupRoot, s.Cgroup)); err != nil {
+		log.Debug("[jails] error deleting the cgroup %s: %s", s.Cgroup, err)
+	}
+}
+
+// newCgroup creates a cgroup, if it doesn't exist, and returns its ID.
+func newCgroup(cgroup string) (uint64, error) {
+	if _, err := os.Stat(filepath.Join(CgroupRoot, "cgroup.controllers")); err != nil {
+		return 0, fmt.Errorf("cgroup v2 not available on %s", CgroupRoot)
+	}
+	dir := filepath.Join(CgroupRoot, cgroup)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("unable to get the ID of the cgroup %s", cgroup)
+	}
+	return st.Ino, nil
+}
+
+// processCgroup returns the cgroup2 path of a process.
+func processCgroup(pid int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(procPath, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if cgroup := strings.TrimPrefix(scanner.Text(), "0::"); cgroup != scanner.Text() {
+			return cgroup, nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 of the process %d not found", pid)
+}
+
+func writeCgroupProcs(cgroup string, pid int) error {
+	return os.WriteFile(filepath.Join(CgroupRoot, cgroup, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+}
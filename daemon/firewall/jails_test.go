@@ -0,0 +1,65 @@
+package firewall
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJails(t *testing.T) {
+	origRoot, origProc := CgroupRoot, procPath
+	CgroupRoot, procPath = t.TempDir(), t.TempDir()
+	defer func() {
+		CgroupRoot, procPath = origRoot, origProc
+		jails = make(map[string]*jailState)
+	}()
+
+	_, lan, _ := net.ParseCIDR("192.168.1.0/24")
+	allowed := map[string][]*net.IPNet{"/usr/bin/curl": {lan}}
+	if err := SetJails(allowed); err == nil {
+		t.Error("jail created without cgroup v2")
+	}
+	if j, err := JailProcess("/usr/bin/curl", 1234); j == nil || err == nil {
+		t.Error("the connections of a binary that can't be jailed should be denied")
+	}
+
+	os.WriteFile(filepath.Join(CgroupRoot, "cgroup.controllers"), []byte{}, 0644)
+	if err := SetJails(allowed); err != nil {
+		t.Fatal("SetJails() error:", err)
+	}
+	os.MkdirAll(filepath.Join(procPath, "1234"), 0755)
+	os.WriteFile(filepath.Join(procPath, "1234", "cgroup"), []byte("0::/user.slice/session-1.scope\n"), 0644)
+
+	if j, err := JailProcess("/usr/bin/wget", 1234); j != nil || err != nil {
+		t.Error("binary not jailed moved to a jail:", j, err)
+	}
+	j, err := JailProcess("/usr/bin/curl", 1234)
+	if j == nil || err != nil {
+		t.Fatal("process not jailed:", err)
+	}
+	if j.Cgroup != "opensnitch/"+j.ID() || j.Level() != 2 || j.CgroupID == 0 {
+		t.Errorf("invalid cgroup of the jail: %+v", j)
+	}
+	procs, _ := os.ReadFile(filepath.Join(CgroupRoot, j.Cgroup, "cgroup.procs"))
+	if string(procs) != "1234" {
+		t.Error("process not moved to the cgroup of the jail:", string(procs))
+	}
+	if !j.Allows(net.ParseIP("192.168.1.1")) || !j.Allows(net.ParseIP("127.0.0.53")) || j.Allows(net.ParseIP("1.1.1.1")) {
+		t.Error("invalid destinations allowed by the jail")
+	}
+
+	// the processes are moved back to their cgroups.
+	os.MkdirAll(filepath.Join(CgroupRoot, "user.slice/session-1.scope"), 0755)
+	if err := SetJails(nil); err != nil {
+		t.Fatal("SetJails() error:", err)
+	}
+	procs, _ = os.ReadFile(filepath.Join(CgroupRoot, "user.slice/session-1.scope", "cgroup.procs"))
+	if strings.TrimSpace(string(procs)) != "1234" {
+		t.Error("process not released from the jail:", string(procs))
+	}
+	if j, _ := JailProcess("/usr/bin/curl", 1234); j != nil {
+		t.Error("jail not deleted")
+	}
+}
@@ -0,0 +1,110 @@
+package nftables
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// DenyPageChain is the chain where the denied HTTP connections, marked by the
+// daemon when they're verdicted, are redirected to the local responder of the
+// deny page:
+//
+// nft add chain inet opensnitch denypage { type nat hook output priority -100 \; }
+// nft add rule inet opensnitch denypage meta mark 0x101d meta l4proto tcp redirect to :8077
+const DenyPageChain = "denypage"
+
+// EnableDenyPage redirects the connections accepted with the given mark to
+// the local port of the deny page.
+func (n *Nft) EnableDenyPage(port uint16, mark uint32) error {
+	n.Lock()
+	defer n.Unlock()
+	n.denyPagePort = port
+	n.denyPageMark = mark
+	return n.addDenyPageRules()
+}
+
+// DisableDenyPage deletes the rules of the deny page.
+func (n *Nft) DisableDenyPage() error {
+	n.Lock()
+	defer n.Unlock()
+	n.denyPagePort = 0
+	return n.delDenyPageRules()
+}
+
+// restoreDenyPageRules adds again the rules of the deny page, if it's
+// enabled, after adding the interception rules.
+func (n *Nft) restoreDenyPageRules() error {
+	n.Lock()
+	defer n.Unlock()
+	if n.denyPagePort == 0 {
+		return nil
+	}
+	return n.addDenyPageRules()
+}
+
+func (n *Nft) addDenyPageRules() error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s deny page: netlink connection not active", logTag)
+	}
+	table := n.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if table == nil {
+		return fmt.Errorf("%s deny page: table %s not found", logTag, exprs.TABLE_OPENSNITCH)
+	}
+	// the port may have changed.
+	if err := n.delDenyPageRules(); err != nil {
+		return err
+	}
+
+	policy := nftables.ChainPolicyAccept
+	chain := n.Conn.AddChain(&nftables.Chain{
+		Name:     DenyPageChain,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookOutput,
+		Priority: nftables.ChainPriorityNATDest,
+		Policy:   &policy,
+	})
+	n.Conn.AddRule(&nftables.Rule{
+		Table: table,
+		Chain: chain,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.NativeEndian.PutUint32(n.denyPageMark)},
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+			&expr.Immediate{Register: 1, Data: binaryutil.BigEndian.PutUint16(n.denyPagePort)},
+			&expr.Redir{RegisterProtoMin: 1},
+		},
+		UserData: []byte(DenyPageRuleKey),
+	})
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding the deny page rules", logTag)
+	}
+	return nil
+}
+
+// delDenyPageRules deletes the rules of the deny page, and its chain.
+func (n *Nft) delDenyPageRules() error {
+	if err := n.delRulesByKey(DenyPageRuleKey); err != nil {
+		return err
+	}
+	chains, err := n.Conn.ListChains()
+	if err != nil {
+		return fmt.Errorf("%s deny page, error listing chains: %s", logTag, err)
+	}
+	for _, c := range chains {
+		if c.Name != DenyPageChain || c.Table.Name != exprs.TABLE_OPENSNITCH {
+			continue
+		}
+		n.Conn.DelChain(c)
+		if !n.Commit() {
+			return fmt.Errorf("%s error deleting the deny page chain", logTag)
+		}
+	}
+	return nil
+}
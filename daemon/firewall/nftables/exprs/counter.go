@@ -4,12 +4,24 @@ import (
 	"github.com/google/nftables/expr"
 )
 
+// types of the named objects (nft_object_type).
+const (
+	NFT_OBJECT_COUNTER = 1
+	NFT_OBJECT_QUOTA   = 2
+	NFT_OBJECT_LIMIT   = 4
+)
+
 // NewExprCounter returns a counter for packets or bytes.
 func NewExprCounter(counterName string) *[]expr.Any {
+	return NewExprObjref(NFT_OBJECT_COUNTER, counterName)
+}
+
+// NewExprObjref returns a reference to a named object (counter, quota).
+func NewExprObjref(objType int, name string) *[]expr.Any {
 	return &[]expr.Any{
 		&expr.Objref{
-			Type: 1,
-			Name: counterName,
+			Type: objType,
+			Name: name,
 		},
 	}
 }
@@ -113,6 +113,8 @@ func getCtState(flag string) (mask uint32, err error) {
 		mask |= expr.CtStateBitRELATED
 	case CT_STATE_INVALID:
 		mask |= expr.CtStateBitINVALID
+	case CT_STATE_UNTRACKED:
+		mask |= expr.CtStateBitUNTRACKED
 	default:
 		return 0, fmt.Errorf("Invalid conntrack flag: %s", flag)
 	}
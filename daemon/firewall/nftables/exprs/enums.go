@@ -102,10 +102,12 @@ const (
 	CT_STATE_ESTABLISHED = "established"
 	CT_STATE_RELATED     = "related"
 	CT_STATE_INVALID     = "invalid"
+	CT_STATE_UNTRACKED   = "untracked"
 
 	NFT_NOTRACK = "notrack"
 
 	NFT_QUOTA            = "quota"
+	NFT_QUOTA_NAME       = "name"
 	NFT_QUOTA_UNTIL      = "until"
 	NFT_QUOTA_OVER       = "over"
 	NFT_QUOTA_USED       = "used"
@@ -120,6 +122,7 @@ const (
 	NFT_COUNTER_BYTES   = "bytes"
 
 	NFT_LIMIT             = "limit"
+	NFT_LIMIT_NAME        = "name"
 	NFT_LIMIT_OVER        = "over"
 	NFT_LIMIT_BURST       = "burst"
 	NFT_LIMIT_UNITS_RATE  = "rate-units"
@@ -174,6 +177,7 @@ const (
 	ICMP_ADMIN_PROHIBITED   = "admin-prohibited"
 	ICMP_REJECT_ROUTE       = "reject-route"
 	ICMP_REJECT_POLICY_FAIL = "policy-fail"
+	ICMP_FRAG_NEEDED        = "frag-needed"
 
 	ICMP_ECHO_REPLY           = "echo-reply"
 	ICMP_ECHO_REQUEST         = "echo-request"
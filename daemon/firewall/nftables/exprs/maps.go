@@ -0,0 +1,255 @@
+package exprs
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/config"
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// keywords used to define maps, and to use them in the rules:
+// "Name": "vmap",
+// "Values": [ {"Key": "dport", "Value": "input_ports"} ]
+const (
+	NFT_MAP  = "map"
+	NFT_VMAP = "vmap"
+
+	NFT_MAP_TYPE_VERDICT = "verdict"
+	NFT_MAP_TYPE_PORT    = "inet_service"
+	NFT_MAP_TYPE_IP      = "ipv4_addr"
+	NFT_MAP_TYPE_IP6     = "ipv6_addr"
+	NFT_MAP_TYPE_PROTO   = "inet_proto"
+	NFT_MAP_TYPE_IFNAME  = "ifname"
+	NFT_MAP_TYPE_MARK    = "mark"
+)
+
+// GetMapDatatype returns the nftables type of the keys or values of a map.
+func GetMapDatatype(name string) (nftables.SetDatatype, error) {
+	switch name {
+	case NFT_MAP_TYPE_VERDICT:
+		return nftables.TypeVerdict, nil
+	case NFT_MAP_TYPE_PORT:
+		return nftables.TypeInetService, nil
+	case NFT_MAP_TYPE_IP:
+		return nftables.TypeIPAddr, nil
+	case NFT_MAP_TYPE_IP6:
+		return nftables.TypeIP6Addr, nil
+	case NFT_MAP_TYPE_PROTO:
+		return nftables.TypeInetProto, nil
+	case NFT_MAP_TYPE_IFNAME:
+		return nftables.TypeIFName, nil
+	case NFT_MAP_TYPE_MARK:
+		return nftables.TypeMark, nil
+	}
+	return nftables.TypeInvalid, fmt.Errorf("unsupported map type: %s", name)
+}
+
+// NewMapElements returns the elements of a map, encoded with the types of
+// the keys and values.
+func NewMapElements(keyType, dataType nftables.SetDatatype, elements []*config.ExprValues) ([]nftables.SetElement, error) {
+	if keyType.Name == NFT_MAP_TYPE_VERDICT {
+		return nil, fmt.Errorf("verdicts can't be used as keys of a map")
+	}
+	setElements := make([]nftables.SetElement, 0, len(elements))
+	for _, el := range elements {
+		key, err := encodeMapValue(keyType, el.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %s", el.Key, err)
+		}
+		elem := nftables.SetElement{Key: key}
+		if dataType.Name == NFT_MAP_TYPE_VERDICT {
+			elem.VerdictData, err = newMapVerdict(el.Value)
+		} else {
+			elem.Val, err = encodeMapValue(dataType, el.Value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %s: %s", el.Value, err)
+		}
+		setElements = append(setElements, elem)
+	}
+
+	return setElements, nil
+}
+
+func encodeMapValue(dt nftables.SetDatatype, value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	switch dt.Name {
+	case NFT_MAP_TYPE_PORT:
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		return binaryutil.BigEndian.PutUint16(uint16(port)), nil
+	case NFT_MAP_TYPE_IP:
+		if ip := net.ParseIP(value).To4(); ip != nil {
+			return ip, nil
+		}
+	case NFT_MAP_TYPE_IP6:
+		if ip := net.ParseIP(value); ip != nil && ip.To4() == nil {
+			return ip.To16(), nil
+		}
+	case NFT_MAP_TYPE_PROTO:
+		proto, err := getProtocolCode(value)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{proto}, nil
+	case NFT_MAP_TYPE_IFNAME:
+		if value != "" && len(value) < 16 && !strings.HasSuffix(value, "*") {
+			return ifname(value), nil
+		}
+	case NFT_MAP_TYPE_MARK:
+		mark, err := strconv.ParseUint(value, 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		return binaryutil.NativeEndian.PutUint32(uint32(mark)), nil
+	}
+	return nil, fmt.Errorf("invalid %s", dt.Name)
+}
+
+// newMapVerdict returns the verdict of an element of a verdict map:
+// accept, drop, return, continue, jump <chain>, goto <chain>
+func newMapVerdict(value string) (*expr.Verdict, error) {
+	parts := strings.Fields(strings.ToLower(value))
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty verdict")
+	}
+	switch parts[0] {
+	case VERDICT_ACCEPT:
+		return &expr.Verdict{Kind: expr.VerdictAccept}, nil
+	case VERDICT_DROP:
+		return &expr.Verdict{Kind: expr.VerdictDrop}, nil
+	case VERDICT_RETURN:
+		return &expr.Verdict{Kind: expr.VerdictReturn}, nil
+	case VERDICT_CONTINUE:
+		return &expr.Verdict{Kind: expr.VerdictContinue}, nil
+	case VERDICT_JUMP, VERDICT_GOTO:
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s requires the name of the chain", parts[0])
+		}
+		kind := expr.VerdictJump
+		if parts[0] == VERDICT_GOTO {
+			kind = expr.VerdictGoto
+		}
+		// chain names are case sensitive
+		return &expr.Verdict{Kind: kind, Chain: strings.Fields(value)[1]}, nil
+	}
+	return nil, fmt.Errorf("unsupported verdict")
+}
+
+// NewExprMapKey returns the expressions to load the key of a lookup in a map
+// into the register 1. The key must be of the same type as the keys of the map.
+func NewExprMapKey(family, key string, keyType nftables.SetDatatype) (*[]expr.Any, error) {
+	exprList := []expr.Any{}
+	switch key {
+	case NFT_DPORT, NFT_SPORT:
+		if keyType.Name != NFT_MAP_TYPE_PORT {
+			break
+		}
+		exprPDir, err := NewExprPortDirection(key)
+		if err != nil {
+			return nil, err
+		}
+		return &[]expr.Any{exprPDir}, nil
+
+	case NFT_SADDR, NFT_DADDR:
+		var offset uint32
+		nfproto := byte(unix.NFPROTO_IPV4)
+		switch {
+		case keyType.Name == NFT_MAP_TYPE_IP && family != NFT_FAMILY_IP6:
+			offset = 12
+			if key == NFT_DADDR {
+				offset = 16
+			}
+		case keyType.Name == NFT_MAP_TYPE_IP6 && family != NFT_FAMILY_IP:
+			nfproto = unix.NFPROTO_IPV6
+			offset = 8
+			if key == NFT_DADDR {
+				offset = 24
+			}
+		default:
+			return nil, fmt.Errorf("%s can't be used with maps of type %s in %s tables", key, keyType.Name, family)
+		}
+		// if the table family is inet, we need to specify the protocol of the IP.
+		if family == NFT_FAMILY_INET {
+			exprList = append(exprList,
+				&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{nfproto}},
+			)
+		}
+		exprList = append(exprList, &expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          keyType.Bytes,
+		})
+		return &exprList, nil
+
+	case NFT_META_L4PROTO:
+		if keyType.Name == NFT_MAP_TYPE_PROTO {
+			return &[]expr.Any{&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1}}, nil
+		}
+	case NFT_IIFNAME, NFT_OIFNAME:
+		if keyType.Name == NFT_MAP_TYPE_IFNAME {
+			metaKey := expr.MetaKeyIIFNAME
+			if key == NFT_OIFNAME {
+				metaKey = expr.MetaKeyOIFNAME
+			}
+			return &[]expr.Any{&expr.Meta{Key: metaKey, Register: 1}}, nil
+		}
+	case NFT_META_MARK:
+		if keyType.Name == NFT_MAP_TYPE_MARK {
+			return &[]expr.Any{&expr.Meta{Key: expr.MetaKeyMARK, Register: 1}}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported map key: %s", key)
+	}
+
+	return nil, fmt.Errorf("%s can't be used with maps of type %s", key, keyType.Name)
+}
+
+// NewExprMapLookup returns the expressions to lookup a key in a map:
+//   - vmap: the verdict of the element found is applied:
+//     tcp dport vmap @input_ports
+//   - map: the packet is marked with the value of the element found (the map
+//     must be of type mark):
+//     meta mark set tcp dport map @ports_marks
+func NewExprMapLookup(what string, set *nftables.Set) (*[]expr.Any, error) {
+	switch what {
+	case NFT_VMAP:
+		if set.DataType.Name != NFT_MAP_TYPE_VERDICT {
+			return nil, fmt.Errorf("%s is not a verdict map", set.Name)
+		}
+		return &[]expr.Any{
+			&expr.Lookup{
+				SourceRegister: 1,
+				DestRegister:   0,
+				IsDestRegSet:   true,
+				SetName:        set.Name,
+				SetID:          set.ID,
+			},
+		}, nil
+	case NFT_MAP:
+		if set.DataType.Name != NFT_MAP_TYPE_MARK {
+			return nil, fmt.Errorf("only maps of type mark can be used to set the mark of a packet (%s)", set.Name)
+		}
+		return &[]expr.Any{
+			&expr.Lookup{
+				SourceRegister: 1,
+				DestRegister:   1,
+				IsDestRegSet:   true,
+				SetName:        set.Name,
+				SetID:          set.ID,
+			},
+			&expr.Meta{Key: expr.MetaKeyMARK, SourceRegister: true, Register: 1},
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid map statement: %s", what)
+}
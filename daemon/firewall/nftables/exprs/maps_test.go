@@ -0,0 +1,78 @@
+package exprs_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/config"
+	exprs "github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+func TestMapElements(t *testing.T) {
+	elements := []*config.ExprValues{
+		{Key: "22", Value: "accept"},
+		{Key: "80", Value: "jump filter_Web"},
+	}
+	setElements, err := exprs.NewMapElements(nftables.TypeInetService, nftables.TypeVerdict, elements)
+	if err != nil {
+		t.Fatal("NewMapElements() error:", err)
+	}
+	if !bytes.Equal(setElements[0].Key, []byte{0, 22}) || setElements[0].VerdictData.Kind != expr.VerdictAccept {
+		t.Errorf("invalid element: %+v", setElements[0])
+	}
+	if v := setElements[1].VerdictData; v.Kind != expr.VerdictJump || v.Chain != "filter_Web" {
+		t.Errorf("invalid jump verdict: %+v", v)
+	}
+
+	setElements, err = exprs.NewMapElements(nftables.TypeIPAddr, nftables.TypeMark, []*config.ExprValues{{Key: "1.1.1.1", Value: "0x10"}})
+	if err != nil || !bytes.Equal(setElements[0].Key, []byte{1, 1, 1, 1}) || len(setElements[0].Val) != 4 {
+		t.Errorf("invalid ip -> mark element: %+v, %v", setElements, err)
+	}
+
+	invalid := []struct {
+		keyType, dataType nftables.SetDatatype
+		key, value        string
+	}{
+		{nftables.TypeInetService, nftables.TypeVerdict, "65536", "accept"},
+		{nftables.TypeInetService, nftables.TypeVerdict, "22", "jump"},
+		{nftables.TypeInetService, nftables.TypeVerdict, "22", "queue"},
+		{nftables.TypeIPAddr, nftables.TypeVerdict, "::1", "drop"},
+		{nftables.TypeIFName, nftables.TypeVerdict, "eth*", "drop"},
+		{nftables.TypeVerdict, nftables.TypeMark, "accept", "1"},
+	}
+	for _, test := range invalid {
+		if _, err := exprs.NewMapElements(test.keyType, test.dataType, []*config.ExprValues{{Key: test.key, Value: test.value}}); err == nil {
+			t.Errorf("invalid element accepted: %s -> %s", test.key, test.value)
+		}
+	}
+}
+
+func TestMapKey(t *testing.T) {
+	if _, err := exprs.GetMapDatatype("string"); err == nil {
+		t.Error("unsupported map type accepted")
+	}
+	keyExpr, err := exprs.NewExprMapKey(exprs.NFT_FAMILY_INET, exprs.NFT_DADDR, nftables.TypeIP6Addr)
+	if err != nil || len(*keyExpr) != 3 {
+		t.Fatal("invalid daddr key:", keyExpr, err)
+	}
+	if p := (*keyExpr)[2].(*expr.Payload); p.Offset != 24 || p.Len != 16 {
+		t.Errorf("invalid ipv6 daddr payload: %+v", p)
+	}
+	if _, err := exprs.NewExprMapKey(exprs.NFT_FAMILY_INET, exprs.NFT_DPORT, nftables.TypeIPAddr); err == nil {
+		t.Error("dport accepted as key of a map of IPs")
+	}
+	if _, err := exprs.NewExprMapKey(exprs.NFT_FAMILY_IP, exprs.NFT_SADDR, nftables.TypeIP6Addr); err == nil {
+		t.Error("ipv6 key accepted in an ip table")
+	}
+
+	set := &nftables.Set{Name: "ports", IsMap: true, KeyType: nftables.TypeInetService, DataType: nftables.TypeMark}
+	if _, err := exprs.NewExprMapLookup(exprs.NFT_VMAP, set); err == nil {
+		t.Error("map of marks used as verdict map")
+	}
+	lookup, err := exprs.NewExprMapLookup(exprs.NFT_MAP, set)
+	if err != nil || len(*lookup) != 2 {
+		t.Error("invalid map lookup:", lookup, err)
+	}
+}
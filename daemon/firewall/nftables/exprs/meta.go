@@ -3,6 +3,7 @@ package exprs
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/evilsocket/opensnitch/daemon/firewall/config"
 	"github.com/google/nftables/binaryutil"
@@ -25,25 +26,40 @@ func NewExprMeta(values []*config.ExprValues, cmpOp *expr.CmpOp) (*[]expr.Any, e
 			if err != nil {
 				return nil, err
 			}
-			metaVal, err := getMetaValue(meta.Value)
+			metaVal, mask, err := parseMark(meta.Value)
 			if err != nil {
 				return nil, err
 			}
 			if setMark {
+				if mask != ^uint32(0) {
+					return nil, fmt.Errorf("%s a mask is not allowed setting a mark: %s", "nftables", meta.Value)
+				}
 				metaExpr = append(metaExpr, []expr.Any{
 					&expr.Immediate{
 						Register: 1,
-						Data:     binaryutil.NativeEndian.PutUint32(uint32(metaVal)),
+						Data:     binaryutil.NativeEndian.PutUint32(metaVal),
 					}}...)
 				metaExpr = append(metaExpr, []expr.Any{
 					&expr.Meta{Key: metaKey, Register: 1, SourceRegister: setMark}}...)
 			} else {
 				metaExpr = append(metaExpr, []expr.Any{
-					&expr.Meta{Key: metaKey, Register: 1, SourceRegister: setMark},
+					&expr.Meta{Key: metaKey, Register: 1, SourceRegister: setMark}}...)
+				// meta mark and 0xff00 == 0x100
+				if mask != ^uint32(0) {
+					metaExpr = append(metaExpr, []expr.Any{
+						&expr.Bitwise{
+							SourceRegister: 1,
+							DestRegister:   1,
+							Len:            4,
+							Mask:           binaryutil.NativeEndian.PutUint32(mask),
+							Xor:            binaryutil.NativeEndian.PutUint32(0),
+						}}...)
+				}
+				metaExpr = append(metaExpr, []expr.Any{
 					&expr.Cmp{
 						Op:       *cmpOp,
 						Register: 1,
-						Data:     binaryutil.NativeEndian.PutUint32(uint32(metaVal)),
+						Data:     binaryutil.NativeEndian.PutUint32(metaVal),
 					}}...)
 			}
 
@@ -116,6 +132,25 @@ func getMetaValue(value string) (int, error) {
 	return metaVal, nil
 }
 
+// parseMark returns the mark and the mask of a value: decimal or hexadecimal,
+// with an optional mask (0x100/0xff00).
+func parseMark(value string) (mark, mask uint32, err error) {
+	mask = ^uint32(0)
+	parts := strings.SplitN(value, "/", 2)
+	m, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid mark: %s", value)
+	}
+	if len(parts) == 2 {
+		msk, err := strconv.ParseUint(parts[1], 0, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid mark mask: %s", value)
+		}
+		mask = uint32(msk)
+	}
+	return uint32(m) & mask, mask, nil
+}
+
 // https://github.com/google/nftables/blob/main/expr/expr.go#L168
 func getMetaKey(value string) (expr.MetaKey, error) {
 	switch value {
@@ -41,6 +41,54 @@ func TestExprMeta(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"test-meta-mark-mask",
+			exprs.NFT_FAMILY_IP,
+			"",
+			[]*config.ExprValues{
+				&config.ExprValues{
+					Key:   exprs.NFT_META_MARK,
+					Value: "0x100/0xff00",
+				},
+			},
+			3,
+			[]interface{}{
+				&expr.Meta{
+					Key:            expr.MetaKeyMARK,
+					Register:       1,
+					SourceRegister: false,
+				},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Len:            4,
+					Mask:           binaryutil.NativeEndian.PutUint32(0xff00),
+					Xor:            binaryutil.NativeEndian.PutUint32(0),
+				},
+				&expr.Cmp{
+					Data: binaryutil.NativeEndian.PutUint32(uint32(0x100)),
+				},
+			},
+			false,
+		},
+		{
+			"test-meta-set-mark-mask",
+			exprs.NFT_FAMILY_IP,
+			"",
+			[]*config.ExprValues{
+				&config.ExprValues{
+					Key:   exprs.NFT_META_SET_MARK,
+					Value: "",
+				},
+				&config.ExprValues{
+					Key:   exprs.NFT_META_MARK,
+					Value: "0x100/0xff00",
+				},
+			},
+			2,
+			[]interface{}{},
+			true,
+		},
 		{
 			"test-meta-set-mark",
 			exprs.NFT_FAMILY_IP,
@@ -5,12 +5,36 @@ import (
 	"strconv"
 
 	"github.com/evilsocket/opensnitch/daemon/firewall/config"
+	"github.com/google/nftables"
 	"github.com/google/nftables/expr"
 )
 
 // NewQuota returns a new quota expression.
-// TODO: named quotas
+// The named quotas are referenced with NewExprObjref().
 func NewQuota(opts []*config.ExprValues) (*[]expr.Any, error) {
+	quota, err := newQuota(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &[]expr.Any{quota}, nil
+}
+
+// NewQuotaObj returns a named quota, to be shared by several rules.
+func NewQuotaObj(tbl *nftables.Table, name string, opts []*config.ExprValues) (*nftables.QuotaObj, error) {
+	quota, err := newQuota(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &nftables.QuotaObj{
+		Table:    tbl,
+		Name:     name,
+		Bytes:    quota.Bytes,
+		Consumed: quota.Consumed,
+		Over:     quota.Over,
+	}, nil
+}
+
+func newQuota(opts []*config.ExprValues) (*expr.Quota, error) {
 	over := false
 	bytes := int64(0)
 	used := int64(0)
@@ -56,11 +80,9 @@ func NewQuota(opts []*config.ExprValues) (*[]expr.Any, error) {
 	if bytes == 0 {
 		return nil, fmt.Errorf("quota bytes cannot be 0")
 	}
-	return &[]expr.Any{
-		&expr.Quota{
-			Bytes:    uint64(bytes),
-			Consumed: uint64(used),
-			Over:     over,
-		},
+	return &expr.Quota{
+		Bytes:    uint64(bytes),
+		Consumed: uint64(used),
+		Over:     over,
 	}, nil
 }
@@ -1,6 +1,7 @@
 package exprs
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/google/gopacket/layers"
@@ -111,6 +112,69 @@ func GetICMPv6Type(icmpType string) uint8 {
 	return 0
 }
 
+// ParseICMPType returns the ICMP or ICMPv6 type of a name (echo-request,
+// destination-unreachable, ...) or a number.
+func ParseICMPType(proto, value string) (uint8, error) {
+	if n, err := strconv.ParseUint(value, 10, 8); err == nil {
+		return uint8(n), nil
+	}
+	icmpType := uint8(0)
+	if proto == NFT_PROTO_ICMPv6 {
+		icmpType = GetICMPv6Type(value)
+	} else {
+		icmpType = GetICMPType(value)
+	}
+	// echo-reply is the only ICMP type 0.
+	if icmpType == 0 && (proto == NFT_PROTO_ICMPv6 || value != ICMP_ECHO_REPLY) {
+		return 0, fmt.Errorf("Invalid %s type: %s", proto, value)
+	}
+	return icmpType, nil
+}
+
+// ParseICMPCode returns the ICMP or ICMPv6 code of a name (port-unreachable,
+// admin-prohibited, ...) or a number.
+func ParseICMPCode(proto, value string) (uint8, error) {
+	if n, err := strconv.ParseUint(value, 10, 8); err == nil {
+		return uint8(n), nil
+	}
+	if proto == NFT_PROTO_ICMPv6 {
+		switch value {
+		case ICMP_NO_ROUTE:
+			return layers.ICMPv6CodeNoRouteToDst, nil
+		case ICMP_ADMIN_PROHIBITED:
+			return layers.ICMPv6CodeAdminProhibited, nil
+		case ICMP_ADDR_UNREACHABLE:
+			return layers.ICMPv6CodeAddressUnreachable, nil
+		case ICMP_PORT_UNREACHABLE:
+			return layers.ICMPv6CodePortUnreachable, nil
+		case ICMP_REJECT_POLICY_FAIL:
+			return layers.ICMPv6CodeSrcAddressFailedPolicy, nil
+		case ICMP_REJECT_ROUTE:
+			return layers.ICMPv6CodeRejectRouteToDst, nil
+		}
+		return 0, fmt.Errorf("Invalid %s code: %s", proto, value)
+	}
+	switch value {
+	case ICMP_NET_UNREACHABLE:
+		return layers.ICMPv4CodeNet, nil
+	case ICMP_HOST_UNREACHABLE:
+		return layers.ICMPv4CodeHost, nil
+	case ICMP_PROT_UNREACHABLE:
+		return layers.ICMPv4CodeProtocol, nil
+	case ICMP_PORT_UNREACHABLE:
+		return layers.ICMPv4CodePort, nil
+	case ICMP_FRAG_NEEDED:
+		return layers.ICMPv4CodeFragmentationNeeded, nil
+	case ICMP_NET_PROHIBITED:
+		return layers.ICMPv4CodeNetAdminProhibited, nil
+	case ICMP_HOST_PROHIBITED:
+		return layers.ICMPv4CodeHostAdminProhibited, nil
+	case ICMP_ADMIN_PROHIBITED:
+		return layers.ICMPv4CodeCommAdminProhibited, nil
+	}
+	return 0, fmt.Errorf("Invalid %s code: %s", proto, value)
+}
+
 // GetICMPv6RejectCode returns the code by its name.
 func GetICMPv6RejectCode(reason string) uint8 {
 	switch reason {
@@ -0,0 +1,53 @@
+package exprs_test
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+)
+
+func TestParseICMP(t *testing.T) {
+	tests := []struct {
+		proto, value string
+		isCode       bool
+		expected     uint8
+		fail         bool
+	}{
+		{exprs.NFT_PROTO_ICMP, exprs.ICMP_ECHO_REPLY, false, 0, false},
+		{exprs.NFT_PROTO_ICMP, exprs.ICMP_DEST_UNREACHABLE, false, 3, false},
+		{exprs.NFT_PROTO_ICMP, "13", false, 13, false},
+		{exprs.NFT_PROTO_ICMP, "xxx", false, 0, true},
+		{exprs.NFT_PROTO_ICMP, "256", false, 0, true},
+		{exprs.NFT_PROTO_ICMPv6, exprs.ICMP_ECHO_REPLY, false, 129, false},
+		{exprs.NFT_PROTO_ICMPv6, exprs.ICMP_NEIGHBOUR_SOLICITATION, false, 135, false},
+		{exprs.NFT_PROTO_ICMPv6, exprs.ICMP_INFO_REQUEST, false, 0, true},
+
+		{exprs.NFT_PROTO_ICMP, exprs.ICMP_PORT_UNREACHABLE, true, 3, false},
+		{exprs.NFT_PROTO_ICMP, exprs.ICMP_ADMIN_PROHIBITED, true, 13, false},
+		{exprs.NFT_PROTO_ICMP, exprs.ICMP_FRAG_NEEDED, true, 4, false},
+		{exprs.NFT_PROTO_ICMP, "1", true, 1, false},
+		{exprs.NFT_PROTO_ICMP, exprs.ICMP_NO_ROUTE, true, 0, true},
+		{exprs.NFT_PROTO_ICMPv6, exprs.ICMP_PORT_UNREACHABLE, true, 4, false},
+		{exprs.NFT_PROTO_ICMPv6, exprs.ICMP_ADMIN_PROHIBITED, true, 1, false},
+		{exprs.NFT_PROTO_ICMPv6, exprs.ICMP_HOST_PROHIBITED, true, 0, true},
+	}
+
+	for _, test := range tests {
+		parse := exprs.ParseICMPType
+		if test.isCode {
+			parse = exprs.ParseICMPCode
+		}
+		val, err := parse(test.proto, test.value)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%s %s (code: %v) should have failed", test.proto, test.value, test.isCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s (code: %v) error: %s", test.proto, test.value, test.isCode, err)
+		} else if val != test.expected {
+			t.Errorf("%s %s (code: %v) = %d, expected %d", test.proto, test.value, test.isCode, val, test.expected)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package nftables
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// Verdict maps of the UDP flows already verdicted, to accept or drop in kernel
+// the following packets of a flow, without queueing them:
+// nft add map inet opensnitch udp_flows4 { type ipv4_addr . inet_service . ipv4_addr . inet_service : verdict; flags timeout; }
+//
+// The packets of a UDP flow are in the ct state new until a reply is received,
+// so every packet sent before is queued (DNS queries, QUIC handshakes, ...).
+// TCP flows don't need it, only their SYN packets are queued.
+const (
+	FlowsMap4 = "udp_flows4"
+	FlowsMap6 = "udp_flows6"
+)
+
+// verdict maps of the UDP flows.
+var flowMaps = &familySetsT{
+	sets: make(map[byte]*nftables.Set),
+}
+
+// addFlowMaps creates the verdict maps of the UDP flows, and returns the rules
+// that apply the verdicts to the packets of these flows.
+//
+// nft --debug=netlink add rule inet opensnitch mangle_output meta nfproto ipv4 meta l4proto udp ip saddr . udp sport . ip daddr . udp dport vmap @udp_flows4
+//
+//	[ meta load nfproto => reg 1 ]
+//	[ cmp eq reg 1 0x00000002 ]
+//	[ meta load l4proto => reg 1 ]
+//	[ cmp eq reg 1 0x00000011 ]
+//	[ payload load 4b @ network header + 12 => reg 1 ]
+//	[ payload load 2b @ transport header + 0 => reg 9 ]
+//	[ payload load 4b @ network header + 16 => reg 10 ]
+//	[ payload load 2b @ transport header + 2 => reg 11 ]
+//	[ lookup reg 1 set udp_flows4 dreg 0 ]
+func (n *Nft) addFlowMaps(table *nftables.Table, chain *nftables.Chain) ([]*nftables.Rule, error) {
+	rules := []*nftables.Rule{}
+	for _, family := range []byte{unix.NFPROTO_IPV4, unix.NFPROTO_IPV6} {
+		name, addrType, addrLen, srcOff, dstOff := FlowsMap4, nftables.TypeIPAddr, uint32(4), uint32(12), uint32(16)
+		if family == unix.NFPROTO_IPV6 {
+			name, addrType, addrLen, srcOff, dstOff = FlowsMap6, nftables.TypeIP6Addr, 16, 8, 24
+		}
+		set := &nftables.Set{
+			Table:         table,
+			Name:          name,
+			IsMap:         true,
+			KeyType:       nftables.MustConcatSetType(addrType, nftables.TypeInetService, addrType, nftables.TypeInetService),
+			DataType:      nftables.TypeVerdict,
+			Concatenation: true,
+			HasTimeout:    true,
+		}
+		if err := n.Conn.AddSet(set, nil); err != nil {
+			return nil, fmt.Errorf("%s map %s, AddSet() error: %s", logTag, name, err)
+		}
+		flowMaps.Add(family, set)
+
+		sportReg := 8 + addrLen/4
+		daddrReg := sportReg + 1
+		dportReg := daddrReg + addrLen/4
+		rules = append(rules, &nftables.Rule{
+			Table: table,
+			Chain: chain,
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_UDP}},
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: srcOff, Len: addrLen},
+				&expr.Payload{DestRegister: sportReg, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 2},
+				&expr.Payload{DestRegister: daddrReg, Base: expr.PayloadBaseNetworkHeader, Offset: dstOff, Len: addrLen},
+				&expr.Payload{DestRegister: dportReg, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+				&expr.Lookup{SourceRegister: 1, DestRegister: 0, IsDestRegSet: true, SetName: set.Name, SetID: set.ID},
+			},
+			// not an interception rule, it doesn't send packets to the queue.
+			UserData: []byte(FlowsRuleKey),
+		})
+	}
+	return rules, nil
+}
+
+// loadFlowMaps gets the verdict maps of the UDP flows added by a previous
+// instance of the daemon.
+func (n *Nft) loadFlowMaps(table *nftables.Table) {
+	for family, name := range map[byte]string{unix.NFPROTO_IPV4: FlowsMap4, unix.NFPROTO_IPV6: FlowsMap6} {
+		set, err := n.Conn.GetSetByName(table, name)
+		if err != nil {
+			log.Debug("%s map %s not found: %s", logTag, name, err)
+			continue
+		}
+		flowMaps.Add(family, set)
+	}
+}
+
+// delFlowMaps deletes the verdict maps of the UDP flows.
+// The rules using the maps must be deleted before.
+func (n *Nft) delFlowMaps() {
+	deleted := false
+	for _, set := range flowMaps.Reset() {
+		n.Conn.DelSet(set)
+		deleted = true
+	}
+	if deleted && !n.Commit() {
+		log.Debug("%s error deleting the maps of the UDP flows", logTag)
+	}
+}
+
+// AddFlow accepts or drops in kernel, for the given time, the following
+// packets of a UDP flow already verdicted.
+func (n *Nft) AddFlow(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint, accept bool, timeout time.Duration) error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s AddFlow: netlink connection not active", logTag)
+	}
+	family, key := tupleKey(srcIP, srcPort, dstIP, dstPort)
+	set := flowMaps.Get(family)
+	if set == nil {
+		return fmt.Errorf("%s map of UDP flows not found (family %d)", logTag, family)
+	}
+	verdict := &expr.Verdict{Kind: expr.VerdictDrop}
+	if accept {
+		verdict.Kind = expr.VerdictAccept
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	if err := n.Conn.SetAddElements(set, []nftables.SetElement{{Key: key, VerdictData: verdict, Timeout: timeout}}); err != nil {
+		return err
+	}
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding element to %s", logTag, set.Name)
+	}
+	return nil
+}
+
+// FlushFlows deletes the UDP flows verdicted, when the rules change, so the
+// following packets are queued and evaluated again.
+func (n *Nft) FlushFlows() error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s FlushFlows: netlink connection not active", logTag)
+	}
+	n.Lock()
+	defer n.Unlock()
+	flushed := false
+	for _, family := range []byte{unix.NFPROTO_IPV4, unix.NFPROTO_IPV6} {
+		if set := flowMaps.Get(family); set != nil {
+			n.Conn.FlushSet(set)
+			flushed = true
+		}
+	}
+	if flushed && !n.Commit() {
+		return fmt.Errorf("%s error flushing the maps of the UDP flows", logTag)
+	}
+	return nil
+}
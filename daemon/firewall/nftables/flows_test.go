@@ -0,0 +1,96 @@
+package nftables_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	nftb "github.com/evilsocket/opensnitch/daemon/firewall/nftables"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+func TestFlows(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+	nftest.Fw.Conn = conn
+
+	tbl, err := nftest.Fw.AddTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if err != nil {
+		t.Error("pre step add_table() opensnitch-inet failed")
+	}
+	chn := nftest.Fw.AddChain(
+		exprs.CHAIN_MANGLE_OUTPUT, exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET,
+		nftables.ChainPriorityFilter,
+		nftables.ChainTypeFilter,
+		nftables.ChainHookOutput,
+		nftables.ChainPolicyAccept)
+	if chn == nil {
+		t.Error("pre step add_chain() mangle_output-opensnitch-inet failed")
+	}
+	if err1, err2 := nftest.Fw.QueueConnections(true, true); err1 != nil && err2 != nil {
+		t.Fatalf("rule to queue connections not added: %s, %s", err1, err2)
+	}
+
+	// the rules applying the verdicts of the flows go before the queue rule.
+	rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT)
+	flowsIdx, queueIdx := -1, -1
+	for i, r := range rules {
+		switch string(r.UserData) {
+		case nftb.FlowsRuleKey:
+			flowsIdx = i
+		case nftb.InterceptionRuleKey:
+			if queueIdx == -1 {
+				queueIdx = i
+			}
+		}
+	}
+	if flowsIdx == -1 || flowsIdx > queueIdx {
+		t.Fatalf("flows rules not added before the queue rules: %d, %d", flowsIdx, queueIdx)
+	}
+
+	if err := nftest.Fw.AddFlow(net.ParseIP("192.168.1.10"), 41234, net.ParseIP("9.9.9.9"), 53, true, time.Minute); err != nil {
+		t.Fatal("AddFlow() error:", err)
+	}
+	if err := nftest.Fw.AddFlow(net.ParseIP("fd00::10"), 41234, net.ParseIP("2620:fe::fe"), 443, false, time.Minute); err != nil {
+		t.Fatal("AddFlow() IPv6 error:", err)
+	}
+
+	set4, err := conn.GetSetByName(tbl, nftb.FlowsMap4)
+	if err != nil {
+		t.Fatal("map of IPv4 flows not found:", err)
+	}
+	elems, err := conn.GetSetElements(set4)
+	if err != nil || len(elems) != 1 {
+		t.Fatalf("invalid IPv4 flows: %v, %s", elems, err)
+	}
+	if elems[0].VerdictData == nil || elems[0].VerdictData.Kind != expr.VerdictAccept {
+		t.Errorf("invalid verdict of the IPv4 flow: %v", elems[0].VerdictData)
+	}
+	set6, err := conn.GetSetByName(tbl, nftb.FlowsMap6)
+	if err != nil {
+		t.Fatal("map of IPv6 flows not found:", err)
+	}
+	if elems, err = conn.GetSetElements(set6); err != nil || len(elems) != 1 {
+		t.Fatalf("invalid IPv6 flows: %v, %s", elems, err)
+	}
+
+	if err := nftest.Fw.FlushFlows(); err != nil {
+		t.Fatal("FlushFlows() error:", err)
+	}
+	if elems, _ = conn.GetSetElements(set4); len(elems) != 0 {
+		t.Errorf("flows not flushed: %v", elems)
+	}
+
+	nftest.Fw.DelInterceptionRules()
+	if r, _ := getRule(t, conn, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT, nftb.FlowsRuleKey, 0); r != nil {
+		t.Error("flows rules not deleted")
+	}
+	if _, err := conn.GetSetByName(tbl, nftb.FlowsMap4); err == nil {
+		t.Error("map of IPv4 flows not deleted")
+	}
+}
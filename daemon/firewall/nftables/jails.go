@@ -0,0 +1,135 @@
+package nftables
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+)
+
+// The new connections of the cgroup of a jail are filtered by a chain of the
+// jail, before queueing them. Only the ones to the loopback interface and to
+// the allowed destinations are let through:
+//
+// nft add chain inet opensnitch jail-1a2b3c4d
+// nft add rule inet opensnitch jail-1a2b3c4d oifname "lo" return
+// nft add rule inet opensnitch jail-1a2b3c4d meta nfproto ipv4 ip daddr 192.168.1.0/24 return
+// nft add rule inet opensnitch jail-1a2b3c4d drop
+// nft insert rule inet opensnitch mangle_output ct state new socket cgroupv2 level 2 "opensnitch/jail-1a2b3c4d" jump jail-1a2b3c4d
+
+// AddJail adds the chain of a jail, replacing it if it already exists.
+func (n *Nft) AddJail(j *common.Jail) error {
+	n.Lock()
+	defer n.Unlock()
+	if n.jails == nil {
+		n.jails = make(map[string]*common.Jail)
+	}
+	n.jails[j.ID()] = j
+	return n.addJailRules(j)
+}
+
+// DelJail deletes the chain of a jail.
+func (n *Nft) DelJail(j *common.Jail) error {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.jails, j.ID())
+	return n.delJailRules(j.ID())
+}
+
+// restoreJailRules adds again the chains of the jails, after adding the
+// interception rules.
+func (n *Nft) restoreJailRules() error {
+	n.Lock()
+	defer n.Unlock()
+	for _, j := range n.jails {
+		if err := n.addJailRules(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanJailRules deletes the chains of all the jails, keeping them to add
+// them again.
+func (n *Nft) cleanJailRules() {
+	n.Lock()
+	defer n.Unlock()
+	for id := range n.jails {
+		n.delJailRules(id)
+	}
+}
+
+func (n *Nft) addJailRules(j *common.Jail) error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s jail %s: netlink connection not active", logTag, j.Path)
+	}
+	table := n.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	output := GetChain(exprs.CHAIN_MANGLE_OUTPUT, table)
+	if table == nil || output == nil {
+		return fmt.Errorf("%s jail %s: interception chain mangle_output not found", logTag, j.Path)
+	}
+	// the allowed destinations may have changed.
+	if err := n.delJailRules(j.ID()); err != nil {
+		return err
+	}
+
+	key := JailRuleKey + "-" + j.ID()
+	chain := n.Conn.AddChain(&nftables.Chain{
+		Name:  j.ID(),
+		Table: table,
+	})
+	addRule := func(e ...expr.Any) {
+		n.Conn.AddRule(&nftables.Rule{
+			Table:    table,
+			Chain:    chain,
+			Exprs:    e,
+			UserData: []byte(key),
+		})
+	}
+	addRule(append(*exprs.NewExprIface("lo", true, expr.CmpOpEq), &expr.Verdict{Kind: expr.VerdictReturn})...)
+	for _, dst := range j.Allowed {
+		addRule(append(daddrExprs(dst), &expr.Verdict{Kind: expr.VerdictReturn})...)
+	}
+	addRule(&expr.Verdict{Kind: expr.VerdictDrop})
+
+	jump := append(ctStateNewExprs(),
+		&expr.Socket{Key: expr.SocketKeyCgroupv2, Level: j.Level(), Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.NativeEndian.PutUint64(j.CgroupID)},
+		&expr.Verdict{Kind: expr.VerdictJump, Chain: j.ID()},
+	)
+	n.Conn.InsertRule(&nftables.Rule{
+		Position: 0,
+		Table:    table,
+		Chain:    output,
+		Exprs:    jump,
+		UserData: []byte(key),
+	})
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding the rules of the jail %s", logTag, j.Path)
+	}
+	return nil
+}
+
+// delJailRules deletes the rules of a jail, and its chain.
+func (n *Nft) delJailRules(id string) error {
+	if err := n.delRulesByKey(JailRuleKey + "-" + id); err != nil {
+		return err
+	}
+	chains, err := n.Conn.ListChains()
+	if err != nil {
+		return fmt.Errorf("%s jail, error listing chains: %s", logTag, err)
+	}
+	for _, c := range chains {
+		if c.Name != id || c.Table.Name != exprs.TABLE_OPENSNITCH {
+			continue
+		}
+		n.Conn.DelChain(c)
+		if !n.Commit() {
+			return fmt.Errorf("%s error deleting the chain of the jail %s", logTag, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package nftables_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+	nftb "github.com/evilsocket/opensnitch/daemon/firewall/nftables"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+	"github.com/google/nftables"
+)
+
+func TestJails(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+	nftest.Fw.Conn = conn
+
+	_, err := nftest.Fw.AddTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if err != nil {
+		t.Error("pre step add_table() opensnitch-inet failed")
+	}
+	chn := nftest.Fw.AddChain(
+		exprs.CHAIN_MANGLE_OUTPUT, exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET,
+		nftables.ChainPriorityFilter,
+		nftables.ChainTypeFilter,
+		nftables.ChainHookOutput,
+		nftables.ChainPolicyAccept)
+	if chn == nil {
+		t.Error("pre step add_chain() mangle_output-opensnitch-inet failed")
+	}
+
+	_, net4, _ := net.ParseCIDR("192.168.1.0/24")
+	jail := &common.Jail{
+		Path:     "/usr/bin/curl",
+		Cgroup:   "opensnitch/" + common.JailID("/usr/bin/curl"),
+		CgroupID: 1234,
+		Allowed:  []*net.IPNet{net4},
+	}
+	if err := nftest.Fw.AddJail(jail); err != nil {
+		t.Fatal("AddJail() error:", err)
+	}
+	// adding it again replaces the rules.
+	if err := nftest.Fw.AddJail(jail); err != nil {
+		t.Fatal("AddJail() error:", err)
+	}
+
+	key := nftb.JailRuleKey + "-" + jail.ID()
+	rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT)
+	if len(rules) != 1 || string(rules[0].UserData) != key {
+		t.Fatal("jail rule not added to mangle_output")
+	}
+	// loopback, allowed destinations and drop.
+	rules, _ = getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, jail.ID())
+	if len(rules) != 3 {
+		t.Errorf("invalid number of jail rules: %d, expected 3", len(rules))
+	}
+
+	if err := nftest.Fw.DelJail(jail); err != nil {
+		t.Fatal("DelJail() error:", err)
+	}
+	if r, _ := getRule(t, conn, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT, key, 0); r != nil {
+		t.Error("jail rule not deleted")
+	}
+	if _, idx := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, jail.ID()); idx != -1 {
+		t.Error("jail chain not deleted")
+	}
+}
@@ -0,0 +1,149 @@
+package nftables
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/config"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+// store of named maps added to the system
+type sysMapsT struct {
+	maps map[string]*nftables.Set
+	sync.RWMutex
+}
+
+func (m *sysMapsT) Add(key string, set *nftables.Set) {
+	m.Lock()
+	defer m.Unlock()
+	m.maps[key] = set
+}
+
+func (m *sysMapsT) Get(key string) *nftables.Set {
+	m.RLock()
+	defer m.RUnlock()
+	return m.maps[key]
+}
+
+// Reset returns the maps added, and empties the store.
+func (m *sysMapsT) Reset() map[string]*nftables.Set {
+	m.Lock()
+	defer m.Unlock()
+	maps := m.maps
+	m.maps = make(map[string]*nftables.Set)
+	return maps
+}
+
+func getMapKey(name, table, family string) string {
+	return fmt.Sprint(name, "-", getTableKey(table, family))
+}
+
+// AddSystemMap creates a named map or verdict map, with its elements.
+// nft add map inet opensnitch input_ports { type inet_service : verdict; }
+func (n *Nft) AddSystemMap(fwMap *config.FwMap) error {
+	if fwMap.IsInvalid() {
+		return fmt.Errorf("%s map fields Name, Table, Family, KeyType and DataType cannot be empty", logTag)
+	}
+	keyType, err := exprs.GetMapDatatype(fwMap.KeyType)
+	if err != nil {
+		return fmt.Errorf("%s map %s: %s", logTag, fwMap.Name, err)
+	}
+	dataType, err := exprs.GetMapDatatype(fwMap.DataType)
+	if err != nil {
+		return fmt.Errorf("%s map %s: %s", logTag, fwMap.Name, err)
+	}
+	elements, err := exprs.NewMapElements(keyType, dataType, fwMap.Elements)
+	if err != nil {
+		return fmt.Errorf("%s map %s: %s", logTag, fwMap.Name, err)
+	}
+
+	tbl, err := n.AddTable(fwMap.Table, fwMap.Family)
+	if err != nil {
+		return err
+	}
+	set := &nftables.Set{
+		Table:    tbl,
+		Name:     fwMap.Name,
+		IsMap:    true,
+		KeyType:  keyType,
+		DataType: dataType,
+	}
+	if err := n.Conn.AddSet(set, elements); err != nil {
+		return fmt.Errorf("%s map %s, AddSet() error: %s", logTag, fwMap.Name, err)
+	}
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding map %s (%s, %s)", logTag, fwMap.Name, fwMap.Table, fwMap.Family)
+	}
+	sysMaps.Add(getMapKey(fwMap.Name, fwMap.Table, fwMap.Family), set)
+
+	return nil
+}
+
+// GetSystemMap returns a map previously added with AddSystemMap().
+func (n *Nft) GetSystemMap(name, table, family string) *nftables.Set {
+	return sysMaps.Get(getMapKey(name, table, family))
+}
+
+// flushSystemMaps deletes the elements of the maps added, in order to delete
+// the chains referenced by the verdict maps (jump, goto).
+func (n *Nft) flushSystemMaps() {
+	sysMaps.RLock()
+	defer sysMaps.RUnlock()
+	if len(sysMaps.maps) == 0 {
+		return
+	}
+	for _, set := range sysMaps.maps {
+		n.Conn.FlushSet(set)
+	}
+	if !n.Commit() {
+		log.Warning("%s error flushing system maps", logTag)
+	}
+}
+
+// delSystemMaps deletes the maps added.
+// The rules using the maps must be deleted before.
+func (n *Nft) delSystemMaps() {
+	for key, set := range sysMaps.Reset() {
+		n.Conn.DelSet(set)
+		if !n.Commit() {
+			log.Warning("%s error deleting system map: %s", logTag, key)
+		}
+	}
+}
+
+// buildMapRule helper builds a new rule to lookup a key in a map:
+// "Name": "vmap",
+// "Values": [ {"Key": "dport", "Value": "input_ports"} ]
+//
+// nft --debug=netlink add rule inet opensnitch filter_input tcp dport vmap @input_ports
+//
+//	inet opensnitch filter_input
+//	  [ payload load 2b @ transport header + 2 => reg 1 ]
+//	  [ lookup reg 1 set input_ports dreg 0 ]
+func (n *Nft) buildMapRule(table, family string, statement *config.ExprStatement) (*[]expr.Any, error) {
+	if len(statement.Values) != 1 {
+		return nil, fmt.Errorf("%s statement requires one key and the name of the map", statement.Name)
+	}
+	key := statement.Values[0].Key
+	mapName := statement.Values[0].Value
+	set := n.GetSystemMap(mapName, table, family)
+	if set == nil {
+		return nil, fmt.Errorf("map not found: %s (%s, %s)", mapName, table, family)
+	}
+
+	exprList, err := exprs.NewExprMapKey(family, key, set.KeyType)
+	if err != nil {
+		return nil, err
+	}
+	exprLookup, err := exprs.NewExprMapLookup(statement.Name, set)
+	if err != nil {
+		return nil, err
+	}
+	*exprList = append(*exprList, *exprLookup...)
+
+	return exprList, nil
+}
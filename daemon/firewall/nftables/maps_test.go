@@ -0,0 +1,54 @@
+package nftables_test
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+)
+
+func TestAddSystemMaps(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+	nftest.Fw.Conn = conn
+
+	cfg, err := nftest.Fw.NewSystemFwConfig(configFile, nftest.Fw.PreloadConfCallback, nftest.Fw.ReloadConfCallback)
+	if err != nil {
+		t.Logf("Error creating fw config: %s", err)
+	}
+
+	cfg.SetConfigFile("./testdata/test-sysfw-maps.json")
+	if err := cfg.LoadDiskConfiguration(false); err != nil {
+		t.Errorf("Error loading config from disk: %s", err)
+	}
+
+	nftest.Fw.AddSystemRules(false, false)
+
+	set := nftest.Fw.GetSystemMap("input_ports", exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if set == nil {
+		t.Fatal("map input_ports not added")
+	}
+	elements, err := conn.GetSetElements(set)
+	if err != nil || len(elements) != 2 {
+		t.Errorf("map input_ports should contain 2 elements, got %d: %v", len(elements), err)
+	}
+	rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, exprs.CHAIN_FILTER_INPUT)
+	if len(rules) != 1 {
+		t.Errorf("filter_input should contain 1 rule, got %d", len(rules))
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		nftest.Fw.DeleteSystemRules(false, false, true)
+		if nftest.Fw.GetSystemMap("input_ports", exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET) != nil {
+			t.Error("map input_ports not deleted")
+		}
+		sets, _ := conn.GetSets(nftest.Fw.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET))
+		for _, s := range sets {
+			if s.Name == "input_ports" {
+				t.Error("map input_ports still exists")
+			}
+		}
+	})
+}
@@ -6,10 +6,140 @@ import (
 	"github.com/evilsocket/opensnitch/daemon/firewall/common"
 	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
 	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
 )
 
-// AreRulesLoaded checks if the firewall rules for intercept traffic are loaded.
+// StartMonitor listens for changes of the nftables ruleset, in order to check
+// our rules as soon as other program (firewalld, docker, nft flush ruleset...)
+// modifies them, instead of waiting for the next check.
+func (n *Nft) StartMonitor() {
+	n.StopMonitor()
+	if n.RulesCheckInterval.String() == common.RulesCheckerDisabled {
+		return
+	}
+
+	mon := nftables.NewMonitor(
+		nftables.WithMonitorAction(nftables.MonitorActionAny),
+		nftables.WithMonitorObject(nftables.MonitorObjectTables|nftables.MonitorObjectChains|nftables.MonitorObjectRules),
+	)
+	events, err := NewNft().AddMonitor(mon)
+	if err != nil {
+		log.Warning("%s unable to monitor firewall changes, checking the rules every %s: %s", logTag, n.RulesCheckInterval, err)
+		return
+	}
+	n.monitorLock.Lock()
+	n.monitor = mon
+	n.monitorLock.Unlock()
+
+	go func() {
+		// the channel is closed when the monitor is closed.
+		for ev := range events {
+			if ev.Error != nil {
+				log.Debug("%s monitor error: %s", logTag, ev.Error)
+				continue
+			}
+			if affectsRules(ev) {
+				n.CheckRulesNow()
+			}
+		}
+		log.Debug("%s monitor stopped", logTag)
+	}()
+}
+
+// StopMonitor stops listening for changes of the ruleset.
+func (n *Nft) StopMonitor() {
+	n.monitorLock.Lock()
+	defer n.monitorLock.Unlock()
+	if n.monitor != nil {
+		n.monitor.Close()
+		n.monitor = nil
+	}
+}
+
+// affectsRules returns true if the event modifies the table where the
+// interception rules are, or deletes any table, chain or rule (the system rules
+// may be in tables shared with other programs).
+func affectsRules(ev *nftables.MonitorEvent) bool {
+	var table *nftables.Table
+	switch data := ev.Data.(type) {
+	case *nftables.Table:
+		table = data
+	case *nftables.Chain:
+		table = data.Table
+	case *nftables.Rule:
+		table = data.Table
+	}
+	switch ev.Type {
+	case nftables.MonitorEventTypeDelTable, nftables.MonitorEventTypeDelChain, nftables.MonitorEventTypeDelRule:
+		return true
+	}
+	return table != nil && table.Name == exprs.TABLE_OPENSNITCH
+}
+
+// queueNum returns the queue number of a rule, if it sends packets to a queue.
+func queueNum(r *nftables.Rule) (uint16, bool) {
+	for _, e := range r.Exprs {
+		if q, ok := e.(*expr.Queue); ok {
+			return q.Num, true
+		}
+	}
+	return 0, false
+}
+
+// missingSystemChains returns the chains of the system firewall configuration
+// that were added, but that are no longer loaded.
+func (n *Nft) missingSystemChains(chains []*nftables.Chain) []string {
+	loaded := make(map[string]bool, len(chains))
+	for _, c := range chains {
+		loaded[getChainKey(c.Name, c.Table)] = true
+	}
+
+	n.SysConfig.RLock()
+	defer n.SysConfig.RUnlock()
+	if !n.SysConfig.Enabled {
+		return nil
+	}
+	var missing []string
+	for _, fwCfg := range n.SysConfig.SystemRules {
+		for _, chain := range fwCfg.Chains {
+			if chain.IsInvalid() {
+				continue
+			}
+			key := getChainKey(chain.Name, &nftables.Table{Name: chain.Table, Family: GetFamilyCode(chain.Family)})
+			if _, added := sysChains.Load(key); added && !loaded[key] {
+				missing = append(missing, key)
+			}
+		}
+	}
+	return missing
+}
+
+// AreSystemRulesLoaded checks if the chains of the system firewall are loaded.
+func (n *Nft) AreSystemRulesLoaded() bool {
+	n.Lock()
+	chains, err := n.Conn.ListChains()
+	n.Unlock()
+	if err != nil {
+		log.Warning("[nftables] error listing nftables chains: %s", err)
+		return false
+	}
+	if missing := n.missingSystemChains(chains); len(missing) > 0 {
+		log.Warning("nftables system chains not loaded: %v", missing)
+		return false
+	}
+	return true
+}
+
+// AreRulesLoaded checks if the firewall rules for intercept traffic are loaded,
+// and that they send the packets to our queues.
 func (n *Nft) AreRulesLoaded() bool {
+	if !n.AreSystemRulesLoaded() {
+		return false
+	}
+	qNum := n.QueueNum
+	dnsQNum, _ := n.GetDNSQueue(n.bypassQueue)
+
 	n.Lock()
 	defer n.Unlock()
 
@@ -40,6 +170,14 @@ func (n *Nft) AreRulesLoaded() bool {
 					log.Warning("nftables queue rule is not the latest of the list (%d/%d), reloading", rdx, len(rules))
 					return false
 				}
+				expected := qNum
+				if c.Name == exprs.CHAIN_FILTER_INPUT {
+					expected = dnsQNum
+				}
+				if num, found := queueNum(r); !found || num != expected {
+					log.Warning("nftables interception rule modified, queue %d, expected %d", num, expected)
+					return false
+				}
 			}
 		}
 	}
@@ -63,6 +201,9 @@ func (n *Nft) ReloadConfCallback() {
 // ReloadRulesCallback gets called when the interception rules are not present.
 func (n *Nft) ReloadRulesCallback() {
 	log.Important("nftables firewall rules changed, reloading")
+	if !n.AreSystemRulesLoaded() {
+		n.ReloadConfCallback()
+	}
 	n.DisableInterception(log.GetLogLevel() == log.DEBUG)
 	time.Sleep(time.Millisecond * 500)
 	n.EnableInterception()
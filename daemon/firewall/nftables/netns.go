@@ -0,0 +1,83 @@
+package nftables
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+// InterceptNetNS adds the interception rules of the connections to a network
+// namespace (a container, usually), queueing them to the queue qNum of that
+// namespace.
+// It uses its own netlink connection, bound to the namespace, and doesn't
+// cache the table and chain added, which are not the ones of the daemon.
+// The rules are replaced if they already exist.
+func InterceptNetNS(nsFd int, qNum uint16, bypass bool) error {
+	conn, err := nftables.New(nftables.WithNetNSFd(nsFd))
+	if err != nil {
+		return fmt.Errorf("%s netns connection: %s", logTag, err)
+	}
+	policy := nftables.ChainPolicyAccept
+	tbl := conn.AddTable(&nftables.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   exprs.TABLE_OPENSNITCH,
+	})
+	chain := &nftables.Chain{
+		Name:     exprs.CHAIN_MANGLE_OUTPUT,
+		Table:    tbl,
+		Hooknum:  nftables.ChainHookOutput,
+		Priority: nftables.ChainPriorityMangle,
+		Type:     nftables.ChainTypeRoute,
+		Policy:   &policy,
+	}
+	conn.AddChain(chain)
+	if err := conn.Flush(); err != nil {
+		log.Debug("%s netns: error adding chain %s with type route, trying with type filter: %s", logTag, chain.Name, err)
+
+		// @see AddInterceptionChains()
+		chain.Priority, chain.Type = GetChainPriority(exprs.NFT_FAMILY_INET, exprs.NFT_CHAIN_MANGLE, exprs.NFT_HOOK_OUTPUT)
+		conn.AddTable(tbl)
+		conn.AddChain(chain)
+		if err := conn.Flush(); err != nil {
+			return fmt.Errorf("%s netns: error adding chain %s: %s", logTag, chain.Name, err)
+		}
+	}
+
+	queue := &expr.Queue{Num: qNum}
+	if bypass {
+		queue.Flag = expr.QueueFlagBypass
+	}
+	conn.FlushChain(chain)
+	for _, ruleExprs := range connectionsQueueExprs(queue) {
+		conn.AddRule(&nftables.Rule{
+			Table:    tbl,
+			Chain:    chain,
+			Exprs:    ruleExprs,
+			UserData: []byte(InterceptionRuleKey),
+		})
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("%s netns: error adding interception rules: %s", logTag, err)
+	}
+	return nil
+}
+
+// RemoveNetNSInterception deletes the interception rules of a network
+// namespace, added by InterceptNetNS().
+func RemoveNetNSInterception(nsFd int) error {
+	conn, err := nftables.New(nftables.WithNetNSFd(nsFd))
+	if err != nil {
+		return fmt.Errorf("%s netns connection: %s", logTag, err)
+	}
+	conn.DelTable(&nftables.Table{
+		Family: nftables.TableFamilyINet,
+		Name:   exprs.TABLE_OPENSNITCH,
+	})
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("%s netns: error deleting the interception table: %s", logTag, err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package nftables_test
+
+import (
+	"testing"
+
+	nftb "github.com/evilsocket/opensnitch/daemon/firewall/nftables"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+)
+
+func TestInterceptNetNS(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+
+	for i := 0; i < 2; i++ {
+		if err := nftb.InterceptNetNS(int(newNS), 0, true); err != nil {
+			t.Fatal("InterceptNetNS() error:", err)
+		}
+		rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT)
+		if len(rules) != 2 {
+			t.Fatalf("unexpected number of interception rules (%d): %d", i, len(rules))
+		}
+	}
+
+	if err := nftb.RemoveNetNSInterception(int(newNS)); err != nil {
+		t.Fatal("RemoveNetNSInterception() error:", err)
+	}
+	tables, _ := conn.ListTables()
+	for _, tbl := range tables {
+		if tbl.Name == exprs.TABLE_OPENSNITCH {
+			t.Error("interception table not deleted")
+		}
+	}
+}
@@ -3,12 +3,14 @@ package nftables
 import (
 	"bytes"
 	"encoding/json"
+	"net"
 	"strings"
 	"sync"
 
 	"github.com/evilsocket/opensnitch/daemon/firewall/common"
 	"github.com/evilsocket/opensnitch/daemon/firewall/config"
 	"github.com/evilsocket/opensnitch/daemon/firewall/iptables"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 	"github.com/golang/protobuf/jsonpb"
@@ -23,6 +25,12 @@ const (
 	fwKey               = "opensnitch-key"
 	InterceptionRuleKey = fwKey + "-interception"
 	SystemRuleKey       = fwKey + "-system"
+	RetransmitRuleKey   = fwKey + "-retransmit"
+	FlowsRuleKey        = fwKey + "-flows"
+	PanicRuleKey        = fwKey + "-panic"
+	JailRuleKey         = fwKey + "-jail"
+	DenyPageRuleKey     = fwKey + "-denypage"
+	ProxyRuleKey        = fwKey + "-proxy"
 	Name                = "nftables"
 )
 
@@ -32,6 +40,27 @@ type Nft struct {
 	chains      iptables.SystemChains
 	bypassQueue bool
 
+	// monitor of the changes of the ruleset.
+	monitor     *nftables.Monitor
+	monitorLock sync.Mutex
+
+	// destinations allowed in panic mode.
+	panicAllowed []*net.IPNet
+	panicMode    bool
+
+	// jails of the binaries, by ID.
+	jails map[string]*common.Jail
+
+	// port of the deny page, 0 if it's disabled, and mark of the connections
+	// redirected to it.
+	denyPagePort uint16
+	denyPageMark uint32
+
+	// port of the forwarder of the proxy rules, 0 if it's disabled, and mark
+	// of the connections redirected to it.
+	proxyPort uint16
+	proxyMark uint32
+
 	common.Common
 	config.Config
 	sync.Mutex
@@ -76,10 +105,27 @@ func (n *Nft) Init(qNum uint16, configPath, monitorInterval string, bypassQueue
 	n.NewSystemFwConfig(configPath, n.PreloadConfCallback, n.ReloadConfCallback)
 	n.LoadDiskConfiguration(!common.ReloadConf)
 
+	// the rules of the previous instance of the daemon are still loaded,
+	// reuse them to not stop intercepting connections while upgrading.
+	if common.KeepRules && n.AreRulesLoaded() {
+		log.Info("%s reusing the interception rules of the previous instance", logTag)
+		n.AddInterceptionTables()
+		n.AddInterceptionChains()
+		n.loadRetransmitSets(n.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET))
+		n.loadFlowMaps(n.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET))
+		n.NewRulesChecker(n.AreRulesLoaded, n.ReloadRulesCallback)
+		n.StartMonitor()
+		n.Running = true
+		return
+	}
+
 	// start from a clean state
 	// The daemon may have exited unexpectedly, leaving residual fw rules, so we
 	// need to clean them up to avoid duplicated rules.
 	n.DelInterceptionRules()
+	n.delPanicRules()
+	n.delDenyPageRules()
+	n.delProxyRules()
 	n.AddSystemRules(!common.ReloadRules, common.BackupChains)
 	n.EnableInterception()
 
@@ -93,6 +139,7 @@ func (n *Nft) Stop() {
 		return
 	}
 	n.StopConfigWatcher()
+	n.StopMonitor()
 	n.StopCheckingRules()
 	n.CleanRules(log.GetLogLevel() == log.DEBUG)
 
@@ -118,8 +165,21 @@ func (n *Nft) EnableInterception() {
 	if err, _ := n.QueueConnections(common.EnableRule, common.EnableRule); err != nil {
 		log.Error("Error while running conntrack nftables rule: %s", err)
 	}
+	if err := n.restorePanicRules(); err != nil {
+		log.Error("Error while adding panic mode rules: %s", err)
+	}
+	if err := n.restoreJailRules(); err != nil {
+		log.Error("Error while adding jails rules: %s", err)
+	}
+	if err := n.restoreDenyPageRules(); err != nil {
+		log.Error("Error while adding deny page rules: %s", err)
+	}
+	if err := n.restoreProxyRules(); err != nil {
+		log.Error("Error while adding proxy rules: %s", err)
+	}
 	// start monitoring firewall rules to intercept network traffic.
 	n.NewRulesChecker(n.AreRulesLoaded, n.ReloadRulesCallback)
+	n.StartMonitor()
 }
 
 // DisableInterception removes firewall rules to intercept outbound connections.
@@ -131,6 +191,10 @@ func (n *Nft) DisableInterception(logErrors bool) {
 // CleanRules deletes the rules we added.
 func (n *Nft) CleanRules(logErrors bool) {
 	n.DisableInterception(logErrors)
+	n.delPanicRules()
+	n.cleanJailRules()
+	n.delDenyPageRules()
+	n.delProxyRules()
 	n.DeleteSystemRules(common.ForcedDelRules, common.RestoreChains, logErrors)
 }
 
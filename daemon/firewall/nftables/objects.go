@@ -0,0 +1,196 @@
+package nftables
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/common"
+	"github.com/evilsocket/opensnitch/daemon/firewall/config"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+// sysObject is a named object added to the system.
+type sysObject struct {
+	cfg *config.FwObject
+	// nil for the limits, which are not supported as named objects by the
+	// nftables lib. Their definition is copied to the rules that reference them.
+	obj nftables.Obj
+}
+
+// store of named objects added to the system
+type sysObjectsT struct {
+	objs map[string]*sysObject
+	sync.RWMutex
+}
+
+func (o *sysObjectsT) Add(key string, obj *sysObject) {
+	o.Lock()
+	defer o.Unlock()
+	o.objs[key] = obj
+}
+
+func (o *sysObjectsT) Get(key string) *sysObject {
+	o.RLock()
+	defer o.RUnlock()
+	return o.objs[key]
+}
+
+// Reset returns the objects added, and empties the store.
+func (o *sysObjectsT) Reset() map[string]*sysObject {
+	o.Lock()
+	defer o.Unlock()
+	objs := o.objs
+	o.objs = make(map[string]*sysObject)
+	return objs
+}
+
+func getObjectKey(name, table, family string) string {
+	return fmt.Sprint(name, "-", getTableKey(table, family))
+}
+
+// AddSystemObject creates a named counter, quota or limit.
+// nft add quota inet opensnitch http_quota { over 500 mbytes }
+func (n *Nft) AddSystemObject(fwObj *config.FwObject) error {
+	if fwObj.IsInvalid() {
+		return fmt.Errorf("%s object fields Name, Table, Family and Type cannot be empty", logTag)
+	}
+	tbl, err := n.AddTable(fwObj.Table, fwObj.Family)
+	if err != nil {
+		return err
+	}
+
+	var obj nftables.Obj
+	switch fwObj.Type {
+	case exprs.NFT_COUNTER:
+		obj = &nftables.CounterObj{Table: tbl, Name: fwObj.Name}
+	case exprs.NFT_QUOTA:
+		quota, err := exprs.NewQuotaObj(tbl, fwObj.Name, fwObj.Values)
+		if err != nil {
+			return fmt.Errorf("%s object %s: %s", logTag, fwObj.Name, err)
+		}
+		obj = quota
+	case exprs.NFT_LIMIT:
+		if _, err := exprs.NewExprLimit(objectStatement(fwObj)); err != nil {
+			return fmt.Errorf("%s object %s: %s", logTag, fwObj.Name, err)
+		}
+	default:
+		return fmt.Errorf("%s object %s, invalid type: %s", logTag, fwObj.Name, fwObj.Type)
+	}
+
+	if obj != nil {
+		n.Conn.AddObj(obj)
+		if !n.Commit() {
+			return fmt.Errorf("%s error adding %s %s (%s, %s)", logTag, fwObj.Type, fwObj.Name, fwObj.Table, fwObj.Family)
+		}
+	}
+	sysObjects.Add(getObjectKey(fwObj.Name, fwObj.Table, fwObj.Family), &sysObject{cfg: fwObj, obj: obj})
+
+	return nil
+}
+
+// GetSystemObject returns the definition of an object previously added with
+// AddSystemObject().
+func (n *Nft) GetSystemObject(name, table, family string) *config.FwObject {
+	if o := sysObjects.Get(getObjectKey(name, table, family)); o != nil {
+		return o.cfg
+	}
+	return nil
+}
+
+// delSystemObjects deletes the objects added.
+// The rules referencing the objects must be deleted before.
+func (n *Nft) delSystemObjects() {
+	for key, o := range sysObjects.Reset() {
+		if o.obj == nil {
+			continue
+		}
+		n.Conn.DeleteObject(o.obj)
+		if !n.Commit() {
+			log.Warning("%s error deleting system object: %s", logTag, key)
+		}
+	}
+}
+
+// GetObjects returns the current values of the named objects added: packets
+// and bytes of the counters, and bytes consumed of the quotas.
+func (n *Nft) GetObjects() ([]*common.ObjectValues, error) {
+	sysObjects.RLock()
+	defer sysObjects.RUnlock()
+
+	list := make([]*common.ObjectValues, 0, len(sysObjects.objs))
+	for _, o := range sysObjects.objs {
+		values := &common.ObjectValues{
+			Name:   o.cfg.Name,
+			Table:  o.cfg.Table,
+			Family: o.cfg.Family,
+			Type:   o.cfg.Type,
+		}
+		list = append(list, values)
+		if o.obj == nil {
+			continue
+		}
+		cur, err := n.Conn.GetObject(o.obj)
+		if err != nil || cur == nil {
+			return nil, fmt.Errorf("%s error reading %s %s: %v", logTag, o.cfg.Type, o.cfg.Name, err)
+		}
+		switch obj := cur.(type) {
+		case *nftables.CounterObj:
+			values.Packets = obj.Packets
+			values.Bytes = obj.Bytes
+		case *nftables.QuotaObj:
+			values.Bytes = obj.Bytes
+			values.Consumed = obj.Consumed
+			values.Over = obj.Over
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return getObjectKey(list[i].Name, list[i].Table, list[i].Family) < getObjectKey(list[j].Name, list[j].Table, list[j].Family)
+	})
+
+	return list, nil
+}
+
+// buildObjectRule helper builds the expressions to reference a named object
+// from a rule:
+// "Name": "quota",
+// "Values": [ {"Key": "name", "Value": "http_quota"} ]
+//
+// nft add rule inet opensnitch filter_output tcp dport 80 quota name "http_quota" drop
+//
+//	[ objref type 2 name http_quota ]
+func (n *Nft) buildObjectRule(table, family, name string, statement *config.ExprStatement) (*[]expr.Any, error) {
+	fwObj := n.GetSystemObject(name, table, family)
+	if fwObj == nil {
+		return nil, fmt.Errorf("%s not found: %s (%s, %s)", statement.Name, name, table, family)
+	}
+	if fwObj.Type != statement.Name {
+		return nil, fmt.Errorf("%s is a %s, not a %s", name, fwObj.Type, statement.Name)
+	}
+	switch fwObj.Type {
+	case exprs.NFT_COUNTER:
+		return exprs.NewExprObjref(exprs.NFT_OBJECT_COUNTER, name), nil
+	case exprs.NFT_QUOTA:
+		return exprs.NewExprObjref(exprs.NFT_OBJECT_QUOTA, name), nil
+	}
+	return exprs.NewExprLimit(objectStatement(fwObj))
+}
+
+// objectStatement returns the statement equivalent to the definition of an
+// object.
+func objectStatement(fwObj *config.FwObject) *config.ExprStatement {
+	return &config.ExprStatement{Name: fwObj.Type, Values: fwObj.Values}
+}
+
+// getObjectName returns the object referenced by a statement, if any.
+func getObjectName(statement *config.ExprStatement) string {
+	for _, v := range statement.Values {
+		if v.Key == exprs.NFT_COUNTER_NAME {
+			return v.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package nftables_test
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+	"github.com/google/nftables/expr"
+)
+
+func TestAddSystemObjects(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+	nftest.Fw.Conn = conn
+
+	cfg, err := nftest.Fw.NewSystemFwConfig(configFile, nftest.Fw.PreloadConfCallback, nftest.Fw.ReloadConfCallback)
+	if err != nil {
+		t.Logf("Error creating fw config: %s", err)
+	}
+
+	cfg.SetConfigFile("./testdata/test-sysfw-objects.json")
+	if err := cfg.LoadDiskConfiguration(false); err != nil {
+		t.Errorf("Error loading config from disk: %s", err)
+	}
+
+	nftest.Fw.AddSystemRules(false, false)
+
+	for _, name := range []string{"web_out", "web_quota", "dns_limit"} {
+		if nftest.Fw.GetSystemObject(name, exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET) == nil {
+			t.Errorf("object %s not added", name)
+		}
+	}
+	rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, "filter_output")
+	if len(rules) != 3 {
+		t.Fatalf("filter_output should contain 3 rules, got %d", len(rules))
+	}
+	refs := 0
+	for _, r := range rules {
+		for _, e := range r.Exprs {
+			if ref, ok := e.(*expr.Objref); ok && (ref.Name == "web_out" || ref.Name == "web_quota") {
+				refs++
+			}
+		}
+	}
+	if refs != 4 {
+		t.Errorf("the counter and the quota should be referenced by 2 rules, got %d references", refs)
+	}
+
+	objs, err := nftest.Fw.GetObjects()
+	if err != nil {
+		t.Fatal("GetObjects() error:", err)
+	}
+	if len(objs) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(objs))
+	}
+	// sorted by name
+	if objs[1].Name != "web_out" || objs[2].Name != "web_quota" || objs[2].Bytes != 500*1024*1024 || !objs[2].Over {
+		t.Errorf("unexpected objects values: %+v, %+v", objs[1], objs[2])
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		nftest.Fw.DeleteSystemRules(false, false, true)
+		if nftest.Fw.GetSystemObject("web_quota", exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET) != nil {
+			t.Error("object web_quota not deleted")
+		}
+		objs, _ := conn.GetObjects(nftest.Fw.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET))
+		if len(objs) != 0 {
+			t.Errorf("objects not deleted: %v", objs)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package nftables
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// PanicChain is the chain where the new outbound connections are dropped in
+// panic mode, before queueing them, except the ones to the loopback interface
+// and to the allowed destinations:
+//
+// nft add chain inet opensnitch panic
+// nft add rule inet opensnitch panic oifname "lo" return
+// nft add rule inet opensnitch panic meta nfproto ipv4 ip daddr 192.168.1.0/24 return
+// nft add rule inet opensnitch panic drop
+// nft insert rule inet opensnitch mangle_output ct state new jump panic
+const PanicChain = "panic"
+
+// EnablePanicMode drops the new outbound connections, except the ones to the
+// loopback interface and to the allowed destinations. The rules already
+// loaded and the established connections are not modified.
+func (n *Nft) EnablePanicMode(allowed []*net.IPNet) error {
+	n.Lock()
+	defer n.Unlock()
+	n.panicMode = true
+	n.panicAllowed = allowed
+	return n.addPanicRules()
+}
+
+// DisablePanicMode deletes the rules of the panic mode.
+func (n *Nft) DisablePanicMode() error {
+	n.Lock()
+	defer n.Unlock()
+	n.panicMode = false
+	n.panicAllowed = nil
+	return n.delPanicRules()
+}
+
+// restorePanicRules adds again the rules of the panic mode, if it's enabled,
+// after adding the interception rules.
+func (n *Nft) restorePanicRules() error {
+	n.Lock()
+	defer n.Unlock()
+	if !n.panicMode {
+		return nil
+	}
+	return n.addPanicRules()
+}
+
+func (n *Nft) addPanicRules() error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s panic mode: netlink connection not active", logTag)
+	}
+	table := n.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	output := GetChain(exprs.CHAIN_MANGLE_OUTPUT, table)
+	if table == nil || output == nil {
+		return fmt.Errorf("%s panic mode: interception chain mangle_output not found", logTag)
+	}
+	// the allowed destinations may have changed.
+	if err := n.delPanicRules(); err != nil {
+		return err
+	}
+
+	chain := n.Conn.AddChain(&nftables.Chain{
+		Name:  PanicChain,
+		Table: table,
+	})
+	addRule := func(e ...expr.Any) {
+		n.Conn.AddRule(&nftables.Rule{
+			Table:    table,
+			Chain:    chain,
+			Exprs:    e,
+			UserData: []byte(PanicRuleKey),
+		})
+	}
+	addRule(append(*exprs.NewExprIface("lo", true, expr.CmpOpEq), &expr.Verdict{Kind: expr.VerdictReturn})...)
+	for _, dst := range n.panicAllowed {
+		addRule(append(daddrExprs(dst), &expr.Verdict{Kind: expr.VerdictReturn})...)
+	}
+	addRule(&expr.Verdict{Kind: expr.VerdictDrop})
+
+	// on top of the interception rules, the connections dropped are not
+	// queued.
+	n.Conn.InsertRule(&nftables.Rule{
+		Position: 0,
+		Table:    table,
+		Chain:    output,
+		Exprs:    append(ctStateNewExprs(), &expr.Verdict{Kind: expr.VerdictJump, Chain: PanicChain}),
+		UserData: []byte(PanicRuleKey),
+	})
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding the panic mode rules", logTag)
+	}
+	return nil
+}
+
+// delPanicRules deletes the rules of the panic mode, and its chain.
+func (n *Nft) delPanicRules() error {
+	if err := n.delRulesByKey(PanicRuleKey); err != nil {
+		return err
+	}
+	chains, err := n.Conn.ListChains()
+	if err != nil {
+		return fmt.Errorf("%s panic mode, error listing chains: %s", logTag, err)
+	}
+	for _, c := range chains {
+		if c.Name != PanicChain || c.Table.Name != exprs.TABLE_OPENSNITCH {
+			continue
+		}
+		n.Conn.DelChain(c)
+		if !n.Commit() {
+			return fmt.Errorf("%s error deleting the panic mode chain", logTag)
+		}
+	}
+	return nil
+}
+
+// ctStateNewExprs returns the expressions to match the new connections.
+func ctStateNewExprs() []expr.Any {
+	return []expr.Any{
+		&expr.Ct{Register: 1, SourceRegister: false, Key: expr.CtKeySTATE},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            4,
+			Mask:           binaryutil.NativeEndian.PutUint32(expr.CtStateBitNEW),
+			Xor:            binaryutil.NativeEndian.PutUint32(0),
+		},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0, 0, 0, 0}},
+	}
+}
+
+// daddrExprs returns the expressions to match the destination network of a
+// packet.
+func daddrExprs(dst *net.IPNet) []expr.Any {
+	family, ip, offset := byte(unix.NFPROTO_IPV4), dst.IP.To4(), uint32(16)
+	if ip == nil {
+		family, ip, offset = unix.NFPROTO_IPV6, dst.IP.To16(), 24
+	}
+	mask := dst.Mask
+	if len(mask) > len(ip) {
+		mask = mask[len(mask)-len(ip):]
+	}
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+		&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: offset, Len: uint32(len(ip))},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            uint32(len(ip)),
+			Mask:           mask,
+			Xor:            make([]byte, len(ip)),
+		},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ip.Mask(mask)},
+	}
+}
@@ -0,0 +1,72 @@
+package nftables_test
+
+import (
+	"net"
+	"testing"
+
+	nftb "github.com/evilsocket/opensnitch/daemon/firewall/nftables"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+	"github.com/google/nftables"
+)
+
+func TestPanicMode(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+	nftest.Fw.Conn = conn
+
+	_, err := nftest.Fw.AddTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if err != nil {
+		t.Error("pre step add_table() opensnitch-inet failed")
+	}
+	chn := nftest.Fw.AddChain(
+		exprs.CHAIN_MANGLE_OUTPUT, exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET,
+		nftables.ChainPriorityFilter,
+		nftables.ChainTypeFilter,
+		nftables.ChainHookOutput,
+		nftables.ChainPolicyAccept)
+	if chn == nil {
+		t.Error("pre step add_chain() mangle_output-opensnitch-inet failed")
+	}
+	if err1, err2 := nftest.Fw.QueueConnections(true, true); err1 != nil && err2 != nil {
+		t.Errorf("rule to queue connections not added: %s, %s", err1, err2)
+	}
+
+	_, net4, _ := net.ParseCIDR("192.168.1.0/24")
+	_, net6, _ := net.ParseCIDR("2001:db8::1/128")
+	if err := nftest.Fw.EnablePanicMode([]*net.IPNet{net4, net6}); err != nil {
+		t.Fatal("EnablePanicMode() error:", err)
+	}
+	// enabling it again replaces the rules.
+	if err := nftest.Fw.EnablePanicMode([]*net.IPNet{net4, net6}); err != nil {
+		t.Fatal("EnablePanicMode() error:", err)
+	}
+
+	rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT)
+	if len(rules) == 0 || string(rules[0].UserData) != nftb.PanicRuleKey {
+		t.Fatal("panic mode rule not in 1st position of mangle_output")
+	}
+	if string(rules[len(rules)-1].UserData) != nftb.InterceptionRuleKey {
+		t.Error("interception rules not in the last position of mangle_output")
+	}
+	// loopback, allowed destinations and drop.
+	rules, _ = getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, nftb.PanicChain)
+	if len(rules) != 4 {
+		t.Errorf("invalid number of panic mode rules: %d, expected 4", len(rules))
+	}
+
+	if err := nftest.Fw.DisablePanicMode(); err != nil {
+		t.Fatal("DisablePanicMode() error:", err)
+	}
+	if r, _ := getRule(t, conn, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT, nftb.PanicRuleKey, 0); r != nil {
+		t.Error("panic mode rule not deleted")
+	}
+	if _, idx := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, nftb.PanicChain); idx != -1 {
+		t.Error("panic mode chain not deleted")
+	}
+	if r, _ := getRule(t, conn, exprs.TABLE_OPENSNITCH, exprs.CHAIN_MANGLE_OUTPUT, nftb.InterceptionRuleKey, 0); r == nil {
+		t.Error("interception rules deleted with the panic mode rules")
+	}
+}
@@ -104,7 +104,7 @@ func (n *Nft) parseExpression(table, chain, family string, expression *config.Ex
 		exprList = append(exprList, *exprIP...)
 
 	case exprs.NFT_PROTO_ICMP, exprs.NFT_PROTO_ICMPv6:
-		exprICMP := n.buildICMPRule(table, family, expression.Statement.Name, expression.Statement.Values)
+		exprICMP := n.buildICMPRule(table, family, expression.Statement.Name, expression.Statement.Values, &cmpOp)
 		if exprICMP == nil {
 			log.Warning("%s icmp statement error", logTag)
 			return nil
@@ -120,6 +120,15 @@ func (n *Nft) parseExpression(table, chain, family string, expression *config.Ex
 		exprList = append(exprList, *exprLog...)
 
 	case exprs.NFT_LIMIT:
+		if name := getObjectName(expression.Statement); name != "" {
+			exprObj, err := n.buildObjectRule(table, family, name, expression.Statement)
+			if err != nil {
+				log.Warning("%s limit statement error: %s", logTag, err)
+				return nil
+			}
+			exprList = append(exprList, *exprObj...)
+			break
+		}
 		exprLimit, err := exprs.NewExprLimit(expression.Statement)
 		if err != nil {
 			log.Warning("%s %s", logTag, err)
@@ -155,7 +164,24 @@ func (n *Nft) parseExpression(table, chain, family string, expression *config.Ex
 
 		}
 
+	case exprs.NFT_MAP, exprs.NFT_VMAP:
+		exprMap, err := n.buildMapRule(table, family, expression.Statement)
+		if err != nil {
+			log.Warning("%s %s statement error: %s", logTag, expression.Statement.Name, err)
+			return nil
+		}
+		exprList = append(exprList, *exprMap...)
+
 	case exprs.NFT_QUOTA:
+		if name := getObjectName(expression.Statement); name != "" {
+			exprObj, err := n.buildObjectRule(table, family, name, expression.Statement)
+			if err != nil {
+				log.Warning("%s quota statement error: %s", logTag, err)
+				return nil
+			}
+			exprList = append(exprList, *exprObj...)
+			break
+		}
 		exprQuota, err := exprs.NewQuota(expression.Statement.Values)
 		if err != nil {
 			log.Warning("%s quota statement error: %s", logTag, err)
@@ -168,6 +194,16 @@ func (n *Nft) parseExpression(table, chain, family string, expression *config.Ex
 		exprList = append(exprList, *exprs.NewNoTrack()...)
 
 	case exprs.NFT_COUNTER:
+		// counters shared by several rules.
+		if name := getObjectName(expression.Statement); n.GetSystemObject(name, table, family) != nil {
+			exprObj, err := n.buildObjectRule(table, family, name, expression.Statement)
+			if err != nil {
+				log.Warning("%s counter statement error: %s", logTag, err)
+				return nil
+			}
+			exprList = append(exprList, *exprObj...)
+			break
+		}
 		tbl := n.GetTable(table, family)
 		if tbl == nil {
 			log.Warning("%s Error getting table counter: %s, %s, %s", logTag, table, chain, family)
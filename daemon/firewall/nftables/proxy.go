@@ -0,0 +1,113 @@
+package nftables
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// ProxyChain is the chain where the connections of the proxy rules, marked
+// by the daemon when they're verdicted, are redirected to the local forwarder,
+// which sends them through the upstream proxy of their rule:
+//
+// nft add chain inet opensnitch proxy { type nat hook output priority -100 \; }
+// nft add rule inet opensnitch proxy meta mark 0x101e meta l4proto tcp redirect to :8078
+//
+// The connections are originated on this host, so they're redirected with
+// redirect, since tproxy only applies to the packets in prerouting.
+const ProxyChain = "proxy"
+
+// EnableProxy redirects the connections accepted with the given mark to the
+// local port of the forwarder.
+func (n *Nft) EnableProxy(port uint16, mark uint32) error {
+	n.Lock()
+	defer n.Unlock()
+	n.proxyPort = port
+	n.proxyMark = mark
+	return n.addProxyRules()
+}
+
+// DisableProxy deletes the rules of the forwarder.
+func (n *Nft) DisableProxy() error {
+	n.Lock()
+	defer n.Unlock()
+	n.proxyPort = 0
+	return n.delProxyRules()
+}
+
+// restoreProxyRules adds again the rules of the forwarder, if it's enabled,
+// after adding the interception rules.
+func (n *Nft) restoreProxyRules() error {
+	n.Lock()
+	defer n.Unlock()
+	if n.proxyPort == 0 {
+		return nil
+	}
+	return n.addProxyRules()
+}
+
+func (n *Nft) addProxyRules() error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s proxy: netlink connection not active", logTag)
+	}
+	table := n.GetTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if table == nil {
+		return fmt.Errorf("%s proxy: table %s not found", logTag, exprs.TABLE_OPENSNITCH)
+	}
+	// the port may have changed.
+	if err := n.delProxyRules(); err != nil {
+		return err
+	}
+
+	policy := nftables.ChainPolicyAccept
+	chain := n.Conn.AddChain(&nftables.Chain{
+		Name:     ProxyChain,
+		Table:    table,
+		Type:     nftables.ChainTypeNAT,
+		Hooknum:  nftables.ChainHookOutput,
+		Priority: nftables.ChainPriorityNATDest,
+		Policy:   &policy,
+	})
+	n.Conn.AddRule(&nftables.Rule{
+		Table: table,
+		Chain: chain,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyMARK, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: binaryutil.NativeEndian.PutUint32(n.proxyMark)},
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+			&expr.Immediate{Register: 1, Data: binaryutil.BigEndian.PutUint16(n.proxyPort)},
+			&expr.Redir{RegisterProtoMin: 1},
+		},
+		UserData: []byte(ProxyRuleKey),
+	})
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding the proxy rules", logTag)
+	}
+	return nil
+}
+
+// delProxyRules deletes the rules of the forwarder, and its chain.
+func (n *Nft) delProxyRules() error {
+	if err := n.delRulesByKey(ProxyRuleKey); err != nil {
+		return err
+	}
+	chains, err := n.Conn.ListChains()
+	if err != nil {
+		return fmt.Errorf("%s proxy, error listing chains: %s", logTag, err)
+	}
+	for _, c := range chains {
+		if c.Name != ProxyChain || c.Table.Name != exprs.TABLE_OPENSNITCH {
+			continue
+		}
+		n.Conn.DelChain(c)
+		if !n.Commit() {
+			return fmt.Errorf("%s error deleting the proxy chain", logTag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package nftables_test
+
+import (
+	"testing"
+
+	nftb "github.com/evilsocket/opensnitch/daemon/firewall/nftables"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/exprs"
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables/nftest"
+)
+
+func TestProxy(t *testing.T) {
+	nftest.SkipIfNotPrivileged(t)
+
+	conn, newNS := nftest.OpenSystemConn(t)
+	defer nftest.CleanupSystemConn(t, newNS)
+	nftest.Fw.Conn = conn
+
+	_, err := nftest.Fw.AddTable(exprs.TABLE_OPENSNITCH, exprs.NFT_FAMILY_INET)
+	if err != nil {
+		t.Error("pre step add_table() opensnitch-inet failed")
+	}
+
+	if err := nftest.Fw.EnableProxy(8078, 0x101e); err != nil {
+		t.Fatal("EnableProxy() error:", err)
+	}
+	// enabling it again replaces the rules.
+	if err := nftest.Fw.EnableProxy(8079, 0x101e); err != nil {
+		t.Fatal("EnableProxy() error:", err)
+	}
+	rules, _ := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, nftb.ProxyChain)
+	if len(rules) != 1 || string(rules[0].UserData) != nftb.ProxyRuleKey {
+		t.Errorf("invalid proxy rules: %d, expected 1", len(rules))
+	}
+
+	if err := nftest.Fw.DisableProxy(); err != nil {
+		t.Fatal("DisableProxy() error:", err)
+	}
+	if _, idx := getRulesList(t, conn, exprs.NFT_FAMILY_INET, exprs.TABLE_OPENSNITCH, nftb.ProxyChain); idx != -1 {
+		t.Error("proxy chain not deleted")
+	}
+}
@@ -0,0 +1,185 @@
+package nftables
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// Sets of the TCP connections denied, to drop the retransmissions of their SYN
+// packets in kernel, without queueing them:
+// nft add set inet opensnitch denied_syn4 { type ipv4_addr . inet_service . ipv4_addr . inet_service; flags timeout; }
+const (
+	RetransmitSet4 = "denied_syn4"
+	RetransmitSet6 = "denied_syn6"
+)
+
+// store of the sets of the daemon, by family.
+type familySetsT struct {
+	sets map[byte]*nftables.Set
+	sync.RWMutex
+}
+
+// sets of the denied connections.
+var retransmitSets = &familySetsT{
+	sets: make(map[byte]*nftables.Set),
+}
+
+func (r *familySetsT) Add(family byte, set *nftables.Set) {
+	r.Lock()
+	defer r.Unlock()
+	r.sets[family] = set
+}
+
+func (r *familySetsT) Get(family byte) *nftables.Set {
+	r.RLock()
+	defer r.RUnlock()
+	return r.sets[family]
+}
+
+// Reset returns the sets added, and empties the store.
+func (r *familySetsT) Reset() map[byte]*nftables.Set {
+	r.Lock()
+	defer r.Unlock()
+	sets := r.sets
+	r.sets = make(map[byte]*nftables.Set)
+	return sets
+}
+
+// addRetransmitSets creates the sets of the denied connections, and returns
+// the rules that drop the packets of these connections.
+//
+// nft --debug=netlink add rule inet opensnitch mangle_output meta nfproto ipv4 meta l4proto tcp ip saddr . tcp sport . ip daddr . tcp dport @denied_syn4 drop
+//
+//	[ meta load nfproto => reg 1 ]
+//	[ cmp eq reg 1 0x00000002 ]
+//	[ meta load l4proto => reg 1 ]
+//	[ cmp eq reg 1 0x00000006 ]
+//	[ payload load 4b @ network header + 12 => reg 1 ]
+//	[ payload load 2b @ transport header + 0 => reg 9 ]
+//	[ payload load 4b @ network header + 16 => reg 10 ]
+//	[ payload load 2b @ transport header + 2 => reg 11 ]
+//	[ lookup reg 1 set denied_syn4 ]
+//	[ immediate reg 0 drop ]
+func (n *Nft) addRetransmitSets(table *nftables.Table, chain *nftables.Chain) ([]*nftables.Rule, error) {
+	rules := []*nftables.Rule{}
+	for _, family := range []byte{unix.NFPROTO_IPV4, unix.NFPROTO_IPV6} {
+		name, addrType, addrLen, srcOff, dstOff := RetransmitSet4, nftables.TypeIPAddr, uint32(4), uint32(12), uint32(16)
+		if family == unix.NFPROTO_IPV6 {
+			name, addrType, addrLen, srcOff, dstOff = RetransmitSet6, nftables.TypeIP6Addr, 16, 8, 24
+		}
+		set := &nftables.Set{
+			Table:         table,
+			Name:          name,
+			KeyType:       nftables.MustConcatSetType(addrType, nftables.TypeInetService, addrType, nftables.TypeInetService),
+			Concatenation: true,
+			HasTimeout:    true,
+		}
+		if err := n.Conn.AddSet(set, nil); err != nil {
+			return nil, fmt.Errorf("%s set %s, AddSet() error: %s", logTag, name, err)
+		}
+		retransmitSets.Add(family, set)
+
+		// the registers are 32 bits wide (reg 1 is reg 8 to 11), the ports
+		// are padded to 4 bytes.
+		sportReg := 8 + addrLen/4
+		daddrReg := sportReg + 1
+		dportReg := daddrReg + addrLen/4
+		rules = append(rules, &nftables.Rule{
+			Table: table,
+			Chain: chain,
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+				&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_TCP}},
+				&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseNetworkHeader, Offset: srcOff, Len: addrLen},
+				&expr.Payload{DestRegister: sportReg, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 2},
+				&expr.Payload{DestRegister: daddrReg, Base: expr.PayloadBaseNetworkHeader, Offset: dstOff, Len: addrLen},
+				&expr.Payload{DestRegister: dportReg, Base: expr.PayloadBaseTransportHeader, Offset: 2, Len: 2},
+				&expr.Lookup{SourceRegister: 1, SetName: set.Name, SetID: set.ID},
+				&expr.Verdict{Kind: expr.VerdictDrop},
+			},
+			// not an interception rule, it doesn't send packets to the queue.
+			UserData: []byte(RetransmitRuleKey),
+		})
+	}
+	return rules, nil
+}
+
+// loadRetransmitSets gets the sets of the denied connections added by a
+// previous instance of the daemon.
+func (n *Nft) loadRetransmitSets(table *nftables.Table) {
+	for family, name := range map[byte]string{unix.NFPROTO_IPV4: RetransmitSet4, unix.NFPROTO_IPV6: RetransmitSet6} {
+		set, err := n.Conn.GetSetByName(table, name)
+		if err != nil {
+			log.Debug("%s set %s not found: %s", logTag, name, err)
+			continue
+		}
+		retransmitSets.Add(family, set)
+	}
+}
+
+// delRetransmitSets deletes the sets of the denied connections.
+// The rules using the sets must be deleted before.
+func (n *Nft) delRetransmitSets() {
+	deleted := false
+	for _, set := range retransmitSets.Reset() {
+		n.Conn.DelSet(set)
+		deleted = true
+	}
+	if deleted && !n.Commit() {
+		log.Debug("%s error deleting the sets of the denied connections", logTag)
+	}
+}
+
+// DropRetransmissions drops in kernel the packets of a TCP connection for the
+// given time, in order not to queue the retransmissions of a connection
+// already denied.
+func (n *Nft) DropRetransmissions(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint, timeout time.Duration) error {
+	if n.Conn == nil {
+		return fmt.Errorf("%s DropRetransmissions: netlink connection not active", logTag)
+	}
+	family, key := tupleKey(srcIP, srcPort, dstIP, dstPort)
+	set := retransmitSets.Get(family)
+	if set == nil {
+		return fmt.Errorf("%s set of denied connections not found (family %d)", logTag, family)
+	}
+
+	// the workers add the elements concurrently, don't flush the changes
+	// of other workers.
+	n.Lock()
+	defer n.Unlock()
+	if err := n.Conn.SetAddElements(set, []nftables.SetElement{{Key: key, Timeout: timeout}}); err != nil {
+		return err
+	}
+	if !n.Commit() {
+		return fmt.Errorf("%s error adding element to %s", logTag, set.Name)
+	}
+	return nil
+}
+
+// tupleKey returns the family and the key of the elements of the sets of
+// connections: saddr . sport . daddr . dport, with the ports padded to 4
+// bytes.
+func tupleKey(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint) (byte, []byte) {
+	family, src, dst := byte(unix.NFPROTO_IPV4), srcIP.To4(), dstIP.To4()
+	if src == nil || dst == nil {
+		family, src, dst = unix.NFPROTO_IPV6, srcIP.To16(), dstIP.To16()
+	}
+	key := make([]byte, 0, 40)
+	key = append(key, src...)
+	key = append(key, binaryutil.BigEndian.PutUint16(uint16(srcPort))...)
+	key = append(key, 0, 0)
+	key = append(key, dst...)
+	key = append(key, binaryutil.BigEndian.PutUint16(uint16(dstPort))...)
+	key = append(key, 0, 0)
+	return family, key
+}
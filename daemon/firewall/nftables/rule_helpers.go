@@ -13,116 +13,150 @@ import (
 
 // rules examples: https://github.com/google/nftables/blob/master/nftables_test.go
 
-func (n *Nft) buildICMPRule(table, family string, icmpProtoVersion string, icmpOptions []*config.ExprValues) *[]expr.Any {
+// buildICMPRule helper builds a rule to match the type and/or the code of the
+// ICMP and ICMPv6 packets. Several values are matched with an anonymous set.
+//
+// nft --debug=netlink add rule filter output icmp type destination-unreachable icmp code { port-unreachable, admin-prohibited }
+//	[ meta load l4proto => reg 1 ]
+//	[ cmp eq reg 1 0x00000001 ]
+//	[ payload load 1b @ transport header + 0 => reg 1 ]
+//	[ cmp eq reg 1 0x00000003 ]
+//	[ payload load 1b @ transport header + 1 => reg 1 ]
+//	[ lookup reg 1 set __set%d ]
+func (n *Nft) buildICMPRule(table, family string, icmpProtoVersion string, icmpOptions []*config.ExprValues, cmpOp *expr.CmpOp) *[]expr.Any {
 	tbl := n.GetTable(table, family)
 	if tbl == nil {
 		return nil
 	}
-	offset := uint32(0)
-	icmpType := uint8(0)
-	setType := nftables.SetDatatype{}
+	typeSetType := nftables.SetDatatype{}
+	codeSetType := nftables.SetDatatype{}
 
 	switch icmpProtoVersion {
 	case exprs.NFT_PROTO_ICMP:
-		setType = nftables.TypeICMPType
+		typeSetType = nftables.TypeICMPType
+		codeSetType = nftables.TypeICMPCode
 	case exprs.NFT_PROTO_ICMPv6:
-		setType = nftables.TypeICMP6Type
+		typeSetType = nftables.TypeICMP6Type
+		codeSetType = nftables.TypeICMPV6Code
 	default:
 		return nil
 	}
 
-	exprICMP, _ := exprs.NewExprProtocol(icmpProtoVersion)
-	ICMPrule := []expr.Any{}
-	ICMPrule = append(ICMPrule, *exprICMP...)
-
-	ICMPtemp := []expr.Any{}
-	setElements := []nftables.SetElement{}
+	icmpTypes := []byte{}
+	icmpCodes := []byte{}
 	for _, icmp := range icmpOptions {
-		switch icmp.Key {
-		case exprs.NFT_ICMP_TYPE:
-			icmpTypeList := strings.Split(icmp.Value, ",")
-			for _, icmpTypeStr := range icmpTypeList {
-				if exprs.NFT_PROTO_ICMPv6 == icmpProtoVersion {
-					icmpType = exprs.GetICMPv6Type(icmpTypeStr)
-				} else {
-					icmpType = exprs.GetICMPType(icmpTypeStr)
+		for _, value := range strings.Split(icmp.Value, ",") {
+			switch icmp.Key {
+			case exprs.NFT_ICMP_TYPE:
+				icmpType, err := exprs.ParseICMPType(icmpProtoVersion, strings.TrimSpace(value))
+				if err != nil {
+					log.Warning("%s %s", logTag, err)
+					return nil
 				}
-				exprCmp := &expr.Cmp{
-					Op:       expr.CmpOpEq,
-					Register: 1,
-					Data:     []byte{icmpType},
+				icmpTypes = append(icmpTypes, icmpType)
+			case exprs.NFT_ICMP_CODE:
+				icmpCode, err := exprs.ParseICMPCode(icmpProtoVersion, strings.TrimSpace(value))
+				if err != nil {
+					log.Warning("%s %s", logTag, err)
+					return nil
 				}
-				ICMPtemp = append(ICMPtemp, []expr.Any{exprCmp}...)
-
-				// fill setElements. If there're more than 1 icmp type we'll use it later
-				setElements = append(setElements,
-					[]nftables.SetElement{
-						{
-							Key: []byte{icmpType},
-						},
-					}...)
+				icmpCodes = append(icmpCodes, icmpCode)
+			default:
+				log.Warning("%s invalid %s option: %s", logTag, icmpProtoVersion, icmp.Key)
+				return nil
 			}
-		case exprs.NFT_ICMP_CODE:
-			// TODO
-			offset = 1
 		}
 	}
 
-	ICMPrule = append(ICMPrule, []expr.Any{
+	exprICMP, _ := exprs.NewExprProtocol(icmpProtoVersion)
+	ICMPrule := []expr.Any{}
+	ICMPrule = append(ICMPrule, *exprICMP...)
+
+	// 0 type, 1 code
+	for offset, match := range []struct {
+		values  []byte
+		setType nftables.SetDatatype
+	}{
+		{icmpTypes, typeSetType},
+		{icmpCodes, codeSetType},
+	} {
+		if len(match.values) == 0 {
+			continue
+		}
+		exprMatch := n.buildICMPMatch(tbl, uint32(offset), match.setType, match.values, cmpOp)
+		if exprMatch == nil {
+			return nil
+		}
+		ICMPrule = append(ICMPrule, exprMatch...)
+	}
+
+	return &ICMPrule
+}
+
+// buildICMPMatch matches a field of the ICMP header (offset 0 type, 1 code)
+// against one or more values.
+func (n *Nft) buildICMPMatch(tbl *nftables.Table, offset uint32, setType nftables.SetDatatype, values []byte, cmpOp *expr.CmpOp) []expr.Any {
+	exprMatch := []expr.Any{
 		&expr.Payload{
 			DestRegister: 1,
 			Base:         expr.PayloadBaseTransportHeader,
-			Offset:       offset, // 0 type, 1 code
+			Offset:       offset,
 			Len:          1,
 		},
-	}...)
-
-	if len(setElements) == 1 {
-		ICMPrule = append(ICMPrule, ICMPtemp...)
-	} else {
-		set := &nftables.Set{
-			Anonymous: true,
-			Constant:  true,
-			Table:     tbl,
-			KeyType:   setType,
-		}
-		if err := n.Conn.AddSet(set, setElements); err != nil {
-			log.Warning("%s AddSet() error: %s", logTag, err)
-			return nil
-		}
-		sysSets = append(sysSets, []*nftables.Set{set}...)
+	}
+	if len(values) == 1 {
+		return append(exprMatch, &expr.Cmp{
+			Op:       *cmpOp,
+			Register: 1,
+			Data:     []byte{values[0]},
+		})
+	}
 
-		ICMPrule = append(ICMPrule, []expr.Any{
-			&expr.Lookup{
-				SourceRegister: 1,
-				SetName:        set.Name,
-				SetID:          set.ID,
-			}}...)
+	setElements := []nftables.SetElement{}
+	for _, value := range values {
+		setElements = append(setElements, nftables.SetElement{Key: []byte{value}})
+	}
+	set := &nftables.Set{
+		Anonymous: true,
+		Constant:  true,
+		Table:     tbl,
+		KeyType:   setType,
+	}
+	if err := n.Conn.AddSet(set, setElements); err != nil {
+		log.Warning("%s AddSet() error: %s", logTag, err)
+		return nil
 	}
+	sysSets = append(sysSets, []*nftables.Set{set}...)
 
-	return &ICMPrule
+	return append(exprMatch, &expr.Lookup{
+		SourceRegister: 1,
+		SetName:        set.Name,
+		SetID:          set.ID,
+		Invert:         *cmpOp == expr.CmpOpNeq,
+	})
 }
 
+// buildConntrackRule helper builds a rule to match the conntrack states and
+// marks, or to set the conntrack mark.
+//
+// nft --debug=netlink add rule filter output ct state established,related ct mark 1
+//	[ ct load state => reg 1 ]
+//	[ bitwise reg 1 = ( reg 1 & 0x00000006 ) ^ 0x00000000 ]
+//	[ cmp neq reg 1 0x00000000 ]
+//	[ ct load mark => reg 1 ]
+//	[ cmp eq reg 1 0x00000001 ]
 func (n *Nft) buildConntrackRule(ctOptions []*config.ExprValues, cmpOp *expr.CmpOp) *[]expr.Any {
 	exprList := []expr.Any{}
+	markList := []expr.Any{}
+	// we expect to have multiple "state" keys:
+	// { "state": "established", "state": "related" }
+	states := []*config.ExprValues{}
 
 	setMark := false
 	for _, ctOption := range ctOptions {
 		switch ctOption.Key {
-		// we expect to have multiple "state" keys:
-		// { "state": "established", "state": "related" }
 		case exprs.NFT_CT_STATE:
-			ctExprState, err := exprs.NewExprCtState(ctOptions)
-			if err != nil {
-				log.Warning("%s ct set state error: %s", logTag, err)
-				return nil
-			}
-			exprList = append(exprList, *ctExprState...)
-			exprList = append(exprList,
-				&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0, 0, 0, 0}},
-			)
-			// we only need to iterate once here
-			goto Exit
+			states = append(states, ctOption)
 		case exprs.NFT_CT_SET_MARK:
 			setMark = true
 		case exprs.NFT_CT_MARK:
@@ -131,15 +165,34 @@ func (n *Nft) buildConntrackRule(ctOptions []*config.ExprValues, cmpOp *expr.Cmp
 				log.Warning("%s ct mark error: %s", logTag, err)
 				return nil
 			}
-			exprList = append(exprList, *ctExprMark...)
-			goto Exit
+			markList = append(markList, *ctExprMark...)
+			setMark = false
 		default:
 			log.Warning("%s invalid conntrack option: %s", logTag, ctOption)
 			return nil
 		}
 	}
 
-Exit:
+	if len(states) > 0 {
+		ctExprState, err := exprs.NewExprCtState(states)
+		if err != nil {
+			log.Warning("%s ct set state error: %s", logTag, err)
+			return nil
+		}
+		// the state matches if any of the bits is set: ct state != { new, ... }
+		// matches if none of them is set.
+		stateOp := expr.CmpOpNeq
+		if *cmpOp == expr.CmpOpNeq {
+			stateOp = expr.CmpOpEq
+		}
+		exprList = append(exprList, *ctExprState...)
+		exprList = append(exprList,
+			&expr.Cmp{Op: stateOp, Register: 1, Data: []byte{0, 0, 0, 0}},
+		)
+	}
+	// the marks are set after matching the states.
+	exprList = append(exprList, markList...)
+
 	return &exprList
 }
 
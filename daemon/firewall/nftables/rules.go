@@ -19,6 +19,11 @@ func (n *Nft) QueueDNSResponses(enable, logError bool) (error, error) {
 	if n.Conn == nil {
 		return nil, nil
 	}
+	qNum, bypass := n.GetDNSQueue(n.bypassQueue)
+	flag := expr.QueueFlag(0)
+	if bypass {
+		flag = expr.QueueFlagBypass
+	}
 	families := []string{exprs.NFT_FAMILY_INET}
 	for _, fam := range families {
 		table := n.GetTable(exprs.TABLE_OPENSNITCH, fam)
@@ -56,8 +61,8 @@ func (n *Nft) QueueDNSResponses(enable, logError bool) (error, error) {
 					Data:     binaryutil.BigEndian.PutUint16(uint16(53)),
 				},
 				&expr.Queue{
-					Num:  n.QueueNum,
-					Flag: n.getBypassFlag(),
+					Num:  qNum,
+					Flag: flag,
 				},
 			},
 			// rule key, to allow get it later by key
@@ -77,6 +82,11 @@ func (n *Nft) QueueDNSResponses(enable, logError bool) (error, error) {
 // This rule must be added at the end of all the other rules, that way we can add
 // rules above this one to exclude a service/app from being intercepted.
 // nft insert rule ip mangle OUTPUT ct state new queue num 0 bypass
+//
+// Only the first packet of a TCP connection is queued, but the UDP flows stay
+// in the ct state new until a reply is received. The packets of the UDP flows
+// already verdicted are accepted or dropped before reaching this rule, if
+// they've been added to the maps of flows (Rules.UDPFlows.InKernel).
 func (n *Nft) QueueConnections(enable, logError bool) (error, error) {
 	if n.Conn == nil {
 		return nil, fmt.Errorf("nftables QueueConnections: netlink connection not active")
@@ -90,11 +100,50 @@ func (n *Nft) QueueConnections(enable, logError bool) (error, error) {
 		return nil, fmt.Errorf("QueueConnections() Error getting outputChain: mangle_output-%s-inet", table.Name)
 	}
 
-	n.Conn.AddRule(&nftables.Rule{
-		Position: 0,
-		Table:    table,
-		Chain:    chain,
-		Exprs: []expr.Any{
+	// drop the SYN retransmissions of the connections already denied, before
+	// queueing them again.
+	dropRules, err := n.addRetransmitSets(table, chain)
+	if err != nil {
+		log.Warning("%s", err)
+	}
+	for _, r := range dropRules {
+		n.Conn.AddRule(r)
+	}
+	// apply the verdict of the UDP flows already verdicted, without queueing
+	// their packets.
+	flowRules, err := n.addFlowMaps(table, chain)
+	if err != nil {
+		log.Warning("%s", err)
+	}
+	for _, r := range flowRules {
+		n.Conn.AddRule(r)
+	}
+
+	for _, ruleExprs := range connectionsQueueExprs(n.getConnectionsQueue()) {
+		n.Conn.AddRule(&nftables.Rule{
+			Position: 0,
+			Table:    table,
+			Chain:    chain,
+			Exprs:    ruleExprs,
+			// rule key, to allow get it later by key
+			UserData: []byte(InterceptionRuleKey),
+		})
+	}
+
+	// apply changes
+	if !n.Commit() {
+		return fmt.Errorf("Error adding interception rule "), nil
+	}
+
+	return nil, nil
+}
+
+// connectionsQueueExprs returns the expressions of the rules that queue the
+// new connections: the packets of ct state new or related of every protocol
+// but TCP, and the TCP packets with only the SYN flag set.
+func connectionsQueueExprs(queue *expr.Queue) [][]expr.Any {
+	return [][]expr.Any{
+		{
 			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
 			&expr.Cmp{
 				Op:       expr.CmpOpNeq,
@@ -110,33 +159,22 @@ func (n *Nft) QueueConnections(enable, logError bool) (error, error) {
 				Xor:            binaryutil.NativeEndian.PutUint32(0),
 			},
 			&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0, 0, 0, 0}},
-			&expr.Queue{
-				Num:  n.QueueNum,
-				Flag: n.getBypassFlag(),
-			},
+			queue,
 		},
-		// rule key, to allow get it later by key
-		UserData: []byte(InterceptionRuleKey),
-	})
+		/* nft --debug=netlink add rule inet mangle output tcp flags '& (fin|syn|rst|ack) == syn' queue bypass num 0
+		[ meta load l4proto => reg 1 ]
+		[ cmp eq reg 1 0x00000006 ]
+		[ payload load 1b @ transport header + 13 => reg 1 ]
+		[ bitwise reg 1 = ( reg 1 & 0x00000002 ) ^ 0x00000000 ]
+		[ cmp neq reg 1 0x00000000 ]
+		[ queue num 0 bypass ]
 
-	/* nft --debug=netlink add rule inet mangle output tcp flags '& (fin|syn|rst|ack) == syn' queue bypass num 0
-	[ meta load l4proto => reg 1 ]
-	[ cmp eq reg 1 0x00000006 ]
-	[ payload load 1b @ transport header + 13 => reg 1 ]
-	[ bitwise reg 1 = ( reg 1 & 0x00000002 ) ^ 0x00000000 ]
-	[ cmp neq reg 1 0x00000000 ]
-	[ queue num 0 bypass ]
-
-	Intercept packets *only* with the SYN flag set.
-	Using 'ct state NEW' causes to intercept packets with other flags set, which
-	sometimes means that we receive outbound connections not in the expected order:
-	  443:1.1.1.1 -> 192.168.123:12345 (bits ACK, ACK+PSH or SYN+ACK set)
-	*/
-	n.Conn.AddRule(&nftables.Rule{
-		Position: 0,
-		Table:    table,
-		Chain:    chain,
-		Exprs: []expr.Any{
+		Intercept packets *only* with the SYN flag set.
+		Using 'ct state NEW' causes to intercept packets with other flags set, which
+		sometimes means that we receive outbound connections not in the expected order:
+		  443:1.1.1.1 -> 192.168.123:12345 (bits ACK, ACK+PSH or SYN+ACK set)
+		*/
+		{
 			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
 			&expr.Cmp{
 				Op:       expr.CmpOpEq,
@@ -161,21 +199,9 @@ func (n *Nft) QueueConnections(enable, logError bool) (error, error) {
 				Register: 1,
 				Data:     []byte{0x02},
 			},
-			&expr.Queue{
-				Num:  n.QueueNum,
-				Flag: n.getBypassFlag(),
-			},
+			queue,
 		},
-		// rule key, to allow get it later by key
-		UserData: []byte(InterceptionRuleKey),
-	})
-
-	// apply changes
-	if !n.Commit() {
-		return fmt.Errorf("Error adding interception rule "), nil
 	}
-
-	return nil, nil
 }
 
 // InsertRule inserts a rule at the top of rules list.
@@ -279,5 +305,9 @@ func (n *Nft) delRulesByKey(key string) error {
 
 // DelInterceptionRules deletes our interception rules, by key.
 func (n *Nft) DelInterceptionRules() {
+	n.delRulesByKey(RetransmitRuleKey)
+	n.delRulesByKey(FlowsRuleKey)
 	n.delRulesByKey(InterceptionRuleKey)
+	n.delRetransmitSets()
+	n.delFlowMaps()
 }
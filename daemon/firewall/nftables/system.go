@@ -50,15 +50,23 @@ var (
 	sysChains     *sync.Map
 	origSysChains map[string]*nftables.Chain
 	sysSets       []*nftables.Set
+	sysMaps       *sysMapsT
+	sysObjects    *sysObjectsT
 )
 
-// InitMapsStore initializes internal stores of chains and maps.
+// InitMapsStore initializes internal stores of chains, maps and objects.
 func InitMapsStore() {
 	sysTables = &sysTablesT{
 		tables: make(map[string]*nftables.Table),
 	}
 	sysChains = &sync.Map{}
 	origSysChains = make(map[string]*nftables.Chain)
+	sysMaps = &sysMapsT{
+		maps: make(map[string]*nftables.Set),
+	}
+	sysObjects = &sysObjectsT{
+		objs: make(map[string]*sysObject),
+	}
 }
 
 // CreateSystemRule create the custom firewall chains and adds them to system.
@@ -112,12 +120,37 @@ func (n *Nft) AddSystemRules(reload, backupExistingChains bool) {
 		n.backupExistingChains()
 	}
 
+	// the chains, maps and objects are created before the rules, because
+	// the maps may jump to the chains, and the rules may use the maps and
+	// the objects.
+	created := make(map[*config.FwChain]bool)
 	for _, fwCfg := range n.SysConfig.SystemRules {
 		for _, chain := range fwCfg.Chains {
 			if !n.CreateSystemRule(chain, true) {
 				log.Info("createSystem failed: %s %s", chain.Name, chain.Table)
 				continue
 			}
+			created[chain] = true
+		}
+	}
+	for _, fwCfg := range n.SysConfig.SystemRules {
+		for _, fwMap := range fwCfg.Maps {
+			if err := n.AddSystemMap(fwMap); err != nil {
+				n.SendError(err.Error())
+			}
+		}
+		for _, fwObj := range fwCfg.Objects {
+			if err := n.AddSystemObject(fwObj); err != nil {
+				n.SendError(err.Error())
+			}
+		}
+	}
+
+	for _, fwCfg := range n.SysConfig.SystemRules {
+		for _, chain := range fwCfg.Chains {
+			if !created[chain] {
+				continue
+			}
 			for i := len(chain.Rules) - 1; i >= 0; i-- {
 				if chain.Rules[i].UUID == "" {
 					uuid := uuid.New()
@@ -140,9 +173,12 @@ func (n *Nft) DeleteSystemRules(force, restoreExistingChains, logErrors bool) {
 	n.Lock()
 	defer n.Unlock()
 
+	n.flushSystemMaps()
 	if err := n.delRulesByKey(SystemRuleKey); err != nil {
 		log.Warning("error deleting interception rules: %s", err)
 	}
+	n.delSystemMaps()
+	n.delSystemObjects()
 
 	if restoreExistingChains {
 		n.restoreBackupChains()
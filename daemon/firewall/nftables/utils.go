@@ -17,6 +17,22 @@ func (n *Nft) getBypassFlag() expr.QueueFlag {
 	return 0x0
 }
 
+// getConnectionsQueue returns the queue statement of the connections.
+// If there's more than one queue, the connections are balanced between them
+// by the CPU that sends them:
+// queue flags bypass,fanout to 0-3
+func (n *Nft) getConnectionsQueue() *expr.Queue {
+	q := &expr.Queue{
+		Num:  n.QueueNum,
+		Flag: n.getBypassFlag(),
+	}
+	if count := n.GetQueueCount(); count > 1 {
+		q.Total = count
+		q.Flag |= expr.QueueFlagFanout
+	}
+	return q
+}
+
 func GetFamilyCode(family string) nftables.TableFamily {
 	famCode := nftables.TableFamilyINet
 	switch family {
@@ -0,0 +1,99 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// destinations allowed in panic mode, kept to enable it again when the
+// firewall is reloaded.
+var (
+	panicAllowed []*net.IPNet
+	panicMode    bool
+	panicLock    sync.RWMutex
+)
+
+// EnablePanicMode drops the new outbound connections, except the ones to the
+// loopback interface and to the allowed destinations, until it's disabled.
+// The rules of the daemon and the established connections are not modified.
+// Enabling it again replaces the allowed destinations.
+func EnablePanicMode(allowed []*net.IPNet) error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	panicLock.Lock()
+	defer panicLock.Unlock()
+	if err := fw.EnablePanicMode(allowed); err != nil {
+		return err
+	}
+	panicMode = true
+	panicAllowed = allowed
+	return nil
+}
+
+// DisablePanicMode intercepts again the new outbound connections.
+func DisablePanicMode() error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	panicLock.Lock()
+	defer panicLock.Unlock()
+	if err := fw.DisablePanicMode(); err != nil {
+		return err
+	}
+	panicMode = false
+	panicAllowed = nil
+	return nil
+}
+
+// IsPanicMode returns true if the new outbound connections are being dropped.
+func IsPanicMode() bool {
+	panicLock.RLock()
+	defer panicLock.RUnlock()
+	return panicMode
+}
+
+// restorePanicMode enables the panic mode on a new firewall, if it was enabled.
+func restorePanicMode() {
+	panicLock.RLock()
+	defer panicLock.RUnlock()
+	if !panicMode {
+		return
+	}
+	if err := fw.EnablePanicMode(panicAllowed); err != nil {
+		log.Error("Error enabling panic mode: %s", err)
+	}
+}
+
+// ParseDestinations parses a list of IPs and networks in CIDR notation.
+func ParseDestinations(dsts []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(dsts))
+	for _, dst := range dsts {
+		if !strings.Contains(dst, "/") {
+			ip := net.ParseIP(dst)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %s", dst)
+			}
+			nets = append(nets, HostNet(ip))
+			continue
+		}
+		_, n, err := net.ParseCIDR(dst)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// HostNet returns the network of a single IP.
+func HostNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
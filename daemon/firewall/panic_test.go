@@ -0,0 +1,33 @@
+package firewall
+
+import (
+	"testing"
+)
+
+func TestParseDestinations(t *testing.T) {
+	nets, err := ParseDestinations([]string{"192.168.1.10", "10.0.0.0/8", "2001:db8::1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal("ParseDestinations() error:", err)
+	}
+	expected := []string{"192.168.1.10/32", "10.0.0.0/8", "2001:db8::1/128", "2001:db8::/32"}
+	if len(nets) != len(expected) {
+		t.Fatal("invalid destinations:", nets)
+	}
+	for i, n := range nets {
+		if n.String() != expected[i] {
+			t.Errorf("invalid destination %d: %s, expected %s", i, n, expected[i])
+		}
+	}
+
+	for _, dst := range []string{"opensnitch.io", "10.0.0.0/33", "10.0.0"} {
+		if _, err := ParseDestinations([]string{dst}); err == nil {
+			t.Error("invalid destination parsed:", dst)
+		}
+	}
+}
+
+func TestPanicModeNotInitialized(t *testing.T) {
+	if err := EnablePanicMode(nil); err == nil || IsPanicMode() {
+		t.Error("panic mode enabled without a firewall")
+	}
+}
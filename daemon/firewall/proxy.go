@@ -0,0 +1,46 @@
+package firewall
+
+import (
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// ProxyMark is the mark of the connections redirected to the forwarder of
+// the proxy rules.
+const ProxyMark = 0x101e
+
+// port of the forwarder, kept to add its rules again when the firewall is
+// reloaded. 0 if it's disabled.
+var (
+	proxyPort uint16
+	proxyLock sync.RWMutex
+)
+
+// SetProxy redirects the connections marked with ProxyMark to the given
+// local port. 0 disables it.
+func SetProxy(port uint16) error {
+	proxyLock.Lock()
+	defer proxyLock.Unlock()
+	proxyPort = port
+	if fw == nil {
+		return nil
+	}
+	if port == 0 {
+		return fw.DisableProxy()
+	}
+	return fw.EnableProxy(port, ProxyMark)
+}
+
+// restoreProxy adds the rules of the forwarder to a new firewall, if it's
+// enabled.
+func restoreProxy() {
+	proxyLock.RLock()
+	defer proxyLock.RUnlock()
+	if proxyPort == 0 {
+		return
+	}
+	if err := fw.EnableProxy(proxyPort, ProxyMark); err != nil {
+		log.Error("Error enabling the proxy forwarder: %s", err)
+	}
+}
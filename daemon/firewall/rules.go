@@ -2,30 +2,49 @@ package firewall
 
 import (
 	"fmt"
+	"net"
+	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/firewall/common"
 	"github.com/evilsocket/opensnitch/daemon/firewall/config"
+	"github.com/evilsocket/opensnitch/daemon/firewall/firewalld"
 	"github.com/evilsocket/opensnitch/daemon/firewall/iptables"
 	"github.com/evilsocket/opensnitch/daemon/firewall/nftables"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 )
 
-// Firewall is the interface that all firewalls (iptables, nftables) must implement.
+// Firewall is the interface that all firewalls (iptables, nftables, firewalld) must implement.
 type Firewall interface {
 	Init(uint16, string, string, bool)
 	Stop()
 	Name() string
 	IsRunning() bool
 	SetQueueNum(num uint16)
+	SetQueueCount(count uint16)
+	SetDNSQueue(num uint16, bypass bool)
 
 	SaveConfiguration(rawConfig string) error
+	ValidateConfiguration() error
+	LoadDiskConfiguration(reload bool) error
 
 	EnableInterception()
 	DisableInterception(bool)
 	QueueDNSResponses(bool, bool) (error, error)
 	QueueConnections(bool, bool) (error, error)
 	CleanRules(bool)
+	DropRetransmissions(net.IP, uint, net.IP, uint, time.Duration) error
+	AddFlow(net.IP, uint, net.IP, uint, bool, time.Duration) error
+	FlushFlows() error
+	EnablePanicMode([]*net.IPNet) error
+	DisablePanicMode() error
+	AddJail(*common.Jail) error
+	DelJail(*common.Jail) error
+	GetObjects() ([]*common.ObjectValues, error)
+	EnableDenyPage(uint16, uint32) error
+	DisableDenyPage() error
+	EnableProxy(uint16, uint32) error
+	DisableProxy() error
 
 	AddSystemRules(bool, bool)
 	DeleteSystemRules(bool, bool, bool)
@@ -38,14 +57,50 @@ type Firewall interface {
 }
 
 var (
-	fw       Firewall
-	queueNum = uint16(0)
+	fw         Firewall
+	queueNum   = uint16(0)
+	queueCount = uint16(1)
+
+	dnsQueueNum    = uint16(0)
+	dnsQueueBypass = false
 )
 
+// SetDNSQueue configures a dedicated queue for the DNS responses, with its own
+// bypass policy, so they're not delayed by the connections packets.
+// It's applied the next time the firewall is initialized. 0 to use the queue
+// of the connections.
+func SetDNSQueue(qNum uint16, bypass bool) {
+	dnsQueueNum = qNum
+	dnsQueueBypass = bypass
+}
+
+// SetQueueCount configures the number of queues, from the queue number, where
+// the connections are balanced to.
+// It's applied the next time the firewall is initialized. The repeat queue is
+// the next one after the range.
+func SetQueueCount(count uint16) {
+	if count == 0 {
+		count = 1
+	}
+	queueCount = count
+}
+
+// KeepRules configures the firewall to reuse the interception rules of a
+// previous instance of the daemon on start, if they're still loaded.
+func KeepRules(keep bool) {
+	common.KeepRules = keep
+}
+
+// OnRulesMissing sets the function to call when the interception rules have
+// been deleted externally.
+func OnRulesMissing(cb func()) {
+	common.OnRulesMissing = cb
+}
+
 // Init initializes the firewall and loads firewall rules.
-// We'll try to use the firewall configured in the configuration (iptables/nftables).
-// If iptables is not installed, we can add nftables rules directly to the kernel,
-// without relying on any binaries.
+// We'll try to use the firewall configured in the configuration (iptables/nftables/firewalld).
+// If iptables is not installed or firewalld is not running, we can add nftables
+// rules directly to the kernel, without relying on any binaries.
 func Init(fwType, configPath, monitorInterval string, bypassQueue bool, qNum uint16) (err error) {
 	confError := false
 	if fwType == "" {
@@ -57,6 +112,13 @@ func Init(fwType, configPath, monitorInterval string, bypassQueue bool, qNum uin
 		configPath = config.DefaultConfigFile
 	}
 
+	if fwType == firewalld.Name {
+		fw, err = firewalld.Fw()
+		if err != nil {
+			log.Warning("firewalld not available: %s", err)
+		}
+	}
+
 	if fwType == iptables.Name {
 		fw, err = iptables.Fw()
 		if err != nil {
@@ -72,14 +134,25 @@ func Init(fwType, configPath, monitorInterval string, bypassQueue bool, qNum uin
 	}
 
 	if err != nil {
-		return fmt.Errorf("firewall error: %s, not iptables nor nftables are available or are usable. Please, report it on github", err)
+		return fmt.Errorf("firewall error: %s, not iptables, nftables nor firewalld are available or are usable. Please, report it on github", err)
 	}
 
 	if fw == nil {
 		return fmt.Errorf("Firewall not initialized. Be sure that you're using latest configuration file. Report it on github if needed.")
 	}
 	fw.Stop()
+	fw.SetQueueCount(queueCount)
+	if dnsQueueNum != 0 && dnsQueueNum >= qNum && dnsQueueNum <= qNum+queueCount {
+		log.Warning("DNS queue #%d is one of the connections queues (#%d-#%d), sending DNS responses to queue #%d", dnsQueueNum, qNum, qNum+queueCount, qNum)
+		fw.SetDNSQueue(0, dnsQueueBypass)
+	} else {
+		fw.SetDNSQueue(dnsQueueNum, dnsQueueBypass)
+	}
 	fw.Init(qNum, configPath, monitorInterval, bypassQueue)
+	restorePanicMode()
+	restoreJails()
+	restoreDenyPage()
+	restoreProxy()
 	if confError {
 		log.Error("Firewall error: the default configuration seem to be outdated (default-config.json). Get latest configuration from github.")
 	}
@@ -127,6 +200,24 @@ func ReloadSystemRules() {
 	fw.AddSystemRules(common.ReloadRules, common.BackupChains)
 }
 
+// ValidateConfiguration checks that the system firewall configuration on disk
+// can be loaded, without applying it.
+func ValidateConfiguration() error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	return fw.ValidateConfiguration()
+}
+
+// ReloadConfiguration loads the system firewall configuration from disk
+// again, replacing the system rules. The rules are kept if it's not valid.
+func ReloadConfiguration() error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	return fw.LoadDiskConfiguration(common.ReloadConf)
+}
+
 // EnableInterception removes the rules to intercept outbound connections.
 func EnableInterception() error {
 	if fw == nil {
@@ -145,6 +236,41 @@ func DisableInterception() error {
 	return nil
 }
 
+// DropRetransmissions drops in kernel, for the given time, the retransmissions
+// of a TCP connection already denied.
+func DropRetransmissions(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint, timeout time.Duration) error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	return fw.DropRetransmissions(srcIP, srcPort, dstIP, dstPort, timeout)
+}
+
+// AddFlow accepts or drops in kernel, for the given time, the following
+// packets of a UDP flow already verdicted, without queueing them.
+func AddFlow(srcIP net.IP, srcPort uint, dstIP net.IP, dstPort uint, accept bool, timeout time.Duration) error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	return fw.AddFlow(srcIP, srcPort, dstIP, dstPort, accept, timeout)
+}
+
+// FlushFlows deletes the UDP flows added with AddFlow.
+func FlushFlows() error {
+	if fw == nil {
+		return fmt.Errorf("firewall not initialized")
+	}
+	return fw.FlushFlows()
+}
+
+// GetObjects returns the current values of the named objects of the system
+// firewall (counters, quotas, limits).
+func GetObjects() ([]*common.ObjectValues, error) {
+	if fw == nil {
+		return nil, fmt.Errorf("firewall not initialized")
+	}
+	return fw.GetObjects()
+}
+
 // Stop deletes the firewall rules, allowing network traffic.
 func Stop() {
 	if fw == nil {
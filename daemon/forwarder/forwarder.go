@@ -0,0 +1,252 @@
+// Package forwarder sends the TCP connections of the rules with action proxy
+// through the upstream proxies of the daemon (i.e.: force the connections of
+// a browser through a SOCKS proxy). The firewall redirects these connections
+// to a local port, where the forwarder looks up their destination and the
+// upstream proxy of their rule by their source port, and relays them.
+package forwarder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/firewall"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"golang.org/x/net/proxy"
+)
+
+var (
+	defaultPort = uint16(8078)
+	// time the connections redirected are remembered, waiting to be
+	// accepted.
+	redirectedTTL = time.Minute
+	maxRedirected = 4096
+	dialTimeout   = 30 * time.Second
+	setFirewall   = firewall.SetProxy
+	newDialer     = func(u *url.URL) (proxy.Dialer, error) {
+		return proxy.FromURL(u, &net.Dialer{Timeout: dialTimeout})
+	}
+)
+
+// Config holds the configuration of the forwarder.
+type Config struct {
+	// Port where the forwarder listens on the loopback interface (8078 by
+	// default).
+	Port uint16 `json:"Port"`
+	// Upstreams are the proxies the rules can name, as URLs:
+	// {"tor": "socks5://127.0.0.1:9050"}
+	Upstreams map[string]string `json:"Upstreams"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+type redirected struct {
+	time     time.Time
+	upstream string
+	// destination of the connection, by name if it's known, to let the
+	// upstream proxy resolve it.
+	dst string
+}
+
+// Forwarder relays the connections redirected through their upstream proxies.
+type Forwarder struct {
+	listeners []net.Listener
+	upstreams map[string]proxy.Dialer
+	// connections redirected, by source port.
+	redirected map[uint16]*redirected
+
+	cfg Config
+	sync.Mutex
+}
+
+// Default is the forwarder of the daemon.
+var Default = New()
+
+// New returns a new forwarder, disabled until it's configured.
+func New() *Forwarder {
+	return &Forwarder{redirected: make(map[uint16]*redirected)}
+}
+
+// SetConfig starts or stops the forwarder, and the redirection of the
+// connections of the proxy rules.
+func (f *Forwarder) SetConfig(cfg Config) error {
+	upstreams := make(map[string]proxy.Dialer, len(cfg.Upstreams))
+	for name, raw := range cfg.Upstreams {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("forwarder, invalid upstream %s: %s", name, err)
+		}
+		d, err := newDialer(u)
+		if err != nil {
+			return fmt.Errorf("forwarder, invalid upstream %s: %s", name, err)
+		}
+		upstreams[name] = d
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.stop()
+	if !cfg.Enabled {
+		return setFirewall(0)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = defaultPort
+	}
+	for _, ip := range []string{"127.0.0.1", "::1"} {
+		l, err := net.Listen("tcp", net.JoinHostPort(ip, strconv.Itoa(int(cfg.Port))))
+		if err != nil {
+			if ip == "::1" {
+				log.Debug("[forwarder] not listening on %s: %s", ip, err)
+				continue
+			}
+			f.stop()
+			setFirewall(0)
+			return fmt.Errorf("forwarder: %s", err)
+		}
+		go f.serve(l)
+		f.listeners = append(f.listeners, l)
+	}
+	if err := setFirewall(cfg.Port); err != nil {
+		f.stop()
+		return err
+	}
+	f.upstreams = upstreams
+	f.cfg = cfg
+	log.Info("[forwarder] connections of the proxy rules redirected to port %d", cfg.Port)
+	return nil
+}
+
+// Redirect remembers the upstream proxy of a connection, to relay it through
+// it once it's redirected. It returns false if the connection can't be
+// proxied (it's not TCP, or the upstream is not configured), and it must be
+// dropped.
+func (f *Forwarder) Redirect(con *conman.Connection, upstream string) bool {
+	if con == nil || !strings.HasPrefix(con.Protocol, "tcp") {
+		return false
+	}
+	f.Lock()
+	defer f.Unlock()
+	if !f.cfg.Enabled {
+		return false
+	}
+	if _, found := f.upstreams[upstream]; !found {
+		log.Warning("[forwarder] upstream proxy not configured: %s", upstream)
+		return false
+	}
+
+	now := time.Now()
+	if len(f.redirected) >= maxRedirected {
+		for port, r := range f.redirected {
+			if now.Sub(r.time) > redirectedTTL {
+				delete(f.redirected, port)
+			}
+		}
+		if len(f.redirected) >= maxRedirected {
+			return false
+		}
+	}
+	host := con.DstHost
+	if host == "" {
+		host = con.DstIP.String()
+	}
+	f.redirected[uint16(con.SrcPort)] = &redirected{
+		time:     now,
+		upstream: upstream,
+		dst:      net.JoinHostPort(host, strconv.FormatUint(uint64(con.DstPort), 10)),
+	}
+	return true
+}
+
+func (f *Forwarder) serve(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go f.forward(c)
+	}
+}
+
+// forward relays a connection redirected through its upstream proxy.
+func (f *Forwarder) forward(c net.Conn) {
+	defer c.Close()
+
+	r, dialer := f.take(c.RemoteAddr())
+	if r == nil {
+		log.Debug("[forwarder] unknown connection from %s", c.RemoteAddr())
+		return
+	}
+	up, err := dial(dialer, r.dst)
+	if err != nil {
+		log.Warning("[forwarder] error connecting to %s through %s: %s", r.dst, r.upstream, err)
+		return
+	}
+	defer up.Close()
+
+	done := make(chan struct{}, 2)
+	go relay(up, c, done)
+	go relay(c, up, done)
+	<-done
+	<-done
+}
+
+// take returns the connection redirected from the given address, forgetting
+// it, and the dialer of its upstream proxy.
+func (f *Forwarder) take(addr net.Addr) (*redirected, proxy.Dialer) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil, nil
+	}
+	f.Lock()
+	defer f.Unlock()
+	port := uint16(tcpAddr.Port)
+	r, found := f.redirected[port]
+	if !found {
+		return nil, nil
+	}
+	delete(f.redirected, port)
+	dialer, found := f.upstreams[r.upstream]
+	if !found {
+		return nil, nil
+	}
+	return r, dialer
+}
+
+func dial(dialer proxy.Dialer, addr string) (net.Conn, error) {
+	if d, ok := dialer.(proxy.ContextDialer); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+		defer cancel()
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+// relay copies the data from src to dst, until src is closed, and then
+// closes the writing side of dst.
+func relay(dst, src net.Conn, done chan<- struct{}) {
+	io.Copy(dst, src)
+	if c, ok := dst.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+	} else {
+		dst.Close()
+	}
+	done <- struct{}{}
+}
+
+// stop closes the listeners. The caller must hold the lock.
+func (f *Forwarder) stop() {
+	for _, l := range f.listeners {
+		l.Close()
+	}
+	f.listeners = nil
+	f.upstreams = nil
+	f.cfg = Config{}
+}
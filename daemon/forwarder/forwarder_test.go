@@ -0,0 +1,131 @@
+package forwarder
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"golang.org/x/net/proxy"
+)
+
+func freePort(t *testing.T) uint16 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+// testDialer connects to an echo server, remembering the addresses asked.
+type testDialer struct {
+	echo  string
+	addrs chan string
+}
+
+func (d *testDialer) Dial(network, addr string) (net.Conn, error) {
+	d.addrs <- addr
+	return net.Dial(network, d.echo)
+}
+
+func newEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				line, _ := bufio.NewReader(c).ReadString('\n')
+				fmt.Fprint(c, "echo: ", line)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestForwarder(t *testing.T) {
+	fwPort := uint16(1)
+	origSetFirewall, origNewDialer := setFirewall, newDialer
+	setFirewall = func(port uint16) error {
+		fwPort = port
+		return nil
+	}
+	dialer := &testDialer{echo: newEchoServer(t), addrs: make(chan string, 1)}
+	newDialer = func(u *url.URL) (proxy.Dialer, error) {
+		if u.Scheme != "socks5" {
+			return nil, fmt.Errorf("unsupported scheme: %s", u.Scheme)
+		}
+		return dialer, nil
+	}
+	defer func() { setFirewall, newDialer = origSetFirewall, origNewDialer }()
+
+	f := New()
+	srcPort := freePort(t)
+	con := &conman.Connection{
+		Protocol: "tcp",
+		SrcIP:    net.ParseIP("127.0.0.1"),
+		SrcPort:  uint(srcPort),
+		DstIP:    net.ParseIP("185.53.178.14"),
+		DstHost:  "opensnitch.io",
+		DstPort:  443,
+	}
+	if f.Redirect(con, "tor") {
+		t.Error("connection redirected with the forwarder disabled")
+	}
+
+	if err := f.SetConfig(Config{Enabled: true, Upstreams: map[string]string{"tor": "http://127.0.0.1:8080"}}); err == nil {
+		t.Error("unsupported upstream accepted")
+	}
+	port := freePort(t)
+	if err := f.SetConfig(Config{Enabled: true, Port: port, Upstreams: map[string]string{"tor": "socks5://127.0.0.1:9050"}}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer f.SetConfig(Config{})
+	if fwPort != port {
+		t.Error("firewall not configured:", fwPort)
+	}
+
+	udp := *con
+	udp.Protocol = "udp"
+	if f.Redirect(&udp, "tor") || f.Redirect(con, "i2p") {
+		t.Error("connection redirected, but it can't be proxied")
+	}
+	if !f.Redirect(con, "tor") {
+		t.Fatal("connection not redirected")
+	}
+
+	// the connection redirected by the firewall, from its source port.
+	d := net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: int(srcPort)}, Timeout: 5 * time.Second}
+	c, err := d.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal("forwarder not listening:", err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprint(c, "hello\n")
+	reply, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil || reply != "echo: hello\n" {
+		t.Errorf("connection not relayed: %q, %v", reply, err)
+	}
+	if addr := <-dialer.addrs; addr != "opensnitch.io:443" {
+		t.Error("invalid destination asked to the upstream:", addr)
+	}
+
+	if err := f.SetConfig(Config{}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	if fwPort != 0 || f.Redirect(con, "tor") {
+		t.Error("forwarder not disabled")
+	}
+}
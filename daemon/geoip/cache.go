@@ -0,0 +1,95 @@
+package geoip
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheStats holds the counters of the cache.
+type CacheStats struct {
+	Items     int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+type cacheItem struct {
+	key string
+	asn ASN
+}
+
+// asnCache is a LRU cache of the ASNs resolved, by IP.
+// Connections to the same destinations are very common, and decoding the
+// records of the database is more expensive than looking up a map.
+type asnCache struct {
+	items map[string]*list.Element
+	// items ordered by last access, used to evict the least recently used
+	// items when the cache is full.
+	lru *list.List
+	mu  sync.Mutex
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+
+	maxItems int
+}
+
+func newASNCache(maxItems int) *asnCache {
+	return &asnCache{
+		items:    make(map[string]*list.Element, maxItems),
+		lru:      list.New(),
+		maxItems: maxItems,
+	}
+}
+
+func (c *asnCache) get(key string) (ASN, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, found := c.items[key]
+	if !found {
+		c.misses.Add(1)
+		return ASN{}, false
+	}
+	c.hits.Add(1)
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheItem).asn, true
+}
+
+func (c *asnCache) add(key string, asn ASN) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, found := c.items[key]; found {
+		el.Value.(*cacheItem).asn = asn
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.lru.PushFront(&cacheItem{key: key, asn: asn})
+	for c.maxItems > 0 && len(c.items) > c.maxItems {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.items, el.Value.(*cacheItem).key)
+		c.evictions.Add(1)
+	}
+}
+
+// purge deletes all the items, i.e.: when the database is reloaded.
+func (c *asnCache) purge() {
+	c.mu.Lock()
+	c.items = make(map[string]*list.Element, c.maxItems)
+	c.lru.Init()
+	c.mu.Unlock()
+}
+
+func (c *asnCache) stats() CacheStats {
+	c.mu.Lock()
+	items := len(c.items)
+	c.mu.Unlock()
+	return CacheStats{
+		Items:     items,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
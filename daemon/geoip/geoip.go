@@ -0,0 +1,211 @@
+// Package geoip resolves the country and the autonomous system of IP
+// addresses, using MaxMind DB databases (MaxMind GeoLite2/GeoIP2, DB-IP lite, ...).
+package geoip
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+var (
+	// interval to check if the databases have changed on disk.
+	reloadInterval = time.Minute
+	// max number of ASNs resolved kept in memory.
+	defaultCacheSize = 4096
+)
+
+// Config holds the configuration of the databases.
+type Config struct {
+	// CountryDB is the path to a Country or City database, i.e.:
+	// /var/lib/GeoIP/GeoLite2-Country.mmdb
+	// Empty to disable the lookups.
+	CountryDB string `json:"CountryDB"`
+
+	// ASNDB is the path to an ASN database, i.e.:
+	// /var/lib/GeoIP/GeoLite2-ASN.mmdb
+	// Empty to disable the lookups.
+	ASNDB string `json:"ASNDB"`
+
+	// CacheSize is the max number of ASNs resolved kept in memory.
+	CacheSize int `json:"CacheSize"`
+}
+
+// ASN holds the details of an autonomous system.
+type ASN struct {
+	Org    string
+	Number uint64
+}
+
+// database is a database reloaded when it changes on disk.
+type database struct {
+	reader  atomic.Pointer[Reader]
+	onLoad  func()
+	path    string
+	modTime time.Time
+}
+
+// DB resolves the details of IPs.
+type DB struct {
+	cancel  context.CancelFunc
+	country *database
+	asn     *database
+	cache   *asnCache
+	sync.Mutex
+}
+
+// Default is the database used by the rules.
+var Default = &DB{}
+
+// SetConfig opens the configured databases, and starts monitoring them for
+// changes (i.e.: updated by geoipupdate).
+func (db *DB) SetConfig(cfg Config) error {
+	db.Lock()
+	defer db.Unlock()
+
+	if db.cancel != nil {
+		db.cancel()
+		db.cancel = nil
+	}
+	db.country = nil
+	db.asn = nil
+	db.cache = nil
+
+	dbs := []*database{}
+	if cfg.CountryDB != "" {
+		country := &database{path: cfg.CountryDB}
+		if err := country.load(); err != nil {
+			return err
+		}
+		db.country = country
+		dbs = append(dbs, country)
+	}
+	if cfg.ASNDB != "" {
+		cacheSize := cfg.CacheSize
+		if cacheSize <= 0 {
+			cacheSize = defaultCacheSize
+		}
+		cache := newASNCache(cacheSize)
+		asn := &database{path: cfg.ASNDB, onLoad: cache.purge}
+		if err := asn.load(); err != nil {
+			db.country = nil
+			return err
+		}
+		db.asn = asn
+		db.cache = cache
+		dbs = append(dbs, asn)
+	}
+	if len(dbs) == 0 {
+		return nil
+	}
+
+	var ctx context.Context
+	ctx, db.cancel = context.WithCancel(context.Background())
+	go db.monitor(ctx, dbs...)
+
+	return nil
+}
+
+// Country returns the ISO code of the country of an IP (ES, US, ...), or an
+// empty string if it's unknown.
+func (db *DB) Country(ip net.IP) string {
+	db.Lock()
+	country := db.country
+	db.Unlock()
+	if country == nil || ip == nil {
+		return ""
+	}
+	reader := country.reader.Load()
+	code, err := reader.Lookup(ip, "country", "iso_code")
+	if err != nil {
+		log.Debug("[geoip] error looking up %s: %s", ip, err)
+		return ""
+	}
+	if code == nil {
+		// anycast, satellite providers, ...
+		code, _ = reader.Lookup(ip, "registered_country", "iso_code")
+	}
+	iso, _ := code.(string)
+	return iso
+}
+
+// ASN returns the autonomous system of an IP. It returns false if it's
+// unknown, or the ASN database is not configured.
+func (db *DB) ASN(ip net.IP) (ASN, bool) {
+	db.Lock()
+	asnDB, cache := db.asn, db.cache
+	db.Unlock()
+	if asnDB == nil || ip == nil {
+		return ASN{}, false
+	}
+
+	key := string(ip.To16())
+	if asn, found := cache.get(key); found {
+		return asn, asn.Number != 0
+	}
+	rec, err := asnDB.reader.Load().Lookup(ip)
+	if err != nil {
+		log.Debug("[geoip] error looking up ASN of %s: %s", ip, err)
+		return ASN{}, false
+	}
+	asn := ASN{}
+	if m, ok := rec.(map[string]interface{}); ok {
+		asn.Number, _ = m["autonomous_system_number"].(uint64)
+		asn.Org, _ = m["autonomous_system_organization"].(string)
+	}
+	// unknown IPs are also cached, to not look them up again.
+	cache.add(key, asn)
+	return asn, asn.Number != 0
+}
+
+// CacheStats returns the counters of the ASNs cache.
+func (db *DB) CacheStats() CacheStats {
+	db.Lock()
+	cache := db.cache
+	db.Unlock()
+	if cache == nil {
+		return CacheStats{}
+	}
+	return cache.stats()
+}
+
+func (db *DB) monitor(ctx context.Context, dbs ...*database) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, d := range dbs {
+				modTime, err := core.GetFileModTime(d.path)
+				if err != nil || modTime.Equal(d.modTime) {
+					continue
+				}
+				if err := d.load(); err != nil {
+					log.Warning("[geoip] error reloading %s: %s", d.path, err)
+				}
+			}
+		}
+	}
+}
+
+func (d *database) load() error {
+	modTime, _ := core.GetFileModTime(d.path)
+	reader, err := OpenReader(d.path)
+	if err != nil {
+		return err
+	}
+	d.modTime = modTime
+	d.reader.Store(reader)
+	if d.onLoad != nil {
+		d.onLoad()
+	}
+	log.Info("[geoip] database loaded: %s (%s, %d nodes)", d.path, reader.Metadata.DatabaseType, reader.Metadata.NodeCount)
+	return nil
+}
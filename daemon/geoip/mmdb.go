@@ -0,0 +1,395 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+// Reader of MaxMind DB files (.mmdb), the format used by the MaxMind and
+// DB-IP databases:
+// https://maxmind.github.io/MaxMind-DB/
+type Reader struct {
+	buf  []byte
+	data decoder
+	// Metadata of the database
+	Metadata  Metadata
+	ipv4Start uint
+	nodeSize  uint
+}
+
+// Metadata holds the fields of the metadata we use.
+type Metadata struct {
+	DatabaseType string
+	BuildEpoch   uint64
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+}
+
+// types of the data section fields
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+var (
+	metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+	// the data section starts after the search tree and 16 bytes of zeros.
+	dataSectionSeparator uint = 16
+)
+
+// OpenReader reads a database from disk.
+func OpenReader(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(buf)
+}
+
+// NewReader parses a database.
+func NewReader(buf []byte) (*Reader, error) {
+	metaStart := bytes.LastIndex(buf, metadataMarker)
+	if metaStart == -1 {
+		return nil, fmt.Errorf("invalid database, metadata not found")
+	}
+	metaStart += len(metadataMarker)
+	meta, _, err := decoder{buf[metaStart:]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database metadata: %s", err)
+	}
+	metaMap, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid database metadata")
+	}
+
+	r := &Reader{buf: buf}
+	r.Metadata.DatabaseType, _ = metaMap["database_type"].(string)
+	r.Metadata.BuildEpoch, _ = metaMap["build_epoch"].(uint64)
+	nodeCount, _ := metaMap["node_count"].(uint64)
+	recordSize, _ := metaMap["record_size"].(uint64)
+	ipVersion, _ := metaMap["ip_version"].(uint64)
+	r.Metadata.NodeCount = uint(nodeCount)
+	r.Metadata.RecordSize = uint(recordSize)
+	r.Metadata.IPVersion = uint(ipVersion)
+
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size: %d", r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported ip version: %d", r.Metadata.IPVersion)
+	}
+	r.nodeSize = r.Metadata.RecordSize / 4
+	treeSize := r.nodeSize * r.Metadata.NodeCount
+	if treeSize+dataSectionSeparator > uint(metaStart-len(metadataMarker)) {
+		return nil, fmt.Errorf("invalid database, search tree size out of bounds")
+	}
+	r.data = decoder{buf[treeSize+dataSectionSeparator : metaStart-len(metadataMarker)]}
+
+	// IPv4 addresses are stored in IPv6 databases as ::a.b.c.d
+	if r.Metadata.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Lookup returns the value of the field of the record of the given IP,
+// following the path of map keys, i.e.: "country", "iso_code".
+// It returns nil if the IP or the field are not found.
+func (r *Reader) Lookup(ip net.IP, path ...string) (interface{}, error) {
+	offset, found, err := r.lookupOffset(ip)
+	if err != nil || !found {
+		return nil, err
+	}
+	return r.data.decodePath(offset, path)
+}
+
+func (r *Reader) lookupOffset(ip net.IP) (uint, bool, error) {
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = r.ipv4Start
+	} else if r.Metadata.IPVersion == 4 {
+		return 0, false, nil
+	}
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return 0, false, fmt.Errorf("invalid IP: %v", ip)
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < len(ip)*8 && node < nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-(i&7))) & 1
+		node = r.readRecord(node, bit)
+	}
+	if node == nodeCount {
+		return 0, false, nil
+	}
+	if node < nodeCount {
+		return 0, false, fmt.Errorf("invalid database, search tree too deep")
+	}
+	offset := node - nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data.buf)) {
+		return 0, false, fmt.Errorf("invalid database, data offset out of bounds")
+	}
+	return offset, true, nil
+}
+
+// readRecord returns the left (0) or right (1) record of a node.
+func (r *Reader) readRecord(node, bit uint) uint {
+	b := r.buf[node*r.nodeSize : node*r.nodeSize+r.nodeSize]
+	switch r.Metadata.RecordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// decoder of the data section.
+type decoder struct {
+	buf []byte
+}
+
+var errOutOfBounds = fmt.Errorf("invalid database, data out of bounds")
+
+// control reads the type and size of the field at offset.
+// For pointers, the size is the offset pointed to.
+func (d decoder) control(offset uint) (typ int, size, next uint, err error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errOutOfBounds
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ = int(ctrl >> 5)
+
+	if typ == typePointer {
+		ptrSize := uint((ctrl>>3)&0x3) + 1
+		if offset+ptrSize > uint(len(d.buf)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		b := d.buf[offset : offset+ptrSize]
+		vvv := uint(ctrl & 0x7)
+		switch ptrSize {
+		case 1:
+			size = vvv<<8 | uint(b[0])
+		case 2:
+			size = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 3:
+			size = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		default:
+			size = uint(binary.BigEndian.Uint32(b))
+		}
+		return typ, size, offset + ptrSize, nil
+	}
+
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size = uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, errOutOfBounds
+		}
+		b := d.buf[offset : offset+n]
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+		offset += n
+	}
+	return typ, size, offset, nil
+}
+
+// decode returns the value at offset, and the offset of the next field.
+func (d decoder) decode(offset uint) (interface{}, uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		// a pointer can't point to another pointer
+		if ptrTyp, _, _, err := d.control(size); err != nil || ptrTyp == typePointer {
+			return nil, 0, fmt.Errorf("invalid database, invalid pointer")
+		}
+		v, _, err := d.decode(size)
+		return v, offset, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			var k, v interface{}
+			if k, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("invalid database, map key is not a string")
+			}
+			if v, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var v interface{}
+			if v, offset, err = d.decode(offset); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errOutOfBounds
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte{}, b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid database, invalid double size: %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid database, invalid float size: %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid database, invalid uint size: %d", size)
+		}
+		var u uint64
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		return u, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid database, invalid int32 size: %d", size)
+		}
+		var u uint32
+		for _, c := range b {
+			u = u<<8 | uint32(c)
+		}
+		return int64(int32(u)), next, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), next, nil
+	}
+	return nil, 0, fmt.Errorf("invalid database, unknown data type: %d", typ)
+}
+
+// skip returns the offset of the field after the one at offset.
+func (d decoder) skip(offset uint) (uint, error) {
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return 0, err
+	}
+	switch typ {
+	case typePointer, typeBool:
+		return offset, nil
+	case typeMap:
+		size *= 2
+		fallthrough
+	case typeArray:
+		for i := uint(0); i < size; i++ {
+			if offset, err = d.skip(offset); err != nil {
+				return 0, err
+			}
+		}
+		return offset, nil
+	}
+	return offset + size, nil
+}
+
+// decodePath decodes only the field of the given path, skipping the rest.
+func (d decoder) decodePath(offset uint, path []string) (interface{}, error) {
+	for _, key := range path {
+		typ, size, next, err := d.control(offset)
+		if err != nil {
+			return nil, err
+		}
+		if typ == typePointer {
+			if typ, size, next, err = d.control(size); err != nil {
+				return nil, err
+			}
+		}
+		if typ != typeMap {
+			return nil, nil
+		}
+		offset = next
+		found := false
+		for i := uint(0); i < size; i++ {
+			var k interface{}
+			if k, offset, err = d.decode(offset); err != nil {
+				return nil, err
+			}
+			if k == key {
+				found = true
+				break
+			}
+			if offset, err = d.skip(offset); err != nil {
+				return nil, err
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+	}
+	v, _, err := d.decode(offset)
+	return v, err
+}
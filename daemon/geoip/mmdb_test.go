@@ -0,0 +1,272 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// field of the data section, already encoded.
+type field []byte
+
+func mmdbString(s string) field {
+	if len(s) >= 29 {
+		return append(field{byte(typeString<<5 | 29), byte(len(s) - 29)}, s...)
+	}
+	return append(field{byte(typeString<<5 | len(s))}, s...)
+}
+
+func mmdbUint(typ int, u uint64) field {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, u)
+	if typ < typeMap {
+		return append(field{byte(typ<<5 | 8)}, b...)
+	}
+	return append(field{8, byte(typ - 7)}, b...)
+}
+
+// mmdbMap encodes a map from a list of key, value pairs.
+func mmdbMap(kv ...field) field {
+	f := field{byte(typeMap<<5 | len(kv)/2)}
+	for _, e := range kv {
+		f = append(f, e...)
+	}
+	return f
+}
+
+func mmdbPointer(offset int) field {
+	return field{byte(typePointer<<5 | (offset>>8)&0x7), byte(offset)}
+}
+
+type mmdbNetwork struct {
+	net  string
+	data field
+}
+
+// buildMMDB builds a database with the given networks.
+// The data of every network is written to the data section in order.
+func buildMMDB(t *testing.T, ipVersion, recordSize int, networks []mmdbNetwork) []byte {
+	const empty = -1
+	type node [2]int
+	nodes := []node{{empty, empty}}
+	// data records are stored as -(offset + 2)
+	dataSection := []byte{}
+
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipNet.IP
+		ones, _ := ipNet.Mask.Size()
+		if ipVersion == 6 && len(ip) == net.IPv4len {
+			ip = append(make(net.IP, 12), ip...)
+			ones += 96
+		}
+		cur := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i>>3]>>(7-(i&7))) & 1
+			if i == ones-1 {
+				nodes[cur][bit] = -(len(dataSection) + 2)
+				break
+			}
+			if nodes[cur][bit] == empty {
+				nodes = append(nodes, node{empty, empty})
+				nodes[cur][bit] = len(nodes) - 1
+			}
+			cur = nodes[cur][bit]
+		}
+		dataSection = append(dataSection, n.data...)
+	}
+
+	nodeCount := len(nodes)
+	record := func(r int) uint32 {
+		switch {
+		case r == empty:
+			return uint32(nodeCount)
+		case r < 0:
+			return uint32(nodeCount + 16 + (-r - 2))
+		}
+		return uint32(r)
+	}
+	tree := []byte{}
+	for _, n := range nodes {
+		left, right := record(n[0]), record(n[1])
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte((left>>20)&0xf0|(right>>24)&0x0f),
+				byte(right>>16), byte(right>>8), byte(right))
+		default:
+			tree = binary.BigEndian.AppendUint32(tree, left)
+			tree = binary.BigEndian.AppendUint32(tree, right)
+		}
+	}
+
+	db := append(tree, make([]byte, 16)...)
+	db = append(db, dataSection...)
+	db = append(db, metadataMarker...)
+	db = append(db, mmdbMap(
+		mmdbString("database_type"), mmdbString("Test-Country"),
+		mmdbString("node_count"), mmdbUint(typeUint32, uint64(nodeCount)),
+		mmdbString("record_size"), mmdbUint(typeUint16, uint64(recordSize)),
+		mmdbString("ip_version"), mmdbUint(typeUint16, uint64(ipVersion)),
+		mmdbString("build_epoch"), mmdbUint(typeUint64, 1700000000),
+	)...)
+	return db
+}
+
+// testNetworks returns networks of several countries. The second one uses
+// pointers to the keys of the first one.
+func testNetworks() []mmdbNetwork {
+	au := mmdbMap(
+		mmdbString("continent"), mmdbMap(mmdbString("code"), mmdbString("OC")),
+		mmdbString("country"), mmdbMap(mmdbString("iso_code"), mmdbString("AU")),
+	)
+	// offset of "country" in the data section
+	countryKey := 1 + len(mmdbString("continent")) + len(mmdbMap(mmdbString("code"), mmdbString("OC")))
+	return []mmdbNetwork{
+		{"1.0.0.0/8", au},
+		{"2.0.0.0/16", mmdbMap(
+			mmdbPointer(countryKey), mmdbMap(mmdbString("iso_code"), mmdbString("ES")),
+		)},
+		{"3.0.0.0/8", mmdbMap(
+			mmdbString("registered_country"), mmdbMap(mmdbString("iso_code"), mmdbString("FR")),
+		)},
+		{"2001:db8::/32", mmdbMap(
+			mmdbString("country"), mmdbMap(mmdbString("iso_code"), mmdbString("DE")),
+		)},
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		ip      string
+		country interface{}
+	}{
+		{"1.1.1.1", "AU"},
+		{"2.0.255.1", "ES"},
+		{"2.1.0.1", nil},
+		{"4.4.4.4", nil},
+		{"2001:db8::1", "DE"},
+	}
+
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			networks := testNetworks()
+			if ipVersion == 4 {
+				networks = networks[:3]
+			}
+			r, err := NewReader(buildMMDB(t, ipVersion, recordSize, networks))
+			if err != nil {
+				t.Fatalf("ipv%d, record size %d: %s", ipVersion, recordSize, err)
+			}
+			if r.Metadata.DatabaseType != "Test-Country" || r.Metadata.BuildEpoch != 1700000000 {
+				t.Errorf("unexpected metadata: %+v", r.Metadata)
+			}
+			for _, test := range tests {
+				if ipVersion == 4 && test.ip == "2001:db8::1" {
+					test.country = nil
+				}
+				country, err := r.Lookup(net.ParseIP(test.ip), "country", "iso_code")
+				if err != nil {
+					t.Errorf("ipv%d, record size %d, %s: %s", ipVersion, recordSize, test.ip, err)
+				}
+				if country != test.country {
+					t.Errorf("ipv%d, record size %d, %s: expected %v, got %v", ipVersion, recordSize, test.ip, test.country, country)
+				}
+			}
+
+			continent, _ := r.Lookup(net.ParseIP("1.2.3.4"), "continent")
+			if m, ok := continent.(map[string]interface{}); !ok || m["code"] != "OC" {
+				t.Errorf("unexpected continent: %v", continent)
+			}
+		}
+	}
+
+	if _, err := NewReader([]byte("not a database")); err == nil {
+		t.Error("invalid database should return an error")
+	}
+}
+
+func TestCountry(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "country.mmdb")
+	if err := os.WriteFile(path, buildMMDB(t, 6, 28, testNetworks()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &DB{}
+	if err := db.SetConfig(Config{CountryDB: path}); err != nil {
+		t.Fatal(err)
+	}
+	defer db.SetConfig(Config{})
+
+	for ip, expected := range map[string]string{
+		"1.1.1.1":     "AU",
+		"3.3.3.3":     "FR",
+		"4.4.4.4":     "",
+		"2001:db8::a": "DE",
+	} {
+		if country := db.Country(net.ParseIP(ip)); country != expected {
+			t.Errorf("Country(%s) = %q, expected %q", ip, country, expected)
+		}
+	}
+
+	if err := db.SetConfig(Config{CountryDB: filepath.Join(dir, "missing.mmdb")}); err == nil {
+		t.Error("missing database should return an error")
+	}
+	if country := db.Country(net.ParseIP("1.1.1.1")); country != "" {
+		t.Errorf("expected no country without database, got %s", country)
+	}
+}
+
+func TestASN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	asnRecord := func(num uint64, org string) field {
+		return mmdbMap(
+			mmdbString("autonomous_system_number"), mmdbUint(typeUint32, num),
+			mmdbString("autonomous_system_organization"), mmdbString(org),
+		)
+	}
+	networks := []mmdbNetwork{
+		{"1.1.1.0/24", asnRecord(13335, "CLOUDFLARENET")},
+		{"8.8.8.0/24", asnRecord(15169, "GOOGLE")},
+	}
+	if err := os.WriteFile(path, buildMMDB(t, 6, 24, networks), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &DB{}
+	if err := db.SetConfig(Config{ASNDB: path, CacheSize: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer db.SetConfig(Config{})
+
+	for i := 0; i < 2; i++ {
+		asn, found := db.ASN(net.ParseIP("1.1.1.1"))
+		if !found || asn.Number != 13335 || asn.Org != "CLOUDFLARENET" {
+			t.Errorf("unexpected ASN of 1.1.1.1: %+v, %v", asn, found)
+		}
+	}
+	if stats := db.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Items != 1 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+	if asn, found := db.ASN(net.ParseIP("8.8.8.8")); !found || asn.Number != 15169 {
+		t.Errorf("unexpected ASN of 8.8.8.8: %+v, %v", asn, found)
+	}
+	if stats := db.CacheStats(); stats.Evictions != 1 || stats.Items != 1 {
+		t.Errorf("the least recently used item should have been evicted: %+v", stats)
+	}
+	if _, found := db.ASN(net.ParseIP("9.9.9.9")); found {
+		t.Error("9.9.9.9 should not have an ASN")
+	}
+	if country := db.Country(net.ParseIP("1.1.1.1")); country != "" {
+		t.Errorf("expected no country without database, got %s", country)
+	}
+}
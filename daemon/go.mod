@@ -7,6 +7,7 @@ go 1.25.0
 require (
 	github.com/cilium/ebpf v0.22.0
 	github.com/fsnotify/fsnotify v1.4.7
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/golang/protobuf v1.5.0
 	github.com/google/gopacket v1.1.19
 	github.com/google/nftables v0.2.0
@@ -0,0 +1,267 @@
+// Package hooks runs the executables configured by the rules when they match
+// a connection, to automate actions outside of the daemon: open a ticket,
+// add the destination to an external blocklist, notify someone...
+//
+// The hooks run asynchronously, so they don't delay the verdicts, with a
+// strict timeout, a minimal environment, and the connection matched as a
+// json record on stdin (the same record of the audit logger).
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/log/loggers"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// Verdicts a hook can be run on.
+const (
+	OnAllow = "allow"
+	OnDeny  = "deny"
+)
+
+var (
+	defaultTimeout = 5 * time.Second
+	maxTimeout     = time.Minute
+	// max hooks running at the same time. The rest are discarded, so a burst
+	// of connections doesn't spawn a burst of processes.
+	maxRunning = 8
+	// max output of a hook kept, to log it.
+	maxOutput = 4096
+
+	// owner of the hooks, replaced by the tests.
+	ownerUID uint32 = 0
+
+	// environment of the hooks, the one of the daemon is not inherited.
+	hookEnv = []string{
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"LANG=C",
+	}
+)
+
+// Hook is the executable a rule runs when it matches a connection.
+type Hook struct {
+	// Path of the executable, absolute. It must be owned by root, and
+	// neither it nor its directories can be writable by other users, since
+	// it runs with the privileges of the daemon.
+	Path string `json:"path"`
+
+	// On holds the verdicts the hook is run on: allow, deny. Empty to run it
+	// on both.
+	On []string `json:"on,omitempty"`
+
+	// Timeout of the hook (5s by default, 1m at most). The hook and its
+	// children are killed once it expires.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Validate checks the options of a hook, and the permissions of its
+// executable.
+func (h *Hook) Validate() error {
+	if !filepath.IsAbs(h.Path) {
+		return fmt.Errorf("invalid hook, it must be an absolute path: '%s'", h.Path)
+	}
+	for _, on := range h.On {
+		if on != OnAllow && on != OnDeny {
+			return fmt.Errorf("invalid hook verdict: %s", on)
+		}
+	}
+	if _, err := h.timeout(); err != nil {
+		return err
+	}
+	return checkExecutable(h.Path)
+}
+
+// Triggers returns true if the hook must be run on the given verdict.
+func (h *Hook) Triggers(allowed bool) bool {
+	if len(h.On) == 0 {
+		return true
+	}
+	verdict := OnDeny
+	if allowed {
+		verdict = OnAllow
+	}
+	for _, on := range h.On {
+		if on == verdict {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hook) timeout() (time.Duration, error) {
+	if h.Timeout == "" {
+		return defaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(h.Timeout)
+	if err != nil || timeout <= 0 || timeout > maxTimeout {
+		return 0, fmt.Errorf("invalid hook timeout '%s', it must be between 0 and %s", h.Timeout, maxTimeout)
+	}
+	return timeout, nil
+}
+
+// checkExecutable checks that a hook is a regular executable file owned by
+// root, and that neither the file nor its parent directories can be modified
+// by other users. Symbolic links are not allowed, they could point to another
+// file after the validation.
+func checkExecutable(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("invalid hook: %s", err)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("invalid hook %s: symbolic links are not allowed", path)
+	}
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("invalid hook %s: not an executable file", path)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Uid != ownerUID {
+		return fmt.Errorf("invalid hook %s: not owned by root", path)
+	}
+	if fi.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("invalid hook %s: writable by other users (%s)", path, fi.Mode().Perm())
+	}
+	return checkParentDirs(path)
+}
+
+// checkParentDirs checks that the directories of a hook are owned by root,
+// and not writable by other users, who could replace it. The sticky
+// directories (/tmp) are allowed, their entries can only be replaced by
+// their owners.
+// Both the directories of the path and the ones they link to are checked
+// (/bin -> /usr/bin).
+func checkParentDirs(path string) error {
+	dir := filepath.Dir(filepath.Clean(path))
+	if err := checkDirs(path, dir); err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("invalid hook: %s", err)
+	}
+	if resolved == dir {
+		return nil
+	}
+	return checkDirs(path, resolved)
+}
+
+func checkDirs(path, dir string) error {
+	for ; ; dir = filepath.Dir(dir) {
+		fi, err := os.Lstat(dir)
+		if err != nil {
+			return fmt.Errorf("invalid hook: %s", err)
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok || (st.Uid != 0 && st.Uid != ownerUID) {
+			return fmt.Errorf("invalid hook %s: directory %s not owned by root", path, dir)
+		}
+		// the permissions of the links are not used, and they can only be
+		// replaced by who can write to their directory.
+		if fi.Mode()&os.ModeSymlink == 0 && fi.Mode().Perm()&0022 != 0 && fi.Mode()&os.ModeSticky == 0 {
+			return fmt.Errorf("invalid hook %s: directory %s writable by other users (%s)", path, dir, fi.Mode().Perm())
+		}
+		if dir == "/" {
+			return nil
+		}
+	}
+}
+
+// Runner runs the hooks of the rules.
+type Runner struct {
+	running chan struct{}
+}
+
+// Default is the runner of the hooks of the daemon.
+var Default = NewRunner(maxRunning)
+
+// NewRunner returns a new runner, that runs up to max hooks at the same time.
+func NewRunner(max int) *Runner {
+	return &Runner{running: make(chan struct{}, max)}
+}
+
+// Run runs the hook of a rule in background, with the connection it has
+// matched. It returns false if the hook has not been run.
+func (r *Runner) Run(h *Hook, con *protocol.Connection, action, ruleName string, allowed bool) bool {
+	if h == nil || con == nil || !h.Triggers(allowed) {
+		return false
+	}
+	select {
+	case r.running <- struct{}{}:
+	default:
+		log.Warning("[hooks] %s: too many hooks running, discarded: %s", ruleName, h.Path)
+		return false
+	}
+	go func() {
+		defer func() { <-r.running }()
+		if err := run(h, con, action, ruleName); err != nil {
+			log.Warning("[hooks] %s: %s", ruleName, err)
+		}
+	}()
+	return true
+}
+
+func run(h *Hook, con *protocol.Connection, action, ruleName string) error {
+	// the executable may have been modified since the rule was loaded, and
+	// the rules added from the GUI are not validated.
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	timeout, _ := h.timeout()
+	event, err := json.Marshal(loggers.NewAuditRecord(con, action, ruleName))
+	if err != nil {
+		return fmt.Errorf("error serializing the connection: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out := &limitedBuffer{max: maxOutput}
+	cmd := exec.CommandContext(ctx, h.Path)
+	cmd.Dir = "/"
+	cmd.Env = append(append([]string{}, hookEnv...), "OPENSNITCH_RULE="+ruleName, "OPENSNITCH_ACTION="+action)
+	cmd.Stdin = bytes.NewReader(event)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// kill the children of the hook too.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	output := out.Bytes()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s killed, timeout (%s) expired", h.Path, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s, output: %s", h.Path, err, bytes.TrimSpace(output))
+	}
+	log.Debug("[hooks] %s: %s done, output: %s", ruleName, h.Path, bytes.TrimSpace(output))
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written, discarding the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
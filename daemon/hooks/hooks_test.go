@@ -0,0 +1,130 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func writeHook(t *testing.T, dir, name, script string, perm os.FileMode) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), perm); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(path, perm)
+	return path
+}
+
+func TestValidate(t *testing.T) {
+	// the hooks of the tests are owned by the user running them.
+	ownerUID = uint32(os.Getuid())
+	defer func() { ownerUID = 0 }()
+
+	dir := t.TempDir()
+	valid := writeHook(t, dir, "valid.sh", "true", 0700)
+	shared := writeHook(t, dir, "shared.sh", "true", 0777)
+	noexec := writeHook(t, dir, "noexec.sh", "true", 0600)
+	sharedDir := filepath.Join(dir, "shared")
+	os.Mkdir(sharedDir, 0777)
+	os.Chmod(sharedDir, 0777)
+	inSharedDir := writeHook(t, sharedDir, "hook.sh", "true", 0700)
+	stickyDir := filepath.Join(dir, "sticky")
+	os.Mkdir(stickyDir, 0777)
+	os.Chmod(stickyDir, 0777|os.ModeSticky)
+	inStickyDir := writeHook(t, stickyDir, "hook.sh", "true", 0700)
+	link := filepath.Join(dir, "link.sh")
+	os.Symlink(inSharedDir, link)
+	linkToValid := filepath.Join(dir, "link-valid.sh")
+	os.Symlink(valid, linkToValid)
+	// the directory linked is safe, but not the one of the link.
+	linkedDir := filepath.Join(sharedDir, "linked")
+	os.Symlink(dir, linkedDir)
+
+	if err := (&Hook{Path: valid, On: []string{OnDeny}, Timeout: "10s"}).Validate(); err != nil {
+		t.Error("valid hook rejected:", err)
+	}
+	if err := (&Hook{Path: inStickyDir}).Validate(); err != nil {
+		t.Error("hook in a sticky directory rejected:", err)
+	}
+	invalid := []*Hook{
+		{Path: "valid.sh"},
+		{Path: shared},
+		{Path: noexec},
+		{Path: inSharedDir},
+		{Path: link},
+		{Path: linkToValid},
+		{Path: filepath.Join(linkedDir, "valid.sh")},
+		{Path: dir},
+		{Path: filepath.Join(dir, "missing.sh")},
+		{Path: valid, On: []string{"reject"}},
+		{Path: valid, Timeout: "2h"},
+		{Path: valid, Timeout: "-1s"},
+	}
+	for _, h := range invalid {
+		if err := h.Validate(); err == nil {
+			t.Errorf("invalid hook accepted: %v", h)
+		}
+	}
+
+	// only root can own the hooks.
+	ownerUID = uint32(os.Getuid()) + 1
+	if err := (&Hook{Path: valid}).Validate(); err == nil {
+		t.Error("hook of another user accepted")
+	}
+
+	h := &Hook{Path: valid}
+	if !h.Triggers(true) || !h.Triggers(false) {
+		t.Error("hook without verdicts not triggered")
+	}
+	h.On = []string{OnDeny}
+	if h.Triggers(true) || !h.Triggers(false) {
+		t.Error("hook triggered on the wrong verdict")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "event.json")
+	hook := writeHook(t, dir, "hook.sh", "cat > "+out+".tmp; echo \"$OPENSNITCH_RULE $OPENSNITCH_ACTION $HOME\" >> "+out+".tmp; mv "+out+".tmp "+out, 0700)
+	con := &protocol.Connection{Protocol: "tcp", DstIp: "1.1.1.1", DstPort: 443, ProcessPath: "/usr/bin/curl"}
+
+	r := NewRunner(1)
+	if r.Run(&Hook{Path: hook, On: []string{OnAllow}}, con, "deny", "deny-curl", false) {
+		t.Error("hook run on the wrong verdict")
+	}
+	if !r.Run(&Hook{Path: hook}, con, "deny", "deny-curl", false) {
+		t.Fatal("hook not run")
+	}
+	var raw []byte
+	for i := 0; i < 50 && raw == nil; i++ {
+		time.Sleep(100 * time.Millisecond)
+		raw, _ = os.ReadFile(out)
+	}
+	event := string(raw)
+	if !strings.Contains(event, `"dst_ip":"1.1.1.1"`) {
+		t.Errorf("connection not received on stdin: %s", event)
+	}
+	if !strings.Contains(event, "deny-curl deny \n") {
+		t.Errorf("unexpected environment: %s", event)
+	}
+
+	// the hooks are killed when the timeout expires.
+	slow := writeHook(t, dir, "slow.sh", "sleep 30", 0700)
+	start := time.Now()
+	if err := run(&Hook{Path: slow, Timeout: "200ms"}, con, "deny", "deny-curl"); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Error("slow hook not killed:", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("slow hook killed too late:", time.Since(start))
+	}
+
+	// the hooks exceeding the limit are discarded.
+	r.running <- struct{}{}
+	if r.Run(&Hook{Path: hook}, con, "deny", "deny-curl", false) {
+		t.Error("hook run beyond the limit")
+	}
+}
@@ -0,0 +1,128 @@
+package i18n
+
+// IDs of the messages. The IDs must not change once released, because the
+// translations of the UIs are looked up by them.
+const (
+	// errors of the netfilter queues.
+	QueueCreateError       ID = "queue.create_error"
+	RepeatQueueCreateError ID = "queue.repeat_create_error"
+	DNSQueueCreateError    ID = "queue.dns_create_error"
+	QueueNotRead           ID = "queue.not_read"
+	QueueNotVerdicted      ID = "queue.not_verdicted"
+
+	// the connections are not being processed.
+	ConnectionsStalled         ID = "connections.stalled"
+	ConnectionsStalledBypass   ID = "connections.stalled_bypass"
+	ConnectionsRecovered       ID = "connections.recovered"
+	ConnectionsRecoveredBypass ID = "connections.recovered_bypass"
+
+	FirewallWiped      ID = "firewall.wiped"
+	FirewallWipedTitle ID = "firewall.wiped.title"
+
+	ConfigLoadError  ID = "config.load_error"
+	ReloadError      ID = "config.reload_error"
+	ProcMonitorError ID = "procmon.method_error"
+	EbpfDNSError     ID = "ebpf.dns_error"
+	UpgradeError     ID = "upgrade.handover_error"
+
+	// updates of the daemon.
+	UpdateAvailable      ID = "updater.available"
+	UpdateAvailableTitle ID = "updater.available.title"
+
+	PanicModeEnabled  ID = "panic.enabled"
+	PanicModeDisabled ID = "panic.disabled"
+	AppOffline        ID = "offline.app"
+
+	// alerts of the connections.
+	NewBinary               ID = "alert.new_binary"
+	NewBinaryTitle          ID = "alert.new_binary.title"
+	ChecksumMismatch        ID = "alert.checksum_mismatch"
+	ChecksumMismatchTitle   ID = "alert.checksum_mismatch.title"
+	ChecksumQuarantine      ID = "alert.checksum_quarantine"
+	ChecksumQuarantineTitle ID = "alert.checksum_quarantine.title"
+	TaggedConnection        ID = "alert.tagged_connection"
+	TaggedConnectionTitle   ID = "alert.tagged_connection.title"
+
+	// sockets listening for connections.
+	NewListener         ID = "listener.new"
+	NewListenerTitle    ID = "listener.new.title"
+	PrivilegedPort      ID = "listener.privileged_port"
+	PrivilegedPortTitle ID = "listener.privileged_port.title"
+	ListenerDenied      ID = "listener.denied"
+
+	// page of the HTTP connections denied.
+	DenyPage      ID = "denypage.text"
+	DenyPageTitle ID = "denypage.title"
+
+	// policy audits.
+	PolicyAudit           ID = "audit.summary"
+	PolicyAuditTitle      ID = "audit.summary.title"
+	AuditUnusedRule       ID = "audit.unused_rule"
+	AuditBroadRule        ID = "audit.broad_rule"
+	AuditUnpackagedBinary ID = "audit.unpackaged_binary"
+	AuditFirewallModified ID = "audit.firewall_modified"
+	AuditSingleFamily     ID = "audit.single_family"
+
+	// rules suggested in the prompts.
+	SuggestDomain ID = "suggest.domain"
+	SuggestPort   ID = "suggest.port"
+)
+
+// catalog holds the English texts of the messages.
+var catalog = map[ID]string{
+	QueueCreateError:       "Error creating queue #{queue}: {error}",
+	RepeatQueueCreateError: "Error creating repeat queue #{queue}: {error}",
+	DNSQueueCreateError:    "Error creating DNS queue #{queue}: {error}",
+	QueueNotRead:           "queue {queue}: {packets} packets not read by the daemon",
+	QueueNotVerdicted:      "queue {queue}: {packets} packets waiting for a verdict",
+
+	ConnectionsStalled:         "Connections are not being processed ({reason}), connections will be dropped",
+	ConnectionsStalledBypass:   "Connections are not being processed ({reason}), interception disabled, connections will be allowed",
+	ConnectionsRecovered:       "Connections are being processed again",
+	ConnectionsRecoveredBypass: "Connections are being processed again, interception enabled",
+
+	FirewallWiped:      "The firewall rules to intercept connections have been deleted or modified externally, restoring them",
+	FirewallWipedTitle: "Firewall rules deleted",
+
+	ConfigLoadError:  "Error loading the configuration: {error}",
+	ReloadError:      "Unable to reload the daemon, the previous configuration is kept: {error}",
+	ProcMonitorError: "Unable to set process monitor method via parameter: {error}",
+	EbpfDNSError:     "EBPF-DNS: Unable to attach ebpf listener: {error}",
+	UpgradeError:     "[upgrade] unable to hand over to the new instance: {error}",
+
+	UpdateAvailable:      "opensnitchd {version} is available (running {current}), staged at {path}",
+	UpdateAvailableTitle: "opensnitchd {version} available",
+
+	PanicModeEnabled:  "Panic mode enabled, new outbound connections are blocked",
+	PanicModeDisabled: "Panic mode disabled, new outbound connections are intercepted again",
+	AppOffline:        "{path} and its children are offline for {duration}, {closed} connections closed",
+
+	NewBinary:               "{path} has opened a connection to {ip}:{port} for the first time",
+	NewBinaryTitle:          "New binary connecting to the network",
+	ChecksumMismatch:        "The checksum of {path} has changed since the last time it was seen",
+	ChecksumMismatchTitle:   "Binary modified",
+	ChecksumQuarantine:      "The checksum of {path} doesn't match the rule {rule}, its connections are denied for {duration}",
+	ChecksumQuarantineTitle: "Binary quarantined",
+	TaggedConnection:        "{path} has opened a connection to {ip}:{port}, tagged as {tag} by the rule {rule}",
+	TaggedConnectionTitle:   "Connection tagged as {tag}",
+
+	NewListener:         "{path} ({pid}) is listening for connections for the first time, on {proto} {ip}:{port}",
+	NewListenerTitle:    "New process listening for connections",
+	PrivilegedPort:      "{path} ({pid}) has bound the privileged port {proto} {ip}:{port}",
+	PrivilegedPortTitle: "Privileged port bound",
+	ListenerDenied:      "{path} ({pid}) listening on {proto} {ip}:{port} denied by the rule {rule}, socket closed",
+
+	DenyPage:      "The connection of {path} to {host} has been blocked by the rule {rule}",
+	DenyPageTitle: "Blocked by OpenSnitch",
+
+	PolicyAudit:           "policy audit: {findings} findings ({checks})",
+	PolicyAuditTitle:      "Policy audit: {findings} findings",
+	AuditUnusedRule:       "the rule hasn't matched any connection since the daemon started",
+	AuditBroadRule:        "the rule allows connections of any process to any destination",
+	AuditUnpackagedBinary: "the rule allows a binary {status}",
+	AuditFirewallModified: "firewall rules modified externally at {time}",
+	AuditSingleFamily:     "the rule only applies to {family} connections",
+
+	SuggestDomain: "{path} connected to {count} hosts of {domain} in the last {window}, allow *.{domain}",
+	SuggestPort:   "{path} connected to {count} hosts on port {port} in the last {window}, allow any host on port {port}",
+}
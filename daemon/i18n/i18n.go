@@ -0,0 +1,133 @@
+// Package i18n identifies the messages shown to the users, so the UIs can
+// translate them.
+//
+// Every message has an ID, and parameters referenced from the text of the
+// message as {name}. The daemon only knows the English texts of the messages
+// (see catalog.go), the UIs look up the translations by ID, falling back to
+// the English text if there's no translation:
+//
+//	{"id": "queue.create_error", "params": {"queue": "0", "error": "..."}, "text": "Error creating queue #0: ..."}
+//
+// A parameter may be another message: its value is the ID of the message, and
+// its parameters are added to the ones of the message that contains it.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ID identifies a message.
+type ID string
+
+// Message is a message shown to the users, with the values of its parameters.
+type Message struct {
+	ID     ID                `json:"id"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// encoded is the format of the messages sent to the UIs.
+type encoded struct {
+	Message
+	Text string `json:"text"`
+}
+
+var paramRegexp = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// New returns a message with the given parameters, as name, value pairs:
+// New(QueueCreateError, "queue", 0, "error", err)
+func New(id ID, params ...interface{}) Message {
+	m := Message{ID: id}
+	if len(params) > 1 {
+		m.Params = make(map[string]string, len(params)/2)
+	}
+	for i := 0; i+1 < len(params); i += 2 {
+		name := fmt.Sprint(params[i])
+		inner, ok := params[i+1].(Message)
+		if !ok {
+			m.Params[name] = fmt.Sprint(params[i+1])
+			continue
+		}
+		m.Params[name] = string(inner.ID)
+		for k, v := range inner.Params {
+			if _, found := m.Params[k]; !found {
+				m.Params[k] = v
+			}
+		}
+	}
+	return m
+}
+
+// IsEmpty returns true if the message has no ID.
+func (m Message) IsEmpty() bool {
+	return m.ID == ""
+}
+
+// String returns the English text of the message.
+// The parameters not defined are left as is, and the messages not found in
+// the catalog are returned as the ID followed by the parameters.
+func (m Message) String() string {
+	text, found := catalog[m.ID]
+	if !found {
+		if len(m.Params) == 0 {
+			return string(m.ID)
+		}
+		return fmt.Sprint(m.ID, " ", m.Params)
+	}
+	return Format(text, m.Params)
+}
+
+// Encode returns the message in JSON, with its ID, its parameters and its
+// English text.
+func (m Message) Encode() string {
+	buf, err := json.Marshal(encoded{Message: m, Text: m.String()})
+	if err != nil {
+		return m.String()
+	}
+	return string(buf)
+}
+
+// Decode parses a message encoded with Encode().
+func Decode(s string) (Message, bool) {
+	if !strings.HasPrefix(s, "{") {
+		return Message{}, false
+	}
+	var e encoded
+	if err := json.Unmarshal([]byte(s), &e); err != nil || e.ID == "" {
+		return Message{}, false
+	}
+	return e.Message, true
+}
+
+// Format replaces the {name} parameters of a text with their values.
+// The values that are the ID of a message are replaced with its text.
+func Format(text string, params map[string]string) string {
+	return paramRegexp.ReplaceAllStringFunc(text, func(p string) string {
+		v, found := params[p[1:len(p)-1]]
+		if !found {
+			return p
+		}
+		if inner, found := catalog[ID(v)]; found {
+			// only one level of nested messages.
+			return paramRegexp.ReplaceAllStringFunc(inner, func(p string) string {
+				if v, found := params[p[1:len(p)-1]]; found {
+					return v
+				}
+				return p
+			})
+		}
+		return v
+	})
+}
+
+// Catalog returns the English texts of the messages, by ID, to translate
+// them.
+func Catalog() map[ID]string {
+	texts := make(map[ID]string, len(catalog))
+	for id, text := range catalog {
+		texts[id] = text
+	}
+	return texts
+}
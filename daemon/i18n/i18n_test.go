@@ -0,0 +1,81 @@
+package i18n
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	t.Run("New", func(t *testing.T) {
+		m := New(QueueCreateError, "queue", 0, "error", errors.New("permission denied"))
+		if m.ID != QueueCreateError || m.Params["queue"] != "0" || m.Params["error"] != "permission denied" {
+			t.Error("invalid message:", m)
+		}
+		if s := m.String(); s != "Error creating queue #0: permission denied" {
+			t.Error("invalid text:", s)
+		}
+		if m.IsEmpty() || !(Message{}).IsEmpty() {
+			t.Error("IsEmpty() error")
+		}
+	})
+	t.Run("nested", func(t *testing.T) {
+		reason := New(QueueNotRead, "queue", 1, "packets", 3)
+		m := New(ConnectionsStalled, "reason", reason)
+		if m.Params["reason"] != string(QueueNotRead) || m.Params["packets"] != "3" {
+			t.Error("invalid nested message params:", m.Params)
+		}
+		if s := m.String(); s != "Connections are not being processed (queue 1: 3 packets not read by the daemon), connections will be dropped" {
+			t.Error("invalid nested message text:", s)
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		if s := New("unknown.id").String(); s != "unknown.id" {
+			t.Error("invalid text of unknown message:", s)
+		}
+		if s := Format("{path} {ip}", map[string]string{"path": "/bin/curl"}); s != "/bin/curl {ip}" {
+			t.Error("invalid text with missing params:", s)
+		}
+	})
+	t.Run("Encode", func(t *testing.T) {
+		m := New(ChecksumMismatch, "path", "/usr/bin/curl")
+		m2, ok := Decode(m.Encode())
+		if !ok || m2.ID != m.ID || m2.Params["path"] != "/usr/bin/curl" {
+			t.Error("message not decoded:", m.Encode(), m2)
+		}
+		for _, s := range []string{"", "Error creating queue", "{}", `{"text": "hello"}`} {
+			if _, ok := Decode(s); ok {
+				t.Error("invalid message decoded:", s)
+			}
+		}
+	})
+}
+
+func TestCatalog(t *testing.T) {
+	for id, text := range Catalog() {
+		if id == "" || text == "" {
+			t.Errorf("invalid catalog entry: %q: %q", id, text)
+		}
+	}
+	ids := []ID{
+		QueueCreateError, RepeatQueueCreateError, DNSQueueCreateError, QueueNotRead, QueueNotVerdicted,
+		ConnectionsStalled, ConnectionsStalledBypass, ConnectionsRecovered, ConnectionsRecoveredBypass,
+		FirewallWiped, FirewallWipedTitle,
+		ConfigLoadError, ReloadError, ProcMonitorError, EbpfDNSError, UpgradeError,
+		UpdateAvailable, UpdateAvailableTitle,
+		PanicModeEnabled, PanicModeDisabled, AppOffline,
+		NewBinary, NewBinaryTitle, ChecksumMismatch, ChecksumMismatchTitle, ChecksumQuarantine, ChecksumQuarantineTitle,
+		TaggedConnection, TaggedConnectionTitle,
+		NewListener, NewListenerTitle, PrivilegedPort, PrivilegedPortTitle, ListenerDenied,
+		DenyPage, DenyPageTitle,
+		PolicyAudit, PolicyAuditTitle, AuditUnusedRule, AuditBroadRule, AuditUnpackagedBinary, AuditFirewallModified, AuditSingleFamily,
+		SuggestDomain, SuggestPort,
+	}
+	for _, id := range ids {
+		if _, found := catalog[id]; !found {
+			t.Error("message without English text:", id)
+		}
+	}
+	if len(ids) != len(catalog) {
+		t.Error("messages not tested:", len(catalog)-len(ids))
+	}
+}
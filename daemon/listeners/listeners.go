@@ -0,0 +1,352 @@
+// Package listeners monitors the sockets listening for connections, to report
+// the processes that listen for connections for the first time or that bind
+// privileged ports, and to close the listeners denied by the rules with the
+// operand listener.
+package listeners
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// Events reported.
+const (
+	// a process is listening for connections for the first time since the
+	// daemon started.
+	EventNewListener = "new-listener"
+	// a process has bound a port lower than 1024.
+	EventPrivilegedPort = "privileged-port"
+	// a listener has been denied by a rule, and its socket closed.
+	EventDenied = "denied"
+)
+
+// ports lower than this one can only be bound by privileged processes.
+const privilegedPorts = 1024
+
+var (
+	defaultInterval = 5 * time.Second
+	portRangeFile   = "/proc/sys/net/ipv4/ip_local_port_range"
+
+	// ports assigned to the sockets not bound explicitly.
+	ephemeralFirst, ephemeralLast = uint16(32768), uint16(60999)
+)
+
+// Config holds the configuration of the monitor.
+type Config struct {
+	// Interval between checks of the listening sockets (5s by default).
+	Interval string `json:"Interval"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+// Listener is a socket listening for connections.
+type Listener struct {
+	Process *procmon.Process
+	Proto   string
+	IP      net.IP
+	Port    uint16
+	UID     uint32
+	INode   uint32
+
+	sock   *netlink.Socket
+	family uint8
+	proto  uint8
+}
+
+func (l *Listener) key() string {
+	return fmt.Sprint(l.Proto, l.IP, l.Port, l.INode)
+}
+
+// Connection returns the listener as a connection, to evaluate it against the
+// rules.
+func (l *Listener) Connection() *conman.Connection {
+	return conman.NewListener(l.Process, l.Proto, l.IP, uint(l.Port), int(l.UID), int(l.INode))
+}
+
+// Event is reported for every listener new, or denied.
+type Event struct {
+	Listener *Listener
+	// rule that has denied the listener, with the event EventDenied.
+	Rule    *rule.Rule
+	Kind    string
+	Message i18n.Message
+}
+
+type sockType struct {
+	name  string
+	fam   uint8
+	proto uint8
+}
+
+var sockTypes = []sockType{
+	{"tcp", syscall.AF_INET, syscall.IPPROTO_TCP},
+	{"tcp6", syscall.AF_INET6, syscall.IPPROTO_TCP},
+	{"udp", syscall.AF_INET, syscall.IPPROTO_UDP},
+	{"udp6", syscall.AF_INET6, syscall.IPPROTO_UDP},
+}
+
+// functions to get the sockets from the system, replaced in the tests.
+var (
+	dumpSockets = netlink.SocketsDump
+	closeSocket = netlink.SocketKill
+	findProcess = func(s *netlink.Socket) *procmon.Process {
+		inodeKey := fmt.Sprint(s.INode, s.ID.Source, s.ID.SourcePort, s.ID.Destination, s.ID.DestinationPort)
+		pid := procmon.GetPIDFromINode(int(s.INode), inodeKey)
+		if pid == -1 {
+			return nil
+		}
+		return procmon.FindProcess(pid, false)
+	}
+)
+
+// Monitor checks periodically the sockets listening for connections.
+type Monitor struct {
+	rules   *rule.Loader
+	onEvent func(ev *Event)
+	stop    chan struct{}
+
+	// listeners found in the last check, nil until the first one.
+	seen map[string]struct{}
+	// binaries that have listened for connections.
+	binaries map[string]struct{}
+	// generation of the rules the listeners have been evaluated with.
+	generation uint64
+
+	cfg Config
+	sync.Mutex
+}
+
+// Default is the monitor of the daemon.
+var Default = New()
+
+// New returns a new monitor, disabled until it's configured.
+func New() *Monitor {
+	return &Monitor{binaries: make(map[string]struct{})}
+}
+
+// SetRules sets the rules to evaluate the listeners with.
+func (m *Monitor) SetRules(rules *rule.Loader) {
+	m.Lock()
+	defer m.Unlock()
+	m.rules = rules
+}
+
+// OnEvent registers the function to call with every event.
+func (m *Monitor) OnEvent(cb func(ev *Event)) {
+	m.Lock()
+	defer m.Unlock()
+	m.onEvent = cb
+}
+
+// SetConfig applies a new configuration, restarting the monitor.
+func (m *Monitor) SetConfig(cfg Config) error {
+	m.Lock()
+	defer m.Unlock()
+
+	interval := defaultInterval
+	if cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid listeners interval '%s'", cfg.Interval)
+		}
+	}
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	m.cfg = cfg
+	m.seen = nil
+	m.binaries = make(map[string]struct{})
+	if !cfg.Enabled {
+		return nil
+	}
+	readEphemeralPorts()
+	log.Info("[listeners] checking the listening sockets every %s", interval)
+	m.stop = make(chan struct{})
+	go m.run(m.stop, interval)
+	return nil
+}
+
+func (m *Monitor) run(stop chan struct{}, interval time.Duration) {
+	m.Check()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+// Check lists the sockets listening for connections, and reports the new
+// ones. The listeners found by the first check are not reported, but they're
+// evaluated against the rules like the rest. When the rules change, all the
+// listeners are evaluated again.
+func (m *Monitor) Check() {
+	listeners := list()
+
+	m.Lock()
+	rules, cb := m.rules, m.onEvent
+	previous, first := m.seen, m.seen == nil
+	m.seen = make(map[string]struct{}, len(listeners))
+	for _, l := range listeners {
+		m.seen[l.key()] = struct{}{}
+	}
+	rulesChanged := false
+	if rules != nil && rules.Generation() != m.generation {
+		m.generation = rules.Generation()
+		rulesChanged = true
+	}
+	m.Unlock()
+
+	for _, l := range listeners {
+		_, found := previous[l.key()]
+		if found && !rulesChanged {
+			continue
+		}
+		if ev := deny(l, rules); ev != nil {
+			m.report(cb, ev)
+			continue
+		}
+		if found {
+			continue
+		}
+
+		m.Lock()
+		_, seenBinary := m.binaries[l.Process.Path]
+		m.binaries[l.Process.Path] = struct{}{}
+		m.Unlock()
+		if first {
+			continue
+		}
+		if !seenBinary {
+			m.report(cb, newEvent(EventNewListener, l, i18n.NewListener))
+		}
+		if l.Port < privilegedPorts {
+			m.report(cb, newEvent(EventPrivilegedPort, l, i18n.PrivilegedPort))
+		}
+	}
+}
+
+func (m *Monitor) report(cb func(ev *Event), ev *Event) {
+	log.Info("[listeners] %s", ev.Message)
+	if cb != nil {
+		cb(ev)
+	}
+}
+
+func newEvent(kind string, l *Listener, id i18n.ID, params ...interface{}) *Event {
+	return &Event{
+		Kind:     kind,
+		Listener: l,
+		Message: i18n.New(id, append([]interface{}{
+			"path", l.Process.Path, "pid", l.Process.ID, "proto", l.Proto, "ip", l.IP, "port", l.Port,
+		}, params...)...),
+	}
+}
+
+// deny closes the socket of a listener denied by the rules, and kills its
+// process if the rule is configured to do so.
+func deny(l *Listener, rules *rule.Loader) *Event {
+	if rules == nil {
+		return nil
+	}
+	r := rules.FindFirstListenerMatch(l.Connection())
+	if r == nil || r.Action.Allows() {
+		return nil
+	}
+	if err := closeSocket(l.family, l.proto, l.sock.ID); err != nil {
+		log.Warning("[listeners] %s: unable to close the socket %s %s:%d of %s: %s", r.Name, l.Proto, l.IP, l.Port, l.Process.Path, err)
+		return nil
+	}
+	if sig, kill := r.KillSignal(); kill {
+		if err := l.Process.Kill(sig); err != nil {
+			log.Warning("[%s] unable to kill %s (%d): %s", r.Name, l.Process.Path, l.Process.ID, err)
+		}
+	}
+	ev := newEvent(EventDenied, l, i18n.ListenerDenied, "rule", r.Name)
+	ev.Rule = r
+	return ev
+}
+
+// list returns the sockets listening for connections: TCP sockets in the
+// LISTEN state, and UDP sockets not connected bound to a port out of the
+// ephemeral range. The sockets of the kernel, without process, are excluded.
+func list() []*Listener {
+	listeners := []*Listener{}
+	for _, st := range sockTypes {
+		socks, err := dumpSockets(st.fam, st.proto)
+		if err != nil {
+			log.Debug("[listeners] unable to dump %s sockets: %s", st.name, err)
+			continue
+		}
+		for _, s := range socks {
+			if !isListening(st.proto, s) || s.INode == 0 {
+				continue
+			}
+			proc := findProcess(s)
+			if proc == nil || proc.Path == "" {
+				log.Debug("[listeners] process not found for %s %s:%d", st.name, s.ID.Source, s.ID.SourcePort)
+				continue
+			}
+			listeners = append(listeners, &Listener{
+				Process: proc,
+				Proto:   st.name,
+				IP:      s.ID.Source,
+				Port:    s.ID.SourcePort,
+				UID:     s.UID,
+				INode:   s.INode,
+				sock:    s,
+				family:  st.fam,
+				proto:   st.proto,
+			})
+		}
+	}
+	return listeners
+}
+
+func isListening(proto uint8, s *netlink.Socket) bool {
+	if proto == syscall.IPPROTO_TCP {
+		return s.State == netlink.TCP_LISTEN
+	}
+	// the UDP clients usually send datagrams from sockets not connected,
+	// bound to ephemeral ports.
+	return s.State == netlink.TCP_CLOSE &&
+		s.ID.DestinationPort == 0 &&
+		(s.ID.SourcePort < ephemeralFirst || s.ID.SourcePort > ephemeralLast)
+}
+
+// readEphemeralPorts reads the range of ephemeral ports configured.
+func readEphemeralPorts() {
+	raw, err := os.ReadFile(portRangeFile)
+	if err != nil {
+		return
+	}
+	fields := strings.Fields(string(raw))
+	if len(fields) != 2 {
+		return
+	}
+	first, err1 := strconv.ParseUint(fields[0], 10, 16)
+	last, err2 := strconv.ParseUint(fields[1], 10, 16)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	ephemeralFirst, ephemeralLast = uint16(first), uint16(last)
+}
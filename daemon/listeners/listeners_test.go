@@ -0,0 +1,161 @@
+package listeners
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+type fakeSystem struct {
+	socks  map[uint8][]*netlink.Socket
+	procs  map[uint32]*procmon.Process
+	closed []uint32
+}
+
+func newFakeSystem(t *testing.T) *fakeSystem {
+	sys := &fakeSystem{
+		socks: make(map[uint8][]*netlink.Socket),
+		procs: make(map[uint32]*procmon.Process),
+	}
+	origDump, origClose, origFind := dumpSockets, closeSocket, findProcess
+	t.Cleanup(func() {
+		dumpSockets, closeSocket, findProcess = origDump, origClose, origFind
+	})
+	dumpSockets = func(family, proto uint8) ([]*netlink.Socket, error) {
+		if family != syscall.AF_INET {
+			return nil, nil
+		}
+		return sys.socks[proto], nil
+	}
+	closeSocket = func(family, proto uint8, id netlink.SocketID) error {
+		for _, s := range sys.socks[proto] {
+			if s.ID.SourcePort == id.SourcePort {
+				sys.closed = append(sys.closed, s.INode)
+			}
+		}
+		return nil
+	}
+	findProcess = func(s *netlink.Socket) *procmon.Process {
+		return sys.procs[s.INode]
+	}
+	return sys
+}
+
+func (sys *fakeSystem) listen(proto uint8, state uint8, port uint16, inode uint32, path string) {
+	sys.socks[proto] = append(sys.socks[proto], &netlink.Socket{
+		ID:    netlink.SocketID{Source: net.IPv4zero, SourcePort: port, Destination: net.IPv4zero},
+		State: state,
+		INode: inode,
+	})
+	sys.procs[inode] = &procmon.Process{ID: int(inode), Path: path}
+}
+
+func TestCheck(t *testing.T) {
+	sys := newFakeSystem(t)
+	sys.listen(syscall.IPPROTO_TCP, netlink.TCP_LISTEN, 22, 1, "/usr/sbin/sshd")
+	// not listening: established, UDP client, and kernel sockets.
+	sys.listen(syscall.IPPROTO_TCP, netlink.TCP_ESTABLISHED, 40000, 2, "/usr/bin/curl")
+	sys.listen(syscall.IPPROTO_UDP, netlink.TCP_CLOSE, 45000, 3, "/usr/bin/dig")
+	sys.listen(syscall.IPPROTO_TCP, netlink.TCP_LISTEN, 2049, 0, "")
+
+	m := New()
+	var events []*Event
+	m.OnEvent(func(ev *Event) { events = append(events, ev) })
+
+	m.Check()
+	if len(events) != 0 {
+		t.Fatal("listeners found by the first check should not be reported:", events[0].Message)
+	}
+	if l := list(); len(l) != 1 || l[0].Port != 22 {
+		t.Error("invalid listeners:", l)
+	}
+
+	sys.listen(syscall.IPPROTO_TCP, netlink.TCP_LISTEN, 8080, 4, "/usr/bin/python3")
+	sys.listen(syscall.IPPROTO_UDP, netlink.TCP_CLOSE, 8081, 5, "/usr/bin/python3")
+	sys.listen(syscall.IPPROTO_UDP, netlink.TCP_CLOSE, 53, 6, "/usr/sbin/dnsmasq")
+	m.Check()
+	if len(events) != 3 {
+		t.Fatal("invalid number of events:", len(events))
+	}
+	if events[0].Kind != EventNewListener || events[0].Listener.Port != 8080 {
+		t.Error("first listener of a process not reported:", events[0].Message)
+	}
+	if events[1].Kind != EventNewListener || events[1].Listener.Process.Path != "/usr/sbin/dnsmasq" {
+		t.Error("second listener of a process reported:", events[1].Message)
+	}
+	if events[2].Kind != EventPrivilegedPort || events[2].Listener.Port != 53 {
+		t.Error("privileged port not reported:", events[2].Message)
+	}
+	if s := events[2].Message.String(); s != "/usr/sbin/dnsmasq (6) has bound the privileged port udp 0.0.0.0:53" {
+		t.Error("invalid message:", s)
+	}
+
+	events = nil
+	m.Check()
+	if len(events) != 0 {
+		t.Error("listeners reported twice:", events[0].Message)
+	}
+}
+
+func TestCheckDenied(t *testing.T) {
+	sys := newFakeSystem(t)
+	sys.listen(syscall.IPPROTO_TCP, netlink.TCP_LISTEN, 22, 1, "/usr/sbin/sshd")
+	sys.listen(syscall.IPPROTO_TCP, netlink.TCP_LISTEN, 4444, 2, "/usr/bin/nc")
+
+	rules, err := rule.NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rules.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	add := func(name string, action rule.Action, list []rule.Operator) {
+		for i := range list {
+			if err := list[i].Compile(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		op, _ := rule.NewOperator(rule.List, false, rule.OpList, "", list)
+		if err := rules.Add(rule.Create(name, "", true, false, false, action, rule.Always, op), false); err != nil {
+			t.Fatal("Error adding rule: ", err)
+		}
+	}
+	add("000-allow-sshd", rule.Allow, []rule.Operator{
+		{Type: rule.Simple, Operand: rule.OpListener, Data: "true"},
+		{Type: rule.Simple, Operand: rule.OpProcessPath, Data: "/usr/sbin/sshd"},
+	})
+	add("001-deny-listeners", rule.Deny, []rule.Operator{
+		{Type: rule.Simple, Operand: rule.OpListener, Data: "true"},
+		{Type: rule.Simple, Operand: rule.OpDstPort, Data: "4444"},
+	})
+
+	m := New()
+	m.SetRules(rules)
+	var events []*Event
+	m.OnEvent(func(ev *Event) { events = append(events, ev) })
+
+	// the listeners found by the first check are also evaluated.
+	m.Check()
+	if len(events) != 1 || events[0].Kind != EventDenied || events[0].Rule.Name != "001-deny-listeners" {
+		t.Fatal("listener not denied:", events)
+	}
+	if len(sys.closed) != 1 || sys.closed[0] != 2 {
+		t.Error("socket of the listener denied not closed:", sys.closed)
+	}
+
+	// the listeners are evaluated again when the rules change.
+	events = nil
+	sys.closed = nil
+	add("002-deny-sshd", rule.Deny, []rule.Operator{
+		{Type: rule.Simple, Operand: rule.OpListener, Data: "true"},
+		{Type: rule.Simple, Operand: rule.OpDstPort, Data: "22"},
+	})
+	m.Check()
+	if len(sys.closed) != 2 {
+		t.Error("listeners not evaluated again after changing the rules:", sys.closed)
+	}
+}
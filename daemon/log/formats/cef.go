@@ -0,0 +1,134 @@
+package formats
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	taskBase "github.com/evilsocket/opensnitch/daemon/tasks/base"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// CEF name of the output format, used in our json config
+const CEF = "cef"
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// Cef object
+type Cef struct {
+}
+
+// NewCEF returns a new Cef object, that transforms a message to the
+// ArcSight Common Event Format, prefixed by a RFC5424 header.
+func NewCEF() *Cef {
+	return &Cef{}
+}
+
+// Transform takes input arguments and formats them to CEF format.
+// CEF:Version|Device Vendor|Device Product|Device Version|Signature ID|Name|Severity|Extension
+func (c *Cef) Transform(args ...interface{}) (out string) {
+	hostname := ""
+	tag := ""
+	event := "GENERIC"
+	name := "generic event"
+	severity := "3"
+	ext := []string{}
+	msg := []string{}
+	isConnection := false
+	arg1 := args[0]
+	if len(args) > 1 {
+		hostname = args[1].(string)
+		tag = args[2].(string)
+	}
+	values := arg1.([]interface{})
+	for n, val := range values {
+		switch val.(type) {
+		case *protocol.Connection:
+			con := val.(*protocol.Connection)
+			isConnection = true
+			event = connEvent(con)
+			name = "outbound connection"
+			ext = append(ext, connToCEF(con)...)
+
+		case taskBase.TaskNotification:
+			event = "TASK_NOTIFICATION"
+			name = "task notification"
+			tsk := val.(taskBase.TaskNotification)
+			ext = append(ext,
+				cefField("cs1Label", "task"), cefField("cs1", tsk.Name),
+				cefField("msg", fmt.Sprint(tsk.Data)),
+			)
+
+		case string:
+			// action, rule name
+			if isConnection && n == 1 {
+				name = core.ConcatStrings("outbound connection ", val.(string))
+				if val.(string) == "deny" || val.(string) == "reject" {
+					severity = "7"
+				}
+				ext = append(ext, cefField("act", val.(string)))
+			} else if isConnection && n == 2 {
+				ext = append(ext, cefField("cs2Label", "rule"), cefField("cs2", val.(string)))
+			} else {
+				msg = append(msg, val.(string))
+			}
+
+		default:
+			msg = append(msg, fmt.Sprint(val))
+		}
+	}
+	if len(msg) > 0 {
+		ext = append(ext, cefField("msg", strings.Join(msg, " ")))
+	}
+
+	out = "<" + syslogLevel + ">1 " +
+		time.Now().Format(time.RFC3339) + " " +
+		hostname + " " +
+		tag + " " +
+		ourPid + " " +
+		event + " - " +
+		"CEF:0|OpenSnitch|opensnitchd|" + cefHeaderEscaper.Replace(core.Version) + "|" +
+		event + "|" + cefHeaderEscaper.Replace(name) + "|" + severity + "|" +
+		strings.Join(ext, " ") + "\n"
+
+	return
+}
+
+func cefField(key, value string) string {
+	return core.ConcatStrings(key, "=", cefExtensionEscaper.Replace(value))
+}
+
+// transform protocol.Connection to CEF extension fields.
+func connToCEF(con *protocol.Connection) []string {
+	ext := []string{
+		cefField("proto", con.Protocol),
+		cefField("src", con.SrcIp),
+		cefField("spt", strconv.FormatUint(uint64(con.SrcPort), 10)),
+		cefField("dst", con.DstIp),
+		cefField("dhost", con.DstHost),
+		cefField("dpt", strconv.FormatUint(uint64(con.DstPort), 10)),
+		cefField("spid", strconv.FormatUint(uint64(con.ProcessId), 10)),
+		cefField("suid", strconv.FormatUint(uint64(con.UserId), 10)),
+		cefField("sproc", con.ProcessPath),
+		cefField("cs3Label", "cmdline"),
+		cefField("cs3", strings.Join(con.ProcessArgs, " ")),
+		cefField("cs4Label", "tags"),
+		cefField("cs4", strings.Join(con.Tags, ",")),
+	}
+	if con.SandboxType != "" {
+		ext = append(ext,
+			cefField("cs5Label", "sandbox"),
+			cefField("cs5", core.ConcatStrings(con.SandboxType, ":", con.SandboxName)),
+			cefField("cs6Label", "sandbox owner"),
+			cefField("cs6", con.SandboxOwnerPath),
+			cefField("cn1Label", "sandbox owner pid"),
+			cefField("cn1", strconv.FormatUint(uint64(con.SandboxOwnerPid), 10)),
+		)
+	}
+	return ext
+}
@@ -0,0 +1,72 @@
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestCEF(t *testing.T) {
+	con := &protocol.Connection{
+		Protocol:    "tcp",
+		SrcIp:       "127.0.0.1",
+		SrcPort:     41234,
+		DstIp:       "185.53.178.14",
+		DstHost:     "opensnitch.io",
+		DstPort:     443,
+		UserId:      1000,
+		ProcessId:   1234,
+		ProcessPath: "/usr/bin/curl",
+		ProcessArgs: []string{"curl", "https://opensnitch.io/?a=b"},
+	}
+	out := NewCEF().Transform([]interface{}{con, "deny", "deny-curl"}, "localhost", "opensnitch")
+
+	if !strings.HasSuffix(out, "\n") {
+		t.Error("the line must end with a new line")
+	}
+	idx := strings.Index(out, "CEF:0|OpenSnitch|opensnitchd|")
+	if idx == -1 {
+		t.Fatal("invalid CEF header:", out)
+	}
+	if !strings.HasPrefix(out, "<"+syslogLevel+">1 ") || !strings.Contains(out[:idx], " localhost opensnitch ") {
+		t.Error("invalid syslog header:", out)
+	}
+	header := strings.SplitN(out[idx:], "|", 8)
+	if len(header) != 8 || header[4] != "CONNECTION" || header[5] != "outbound connection deny" || header[6] != "7" {
+		t.Fatal("invalid CEF fields:", header)
+	}
+	for _, field := range []string{
+		"proto=tcp", "src=127.0.0.1", "spt=41234", "dst=185.53.178.14", "dhost=opensnitch.io", "dpt=443",
+		"spid=1234", "suid=1000", "sproc=/usr/bin/curl", `cs3=curl https://opensnitch.io/?a\=b`,
+		"act=deny", "cs2Label=rule", "cs2=deny-curl",
+	} {
+		if !strings.Contains(header[7], field) {
+			t.Errorf("field %s not found: %s", field, header[7])
+		}
+	}
+}
+
+func TestCEFSandbox(t *testing.T) {
+	con := &protocol.Connection{
+		Protocol:         "udp",
+		DstIp:            "9.9.9.9",
+		DstPort:          53,
+		ProcessId:        4321,
+		ProcessPath:      "/usr/bin/slirp4netns",
+		SandboxType:      "slirp4netns",
+		SandboxName:      "netns-1",
+		SandboxOwnerPath: "/usr/bin/podman",
+		SandboxOwnerPid:  1000,
+	}
+	out := NewCEF().Transform([]interface{}{con, "allow", "allow-podman"}, "localhost", "opensnitch")
+	header := strings.SplitN(out[strings.Index(out, "CEF:0|"):], "|", 8)
+	if len(header) != 8 || header[4] != "SANDBOX_CONNECTION" || header[5] != "outbound connection allow" {
+		t.Fatal("invalid CEF fields:", header)
+	}
+	for _, field := range []string{"cs5=slirp4netns:netns-1", "cs6=/usr/bin/podman", "cn1=1000", "act=allow", "cs2=allow-podman"} {
+		if !strings.Contains(header[7], field) {
+			t.Errorf("field %s not found: %s", field, header[7])
+		}
+	}
+}
@@ -42,7 +42,7 @@ func connToSD(out string, val interface{}) string {
 	}
 
 	// TODO: allow to configure this via configuration file.
-	return core.ConcatStrings(out,
+	out = core.ConcatStrings(out,
 		" SRC=\"", con.SrcIp, "\"",
 		" SPT=\"", strconv.FormatUint(uint64(con.SrcPort), 10), "\"",
 		" DST=\"", con.DstIp, "\"",
@@ -57,6 +57,25 @@ func connToSD(out string, val interface{}) string {
 		" CWD=\"", con.ProcessCwd, "\"",
 		" CHECKSUMS=\"", checksums, "\"",
 		" PROCTREE=\"", tree, "\"",
+		" TAGS=\"", strings.Join(con.Tags, ","), "\"",
 		// TODO: envs
 	)
+	if con.SandboxType != "" {
+		out = core.ConcatStrings(out,
+			" SANDBOX=\"", con.SandboxType, "\"",
+			" SANDBOXNAME=\"", con.SandboxName, "\"",
+			" SANDBOXOWNER=\"", con.SandboxOwnerPath, "\"",
+			" SANDBOXOWNERPID=\"", strconv.FormatUint(uint64(con.SandboxOwnerPid), 10), "\"",
+		)
+	}
+	return out
+}
+
+// connEvent returns the type of event of a connection: CONNECTION, or
+// SANDBOX_CONNECTION if it's been opened on behalf of a VM or sandbox.
+func connEvent(con *protocol.Connection) string {
+	if con.SandboxType != "" {
+		return "SANDBOX_CONNECTION"
+	}
+	return "CONNECTION"
 }
@@ -16,6 +16,8 @@ const (
 	EvConnection = iota
 	EvExec
 	EvTaskNotification
+	// connection opened on behalf of a VM or sandbox
+	EvSandboxConnection
 )
 
 // JSONEventFormat object to be sent to the remote service.
@@ -48,8 +50,12 @@ func (j *JSONEventFormat) Transform(args ...interface{}) (out string) {
 		case *protocol.Connection:
 			// XXX: All fields of the Connection object are sent, is this what we want?
 			// or should we send an anonymous json?
-			jObj.Event = val.(*protocol.Connection)
+			con := val.(*protocol.Connection)
+			jObj.Event = con
 			jObj.Type = EvConnection
+			if con.SandboxType != "" {
+				jObj.Type = EvSandboxConnection
+			}
 
 		case taskBase.TaskNotification:
 			jObj.Event = val.(taskBase.TaskNotification)
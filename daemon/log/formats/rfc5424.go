@@ -40,7 +40,7 @@ func (r *Rfc5424) Transform(args ...interface{}) (out string) {
 	for n, val := range values {
 		switch val.(type) {
 		case *protocol.Connection:
-			event = "CONNECTION"
+			event = connEvent(val.(*protocol.Connection))
 			out = connToSD(out, val)
 
 		case taskBase.TaskNotification:
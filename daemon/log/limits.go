@@ -0,0 +1,188 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits configures the suppression of the repeated and excessive messages,
+// so a subsystem failing in a loop doesn't fill the disk with the same lines.
+// The errors and the important messages are never suppressed.
+type Limits struct {
+	// Print the consecutive identical messages only once, followed by
+	// "last message repeated N times".
+	SuppressRepeated bool `json:"SuppressRepeated"`
+	// Maximum number of messages per second of every module (the "[tag]" or
+	// "tag:" prefix of the messages). 0 to disable it.
+	RateLimit int `json:"RateLimit"`
+}
+
+type repeatedMsg struct {
+	since time.Time
+	msg   string
+	level int
+	count int
+}
+
+// notice is a message printed by the logger about the messages suppressed.
+type notice struct {
+	msg   string
+	level int
+}
+
+type moduleRate struct {
+	window  time.Time
+	count   int
+	dropped int
+}
+
+var (
+	limits     Limits
+	limitsLock sync.Mutex
+	lastMsg    repeatedMsg
+	rates      = make(map[string]*moduleRate)
+
+	// interval to print the count of a message that keeps being repeated.
+	repeatFlushInterval = 30 * time.Second
+	timeNow             = time.Now
+
+	// max number of modules rate limited separately. The prefixes of the
+	// messages are not always modules (paths, addresses), the rest of them
+	// share the limit of the messages without module.
+	maxRateModules = 128
+)
+
+// SetLimits configures the suppression of the repeated and excessive messages.
+func SetLimits(l Limits) {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+	limits = l
+	lastMsg = repeatedMsg{}
+	rates = make(map[string]*moduleRate)
+}
+
+// GetLimits returns the current config.
+func GetLimits() Limits {
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+	return limits
+}
+
+// limit decides if a message must be printed. It also returns the notices
+// of the messages suppressed before it, to be printed before the message.
+func limit(level int, what string) (notices []notice, print bool) {
+	if level >= FATAL {
+		return nil, true
+	}
+	limitsLock.Lock()
+	defer limitsLock.Unlock()
+	now := timeNow()
+
+	if level == IMPORTANT || level == ERROR {
+		if lastMsg.count > 0 {
+			notices = append(notices, repeatedNotice(lastMsg))
+		}
+		lastMsg = repeatedMsg{}
+		return notices, true
+	}
+
+	if limits.SuppressRepeated {
+		if lastMsg.level == level && lastMsg.msg == what {
+			lastMsg.count++
+			if now.Sub(lastMsg.since) >= repeatFlushInterval {
+				notices = append(notices, repeatedNotice(lastMsg))
+				lastMsg.count = 0
+				lastMsg.since = now
+			}
+			return notices, false
+		}
+		if lastMsg.count > 0 {
+			notices = append(notices, repeatedNotice(lastMsg))
+		}
+		lastMsg = repeatedMsg{level: level, msg: what, since: now}
+	}
+
+	if limits.RateLimit > 0 {
+		module := moduleOf(what)
+		r, found := rates[module]
+		if !found && len(rates) >= maxRateModules {
+			notices = append(notices, purgeRates(now)...)
+			if len(rates) >= maxRateModules {
+				module = ""
+				r, found = rates[module]
+			}
+		}
+		if !found {
+			r = &moduleRate{window: now}
+			rates[module] = r
+		}
+		if now.Sub(r.window) >= time.Second {
+			if r.dropped > 0 {
+				notices = append(notices, droppedNotice(module, r))
+			}
+			r.window = now
+			r.count = 0
+			r.dropped = 0
+		}
+		r.count++
+		if r.count > limits.RateLimit {
+			r.dropped++
+			return notices, false
+		}
+	}
+
+	return notices, true
+}
+
+// purgeRates deletes the modules without messages in the current second.
+func purgeRates(now time.Time) (notices []notice) {
+	for module, r := range rates {
+		if now.Sub(r.window) < time.Second {
+			continue
+		}
+		if r.dropped > 0 {
+			notices = append(notices, droppedNotice(module, r))
+		}
+		delete(rates, module)
+	}
+	return notices
+}
+
+func droppedNotice(module string, r *moduleRate) notice {
+	return notice{
+		level: WARNING,
+		msg:   fmt.Sprintf("%d messages of %s suppressed, more than %d per second\n", r.dropped, moduleName(module), limits.RateLimit),
+	}
+}
+
+func repeatedNotice(m repeatedMsg) notice {
+	return notice{level: m.level, msg: fmt.Sprintf("last message repeated %d times\n", m.count)}
+}
+
+// moduleOf returns the module of a message: "[eBPF] ..." -> "[eBPF]",
+// "nftables: ..." -> "nftables:"
+func moduleOf(what string) string {
+	if strings.HasPrefix(what, "[") {
+		if end := strings.IndexByte(what, ']'); end != -1 {
+			return what[:end+1]
+		}
+		return ""
+	}
+	word := what
+	if end := strings.IndexAny(what, " \t\n"); end != -1 {
+		word = what[:end]
+	}
+	if len(word) > 1 && strings.HasSuffix(word, ":") {
+		return word
+	}
+	return ""
+}
+
+func moduleName(module string) string {
+	if module == "" {
+		return "unknown module"
+	}
+	return module
+}
@@ -0,0 +1,163 @@
+package log
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logTo redirects the output to a temp file, and returns a function to read
+// the lines written.
+func logTo(t *testing.T, l Limits) func() []string {
+	f, err := ioutil.TempFile(t.TempDir(), "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldOutput, oldColors, oldNow := Output, WithColors, timeNow
+	Output = f
+	WithColors = false
+	SetLimits(l)
+	t.Cleanup(func() {
+		Output, WithColors, timeNow = oldOutput, oldColors, oldNow
+		SetLimits(Limits{})
+		f.Close()
+	})
+
+	return func() []string {
+		raw, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+			// strip the date
+			if i := strings.Index(line, "] "); i != -1 {
+				line = line[i+2:]
+			}
+			lines = append(lines, strings.TrimSpace(line))
+		}
+		return lines
+	}
+}
+
+func checkLines(t *testing.T, got, expected []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestSuppressRepeated(t *testing.T) {
+	lines := logTo(t, Limits{SuppressRepeated: true})
+
+	for i := 0; i < 5; i++ {
+		Warning("nftables: error adding rule")
+	}
+	Info("nftables: rule added")
+	Info("nftables: rule added")
+
+	checkLines(t, lines(), []string{
+		"WAR  nftables: error adding rule",
+		"WAR  last message repeated 4 times",
+		"INF  nftables: rule added",
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		now := time.Now()
+		timeNow = func() time.Time { return now }
+		Warning("[eBPF] event lost")
+		Warning("[eBPF] event lost")
+		now = now.Add(repeatFlushInterval)
+		Warning("[eBPF] event lost")
+		Warning("[eBPF] event lost")
+
+		checkLines(t, lines()[3:], []string{
+			"INF  last message repeated 1 times",
+			"WAR  [eBPF] event lost",
+			"WAR  last message repeated 2 times",
+		})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		Error("[fw] error reloading")
+		Error("[fw] error reloading")
+		Important("[fw] reloaded")
+		Important("[fw] reloaded")
+
+		// the messages repeated before are reported first.
+		checkLines(t, lines()[6:], []string{
+			"WAR  last message repeated 1 times",
+			"ERR  [fw] error reloading",
+			"ERR  [fw] error reloading",
+			"IMP  [fw] reloaded",
+			"IMP  [fw] reloaded",
+		})
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	lines := logTo(t, Limits{RateLimit: 2})
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		Info("[fw] rule %d", i)
+		Info("dns: response %d", i)
+	}
+	now = now.Add(time.Second)
+	Info("[fw] rule 5")
+
+	checkLines(t, lines(), []string{
+		"INF  [fw] rule 0",
+		"INF  dns: response 0",
+		"INF  [fw] rule 1",
+		"INF  dns: response 1",
+		"WAR  3 messages of [fw] suppressed, more than 2 per second",
+		"INF  [fw] rule 5",
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			Error("[fw] error %d", i)
+		}
+		if n := len(lines()); n != 11 {
+			t.Error("errors rate limited:", lines()[6:])
+		}
+	})
+
+	t.Run("max modules", func(t *testing.T) {
+		origMax := maxRateModules
+		maxRateModules = 2
+		defer func() { maxRateModules = origMax }()
+		SetLimits(Limits{RateLimit: 1})
+
+		Info("[a] message")
+		Info("[b] message")
+		Info("[c] message")
+		if rates["[c]"] != nil || rates[""] == nil {
+			t.Error("rate limited modules not capped:", len(rates))
+		}
+		// the modules without messages in the last second are expired.
+		now = now.Add(time.Second)
+		Info("[c] message")
+		if len(rates) != 1 || rates["[c]"] == nil {
+			t.Error("idle modules not expired:", len(rates))
+		}
+	})
+}
+
+func TestModuleOf(t *testing.T) {
+	tests := map[string]string{
+		"[eBPF] event lost\n":       "[eBPF]",
+		"nftables: rule added\n":    "nftables:",
+		"connection from 1.1.1.1\n": "",
+		"[unterminated tag":         "",
+		": empty\n":                 "",
+	}
+	for msg, module := range tests {
+		if got := moduleOf(msg); got != module {
+			t.Errorf("moduleOf(%q) = %q, expected %q", msg, got, module)
+		}
+	}
+}
@@ -171,8 +171,6 @@ func Log(level int, format string, args ...interface{}) {
 	mutex.RLock()
 	defer mutex.RUnlock()
 	if level >= MinLevel {
-		label := labels[level]
-		color := colors[level]
 		datefmt := DateFormat
 
 		if LogMicro {
@@ -188,13 +186,23 @@ func Log(level int, format string, args ...interface{}) {
 			what += "\n"
 		}
 
-		l := Dim("[%s]")
-		r := Wrap(" %s ", color) + " %s"
-
-		fmt.Fprintf(Output, l+" "+r, when, label, what)
+		notices, print := limit(level, what)
+		for _, n := range notices {
+			write(when, n.level, n.msg)
+		}
+		if print {
+			write(when, level, what)
+		}
 	}
 }
 
+func write(when string, level int, what string) {
+	l := Dim("[%s]")
+	r := Wrap(" %s ", colors[level]) + " %s"
+
+	fmt.Fprintf(Output, l+" "+r, when, labels[level], what)
+}
+
 func setDefaultLogOutput() {
 	mutex.Lock()
 	Output = os.Stdout
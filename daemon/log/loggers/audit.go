@@ -0,0 +1,171 @@
+package loggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+const (
+	LOGGER_AUDIT = "audit"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"syslog":   syslog.LOG_SYSLOG,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// AuditConfig holds the configuration of the audit log, where the connections
+// matched by rules with the action "audit" are recorded.
+type AuditConfig struct {
+	// File where the audit records are written, i.e.: /var/log/opensnitchd-audit.log
+	File string
+
+	// SyslogFacility where the audit records are sent: authpriv, local0, ...
+	// Empty to disable it.
+	SyslogFacility string
+
+	// Tag: opensnitchd, mytag, ...
+	Tag string
+}
+
+// AuditRecord is the structured record written for every audited connection.
+type AuditRecord struct {
+	Time      string            `json:"time"`
+	Rule      string            `json:"rule"`
+	Action    string            `json:"action"`
+	Protocol  string            `json:"protocol"`
+	SrcIP     string            `json:"src_ip"`
+	SrcPort   uint32            `json:"src_port"`
+	DstIP     string            `json:"dst_ip"`
+	DstHost   string            `json:"dst_host"`
+	DstPort   uint32            `json:"dst_port"`
+	UserID    uint32            `json:"user_id"`
+	PID       uint32            `json:"process_id"`
+	Path      string            `json:"process_path"`
+	Args      []string          `json:"process_args"`
+	Checksums map[string]string `json:"process_checksums"`
+	// packaged, modified or unpackaged, if the binaries are verified.
+	Origin string `json:"process_origin,omitempty"`
+}
+
+// Audit writes audit records to a dedicated file and/or to syslog.
+type Audit struct {
+	mu     sync.Mutex
+	file   *os.File
+	syslog *syslog.Writer
+	cfg    AuditConfig
+}
+
+// NewAudit opens the destinations of the audit records.
+func NewAudit(cfg AuditConfig) (*Audit, error) {
+	log.Info("NewAudit logger: %v", cfg)
+	a := &Audit{cfg: cfg}
+
+	if cfg.File != "" {
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] error opening %s: %s", LOGGER_AUDIT, cfg.File, err)
+		}
+		a.file = f
+	}
+
+	if cfg.SyslogFacility != "" {
+		facility, found := syslogFacilities[strings.ToLower(cfg.SyslogFacility)]
+		if !found {
+			a.Close()
+			return nil, fmt.Errorf("[%s] invalid syslog facility: %s", LOGGER_AUDIT, cfg.SyslogFacility)
+		}
+		tag := logTag
+		if cfg.Tag != "" {
+			tag = cfg.Tag
+		}
+		w, err := syslog.New(syslog.LOG_NOTICE|facility, tag)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("[%s] error opening syslog: %s", LOGGER_AUDIT, err)
+		}
+		a.syslog = w
+	}
+
+	return a, nil
+}
+
+// NewAuditRecord builds the audit record of a connection.
+func NewAuditRecord(con *protocol.Connection, action, ruleName string) *AuditRecord {
+	return &AuditRecord{
+		Time:      time.Now().Format(time.RFC3339),
+		Rule:      ruleName,
+		Action:    action,
+		Protocol:  con.Protocol,
+		SrcIP:     con.SrcIp,
+		SrcPort:   con.SrcPort,
+		DstIP:     con.DstIp,
+		DstHost:   con.DstHost,
+		DstPort:   con.DstPort,
+		UserID:    con.UserId,
+		PID:       con.ProcessId,
+		Path:      con.ProcessPath,
+		Args:      con.ProcessArgs,
+		Checksums: con.ProcessChecksums,
+		Origin:    con.ProcessPackageStatus,
+	}
+}
+
+// Write writes the audit record of a connection to the configured destinations.
+func (a *Audit) Write(con *protocol.Connection, action, ruleName string) {
+	raw, err := json.Marshal(NewAuditRecord(con, action, ruleName))
+	if err != nil {
+		log.Error("[%s] error serializing record: %s", LOGGER_AUDIT, err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil {
+		if _, err := a.file.Write(append(raw, '\n')); err != nil {
+			log.Error("[%s] write error: %s", LOGGER_AUDIT, err)
+		}
+	}
+	if a.syslog != nil {
+		if err := a.syslog.Notice(string(raw)); err != nil {
+			log.Error("[%s] syslog write error: %s", LOGGER_AUDIT, err)
+		}
+	}
+}
+
+// Close closes the destinations of the audit records.
+func (a *Audit) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+	if a.syslog != nil {
+		a.syslog.Close()
+		a.syslog = nil
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package loggers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestAudit(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	lm := NewLoggerManager()
+	if err := lm.LoadAudit(AuditConfig{File: auditFile}); err != nil {
+		t.Fatal("LoadAudit() error:", err)
+	}
+
+	con := &protocol.Connection{
+		Protocol:         "tcp",
+		DstIp:            "1.1.1.1",
+		DstHost:          "one.one.one.one",
+		DstPort:          443,
+		ProcessPath:      "/usr/bin/curl",
+		ProcessChecksums: map[string]string{"md5": "7a2f3b1d"},
+	}
+	lm.Audit(con, "audit", "audit-curl")
+	lm.LoadAudit(AuditConfig{})
+
+	raw, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal("error reading audit file:", err)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		t.Fatal("invalid audit record:", err, string(raw))
+	}
+	if rec.Rule != "audit-curl" || rec.Path != con.ProcessPath || rec.DstHost != con.DstHost || rec.Checksums["md5"] != "7a2f3b1d" {
+		t.Error("invalid audit record:", rec)
+	}
+
+	t.Run("invalid facility", func(t *testing.T) {
+		if err := lm.LoadAudit(AuditConfig{SyslogFacility: "xxx"}); err == nil {
+			t.Error("LoadAudit() should fail with an invalid syslog facility")
+		}
+	})
+}
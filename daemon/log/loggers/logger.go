@@ -3,13 +3,19 @@ package loggers
 import (
 	"context"
 	"sync"
-	"time"
 
+	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 )
 
 const logTag = "opensnitch"
 
+var (
+	defaultWorkers   = 4
+	defaultQueueSize = 1024
+)
+
 // Logger is the common interface that every logger must met.
 // Serves as a generic holder of different types of loggers.
 type Logger interface {
@@ -26,9 +32,12 @@ type LoggerConfig struct {
 	// Format: rfc5424, csv, json, ...
 	Format string
 
-	// Protocol: udp, tcp
+	// Protocol: udp, tcp, tls
 	Protocol string
 
+	// TLSOptions used when Protocol is tls
+	TLSOptions LoggerTLSOptions
+
 	// Server: 127.0.0.1:514
 	Server string
 
@@ -44,22 +53,52 @@ type LoggerConfig struct {
 	// Workers: number of workers
 	Workers int
 
+	// QueueSize: max number of events waiting to be written (1024 by default).
+	// QueuePolicy: what to do when the queue is full, drop-oldest (default)
+	// or drop-newest.
+	QueueSize   int
+	QueuePolicy string
+
 	// MaxConnectAttempts holds the max attemps to connect to the remote server.
 	// A value of 0 will try to connect indefinitely.
 	MaxConnectAttempts uint16
+
+	// Tags: only log the connections tagged by the rules with any of these
+	// tags. All the connections are logged if it's empty.
+	Tags []string
+}
+
+// LoggerTLSOptions holds the TLS configuration to connect with a remote server.
+type LoggerTLSOptions struct {
+	// CACert to verify the server certificate. Empty to use the system CAs.
+	CACert string
+
+	// ClientCert and ClientKey to authenticate against the server (optional).
+	ClientCert string
+	ClientKey  string
+
+	// ServerName to verify the certificate against, if it differs from the
+	// host of the Server.
+	ServerName string
+
+	// SkipVerify disables the verification of the server certificate.
+	SkipVerify bool
 }
 
 // LoggerManager represents the LoggerManager.
 type LoggerManager struct {
-	ctx           context.Context
-	cancel        context.CancelFunc
-	configs       []LoggerConfig
-	loggers       map[string]Logger
-	msgs          chan []interface{}
-	count         int
-	workers       int
-	queueFullHits int
-	mu            *sync.RWMutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	configs []LoggerConfig
+	loggers map[string]Logger
+	// every logger has its own queue, so a slow logger doesn't affect the rest.
+	queues  map[string]*core.Queue[[]interface{}]
+	audit   *Audit
+	rules   *ruleLog
+	tags    map[string]map[string]bool // tags of the connections to log, by logger
+	count   int
+	workers int
+	mu      *sync.RWMutex
 }
 
 // NewLoggerManager instantiates all the configured loggers.
@@ -70,6 +109,9 @@ func NewLoggerManager() *LoggerManager {
 		ctx:     ctx,
 		cancel:  cancel,
 		loggers: make(map[string]Logger),
+		queues:  make(map[string]*core.Queue[[]interface{}]),
+		tags:    make(map[string]map[string]bool),
+		rules:   newRuleLog(),
 	}
 
 	return lm
@@ -84,39 +126,87 @@ func (l *LoggerManager) Load(configs []LoggerConfig) {
 	l.configs = configs
 
 	for _, cfg := range configs {
+		var lgr Logger
+		var key string
 		switch cfg.Name {
-		case LOGGER_REMOTE, LOGGER_REMOTE_SYSLOG, LOGGER_SYSLOG:
-			l.workers += cfg.Workers
-			l.count++
+		case LOGGER_REMOTE:
+			r, _ := NewRemote(cfg)
+			lgr, key = r, r.Name+r.cfg.Server+r.cfg.Protocol
+		case LOGGER_REMOTE_SYSLOG:
+			r, _ := NewRemoteSyslog(cfg)
+			lgr, key = r, r.Name+r.cfg.Server+r.cfg.Protocol
+		case LOGGER_SYSLOG:
+			r, _ := NewSyslog(cfg)
+			lgr, key = r, r.Name
+		default:
+			continue
+		}
+
+		workers := cfg.Workers
+		if workers == 0 {
+			workers = defaultWorkers
+		}
+		size := cfg.QueueSize
+		if size <= 0 {
+			size = defaultQueueSize
+		}
+		queue, err := core.NewQueue[[]interface{}](size, cfg.QueuePolicy)
+		if err != nil {
+			log.Warning("[%s logger] %s, using the default queue", cfg.Name, err)
+			queue, _ = core.NewQueue[[]interface{}](size, core.DropOldest)
+		}
+
+		l.loggers[key] = lgr
+		l.queues[key] = queue
+		if len(cfg.Tags) > 0 {
+			l.tags[key] = make(map[string]bool, len(cfg.Tags))
+			for _, tag := range cfg.Tags {
+				l.tags[key][tag] = true
+			}
+		}
+		l.count++
+		for i := 0; i < workers; i++ {
+			go newWorker(l.workers, l.ctx.Done(), queue.C(), lgr)
+			l.workers++
 		}
 	}
-	if l.count == 0 {
-		return
+}
+
+// LoadAudit configures the audit log. An empty configuration disables it.
+func (l *LoggerManager) LoadAudit(cfg AuditConfig) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.audit != nil {
+		l.audit.Close()
+		l.audit = nil
 	}
-	if l.workers == 0 {
-		l.workers = 4
+	if cfg.File == "" && cfg.SyslogFacility == "" {
+		return nil
 	}
-
-	// TODO: allow to configure messages queue size
-	l.msgs = make(chan []interface{}, l.workers)
-	for i := 0; i < l.workers; i++ {
-		go newWorker(i, l.ctx.Done(), l.msgs, l.write)
+	audit, err := NewAudit(cfg)
+	if err != nil {
+		return err
 	}
+	l.audit = audit
+	return nil
+}
 
-	for _, cfg := range configs {
-		switch cfg.Name {
-		case LOGGER_REMOTE:
-			lgr, _ := NewRemote(cfg)
-			l.loggers[lgr.Name+lgr.cfg.Server+lgr.cfg.Protocol] = lgr
-		case LOGGER_REMOTE_SYSLOG:
-			lgr, _ := NewRemoteSyslog(cfg)
-			l.loggers[lgr.Name+lgr.cfg.Server+lgr.cfg.Protocol] = lgr
-		case LOGGER_SYSLOG:
-			lgr, _ := NewSyslog(cfg)
-			l.loggers[lgr.Name] = lgr
-		}
+// Audit writes the audit record of a connection, if the audit log is enabled.
+func (l *LoggerManager) Audit(con *protocol.Connection, action, ruleName string) {
+	l.mu.RLock()
+	audit := l.audit
+	l.mu.RUnlock()
+	if audit == nil {
+		return
 	}
+	audit.Write(con, action, ruleName)
+}
 
+// LogRule writes the record of a connection matched by a rule with a log
+// directive to the target of the directive.
+func (l *LoggerManager) LogRule(cfg *RuleLogConfig, con *protocol.Connection, action, ruleName string) {
+	l.rules.write(cfg, con, action, ruleName)
 }
 
 // Reload stops and loads the configured loggers again
@@ -131,31 +221,25 @@ func (l *LoggerManager) Stop() {
 	defer l.mu.Unlock()
 	l.count = 0
 	l.workers = 0
-	l.queueFullHits = 0
 
 	l.cancel()
 	for _, lg := range l.loggers {
 		lg.Close()
 	}
+	// the files of the rules are opened again when needed.
+	l.rules.close()
 	l.loggers = make(map[string]Logger)
+	l.queues = make(map[string]*core.Queue[[]interface{}])
+	l.tags = make(map[string]map[string]bool)
 }
 
-func (l *LoggerManager) write(args ...interface{}) {
-	//l.mu.RLock()
-	//defer l.mu.RUnlock()
-	// FIXME: leak when configuring the loggers.
-	for _, logger := range l.loggers {
-		logger.Write(logger.Transform(args...))
-	}
-}
-
-func newWorker(id int, done <-chan struct{}, msgs chan []interface{}, write func(args ...interface{})) {
+func newWorker(id int, done <-chan struct{}, msgs <-chan []interface{}, logger Logger) {
 	for {
 		select {
 		case <-done:
 			goto Exit
 		case msg := <-msgs:
-			write(msg)
+			logger.Write(logger.Transform(msg...))
 		}
 	}
 Exit:
@@ -163,32 +247,51 @@ Exit:
 }
 
 // Log sends data to the loggers.
+// It never blocks: if a logger can't keep up with the events (high load,
+// remote server not reachable, ...), events are discarded according to the
+// policy of its queue, and counted as dropped.
 func (l *LoggerManager) Log(args ...interface{}) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	if l.count == 0 {
 		return
 	}
 
-	// Sending messages to the queue (channel) should be instantaneous, but there're
-	// several scenarios where we can end up filling up the queue (channel):
-	// - If we're not connected to the server (GUI), and we need to allow some
-	//   connections.
-	// - If there's a high load, all workers busy, and writing the logs to the
-	//   logger take too much time.
-	// In these and other scenarios, if we try to send more than <queueFullHits> times
-	// while the queue (channel) is full, we'll reload the loggers.
-	select {
-	case <-time.After(time.Millisecond * 1):
-		l.mu.Lock()
-		log.Debug("loggerMgr.Log() TIMEOUT dispatching log, queued: %d, queue full hits: %d", len(l.msgs), l.queueFullHits)
-		l.queueFullHits++
-		// TODO: make queueFullHits configurable
-		needsReload := len(l.msgs) == l.workers && l.queueFullHits > 30
-		l.mu.Unlock()
-
-		if needsReload {
-			// FIXME: races occurs on l.write() and l.Load()
-			l.Reload()
+	for name, queue := range l.queues {
+		if !wantsEvent(l.tags[name], args) {
+			continue
+		}
+		if !queue.Push(args) {
+			log.Trace("loggerMgr.Log() %s queue full (%d), event dropped", name, queue.Len())
+		}
+	}
+}
+
+// wantsEvent returns true if the event must be logged by a logger that only
+// logs the connections with some tags. Other events are always logged.
+func wantsEvent(tags map[string]bool, args []interface{}) bool {
+	if len(tags) == 0 || len(args) == 0 {
+		return true
+	}
+	con, isConn := args[0].(*protocol.Connection)
+	if !isConn {
+		return true
+	}
+	for _, tag := range con.Tags {
+		if tags[tag] {
+			return true
 		}
-	case l.msgs <- args:
 	}
+	return false
+}
+
+// Dropped returns the number of events discarded by every logger.
+func (l *LoggerManager) Dropped() map[string]uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	dropped := make(map[string]uint64, len(l.queues))
+	for name, queue := range l.queues {
+		dropped[name] = queue.Dropped()
+	}
+	return dropped
 }
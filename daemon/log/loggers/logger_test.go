@@ -0,0 +1,26 @@
+package loggers
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestLoggerTags(t *testing.T) {
+	tags := map[string]bool{"telemetry": true}
+	tagged := &protocol.Connection{Tags: []string{"updates", "telemetry"}}
+	untagged := &protocol.Connection{}
+
+	if !wantsEvent(nil, []interface{}{untagged, "allow", "rule"}) {
+		t.Error("loggers without tags should log every connection")
+	}
+	if !wantsEvent(tags, []interface{}{tagged, "allow", "rule"}) {
+		t.Error("tagged connection not logged")
+	}
+	if wantsEvent(tags, []interface{}{untagged, "allow", "rule"}) {
+		t.Error("connection without the tags logged")
+	}
+	if !wantsEvent(tags, []interface{}{"task notification"}) {
+		t.Error("events other than connections should be logged")
+	}
+}
@@ -2,7 +2,10 @@ package loggers
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"log/syslog"
 	"net"
 	"os"
@@ -38,8 +41,8 @@ const (
 )
 
 // Remote defines a logger that writes events to a generic remote server.
-// It can write to a local or a remote daemon, UDP or TCP.
-// It supports writing events in RFC5424, RFC3164, CSV and JSON formats.
+// It can write to a local or a remote daemon, UDP, TCP or TLS.
+// It supports writing events in RFC5424, RFC3164, CEF, CSV and JSON formats.
 type Remote struct {
 	mu        *sync.RWMutex
 	Writer    *syslog.Writer
@@ -89,6 +92,8 @@ func NewRemote(cfg LoggerConfig) (*Remote, error) {
 		sys.logFormat = formats.NewJSON()
 	} else if cfg.Format == formats.CSV {
 		sys.logFormat = formats.NewCSV()
+	} else if cfg.Format == formats.CEF {
+		sys.logFormat = formats.NewCEF()
 	}
 
 	sys.Tag = logTag
@@ -168,14 +173,58 @@ func (s *Remote) Dial(proto, addr string, connTimeout time.Duration) (netConn ne
 			log.Debug("remote.Dial() %s error: %s", s.cfg.Server, err)
 			return nil, err
 		}
+	case "tls":
+		tlsCfg, err := s.tlsConfig()
+		if err != nil {
+			log.Warning("[%s] %s TLS configuration error: %s", s.Name, s.cfg.Server, err)
+			return nil, err
+		}
+		netConn, err = tls.DialWithDialer(&net.Dialer{Timeout: connTimeout}, "tcp", addr, tlsCfg)
+		if err != nil {
+			log.Debug("remote.Dial() %s error: %s", s.cfg.Server, err)
+			return nil, err
+		}
 	default:
-		return nil, fmt.Errorf("[%s] Network protocol %s not supported (use 'tcp' or 'udp')", s.Name, proto)
+		return nil, fmt.Errorf("[%s] Network protocol %s not supported (use 'tcp', 'udp' or 'tls')", s.Name, proto)
 	}
 
 	atomic.StoreUint32(&s.status, CONNECTED)
 	return netConn, nil
 }
 
+// tlsConfig builds the TLS configuration to connect with the remote server.
+// The certificates are read on every connection attempt, so they can be
+// renewed without reloading the loggers.
+func (s *Remote) tlsConfig() (*tls.Config, error) {
+	opts := s.cfg.TLSOptions
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: opts.SkipVerify,
+		ServerName:         opts.ServerName,
+	}
+
+	if opts.CACert != "" {
+		caPem, err := ioutil.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %s", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("invalid CA certificate: %s", opts.CACert)
+		}
+		tlsCfg.RootCAs = certPool
+	}
+
+	if opts.ClientCert != "" {
+		clientCert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %s", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsCfg, nil
+}
+
 // Close closes the writer object
 func (s *Remote) Close() (err error) {
 	s.cancel()
@@ -0,0 +1,153 @@
+package loggers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// Levels of the records of the connections matched by a rule.
+const (
+	// RuleLogNone doesn't log the connections, not even to the loggers.
+	RuleLogNone = "none"
+	// RuleLogSummary writes one line per connection.
+	RuleLogSummary = "summary"
+	// RuleLogFull writes a structured record (json) with all the metadata
+	// of the connection and its process.
+	RuleLogFull = "full"
+)
+
+// Targets of the records of the connections matched by a rule.
+const (
+	// RuleLogMain writes the records to the log of the daemon.
+	RuleLogMain   = "main"
+	RuleLogFile   = "file"
+	RuleLogSyslog = "syslog"
+)
+
+// RuleLogConfig is the log directive of a rule: how the connections it
+// matches are logged, and where. The records are written in addition to the
+// configured loggers, except with the level none.
+type RuleLogConfig struct {
+	// Level: none, summary (default) or full.
+	Level string `json:"level,omitempty"`
+
+	// Target: main (default), file or syslog.
+	Target string `json:"target,omitempty"`
+
+	// File where the records are written, when the target is file.
+	File string `json:"file,omitempty"`
+}
+
+// Validate checks the options of a log directive.
+func (c *RuleLogConfig) Validate() error {
+	switch c.Level {
+	case "", RuleLogNone, RuleLogSummary, RuleLogFull:
+	default:
+		return fmt.Errorf("invalid log level: %s", c.Level)
+	}
+	switch c.Target {
+	case "", RuleLogMain, RuleLogSyslog:
+		if c.File != "" {
+			return fmt.Errorf("a log file can only be used with the target %s", RuleLogFile)
+		}
+	case RuleLogFile:
+		if !filepath.IsAbs(c.File) {
+			return fmt.Errorf("invalid log file, it must be an absolute path: '%s'", c.File)
+		}
+	default:
+		return fmt.Errorf("invalid log target: %s", c.Target)
+	}
+	return nil
+}
+
+// Silenced returns true if the connections must not be logged.
+func (c *RuleLogConfig) Silenced() bool {
+	return c != nil && c.Level == RuleLogNone
+}
+
+// ruleLog writes the records of the rules with a log directive. The files and
+// the syslog writer are opened the first time they're used.
+type ruleLog struct {
+	mu     sync.Mutex
+	files  map[string]*os.File
+	syslog *syslog.Writer
+}
+
+func newRuleLog() *ruleLog {
+	return &ruleLog{files: make(map[string]*os.File)}
+}
+
+// format returns the record of a connection, according to the level.
+func (r *ruleLog) format(con *protocol.Connection, action, ruleName, level string) string {
+	if level == RuleLogFull {
+		raw, err := json.Marshal(NewAuditRecord(con, action, ruleName))
+		if err == nil {
+			return string(raw)
+		}
+		log.Warning("[rule log] error formatting record: %s", err)
+	}
+	return fmt.Sprintf("rule=%s action=%s proto=%s dst=%s(%s):%d uid=%d pid=%d process=%s",
+		ruleName, action, con.Protocol, con.DstIp, con.DstHost, con.DstPort, con.UserId, con.ProcessId, con.ProcessPath)
+}
+
+func (r *ruleLog) write(cfg *RuleLogConfig, con *protocol.Connection, action, ruleName string) {
+	if cfg == nil || cfg.Silenced() || con == nil {
+		return
+	}
+	msg := r.format(con, action, ruleName, cfg.Level)
+
+	switch cfg.Target {
+	case RuleLogFile:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		f, found := r.files[cfg.File]
+		if !found {
+			var err error
+			f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+			if err != nil {
+				log.Warning("[rule log] error opening %s: %s", cfg.File, err)
+				return
+			}
+			r.files[cfg.File] = f
+		}
+		if _, err := f.WriteString(msg + "\n"); err != nil {
+			log.Warning("[rule log] error writing to %s: %s", cfg.File, err)
+		}
+	case RuleLogSyslog:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.syslog == nil {
+			var err error
+			if r.syslog, err = syslog.New(syslog.LOG_NOTICE|syslog.LOG_DAEMON, logTag); err != nil {
+				log.Warning("[rule log] error opening syslog: %s", err)
+				return
+			}
+		}
+		if err := r.syslog.Notice(msg); err != nil {
+			log.Warning("[rule log] error writing to syslog: %s", err)
+		}
+	default:
+		log.Info("[rule log] %s", msg)
+	}
+}
+
+// close closes the files and the syslog writer opened.
+func (r *ruleLog) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, f := range r.files {
+		f.Close()
+		delete(r.files, name)
+	}
+	if r.syslog != nil {
+		r.syslog.Close()
+		r.syslog = nil
+	}
+}
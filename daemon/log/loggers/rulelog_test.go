@@ -0,0 +1,62 @@
+package loggers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestRuleLog(t *testing.T) {
+	dir := t.TempDir()
+	for _, cfg := range []RuleLogConfig{
+		{Level: "verbose"},
+		{Target: "journal"},
+		{Target: RuleLogFile},
+		{Target: RuleLogFile, File: "relative.log"},
+		{Target: RuleLogSyslog, File: "/var/log/opensnitchd-rules.log"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("invalid log directive accepted: %+v", cfg)
+		}
+	}
+
+	con := &protocol.Connection{
+		Protocol:    "tcp",
+		DstIp:       "1.1.1.1",
+		DstHost:     "one.one.one.one",
+		DstPort:     443,
+		ProcessPath: "/usr/bin/curl",
+		ProcessArgs: []string{"curl", "https://one.one.one.one"},
+	}
+	lm := NewLoggerManager()
+	summary := &RuleLogConfig{Target: RuleLogFile, File: filepath.Join(dir, "summary.log")}
+	full := &RuleLogConfig{Level: RuleLogFull, Target: RuleLogFile, File: filepath.Join(dir, "full.log")}
+	none := &RuleLogConfig{Level: RuleLogNone, Target: RuleLogFile, File: filepath.Join(dir, "none.log")}
+	for _, cfg := range []*RuleLogConfig{summary, full, none} {
+		if err := cfg.Validate(); err != nil {
+			t.Fatal("valid log directive rejected:", err)
+		}
+		lm.LogRule(cfg, con, "deny", "deny-curl")
+	}
+	lm.Stop()
+
+	raw, err := os.ReadFile(summary.File)
+	if err != nil || !strings.Contains(string(raw), "rule=deny-curl action=deny") || strings.Count(string(raw), "\n") != 1 {
+		t.Error("invalid summary record:", err, string(raw))
+	}
+	raw, err = os.ReadFile(full.File)
+	if err != nil {
+		t.Fatal("error reading full log:", err)
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(raw, &rec); err != nil || rec.Rule != "deny-curl" || len(rec.Args) != 2 {
+		t.Error("invalid full record:", err, string(raw))
+	}
+	if _, err := os.Stat(none.File); !os.IsNotExist(err) {
+		t.Error("connections of a silenced rule logged:", err)
+	}
+}
@@ -23,8 +23,10 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -32,33 +34,56 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/pprof"
 	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/evilsocket/opensnitch/daemon/alerts"
 	"github.com/evilsocket/opensnitch/daemon/conman"
 	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/denypage"
 	"github.com/evilsocket/opensnitch/daemon/dns"
 	"github.com/evilsocket/opensnitch/daemon/dns/systemd"
+	"github.com/evilsocket/opensnitch/daemon/features"
 	"github.com/evilsocket/opensnitch/daemon/firewall"
+	"github.com/evilsocket/opensnitch/daemon/forwarder"
+	"github.com/evilsocket/opensnitch/daemon/hooks"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/listeners"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/log/loggers"
 	"github.com/evilsocket/opensnitch/daemon/netfilter"
 	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/netns"
+	"github.com/evilsocket/opensnitch/daemon/pcap"
+	"github.com/evilsocket/opensnitch/daemon/policyaudit"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
 	"github.com/evilsocket/opensnitch/daemon/procmon/ebpf"
 	"github.com/evilsocket/opensnitch/daemon/procmon/monitor"
+	"github.com/evilsocket/opensnitch/daemon/profile"
+	"github.com/evilsocket/opensnitch/daemon/replay"
 	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/rulesync"
 	"github.com/evilsocket/opensnitch/daemon/statistics"
+	"github.com/evilsocket/opensnitch/daemon/suggestions"
 	"github.com/evilsocket/opensnitch/daemon/ui"
 	"github.com/evilsocket/opensnitch/daemon/ui/config"
+	"github.com/evilsocket/opensnitch/daemon/ui/prompt"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+	"github.com/evilsocket/opensnitch/daemon/updater"
+	"github.com/evilsocket/opensnitch/daemon/upgrade"
 )
 
 var (
 	showVersion       = false
 	checkRequirements = false
+	showFeatures      = false
 	procmonMethod     = ""
 	logFile           = ""
 	logUTC            = true
@@ -69,9 +94,13 @@ var (
 	fwConfigFile      = ""
 	ebpfModPath       = "" // /usr/lib/opensnitchd/ebpf
 	noLiveReload      = false
+	monitorMode       = false
 	queueNum          = 0
-	repeatQueueNum    int //will be set later to queueNum + 1
+	queueCount        = 0
+	repeatQueueNum    int //will be set later to queueNum + queueCount
+	dnsQueueNum       uint16
 	workers           = 16
+	dnsWorkers        = 2
 	debug             = false
 	warning           = false
 	important         = false
@@ -85,30 +114,68 @@ var (
 	traceFile  = ""
 	memFile    *os.File
 
+	captureFile   = ""
+	replayFile    = ""
+	replayRounds  = 0
+	captureWriter *replay.Writer
+
+	exportRulesFile = ""
+	importRulesFile = ""
+	rulesFormat     = ""
+	importConflict  = rule.ConflictSkip
+	profileFile     = ""
+
+	storageKey = ""
+	unsealFile = ""
+
 	ctx           = (context.Context)(nil)
 	cancel        = (context.CancelFunc)(nil)
 	err           = (error)(nil)
 	rules         = (*rule.Loader)(nil)
 	stats         = (*statistics.Statistics)(nil)
-	queue         = (*netfilter.Queue)(nil)
+	queues        = (*netfilter.QueueGroup)(nil)
 	repeatQueue   = (*netfilter.Queue)(nil)
+	dnsQueue      = (*netfilter.Queue)(nil)
 	repeatPktChan = (<-chan netfilter.Packet)(nil)
 	pktChan       = (<-chan netfilter.Packet)(nil)
 	wrkChan       = (chan netfilter.Packet)(nil)
 	sigChan       = (chan os.Signal)(nil)
 	loggerMgr     *loggers.LoggerManager
 	resolvMonitor *systemd.ResolvedMonitor
+	handover      *upgrade.Handover
+
+	// time to wait for the packets already read to get a verdict, when
+	// handing over the queues to a new instance.
+	handOverTimeout = 5 * time.Second
+
+	// generation of the rules the jails have been updated with.
+	jailsGeneration = ^uint64(0)
+	jailsLock       sync.Mutex
+
+	// generation of the rules the UDP flows verdicted in kernel have been
+	// added with.
+	kernelFlowsGeneration = ^uint64(0)
+	kernelFlowsLock       sync.Mutex
+
+	// tag of the connections reported in monitor mode.
+	monitorTag = "monitor"
+
+	// serializes the reloads requested with SIGHUP.
+	reloadLock sync.Mutex
 )
 
 func init() {
 	flag.BoolVar(&showVersion, "version", debug, "Show daemon version of this executable and exit.")
 	flag.BoolVar(&checkRequirements, "check-requirements", debug, "Check system requirements for incompatibilities.")
+	flag.BoolVar(&showFeatures, "features", showFeatures, "Show the kernel features supported by this host and exit.")
 
 	flag.StringVar(&procmonMethod, "process-monitor-method", procmonMethod, "Options: audit, ebpf, proc (default)")
 	flag.StringVar(&uiSocket, "ui-socket", uiSocket, "Path the UI gRPC service listener (https://github.com/grpc/grpc/blob/master/doc/naming.md).")
 	flag.IntVar(&queueNum, "queue-num", queueNum, "Netfilter queue number.")
+	flag.IntVar(&queueCount, "queue-count", queueCount, "Number of netfilter queues, from the queue number, to balance the connections.")
 	flag.IntVar(&workers, "workers", workers, "Number of concurrent workers.")
 	flag.BoolVar(&noLiveReload, "no-live-reload", debug, "Disable rules live reloading.")
+	flag.BoolVar(&monitorMode, "monitor-mode", monitorMode, "Report the connections with the verdicts of the rules, without enforcing them.")
 
 	flag.StringVar(&rulesPath, "rules-path", rulesPath, "Path to load JSON rules from.")
 	flag.StringVar(&configFile, "config-file", configFile, "Path to the daemon configuration file.")
@@ -125,6 +192,18 @@ func init() {
 	flag.StringVar(&cpuProfile, "cpu-profile", cpuProfile, "Write CPU profile to this file.")
 	flag.StringVar(&memProfile, "mem-profile", memProfile, "Write memory profile to this file.")
 	flag.StringVar(&traceFile, "trace-file", traceFile, "Write trace file to this file.")
+
+	flag.StringVar(&captureFile, "capture-file", captureFile, "Write the intercepted connections to this file, to replay them later.")
+	flag.StringVar(&replayFile, "replay-file", replayFile, "Evaluate the connections of a capture file against the rules, print the differences and exit.")
+	flag.IntVar(&replayRounds, "replay-benchmark", replayRounds, "With -replay-file, evaluate the connections this number of rounds, evaluating all the rules and only the rules indexed, print the timings and exit.")
+
+	flag.StringVar(&exportRulesFile, "export-rules", exportRulesFile, "Export all the rules to this file (- for stdout) and exit.")
+	flag.StringVar(&importRulesFile, "import-rules", importRulesFile, "Import the rules of this file, save them to the rules path and exit.")
+	flag.StringVar(&rulesFormat, "rules-format", rulesFormat, "Format of the rules to export or import: json or csv (by default, by the file extension).")
+	flag.StringVar(&importConflict, "import-conflict", importConflict, "What to do with the imported rules that already exist: skip, overwrite or rename.")
+	flag.StringVar(&storageKey, "storage-key", storageKey, "Key to encrypt the rules and the configuration at rest: a file, or the name of a systemd credential (by default, the credential "+core.StorageKeyCredential+" if it's passed to the service).")
+	flag.StringVar(&unsealFile, "unseal-file", unsealFile, "Decrypt a rule, configuration or decisions file encrypted with the storage key to the standard output and exit.")
+	flag.StringVar(&profileFile, "generate-profile", profileFile, "Propose a default-deny set of rules from the connections established, write it to this file, and apply it after confirmation.")
 }
 
 // Load configuration file from disk, by default from /etc/opensnitchd/default-config.json,
@@ -167,29 +246,233 @@ func overwriteFw(cfg *config.Config, qNum uint16, fwCfg string) {
 	//setupQueues(qNum)
 }
 
-func setupQueues(qNum uint16) {
+// setupQueues listens on the queues of the connections, from qNum to
+// qNum+qCount-1, and on the repeat queue, the next one.
+func setupQueues(qNum, qCount uint16) {
 	// prepare the queue
 	var err error
-	queue, err = netfilter.NewQueue(qNum)
+	if handover != nil && handover.State.QueueNum == qNum && handover.State.Queues() == qCount {
+		if err = setupInheritedQueues(); err == nil {
+			log.Info("Listening on inherited queue number %d (%d queues) ...", qNum, qCount)
+			return
+		}
+		log.Warning("[upgrade] unable to use the inherited queues: %s", err)
+	}
+	queues, err = netfilter.NewQueueGroup(qNum, qCount)
 	if err != nil {
-		msg := fmt.Sprintf("Error creating queue #%d: %s", qNum, err)
+		msg := i18n.New(i18n.QueueCreateError, "queue", qNum, "error", err)
 		uiClient.SendWarningAlert(msg)
 		log.Warning("Is opensnitchd already running?")
 		log.Fatal("%s", msg)
 	}
-	pktChan = queue.Packets()
+	pktChan = queues.Packets()
 
-	repeatQueueNum = int(qNum) + 1
+	repeatQueueNum = int(qNum) + int(qCount)
 
 	repeatQueue, err = netfilter.NewQueue(uint16(repeatQueueNum))
 	if err != nil {
-		msg := fmt.Sprintf("Error creating repeat queue #%d: %s", repeatQueueNum, err)
+		msg := i18n.New(i18n.RepeatQueueCreateError, "queue", repeatQueueNum, "error", err)
 		uiClient.SendErrorAlert(msg)
 		log.Warning("Is opensnitchd already running?")
 		log.Warning("%s", msg)
 	}
 	repeatPktChan = repeatQueue.Packets()
-	log.Info("Listening on queue number %d ...", qNum)
+	log.Info("Listening on queue number %d (%d queues) ...", qNum, qCount)
+}
+
+// setupDNSQueue listens on a dedicated queue for the DNS responses, if
+// configured, so they're not delayed by the connections packets and vice versa.
+func setupDNSQueue(qNum, qCount, dnsNum uint16) {
+	if dnsNum == 0 || dnsNum == qNum {
+		return
+	}
+	if dnsNum > qNum && dnsNum <= qNum+qCount {
+		log.Warning("DNS queue #%d is one of the connections queues (#%d-#%d), DNS responses will be sent to queue #%d", dnsNum, qNum, qNum+qCount, qNum)
+		return
+	}
+
+	var err error
+	if f := inheritedDNSQueue(dnsNum); f != nil {
+		dnsQueue, err = netfilter.NewQueueFromFile(dnsNum, f)
+	} else {
+		dnsQueue, err = netfilter.NewQueue(dnsNum)
+	}
+	if err != nil {
+		msg := i18n.New(i18n.DNSQueueCreateError, "queue", dnsNum, "error", err)
+		uiClient.SendErrorAlert(msg)
+		log.Warning("%s", msg)
+		return
+	}
+	stats.AddQueue("dns", dnsNum)
+	netfilter.Watchdog.AddQueue(dnsQueue)
+	for i := 0; i < dnsWorkers; i++ {
+		go dnsWorker(i, dnsQueue.Packets())
+	}
+	log.Info("Listening DNS responses on queue number %d ...", dnsNum)
+}
+
+func inheritedDNSQueue(dnsNum uint16) *os.File {
+	if handover == nil || handover.State.DNSQueueNum != dnsNum {
+		return nil
+	}
+	return handover.File(upgrade.FileDNSQueue)
+}
+
+func setupInheritedQueues() error {
+	count := handover.State.Queues()
+	files := make([]*os.File, 0, count)
+	for i := uint16(0); i < count; i++ {
+		f := handover.File(upgrade.QueueFile(int(i)))
+		if f == nil {
+			return fmt.Errorf("queue #%d file-descriptor not received", handover.State.QueueNum+i)
+		}
+		files = append(files, f)
+	}
+	rf := handover.File(upgrade.FileRepeatQueue)
+	if rf == nil {
+		return fmt.Errorf("repeat queue file-descriptor not received")
+	}
+	inherited := make([]*netfilter.Queue, 0, count)
+	for i, f := range files {
+		q, err := netfilter.NewQueueFromFile(handover.State.QueueNum+uint16(i), f)
+		if err != nil {
+			return err
+		}
+		inherited = append(inherited, q)
+	}
+	var err error
+	repeatQueueNum = int(handover.State.QueueNum) + int(count)
+	if repeatQueue, err = netfilter.NewQueueFromFile(uint16(repeatQueueNum), rf); err != nil {
+		return err
+	}
+	queues = netfilter.NewQueueGroupFrom(inherited...)
+	pktChan = queues.Packets()
+	repeatPktChan = repeatQueue.Packets()
+	return nil
+}
+
+// inheritState restores the state handed over by the previous instance of the
+// daemon.
+func inheritState() {
+	log.Info("[upgrade] restoring %d DNS records and %d rules", len(handover.State.DNS), len(handover.State.Rules))
+	dns.Restore(handover.State.DNS)
+	for _, r := range handover.State.Rules {
+		if err := rules.Replace(r, false); err != nil {
+			log.Warning("[upgrade] unable to restore rule %s: %s", r.Name, err)
+		}
+	}
+	if handover.State.PanicMode {
+		if err := uiClient.EnablePanicMode(); err != nil {
+			log.Error("[upgrade] unable to enable panic mode: %s", err)
+		}
+	}
+}
+
+// handOver starts a new instance of the daemon, handing it over the queues,
+// the eBPF maps and the in-memory state. If the new instance starts
+// successfully, this one exits without deleting the firewall rules.
+func handOver() {
+	if queues == nil || repeatQueue == nil {
+		log.Warning("[upgrade] queues not ready yet, ignoring upgrade request")
+		return
+	}
+	log.Important("[upgrade] handing over to a new instance ...")
+
+	files := make(map[string]*os.File)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	handed := map[string]*netfilter.Queue{upgrade.FileRepeatQueue: repeatQueue}
+	for i, q := range queues.Queues() {
+		handed[upgrade.QueueFile(i)] = q
+	}
+	if dnsQueue != nil {
+		handed[upgrade.FileDNSQueue] = dnsQueue
+	}
+	for name, q := range handed {
+		f, err := q.File(name)
+		if err != nil {
+			log.Error("[upgrade] %s", err)
+			return
+		}
+		files[name] = f
+	}
+	for name, f := range ebpf.MapFiles() {
+		files[upgrade.FileEbpfMap+name] = f
+	}
+
+	state := &upgrade.State{
+		DNS:         dns.GetAll(),
+		QueueNum:    queues.Num(),
+		QueueCount:  uint16(queues.Len()),
+		DNSQueueNum: dnsQueueNum,
+		PanicMode:   firewall.IsPanicMode(),
+	}
+	for _, r := range rules.GetAll() {
+		if r.Duration != rule.Always {
+			state.Rules = append(state.Rules, r)
+		}
+	}
+
+	var exe string
+	if installed, err := upgrade.Executable(); err == nil {
+		exe = updater.Default.Staged(installed)
+	}
+	if exe != "" {
+		log.Important("[upgrade] executing the binary staged by the updater: %s", exe)
+	}
+	if _, err := upgrade.Start(exe, state, files); err != nil {
+		msg := i18n.New(i18n.UpgradeError, "error", err)
+		log.Error("%s", msg)
+		uiClient.SendErrorAlert(msg)
+		return
+	}
+
+	log.Important("[upgrade] the new instance is ready, exiting")
+	// the packets read by this instance must get a verdict before exiting,
+	// the new one reads the following ones.
+	netfilter.HandOver(handOverTimeout)
+	if captureWriter != nil {
+		captureWriter.Close()
+	}
+	pcap.Denied.Close()
+	os.Exit(0)
+}
+
+// runUnsealFile writes the content of a file encrypted with the storage key to
+// the standard output, and exits. The files of records (decisions) are
+// encrypted line by line.
+func runUnsealFile() {
+	raw, err := core.ReadSealedFile(unsealFile)
+	if err != nil {
+		log.Fatal("Error decrypting %s: %s", unsealFile, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), len(raw)+1)
+	for scanner.Scan() {
+		line, _, err := core.UnsealLine(scanner.Bytes())
+		if err != nil {
+			log.Fatal("Error decrypting %s: %s", unsealFile, err)
+		}
+		os.Stdout.Write(append(line, '\n'))
+	}
+	os.Exit(0)
+}
+
+// sealPlaintextFiles encrypts the configuration, the rules and the templates
+// written before enabling the encryption at rest, if it's enabled.
+func sealPlaintextFiles(rulesPath string) {
+	if !core.StorageEncrypted() {
+		return
+	}
+	files := []string{configFile, filepath.Join(rulesPath, rule.TemplatesDir, rule.VariablesFile)}
+	for _, dir := range []string{rulesPath, filepath.Join(rulesPath, rule.TemplatesDir), filepath.Join(rulesPath, rule.PromptsDir)} {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		files = append(files, matches...)
+	}
+	core.SealFiles(files)
 }
 
 func setupLogging() {
@@ -254,9 +537,37 @@ func setupSignals() {
 		syscall.SIGHUP,
 		syscall.SIGINT,
 		syscall.SIGTERM,
-		syscall.SIGQUIT)
+		syscall.SIGQUIT,
+		syscall.SIGUSR1,
+		syscall.SIGUSR2)
 	go func() {
 		sig := <-sigChan
+		// SIGUSR1 enables the panic mode (disabled from the UI), SIGUSR2
+		// upgrades the daemon, and only returns if it failed, and SIGHUP
+		// reloads the configuration.
+		for ; sig == syscall.SIGUSR1 || sig == syscall.SIGUSR2 || sig == syscall.SIGHUP; sig = <-sigChan {
+			if sig == syscall.SIGUSR2 {
+				handOver()
+				continue
+			}
+			if sig == syscall.SIGHUP {
+				if err := reloadDaemon(); err != nil {
+					msg := i18n.New(i18n.ReloadError, "error", err)
+					log.Error("[reload] %s", msg)
+					if uiClient != nil {
+						uiClient.SendErrorAlert(msg)
+					}
+				}
+				continue
+			}
+			if uiClient == nil {
+				log.Warning("[panic] daemon not ready yet, ignoring panic mode request")
+				continue
+			}
+			if err := uiClient.EnablePanicMode(); err != nil {
+				log.Error("[panic] unable to enable panic mode: %s", err)
+			}
+		}
 		log.Raw("\n")
 		log.Important("Got signal: %v", sig)
 		cancel()
@@ -267,6 +578,63 @@ func setupSignals() {
 	}()
 }
 
+// reloadDaemon reloads the configuration, the rules and the system firewall
+// rules, without restarting the daemon, so the connections are still
+// intercepted and the events are not lost.
+// Everything is validated before applying anything, and if applying fails,
+// the previous configuration and rules are restored.
+func reloadDaemon() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	if uiClient == nil {
+		return fmt.Errorf("daemon not ready yet")
+	}
+	log.Important("[reload] reloading configuration %s ...", configFile)
+
+	cfg, err := loadDiskConfiguration()
+	if err != nil {
+		return err
+	}
+	path := cfg.Rules.Path
+	if rulesPath != "" {
+		path = rulesPath
+	}
+	if err := rule.ValidatePath(path); err != nil {
+		return err
+	}
+	if err := firewall.ValidateConfiguration(); err != nil {
+		return fmt.Errorf("firewall configuration: %s", err)
+	}
+
+	prevPath := rules.Path
+	restoreConfig, err := uiClient.ReloadConfiguration()
+	if err != nil {
+		return err
+	}
+	// the options from the command line take precedence.
+	if overwriteLogging() {
+		setupLogging()
+	}
+
+	if err := rules.Reload(path); err != nil {
+		log.Warning("[reload] restoring rules from %s: %s", prevPath, err)
+		rules.Reload(prevPath)
+		restoreConfig()
+		return err
+	}
+	if err := firewall.ReloadConfiguration(); err != nil {
+		// the system rules are not modified if the configuration is not valid.
+		log.Warning("[reload] restoring rules from %s: %s", prevPath, err)
+		rules.Reload(prevPath)
+		restoreConfig()
+		return fmt.Errorf("firewall configuration: %s", err)
+	}
+
+	log.Important("[reload] configuration, rules and system firewall rules reloaded")
+	return nil
+}
+
 func worker(id int) {
 	log.Debug("Worker #%d started.", id)
 	for true {
@@ -286,6 +654,51 @@ Exit:
 	log.Debug("worker #%d exit", id)
 }
 
+// setupQueuesWatchdog configures the actions to take when the queued packets
+// are not being processed, according to the fail policy (open or closed).
+func setupQueuesWatchdog() {
+	netfilter.Watchdog.OnStall = func(reason i18n.Message, bypass bool) {
+		msg := i18n.New(i18n.ConnectionsStalled, "reason", reason)
+		if bypass {
+			msg = i18n.New(i18n.ConnectionsStalledBypass, "reason", reason)
+			firewall.DisableInterception()
+		}
+		log.Important("%s", msg)
+		if uiClient != nil {
+			uiClient.SendErrorAlert(msg)
+			if bypass {
+				uiClient.SendInterceptionChanged(false, ui.SourceWatchdog, reason.String())
+			}
+		}
+	}
+	netfilter.Watchdog.OnRecover = func(bypass bool) {
+		msg := i18n.New(i18n.ConnectionsRecovered)
+		if bypass {
+			msg = i18n.New(i18n.ConnectionsRecoveredBypass)
+			firewall.EnableInterception()
+		}
+		log.Important("%s", msg)
+		if uiClient != nil {
+			uiClient.SendInfoAlert(msg)
+			if bypass {
+				uiClient.SendInterceptionChanged(true, ui.SourceWatchdog, "")
+			}
+		}
+	}
+}
+
+// dnsWorker processes the packets of the DNS responses queue.
+func dnsWorker(id int, packets <-chan netfilter.Packet) {
+	log.Debug("DNS worker #%d started.", id)
+	for pkt := range packets {
+		if !onDNSPacket(pkt) {
+			pkt.SetVerdictAndMark(netfilter.NF_ACCEPT, pkt.Mark)
+		}
+		pkt.Release()
+	}
+	log.Debug("DNS worker #%d exit", id)
+}
+
 func setupWorkers() {
 	log.Debug("Starting %d workers ...", workers)
 	// setup the workers
@@ -368,14 +781,25 @@ func initSystemdResolvedMonitor() {
 	}()
 }
 
-func doCleanup(queue, repeatQueue *netfilter.Queue) {
+func doCleanup(queues *netfilter.QueueGroup, repeatQueue *netfilter.Queue) {
 	log.Info("Cleaning up ...")
+	netfilter.Watchdog.Stop()
+	firewall.SetJails(nil)
+	netns.Default.Stop()
 	firewall.Stop()
 	monitor.End()
 	uiClient.Close()
 	if resolvMonitor != nil {
 		resolvMonitor.Close()
 	}
+	if captureWriter != nil {
+		captureWriter.Close()
+	}
+	pcap.Denied.Close()
+	rulesync.Default.Stop()
+	if err := dns.Cache.Save(); err != nil {
+		log.Warning("[DNS] error saving the cache: %s", err)
+	}
 
 	if cpuProfile != "" {
 		pprof.StopCPUProfile()
@@ -392,35 +816,126 @@ func doCleanup(queue, repeatQueue *netfilter.Queue) {
 	if traceFile != "" {
 		trace.Stop()
 	}
+	logQueuesCounters(queues)
 
+	if dnsQueue != nil {
+		dnsQueue.Close()
+	}
 	repeatQueue.Close()
-	queue.Close()
+	queues.Close()
+}
+
+// logQueuesCounters logs the syscalls saved by reading the packets and setting
+// their verdicts in batches, and the buffers reused.
+func logQueuesCounters(queues *netfilter.QueueGroup) {
+	var packets uint64
+	for _, q := range queues.Queues() {
+		c := q.Counters()
+		batched, unbatched := c.Syscalls()
+		packets += c.Verdicts + c.Timeouts
+		log.Info("queue %d: %d packets, %d syscalls (%d without batching), %d verdicts batched",
+			q.Num(), c.Verdicts+c.Timeouts, batched, unbatched, c.Batched)
+	}
+	pool := netfilter.GetPoolCounters()
+	log.Info("packets buffers: %d allocated for %d packets, %d reused", pool.Allocated, packets, pool.Released)
 }
 
+// onDNSPacket parses, tracks and accepts DNS responses.
+// It returns false if the packet is not a DNS response.
+func onDNSPacket(packet netfilter.Packet) bool {
+	if dns.TrackAnswers(packet.Packet) == false {
+		return false
+	}
+	packet.SetVerdictAndMark(netfilter.NF_ACCEPT, packet.Mark)
+	stats.OnDNSResponse()
+	return true
+}
+
+// The packets are released only when the connections parsed from them are
+// discarded, the rest are not reused.
 func onPacket(packet netfilter.Packet) {
-	// DNS response, just parse, track and accept.
-	if dns.TrackAnswers(packet.Packet) == true {
-		packet.SetVerdictAndMark(netfilter.NF_ACCEPT, packet.Mark)
-		stats.OnDNSResponse()
+	if onDNSPacket(packet) {
+		packet.Release()
 		return
 	}
 
 	// Parse the connection state
 	con := conman.Parse(packet, uiClient.InterceptUnknown())
 	if con == nil {
-		applyDefaultAction(&packet, nil)
+		// apply the default action of its protocol (i.e.: IGMP) and destination.
+		applyDefaultAction(&packet, conman.ParseHeaders(packet))
 		return
 	}
 	// accept our own connections
 	if con.Process.ID == os.Getpid() {
 		packet.SetVerdict(netfilter.NF_ACCEPT)
+		packet.Release()
+		return
+	}
+
+	// the packets of a UDP flow already verdicted are not new connections.
+	if verdict, found := conman.Flows.Get(con, rules.Generation()); found {
+		applyVerdict(&packet, con, verdict.(*rule.Rule))
+		verdictFlowInKernel(con, verdict.(*rule.Rule))
+		packet.Release()
+		return
+	}
+
+	// the connections of the local proxies are attributed to the apps that
+	// connected to them.
+	conman.Proxies.Track(con)
+	con.Origin = conman.Proxies.Origin(con)
+
+	monitoring := isMonitoring()
+
+	// the SYN retransmissions of a connection already denied.
+	if !monitoring && conman.Retransmits.IsRetransmission(con, rules.Generation()) {
+		packet.SetVerdict(netfilter.NF_DROP)
+		dropRetransmissions(con)
+		packet.Release()
 		return
 	}
 
+	// the processes of the binaries jailed by the rules are moved to their
+	// jails, and their connections denied if they can't be jailed.
+	if !monitoring && !jailConnection(con) {
+		packet.SetVerdict(netfilter.NF_DROP)
+		packet.Release()
+		return
+	}
+
+	alerts.Default.OnConnection(con)
+	suggestions.Default.Record(con)
+
 	// search a match in preloaded rules
 	r := acceptOrDeny(&packet, con)
+	if r != nil {
+		conman.Flows.Add(con, rules.Generation(), r)
+		verdictFlowInKernel(con, r)
+		con.Tags = r.Tags
+		alerts.Default.OnTaggedConnection(con, r.Name)
+		if !monitoring {
+			killProcess(con, r)
+			runHook(con, r)
+			trackDenied(con, r)
+		}
+	}
+	trackAllowed(con, r)
+	captureConnection(con, r)
+	dumpDenied(&packet, con, r)
 
 	if r != nil && r.Nolog {
+		stats.OnRuleHit(r.Name)
+		return
+	}
+	if monitoring {
+		// the connections not matched are reported with the default
+		// action, so all of them can be reviewed.
+		if r == nil {
+			r = uiClient.MonitorRule(con)
+		}
+		con.Tags = append(append([]string{}, con.Tags...), monitorTag)
+		stats.OnConnectionEvent(con, r, false)
 		return
 	}
 	// XXX: if a connection is not intercepted due to InterceptUnknown == false,
@@ -428,28 +943,405 @@ func onPacket(packet netfilter.Packet) {
 	stats.OnConnectionEvent(con, r, r == nil)
 }
 
+// isMonitoring returns true if the verdicts must only be reported, from the
+// command line or the configuration.
+func isMonitoring() bool {
+	return monitorMode || uiClient.MonitorMode()
+}
+
+// acceptMonitored accepts a packet in monitor mode, logging the verdict that
+// would have been applied to it.
+func acceptMonitored(packet *netfilter.Packet, con *conman.Connection, action rule.Action, ruleName string) {
+	packet.SetVerdictAndMark(netfilter.NF_ACCEPT, packet.Mark)
+	if con == nil || con.Process == nil {
+		log.Trace("[monitor] would %s %s (%s)", action, con, ruleName)
+		return
+	}
+	log.Debug("[monitor] would %s %s -> %s:%d (%s)", action, con.Process.Path, con.To(), con.DstPort, ruleName)
+}
+
+func captureConnection(con *conman.Connection, r *rule.Rule) {
+	if captureWriter == nil {
+		return
+	}
+	action, ruleName := string(uiClient.DefaultActionFor(con)), ""
+	if r != nil {
+		action, ruleName = string(r.Action), r.Name
+	}
+	if err := captureWriter.Write(con.Serialize(), action, ruleName); err != nil {
+		log.Warning("Error writing connection to capture file: %s", err)
+	}
+}
+
+// dumpDenied writes the packets of the denied connections to the pcap file,
+// if it's enabled.
+func dumpDenied(packet *netfilter.Packet, con *conman.Connection, r *rule.Rule) {
+	action := uiClient.DefaultActionFor(con)
+	if r != nil {
+		action = r.Action
+	}
+	if !action.Allows() {
+		pcap.Denied.Write(packet, con)
+	}
+}
+
 func applyDefaultAction(packet *netfilter.Packet, con *conman.Connection) {
-	log.Trace("Applying DefaultAction (%s) on %s", uiClient.DefaultAction(), con)
-	if uiClient.DefaultAction() == rule.Allow {
+	action := uiClient.DefaultActionFor(con)
+	if isMonitoring() {
+		acceptMonitored(packet, con, action, "default-action")
+		return
+	}
+	log.Trace("Applying DefaultAction (%s) on %s", action, con)
+	if action == rule.Allow {
 		packet.SetVerdictAndMark(netfilter.NF_ACCEPT, packet.Mark)
 		return
 	}
-	if uiClient.DefaultAction() == rule.Reject && con != nil {
-		netlink.KillSocket(con.Protocol, con.SrcIP, con.SrcPort, con.DstIP, con.DstPort)
+	if action == rule.Reject {
+		rejectConnection(packet, con)
+		return
 	}
 	packet.SetVerdict(netfilter.NF_DROP)
 }
 
-func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
+// rejectConnection drops the packet, and notifies the application that the
+// connection has been refused, so it doesn't wait until it times out.
+func rejectConnection(packet *netfilter.Packet, con *conman.Connection) {
+	// the connections parsed only from the headers of the packet have no
+	// socket.
+	if con != nil && con.Process != nil {
+		netlink.KillSocket(con.Protocol, con.SrcIP, con.SrcPort, con.DstIP, con.DstPort)
+	}
+	packet.SetRejectVerdict()
+}
+
+// trackDenied tracks the TCP connections dropped by a rule, in order to drop
+// the retransmissions of their SYN packets without evaluating and logging them
+// again.
+// Rejected connections are not retried, because the application is notified.
+func trackDenied(con *conman.Connection, r *rule.Rule) {
+	if !r.Enabled || r.Action != rule.Deny || !conman.Retransmits.Enabled() || denypage.Default.Handles(con) {
+		return
+	}
+	conman.Retransmits.Add(con, rules.Generation())
+	dropRetransmissions(con)
+}
+
+// dropRetransmissions drops in kernel the next retransmissions of a denied
+// connection, if the firewall supports it. Otherwise they're dropped when
+// they're queued.
+func dropRetransmissions(con *conman.Connection) {
+	if !strings.HasPrefix(con.Protocol, "tcp") {
+		return
+	}
+	if err := firewall.DropRetransmissions(con.SrcIP, con.SrcPort, con.DstIP, con.DstPort, conman.Retransmits.Timeout()); err != nil {
+		log.Trace("[retransmits] %s", err)
+	}
+}
+
+// verdictFlowInKernel accepts or drops in kernel the following packets of a
+// UDP flow, if the firewall supports it, so they're not queued.
+// The packets of a flow expired are queued again, and added again.
+func verdictFlowInKernel(con *conman.Connection, r *rule.Rule) {
+	enabled, timeout := conman.Flows.InKernel()
+	if !enabled || isMonitoring() || !strings.HasPrefix(con.Protocol, "udp") {
+		return
+	}
+	// rejecting a packet notifies the application, it can't be done in kernel.
+	if !r.Enabled || (r.Action != rule.Allow && r.Action != rule.Deny) {
+		return
+	}
+	syncKernelFlows()
+	if err := firewall.AddFlow(con.SrcIP, con.SrcPort, con.DstIP, con.DstPort, r.Action == rule.Allow, timeout); err != nil {
+		log.Trace("[flows] %s", err)
+	}
+}
+
+// syncKernelFlows deletes the UDP flows verdicted in kernel when the rules
+// change, or when the option is disabled, so their packets are evaluated
+// again.
+func syncKernelFlows() {
+	kernelFlowsLock.Lock()
+	defer kernelFlowsLock.Unlock()
+	generation := rules.Generation()
+	if enabled, _ := conman.Flows.InKernel(); !enabled || isMonitoring() {
+		generation = ^uint64(0)
+	}
+	if generation == kernelFlowsGeneration {
+		return
+	}
+	kernelFlowsGeneration = generation
+	if err := firewall.FlushFlows(); err != nil {
+		log.Trace("[flows] %s", err)
+	}
+}
+
+// watchKernelFlows checks every second if the rules have changed, to delete
+// the UDP flows verdicted in kernel.
+func watchKernelFlows(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			syncKernelFlows()
+		}
+	}
+}
+
+// jailConnection moves the process of a connection to the jail of its binary,
+// if it's jailed by a rule, and returns false if the connection must be
+// denied: the process can't be jailed, or the destination is not allowed by
+// the jail.
+func jailConnection(con *conman.Connection) bool {
+	syncJails()
+	jail, err := firewall.JailProcess(con.Process.Path, con.Process.ID)
+	if jail == nil {
+		return true
+	}
+	if err != nil {
+		log.Warning("[jails] unable to jail %s (%d), denying connection to %s: %s", con.Process.Path, con.Process.ID, con.DstIP, err)
+		return false
+	}
+	if !jail.Allows(con.DstIP) {
+		log.Debug("[jails] %s: connection to %s not allowed by the jail", con.Process.Path, con.DstIP)
+		return false
+	}
+	return true
+}
+
+// syncJails updates the jails of the firewall when the rules change.
+func syncJails() {
+	jailsLock.Lock()
+	defer jailsLock.Unlock()
+	generation := rules.Generation()
+	if generation == jailsGeneration {
+		return
+	}
+	jailsGeneration = generation
+
+	jails := make(map[string][]*net.IPNet)
+	for path, dsts := range rules.Jails() {
+		jails[path] = make([]*net.IPNet, 0, len(dsts))
+		for _, dst := range dsts {
+			nets, err := firewall.ParseDestinations([]string{dst})
+			if err != nil {
+				log.Warning("[jails] %s: invalid destination %s: %s", path, dst, err)
+				continue
+			}
+			jails[path] = append(jails[path], nets...)
+		}
+	}
+	if err := firewall.SetJails(jails); err != nil {
+		log.Warning("[jails] error updating the jails: %s", err)
+	}
+}
+
+// trackAllowed tracks the connections allowed until they end, to account the
+// bytes and packets they carry.
+func trackAllowed(con *conman.Connection, r *rule.Rule) {
+	action, ruleName := uiClient.DefaultActionFor(con), ""
+	if r != nil && r.Enabled {
+		action, ruleName = r.Action, r.Name
+	}
+	if action.Allows() || isMonitoring() {
+		conman.Accounting.Add(con, ruleName)
+	}
+}
+
+// onFlowEnd sends a connection ended to the GUI if it's configured to do so.
+// Its bytes and packets are added to the stats by stats.OnFlowUsage().
+func onFlowEnd(f *conman.FlowEnd) {
+	log.Debug("[accounting] connection closed (%s): %s, sent: %d, received: %d", f.Rule, f.Con, f.BytesSent, f.BytesRecv)
+	if conman.Accounting.ClosedEvents() && uiClient != nil {
+		uiClient.PostAlert(protocol.Alert_INFO, protocol.Alert_CONNECTION_CLOSED, protocol.Alert_SAVE_TO_DB, protocol.Alert_LOW, f)
+	}
+}
+
+// killProcess terminates the process of a connection, if the rule that has
+// denied the connection is configured to kill it.
+func killProcess(con *conman.Connection, r *rule.Rule) {
+	sig, kill := r.KillSignal()
+	if !kill || con.Process == nil {
+		return
+	}
+	if err := con.Process.Kill(sig); err != nil {
+		log.Warning("[%s] unable to kill %s (%d): %s", r.Name, con.Process.Path, con.Process.ID, err)
+		return
+	}
+	log.Important("[%s] process killed (%s): %s (%d) -> %s:%d", r.Name, r.Kill, con.Process.Path, con.Process.ID, con.To(), con.DstPort)
+}
+
+// runHook runs the hook of the rule that has matched a connection, if any.
+func runHook(con *conman.Connection, r *rule.Rule) {
+	if r.Hook == nil {
+		return
+	}
+	hooks.Default.Run(r.Hook, con.Serialize(), string(r.Action), r.Name, r.Action.Allows())
+}
+
+// onFirewallWiped notifies that the interception rules have been deleted or
+// modified by other program, before restoring them.
+func onFirewallWiped() {
+	alerts.Default.OnFirewallWiped()
+	policyaudit.Default.OnFirewallModified()
+	if uiClient != nil {
+		uiClient.PostAlert(
+			protocol.Alert_WARNING,
+			protocol.Alert_FIREWALL,
+			protocol.Alert_SHOW_ALERT,
+			protocol.Alert_HIGH,
+			i18n.New(i18n.FirewallWiped))
+	}
+}
+
+// onPolicyAudit sends the findings of a policy audit to the alerts and the
+// loggers.
+func onPolicyAudit(report *policyaudit.Report) {
+	loggerMgr.Log(report.String())
+	if len(report.Findings) == 0 {
+		return
+	}
+	fields := make(map[string]string)
+	for check, n := range report.Count() {
+		fields[check] = strconv.Itoa(n)
+	}
+	alerts.Default.Send(alerts.EventPolicyAudit, "",
+		i18n.New(i18n.PolicyAuditTitle, "findings", len(report.Findings)),
+		report.Message(),
+		fields)
+}
+
+// onUpdateAvailable notifies that a new version of the daemon has been
+// staged, to be executed by the next upgrade (SIGUSR2).
+func onUpdateAvailable(status updater.Status) {
+	msg := i18n.New(i18n.UpdateAvailable, "version", status.Available, "current", status.Current, "path", status.Staged)
+	loggerMgr.Log(msg.String())
+	alerts.Default.Send(alerts.EventUpdateAvailable, status.Available,
+		i18n.New(i18n.UpdateAvailableTitle, "version", status.Available),
+		msg,
+		map[string]string{"version": status.Available, "staged": status.Staged, "notes": status.Notes})
+	if uiClient != nil {
+		uiClient.SendInfoAlert(msg)
+	}
+}
+
+// onListenerEvent sends the new listeners, and the ones denied by the rules,
+// to the GUI, the alerts and the loggers.
+func onListenerEvent(ev *listeners.Event) {
+	loggerMgr.Log(ev.Message.String())
+	l := ev.Listener
+	fields := map[string]string{
+		"pid":    strconv.Itoa(l.Process.ID),
+		"uid":    strconv.FormatUint(uint64(l.UID), 10),
+		"path":   l.Process.Path,
+		"listen": fmt.Sprintf("%s %s:%d", l.Proto, l.IP, l.Port),
+	}
+	alertType, priority := protocol.Alert_INFO, protocol.Alert_LOW
+	switch ev.Kind {
+	case listeners.EventNewListener:
+		alerts.Default.Send(alerts.EventNewListener, l.Process.Path,
+			i18n.New(i18n.NewListenerTitle), ev.Message, fields)
+	case listeners.EventPrivilegedPort:
+		alertType, priority = protocol.Alert_WARNING, protocol.Alert_MEDIUM
+		alerts.Default.Send(alerts.EventPrivilegedPort, fmt.Sprint(l.Process.Path, l.Port),
+			i18n.New(i18n.PrivilegedPortTitle), ev.Message, fields)
+	case listeners.EventDenied:
+		alertType, priority = protocol.Alert_WARNING, protocol.Alert_HIGH
+	}
+	if uiClient != nil {
+		uiClient.PostAlert(alertType, protocol.Alert_KERNEL_EVENT, protocol.Alert_SHOW_ALERT, priority, ev.Message)
+	}
+}
+
+// probeFeatures probes the kernel features supported by the host, to report
+// them to the GUI.
+func probeFeatures() {
+	report := features.Probe()
+	if unsupported := report.Unsupported(); len(unsupported) > 0 {
+		log.Info("[features] not supported by this host: %s", strings.Join(unsupported, ", "))
+	}
+}
+
+// onChecksumQuarantine sends the binaries whose connections are denied,
+// because their checksum doesn't match their rules, to the GUI and the alerts.
+func onChecksumQuarantine(quarantined, failed *rule.Rule, con *conman.Connection) {
+	path := con.Process.Path
+	msg := i18n.New(i18n.ChecksumQuarantine, "path", path, "rule", failed.Name, "duration", quarantined.Duration)
+	con.Process.RLock()
+	fields := map[string]string{
+		"pid":  strconv.Itoa(con.Process.ID),
+		"path": path,
+		"rule": failed.Name,
+		"md5":  con.Process.Checksums[procmon.HashMD5],
+	}
+	con.Process.RUnlock()
+	alerts.Default.Send(alerts.EventChecksumQuarantine, path, i18n.New(i18n.ChecksumQuarantineTitle), msg, fields)
+	if uiClient != nil {
+		uiClient.PostAlert(protocol.Alert_WARNING, protocol.Alert_KERNEL_EVENT, protocol.Alert_SHOW_ALERT, protocol.Alert_HIGH, msg)
+	}
+}
+
+// reevaluateConnections closes the connections allowed by a rule whose
+// schedule has closed, if they're no longer allowed by the rules. The
+// connections not matched by any rule are evaluated with the default action,
+// because they can't be prompted anymore.
+func reevaluateConnections(closed *rule.Rule, cons []*conman.Connection) {
+	for _, con := range cons {
+		action := uiClient.DefaultActionFor(con)
+		if r := rules.FindFirstMatch(con); r != nil {
+			action = r.Action
+		}
+		if action.Allows() || isMonitoring() {
+			continue
+		}
+		log.Info("[scheduler] %s: closing connection %s", closed.Name, con)
+		netlink.KillSocket(con.Protocol, con.SrcIP, con.SrcPort, con.DstIP, con.DstPort)
+	}
+}
+
+// findFirstMatch returns the rule that matches the connection, from the cache of
+// verdicts if the same process has connected to the same destination recently.
+func findFirstMatch(con *conman.Connection) *rule.Rule {
+	if !rules.Cacheable() {
+		return rules.FindFirstMatch(con)
+	}
+	generation := rules.Generation()
+	if verdict, found := conman.Verdicts.Get(con, generation); found {
+		return verdict.(*rule.Rule)
+	}
 	r := rules.FindFirstMatch(con)
+	if r != nil {
+		conman.Verdicts.Add(con, generation, r)
+	}
+	return r
+}
+
+func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
+	r := findFirstMatch(con)
 	if r == nil {
 		// no rule matched
 		// Note that as soon as we set a verdict on a packet, the next packet in the netfilter queue
 		// will begin to be processed even if this function hasn't yet returned
 
+		// the connections of some classes of protocols (multicast, ...)
+		// are not prompted.
+		if action, found := uiClient.ProtocolDefault(con); found {
+			log.Debug("Applying the default action of the protocol (%s) on %s", action, con)
+			applyDefaultAction(packet, con)
+			return nil
+		}
+		// nor the connections of the binaries of some origins (packaged, ...)
+		if action, found := uiClient.OriginDefault(con); found {
+			log.Debug("Applying the default action of the binary origin (%s) on %s", action, con)
+			applyDefaultAction(packet, con)
+			return nil
+		}
+
 		// send a request to the UI client if
-		// 1) connected and running and 2) we are not already asking
-		if uiClient.Connected() == false || uiClient.GetIsAsking() == true {
+		// 1) connected and running (or a tty prompt is configured) and 2) we are not already asking
+		if isMonitoring() || uiClient.CanAsk() == false || uiClient.GetIsAsking() == true {
 			applyDefaultAction(packet, con)
 			log.Debug("UI is not running or busy, connected: %v, running: %v", uiClient.Connected(), uiClient.GetIsAsking())
 			return nil
@@ -459,29 +1351,33 @@ func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
 		defer uiClient.SetIsAsking(false)
 
 		// In order not to block packet processing, we send our packet to a different netfilter queue
-		// and then immediately pull it back out of that queue
-		packet.SetRequeueVerdict(uint16(repeatQueueNum))
-
-		var o bool
-		var pkt netfilter.Packet
-		// don't wait for the packet longer than 1 sec
-		select {
-		case pkt, o = <-repeatPktChan:
-			if !o {
-				log.Debug("error while receiving packet from repeatPktChan")
+		// and then immediately pull it back out of that queue.
+		// The namespaces don't have a repeat queue, their packets wait for
+		// the verdict in the queue of the namespace.
+		if packet.NetNS == 0 {
+			packet.SetRequeueVerdict(uint16(repeatQueueNum))
+
+			var o bool
+			var pkt netfilter.Packet
+			// don't wait for the packet longer than 1 sec
+			select {
+			case pkt, o = <-repeatPktChan:
+				if !o {
+					log.Debug("error while receiving packet from repeatPktChan")
+					return nil
+				}
+			case <-time.After(1 * time.Second):
+				log.Debug("timed out while receiving packet from repeatPktChan")
 				return nil
 			}
-		case <-time.After(1 * time.Second):
-			log.Debug("timed out while receiving packet from repeatPktChan")
-			return nil
-		}
 
-		//check if the pulled out packet is the same we put in
-		if res := bytes.Compare(packet.Packet.Data(), pkt.Packet.Data()); res != 0 {
-			log.Error("The packet which was requeued has changed abruptly. This should never happen. Please report this incident to the Opensnitch developers. %v %v ", packet, pkt)
-			return nil
+			//check if the pulled out packet is the same we put in
+			if res := bytes.Compare(packet.Packet.Data(), pkt.Packet.Data()); res != 0 {
+				log.Error("The packet which was requeued has changed abruptly. This should never happen. Please report this incident to the Opensnitch developers. %v %v ", packet, pkt)
+				return nil
+			}
+			packet = &pkt
 		}
-		packet = &pkt
 
 		// Update the hostname again.
 		// This is required due to a race between the ebpf dns hook and the actual first packet beeing sent
@@ -489,7 +1385,9 @@ func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
 			con.DstHost = dns.HostOr(con.DstIP, con.DstHost)
 		}
 
+		done := packet.Asking()
 		r = uiClient.Ask(con)
+		done()
 		if r == nil {
 			log.Error("Invalid rule received, applying default action")
 			applyDefaultAction(packet, con)
@@ -498,7 +1396,7 @@ func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
 		ok := false
 		pers := ""
 		action := string(r.Action)
-		if r.Action == rule.Allow {
+		if r.Action.Allows() {
 			action = log.Green(action)
 		} else {
 			action = log.Red(action)
@@ -508,7 +1406,10 @@ func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
 		if r.Duration == rule.Always {
 			pers = "Saved"
 			// add to the loaded rules and persist on disk
-			if err := rules.Add(r, true); err != nil {
+			err := rules.Track(rule.SourcePrompt, "add rule", func() error {
+				return rules.Add(r, true)
+			})
+			if err != nil {
 				log.Error("Error while saving rule: %s", err)
 			} else {
 				ok = true
@@ -532,14 +1433,38 @@ func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
 		log.Debug("Packet nil after processing rules")
 		return r
 	}
+	applyVerdict(packet, con, r)
 
+	return r
+}
+
+// applyVerdict sets the verdict of the rule that matched a connection.
+func applyVerdict(packet *netfilter.Packet, con *conman.Connection, r *rule.Rule) {
+	if r.Enabled && isMonitoring() {
+		acceptMonitored(packet, con, r.Action, r.Name)
+		if r.Action == rule.Audit {
+			loggerMgr.Audit(con.Serialize(), string(r.Action), r.Name)
+		}
+		return
+	}
 	if r.Enabled == false {
 		applyDefaultAction(packet, con)
 		ruleName := log.Green(r.Name)
-		log.Info("DISABLED (%s) %s %s -> %s:%d (%s)", uiClient.DefaultAction(), log.Bold(log.Green("✔")), log.Bold(con.Process.Path), log.Bold(con.To()), con.DstPort, ruleName)
-
-	} else if r.Action == rule.Allow {
-		packet.SetVerdictAndMark(netfilter.NF_ACCEPT, packet.Mark)
+		log.Info("DISABLED (%s) %s %s -> %s:%d (%s)", uiClient.DefaultActionFor(con), log.Bold(log.Green("✔")), log.Bold(con.Process.Path), log.Bold(con.To()), con.DstPort, ruleName)
+
+	} else if r.Action.Allows() {
+		if r.Action != rule.Proxy {
+			packet.SetVerdictAndMark(netfilter.NF_ACCEPT, packet.Mark)
+		} else if forwarder.Default.Redirect(con, r.Proxy) {
+			// sent through the upstream proxy of the rule.
+			packet.SetVerdictAndMark(netfilter.NF_ACCEPT, firewall.ProxyMark)
+		} else {
+			// not let through directly if it can't be proxied.
+			packet.SetVerdict(netfilter.NF_DROP)
+		}
+		if r.Action == rule.Audit {
+			loggerMgr.Audit(con.Serialize(), string(r.Action), r.Name)
+		}
 		ruleName := log.Green(r.Name)
 		if r.Operator.Operand == rule.OpTrue {
 			ruleName = log.Dim(r.Name)
@@ -547,14 +1472,225 @@ func acceptOrDeny(packet *netfilter.Packet, con *conman.Connection) *rule.Rule {
 		log.Debug("%s %s -> %d:%s => %s:%d, mark: %x (%s)", log.Bold(log.Green("✔")), log.Bold(con.Process.Path), con.SrcPort, log.Bold(con.SrcIP.String()), log.Bold(con.To()), con.DstPort, packet.Mark, ruleName)
 	} else {
 		if r.Action == rule.Reject {
-			netlink.KillSocket(con.Protocol, con.SrcIP, con.SrcPort, con.DstIP, con.DstPort)
+			rejectConnection(packet, con)
+		} else if denypage.Default.Redirect(con, r.Name) {
+			// answered by the deny page instead of timing out.
+			packet.SetVerdictAndMark(netfilter.NF_ACCEPT, firewall.DenyPageMark)
+		} else {
+			packet.SetVerdict(netfilter.NF_DROP)
 		}
-		packet.SetVerdict(netfilter.NF_DROP)
 
 		log.Debug("%s %s -> %d:%s => %s:%d, mark: %x (%s)", log.Bold(log.Red("✘")), log.Bold(con.Process.Path), con.SrcPort, log.Bold(con.SrcIP.String()), log.Bold(con.To()), con.DstPort, packet.Mark, log.Red(r.Name))
 	}
+}
 
-	return r
+// runReplay evaluates the connections of a capture file against the rules
+// (-rules-path if specified), prints the connections that would have been
+// treated differently, or how long it took to evaluate them
+// (-replay-benchmark), and exits.
+func runReplay(cfg *config.Config) {
+	path := cfg.Rules.Path
+	if rulesPath != "" {
+		path = rulesPath
+	}
+	log.Info("Replaying %s against the rules of %s ...", replayFile, path)
+
+	loader, err := rule.NewLoader(false)
+	if err != nil {
+		log.Fatal("%s", err)
+	}
+	loader.EnableChecksums(cfg.Rules.EnableChecksums)
+	if err := loader.Load(path); err != nil {
+		log.Fatal("Error loading rules path %s: %s", path, err)
+	}
+
+	if replayRounds > 0 {
+		report, err := replay.Benchmark(replayFile, loader, replayRounds)
+		if err != nil {
+			log.Fatal("Error replaying %s: %s", replayFile, err)
+		}
+		report.Print(os.Stdout)
+		os.Exit(0)
+	}
+
+	report, err := replay.Run(replayFile, loader, replayDefaultAction(cfg))
+	if err != nil {
+		log.Fatal("Error replaying %s: %s", replayFile, err)
+	}
+	report.Print(os.Stdout)
+	os.Exit(0)
+}
+
+// replayDefaultAction returns the action applied to the connections replayed
+// that don't match any rule, resolved from the configuration as the daemon
+// does: by the class of the protocol, the origin of the binary, the prompt
+// policy of the destination, or the default action.
+func replayDefaultAction(cfg *config.Config) replay.DefaultActionFunc {
+	protocols, err := prompt.NewProtocolDefaults(cfg.ProtocolDefaults)
+	if err != nil {
+		log.Warning("%s", err)
+	}
+	origins, err := prompt.NewOriginDefaults(cfg.OriginDefaults)
+	if err != nil {
+		log.Warning("%s", err)
+	}
+	policies, err := prompt.NewPolicies(cfg.Prompt.Policies)
+	if err != nil {
+		log.Warning("%s", err)
+	}
+	return func(con *conman.Connection) rule.Action {
+		if action, found := protocols.For(con); found {
+			return action
+		}
+		if action, found := origins.For(con); found {
+			return action
+		}
+		if _, action, found := policies.For(con.DstIP); found {
+			return action
+		}
+		return rule.Action(cfg.DefaultAction)
+	}
+}
+
+// runBulkRules exports the rules to a single file, or imports the rules of a
+// file to the rules path, and exits.
+// A running daemon reloads the rules imported automatically, unless live
+// reload is disabled.
+func runBulkRules(cfg *config.Config) {
+	path := cfg.Rules.Path
+	if rulesPath != "" {
+		path = rulesPath
+	}
+	loader, err := rule.NewLoader(false)
+	if err != nil {
+		log.Fatal("%s", err)
+	}
+	if err := loader.Load(path); err != nil {
+		log.Fatal("Error loading rules path %s: %s", path, err)
+	}
+
+	if exportRulesFile != "" {
+		format := rulesFormat
+		if format == "" {
+			format = rule.BulkFormat(exportRulesFile)
+		}
+		out := os.Stdout
+		if exportRulesFile != "-" {
+			if out, err = os.OpenFile(exportRulesFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600); err != nil {
+				log.Fatal("Error exporting rules: %s", err)
+			}
+		}
+		if err := loader.Export(out, format); err != nil {
+			log.Fatal("Error exporting rules to %s: %s", exportRulesFile, err)
+		}
+		out.Close()
+		log.Info("%d rules exported to %s", loader.NumRules(), exportRulesFile)
+		os.Exit(0)
+	}
+
+	format := rulesFormat
+	if format == "" {
+		format = rule.BulkFormat(importRulesFile)
+	}
+	f, err := os.Open(importRulesFile)
+	if err != nil {
+		log.Fatal("Error importing rules: %s", err)
+	}
+	imported, err := rule.ReadRules(f, format)
+	f.Close()
+	if err != nil {
+		log.Fatal("Error importing rules from %s: %s", importRulesFile, err)
+	}
+	rule.SetAllowImportedHooks(cfg.Rules.AllowImportedHooks)
+	result, err := loader.Import(imported, importConflict)
+	if err != nil {
+		log.Fatal("Error importing rules from %s: %s", importRulesFile, err)
+	}
+	fmt.Printf("added: %v\nreplaced: %v\nrenamed: %v\nskipped: %v\n", result.Added, result.Replaced, result.Renamed, result.Skipped)
+	for name, err := range result.Errors {
+		fmt.Printf("error: %s: %s\n", name, err)
+	}
+	os.Exit(0)
+}
+
+// runGenerateProfile proposes a minimal set of rules from the connections
+// established on the system, and writes it to a file to review it.
+// After confirmation, the rules are saved to the rules path and the default
+// action is set to deny.
+func runGenerateProfile(cfg *config.Config) {
+	conns, err := profile.Observe()
+	if err != nil {
+		log.Fatal("Error generating profile: %s", err)
+	}
+	proposed := profile.Generate(conns, profile.Nameservers())
+
+	format := rulesFormat
+	if format == "" {
+		format = rule.BulkFormat(profileFile)
+	}
+	out, err := os.OpenFile(profileFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Fatal("Error generating profile: %s", err)
+	}
+	err = rule.WriteRules(out, proposed, format)
+	out.Close()
+	if err != nil {
+		log.Fatal("Error writing profile to %s: %s", profileFile, err)
+	}
+
+	fmt.Printf("%d connections observed, %d rules proposed:\n\n", len(conns), len(proposed))
+	for _, r := range proposed {
+		fmt.Printf("  %s: %s\n", r.Name, r.Description)
+		for i := range r.Operator.List {
+			op := &r.Operator.List[i]
+			fmt.Printf("    %s %s %s\n", op.Operand, op.Type, op.Data)
+		}
+	}
+	fmt.Printf("\nThe rules have been written to %s.\n", profileFile)
+	fmt.Printf("Apply them, and deny any other connection (default action: deny)? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		fmt.Println("Profile not applied. Review it and import it later with -import-rules.")
+		os.Exit(0)
+	}
+
+	path := cfg.Rules.Path
+	if rulesPath != "" {
+		path = rulesPath
+	}
+	loader, err := rule.NewLoader(false)
+	if err != nil {
+		log.Fatal("%s", err)
+	}
+	if err := loader.Load(path); err != nil {
+		log.Fatal("Error loading rules path %s: %s", path, err)
+	}
+	result, err := loader.Import(proposed, importConflict)
+	if err != nil {
+		log.Fatal("Error applying profile: %s", err)
+	}
+	for name, err := range result.Errors {
+		log.Fatal("Error applying profile, rule %s: %s", name, err)
+	}
+
+	raw, err := config.Load(configFile)
+	if err != nil {
+		log.Fatal("Error applying profile: %s", err)
+	}
+	newConfig, err := config.Parse(raw)
+	if err != nil {
+		log.Fatal("Error applying profile: %s", err)
+	}
+	newConfig.DefaultAction = string(rule.Deny)
+	if raw, err = json.MarshalIndent(newConfig, "", "    "); err != nil {
+		log.Fatal("Error applying profile: %s", err)
+	}
+	if err := config.Save(configFile, string(raw)); err != nil {
+		log.Fatal("Error applying profile: %s", err)
+	}
+	fmt.Printf("Profile applied: %d rules added to %s, default action set to deny in %s\n",
+		len(result.Added)+len(result.Replaced)+len(result.Renamed), path, configFile)
+	os.Exit(0)
 }
 
 func main() {
@@ -570,12 +1706,17 @@ func main() {
 		core.CheckSysRequirements()
 		os.Exit(0)
 	}
+	if showFeatures {
+		fmt.Print(features.Probe())
+		os.Exit(0)
+	}
 
 	setupLogging()
 	setupProfiling()
 	setupSignals()
 
 	log.Important("Starting %s v%s", core.Name, core.Version)
+	go probeFeatures()
 
 	err := rule.LoadAliases(aliasFile)
 	if err != nil {
@@ -583,6 +1724,23 @@ func main() {
 	}
 	log.Info("Loading network aliases from %s ...", aliasFile)
 
+	if handover, err = upgrade.Inherit(); err != nil {
+		log.Warning("[upgrade] %s", err)
+	} else if handover != nil {
+		log.Important("[upgrade] started by a previous instance, taking over ...")
+		if err := ebpf.InheritMaps(handover.Files(upgrade.FileEbpfMap)); err != nil {
+			log.Warning("[upgrade] %s", err)
+		}
+		firewall.KeepRules(true)
+	}
+
+	if err := core.LoadStorageKey(storageKey); err != nil {
+		log.Fatal("%s", err)
+	}
+	if unsealFile != "" {
+		runUnsealFile()
+	}
+
 	cfg, err := loadDiskConfiguration()
 	if err != nil {
 		log.Fatal("%s", err)
@@ -591,6 +1749,16 @@ func main() {
 	if cfg.Rules.Path == "" {
 		cfg.Rules.Path = rule.DefaultPath
 	}
+	if replayFile != "" {
+		runReplay(cfg)
+	}
+	if exportRulesFile != "" || importRulesFile != "" {
+		runBulkRules(cfg)
+	}
+	if profileFile != "" {
+		runGenerateProfile(cfg)
+	}
+	sealPlaintextFiles(cfg.Rules.Path)
 	log.Info("Loading rules from %s ...", cfg.Rules.Path)
 	rules, err = rule.NewLoader(!noLiveReload)
 	if err != nil {
@@ -599,7 +1767,38 @@ func main() {
 	stats = statistics.New(rules)
 	loggerMgr = loggers.NewLoggerManager()
 	stats.SetLoggers(loggerMgr)
+	policyaudit.Default.SetSources(rules, stats)
+	policyaudit.Default.OnReport(onPolicyAudit)
+	updater.Default.OnUpdate(onUpdateAvailable)
+	listeners.Default.SetRules(rules)
+	listeners.Default.OnEvent(onListenerEvent)
+	rulesync.Default.SetLoader(rules)
+	setupQueuesWatchdog()
+	firewall.OnRulesMissing(onFirewallWiped)
+	procmon.OnProcessExit(conman.Verdicts.DeleteProcess)
+	conman.Accounting.OnFlowEnd(onFlowEnd)
+	conman.Accounting.OnFlowUsage(stats.OnFlowUsage)
+	rules.OnNarrowedRule(func(suggested *rule.Rule) {
+		uiClient.PostAlert(protocol.Alert_INFO, protocol.Alert_RULE_SUGGESTION, protocol.Alert_SHOW_ALERT, protocol.Alert_LOW, suggested)
+	})
+	rules.OnScheduleClosed(reevaluateConnections)
+	rules.OnChecksumQuarantine(onChecksumQuarantine)
+
+	// the number of queues can't be changed without restarting the daemon,
+	// so it must be configured before the firewall is initialized.
+	qCount := cfg.FwOptions.QueueCount
+	if queueCount > 0 {
+		qCount = uint16(queueCount)
+	}
+	if qCount == 0 {
+		qCount = 1
+	}
+	firewall.SetQueueCount(qCount)
+
 	uiClient = ui.NewClient(uiSocket, configFile, stats, rules, loggerMgr)
+	if handover != nil {
+		inheritState()
+	}
 
 	// default expected queue from the cli is 0. If it's greater than 0
 	// overwrite config value (which by default is also 0)
@@ -607,16 +1806,43 @@ func main() {
 	if uint16(queueNum) != cfg.FwOptions.QueueNum && queueNum > 0 {
 		qNum = uint16(queueNum)
 	}
-	log.Info("Using queue number %d ...", qNum)
+	log.Info("Using queue number %d (%d queues) ...", qNum, qCount)
+
+	if captureFile != "" {
+		log.Info("Writing intercepted connections to %s ...", captureFile)
+		if captureWriter, err = replay.NewWriter(captureFile); err != nil {
+			log.Fatal("Error opening capture file %s: %s", captureFile, err)
+		}
+	}
 
 	setupWorkers()
-	setupQueues(qNum)
+	setupQueues(qNum, qCount)
+	for i, q := range queues.Queues() {
+		name := "connections"
+		if i > 0 {
+			name = fmt.Sprint(name, "-", i)
+		}
+		stats.AddQueue(name, q.Num())
+		netfilter.Watchdog.AddQueue(q)
+	}
+	dnsQueueNum = cfg.FwOptions.DNSQueueNum
+	setupDNSQueue(qNum, qCount, dnsQueueNum)
+	if err := netns.Default.SetQueue(qNum, cfg.FwOptions.QueueBypass); err != nil {
+		log.Warning("[netns] %s", err)
+	}
 
 	// queue and firewall rules should be ready by now
 
 	uiClient.Connect()
 	listenToEvents()
 
+	if handover != nil {
+		if err := handover.Ready(); err != nil {
+			log.Warning("[upgrade] %s", err)
+		}
+		firewall.KeepRules(false)
+	}
+
 	// overwrite configuration options with the ones specified from the cli
 
 	if overwriteLogging() {
@@ -629,6 +1855,10 @@ func main() {
 	}
 	log.Info("Using system fw configuration %s ...", fwConfigFile)
 
+	if monitorMode {
+		log.Important("Monitor mode enabled, the verdicts of the rules won't be enforced")
+	}
+
 	if rulesPath != "" {
 		log.Info("Reloading rules from %s ...", rulesPath)
 		if err := rules.Reload(rulesPath); err != nil {
@@ -641,7 +1871,7 @@ func main() {
 	if procmonMethod != "" || (ebpfModPath != "" && ebpfModPath != cfg.Ebpf.ModulesPath) {
 		log.Info("Reloading proc monitor (%s) (ebpf mods path: %s)...", procmonMethod, cfg.Ebpf.ModulesPath)
 		if err := monitor.ReconfigureMonitorMethod(procmonMethod, cfg.Ebpf, cfg.Audit); err != nil {
-			msg := fmt.Sprintf("Unable to set process monitor method via parameter: %v", err)
+			msg := i18n.New(i18n.ProcMonitorError, "error", err)
 			uiClient.SendWarningAlert(msg)
 			log.Warning("%s", msg)
 		}
@@ -649,7 +1879,7 @@ func main() {
 
 	go func(uiClient *ui.Client, ebpfPath string) {
 		if err := dns.ListenerEbpf(ebpfPath); err != nil {
-			msg := fmt.Sprintf("EBPF-DNS: Unable to attach ebpf listener: %s", err)
+			msg := i18n.New(i18n.EbpfDNSError, "error", err)
 			log.Warning("%s", msg)
 			// don't display an alert, since this module is not critical
 			uiClient.PostAlert(
@@ -663,6 +1893,7 @@ func main() {
 	}(uiClient, cfg.Ebpf.ModulesPath)
 
 	initSystemdResolvedMonitor()
+	go watchKernelFlows(ctx)
 
 	log.Info("Running on netfilter queue #%d ...", queueNum)
 	for {
@@ -674,10 +1905,12 @@ func main() {
 				goto Exit
 			}
 			wrkChan <- pkt
+		case pkt := <-netns.Default.Packets():
+			wrkChan <- pkt
 		}
 	}
 Exit:
 	close(wrkChan)
-	doCleanup(queue, repeatQueue)
+	doCleanup(queues, repeatQueue)
 	os.Exit(0)
 }
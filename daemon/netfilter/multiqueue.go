@@ -0,0 +1,96 @@
+package netfilter
+
+import (
+	"fmt"
+	"sync"
+)
+
+// QueueGroup receives the packets of a range of queues.
+//
+// The firewall balances the connections between the queues of the range
+// (fanout), and every queue is read by its own goroutine, so the packets are
+// not processed by a single core. The packets of all the queues are delivered
+// on the same channel, to the pool of workers that set the verdicts.
+type QueueGroup struct {
+	queues  []*Queue
+	packets chan Packet
+	wg      sync.WaitGroup
+}
+
+// NewQueueGroup opens the queues first to first+count-1.
+func NewQueueGroup(first, count uint16) (*QueueGroup, error) {
+	if count == 0 {
+		count = 1
+	}
+	if uint32(first)+uint32(count) > 65535 {
+		return nil, fmt.Errorf("invalid queues range: %d-%d", first, uint32(first)+uint32(count)-1)
+	}
+	queues := make([]*Queue, 0, count)
+	for i := uint16(0); i < count; i++ {
+		q, err := NewQueue(first + i)
+		if err != nil {
+			for _, q := range queues {
+				q.Close()
+			}
+			return nil, fmt.Errorf("queue #%d: %s", first+i, err)
+		}
+		queues = append(queues, q)
+	}
+	return NewQueueGroupFrom(queues...), nil
+}
+
+// NewQueueGroupFrom groups queues already opened, i.e.: inherited from a
+// previous instance of the daemon.
+func NewQueueGroupFrom(queues ...*Queue) *QueueGroup {
+	g := &QueueGroup{
+		queues:  queues,
+		packets: make(chan Packet),
+	}
+	for _, q := range queues {
+		g.wg.Add(1)
+		go g.dispatch(q)
+	}
+	go func() {
+		g.wg.Wait()
+		close(g.packets)
+	}()
+	return g
+}
+
+func (g *QueueGroup) dispatch(q *Queue) {
+	defer g.wg.Done()
+	for pkt := range q.Packets() {
+		g.packets <- pkt
+	}
+}
+
+// Packets returns the packets of all the queues of the group.
+// The channel is closed once all the queues are closed.
+func (g *QueueGroup) Packets() <-chan Packet {
+	return g.packets
+}
+
+// Queues returns the queues of the group, ordered by number.
+func (g *QueueGroup) Queues() []*Queue {
+	return g.queues
+}
+
+// Num returns the number of the first queue of the group.
+func (g *QueueGroup) Num() uint16 {
+	if len(g.queues) == 0 {
+		return 0
+	}
+	return g.queues[0].Num()
+}
+
+// Len returns the number of queues of the group.
+func (g *QueueGroup) Len() int {
+	return len(g.queues)
+}
+
+// Close closes all the queues of the group.
+func (g *QueueGroup) Close() {
+	for _, q := range g.queues {
+		q.Close()
+	}
+}
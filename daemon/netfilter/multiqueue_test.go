@@ -0,0 +1,51 @@
+package netfilter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueGroup(t *testing.T) {
+	queues := []*Queue{
+		{num: 10, packets: make(chan Packet)},
+		{num: 11, packets: make(chan Packet)},
+	}
+	g := NewQueueGroupFrom(queues...)
+	if g.Num() != 10 || g.Len() != 2 {
+		t.Errorf("invalid group, first queue: %d, queues: %d", g.Num(), g.Len())
+	}
+
+	for _, q := range queues {
+		go func(q *Queue) {
+			q.packets <- Packet{Mark: uint32(q.num)}
+		}(q)
+	}
+	received := make(map[uint32]bool)
+	for i := 0; i < len(queues); i++ {
+		select {
+		case pkt := <-g.Packets():
+			received[pkt.Mark] = true
+		case <-time.After(time.Second):
+			t.Fatal("packet not received")
+		}
+	}
+	if !received[10] || !received[11] {
+		t.Errorf("the packets of every queue should be received: %v", received)
+	}
+
+	close(queues[0].packets)
+	select {
+	case <-g.Packets():
+		t.Error("the channel should be open while any queue is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(queues[1].packets)
+	select {
+	case _, ok := <-g.Packets():
+		if ok {
+			t.Error("unexpected packet received")
+		}
+	case <-time.After(time.Second):
+		t.Error("the channel should be closed once all the queues are closed")
+	}
+}
@@ -26,13 +26,49 @@ type Packet struct {
 	Packet          gopacket.Packet
 	Mark            uint32
 	verdictChannel  chan VerdictContainer
+	buf             *[]byte
 	UID             uint32
 	NetworkProtocol uint8
 	IfaceInIdx      int
 	IfaceOutIdx     int
+	// inode of the network namespace where the packet was queued, 0 for
+	// the one of the daemon. The interfaces are the ones of that namespace.
+	NetNS uint64
+	// queue the packet was read from.
+	queue *Queue
 }
 
-// SetVerdict emits a veredict on a packet
+// Asking marks the packet as waiting for the user to decide its verdict.
+// The queue is held until the verdict is set, so the watchdog doesn't consider
+// it stalled meanwhile. The function returned must be called once the verdict
+// is decided.
+func (p *Packet) Asking() (done func()) {
+	q := p.queue
+	if q == nil {
+		return func() {}
+	}
+	q.asking.Add(1)
+	return func() { q.asking.Add(-1) }
+}
+
+// Release returns the payload of the packet to the pool of buffers, to be
+// reused by the next packets queued.
+// It must be called at most once, only when the packet, the layers decoded
+// from it and the connections parsed from it (their IPs point to the
+// payload) are no longer used. The packets not released are collected by the
+// GC as usual.
+func (p *Packet) Release() {
+	if p.buf == nil {
+		return
+	}
+	buf := p.buf
+	p.buf = nil
+	p.Packet = nil
+	putBuffer(buf)
+}
+
+// SetVerdict emits a veredict on a packet.
+// The verdict of a packet must be set only once.
 func (p *Packet) SetVerdict(v Verdict) {
 	p.verdictChannel <- VerdictContainer{Verdict: v, Packet: nil, Mark: 0}
 }
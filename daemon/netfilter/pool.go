@@ -0,0 +1,69 @@
+package netfilter
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// The payloads of the packets and the channels of the verdicts are reused,
+// in order not to allocate them for every packet queued.
+var (
+	buffersPool = sync.Pool{
+		New: func() interface{} {
+			buffersAllocated.Add(1)
+			b := make([]byte, 0, NF_DEFAULT_PACKET_SIZE)
+			return &b
+		},
+	}
+	verdictChannelsPool = sync.Pool{
+		New: func() interface{} {
+			return make(chan VerdictContainer)
+		},
+	}
+
+	// buffers allocated by the pool, and buffers returned to the pool.
+	buffersAllocated atomic.Uint64
+	buffersReleased  atomic.Uint64
+)
+
+// PoolCounters holds the counters of the buffers of the packets.
+type PoolCounters struct {
+	// buffers allocated, because there was none available to reuse.
+	Allocated uint64
+	// buffers returned to the pool, to be reused.
+	Released uint64
+}
+
+// GetPoolCounters returns the counters of the buffers of the packets.
+// Without the pool, a buffer would be allocated for every packet.
+func GetPoolCounters() PoolCounters {
+	return PoolCounters{
+		Allocated: buffersAllocated.Load(),
+		Released:  buffersReleased.Load(),
+	}
+}
+
+func getBuffer(size int) *[]byte {
+	buf := buffersPool.Get().(*[]byte)
+	if cap(*buf) < size {
+		*buf = make([]byte, 0, size)
+	}
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	// don't keep the buffers of unusually big packets.
+	if cap(*buf) > int(NF_DEFAULT_PACKET_SIZE) {
+		return
+	}
+	buffersReleased.Add(1)
+	buffersPool.Put(buf)
+}
+
+func getVerdictChannel() chan VerdictContainer {
+	return verdictChannelsPool.Get().(chan VerdictContainer)
+}
+
+func putVerdictChannel(ch chan VerdictContainer) {
+	verdictChannelsPool.Put(ch)
+}
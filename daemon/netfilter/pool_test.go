@@ -0,0 +1,53 @@
+package netfilter
+
+import (
+	"testing"
+)
+
+func TestPacketsPool(t *testing.T) {
+	before := GetPoolCounters()
+
+	buf := getBuffer(100)
+	if cap(*buf) < 100 {
+		t.Fatal("invalid buffer capacity:", cap(*buf))
+	}
+	*buf = append((*buf)[:0], 0x45, 0x00)
+	p := Packet{buf: buf}
+	p.Release()
+	p.Release()
+	if p.buf != nil || p.Packet != nil {
+		t.Error("the packet should not reference the buffer after releasing it")
+	}
+	if released := GetPoolCounters().Released - before.Released; released != 1 {
+		t.Error("the buffer should be released once, released:", released)
+	}
+
+	// the buffers of big packets are not kept.
+	big := getBuffer(int(NF_DEFAULT_PACKET_SIZE) * 2)
+	if cap(*big) < int(NF_DEFAULT_PACKET_SIZE)*2 {
+		t.Fatal("invalid buffer capacity:", cap(*big))
+	}
+	p = Packet{buf: big}
+	p.Release()
+	if released := GetPoolCounters().Released - before.Released; released != 1 {
+		t.Error("big buffers should not be returned to the pool, released:", released)
+	}
+
+	// packets not read from a queue
+	p = Packet{}
+	p.Release()
+}
+
+func TestQueueCountersSyscalls(t *testing.T) {
+	c := QueueCounters{
+		Verdicts:    100,
+		Timeouts:    2,
+		RecvCalls:   20,
+		VerdictMsgs: 30,
+		Batched:     80,
+	}
+	batched, unbatched := c.Syscalls()
+	if batched != 50 || unbatched != 204 {
+		t.Error("invalid syscalls:", batched, unbatched)
+	}
+}
@@ -2,7 +2,7 @@ package netfilter
 
 /*
 #cgo pkg-config: libnetfilter_queue
-#cgo CFLAGS: -I/usr/include
+#cgo CFLAGS: -I/usr/include -D_GNU_SOURCE
 #cgo LDFLAGS: -L/usr/lib64/ -ldl
 
 #include "queue.h"
@@ -12,7 +12,9 @@ import "C"
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -39,10 +41,14 @@ const (
 )
 
 var (
-	queueIndex     = make(map[uint32]*chan Packet, 0)
+	queueIndex     = make(map[uint32]*Queue, 0)
 	queueIndexLock = sync.RWMutex{}
 
 	gopacketDecodeOptions = gopacket.DecodeOptions{Lazy: true, NoCopy: true}
+
+	// closed when the packets waiting for a verdict while handing over the
+	// queues must get the verdict of the fail policy.
+	handOverTimeout = make(chan struct{})
 )
 
 // VerdictContainerC is the struct that contains the mark, action, length and
@@ -58,14 +64,53 @@ type Queue struct {
 	h       *C.struct_nfq_handle
 	qh      *C.struct_nfq_q_handle
 	packets chan Packet
-	fd      C.int
-	idx     uint32
+	// socket inherited from a previous instance of the daemon.
+	file *os.File
+	fd   C.int
+	idx  uint32
+	num  uint16
+	// verdicts pending to be sent, and counters of the syscalls. It's not
+	// freed, the loop reading the packets may still be using it after Close().
+	state *C.queueState
+	// inode of the network namespace of the queue, 0 for the one of the
+	// daemon. Closed once the loop reading the packets exits.
+	netns uint64
+	done  chan struct{}
+
+	// packets with a verdict, and packets not delivered to the daemon in time.
+	verdicts atomic.Uint64
+	timeouts atomic.Uint64
+	// packets waiting for the user to decide their verdict.
+	asking atomic.Int64
+}
+
+// QueueCounters holds the counters of the packets of a queue, and of the
+// syscalls used to read them and to set their verdicts.
+// Without batching, every packet needs one syscall to read it, and another
+// one to set its verdict.
+type QueueCounters struct {
+	// packets with a verdict, and packets not delivered to the daemon in time.
+	Verdicts uint64
+	Timeouts uint64
+	// syscalls to read the packets.
+	RecvCalls uint64
+	// syscalls to set the verdicts.
+	VerdictMsgs uint64
+	// packets whose verdict was sent along with the ones of other packets.
+	Batched uint64
+}
+
+// Syscalls returns the number of syscalls used to read the packets and to set
+// their verdicts, and the number of syscalls needed without batching.
+func (c QueueCounters) Syscalls() (batched, unbatched uint64) {
+	return c.RecvCalls + c.VerdictMsgs, 2 * (c.Verdicts + c.Timeouts)
 }
 
 // NewQueue opens a new netfilter queue to receive packets marked with a mark.
 func NewQueue(queueID uint16) (q *Queue, err error) {
 	q = &Queue{
 		idx:     uint32(time.Now().UnixNano()),
+		num:     queueID,
 		packets: make(chan Packet),
 	}
 
@@ -80,9 +125,105 @@ func NewQueue(queueID uint16) (q *Queue, err error) {
 	return q, nil
 }
 
+// NewQueueInNetNS opens a new netfilter queue in a network namespace, to
+// receive the packets queued by the rules of that namespace.
+// The queue is bound from a thread switched to the namespace, which is
+// discarded if it can't be switched back to the namespace of the daemon.
+func NewQueueInNetNS(queueID uint16, ns *os.File) (q *Queue, err error) {
+	var st unix.Stat_t
+	if err = unix.Fstat(int(ns.Fd()), &st); err != nil {
+		return nil, fmt.Errorf("Invalid network namespace %s: %s", ns.Name(), err)
+	}
+	q = &Queue{
+		idx:     uint32(time.Now().UnixNano()),
+		num:     queueID,
+		packets: make(chan Packet),
+		netns:   st.Ino,
+		done:    make(chan struct{}),
+	}
+
+	errc := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		orig, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errc <- err
+			return
+		}
+		defer orig.Close()
+		if err = unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errc <- fmt.Errorf("Unable to enter the network namespace %s: %s", ns.Name(), err)
+			return
+		}
+		if err = q.create(queueID); err == nil {
+			err = q.setup()
+		}
+		if errns := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); errns != nil {
+			log.Error("Unable to restore the network namespace of the thread: %s", errns)
+		} else {
+			runtime.UnlockOSThread()
+		}
+		errc <- err
+	}()
+	if err = <-errc; err != nil {
+		return nil, err
+	}
+
+	go q.run()
+
+	return q, nil
+}
+
+// NewQueueFromFile receives the packets of a queue already bound to the given
+// netlink socket, usually opened by a previous instance of the daemon.
+// Using the same socket allows to keep intercepting packets while the daemon
+// is upgraded, without binding the queue again.
+func NewQueueFromFile(queueID uint16, f *os.File) (q *Queue, err error) {
+	if f == nil {
+		return nil, fmt.Errorf("Invalid queue file-descriptor")
+	}
+	q = &Queue{
+		idx:     uint32(time.Now().UnixNano()),
+		num:     queueID,
+		packets: make(chan Packet),
+		file:    f,
+		fd:      C.int(f.Fd()),
+	}
+	if q.state = C.NewQueueState(C.uint32_t(q.idx), C.uint16_t(queueID), q.fd); q.state == nil {
+		return nil, fmt.Errorf("Unable to allocate queue state")
+	}
+	// the previous instance may not have set it.
+	if C.set_recv_timeout(q.fd, 1) < 0 {
+		return nil, fmt.Errorf("Unable to set the receive timeout of the queue")
+	}
+
+	queueIndexLock.Lock()
+	queueIndex[q.idx] = q
+	queueIndexLock.Unlock()
+
+	go q.runAdopted()
+
+	return q, nil
+}
+
+// File returns a duplicate of the netlink socket of the queue, to hand it
+// over to a new instance of the daemon.
+func (q *Queue) File(name string) (*os.File, error) {
+	fd, err := unix.FcntlInt(uintptr(q.fd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to duplicate queue file-descriptor: %s", err)
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
+
 func (q *Queue) create(queueID uint16) (err error) {
 	var ret C.int
 
+	if q.state = C.NewQueueState(C.uint32_t(q.idx), C.uint16_t(queueID), -1); q.state == nil {
+		return fmt.Errorf("Unable to allocate queue state")
+	}
 	if q.h, err = C.nfq_open(); err != nil {
 		return fmt.Errorf("Error opening Queue handle: %v", err)
 	} else if ret, err = C.nfq_unbind_pf(q.h, AF_INET); err != nil || ret < 0 {
@@ -97,13 +238,13 @@ func (q *Queue) create(queueID uint16) (err error) {
 		return fmt.Errorf("Error (%d) binding to AF_INET protocol family: %v", ret, err)
 	} else if ret, err := C.nfq_bind_pf(q.h, AF_INET6); err != nil || ret < 0 {
 		return fmt.Errorf("Error (%d) binding to AF_INET6 protocol family: %v", ret, err)
-	} else if q.qh, err = C.CreateQueue(q.h, C.uint16_t(queueID), C.uint32_t(q.idx)); err != nil || q.qh == nil {
+	} else if q.qh, err = C.CreateQueue(q.h, C.uint16_t(queueID), q.state); err != nil || q.qh == nil {
 		q.destroy()
 		return fmt.Errorf("Error binding to queue: %v", err)
 	}
 
 	queueIndexLock.Lock()
-	queueIndex[q.idx] = &q.packets
+	queueIndex[q.idx] = q
 	queueIndexLock.Unlock()
 
 	return nil
@@ -128,13 +269,25 @@ func (q *Queue) setup() (err error) {
 	} else if C.nfnl_rcvbufsiz(C.nfq_nfnlh(q.h), totSize) < 0 {
 		q.destroy()
 		return fmt.Errorf("Unable to increase netfilter buffer space size")
+	} else if C.set_recv_timeout(q.fd, 1) < 0 {
+		q.destroy()
+		return fmt.Errorf("Unable to set the receive timeout of the queue")
 	}
 
 	return nil
 }
 
 func (q *Queue) run() {
-	if errno := C.Run(q.h, q.fd); errno != 0 {
+	if errno := C.Run(q.h, q.fd, q.state); errno != 0 {
+		fmt.Fprintf(os.Stderr, "Terminating, unable to receive packet due to errno=%d", errno)
+	}
+	if q.done != nil {
+		close(q.done)
+	}
+}
+
+func (q *Queue) runAdopted() {
+	if errno := C.RunAdopted(q.fd, q.state); errno != 0 {
 		fmt.Fprintf(os.Stderr, "Terminating, unable to receive packet due to errno=%d", errno)
 	}
 }
@@ -153,7 +306,68 @@ func (q *Queue) Close() {
 	close(q.packets)
 }
 
+// Detach closes only this queue, leaving the rest of queues of the daemon
+// running. Only the queues opened with NewQueueInNetNS can be detached, the
+// rest are closed with Close().
+func (q *Queue) Detach() {
+	if q.done == nil {
+		q.Close()
+		return
+	}
+	C.stop_queue(q.state)
+	// the loop wakes up every second, at most.
+	<-q.done
+	q.release()
+	queueIndexLock.Lock()
+	delete(queueIndex, q.idx)
+	queueIndexLock.Unlock()
+	close(q.packets)
+}
+
+// HandOver stops reading packets from the queues, once a new instance of the
+// daemon reads them from the same sockets, and waits up to timeout for the
+// packets already read by this instance to get a verdict. The packets still
+// waiting for a verdict after the timeout get the verdict of the fail policy,
+// otherwise they'd be kept in the queue forever after exiting.
+func HandOver(timeout time.Duration) {
+	C.start_handover()
+	if !waitForLoops(timeout) {
+		log.Warning("[upgrade] packets without a verdict after %s, applying the fail policy", timeout)
+		close(handOverTimeout)
+		waitForLoops(2 * time.Second)
+	}
+}
+
+// waitForLoops waits until the loops reading the packets of the queues exit.
+// They wake up every second at most.
+func waitForLoops(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		busy := false
+		queueIndexLock.RLock()
+		for _, q := range queueIndex {
+			if q.state != nil && C.queue_running(q.state) != 0 {
+				busy = true
+				break
+			}
+		}
+		queueIndexLock.RUnlock()
+		if !busy {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func (q *Queue) destroy() {
+	// the queues of the namespaces can fail without closing the daemon.
+	if q.done != nil {
+		q.release()
+		return
+	}
 	// we'll try to exit cleanly, but sometimes nfqueue gets stuck
 	time.AfterFunc(5*time.Second, func() {
 		log.Warning("queue (%d) stuck, closing by timeout", q.idx)
@@ -163,6 +377,11 @@ func (q *Queue) destroy() {
 		}
 		os.Exit(0)
 	})
+	q.release()
+}
+
+// release destroys the queue and closes the handles.
+func (q *Queue) release() {
 	if q.qh != nil {
 		if ret := C.nfq_destroy_queue(q.qh); ret != 0 {
 			log.Warning("Queue.destroy() idx=%d, nfq_destroy_queue() not closed: %d", q.idx, ret)
@@ -170,6 +389,9 @@ func (q *Queue) destroy() {
 	}
 
 	q.closeNfq()
+	if q.file != nil {
+		q.file.Close()
+	}
 }
 
 func (q *Queue) closeNfq() {
@@ -180,6 +402,25 @@ func (q *Queue) closeNfq() {
 	}
 }
 
+// Num returns the number of the queue.
+func (q *Queue) Num() uint16 {
+	return q.num
+}
+
+// Counters returns the counters of the packets and syscalls of the queue.
+func (q *Queue) Counters() QueueCounters {
+	c := QueueCounters{
+		Verdicts: q.verdicts.Load(),
+		Timeouts: q.timeouts.Load(),
+	}
+	if q.state != nil {
+		var recvCalls, verdictMsgs, batched C.uint64_t
+		C.get_counters(q.state, &recvCalls, &verdictMsgs, &batched)
+		c.RecvCalls, c.VerdictMsgs, c.Batched = uint64(recvCalls), uint64(verdictMsgs), uint64(batched)
+	}
+	return c
+}
+
 // Packets return the list of enqueued packets.
 func (q *Queue) Packets() <-chan Packet {
 	return q.packets
@@ -195,22 +436,31 @@ func go_callback(queueID C.int, data *C.uchar, length C.int, mark C.uint, idx ui
 	(*vc).length = 0
 
 	queueIndexLock.RLock()
-	queueChannel, found := queueIndex[idx]
+	q, found := queueIndex[idx]
 	queueIndexLock.RUnlock()
 	if !found {
 		fmt.Fprintf(os.Stderr, "Unexpected queue idx %d\n", idx)
 		return
 	}
 
-	xdata := C.GoBytes(unsafe.Pointer(data), length)
+	// the payload is copied to a buffer of the pool, returned by Release().
+	if length < 0 {
+		length = 0
+	}
+	buf := getBuffer(int(length))
+	xdata := append((*buf)[:0], unsafe.Slice((*byte)(unsafe.Pointer(data)), int(length))...)
+	*buf = xdata
 
 	p := Packet{
-		verdictChannel:  make(chan VerdictContainer),
+		verdictChannel:  getVerdictChannel(),
+		buf:             buf,
 		Mark:            uint32(mark),
 		UID:             uid,
 		NetworkProtocol: xdata[0] >> 4, // first 4 bits is the version
 		IfaceInIdx:      int(devIn),
 		IfaceOutIdx:     int(devOut),
+		NetNS:           q.netns,
+		queue:           q,
 	}
 
 	var packet gopacket.Packet
@@ -223,7 +473,7 @@ func go_callback(queueID C.int, data *C.uchar, length C.int, mark C.uint, idx ui
 	p.Packet = packet
 
 	select {
-	case *queueChannel <- p:
+	case q.packets <- p:
 		select {
 		case v := <-p.verdictChannel:
 			if v.Packet == nil {
@@ -238,9 +488,20 @@ func go_callback(queueID C.int, data *C.uchar, length C.int, mark C.uint, idx ui
 				(*vc).mark_set = C.uint(1)
 				(*vc).mark = C.uint(v.Mark)
 			}
+		case <-handOverTimeout:
+			// the channel is not reused, the verdict may still be sent.
+			(*vc).verdict = C.uint(failVerdict.Load())
+			q.timeouts.Add(1)
+			return
 		}
+		q.verdicts.Add(1)
+		putVerdictChannel(p.verdictChannel)
 
 	case <-time.After(1 * time.Millisecond):
+		(*vc).verdict = C.uint(failVerdict.Load())
+		q.timeouts.Add(1)
+		putVerdictChannel(p.verdictChannel)
+		p.Release()
 		fmt.Fprintf(os.Stderr, "Timed out while sending packet to queue channel %d\n", idx)
 	}
 }
@@ -3,6 +3,7 @@
 
 #include <stdio.h>
 #include <stdlib.h>
+#include <string.h>
 #include <stdint.h>
 #include <errno.h>
 #include <math.h>
@@ -10,6 +11,8 @@
 #include <dlfcn.h>
 #include <netinet/in.h>
 #include <linux/types.h>
+#include <sys/socket.h>
+#include <sys/time.h>
 #include <linux/socket.h>
 #include <linux/netfilter.h>
 #include <libnetfilter_queue/libnetfilter_queue.h>
@@ -22,11 +25,122 @@ typedef struct {
     unsigned char *data;
 } verdictContainer;
 
+// max number of packets read from the socket with a single syscall.
+#define NF_RECV_BATCH 16
+// size of the buffer of every packet read.
+#define NF_RECV_BUFFER_SIZE 8192
+
+// queueState holds the verdict pending to be sent of a run of consecutive
+// packets with the same verdict and mark, and the counters of the syscalls.
+//
+// The verdicts of the packets read with the same syscall are sent with a
+// single batch verdict (NFQNL_MSG_VERDICT_BATCH), which applies to all the
+// packets of the queue with an id lower or equal than the one of the verdict.
+// The packets are processed in order, so all the previous packets already
+// have a verdict.
+typedef struct {
+    uint32_t idx;
+    uint16_t queue;
+    int fd;
+    struct nfq_q_handle *qh;
+
+    uint32_t batch_id;
+    uint32_t batch_verdict;
+    uint32_t batch_mark;
+    uint32_t batch_len;
+
+    // counters, read from go with get_counters()
+    uint64_t recv_calls;
+    uint64_t verdict_msgs;
+    uint64_t batched;
+
+    // set to stop reading the packets of this queue only.
+    uint8_t stop;
+    // set while the loop reading the packets is running.
+    uint8_t running;
+} queueState;
+
 static void *get_uid = NULL;
 
 extern void go_callback(int id, unsigned char* data, int len, unsigned int mark, uint32_t idx, verdictContainer *vc, uint32_t uid, uint32_t in_dev, uint32_t out_dev);
 
 static uint8_t stop = 0;
+// set while handing over the queues to a new instance of the daemon. The loops
+// stop reading packets once the ones already read have a verdict.
+static uint8_t handover = 0;
+
+static int send_verdict(int fd, uint16_t queue, uint16_t type, uint32_t id, verdictContainer *vc);
+
+static inline queueState *NewQueueState(uint32_t idx, uint16_t queue, int fd) {
+    queueState *s = calloc(1, sizeof(queueState));
+    if (s != NULL) {
+        s->idx = idx;
+        s->queue = queue;
+        s->fd = fd;
+    }
+    return s;
+}
+
+static inline void get_counters(queueState *s, uint64_t *recv_calls, uint64_t *verdict_msgs, uint64_t *batched) {
+    *recv_calls = __atomic_load_n(&s->recv_calls, __ATOMIC_RELAXED);
+    *verdict_msgs = __atomic_load_n(&s->verdict_msgs, __ATOMIC_RELAXED);
+    *batched = __atomic_load_n(&s->batched, __ATOMIC_RELAXED);
+}
+
+// write_verdict sends the verdict of one packet (batch == 0), or of all the
+// packets up to id, using libnetfilter_queue if the queue was created by
+// this process.
+static inline int write_verdict(queueState *s, uint32_t id, verdictContainer *vc, int batch) {
+    __atomic_fetch_add(&s->verdict_msgs, 1, __ATOMIC_RELAXED);
+    if (s->qh != NULL) {
+        if (batch) {
+            return nfq_set_verdict_batch2(s->qh, id, vc->verdict, vc->mark);
+        }
+        return nfq_set_verdict2(s->qh, id, vc->verdict, vc->mark, vc->length, vc->data);
+    }
+    return send_verdict(s->fd, s->queue, batch ? NFQNL_MSG_VERDICT_BATCH : NFQNL_MSG_VERDICT, id, vc);
+}
+
+// flush_verdicts sends the verdict pending of the last run of packets.
+static inline int flush_verdicts(queueState *s) {
+    int ret = 0;
+    if (s->batch_len == 0) {
+        return 0;
+    }
+    verdictContainer vc = {0};
+    vc.verdict = s->batch_verdict;
+    vc.mark = s->batch_mark;
+    ret = write_verdict(s, s->batch_id, &vc, s->batch_len > 1);
+    if (s->batch_len > 1) {
+        __atomic_fetch_add(&s->batched, s->batch_len, __ATOMIC_RELAXED);
+    }
+    s->batch_len = 0;
+    return ret;
+}
+
+// set_verdict queues the verdict of a packet, to send it along with the ones
+// of the following packets. The verdicts that modify the packet, or that send
+// it to another queue, are sent immediately.
+static inline int set_verdict(queueState *s, uint32_t id, verdictContainer *vc) {
+    unsigned int verdict = vc->verdict & NF_VERDICT_MASK;
+    int batchable = vc->length == 0 && (verdict == NF_ACCEPT || verdict == NF_DROP);
+
+    if (batchable && s->batch_len > 0 && id == s->batch_id + 1 &&
+        vc->verdict == s->batch_verdict && vc->mark == s->batch_mark) {
+        s->batch_id = id;
+        s->batch_len++;
+        return 0;
+    }
+    int ret = flush_verdicts(s);
+    if (!batchable) {
+        return write_verdict(s, id, vc, 0);
+    }
+    s->batch_id = id;
+    s->batch_verdict = vc->verdict;
+    s->batch_mark = vc->mark;
+    s->batch_len = 1;
+    return ret;
+}
 
 static inline void configure_uid_if_available(struct nfq_q_handle *qh){
     void *hndl = dlopen("libnetfilter_queue.so.1", RTLD_LAZY);
@@ -69,7 +183,8 @@ static int nf_callback(struct nfq_q_handle *qh, struct nfgenmsg *nfmsg, struct n
     ph   = nfq_get_msg_packet_hdr(nfa);
     id   = ntohl(ph->packet_id);
     size = nfq_get_payload(nfa, &buffer);
-    idx  = (uint32_t)((uintptr_t)arg);
+    queueState *s = (queueState *)arg;
+    idx  = s->idx;
 
 #ifdef NFQA_CFG_F_UID_GID
     if (get_uid)
@@ -78,40 +193,268 @@ static int nf_callback(struct nfq_q_handle *qh, struct nfgenmsg *nfmsg, struct n
 
     go_callback(id, buffer, size, mark, idx, &vc, uid, in_dev, out_dev);
 
-    if( vc.mark_set == 1 ) {
-      return nfq_set_verdict2(qh, id, vc.verdict, vc.mark, vc.length, vc.data);
-    }
-    return nfq_set_verdict2(qh, id, vc.verdict, vc.mark, vc.length, vc.data);
+    return set_verdict(s, id, &vc);
 }
 
-static inline struct nfq_q_handle* CreateQueue(struct nfq_handle *h, uint16_t queue, uint32_t idx) {
-    struct nfq_q_handle* qh = nfq_create_queue(h, queue, &nf_callback, (void*)((uintptr_t)idx));
+static inline struct nfq_q_handle* CreateQueue(struct nfq_handle *h, uint16_t queue, queueState *s) {
+    struct nfq_q_handle* qh = nfq_create_queue(h, queue, &nf_callback, (void*)s);
     if (qh == NULL){
         printf("ERROR: nfq_create_queue() queue not created\n");
     } else {
+        s->qh = qh;
         configure_uid_if_available(qh);
     }
     return qh;
 }
 
+// recv_packets reads up to NF_RECV_BATCH packets with a single syscall,
+// without waiting for more packets once the first one has been read.
+static inline int recv_packets(int fd, queueState *s, char *bufs, struct mmsghdr *msgs, struct iovec *iovs) {
+    int i;
+    for (i = 0; i < NF_RECV_BATCH; i++) {
+        iovs[i].iov_base = bufs + i * NF_RECV_BUFFER_SIZE;
+        iovs[i].iov_len = NF_RECV_BUFFER_SIZE;
+        memset(&msgs[i], 0, sizeof(struct mmsghdr));
+        msgs[i].msg_hdr.msg_iov = &iovs[i];
+        msgs[i].msg_hdr.msg_iovlen = 1;
+    }
+    __atomic_fetch_add(&s->recv_calls, 1, __ATOMIC_RELAXED);
+    return recvmmsg(fd, msgs, NF_RECV_BATCH, MSG_WAITFORONE, NULL);
+}
+
 static inline void stop_reading_packets() {
     stop = 1;
 }
 
-static inline int Run(struct nfq_handle *h, int fd) {
-    char buf[4096] __attribute__ ((aligned));
-    int rcvd, opt = 1;
+// stop_queue stops reading the packets of a queue, leaving the rest running.
+// The queue must have a receive timeout, otherwise it won't stop until the
+// next packet is received.
+static inline void stop_queue(queueState *s) {
+    __atomic_store_n(&s->stop, 1, __ATOMIC_RELAXED);
+}
+
+static inline int queue_stopped(queueState *s) {
+    return stop == 1 || __atomic_load_n(&s->stop, __ATOMIC_RELAXED) == 1;
+}
+
+// start_handover stops the loops reading the packets, once they wake up by
+// the receive timeout or after setting the verdicts of the packets read.
+static inline void start_handover() {
+    __atomic_store_n(&handover, 1, __ATOMIC_RELEASE);
+}
+
+static inline int handing_over() {
+    return __atomic_load_n(&handover, __ATOMIC_ACQUIRE) == 1;
+}
+
+static inline int queue_running(queueState *s) {
+    return __atomic_load_n(&s->running, __ATOMIC_ACQUIRE);
+}
+
+// set_recv_timeout wakes up the reading loop every secs seconds, to check if
+// the queue has been stopped or handed over.
+static inline int set_recv_timeout(int fd, int secs) {
+    struct timeval tv = {.tv_sec = secs, .tv_usec = 0};
+    return setsockopt(fd, SOL_SOCKET, SO_RCVTIMEO, &tv, sizeof(tv));
+}
+
+static inline int Run(struct nfq_handle *h, int fd, queueState *s) {
+    struct mmsghdr msgs[NF_RECV_BATCH];
+    struct iovec iovs[NF_RECV_BATCH];
+    int i, rcvd, opt = 1, err = 0;
+    char *bufs = malloc(NF_RECV_BATCH * NF_RECV_BUFFER_SIZE);
+    if (bufs == NULL) {
+        return ENOMEM;
+    }
+
+    setsockopt(fd, SOL_NETLINK, NETLINK_NO_ENOBUFS, &opt, sizeof(int));
+    __atomic_store_n(&s->running, 1, __ATOMIC_RELEASE);
+
+    for (;;) {
+        rcvd = recv_packets(fd, s, bufs, msgs, iovs);
+        if (queue_stopped(s)) {
+            break;
+        }
+        if (rcvd < 0) {
+            // receive timeout, to check if the queue has been stopped.
+            if (errno == EAGAIN || errno == EWOULDBLOCK) {
+                if (handing_over()) {
+                    break;
+                }
+                continue;
+            }
+            err = errno;
+            break;
+        }
+        for (i = 0; i < rcvd; i++) {
+            nfq_handle_packet(h, (char *)iovs[i].iov_base, msgs[i].msg_len);
+        }
+        flush_verdicts(s);
+        if (handing_over()) {
+            break;
+        }
+    }
+    free(bufs);
+    __atomic_store_n(&s->running, 0, __ATOMIC_RELEASE);
+
+    return err;
+}
+
+// send_verdict issues the verdict of a packet directly on the netlink socket,
+// as nfq_set_verdict2() and nfq_set_verdict_batch2() do, for queues not
+// created by this process.
+static int send_verdict(int fd, uint16_t queue, uint16_t type, uint32_t id, verdictContainer *vc) {
+    char buf[NLMSG_SPACE(sizeof(struct nfgenmsg)) +
+             NLA_ALIGN(NLA_HDRLEN + sizeof(struct nfqnl_msg_verdict_hdr)) +
+             NLA_ALIGN(NLA_HDRLEN + sizeof(uint32_t)) +
+             NLA_HDRLEN] __attribute__ ((aligned));
+    struct nlmsghdr *nlh = (struct nlmsghdr *)buf;
+    struct nfgenmsg *nfg = NULL;
+    struct nlattr *attr = NULL;
+    struct nfqnl_msg_verdict_hdr *vh = NULL;
+    struct iovec iov[3];
+    struct msghdr msg = {0};
+    static const char pad[NLA_ALIGNTO] = {0};
+    int niov = 1, len = 0;
+
+    memset(buf, 0, sizeof(buf));
+    nlh->nlmsg_type = (NFNL_SUBSYS_QUEUE << 8) | type;
+    nlh->nlmsg_flags = NLM_F_REQUEST;
+
+    nfg = (struct nfgenmsg *)NLMSG_DATA(nlh);
+    nfg->nfgen_family = AF_UNSPEC;
+    nfg->version = NFNETLINK_V0;
+    nfg->res_id = htons(queue);
+    len = NLMSG_SPACE(sizeof(struct nfgenmsg));
+
+    attr = (struct nlattr *)(buf + len);
+    attr->nla_type = NFQA_VERDICT_HDR;
+    attr->nla_len = NLA_HDRLEN + sizeof(struct nfqnl_msg_verdict_hdr);
+    vh = (struct nfqnl_msg_verdict_hdr *)((char *)attr + NLA_HDRLEN);
+    vh->verdict = htonl(vc->verdict);
+    vh->id = htonl(id);
+    len += NLA_ALIGN(attr->nla_len);
+
+    attr = (struct nlattr *)(buf + len);
+    attr->nla_type = NFQA_MARK;
+    attr->nla_len = NLA_HDRLEN + sizeof(uint32_t);
+    *(uint32_t *)((char *)attr + NLA_HDRLEN) = htonl(vc->mark);
+    len += NLA_ALIGN(attr->nla_len);
+
+    iov[0].iov_base = buf;
+    iov[0].iov_len = len;
+    if (vc->length > 0 && vc->data != NULL) {
+        attr = (struct nlattr *)(buf + len);
+        attr->nla_type = NFQA_PAYLOAD;
+        attr->nla_len = NLA_HDRLEN + vc->length;
+        iov[0].iov_len += NLA_HDRLEN;
+        iov[1].iov_base = vc->data;
+        iov[1].iov_len = vc->length;
+        iov[2].iov_base = (void *)pad;
+        iov[2].iov_len = NLA_ALIGN(vc->length) - vc->length;
+        niov = 3;
+        len += NLA_HDRLEN + NLA_ALIGN(vc->length);
+    }
+    nlh->nlmsg_len = len;
+
+    msg.msg_iov = iov;
+    msg.msg_iovlen = niov;
+    return sendmsg(fd, &msg, 0);
+}
+
+// RunAdopted reads the packets of a queue whose netlink socket has been
+// inherited from a previous instance of the daemon.
+// The queue is already bound to the socket, so instead of using the
+// libnetfilter_queue handles, the messages are parsed here.
+static inline int RunAdopted(int fd, queueState *s) {
+    struct mmsghdr msgs[NF_RECV_BATCH];
+    struct iovec iovs[NF_RECV_BATCH];
+    int i, rcvd, opt = 1, err = 0;
+    char *bufs = malloc(NF_RECV_BATCH * NF_RECV_BUFFER_SIZE);
+    if (bufs == NULL) {
+        return ENOMEM;
+    }
 
     setsockopt(fd, SOL_NETLINK, NETLINK_NO_ENOBUFS, &opt, sizeof(int));
+    __atomic_store_n(&s->running, 1, __ATOMIC_RELEASE);
 
-    while ((rcvd = recv(fd, buf, sizeof(buf), 0)) >= 0) {
+    for (;;) {
+        rcvd = recv_packets(fd, s, bufs, msgs, iovs);
         if (stop == 1) {
-            return errno;
+            break;
+        }
+        if (rcvd < 0) {
+            if (errno == EAGAIN || errno == EWOULDBLOCK) {
+                if (handing_over()) {
+                    break;
+                }
+                continue;
+            }
+            err = errno;
+            break;
+        }
+        for (i = 0; i < rcvd; i++) {
+            struct nlmsghdr *nlh = (struct nlmsghdr *)iovs[i].iov_base;
+            int remain = msgs[i].msg_len;
+
+            for (; NLMSG_OK(nlh, remain); nlh = NLMSG_NEXT(nlh, remain)) {
+                if (NFNL_SUBSYS_ID(nlh->nlmsg_type) != NFNL_SUBSYS_QUEUE ||
+                    NFNL_MSG_TYPE(nlh->nlmsg_type) != NFQNL_MSG_PACKET) {
+                    continue;
+                }
+                struct nlattr *attr = (struct nlattr *)((char *)nlh + NLMSG_SPACE(sizeof(struct nfgenmsg)));
+                int attrlen = nlh->nlmsg_len - NLMSG_SPACE(sizeof(struct nfgenmsg));
+                struct nfqnl_msg_packet_hdr *ph = NULL;
+                unsigned char *payload = NULL;
+                int size = 0;
+                uint32_t mark = 0, uid = 0xffffffff, in_dev = 0, out_dev = 0;
+                verdictContainer vc = {0};
+
+                while (attrlen >= (int)NLA_HDRLEN && attr->nla_len >= NLA_HDRLEN && attr->nla_len <= attrlen) {
+                    void *data = (char *)attr + NLA_HDRLEN;
+                    switch (attr->nla_type & NLA_TYPE_MASK) {
+                    case NFQA_PACKET_HDR:
+                        ph = (struct nfqnl_msg_packet_hdr *)data;
+                        break;
+                    case NFQA_MARK:
+                        mark = ntohl(*(uint32_t *)data);
+                        break;
+                    case NFQA_PAYLOAD:
+                        payload = (unsigned char *)data;
+                        size = attr->nla_len - NLA_HDRLEN;
+                        break;
+                    case NFQA_IFINDEX_INDEV:
+                        in_dev = ntohl(*(uint32_t *)data);
+                        break;
+                    case NFQA_IFINDEX_OUTDEV:
+                        out_dev = ntohl(*(uint32_t *)data);
+                        break;
+#ifdef NFQA_CFG_F_UID_GID
+                    case NFQA_UID:
+                        uid = ntohl(*(uint32_t *)data);
+                        break;
+#endif
+                    }
+                    attrlen -= NLA_ALIGN(attr->nla_len);
+                    attr = (struct nlattr *)((char *)attr + NLA_ALIGN(attr->nla_len));
+                }
+                if (ph == NULL) {
+                    continue;
+                }
+
+                go_callback(ntohl(ph->packet_id), payload, size, mark, s->idx, &vc, uid, in_dev, out_dev);
+                set_verdict(s, ntohl(ph->packet_id), &vc);
+            }
+        }
+        flush_verdicts(s);
+        if (handing_over()) {
+            break;
         }
-        nfq_handle_packet(h, buf, rcvd);
     }
+    free(bufs);
+    __atomic_store_n(&s->running, 0, __ATOMIC_RELEASE);
 
-    return errno;
+    return err;
 }
 
 #endif
@@ -0,0 +1,195 @@
+package netfilter
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/sys/unix"
+)
+
+// max bytes of the original packet quoted in the ICMP errors (RFC 1812, RFC 4443)
+const (
+	maxICMPQuote  = 548
+	maxICMP6Quote = 1232
+)
+
+var (
+	rawSockets   = make(map[int]int)
+	rawSocketsMu sync.Mutex
+)
+
+// SetRejectVerdict drops the packet, and sends back to the application a TCP
+// RST (for TCP connections) or an ICMP port unreachable error (for the rest of
+// protocols), so the connection fails immediately instead of timing out.
+func (p *Packet) SetRejectVerdict() {
+	if reply, dst, err := newRejectPacket(p.Packet); err != nil {
+		log.Debug("[reject] unable to build the reply: %s", err)
+	} else if reply != nil {
+		if err := sendRaw(reply, dst); err != nil {
+			log.Debug("[reject] unable to send the reply to %s: %s", dst, err)
+		}
+	}
+	p.SetVerdict(NF_DROP)
+}
+
+// newRejectPacket builds the reply to reject a packet, and returns the address
+// where it must be sent to.
+// The packets that must not be replied (TCP RST, ICMP errors) return a nil reply.
+func newRejectPacket(pkt gopacket.Packet) ([]byte, net.IP, error) {
+	if pkt == nil || pkt.NetworkLayer() == nil {
+		return nil, nil, fmt.Errorf("invalid packet")
+	}
+	tcp, _ := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if tcp != nil && tcp.RST {
+		return nil, nil, nil
+	}
+
+	var replyLayers []gopacket.SerializableLayer
+	var dst net.IP
+	quote := pkt.Data()
+
+	switch orig := pkt.NetworkLayer().(type) {
+	case *layers.IPv4:
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			SrcIP:    orig.DstIP,
+			DstIP:    orig.SrcIP,
+			Protocol: layers.IPProtocolICMPv4,
+		}
+		dst = orig.SrcIP
+		if tcp != nil {
+			ip.Protocol = layers.IPProtocolTCP
+			replyLayers = []gopacket.SerializableLayer{ip, newReset(tcp, ip)}
+			break
+		}
+		if icmp, ok := pkt.Layer(layers.LayerTypeICMPv4).(*layers.ICMPv4); ok && !isICMPv4Query(icmp) {
+			return nil, nil, nil
+		}
+		if len(quote) > maxICMPQuote {
+			quote = quote[:maxICMPQuote]
+		}
+		replyLayers = []gopacket.SerializableLayer{
+			ip,
+			&layers.ICMPv4{
+				TypeCode: layers.CreateICMPv4TypeCode(layers.ICMPv4TypeDestinationUnreachable, layers.ICMPv4CodePort),
+			},
+			gopacket.Payload(quote),
+		}
+	case *layers.IPv6:
+		ip := &layers.IPv6{
+			Version:    6,
+			HopLimit:   64,
+			SrcIP:      orig.DstIP,
+			DstIP:      orig.SrcIP,
+			NextHeader: layers.IPProtocolICMPv6,
+		}
+		dst = orig.SrcIP
+		if tcp != nil {
+			ip.NextHeader = layers.IPProtocolTCP
+			replyLayers = []gopacket.SerializableLayer{ip, newReset(tcp, ip)}
+			break
+		}
+		// types < 128 are errors
+		if icmp, ok := pkt.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6); ok && icmp.TypeCode.Type() < 128 {
+			return nil, nil, nil
+		}
+		if len(quote) > maxICMP6Quote {
+			quote = quote[:maxICMP6Quote]
+		}
+		icmp := &layers.ICMPv6{
+			TypeCode: layers.CreateICMPv6TypeCode(layers.ICMPv6TypeDestinationUnreachable, layers.ICMPv6CodePortUnreachable),
+		}
+		icmp.SetNetworkLayerForChecksum(ip)
+		replyLayers = []gopacket.SerializableLayer{
+			ip,
+			icmp,
+			// 4 bytes unused after the header of the ICMPv6 error.
+			gopacket.Payload(append(make([]byte, 4), quote...)),
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported network layer %s", pkt.NetworkLayer().LayerType())
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, replyLayers...); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), dst, nil
+}
+
+// newReset returns the TCP RST that resets the connection of a TCP segment (RFC 9293, 3.10.7.1).
+func newReset(tcp *layers.TCP, ip gopacket.NetworkLayer) *layers.TCP {
+	rst := &layers.TCP{
+		SrcPort: tcp.DstPort,
+		DstPort: tcp.SrcPort,
+		RST:     true,
+	}
+	if tcp.ACK {
+		rst.Seq = tcp.Ack
+	} else {
+		rst.ACK = true
+		rst.Ack = tcp.Seq + uint32(len(tcp.Payload))
+		if tcp.SYN {
+			rst.Ack++
+		}
+		if tcp.FIN {
+			rst.Ack++
+		}
+	}
+	rst.SetNetworkLayerForChecksum(ip)
+	return rst
+}
+
+// isICMPv4Query returns true if the ICMP packet is not an error, which must
+// never be replied with another ICMP error.
+func isICMPv4Query(icmp *layers.ICMPv4) bool {
+	switch icmp.TypeCode.Type() {
+	case layers.ICMPv4TypeEchoRequest, layers.ICMPv4TypeTimestampRequest,
+		layers.ICMPv4TypeInfoRequest, layers.ICMPv4TypeAddressMaskRequest:
+		return true
+	}
+	return false
+}
+
+// sendRaw sends a packet (including the IP header) through a raw socket.
+func sendRaw(pkt []byte, dst net.IP) error {
+	family := unix.AF_INET6
+	if dst.To4() != nil {
+		family = unix.AF_INET
+	}
+	fd, err := rawSocket(family)
+	if err != nil {
+		return err
+	}
+	if family == unix.AF_INET {
+		sa := &unix.SockaddrInet4{}
+		copy(sa.Addr[:], dst.To4())
+		return unix.Sendto(fd, pkt, 0, sa)
+	}
+	sa := &unix.SockaddrInet6{}
+	copy(sa.Addr[:], dst.To16())
+	return unix.Sendto(fd, pkt, 0, sa)
+}
+
+// rawSocket returns the raw socket of a family, opening it the first time.
+// IPPROTO_RAW sockets expect the packets to include the IP header.
+func rawSocket(family int) (int, error) {
+	rawSocketsMu.Lock()
+	defer rawSocketsMu.Unlock()
+
+	if fd, found := rawSockets[family]; found {
+		return fd, nil
+	}
+	fd, err := unix.Socket(family, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+	if err != nil {
+		return -1, err
+	}
+	rawSockets[family] = fd
+	return fd, nil
+}
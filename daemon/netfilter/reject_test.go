@@ -0,0 +1,93 @@
+package netfilter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func serializePacket(t *testing.T, first gopacket.LayerType, l ...gopacket.SerializableLayer) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, l...); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), first, gopacket.Default)
+}
+
+func TestRejectTCP(t *testing.T) {
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.IP{192, 168, 1, 100},
+		DstIP:    net.IP{1, 1, 1, 1},
+	}
+	syn := &layers.TCP{SrcPort: 43210, DstPort: 443, Seq: 1000, SYN: true}
+	syn.SetNetworkLayerForChecksum(ip)
+
+	reply, dst, err := newRejectPacket(serializePacket(t, layers.LayerTypeIPv4, ip, syn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dst.Equal(ip.SrcIP) {
+		t.Errorf("the reply should be sent to the source of the connection: %s", dst)
+	}
+	pkt := gopacket.NewPacket(reply, layers.LayerTypeIPv4, gopacket.Default)
+	rip := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !rip.SrcIP.Equal(ip.DstIP) || !rip.DstIP.Equal(ip.SrcIP) {
+		t.Errorf("unexpected addresses of the reply: %s -> %s", rip.SrcIP, rip.DstIP)
+	}
+	rst, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !ok {
+		t.Fatal("the reply should be a TCP segment")
+	}
+	if !rst.RST || !rst.ACK || rst.Ack != syn.Seq+1 || rst.SrcPort != syn.DstPort || rst.DstPort != syn.SrcPort {
+		t.Errorf("unexpected reset: %+v", rst)
+	}
+
+	// resets are not replied
+	syn.SYN, syn.RST = false, true
+	if reply, _, err := newRejectPacket(serializePacket(t, layers.LayerTypeIPv4, ip, syn)); reply != nil || err != nil {
+		t.Errorf("a reset should not be replied: %v, %v", reply, err)
+	}
+}
+
+func TestRejectUDP(t *testing.T) {
+	ip := &layers.IPv6{
+		Version:    6,
+		HopLimit:   64,
+		NextHeader: layers.IPProtocolUDP,
+		SrcIP:      net.ParseIP("2001:db8::100"),
+		DstIP:      net.ParseIP("2001:db8::53"),
+	}
+	udp := &layers.UDP{SrcPort: 40000, DstPort: 53}
+	udp.SetNetworkLayerForChecksum(ip)
+	orig := serializePacket(t, layers.LayerTypeIPv6, ip, udp, gopacket.Payload([]byte("query")))
+
+	reply, dst, err := newRejectPacket(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dst.Equal(ip.SrcIP) {
+		t.Errorf("the reply should be sent to the source of the connection: %s", dst)
+	}
+	pkt := gopacket.NewPacket(reply, layers.LayerTypeIPv6, gopacket.Default)
+	icmp, ok := pkt.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6)
+	if !ok {
+		t.Fatal("the reply should be an ICMPv6 error")
+	}
+	if icmp.TypeCode.Type() != layers.ICMPv6TypeDestinationUnreachable || icmp.TypeCode.Code() != layers.ICMPv6CodePortUnreachable {
+		t.Errorf("unexpected ICMPv6 error: %s", icmp.TypeCode)
+	}
+	if quote := icmp.Payload[4:]; string(quote) != string(orig.Data()) {
+		t.Errorf("the error should include the original packet")
+	}
+
+	// ICMP errors are not replied
+	if reply, _, err := newRejectPacket(pkt); reply != nil || err != nil {
+		t.Errorf("an ICMP error should not be replied: %v, %v", reply, err)
+	}
+}
@@ -0,0 +1,66 @@
+package netfilter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// QueuesStatsFile is the file where the kernel reports the counters of the queues.
+var QueuesStatsFile = "/proc/net/netfilter/nfnetlink_queue"
+
+// QueueStats holds the counters of a queue, as reported by the kernel.
+type QueueStats struct {
+	Num uint16
+	// packets waiting for a verdict.
+	Total uint64
+	// packets dropped because the queue was full.
+	Dropped uint64
+	// packets dropped because netlink failed to send them to userspace.
+	UserDropped uint64
+	// id of the last packet queued.
+	IDSequence uint64
+}
+
+// GetQueuesStats returns the counters of the queues with a process listening
+// on them, by queue number.
+func GetQueuesStats() (map[uint16]*QueueStats, error) {
+	f, err := os.Open(QueuesStatsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseQueuesStats(f)
+}
+
+// format:
+// queue_number peer_portid queue_total copy_mode copy_range queue_dropped user_dropped id_sequence 1
+func parseQueuesStats(r io.Reader) (map[uint16]*QueueStats, error) {
+	stats := make(map[uint16]*QueueStats)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		var values [8]uint64
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid queue stats line: %s", scanner.Text())
+			}
+			values[i] = v
+		}
+		stats[uint16(values[0])] = &QueueStats{
+			Num:         uint16(values[0]),
+			Total:       values[2],
+			Dropped:     values[5],
+			UserDropped: values[6],
+			IDSequence:  values[7],
+		}
+	}
+	return stats, scanner.Err()
+}
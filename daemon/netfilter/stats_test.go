@@ -0,0 +1,30 @@
+package netfilter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQueuesStats(t *testing.T) {
+	raw := `    0  12345     3 2  4096     7     1    98765  1
+    2  12346     0 2  4096     0     0       42  1
+`
+	stats, err := parseQueuesStats(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal("parseQueuesStats() error:", err)
+	}
+	if len(stats) != 2 {
+		t.Fatal("expected 2 queues, got:", len(stats))
+	}
+	q := stats[0]
+	if q.Total != 3 || q.Dropped != 7 || q.UserDropped != 1 || q.IDSequence != 98765 {
+		t.Errorf("invalid queue 0 stats: %+v", q)
+	}
+	if stats[2] == nil || stats[2].IDSequence != 42 {
+		t.Errorf("invalid queue 2 stats: %+v", stats[2])
+	}
+
+	if _, err := parseQueuesStats(strings.NewReader("0 1 2 3 4 5 6 x 1\n")); err == nil {
+		t.Error("invalid stats accepted")
+	}
+}
@@ -0,0 +1,214 @@
+package netfilter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// Policies to apply when the daemon stops processing the queued packets.
+const (
+	// accept the connections, removing the interception rules.
+	FailOpen = "open"
+	// drop the connections.
+	FailClosed = "closed"
+)
+
+var (
+	// verdict applied to the packets that couldn't be delivered to the daemon.
+	failVerdict atomic.Uint32
+
+	defaultRecoveryInterval = time.Minute
+)
+
+func init() {
+	failVerdict.Store(uint32(NF_ACCEPT))
+}
+
+// WatchdogConfig holds the configuration of the queues watchdog.
+type WatchdogConfig struct {
+	// Interval to check that the packets are being processed. Empty or 0s
+	// disables the watchdog (default).
+	Interval string `json:"Interval"`
+	// Interval to intercept connections again after a stall, with the
+	// fail-open policy.
+	RecoveryInterval string `json:"RecoveryInterval"`
+	// open or closed. When using the closed policy, the option QueueBypass
+	// should be disabled, so the packets are dropped if the daemon dies.
+	FailPolicy string `json:"FailPolicy"`
+}
+
+// QueuesWatchdog checks that the packets of the queues are being processed,
+// detecting when the daemon stops reading them (deadlocks, stalls), or stops
+// setting verdicts on them.
+type QueuesWatchdog struct {
+	// OnStall is called when the packets are not being processed.
+	// If bypass is true, the interception of connections must be disabled.
+	OnStall func(reason i18n.Message, bypass bool)
+	// OnRecover is called when the packets are processed again.
+	// If bypass is true, the interception of connections must be enabled.
+	OnRecover func(bypass bool)
+
+	queues map[uint16]*Queue
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+type queueCounters struct {
+	verdicts uint64
+	timeouts uint64
+}
+
+// Watchdog is the watchdog of the queues of the daemon.
+var Watchdog = &QueuesWatchdog{
+	queues: make(map[uint16]*Queue),
+}
+
+// getQueuesStats returns the counters reported by the kernel.
+var getQueuesStats = GetQueuesStats
+
+// AddQueue adds a queue to check.
+func (w *QueuesWatchdog) AddQueue(q *Queue) {
+	w.Lock()
+	defer w.Unlock()
+	w.queues[q.Num()] = q
+}
+
+// SetConfig applies the configuration, restarting the watchdog.
+func (w *QueuesWatchdog) SetConfig(cfg WatchdogConfig) error {
+	w.Stop()
+
+	policy := cfg.FailPolicy
+	if policy == "" {
+		policy = FailOpen
+	}
+	if policy != FailOpen && policy != FailClosed {
+		return fmt.Errorf("invalid queues fail policy: %s", cfg.FailPolicy)
+	}
+	failOpen := policy == FailOpen
+	if failOpen {
+		failVerdict.Store(uint32(NF_ACCEPT))
+	} else {
+		failVerdict.Store(uint32(NF_DROP))
+	}
+
+	if cfg.Interval == "" {
+		return nil
+	}
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid queues watchdog interval: %s", err)
+	}
+	if interval <= 0 {
+		return nil
+	}
+	recovery := defaultRecoveryInterval
+	if cfg.RecoveryInterval != "" {
+		if recovery, err = time.ParseDuration(cfg.RecoveryInterval); err != nil || recovery <= 0 {
+			return fmt.Errorf("invalid queues watchdog recovery interval: %s", cfg.RecoveryInterval)
+		}
+	}
+
+	log.Debug("[queues] watchdog started, interval: %s, policy: fail-%s", interval, policy)
+	w.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.wg.Add(1)
+	w.Unlock()
+	go w.run(ctx, interval, recovery, failOpen)
+
+	return nil
+}
+
+// Stop stops the watchdog.
+func (w *QueuesWatchdog) Stop() {
+	w.Lock()
+	cancel := w.cancel
+	w.cancel = nil
+	w.Unlock()
+	if cancel != nil {
+		cancel()
+		w.wg.Wait()
+	}
+}
+
+func (w *QueuesWatchdog) run(ctx context.Context, interval, recovery time.Duration, failOpen bool) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := make(map[uint16]queueCounters)
+	w.check(last)
+	stalled := false
+	stalledAt := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			if stalled && failOpen && w.OnRecover != nil {
+				w.OnRecover(true)
+			}
+			return
+		case <-ticker.C:
+		}
+
+		reason, progressed := w.check(last)
+		switch {
+		case !stalled && !reason.IsEmpty():
+			stalled = true
+			stalledAt = time.Now()
+			if w.OnStall != nil {
+				w.OnStall(reason, failOpen)
+			}
+		case stalled && reason.IsEmpty() && (progressed || (failOpen && time.Since(stalledAt) >= recovery)):
+			// with the fail-open policy no packets are queued while the
+			// interception is disabled, so we intercept connections again
+			// after the recovery interval, to check if the daemon has recovered.
+			stalled = false
+			if w.OnRecover != nil {
+				w.OnRecover(failOpen)
+			}
+		}
+	}
+}
+
+// check compares the counters of the queues with the last ones.
+// A queue is stalled if no verdicts have been set since the last check, while
+// there're packets waiting for a verdict, or packets not delivered to the daemon.
+// The queues waiting for the user to answer a prompt are not stalled.
+func (w *QueuesWatchdog) check(last map[uint16]queueCounters) (reason i18n.Message, progressed bool) {
+	kstats, err := getQueuesStats()
+	if err != nil {
+		log.Debug("[queues] watchdog, unable to get queues stats: %s", err)
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	for num, q := range w.queues {
+		cur := queueCounters{
+			verdicts: q.verdicts.Load(),
+			timeouts: q.timeouts.Load(),
+		}
+		prev, found := last[num]
+		last[num] = cur
+		if !found || q.asking.Load() > 0 {
+			continue
+		}
+		if cur.verdicts != prev.verdicts {
+			progressed = true
+			continue
+		}
+		if cur.timeouts != prev.timeouts {
+			reason = i18n.New(i18n.QueueNotRead, "queue", num, "packets", cur.timeouts-prev.timeouts)
+		} else if ks, found := kstats[num]; found && ks.Total > 0 {
+			reason = i18n.New(i18n.QueueNotVerdicted, "queue", num, "packets", ks.Total)
+		}
+	}
+
+	return reason, progressed
+}
@@ -0,0 +1,104 @@
+package netfilter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+)
+
+func TestWatchdog(t *testing.T) {
+	backlog := uint64(0)
+	getQueuesStats = func() (map[uint16]*QueueStats, error) {
+		return map[uint16]*QueueStats{0: {Num: 0, Total: backlog}}, nil
+	}
+	defer func() { getQueuesStats = GetQueuesStats }()
+
+	q := &Queue{num: 0}
+	w := &QueuesWatchdog{queues: map[uint16]*Queue{0: q}}
+	last := make(map[uint16]queueCounters)
+	w.check(last)
+
+	t.Run("idle", func(t *testing.T) {
+		if reason, progressed := w.check(last); !reason.IsEmpty() || progressed {
+			t.Error("idle queue reported as stalled:", reason, progressed)
+		}
+	})
+	t.Run("processing", func(t *testing.T) {
+		q.verdicts.Add(10)
+		backlog = 5
+		if reason, progressed := w.check(last); !reason.IsEmpty() || !progressed {
+			t.Error("queue processing packets reported as stalled:", reason, progressed)
+		}
+	})
+	t.Run("asking", func(t *testing.T) {
+		done := (&Packet{queue: q}).Asking()
+		if reason, _ := w.check(last); !reason.IsEmpty() {
+			t.Error("queue waiting for a prompt reported as stalled:", reason)
+		}
+		done()
+	})
+	t.Run("verdicts not set", func(t *testing.T) {
+		if reason, _ := w.check(last); reason.IsEmpty() {
+			t.Error("stalled queue not detected")
+		}
+		backlog = 0
+	})
+	t.Run("packets not read", func(t *testing.T) {
+		q.timeouts.Add(3)
+		if reason, _ := w.check(last); reason.IsEmpty() {
+			t.Error("stalled queue not detected")
+		}
+	})
+}
+
+func TestWatchdogPolicy(t *testing.T) {
+	stalls := make(chan bool, 1)
+	recoveries := make(chan bool, 1)
+	w := &QueuesWatchdog{
+		queues:    make(map[uint16]*Queue),
+		OnStall:   func(reason i18n.Message, bypass bool) { stalls <- bypass },
+		OnRecover: func(bypass bool) { recoveries <- bypass },
+	}
+	q := &Queue{num: 5}
+	w.AddQueue(q)
+	defer w.SetConfig(WatchdogConfig{})
+
+	if err := w.SetConfig(WatchdogConfig{Interval: "10ms", RecoveryInterval: "50ms"}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	if failVerdict.Load() != uint32(NF_ACCEPT) {
+		t.Error("fail-open policy not applied")
+	}
+	time.Sleep(20 * time.Millisecond)
+	q.timeouts.Add(1)
+	select {
+	case bypass := <-stalls:
+		if !bypass {
+			t.Error("interception not disabled with the fail-open policy")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stall not notified")
+	}
+	// no packets are queued while the interception is disabled
+	select {
+	case bypass := <-recoveries:
+		if !bypass {
+			t.Error("interception not enabled after the recovery interval")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("recovery not notified")
+	}
+
+	if err := w.SetConfig(WatchdogConfig{FailPolicy: FailClosed}); err != nil {
+		t.Error("SetConfig() error:", err)
+	}
+	if failVerdict.Load() != uint32(NF_DROP) {
+		t.Error("fail-closed policy not applied")
+	}
+	for _, cfg := range []WatchdogConfig{{FailPolicy: "ajar"}, {Interval: "abc"}, {Interval: "1s", RecoveryInterval: "-1s"}} {
+		if err := w.SetConfig(cfg); err == nil {
+			t.Error("invalid config accepted:", cfg)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package netlink
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// type of the messages of the conntrack entries deleted:
+// NFNL_SUBSYS_CTNETLINK << 8 | IPCTNL_MSG_CT_DELETE
+const ctDeleteMsg = unix.NFNL_SUBSYS_CTNETLINK<<8 | 2
+
+// type of the requests to dump the conntrack table:
+// NFNL_SUBSYS_CTNETLINK << 8 | IPCTNL_MSG_CT_GET
+const ctGetMsg = unix.NFNL_SUBSYS_CTNETLINK<<8 | nl.IPCTNL_MSG_CT_GET
+
+// ConntrackAcctFile enables the counters of bytes and packets of the
+// conntrack entries.
+var ConntrackAcctFile = "/proc/sys/net/netfilter/nf_conntrack_acct"
+
+// Flow is a connection tracked by conntrack.
+type Flow struct {
+	Src     net.IP
+	Dst     net.IP
+	SrcPort uint16
+	DstPort uint16
+	Proto   uint8
+	Mark    uint32
+
+	// counters of the original (sent) and reply (received) directions,
+	// only reported if the accounting of conntrack is enabled.
+	PacketsSent uint64
+	BytesSent   uint64
+	PacketsRecv uint64
+	BytesRecv   uint64
+}
+
+// Protocol returns the name of the protocol of the flow, as named in the
+// connections: tcp, udp, udplite, sctp, icmp, ...
+func (f *Flow) Protocol() string {
+	switch f.Proto {
+	case syscall.IPPROTO_TCP:
+		return "tcp"
+	case syscall.IPPROTO_UDP:
+		return "udp"
+	case syscall.IPPROTO_UDPLITE:
+		return "udplite"
+	case syscall.IPPROTO_SCTP:
+		return "sctp"
+	case syscall.IPPROTO_ICMP, syscall.IPPROTO_ICMPV6:
+		return "icmp"
+	}
+	return fmt.Sprint(f.Proto)
+}
+
+// EnableConntrackAcct enables the counters of bytes and packets of the
+// connections tracked from now on.
+func EnableConntrackAcct() error {
+	return os.WriteFile(ConntrackAcctFile, []byte("1"), 0644)
+}
+
+// ConntrackDestroyEvents subscribes to the events of the conntrack entries
+// destroyed, i.e.: the connections that have ended, and sends them to the
+// channel until done is closed.
+func ConntrackDestroyEvents(flows chan<- *Flow, done <-chan struct{}) error {
+	sock, err := nl.Subscribe(unix.NETLINK_NETFILTER, unix.NFNLGRP_CONNTRACK_DESTROY)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-done
+		sock.Close()
+	}()
+
+	go func() {
+		for {
+			msgs, _, err := sock.Receive()
+			if err != nil {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// the events are lost when the buffer of the socket is full.
+				if errors.Is(err, unix.ENOBUFS) {
+					log.Debug("[conntrack] events lost: %s", err)
+					continue
+				}
+				log.Warning("[conntrack] error receiving events: %s", err)
+				return
+			}
+			for _, m := range msgs {
+				if m.Header.Type != ctDeleteMsg {
+					continue
+				}
+				f, err := parseFlow(m.Data)
+				if err != nil {
+					log.Debug("[conntrack] invalid event: %s", err)
+					continue
+				}
+				select {
+				case flows <- f:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// ConntrackDump returns the IPv4 and IPv6 entries of the conntrack table,
+// with their counters.
+func ConntrackDump() ([]*Flow, error) {
+	flows := []*Flow{}
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		req := nl.NewNetlinkRequest(ctGetMsg, unix.NLM_F_DUMP)
+		req.AddData(&nl.Nfgenmsg{NfgenFamily: family, Version: nl.NFNETLINK_V0})
+		msgs, err := req.Execute(unix.NETLINK_NETFILTER, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			f, err := parseFlow(m)
+			if err != nil {
+				log.Debug("[conntrack] invalid entry: %s", err)
+				continue
+			}
+			flows = append(flows, f)
+		}
+	}
+	return flows, nil
+}
+
+// parseFlow parses a conntrack message: a nfgenmsg header followed by the
+// attributes of the entry.
+func parseFlow(data []byte) (*Flow, error) {
+	if len(data) < nl.SizeofNfgenmsg {
+		return nil, fmt.Errorf("short message (%d)", len(data))
+	}
+	attrs, err := nl.ParseRouteAttr(data[nl.SizeofNfgenmsg:])
+	if err != nil {
+		return nil, err
+	}
+	f := &Flow{}
+	for _, attr := range attrs {
+		switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+		case nl.CTA_TUPLE_ORIG:
+			if err := parseTuple(attr.Value, f); err != nil {
+				return nil, err
+			}
+		case nl.CTA_COUNTERS_ORIG:
+			f.PacketsSent, f.BytesSent = parseCounters(attr.Value)
+		case nl.CTA_COUNTERS_REPLY:
+			f.PacketsRecv, f.BytesRecv = parseCounters(attr.Value)
+		case nl.CTA_MARK:
+			if len(attr.Value) >= 4 {
+				f.Mark = binary.BigEndian.Uint32(attr.Value)
+			}
+		}
+	}
+	if f.Src == nil || f.Dst == nil {
+		return nil, fmt.Errorf("flow without addresses")
+	}
+	return f, nil
+}
+
+func parseTuple(data []byte, f *Flow) error {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		typ := attr.Attr.Type & nl.NLA_TYPE_MASK
+		if typ != nl.CTA_TUPLE_IP && typ != nl.CTA_TUPLE_PROTO {
+			continue
+		}
+		nested, err := nl.ParseRouteAttr(attr.Value)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case nl.CTA_TUPLE_IP:
+			for _, a := range nested {
+				switch a.Attr.Type & nl.NLA_TYPE_MASK {
+				case nl.CTA_IP_V4_SRC, nl.CTA_IP_V6_SRC:
+					f.Src = net.IP(append([]byte{}, a.Value...))
+				case nl.CTA_IP_V4_DST, nl.CTA_IP_V6_DST:
+					f.Dst = net.IP(append([]byte{}, a.Value...))
+				}
+			}
+		case nl.CTA_TUPLE_PROTO:
+			for _, a := range nested {
+				switch a.Attr.Type & nl.NLA_TYPE_MASK {
+				case nl.CTA_PROTO_NUM:
+					if len(a.Value) >= 1 {
+						f.Proto = a.Value[0]
+					}
+				case nl.CTA_PROTO_SRC_PORT:
+					if len(a.Value) >= 2 {
+						f.SrcPort = binary.BigEndian.Uint16(a.Value)
+					}
+				case nl.CTA_PROTO_DST_PORT:
+					if len(a.Value) >= 2 {
+						f.DstPort = binary.BigEndian.Uint16(a.Value)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func parseCounters(data []byte) (packets, bytes uint64) {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return 0, 0
+	}
+	for _, a := range attrs {
+		if len(a.Value) < 8 {
+			continue
+		}
+		switch a.Attr.Type & nl.NLA_TYPE_MASK {
+		case nl.CTA_COUNTERS_PACKETS:
+			packets = binary.BigEndian.Uint64(a.Value)
+		case nl.CTA_COUNTERS_BYTES:
+			bytes = binary.BigEndian.Uint64(a.Value)
+		}
+	}
+	return packets, bytes
+}
@@ -0,0 +1,57 @@
+package netlink
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+)
+
+func TestParseFlow(t *testing.T) {
+	nested := func(typ int) *nl.RtAttr {
+		return nl.NewRtAttr(typ|int(nl.NLA_F_NESTED), nil)
+	}
+	orig := nested(nl.CTA_TUPLE_ORIG)
+	ips := orig.AddRtAttr(nl.CTA_TUPLE_IP|int(nl.NLA_F_NESTED), nil)
+	ips.AddRtAttr(nl.CTA_IP_V4_SRC, net.ParseIP("192.168.1.100").To4())
+	ips.AddRtAttr(nl.CTA_IP_V4_DST, net.ParseIP("1.1.1.1").To4())
+	proto := orig.AddRtAttr(nl.CTA_TUPLE_PROTO|int(nl.NLA_F_NESTED), nil)
+	proto.AddRtAttr(nl.CTA_PROTO_NUM, []byte{syscall.IPPROTO_TCP})
+	proto.AddRtAttr(nl.CTA_PROTO_SRC_PORT, nl.BEUint16Attr(51234))
+	proto.AddRtAttr(nl.CTA_PROTO_DST_PORT, nl.BEUint16Attr(443))
+
+	sent := nested(nl.CTA_COUNTERS_ORIG)
+	sent.AddRtAttr(nl.CTA_COUNTERS_PACKETS, nl.BEUint64Attr(10))
+	sent.AddRtAttr(nl.CTA_COUNTERS_BYTES, nl.BEUint64Attr(1200))
+	recv := nested(nl.CTA_COUNTERS_REPLY)
+	recv.AddRtAttr(nl.CTA_COUNTERS_PACKETS, nl.BEUint64Attr(20))
+	recv.AddRtAttr(nl.CTA_COUNTERS_BYTES, nl.BEUint64Attr(64000))
+
+	data := make([]byte, nl.SizeofNfgenmsg)
+	data[0] = syscall.AF_INET
+	for _, attr := range []*nl.RtAttr{orig, sent, recv, nl.NewRtAttr(nl.CTA_MARK, nl.BEUint32Attr(0x101))} {
+		data = append(data, attr.Serialize()...)
+	}
+
+	f, err := parseFlow(data)
+	if err != nil {
+		t.Fatal("parseFlow() error:", err)
+	}
+	if f.Src.String() != "192.168.1.100" || f.Dst.String() != "1.1.1.1" || f.SrcPort != 51234 || f.DstPort != 443 {
+		t.Errorf("invalid tuple: %s:%d -> %s:%d", f.Src, f.SrcPort, f.Dst, f.DstPort)
+	}
+	if f.Protocol() != "tcp" || f.Mark != 0x101 {
+		t.Error("invalid protocol or mark:", f.Protocol(), f.Mark)
+	}
+	if f.PacketsSent != 10 || f.BytesSent != 1200 || f.PacketsRecv != 20 || f.BytesRecv != 64000 {
+		t.Errorf("invalid counters: %+v", f)
+	}
+
+	if _, err := parseFlow(data[:nl.SizeofNfgenmsg]); err == nil {
+		t.Error("flow without addresses parsed")
+	}
+	if _, err := parseFlow(data[:2]); err == nil {
+		t.Error("short message parsed")
+	}
+}
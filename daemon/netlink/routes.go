@@ -0,0 +1,56 @@
+package netlink
+
+import (
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// GetRoute returns the route to a destination from the routing table: the
+// gateway (nil if the destination is on a directly connected network), and
+// the index of the output interface.
+func GetRoute(dst net.IP) (gw net.IP, linkIndex int, err error) {
+	routes, err := netlink.RouteGet(dst)
+	if err != nil || len(routes) == 0 {
+		return nil, 0, err
+	}
+	return routes[0].Gw, routes[0].LinkIndex, nil
+}
+
+// GetNeighborMAC returns the MAC address of a destination on a directly
+// connected network from the neighbors table (ARP, NDP), or an empty string
+// if it's not known.
+func GetNeighborMAC(ip net.IP, linkIndex int) string {
+	family := unix.AF_INET6
+	if ip.To4() != nil {
+		family = unix.AF_INET
+	}
+	neighs, err := netlink.NeighList(linkIndex, family)
+	if err != nil {
+		return ""
+	}
+	for _, n := range neighs {
+		if n.IP.Equal(ip) && len(n.HardwareAddr) > 0 {
+			return strings.ToLower(n.HardwareAddr.String())
+		}
+	}
+	return ""
+}
+
+// GetBroadcastAddrs returns the broadcast addresses of the networks of the
+// local interfaces.
+func GetBroadcastAddrs() []net.IP {
+	addrs, err := netlink.AddrList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil
+	}
+	bcast := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		if a.Broadcast != nil {
+			bcast = append(bcast, a.Broadcast)
+		}
+	}
+	return bcast
+}
@@ -209,6 +209,48 @@ func KillAllSockets() {
 
 }
 
+// CloseSockets kills the connected TCP and UDP sockets with the given inodes,
+// and deletes their conntrack entries. It returns the number of sockets
+// killed.
+func CloseSockets(inodes map[uint32]bool) int {
+	closed := 0
+	for _, fam := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		filters := make([]netlink.CustomConntrackFilter, 0)
+		for _, proto := range []uint8{syscall.IPPROTO_TCP, syscall.IPPROTO_UDP} {
+			sockList, err := SocketsDump(fam, proto)
+			if err != nil {
+				log.Debug("CloseSockets, unable to dump sockets (%d/%d): %s", fam, proto, err)
+				continue
+			}
+			for _, sock := range sockList {
+				if sock == nil || !inodes[sock.INode] || sock.ID.Destination.IsUnspecified() {
+					continue
+				}
+				if err := SocketKill(fam, proto, sock.ID); err != nil {
+					log.Debug("CloseSockets, unable to kill socket (%+v): %s", sock.ID, err)
+					continue
+				}
+				closed++
+
+				filter := &netlink.ConntrackFilter{}
+				filter.AddProtocol(proto)
+				filter.AddIP(netlink.ConntrackOrigSrcIP, sock.ID.Source)
+				filter.AddIP(netlink.ConntrackOrigDstIP, sock.ID.Destination)
+				filter.AddPort(netlink.ConntrackOrigSrcPort, sock.ID.SourcePort)
+				filter.AddPort(netlink.ConntrackOrigDstPort, sock.ID.DestinationPort)
+				filters = append(filters, filter)
+			}
+		}
+		if len(filters) == 0 {
+			continue
+		}
+		if _, err := netlink.ConntrackDeleteFilters(netlink.ConntrackTable, netlink.InetFamily(fam), filters...); err != nil {
+			log.Debug("CloseSockets, error deleting conntrack entries: %s", err)
+		}
+	}
+	return closed
+}
+
 // FlushConnections flushes conntrack as soon as netfilter rule is set.
 // This ensures that already-established connections will go to netfilter queue.
 func FlushConnections() {
@@ -0,0 +1,344 @@
+// Package netns intercepts the connections of the network namespaces of the
+// host: containers (docker, podman, CNI), and the namespaces added with
+// ip-netns(8).
+//
+// The packets of the containers connected to a bridge are NATted by the host,
+// so the daemon only sees the host-side flow, without the process that opened
+// it. Instead, the interception rules are added to every namespace, and a
+// queue is opened inside each one (setns), so the connections are queued
+// before leaving the namespace.
+//
+// The processes of the connections are found with the eBPF process monitor:
+// the sockets of other namespaces are not listed by /proc/net or netlink in
+// the namespace of the daemon.
+package netns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall/nftables"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
+)
+
+var (
+	defaultInterval = 10 * time.Second
+
+	procPath = "/proc"
+	// directories where ip-netns(8), CNI plugins and docker bind mount the
+	// namespaces.
+	mountDirs = []string{"/run/netns", "/var/run/netns", "/var/run/docker/netns"}
+)
+
+// Config holds the configuration of the interception of the namespaces.
+type Config struct {
+	// Enabled intercepts the connections of the namespaces. It requires the
+	// eBPF process monitor to know the processes of the connections.
+	Enabled bool `json:"Enabled"`
+	// Interval to look for new namespaces, and for the ones gone (10s by
+	// default).
+	Interval string `json:"Interval"`
+	// Exclude holds the names of the bind mounted namespaces that are not
+	// intercepted, like the ones of ip-netns(8) or the IDs of docker.
+	Exclude []string `json:"Exclude"`
+}
+
+// Namespace is a network namespace other than the one of the daemon.
+type Namespace struct {
+	// Path to open it: a bind mount, or /proc/<pid>/ns/net.
+	Path  string
+	Inode uint64
+}
+
+// Discover returns the network namespaces of the host, except the one of the
+// daemon. The bind mounted namespaces are listed first, then the ones of the
+// processes not bind mounted.
+func Discover(exclude []string) ([]Namespace, error) {
+	host, err := nsInode(filepath.Join(procPath, "self", "ns", "net"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the network namespace of the daemon: %s", err)
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+
+	seen := map[uint64]bool{host: true}
+	list := []Namespace{}
+	add := func(path string) {
+		ino, err := nsInode(path)
+		if err != nil || seen[ino] {
+			return
+		}
+		seen[ino] = true
+		list = append(list, Namespace{Path: path, Inode: ino})
+	}
+	for _, dir := range mountDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if excluded[e.Name()] {
+				// the processes of the namespace are not added either.
+				if ino, err := nsInode(filepath.Join(dir, e.Name())); err == nil {
+					seen[ino] = true
+				}
+				continue
+			}
+			add(filepath.Join(dir, e.Name()))
+		}
+	}
+
+	entries, err := os.ReadDir(procPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil {
+			continue
+		}
+		add(filepath.Join(procPath, e.Name(), "ns", "net"))
+	}
+	return list, nil
+}
+
+func nsInode(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("%s: unknown inode", path)
+	}
+	return st.Ino, nil
+}
+
+// queue is the subset of netfilter.Queue used, replaced by the tests.
+type queue interface {
+	Packets() <-chan netfilter.Packet
+	Detach()
+}
+
+// functions replaced by the tests.
+var (
+	discover  = Discover
+	intercept = nftables.InterceptNetNS
+	remove    = nftables.RemoveNetNSInterception
+	openQueue = func(num uint16, ns *os.File) (queue, error) {
+		q, err := netfilter.NewQueueInNetNS(num, ns)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	}
+)
+
+type nsQueue struct {
+	ns    Namespace
+	file  *os.File
+	queue queue
+}
+
+// Interceptor adds the interception rules and the queues to the namespaces
+// found, and removes them from the ones gone.
+type Interceptor struct {
+	packets chan netfilter.Packet
+	queues  map[uint64]*nsQueue
+	// namespaces that couldn't be intercepted, retried on every scan.
+	failed map[uint64]bool
+	stop   chan struct{}
+
+	cfg      Config
+	queueNum uint16
+	bypass   bool
+	ready    bool
+	sync.Mutex
+}
+
+// Default is the interceptor of the namespaces of the daemon.
+var Default = New()
+
+// New returns a new interceptor, disabled until it's configured.
+func New() *Interceptor {
+	return &Interceptor{
+		packets: make(chan netfilter.Packet),
+		queues:  make(map[uint64]*nsQueue),
+		failed:  make(map[uint64]bool),
+	}
+}
+
+// Packets returns the packets queued in all the namespaces.
+func (i *Interceptor) Packets() <-chan netfilter.Packet {
+	return i.packets
+}
+
+// SetQueue sets the queue where the connections are queued in every
+// namespace, and starts intercepting them if it's enabled.
+// The queue numbers are not shared between namespaces, so it's usually the
+// queue of the daemon.
+func (i *Interceptor) SetQueue(num uint16, bypass bool) error {
+	i.Lock()
+	defer i.Unlock()
+	i.stopLocked()
+	i.queueNum, i.bypass, i.ready = num, bypass, true
+	return i.startLocked()
+}
+
+// SetConfig applies a new configuration, intercepting the namespaces again.
+func (i *Interceptor) SetConfig(cfg Config) error {
+	i.Lock()
+	defer i.Unlock()
+	i.stopLocked()
+	i.cfg = cfg
+	return i.startLocked()
+}
+
+func (i *Interceptor) startLocked() error {
+	if !i.cfg.Enabled || !i.ready {
+		return nil
+	}
+	interval := defaultInterval
+	if i.cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(i.cfg.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid namespaces interval '%s'", i.cfg.Interval)
+		}
+	}
+	i.stop = make(chan struct{})
+	go i.run(i.stop, interval)
+	log.Info("[netns] intercepting the network namespaces, every %s", interval)
+	return nil
+}
+
+// Stop stops intercepting the namespaces, deleting the rules and the queues.
+func (i *Interceptor) Stop() {
+	i.Lock()
+	defer i.Unlock()
+	i.stopLocked()
+}
+
+func (i *Interceptor) stopLocked() {
+	if i.stop != nil {
+		close(i.stop)
+		i.stop = nil
+	}
+	for ino, q := range i.queues {
+		i.release(q, true)
+		delete(i.queues, ino)
+	}
+	i.failed = make(map[uint64]bool)
+}
+
+func (i *Interceptor) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		i.scan(stop)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scan intercepts the namespaces not intercepted yet, and releases the ones
+// gone.
+func (i *Interceptor) scan(stop chan struct{}) {
+	i.Lock()
+	defer i.Unlock()
+	if stop != i.stop {
+		return
+	}
+	list, err := discover(i.cfg.Exclude)
+	if err != nil {
+		log.Warning("[netns] %s", err)
+		return
+	}
+
+	found := make(map[uint64]bool, len(list))
+	for _, ns := range list {
+		found[ns.Inode] = true
+		if _, ok := i.queues[ns.Inode]; ok {
+			continue
+		}
+		q, err := i.add(ns)
+		if err != nil {
+			if !i.failed[ns.Inode] {
+				log.Warning("[netns] unable to intercept %s: %s", ns.Path, err)
+			} else {
+				log.Debug("[netns] unable to intercept %s: %s", ns.Path, err)
+			}
+			i.failed[ns.Inode] = true
+			continue
+		}
+		delete(i.failed, ns.Inode)
+		i.queues[ns.Inode] = q
+		go i.forward(q.queue)
+		log.Info("[netns] intercepting %s (%d)", ns.Path, ns.Inode)
+	}
+
+	for ino, q := range i.queues {
+		if found[ino] {
+			continue
+		}
+		// the queue keeps the namespace alive, the rules are deleted with it.
+		i.release(q, false)
+		delete(i.queues, ino)
+		log.Info("[netns] %s (%d) gone", q.ns.Path, ino)
+	}
+	for ino := range i.failed {
+		if !found[ino] {
+			delete(i.failed, ino)
+		}
+	}
+}
+
+func (i *Interceptor) add(ns Namespace) (*nsQueue, error) {
+	f, err := os.Open(ns.Path)
+	if err != nil {
+		return nil, err
+	}
+	// the namespace may have been replaced since it was found.
+	if ino, err := nsInode(ns.Path); err != nil || ino != ns.Inode {
+		f.Close()
+		return nil, fmt.Errorf("namespace changed")
+	}
+	q, err := openQueue(i.queueNum, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := intercept(int(f.Fd()), i.queueNum, i.bypass); err != nil {
+		q.Detach()
+		f.Close()
+		return nil, err
+	}
+	return &nsQueue{ns: ns, file: f, queue: q}, nil
+}
+
+func (i *Interceptor) release(q *nsQueue, delRules bool) {
+	if delRules {
+		if err := remove(int(q.file.Fd())); err != nil {
+			log.Warning("[netns] %s: %s", q.ns.Path, err)
+		}
+	}
+	q.queue.Detach()
+	q.file.Close()
+}
+
+// forward delivers the packets of a namespace along with the rest, until its
+// queue is detached.
+func (i *Interceptor) forward(q queue) {
+	for pkt := range q.Packets() {
+		i.packets <- pkt
+	}
+}
@@ -0,0 +1,136 @@
+package netns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
+)
+
+func mkNs(t *testing.T, path string) uint64 {
+	os.MkdirAll(filepath.Dir(path), 0700)
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ino, _ := nsInode(path)
+	return ino
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	origProc, origDirs := procPath, mountDirs
+	defer func() { procPath, mountDirs = origProc, origDirs }()
+	procPath = filepath.Join(dir, "proc")
+	mountDirs = []string{filepath.Join(dir, "netns"), filepath.Join(dir, "missing")}
+
+	mkNs(t, filepath.Join(procPath, "self", "ns", "net"))
+	// the daemon, listed as a process too.
+	os.MkdirAll(filepath.Join(procPath, "1", "ns"), 0700)
+	os.Link(filepath.Join(procPath, "self", "ns", "net"), filepath.Join(procPath, "1", "ns", "net"))
+
+	named := mkNs(t, filepath.Join(dir, "netns", "blue"))
+	excluded := mkNs(t, filepath.Join(dir, "netns", "red"))
+	// a container, and a process of the namespace blue.
+	container := mkNs(t, filepath.Join(procPath, "100", "ns", "net"))
+	os.MkdirAll(filepath.Join(procPath, "200", "ns"), 0700)
+	os.Link(filepath.Join(dir, "netns", "blue"), filepath.Join(procPath, "200", "ns", "net"))
+	os.MkdirAll(filepath.Join(procPath, "300", "ns"), 0700)
+	os.Link(filepath.Join(dir, "netns", "red"), filepath.Join(procPath, "300", "ns", "net"))
+	mkNs(t, filepath.Join(procPath, "sys", "ns", "net"))
+
+	list, err := Discover([]string{"red"})
+	if err != nil {
+		t.Fatal("Discover() error:", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("unexpected namespaces: %v", list)
+	}
+	if list[0].Inode != named || list[0].Path != filepath.Join(dir, "netns", "blue") {
+		t.Errorf("unexpected named namespace: %v", list[0])
+	}
+	if list[1].Inode != container || list[1].Path != filepath.Join(procPath, "100", "ns", "net") {
+		t.Errorf("unexpected container namespace: %v", list[1])
+	}
+	for _, ns := range list {
+		if ns.Inode == excluded {
+			t.Error("excluded namespace discovered:", ns.Path)
+		}
+	}
+}
+
+type fakeQueue struct {
+	packets  chan netfilter.Packet
+	detached bool
+}
+
+func (q *fakeQueue) Packets() <-chan netfilter.Packet { return q.packets }
+func (q *fakeQueue) Detach() {
+	q.detached = true
+	close(q.packets)
+}
+
+func TestInterceptor(t *testing.T) {
+	dir := t.TempDir()
+	nsList := []Namespace{}
+	queues := map[string]*fakeQueue{}
+	intercepted, removed := 0, 0
+
+	origDiscover, origIntercept, origRemove, origOpen := discover, intercept, remove, openQueue
+	defer func() { discover, intercept, remove, openQueue = origDiscover, origIntercept, origRemove, origOpen }()
+	discover = func(exclude []string) ([]Namespace, error) { return nsList, nil }
+	intercept = func(fd int, num uint16, bypass bool) error {
+		if num != 10 || !bypass {
+			return fmt.Errorf("unexpected queue %d, bypass %v", num, bypass)
+		}
+		intercepted++
+		return nil
+	}
+	remove = func(fd int) error { removed++; return nil }
+	openQueue = func(num uint16, ns *os.File) (queue, error) {
+		q := &fakeQueue{packets: make(chan netfilter.Packet)}
+		queues[ns.Name()] = q
+		return q, nil
+	}
+
+	ns1 := filepath.Join(dir, "ns1")
+	ns2 := filepath.Join(dir, "ns2")
+	nsList = []Namespace{{Path: ns1, Inode: mkNs(t, ns1)}, {Path: ns2, Inode: mkNs(t, ns2)}}
+
+	i := New()
+	if err := i.SetConfig(Config{Enabled: true, Interval: "1h"}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	if i.stop != nil {
+		t.Fatal("namespaces intercepted before configuring the queue")
+	}
+	if err := i.SetQueue(10, true); err != nil {
+		t.Fatal("SetQueue() error:", err)
+	}
+	i.scan(i.stop)
+	if len(i.queues) != 2 || intercepted != 2 {
+		t.Fatalf("namespaces not intercepted: %d, %d", len(i.queues), intercepted)
+	}
+
+	go func() { queues[ns1].packets <- netfilter.Packet{NetNS: nsList[0].Inode} }()
+	if pkt := <-i.Packets(); pkt.NetNS != nsList[0].Inode {
+		t.Error("unexpected packet namespace:", pkt.NetNS)
+	}
+
+	// ns1 gone: its rules are deleted along with it.
+	nsList = nsList[1:]
+	i.scan(i.stop)
+	if len(i.queues) != 1 || !queues[ns1].detached || removed != 0 {
+		t.Errorf("namespace gone not released: %d, %v, %d", len(i.queues), queues[ns1].detached, removed)
+	}
+
+	i.Stop()
+	if len(i.queues) != 0 || !queues[ns2].detached || removed != 1 {
+		t.Errorf("namespaces not released on stop: %d, %v, %d", len(i.queues), queues[ns2].detached, removed)
+	}
+
+	if err := i.SetConfig(Config{Enabled: true, Interval: "-1s"}); err == nil {
+		t.Error("invalid interval accepted")
+	}
+}
@@ -0,0 +1,175 @@
+package pcap
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+)
+
+// Filter decides if the packets of a connection must be written to the pcap.
+type Filter func(con *conman.Connection) bool
+
+// NewFilter compiles a tcpdump-like expression, with the following primitives:
+//
+//	tcp, udp, udplite, icmp, sctp, ip, ip6
+//	[src|dst] host <ip>
+//	[src|dst] net <cidr>
+//	[src|dst] port <port>
+//
+// combined with not (!), and (&&), or (||) and parentheses.
+// An empty expression matches all the connections.
+func NewFilter(expr string) (Filter, error) {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ", "!", " ! ").Replace(expr)
+	p := &parser{tokens: strings.Fields(expr)}
+	if len(p.tokens) == 0 {
+		return func(con *conman.Connection) bool { return true }, nil
+	}
+
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.next(); tok != "" {
+		return nil, fmt.Errorf("unexpected token: %s", tok)
+	}
+	return f, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return strings.ToLower(p.tokens[p.pos])
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(con *conman.Connection) bool { return l(con) || right(con) }
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Filter, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.next()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(con *conman.Connection) bool { return l(con) && right(con) }
+	}
+	return left, nil
+}
+
+func (p *parser) not() (Filter, error) {
+	if p.peek() == "not" || p.peek() == "!" {
+		p.next()
+		f, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(con *conman.Connection) bool { return !f(con) }, nil
+	}
+	if p.peek() == "(" {
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return f, nil
+	}
+	return p.primitive()
+}
+
+func (p *parser) primitive() (Filter, error) {
+	tok := p.next()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "tcp", "udp", "udplite", "icmp", "sctp":
+		// the protocol of IPv6 connections is suffixed by 6: tcp6, udp6, ...
+		return func(con *conman.Connection) bool {
+			return strings.TrimSuffix(con.Protocol, "6") == tok
+		}, nil
+	case "ip":
+		return func(con *conman.Connection) bool { return con.DstIP.To4() != nil }, nil
+	case "ip6":
+		return func(con *conman.Connection) bool { return con.DstIP.To4() == nil }, nil
+	}
+
+	src, dst := true, true
+	if tok == "src" {
+		dst = false
+		tok = p.next()
+	} else if tok == "dst" {
+		src = false
+		tok = p.next()
+	}
+	value := p.next()
+	if value == "" {
+		return nil, fmt.Errorf("missing value of %s", tok)
+	}
+
+	switch tok {
+	case "host":
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid host: %s", value)
+		}
+		return func(con *conman.Connection) bool {
+			return (src && ip.Equal(con.SrcIP)) || (dst && ip.Equal(con.DstIP))
+		}, nil
+	case "net":
+		_, ipnet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid net: %s", value)
+		}
+		return func(con *conman.Connection) bool {
+			return (src && ipnet.Contains(con.SrcIP)) || (dst && ipnet.Contains(con.DstIP))
+		}, nil
+	case "port":
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %s", value)
+		}
+		return func(con *conman.Connection) bool {
+			return (src && uint64(con.SrcPort) == port) || (dst && uint64(con.DstPort) == port)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown primitive: %s", tok)
+}
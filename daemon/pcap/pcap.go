@@ -0,0 +1,225 @@
+// Package pcap writes the initial packets of denied connections to a rotating
+// pcap file, to give protocol-level context of the blocked connections.
+package pcap
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+const (
+	snapLen = 65535
+	// size of the header of the pcap files.
+	pcapHeaderSize = 24
+
+	defaultMaxPackets = 5
+	defaultMaxSize    = 10 // MB
+	defaultMaxFiles   = 3
+
+	// time to keep track of the packets written of a connection.
+	flowTTL = time.Minute
+)
+
+// Config holds the configuration of the pcap export.
+type Config struct {
+	// File where the packets are written, i.e.: /var/log/opensnitchd-denied.pcap
+	// Empty to disable it.
+	File string `json:"File"`
+
+	// Filter is a tcpdump-like expression to select the connections to write,
+	// i.e.: "tcp and not dst net 192.168.1.0/24"
+	Filter string `json:"Filter"`
+
+	// MaxPackets to write per connection (5 by default).
+	MaxPackets int `json:"MaxPackets"`
+
+	// MaxSize of the file in MB before rotating it (10 by default).
+	MaxSize int `json:"MaxSize"`
+
+	// MaxFiles is the number of rotated files to keep (3 by default).
+	MaxFiles int `json:"MaxFiles"`
+}
+
+type flow struct {
+	lastSeen time.Time
+	packets  int
+}
+
+// Dumper writes packets to a pcap file.
+type Dumper struct {
+	file      *os.File
+	writer    *pcapgo.Writer
+	filter    Filter
+	flows     map[string]*flow
+	lastPurge time.Time
+	cfg       Config
+	size      int64
+
+	sync.Mutex
+}
+
+// Denied is the dumper of the denied connections.
+var Denied = &Dumper{}
+
+// SetConfig applies a new configuration, closing the current file if any.
+func (d *Dumper) SetConfig(cfg Config) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.close()
+	d.flows = make(map[string]*flow)
+	d.cfg = cfg
+	if cfg.File == "" {
+		return nil
+	}
+	if d.cfg.MaxPackets <= 0 {
+		d.cfg.MaxPackets = defaultMaxPackets
+	}
+	if d.cfg.MaxSize <= 0 {
+		d.cfg.MaxSize = defaultMaxSize
+	}
+	if d.cfg.MaxFiles <= 0 {
+		d.cfg.MaxFiles = defaultMaxFiles
+	}
+
+	filter, err := NewFilter(cfg.Filter)
+	if err != nil {
+		d.cfg.File = ""
+		return fmt.Errorf("invalid pcap filter: %s", err)
+	}
+	d.filter = filter
+
+	// the capture of the previous instance or configuration is rotated, not
+	// overwritten.
+	open := d.open
+	if fi, err := os.Stat(d.cfg.File); err == nil && fi.Size() > pcapHeaderSize {
+		open = d.rotate
+	}
+	if err := open(); err != nil {
+		d.cfg.File = ""
+		return err
+	}
+	log.Info("[pcap] writing denied connections to %s", cfg.File)
+	return nil
+}
+
+// Write adds the packet of a connection to the pcap file, if it matches
+// the filter and the max packets of the connection has not been reached.
+func (d *Dumper) Write(pkt *netfilter.Packet, con *conman.Connection) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.writer == nil || pkt == nil || pkt.Packet == nil || con == nil {
+		return
+	}
+	if !d.filter(con) {
+		return
+	}
+
+	now := time.Now()
+	d.purgeFlows(now)
+	key := fmt.Sprint(con.Protocol, con.SrcIP, con.SrcPort, con.DstIP, con.DstPort)
+	f, found := d.flows[key]
+	if !found {
+		f = &flow{}
+		d.flows[key] = f
+	}
+	f.lastSeen = now
+	if f.packets >= d.cfg.MaxPackets {
+		return
+	}
+	f.packets++
+
+	data := pkt.Packet.Data()
+	if d.size+int64(len(data)) > int64(d.cfg.MaxSize)*1024*1024 {
+		if err := d.rotate(); err != nil {
+			log.Warning("[pcap] error rotating %s: %s", d.cfg.File, err)
+			return
+		}
+	}
+
+	ts := pkt.Packet.Metadata().Timestamp
+	if ts.IsZero() {
+		ts = now
+	}
+	err := d.writer.WritePacket(gopacket.CaptureInfo{
+		Timestamp:     ts,
+		CaptureLength: len(data),
+		Length:        len(data),
+	}, data)
+	if err != nil {
+		log.Warning("[pcap] error writing packet: %s", err)
+		return
+	}
+	// record header + data
+	d.size += int64(16 + len(data))
+}
+
+// Close closes the pcap file.
+func (d *Dumper) Close() {
+	d.Lock()
+	defer d.Unlock()
+	d.close()
+}
+
+func (d *Dumper) open() error {
+	f, err := os.OpenFile(d.cfg.File, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %s", d.cfg.File, err)
+	}
+	w := pcapgo.NewWriter(f)
+	// netfilter queues deliver IP packets, without the link layer.
+	if err := w.WriteFileHeader(snapLen, layers.LinkTypeRaw); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing pcap header to %s: %s", d.cfg.File, err)
+	}
+	d.file = f
+	d.writer = w
+	d.size = pcapHeaderSize
+	return nil
+}
+
+func (d *Dumper) close() {
+	if d.file != nil {
+		d.file.Close()
+	}
+	d.file = nil
+	d.writer = nil
+}
+
+// rotate renames file.pcap to file.pcap.1, file.pcap.1 to file.pcap.2, etc,
+// removing the oldest one, and opens a new file.
+func (d *Dumper) rotate() error {
+	d.close()
+	for i := d.cfg.MaxFiles; i > 0; i-- {
+		src := d.cfg.File
+		if i > 1 {
+			src = fmt.Sprint(d.cfg.File, ".", i-1)
+		}
+		if _, err := os.Stat(src); err == nil {
+			os.Rename(src, fmt.Sprint(d.cfg.File, ".", i))
+		}
+	}
+	return d.open()
+}
+
+func (d *Dumper) purgeFlows(now time.Time) {
+	if now.Sub(d.lastPurge) < flowTTL {
+		return
+	}
+	d.lastPurge = now
+	for k, f := range d.flows {
+		if now.Sub(f.lastSeen) > flowTTL {
+			delete(d.flows, k)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package pcap
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+func newConn(proto, dst string, dport uint) *conman.Connection {
+	return &conman.Connection{
+		Protocol: proto,
+		SrcIP:    net.ParseIP("192.168.1.100"),
+		SrcPort:  41234,
+		DstIP:    net.ParseIP(dst),
+		DstPort:  dport,
+	}
+}
+
+func newPacket(t *testing.T) *netfilter.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.ParseIP("192.168.1.100"), DstIP: net.ParseIP("1.1.1.1")}
+	tcp := &layers.TCP{SrcPort: 41234, DstPort: 443, SYN: true}
+	tcp.SetNetworkLayerForChecksum(ip)
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, ip, tcp); err != nil {
+		t.Fatal(err)
+	}
+	return &netfilter.Packet{Packet: gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4, gopacket.Default)}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		expr  string
+		con   *conman.Connection
+		match bool
+	}{
+		{"", newConn("tcp", "1.1.1.1", 443), true},
+		{"tcp", newConn("tcp6", "2606:4700::1111", 443), true},
+		{"udp", newConn("udplite", "1.1.1.1", 53), false},
+		{"dst port 443", newConn("tcp", "1.1.1.1", 443), true},
+		{"src port 443", newConn("tcp", "1.1.1.1", 443), false},
+		{"host 192.168.1.100", newConn("tcp", "1.1.1.1", 443), true},
+		{"tcp and not dst net 1.1.0.0/16", newConn("tcp", "1.1.1.1", 443), false},
+		{"udp or (tcp && port 443)", newConn("tcp", "1.1.1.1", 443), true},
+		{"ip6 || !port 443", newConn("tcp", "1.1.1.1", 443), false},
+	}
+	for _, test := range tests {
+		f, err := NewFilter(test.expr)
+		if err != nil {
+			t.Fatalf("NewFilter(%s) error: %s", test.expr, err)
+		}
+		if f(test.con) != test.match {
+			t.Errorf("filter %s should return %v", test.expr, test.match)
+		}
+	}
+
+	for _, expr := range []string{"tcp and", "port abc", "host 1.1.1", "(tcp", "tcp udp", "foo 1"} {
+		if _, err := NewFilter(expr); err == nil {
+			t.Errorf("NewFilter(%s) should fail", expr)
+		}
+	}
+}
+
+func TestDumper(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "denied.pcap")
+	d := &Dumper{}
+	if err := d.SetConfig(Config{File: file, Filter: "tcp", MaxPackets: 2}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+
+	pkt := newPacket(t)
+	for i := 0; i < 4; i++ {
+		d.Write(pkt, newConn("tcp", "1.1.1.1", 443))
+	}
+	d.Write(pkt, newConn("udp", "1.1.1.1", 53))
+	d.Close()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatal("invalid pcap file:", err)
+	}
+	if r.LinkType() != layers.LinkTypeRaw {
+		t.Error("invalid link type:", r.LinkType())
+	}
+	packets := 0
+	for {
+		data, _, err := r.ReadPacketData()
+		if err != nil {
+			break
+		}
+		if len(data) != len(pkt.Packet.Data()) {
+			t.Error("invalid packet length:", len(data))
+		}
+		packets++
+	}
+	if packets != 2 {
+		t.Error("invalid number of packets written:", packets)
+	}
+
+	// the capture is kept on restart, or when the configuration changes.
+	if err := d.SetConfig(Config{File: file, Filter: "tcp", MaxPackets: 2}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	d.Close()
+	if fi, err := os.Stat(file + ".1"); err != nil || fi.Size() <= pcapHeaderSize {
+		t.Error("previous capture not rotated:", err)
+	}
+	// the empty captures are not rotated.
+	if err := d.SetConfig(Config{File: file, Filter: "tcp", MaxPackets: 2}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	d.Close()
+	if _, err := os.Stat(file + ".2"); err == nil {
+		t.Error("empty capture rotated")
+	}
+}
@@ -0,0 +1,379 @@
+// Package policyaudit runs periodically a set of audits of the rules and the
+// firewall (rules never hit, allow rules too broad, unpackaged binaries
+// allowed, rules of a single address family, firewall modified externally),
+// and reports the findings.
+package policyaudit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/statistics"
+)
+
+// Audits available.
+const (
+	// enabled rules that haven't matched any connection since the daemon started.
+	CheckUnusedRules = "unused-rules"
+	// allow rules not restricted to a process, nor to a destination.
+	CheckBroadRules = "broad-rules"
+	// allow rules of binaries not installed by the package manager, or modified.
+	CheckUnpackagedBinaries = "unpackaged-binaries"
+	// the firewall rules have been deleted or modified by other program.
+	CheckFirewallModified = "firewall-modified"
+	// rules whose networks only cover IPv4 or IPv6, so the connections of
+	// dual-stack hosts over the other family are not matched.
+	CheckSingleFamily = "single-family"
+)
+
+var (
+	allChecks       = []string{CheckUnusedRules, CheckBroadRules, CheckUnpackagedBinaries, CheckFirewallModified, CheckSingleFamily}
+	defaultInterval = 24 * time.Hour
+	reportFilePerm  = os.FileMode(0600)
+)
+
+// Config holds the configuration of the audits.
+type Config struct {
+	// Interval between audits (24h by default).
+	Interval string `json:"Interval"`
+
+	// Checks to run. All of them if it's empty.
+	Checks []string `json:"Checks"`
+
+	// ReportsDir is the directory where the reports are saved, in JSON.
+	// If it's empty, the reports are only sent to the alerts and loggers.
+	ReportsDir string `json:"ReportsDir"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+// Finding is a problem found by an audit.
+type Finding struct {
+	Check       string `json:"check"`
+	Rule        string `json:"rule,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Description string `json:"description"`
+	// Message identifies the description, to translate it.
+	Message i18n.Message `json:"message"`
+}
+
+func newFinding(check, rule, path string, msg i18n.Message) Finding {
+	return Finding{
+		Check:       check,
+		Rule:        rule,
+		Path:        path,
+		Description: msg.String(),
+		Message:     msg,
+	}
+}
+
+// Report is the result of an audit.
+type Report struct {
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Checks   []string  `json:"checks"`
+	Findings []Finding `json:"findings"`
+}
+
+// Count returns the number of findings by check.
+func (r *Report) Count() map[string]int {
+	count := make(map[string]int, len(r.Checks))
+	for _, c := range r.Checks {
+		count[c] = 0
+	}
+	for _, f := range r.Findings {
+		count[f.Check]++
+	}
+	return count
+}
+
+// Message returns a summary of the report: check=findings, ...
+func (r *Report) Message() i18n.Message {
+	count := r.Count()
+	parts := make([]string, 0, len(r.Checks))
+	for _, c := range r.Checks {
+		parts = append(parts, fmt.Sprintf("%s=%d", c, count[c]))
+	}
+	return i18n.New(i18n.PolicyAudit, "findings", len(r.Findings), "checks", strings.Join(parts, ", "))
+}
+
+// String returns the summary of the report in English.
+func (r *Report) String() string {
+	return r.Message().String()
+}
+
+// Auditor runs the audits periodically.
+type Auditor struct {
+	rules    *rule.Loader
+	stats    *statistics.Statistics
+	onReport func(r *Report)
+	stop     chan struct{}
+
+	// times the firewall has been modified since the last audit.
+	fwModified []time.Time
+
+	cfg    Config
+	checks []string
+	sync.Mutex
+}
+
+// Default is the auditor of the daemon.
+var Default = New()
+
+// New returns a new auditor, disabled until it's configured.
+func New() *Auditor {
+	return &Auditor{}
+}
+
+// SetSources sets the rules and the statistics to audit.
+func (a *Auditor) SetSources(rules *rule.Loader, stats *statistics.Statistics) {
+	a.Lock()
+	defer a.Unlock()
+	a.rules = rules
+	a.stats = stats
+}
+
+// OnReport registers the function to call with the report of every audit.
+func (a *Auditor) OnReport(cb func(r *Report)) {
+	a.Lock()
+	defer a.Unlock()
+	a.onReport = cb
+}
+
+// OnFirewallModified records that the firewall rules have been modified by
+// other program.
+func (a *Auditor) OnFirewallModified() {
+	a.Lock()
+	defer a.Unlock()
+	if !a.cfg.Enabled {
+		return
+	}
+	a.fwModified = append(a.fwModified, time.Now())
+}
+
+// SetConfig applies a new configuration, restarting the audits.
+func (a *Auditor) SetConfig(cfg Config) error {
+	a.Lock()
+	defer a.Unlock()
+
+	interval := defaultInterval
+	if cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid audits interval '%s'", cfg.Interval)
+		}
+	}
+	checks := allChecks
+	if len(cfg.Checks) > 0 {
+		checks = nil
+		for _, c := range cfg.Checks {
+			if !slices.Contains(allChecks, c) {
+				return fmt.Errorf("unknown audit '%s'", c)
+			}
+			checks = append(checks, c)
+		}
+	}
+
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	a.cfg = cfg
+	a.checks = checks
+	if !cfg.Enabled {
+		a.fwModified = nil
+		return nil
+	}
+	log.Info("[policyaudit] running %s every %s", strings.Join(checks, ", "), interval)
+	a.stop = make(chan struct{})
+	go a.run(a.stop, interval)
+	return nil
+}
+
+func (a *Auditor) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			a.Run()
+		}
+	}
+}
+
+// Run runs the audits configured, and reports the findings.
+func (a *Auditor) Run() *Report {
+	a.Lock()
+	rules, stats := a.rules, a.stats
+	checks, reportsDir, cb := a.checks, a.cfg.ReportsDir, a.onReport
+	fwModified := a.fwModified
+	a.fwModified = nil
+	a.Unlock()
+
+	report := &Report{
+		Time:     time.Now(),
+		Hostname: core.GetHostname(),
+		Checks:   checks,
+		Findings: []Finding{},
+	}
+	var loaded map[string]*rule.Rule
+	if rules != nil {
+		loaded = rules.GetAll()
+	}
+	for _, check := range checks {
+		switch check {
+		case CheckUnusedRules:
+			report.Findings = append(report.Findings, unusedRules(loaded, stats)...)
+		case CheckBroadRules:
+			report.Findings = append(report.Findings, broadRules(loaded)...)
+		case CheckUnpackagedBinaries:
+			report.Findings = append(report.Findings, unpackagedBinaries(loaded)...)
+		case CheckSingleFamily:
+			report.Findings = append(report.Findings, singleFamilyRules(loaded)...)
+		case CheckFirewallModified:
+			for _, t := range fwModified {
+				report.Findings = append(report.Findings, newFinding(CheckFirewallModified, "", "",
+					i18n.New(i18n.AuditFirewallModified, "time", t.Format(time.RFC3339))))
+			}
+		}
+	}
+
+	log.Info("[policyaudit] %s", report)
+	if reportsDir != "" {
+		if err := saveReport(reportsDir, report); err != nil {
+			log.Warning("[policyaudit] error saving report: %s", err)
+		}
+	}
+	if cb != nil {
+		cb(report)
+	}
+	return report
+}
+
+// sortedNames returns the names of the rules, sorted.
+func sortedNames(rules map[string]*rule.Rule) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func unusedRules(rules map[string]*rule.Rule, stats *statistics.Statistics) []Finding {
+	if stats == nil {
+		return nil
+	}
+	var findings []Finding
+	for _, rs := range stats.SerializeRules(true) {
+		if r, found := rules[rs.Name]; !found || !r.Enabled {
+			continue
+		}
+		findings = append(findings, newFinding(CheckUnusedRules, rs.Name, "", i18n.New(i18n.AuditUnusedRule)))
+	}
+	return findings
+}
+
+// restricts returns if an operator restricts the processes and the
+// destinations a rule applies to.
+func restricts(op *rule.Operator) (process, dest bool) {
+	operand := string(op.Operand)
+	process = strings.HasPrefix(operand, "process.")
+	dest = strings.HasPrefix(operand, "dest.") || strings.HasPrefix(operand, "lists.")
+	for i := range op.List {
+		p, d := restricts(&op.List[i])
+		process = process || p
+		dest = dest || d
+	}
+	return process, dest
+}
+
+func broadRules(rules map[string]*rule.Rule) []Finding {
+	var findings []Finding
+	for _, name := range sortedNames(rules) {
+		r := rules[name]
+		if !r.Enabled || !r.Action.Allows() {
+			continue
+		}
+		if process, dest := restricts(&r.Operator); process || dest {
+			continue
+		}
+		findings = append(findings, newFinding(CheckBroadRules, name, "", i18n.New(i18n.AuditBroadRule)))
+	}
+	return findings
+}
+
+// processPaths returns the paths of the binaries an operator applies to.
+func processPaths(op *rule.Operator) []string {
+	var paths []string
+	if op.Type == rule.Simple && op.Operand == rule.OpProcessPath && filepath.IsAbs(op.Data) {
+		paths = append(paths, op.Data)
+	}
+	for i := range op.List {
+		paths = append(paths, processPaths(&op.List[i])...)
+	}
+	return paths
+}
+
+func unpackagedBinaries(rules map[string]*rule.Rule) []Finding {
+	var findings []Finding
+	for _, name := range sortedNames(rules) {
+		r := rules[name]
+		if !r.Enabled || !r.Action.Allows() {
+			continue
+		}
+		for _, path := range processPaths(&r.Operator) {
+			p := procmon.NewProcessEmpty(0, "")
+			p.Path = path
+			p.RealPath = path
+			status := procmon.Packages.Verify(p)
+			if status != procmon.PkgStatusUnpackaged && status != procmon.PkgStatusModified {
+				continue
+			}
+			findings = append(findings, newFinding(CheckUnpackagedBinaries, name, path,
+				i18n.New(i18n.AuditUnpackagedBinary, "status", status)))
+		}
+	}
+	return findings
+}
+
+func singleFamilyRules(rules map[string]*rule.Rule) []Finding {
+	var findings []Finding
+	for _, name := range sortedNames(rules) {
+		r := rules[name]
+		if !r.Enabled {
+			continue
+		}
+		if family := r.AddressFamily(); family != "" {
+			findings = append(findings, newFinding(CheckSingleFamily, name, "",
+				i18n.New(i18n.AuditSingleFamily, "family", family)))
+		}
+	}
+	return findings
+}
+
+func saveReport(dir string, report *Report) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprint("policy-audit-", report.Time.Format("20060102-150405"), ".json")
+	return os.WriteFile(filepath.Join(dir, name), raw, reportFilePerm)
+}
@@ -0,0 +1,92 @@
+package policyaudit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/statistics"
+)
+
+func TestAudit(t *testing.T) {
+	rules, err := rule.NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rules.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	add := func(name string, action rule.Action, op *rule.Operator) {
+		if err := rules.Add(rule.Create(name, "", true, false, false, action, rule.Always, op), false); err != nil {
+			t.Fatal("Error adding rule: ", err)
+		}
+	}
+	proto, _ := rule.NewOperator(rule.Simple, false, rule.OpProto, "tcp", nil)
+	curl, _ := rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/curl", nil)
+	list := []rule.Operator{
+		{Type: rule.Simple, Operand: rule.OpUserID, Data: "1000"},
+		{Type: rule.Simple, Operand: rule.OpDstPort, Data: "443"},
+	}
+	https, _ := rule.NewOperator(rule.List, false, rule.OpList, "", list)
+	subnet, _ := rule.NewOperator(rule.Network, false, rule.OpDstNetwork, "192.168.1.0/24", nil)
+	add("allow-tcp", rule.Allow, proto)
+	add("deny-tcp", rule.Deny, proto)
+	add("allow-curl", rule.Allow, curl)
+	add("allow-https", rule.Allow, https)
+	add("deny-subnet", rule.Deny, subnet)
+
+	stats := statistics.New(rules)
+	stats.OnRuleHit("allow-curl")
+
+	dir := t.TempDir()
+	a := New()
+	a.SetSources(rules, stats)
+	if err := a.SetConfig(Config{Checks: []string{"unknown"}}); err == nil {
+		t.Error("unknown audits should not be accepted")
+	}
+	if err := a.SetConfig(Config{Enabled: true, Interval: "1h", ReportsDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	defer a.SetConfig(Config{})
+
+	var reported *Report
+	a.OnReport(func(r *Report) { reported = r })
+	a.OnFirewallModified()
+
+	report := a.Run()
+	if reported != report {
+		t.Error("the report has not been sent")
+	}
+	count := report.Count()
+	if count[CheckUnusedRules] != 4 || count[CheckBroadRules] != 1 || count[CheckFirewallModified] != 1 || count[CheckSingleFamily] != 1 {
+		t.Errorf("unexpected findings: %v, %+v", count, report.Findings)
+	}
+	for _, f := range report.Findings {
+		if f.Check == CheckBroadRules && f.Rule != "allow-tcp" {
+			t.Errorf("rule reported as broad: %s", f.Rule)
+		}
+		if f.Check == CheckSingleFamily && (f.Rule != "deny-subnet" || f.Description != "the rule only applies to IPv4 connections") {
+			t.Errorf("invalid single family finding: %+v", f)
+		}
+		if f.Check == CheckUnusedRules && f.Rule == "allow-curl" {
+			t.Error("rule with hits reported as unused")
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "policy-audit-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 report saved, got %v", files)
+	}
+	raw, _ := os.ReadFile(files[0])
+	var saved Report
+	if err := json.Unmarshal(raw, &saved); err != nil || len(saved.Findings) != len(report.Findings) {
+		t.Errorf("invalid report saved: %s, %s", err, raw)
+	}
+
+	// the firewall modifications are reported once
+	if report = a.Run(); report.Count()[CheckFirewallModified] != 0 {
+		t.Error("firewall modifications reported twice")
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/core"
@@ -96,6 +97,8 @@ var (
 	EventChan      = (chan Event)(nil)
 	eventsExitChan = (chan bool)(nil)
 	auditConn      net.Conn
+	// connected is false while the connection with auditd is lost.
+	connected atomic.Bool
 	// TODO: we may need arm arch
 	rule64 = []string{"exit,always", "-F", "arch=b64", "-F", fmt.Sprint("ppid!=", ourPid), "-F", fmt.Sprint("pid!=", ourPid), "-S", "socket,connect", "-k", "opensnitch"}
 	rule32 = []string{"exit,always", "-F", "arch=b32", "-F", fmt.Sprint("ppid!=", ourPid), "-F", fmt.Sprint("pid!=", ourPid), "-S", "socketcall", "-F", "a0=1", "-k", "opensnitch"}
@@ -278,9 +281,11 @@ func Reader(r io.Reader, eventChan chan<- Event) {
 			buf, _, err := reader.ReadLine()
 			if err != nil {
 				if err == io.EOF {
+					connected.Store(false)
 					log.Error("AuditReader: auditd stopped, reconnecting in 30s %s", err)
 					if newReader, err := reconnect(); err == nil {
 						reader = bufio.NewReader(newReader)
+						connected.Store(true)
 						log.Important("Auditd reconnected, continue reading")
 					}
 					continue
@@ -315,12 +320,22 @@ func connect() (net.Conn, error) {
 	return net.Dial("unix", auditCfg.AudispSocketPath)
 }
 
+// Heartbeat returns an error if the connection with auditd has been lost.
+func Heartbeat() error {
+	if !connected.Load() {
+		return fmt.Errorf("[audit] not connected to %s", auditCfg.AudispSocketPath)
+	}
+	return nil
+}
+
 // Stop stops listening for events from auditd and delete the auditd rules.
 func Stop() {
+	connected.Store(false)
 	if auditConn != nil {
 		if err := auditConn.Close(); err != nil {
 			log.Warning("audit.Stop() error closing socket: %v", err)
 		}
+		auditConn = nil
 	}
 
 	if eventsCleaner != nil {
@@ -329,22 +344,26 @@ func Stop() {
 	if eventsExitChan != nil {
 		eventsExitChan <- true
 		close(eventsExitChan)
+		eventsExitChan = nil
 	}
 	if eventsCleanerChan != nil {
 		eventsCleanerChan <- true
 		close(eventsCleanerChan)
+		eventsCleanerChan = nil
 	}
 
 	deleteRules()
 	if EventChan != nil {
 		close(EventChan)
+		EventChan = nil
 	}
 }
 
 // Start makes a new connection to the audisp af_unix socket.
 func Start(auditOpts Config) (net.Conn, error) {
 	setConfig(auditOpts)
-	auditConn, err := connect()
+	var err error
+	auditConn, err = connect()
 	if err != nil {
 		log.Error("auditd Start() connection error %v", err)
 		deleteRules()
@@ -355,5 +374,6 @@ func Start(auditOpts Config) (net.Conn, error) {
 	eventsCleaner = time.NewTicker(time.Minute * 5)
 	eventsCleanerChan = make(chan bool)
 	eventsExitChan = make(chan bool)
+	connected.Store(true)
 	return auditConn, err
 }
@@ -5,6 +5,15 @@ import "github.com/evilsocket/opensnitch/daemon/log"
 // Config holds the configuration to customize ebpf module behaviour.
 type Config struct {
 	AudispSocketPath string `json:"AudispSocketPath"`
+
+	// WatchdogInterval is the interval to verify that the connection with
+	// auditd is alive ("30s" by default, "0s" to disable it).
+	WatchdogInterval string `json:"WatchdogInterval"`
+
+	// RecoveryInterval is the interval to try to use audit again, after falling
+	// back to /proc because the connection was lost ("5m" by default,
+	// "0s" to disable it).
+	RecoveryInterval string `json:"RecoveryInterval"`
 }
 
 func setConfig(auditOpts Config) {
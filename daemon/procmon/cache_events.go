@@ -1,7 +1,10 @@
 package procmon
 
 import (
+	"container/list"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/log"
@@ -9,26 +12,78 @@ import (
 
 var (
 	// EventsCache is the cache of processes
-	EventsCache       *EventsStore
-	eventsCacheTicker *time.Ticker
-
-	// When we receive an Exit event, we'll delete it from cache if the PID is not alive.
-	// This TTL defines how much time we retain a PID on cache, before we receive
-	// an Exit event.
-	pidTTL = 20 // seconds
+	EventsCache *EventsStore
+)
 
-	// Delay the deletion time of an item.
-	// Sometimes we may receive a connection event AFTER the Exit of the process.
-	// In these scenarios, we need to delay the deletion from cache a little bit.
-	// [exec] /bin/xxx, pid 1234
-	// [exit] /bin/xxx, pid 1234
-	// [new conn] pid 1234 -> process unknown (no /proc entry)
-	exitDelay, _ = time.ParseDuration("2s")
+// Default values of the configuration of the cache of processes.
+const (
+	defaultPidTTL              = 20 * time.Second
+	defaultExitDelay           = 2 * time.Second
+	defaultCacheTickerInterval = 10 * time.Second
 )
 
+// EventsCacheConfig holds the configuration of the cache of processes.
+type EventsCacheConfig struct {
+	// MaxEvents is the max number of processes to keep in cache.
+	// When the limit is reached, the least recently used item is evicted.
+	// 0 means no limit.
+	MaxEvents int `json:"MaxEvents"`
+
+	// PidTTL is the time we retain an exited PID in cache (20s by default).
+	PidTTL string `json:"PidTTL"`
+
+	// ExitDelay is the time we wait before deleting a PID from cache after
+	// receiving an Exit event (2s by default).
+	ExitDelay string `json:"ExitDelay"`
+
+	// TickerInterval is how often the exited PIDs older than PidTTL are
+	// deleted from cache (10s by default).
+	TickerInterval string `json:"TickerInterval"`
+}
+
+// Validate returns an error if any of the options is not valid. The empty
+// ones are valid, they're the default values.
+func (cfg EventsCacheConfig) Validate() error {
+	if cfg.MaxEvents < 0 {
+		return fmt.Errorf("invalid MaxEvents value: %d", cfg.MaxEvents)
+	}
+	for _, opt := range []struct {
+		name, value string
+		min         time.Duration
+	}{
+		{"PidTTL", cfg.PidTTL, time.Second},
+		{"ExitDelay", cfg.ExitDelay, 0},
+		{"TickerInterval", cfg.TickerInterval, time.Second},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(opt.value); err != nil || d < opt.min {
+			return fmt.Errorf("invalid %s value: %s, it must be a duration of at least %s", opt.name, opt.value, opt.min)
+		}
+	}
+	return nil
+}
+
+// EventsCacheStats holds the counters of the cache of processes.
+type EventsCacheStats struct {
+	Items     int    `json:"items"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// exited PIDs deleted after ExitDelay, and the ones deleted after PidTTL
+	// without an Exit event.
+	Exited  uint64 `json:"exited"`
+	Expired uint64 `json:"expired"`
+	// hits / (hits + misses). A low ratio on systems that execute many short
+	// lived processes may improve with a longer PidTTL or ExitDelay, or a
+	// higher MaxEvents if there are evictions.
+	HitRatio float64 `json:"hit_ratio"`
+}
+
 func init() {
 	EventsCache = NewEventsStore()
-	go monitorEventsCache()
+	go EventsCache.monitor()
 }
 
 // ProcessEvent represents an process event
@@ -49,33 +104,161 @@ type ExecEventItem struct {
 	TTL      int32
 }
 
-func (e *ExecEventItem) isValid() bool {
-	lastSeen := time.Now().Sub(
-		time.Unix(0, e.LastSeen),
-	)
-	return int(lastSeen.Seconds()) < pidTTL
+// isValid returns true if the item has been seen in the last ttl.
+func (e *ExecEventItem) isValid(ttl time.Duration) bool {
+	return time.Since(time.Unix(0, e.LastSeen)) < ttl
 }
 
 //EventsStore is the cache of exec events
 type EventsStore struct {
-	eventByPID       map[int]ExecEventItem
-	checksums        map[string]uint
-	mu               *sync.RWMutex
+	eventByPID map[int]ExecEventItem
+	checksums  map[string]uint
+	mu         *sync.RWMutex
+
+	// list of PIDs ordered by last access, used to evict the least recently
+	// used items when the cache is full.
+	lru      *list.List
+	lruByPID map[int]*list.Element
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+	exited    atomic.Uint64
+	expired   atomic.Uint64
+
+	// When we receive an Exit event, we'll delete it from cache if the PID is not alive.
+	// This TTL defines how much time we retain a PID on cache, before we receive
+	// an Exit event.
+	pidTTL time.Duration
+
+	// Delay the deletion time of an item.
+	// Sometimes we may receive a connection event AFTER the Exit of the process.
+	// In these scenarios, we need to delay the deletion from cache a little bit.
+	// [exec] /bin/xxx, pid 1234
+	// [exit] /bin/xxx, pid 1234
+	// [new conn] pid 1234 -> process unknown (no /proc entry)
+	exitDelay time.Duration
+
+	// interval to delete old items from cache. The ticker is not replaced,
+	// only reset, so it can be read without the lock.
+	ticker         *time.Ticker
+	tickerInterval time.Duration
+
+	maxEvents        int
 	checksumsEnabled bool
 }
 
 // NewEventsStore creates a new store of events.
 func NewEventsStore() *EventsStore {
-	if eventsCacheTicker != nil {
-		eventsCacheTicker.Stop()
+	return &EventsStore{
+		mu:             &sync.RWMutex{},
+		checksums:      make(map[string]uint, 2),
+		eventByPID:     make(map[int]ExecEventItem, 500),
+		lru:            list.New(),
+		lruByPID:       make(map[int]*list.Element, 500),
+		pidTTL:         defaultPidTTL,
+		exitDelay:      defaultExitDelay,
+		ticker:         time.NewTicker(defaultCacheTickerInterval),
+		tickerInterval: defaultCacheTickerInterval,
 	}
-	eventsCacheTicker = time.NewTicker(10 * time.Second)
+}
 
-	return &EventsStore{
-		mu:         &sync.RWMutex{},
-		checksums:  make(map[string]uint, 2),
-		eventByPID: make(map[int]ExecEventItem, 500),
+// SetConfig configures the limits of the cache.
+// Invalid or empty durations fall back to the default values.
+func (e *EventsStore) SetConfig(cfg EventsCacheConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.maxEvents = cfg.MaxEvents
+	e.pidTTL = defaultPidTTL
+	if ttl, err := time.ParseDuration(cfg.PidTTL); err == nil && ttl >= time.Second {
+		e.pidTTL = ttl
+	} else if cfg.PidTTL != "" {
+		log.Warning("[cache] invalid PidTTL value: %s, using default (%s)", cfg.PidTTL, e.pidTTL)
+	}
+	e.exitDelay = defaultExitDelay
+	if delay, err := time.ParseDuration(cfg.ExitDelay); err == nil && delay >= 0 {
+		e.exitDelay = delay
+	} else if cfg.ExitDelay != "" {
+		log.Warning("[cache] invalid ExitDelay value: %s, using default (%s)", cfg.ExitDelay, e.exitDelay)
+	}
+	interval := defaultCacheTickerInterval
+	if d, err := time.ParseDuration(cfg.TickerInterval); err == nil && d >= time.Second {
+		interval = d
+	} else if cfg.TickerInterval != "" {
+		log.Warning("[cache] invalid TickerInterval value: %s, using default (%s)", cfg.TickerInterval, interval)
+	}
+	if interval != e.tickerInterval {
+		e.tickerInterval = interval
+		e.ticker.Reset(interval)
+	}
+	log.Debug("[cache] EventsStore config, max events: %d, pidTTL: %s, exitDelay: %s, ticker: %s", e.maxEvents, e.pidTTL, e.exitDelay, e.tickerInterval)
+
+	for e.maxEvents > 0 && len(e.eventByPID) > e.maxEvents {
+		e.evictOldest()
+	}
+}
+
+// Config returns the configuration of the cache in use, with the default
+// values of the options not configured.
+func (e *EventsStore) Config() EventsCacheConfig {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return EventsCacheConfig{
+		MaxEvents:      e.maxEvents,
+		PidTTL:         e.pidTTL.String(),
+		ExitDelay:      e.exitDelay.String(),
+		TickerInterval: e.tickerInterval.String(),
+	}
+}
+
+// Stats returns the counters of the cache.
+func (e *EventsStore) Stats() EventsCacheStats {
+	st := EventsCacheStats{
+		Items:     e.Len(),
+		Hits:      e.hits.Load(),
+		Misses:    e.misses.Load(),
+		Evictions: e.evictions.Load(),
+		Exited:    e.exited.Load(),
+		Expired:   e.expired.Load(),
+	}
+	if lookups := st.Hits + st.Misses; lookups > 0 {
+		st.HitRatio = float64(st.Hits) / float64(lookups)
+	}
+	return st
+}
+
+// touch marks a PID as the most recently used item.
+// The caller must hold the lock.
+func (e *EventsStore) touch(pid int) {
+	if el, found := e.lruByPID[pid]; found {
+		e.lru.MoveToFront(el)
+		return
 	}
+	e.lruByPID[pid] = e.lru.PushFront(pid)
+}
+
+// deleteItem deletes a PID from the cache.
+// The caller must hold the lock.
+func (e *EventsStore) deleteItem(pid int) {
+	delete(e.eventByPID, pid)
+	if el, found := e.lruByPID[pid]; found {
+		e.lru.Remove(el)
+		delete(e.lruByPID, pid)
+	}
+}
+
+// evictOldest deletes the least recently used item of the cache.
+// The caller must hold the lock.
+func (e *EventsStore) evictOldest() {
+	el := e.lru.Back()
+	if el == nil {
+		return
+	}
+	pid := el.Value.(int)
+	log.Trace("[cache] evicting least recently used item: %d", pid)
+	e.deleteItem(pid)
+	e.evictions.Add(1)
 }
 
 // Add adds a new process to cache.
@@ -103,7 +286,7 @@ func (e *EventsStore) UpdateItem(proc *Process) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	oldItem := e.eventByPID[proc.ID]
+	oldItem, found := e.eventByPID[proc.ID]
 
 	// Avoid replacing new procs with old ones.
 	// This can occur when QueueEventsSize is > 0 and computing the checksum takes more time than expected.
@@ -112,11 +295,16 @@ func (e *EventsStore) UpdateItem(proc *Process) {
 		return
 	}
 
+	if !found && e.maxEvents > 0 && len(e.eventByPID) >= e.maxEvents {
+		e.evictOldest()
+	}
+
 	ev := ExecEventItem{
 		Proc:     *proc,
 		LastSeen: time.Now().UnixNano(),
 	}
 	e.eventByPID[proc.ID] = ev
+	e.touch(proc.ID)
 }
 
 // ReplaceItem replaces an existing process with a new one.
@@ -235,17 +423,19 @@ func (e *EventsStore) IsInStore(key int, proc *Process) (item ExecEventItem, nee
 
 // IsInStoreByPID checks if a pid exists in cache.
 func (e *EventsStore) IsInStoreByPID(key int) (item ExecEventItem, found bool) {
-	e.mu.RLock()
-	item, found = e.eventByPID[key]
-	e.mu.RUnlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
+	item, found = e.eventByPID[key]
 	if !found {
+		e.misses.Add(1)
 		return
 	}
+	e.hits.Add(1)
 
-	e.mu.Lock()
 	item.LastSeen = time.Now().UnixNano()
-	e.mu.Unlock()
+	e.eventByPID[key] = item
+	e.touch(key)
 
 	return
 }
@@ -257,21 +447,33 @@ func (e *EventsStore) Len() int {
 	return len(e.eventByPID)
 }
 
+// exitHook is called when a process exits.
+var exitHook = func(pid int) {}
+
+// OnProcessExit sets the function to call when a process exits.
+func OnProcessExit(cb func(pid int)) {
+	exitHook = cb
+}
+
 // Delete schedules an item to be deleted from cache.
 func (e *EventsStore) Delete(key int) {
+	exitHook(key)
+
 	e.mu.Lock()
 	ev, found := e.eventByPID[key]
+	delay := e.exitDelay
 	e.mu.Unlock()
 
 	if !found {
 		return
 	}
-	time.AfterFunc(exitDelay, func() {
+	time.AfterFunc(delay, func() {
 		e.mu.Lock()
 		defer e.mu.Unlock()
 		if !ev.Proc.IsAlive() {
 			log.Trace("[cache delete] deleted %d: %s", key, ev.Proc.Path)
-			delete(e.eventByPID, key)
+			e.deleteItem(key)
+			e.exited.Add(1)
 		}
 	})
 }
@@ -286,9 +488,10 @@ func (e *EventsStore) DeleteOldItems() {
 
 	log.Debug("[cache] deleting old events, total byPID: %d", len(e.eventByPID))
 	for k, item := range e.eventByPID {
-		if !item.isValid() && !item.Proc.IsAlive() {
+		if !item.isValid(e.pidTTL) && !item.Proc.IsAlive() {
 			log.Trace("[cache] deleting old item: %d", k)
-			delete(e.eventByPID, k)
+			e.deleteItem(k)
+			e.expired.Add(1)
 		}
 	}
 }
@@ -368,9 +571,12 @@ func (e *EventsStore) GetComputeChecksums() bool {
 	return e.checksumsEnabled
 }
 
-func monitorEventsCache() {
-	for {
-		<-eventsCacheTicker.C
-		EventsCache.DeleteOldItems()
+// monitor deletes the old items of the cache periodically.
+func (e *EventsStore) monitor() {
+	for range e.ticker.C {
+		e.DeleteOldItems()
+		st := e.Stats()
+		log.Debug("[cache] EventsStore stats, items: %d, hits: %d, misses: %d, evictions: %d, exited: %d, expired: %d",
+			st.Items, st.Hits, st.Misses, st.Evictions, st.Exited, st.Expired)
 	}
 }
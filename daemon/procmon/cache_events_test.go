@@ -2,6 +2,7 @@ package procmon
 
 import (
 	"os"
+	"sync"
 	"testing"
 	"time"
 )
@@ -156,7 +157,7 @@ func TestCacheEventsDeleteOldItems(t *testing.T) {
 	})
 
 	t.Run("DeleteOldItems()", func(t *testing.T) {
-		pidTTL = 1
+		evtsCache.SetConfig(EventsCacheConfig{PidTTL: "1s"})
 		time.Sleep(1 * time.Second)
 		evtsCache.DeleteOldItems()
 	})
@@ -168,6 +169,47 @@ func TestCacheEventsDeleteOldItems(t *testing.T) {
 	})
 }
 
+// Test that when the cache is full, the least recently used items are evicted,
+// and that the hits/misses counters are updated.
+func TestCacheEventsMaxEvents(t *testing.T) {
+	evtsCache := NewEventsStore()
+	evtsCache.SetConfig(EventsCacheConfig{MaxEvents: 2})
+	defer evtsCache.SetConfig(EventsCacheConfig{})
+
+	for pid := 1001; pid <= 1002; pid++ {
+		proc := NewProcessEmpty(pid, "comm")
+		proc.Path = "/tmp/test"
+		evtsCache.Add(proc)
+	}
+	// mark 1001 as recently used, so 1002 is evicted on the next Add()
+	if _, found := evtsCache.IsInStoreByPID(1001); !found {
+		t.Error("PID 1001 not found in cache")
+	}
+	proc := NewProcessEmpty(1003, "comm")
+	proc.Path = "/tmp/test"
+	evtsCache.Add(proc)
+
+	t.Run("Len()", func(t *testing.T) {
+		if evtsCache.Len() != 2 {
+			t.Error("cache Len() should be 2:", evtsCache.Len())
+		}
+	})
+	t.Run("LRU eviction", func(t *testing.T) {
+		if _, found := evtsCache.IsInStoreByPID(1002); found {
+			t.Error("PID 1002 should have been evicted")
+		}
+		if _, found := evtsCache.IsInStoreByPID(1001); !found {
+			t.Error("PID 1001 should not have been evicted")
+		}
+	})
+	t.Run("Stats()", func(t *testing.T) {
+		st := evtsCache.Stats()
+		if st.Hits != 2 || st.Misses != 1 || st.Evictions != 1 {
+			t.Errorf("invalid stats: %+v", st)
+		}
+	})
+}
+
 func BenchmarkAdd(b *testing.B) {
 	proc := NewProcessEmpty(1, "comm")
 	proc.Path = "/proc/self/exe"
@@ -193,3 +235,85 @@ func BenchmarkIsNOTInStoreByPID(b *testing.B) {
 		EventsCache.IsInStoreByPID(2)
 	}
 }
+
+func TestEventsCacheTuning(t *testing.T) {
+	evtsCache := NewEventsStore()
+	defer evtsCache.SetConfig(EventsCacheConfig{})
+
+	if err := (EventsCacheConfig{PidTTL: "500ms"}).Validate(); err == nil {
+		t.Error("PidTTL shorter than 1s accepted")
+	}
+	if err := (EventsCacheConfig{TickerInterval: "often"}).Validate(); err == nil {
+		t.Error("invalid TickerInterval accepted")
+	}
+	if err := (EventsCacheConfig{MaxEvents: -1}).Validate(); err == nil {
+		t.Error("negative MaxEvents accepted")
+	}
+	cfg := EventsCacheConfig{MaxEvents: 100, PidTTL: "1m", ExitDelay: "5s", TickerInterval: "30s"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal("Validate() error:", err)
+	}
+
+	evtsCache.SetConfig(cfg)
+	if current := evtsCache.Config(); current != (EventsCacheConfig{MaxEvents: 100, PidTTL: "1m0s", ExitDelay: "5s", TickerInterval: "30s"}) {
+		t.Errorf("invalid config in use: %+v", current)
+	}
+	evtsCache.SetConfig(EventsCacheConfig{})
+	if current := evtsCache.Config(); current != (EventsCacheConfig{PidTTL: "20s", ExitDelay: "2s", TickerInterval: "10s"}) {
+		t.Errorf("default config not restored: %+v", current)
+	}
+
+	evtsCache.IsInStoreByPID(1)
+	proc := NewProcessEmpty(1, "curl")
+	proc.Path = "/usr/bin/curl"
+	evtsCache.UpdateItem(proc)
+	evtsCache.IsInStoreByPID(1)
+	if st := evtsCache.Stats(); st.Items != 1 || st.Hits != 1 || st.Misses != 1 || st.HitRatio != 0.5 {
+		t.Errorf("invalid stats: %+v", st)
+	}
+}
+
+// Test that the cache can be tuned while it's being used, as the GUI does with
+// TUNE_PROCESS_CACHE. Run with -race.
+func TestEventsCacheTuningConcurrent(t *testing.T) {
+	evtsCache := NewEventsStore()
+	configs := []EventsCacheConfig{
+		{MaxEvents: 10, PidTTL: "1s", ExitDelay: "0s", TickerInterval: "1s"},
+		{MaxEvents: 0, PidTTL: "1m", ExitDelay: "10ms", TickerInterval: "30s"},
+		{},
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				pid := 100000 + w*100 + i%100
+				proc := NewProcessEmpty(pid, "comm")
+				proc.Path = "/tmp/test"
+				evtsCache.UpdateItem(proc)
+				evtsCache.IsInStoreByPID(pid)
+				evtsCache.Delete(pid)
+				evtsCache.DeleteOldItems()
+			}
+		}(w)
+	}
+	for i := 0; i < 20*len(configs); i++ {
+		evtsCache.SetConfig(configs[i%len(configs)])
+		evtsCache.Config()
+		evtsCache.Stats()
+	}
+	close(done)
+	wg.Wait()
+
+	if cfg := evtsCache.Config(); cfg != (EventsCacheConfig{PidTTL: "20s", ExitDelay: "2s", TickerInterval: "10s"}) {
+		t.Errorf("invalid config after tuning: %+v", cfg)
+	}
+}
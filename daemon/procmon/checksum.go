@@ -0,0 +1,242 @@
+package procmon
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"golang.org/x/sys/unix"
+)
+
+// Backends available to compute the checksums of the binaries.
+const (
+	// HashBackendGo uses the Go implementations. crypto/sha1 already uses
+	// the SHA extensions of the CPU when they're available (SHA-NI, ARMv8).
+	HashBackendGo = "go"
+	// HashBackendAFALG uses the kernel crypto API (AF_ALG sockets), which
+	// may offload the hashing to crypto accelerators.
+	HashBackendAFALG = "af_alg"
+)
+
+// HashBackend computes the checksums of the binaries.
+type HashBackend interface {
+	// Available returns an error if the backend can't be used on this system.
+	Available() error
+	// New returns a hash of the given algorithm (HashMD5, HashSHA1).
+	// If the hash implements io.Closer, it's closed after being used.
+	New(algo string) (hash.Hash, error)
+}
+
+var (
+	hashBackends = map[string]HashBackend{
+		HashBackendGo:    goHashBackend{},
+		HashBackendAFALG: afalgHashBackend{},
+	}
+	hashBackendName             = HashBackendGo
+	hashBackend     HashBackend = goHashBackend{}
+	hashBackendLock sync.RWMutex
+)
+
+// RegisterHashBackend adds a new backend, that can be selected by name.
+func RegisterHashBackend(name string, backend HashBackend) {
+	hashBackendLock.Lock()
+	defer hashBackendLock.Unlock()
+	hashBackends[name] = backend
+}
+
+// HashBackends returns the names of the backends registered.
+func HashBackends() []string {
+	hashBackendLock.RLock()
+	defer hashBackendLock.RUnlock()
+	names := make([]string, 0, len(hashBackends))
+	for name := range hashBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetHashBackend selects the backend to compute the checksums. An empty name
+// selects the default one (go).
+// If the backend is not available on this system, the current one is kept.
+func SetHashBackend(name string) error {
+	if name == "" {
+		name = HashBackendGo
+	}
+	hashBackendLock.Lock()
+	defer hashBackendLock.Unlock()
+
+	backend, found := hashBackends[name]
+	if !found {
+		return fmt.Errorf("unknown checksums backend: %s", name)
+	}
+	if err := backend.Available(); err != nil {
+		return fmt.Errorf("checksums backend %s not available: %s", name, err)
+	}
+	log.Debug("[hashing] using backend %s", name)
+	hashBackendName = name
+	hashBackend = backend
+	return nil
+}
+
+// newHash returns a hash of the algorithm, using the backend configured.
+// If the backend fails, the Go implementation is used.
+func newHash(algo string) (hash.Hash, error) {
+	hashBackendLock.RLock()
+	backend, name := hashBackend, hashBackendName
+	hashBackendLock.RUnlock()
+
+	if backend != nil {
+		h, err := backend.New(algo)
+		if err == nil {
+			return h, nil
+		}
+		log.Debug("[hashing] backend %s error: %s, using %s", name, err, HashBackendGo)
+	}
+	return goHashBackend{}.New(algo)
+}
+
+func closeHash(h hash.Hash) {
+	if c, ok := h.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+type goHashBackend struct{}
+
+func (goHashBackend) Available() error {
+	return nil
+}
+
+func (goHashBackend) New(algo string) (hash.Hash, error) {
+	switch algo {
+	case HashMD5:
+		return md5.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	}
+	return nil, fmt.Errorf("unknown hashing algorithm: %s", algo)
+}
+
+type afalgHashBackend struct{}
+
+// kernel names of the algorithms, and the size of the digest.
+var afalgAlgos = map[string]struct {
+	name string
+	size int
+}{
+	HashMD5:  {"md5", md5.Size},
+	HashSHA1: {"sha1", sha1.Size},
+}
+
+func (b afalgHashBackend) Available() error {
+	h, err := b.New(HashMD5)
+	if err != nil {
+		return err
+	}
+	closeHash(h)
+	return nil
+}
+
+func (afalgHashBackend) New(algo string) (hash.Hash, error) {
+	alg, found := afalgAlgos[algo]
+	if !found {
+		return nil, fmt.Errorf("unknown hashing algorithm: %s", algo)
+	}
+	sock, err := unix.Socket(unix.AF_ALG, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("AF_ALG socket: %s", err)
+	}
+	if err := unix.Bind(sock, &unix.SockaddrALG{Type: "hash", Name: alg.name}); err != nil {
+		unix.Close(sock)
+		return nil, fmt.Errorf("AF_ALG bind %s: %s", alg.name, err)
+	}
+	h := &afalgHash{sock: sock, op: -1, size: alg.size}
+	if err := h.accept(); err != nil {
+		unix.Close(sock)
+		return nil, err
+	}
+	return h, nil
+}
+
+// afalgHash hashes the data with the kernel crypto API. The data written to
+// the operation socket with MSG_MORE is added to the hash, until a write
+// without it.
+type afalgHash struct {
+	sock int
+	op   int
+	size int
+}
+
+func (h *afalgHash) accept() error {
+	op, _, err := unix.Accept4(h.sock, unix.SOCK_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("AF_ALG accept: %s", err)
+	}
+	h.op = op
+	return nil
+}
+
+func (h *afalgHash) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n, err := unix.SendmsgN(h.op, p[written:], nil, nil, unix.MSG_MORE)
+		if err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// Sum finalizes a copy of the hash (accept() on the operation socket clones
+// its state), so more data can be written afterwards.
+func (h *afalgHash) Sum(b []byte) []byte {
+	clone, _, err := unix.Accept4(h.op, unix.SOCK_CLOEXEC)
+	if err != nil {
+		log.Debug("[hashing] AF_ALG clone error: %s", err)
+		return b
+	}
+	defer unix.Close(clone)
+	digest := make([]byte, h.size)
+	if _, err := unix.SendmsgN(clone, nil, nil, nil, 0); err != nil {
+		log.Debug("[hashing] AF_ALG final error: %s", err)
+		return b
+	}
+	if _, err := unix.Read(clone, digest); err != nil {
+		log.Debug("[hashing] AF_ALG read error: %s", err)
+		return b
+	}
+	return append(b, digest...)
+}
+
+func (h *afalgHash) Reset() {
+	if h.op >= 0 {
+		unix.Close(h.op)
+		h.op = -1
+	}
+	if err := h.accept(); err != nil {
+		log.Debug("[hashing] AF_ALG reset error: %s", err)
+	}
+}
+
+func (h *afalgHash) Size() int {
+	return h.size
+}
+
+func (h *afalgHash) BlockSize() int {
+	return 64
+}
+
+func (h *afalgHash) Close() error {
+	if h.op >= 0 {
+		unix.Close(h.op)
+		h.op = -1
+	}
+	return unix.Close(h.sock)
+}
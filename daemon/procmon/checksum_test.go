@@ -0,0 +1,110 @@
+package procmon
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type countingBackend struct {
+	goHashBackend
+	hashes int
+}
+
+func (b *countingBackend) New(algo string) (hash.Hash, error) {
+	b.hashes++
+	return b.goHashBackend.New(algo)
+}
+
+func TestHashBackends(t *testing.T) {
+	defer SetHashBackend("")
+
+	data := []byte("opensnitch checksums test")
+	md5sum := md5.Sum(data)
+	sha1sum := sha1.Sum(data)
+	path := filepath.Join(t.TempDir(), "binary")
+	if err := os.WriteFile(path, data, 0700); err != nil {
+		t.Fatal(err)
+	}
+	checksums := func() map[string]string {
+		p := NewProcessEmpty(0, "binary")
+		p.Path = path
+		p.RealPath = path
+		p.ComputeChecksums(map[string]uint{HashMD5: 0, HashSHA1: 0})
+		return p.Checksums
+	}
+
+	if err := SetHashBackend("unknown"); err == nil {
+		t.Error("unknown backends should not be accepted")
+	}
+
+	custom := &countingBackend{}
+	RegisterHashBackend("custom", custom)
+	if err := SetHashBackend("custom"); err != nil {
+		t.Fatal(err)
+	}
+	sums := checksums()
+	if sums[HashMD5] != hex.EncodeToString(md5sum[:]) || sums[HashSHA1] != hex.EncodeToString(sha1sum[:]) {
+		t.Errorf("invalid checksums: %v", sums)
+	}
+	if custom.hashes != 2 {
+		t.Errorf("the backend configured has not been used: %d", custom.hashes)
+	}
+
+	t.Run("af_alg", func(t *testing.T) {
+		if err := SetHashBackend(HashBackendAFALG); err != nil {
+			t.Skip(err)
+		}
+		if sums := checksums(); sums[HashMD5] != hex.EncodeToString(md5sum[:]) || sums[HashSHA1] != hex.EncodeToString(sha1sum[:]) {
+			t.Errorf("invalid AF_ALG checksums: %v", sums)
+		}
+
+		// Sum() doesn't finalize the hash
+		h, _ := afalgHashBackend{}.New(HashSHA1)
+		defer closeHash(h)
+		h.Write(data[:10])
+		h.Sum(nil)
+		h.Write(data[10:])
+		if sum := h.Sum(nil); hex.EncodeToString(sum) != hex.EncodeToString(sha1sum[:]) {
+			t.Errorf("invalid AF_ALG checksum after Sum(): %x", sum)
+		}
+	})
+}
+
+// compare the backends hashing a binary of ~10MB.
+func benchmarkHashBackend(b *testing.B, name string) {
+	defer SetHashBackend("")
+	if err := SetHashBackend(name); err != nil {
+		b.Skip(err)
+	}
+	data := make([]byte, 10*1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	path := filepath.Join(b.TempDir(), "binary")
+	if err := os.WriteFile(path, data, 0700); err != nil {
+		b.Fatal(err)
+	}
+	p := NewProcessEmpty(0, "binary")
+	p.Path = path
+	p.RealPath = path
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Checksums = make(map[string]string)
+		p.ComputeChecksum(HashSHA1)
+	}
+}
+
+func BenchmarkHashBackendGo(b *testing.B) {
+	benchmarkHashBackend(b, HashBackendGo)
+}
+
+func BenchmarkHashBackendAFALG(b *testing.B) {
+	benchmarkHashBackend(b, HashBackendAFALG)
+}
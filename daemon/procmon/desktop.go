@@ -0,0 +1,277 @@
+package procmon
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// directories where the .desktop files of the applications are installed,
+// by order of preference.
+var desktopDirs = []string{
+	"/usr/local/share/applications",
+	"/usr/share/applications",
+	"/var/lib/flatpak/exports/share/applications",
+	"/var/lib/snapd/desktop/applications",
+}
+
+// interval to check if the .desktop files have changed.
+var desktopCheckInterval = 30 * time.Second
+
+// DesktopApp is the name and icon of an application, as defined in its
+// .desktop file.
+type DesktopApp struct {
+	// ID is the name of the .desktop file without the extension
+	// (org.mozilla.firefox).
+	ID   string
+	Name string
+	// Icon is the name of the icon in the icon theme, or an absolute path.
+	Icon string
+}
+
+// DesktopIndex maps processes to the applications installed.
+type DesktopIndex struct {
+	mtimes map[string]time.Time
+	// absolute paths of the binaries (Exec=/usr/bin/firefox)
+	byPath map[string]*DesktopApp
+	// binaries without path (Exec=firefox)
+	byName map[string]*DesktopApp
+	// .desktop files ids (org.mozilla.firefox)
+	byID map[string]*DesktopApp
+
+	lastCheck time.Time
+	dirs      []string
+	sync.RWMutex
+}
+
+// DesktopApps is the index of the applications installed on the system.
+var DesktopApps = NewDesktopIndex(desktopDirs)
+
+// NewDesktopIndex returns a new index of the .desktop files of the given
+// directories. The files are read on the first lookup.
+func NewDesktopIndex(dirs []string) *DesktopIndex {
+	return &DesktopIndex{
+		dirs:   dirs,
+		mtimes: make(map[string]time.Time),
+	}
+}
+
+// Lookup returns the application a process belongs to, or nil if it's not
+// found.
+// Flatpak and snap applications are resolved by their IDs, the rest of
+// processes by their path. If the process is a wrapper script or a child of
+// the application, the arguments and the tree of the process are also
+// checked.
+// The caller must hold the lock of the process.
+func (d *DesktopIndex) Lookup(p *Process) *DesktopApp {
+	d.refresh()
+
+	d.RLock()
+	defer d.RUnlock()
+
+	if id := flatpakID(p); id != "" {
+		if app, found := d.byID[id]; found {
+			return app
+		}
+	}
+	if snap := p.Env["SNAP_NAME"]; snap != "" {
+		if app, found := d.byID[snap+"_"+snap]; found {
+			return app
+		}
+	}
+	if app, found := d.byPath[p.Path]; found {
+		return app
+	}
+	if app, found := d.byName[filepath.Base(p.Path)]; found {
+		return app
+	}
+	for i := 1; i < len(p.Args); i++ {
+		if app, found := d.byPath[p.Args[i]]; found {
+			return app
+		}
+	}
+	// the first item of the tree is the process itself.
+	for i := 1; i < len(p.Tree); i++ {
+		if app, found := d.byPath[p.Tree[i].Key]; found {
+			return app
+		}
+	}
+
+	return nil
+}
+
+// refresh reloads the .desktop files if any of the directories has changed.
+func (d *DesktopIndex) refresh() {
+	d.RLock()
+	recent := time.Since(d.lastCheck) < desktopCheckInterval && d.byPath != nil
+	d.RUnlock()
+	if recent {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.lastCheck = time.Now()
+
+	changed := d.byPath == nil
+	for _, dir := range d.dirs {
+		var mtime time.Time
+		if st, err := os.Stat(dir); err == nil {
+			mtime = st.ModTime()
+		}
+		if !mtime.Equal(d.mtimes[dir]) {
+			d.mtimes[dir] = mtime
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	d.byPath = make(map[string]*DesktopApp)
+	d.byName = make(map[string]*DesktopApp)
+	d.byID = make(map[string]*DesktopApp)
+	for _, dir := range d.dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.desktop"))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			d.add(file)
+		}
+	}
+	log.Debug("[desktop] %d applications indexed", len(d.byID))
+}
+
+// add parses a .desktop file and adds it to the index.
+// The first application found for a given binary has preference.
+func (d *DesktopIndex) add(file string) {
+	entry, err := parseDesktopFile(file)
+	if err != nil || entry["Type"] != "Application" || entry["Name"] == "" || entry["Hidden"] == "true" {
+		return
+	}
+	id := strings.TrimSuffix(filepath.Base(file), ".desktop")
+	if _, found := d.byID[id]; found {
+		return
+	}
+	app := &DesktopApp{
+		ID:   id,
+		Name: entry["Name"],
+		Icon: entry["Icon"],
+	}
+	d.byID[id] = app
+	if fpID := entry["X-Flatpak"]; fpID != "" {
+		if _, found := d.byID[fpID]; !found {
+			d.byID[fpID] = app
+		}
+		return
+	}
+
+	for _, bin := range []string{execBinary(entry["TryExec"]), execBinary(entry["Exec"])} {
+		if bin == "" {
+			continue
+		}
+		if filepath.IsAbs(bin) {
+			if _, found := d.byPath[bin]; !found {
+				d.byPath[bin] = app
+			}
+			// /usr/bin/firefox is a link to /usr/lib/firefox/firefox
+			if real, err := filepath.EvalSymlinks(bin); err == nil && real != bin {
+				if _, found := d.byPath[real]; !found {
+					d.byPath[real] = app
+				}
+			}
+			continue
+		}
+		if _, found := d.byName[bin]; !found {
+			d.byName[bin] = app
+		}
+	}
+}
+
+// parseDesktopFile returns the keys of the [Desktop Entry] group of a
+// .desktop file. Localized keys are ignored.
+func parseDesktopFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entry := make(map[string]string)
+	inEntry := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			inEntry = line == "[Desktop Entry]"
+			continue
+		}
+		if !inEntry {
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found || strings.Contains(key, "[") {
+			continue
+		}
+		entry[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return entry, scanner.Err()
+}
+
+// execBinary returns the binary of an Exec key, skipping the env command
+// used to set variables: env VAR=value /usr/bin/app %u
+func execBinary(exec string) string {
+	fields := strings.Fields(exec)
+	for i := 0; i < len(fields); i++ {
+		bin := strings.Trim(fields[i], `"'`)
+		if bin == "env" || bin == "/usr/bin/env" || strings.Contains(bin, "=") {
+			continue
+		}
+		return bin
+	}
+	return ""
+}
+
+// flatpakID returns the ID of a flatpak application, from its environment
+// variables or from the .flatpak-info file of its sandbox.
+func flatpakID(p *Process) string {
+	if id := p.Env["FLATPAK_ID"]; id != "" {
+		return id
+	}
+	id, _ := parseFlatpakInfo(core.ConcatStrings(p.pathRoot, "/.flatpak-info"))
+	return id
+}
+
+func parseFlatpakInfo(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	inApp := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inApp = line == "[Application]"
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); inApp && found && key == "name" {
+			return value, nil
+		}
+	}
+
+	return "", scanner.Err()
+}
@@ -0,0 +1,72 @@
+package procmon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestDesktopIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"firefox.desktop": "[Desktop Entry]\nType=Application\nName=Firefox\nName[es]=Navegador\nIcon=firefox\nExec=/usr/lib/firefox/firefox %u\n" +
+			"[Desktop Action new-window]\nName=New Window\nExec=/usr/bin/other\n",
+		"org.gimp.GIMP.desktop": "[Desktop Entry]\nType=Application\nName=GIMP\nIcon=org.gimp.GIMP\n" +
+			"Exec=/usr/bin/flatpak run --branch=stable org.gimp.GIMP @@ %f @@\nX-Flatpak=org.gimp.GIMP\n",
+		"vlc.desktop":    "[Desktop Entry]\nType=Application\nName=VLC\nIcon=vlc\nExec=env QT_SCALE=1 vlc --started-from-file %U\n",
+		"hidden.desktop": "[Desktop Entry]\nType=Application\nName=Hidden\nExec=/usr/bin/hidden\nHidden=true\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := NewDesktopIndex([]string{dir})
+
+	newProc := func(path string, args []string, env map[string]string) *Process {
+		p := NewProcessEmpty(999999, "")
+		p.Path = path
+		p.Args = args
+		p.Env = env
+		return p
+	}
+	tests := []struct {
+		name     string
+		proc     *Process
+		expected string
+	}{
+		{"path", newProc("/usr/lib/firefox/firefox", nil, nil), "Firefox"},
+		{"flatpak", newProc("/app/bin/gimp", nil, map[string]string{"FLATPAK_ID": "org.gimp.GIMP"}), "GIMP"},
+		{"name", newProc("/usr/bin/vlc", nil, nil), "VLC"},
+		{"wrapper", newProc("/usr/bin/bash", []string{"/bin/sh", "/usr/lib/firefox/firefox"}, nil), "Firefox"},
+		{"hidden", newProc("/usr/bin/hidden", nil, nil), ""},
+		{"action", newProc("/usr/bin/other", nil, nil), ""},
+	}
+	child := newProc("/usr/lib/firefox/crashreporter", nil, nil)
+	child.Tree = []*protocol.StringInt{{Key: child.Path}, {Key: "/usr/lib/firefox/firefox"}}
+	tests = append(tests, struct {
+		name     string
+		proc     *Process
+		expected string
+	}{"tree", child, "Firefox"})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := idx.Lookup(test.proc)
+			if test.expected == "" {
+				if app != nil {
+					t.Error("unexpected application found:", app.Name)
+				}
+				return
+			}
+			if app == nil || app.Name != test.expected {
+				t.Errorf("application not found, expected %s, got %v", test.expected, app)
+			}
+		})
+	}
+	if app := idx.Lookup(newProc("/usr/lib/firefox/firefox", nil, nil)); app.Icon != "firefox" || app.ID != "firefox" {
+		t.Error("invalid application:", app)
+	}
+}
@@ -3,11 +3,8 @@ package procmon
 import (
 	"bufio"
 	"bytes"
-	"crypto/md5"
-	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
-	"hash"
 	"io"
 	"io/ioutil"
 	"os"
@@ -114,9 +111,13 @@ func (p *Process) GetDetails() error {
 	p.ReadCmdline()
 	p.ReadComm()
 	p.ReadCwd()
+	if p.StartTicks == 0 {
+		p.StartTicks, _ = readStartTicks(p.ID)
+	}
 
 	// we need to load the env variables now, in order to be used with the rules.
 	p.ReadEnv()
+	p.ReadNamespaces()
 
 	return nil
 }
@@ -178,6 +179,7 @@ func (p *Process) ReadCwd() error {
 }
 
 // ReadEnv reads and parses the environment variables of a process.
+// Only the variables configured with SetEnvConfig() are saved.
 func (p *Process) ReadEnv() {
 	raw, err := ioutil.ReadFile(p.pathEnviron)
 	if err != nil {
@@ -193,6 +195,9 @@ func (p *Process) ReadEnv() {
 		}
 
 		key := s[:idx]
+		if !captureEnvVar(key) {
+			continue
+		}
 		val := s[idx+1 : len(s)]
 		env[key] = val
 	}
@@ -547,15 +552,12 @@ func (p *Process) ComputeChecksum(algo string) {
 	//   The real path is /proc/<pid>/root/<path-to-the-binary>
 	paths := []string{p.pathExe, p.RealPath, p.Path}
 
-	var h hash.Hash
-	if algo == HashMD5 {
-		h = md5.New()
-	} else if algo == HashSHA1 {
-		h = sha1.New()
-	} else {
-		log.Debug("Unknown hashing algorithm: %s", algo)
+	h, err := newHash(algo)
+	if err != nil {
+		log.Debug("%s", err)
 		return
 	}
+	defer closeHash(h)
 
 	i := uint8(0)
 	for i = 0; i < 3; i++ {
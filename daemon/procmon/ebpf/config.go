@@ -1,6 +1,9 @@
 package ebpf
 
-import "github.com/evilsocket/opensnitch/daemon/log"
+import (
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
 
 // Config holds the configuration to customize ebpf module behaviour.
 type Config struct {
@@ -21,6 +24,29 @@ type Config struct {
 	// events, they'll be queued. Once the daemon queue is full, kernel ebpf program
 	// will have to wait/discard new events. (XXX: citation/testing needed).
 	QueueEventsSize int `json:"QueueEventsSize"`
+
+	// MaxQueueEventsSize is the maximum size the queue of events can grow to,
+	// when events from the kernel are being lost. 0 disables it.
+	MaxQueueEventsSize int `json:"MaxQueueEventsSize"`
+
+	// EventsBuffer is the type of buffer used to receive the events from
+	// kernel space: "ringbuf" (kernel >= 5.8), "perf", or "auto" (default),
+	// to use ring buffers if the kernel supports them.
+	EventsBuffer string `json:"EventsBuffer"`
+
+	// WatchdogInterval is the interval to verify that the hooks are still
+	// attached and working ("30s" by default, "0s" to disable it).
+	WatchdogInterval string `json:"WatchdogInterval"`
+
+	// RecoveryInterval is the interval to try to use eBPF again, after falling
+	// back to /proc because the hooks stopped working ("5m" by default,
+	// "0s" to disable it).
+	RecoveryInterval string `json:"RecoveryInterval"`
+
+	// BTFPath is the BTF (vmlinux) used to load the CO-RE modules (only
+	// opensnitch-procs.o), on kernels not exposing it under
+	// /sys/kernel/btf/vmlinux. Empty by default.
+	BTFPath string `json:"BTFPath"`
 }
 
 func setConfig(ebpfOpts Config) {
@@ -37,5 +63,11 @@ func setConfig(ebpfOpts Config) {
 		ebpfCfg.RingBuffSize = 64
 	}
 
+	if err := core.SetKernelBTF(ebpfCfg.BTFPath); err != nil {
+		log.Warning("%s", err)
+	} else if ebpfCfg.BTFPath == "" && !core.HasKernelBTF() {
+		log.Debug("[eBPF] kernel BTF not available, CO-RE modules won't be loaded")
+	}
+
 	log.Debug("[eBPF] config loaded: %v", ebpfCfg)
 }
@@ -10,7 +10,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
@@ -85,7 +84,7 @@ type Error struct {
 
 var (
 	m            *ebpf.Collection
-	eventsReader *ringbuf.Reader
+	eventsReader eventsBuffer
 	ebpfCfg      Config
 	lock         = sync.RWMutex{}
 	mapSize      = uint(12000)
@@ -193,6 +192,7 @@ func Start(ebpfOpts Config) *Error {
 		log.Debug("opening kprobe: %s", err)
 	}
 	hooks = append(hooks, kp)
+	attachSockOwners(m)
 
 	ebpfMaps = map[string]*ebpfMapsForProto{
 		"tcp":  {bpfMap: ebpfMod.TCPMap},
@@ -217,6 +217,7 @@ func Start(ebpfOpts Config) *Error {
 
 	go monitorCache()
 	go monitorMaps()
+	go monitorPressure()
 	go monitorLocalAddresses()
 	go monitorAlreadyEstablished()
 
@@ -281,6 +282,7 @@ func Stop() {
 		}
 	}
 	hooks = []link.Link{}
+	sockOwnerMap = nil
 	collectionMaps = make([]*ebpf.Collection, 0)
 
 	if m != nil {
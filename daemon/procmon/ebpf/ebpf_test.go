@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 	"unsafe"
@@ -1675,3 +1676,76 @@ int main() {
 		t.Log("gethostbyname event not found in ringbuf")
 	}
 }
+
+func TestInetSockSetStateIntegration(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("requires root to load eBPF modules")
+	}
+
+	modulePath := findEbpfModule("opensnitch.o")
+	if modulePath == "" {
+		t.Skip("opensnitch.o not found - build with: make -C ebpf_prog")
+	}
+
+	spec, err := ebpf.LoadCollectionSpec(modulePath)
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+	coll, err := ebpf.NewCollection(spec)
+	if err != nil {
+		t.Fatalf("failed to load collection: %v", err)
+	}
+	defer coll.Close()
+
+	prog := coll.Programs[sockOwnerProgName]
+	owners := coll.Maps[sockOwnerMapName]
+	if prog == nil || owners == nil {
+		t.Skip("opensnitch.o built without the sockets owners tracepoint")
+	}
+	tp, err := link.Tracepoint("sock", "inet_sock_set_state", prog, nil)
+	if err != nil {
+		t.Skipf("tracepoint sock/inet_sock_set_state not available: %v", err)
+	}
+	defer tp.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start local server: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}()
+
+	conn, err := net.DialTimeout("tcp", listener.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	f, err := conn.(*net.TCPConn).File()
+	if err != nil {
+		t.Fatalf("failed to get the socket file: %v", err)
+	}
+	defer f.Close()
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		t.Fatalf("failed to stat the socket: %v", err)
+	}
+
+	var value networkEventT
+	key := uint64(st.Ino)
+	if err := owners.Lookup(&key, &value); err != nil {
+		t.Fatalf("socket inode %d not found in %s: %v", st.Ino, sockOwnerMapName, err)
+	}
+	if value.Pid != uint64(os.Getpid()) {
+		t.Errorf("PID mismatch: got %d, want %d", value.Pid, os.Getpid())
+	}
+	if value.UID != uint64(os.Getuid()) {
+		t.Errorf("UID mismatch: got %d, want %d", value.UID, os.Getuid())
+	}
+}
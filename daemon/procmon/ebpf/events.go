@@ -5,10 +5,10 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/ringbuf"
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/procmon"
@@ -93,7 +93,8 @@ func initEventsStreamer() *Error {
 		}
 	}
 
-	eventsColl, err := core.LoadEbpfModule("opensnitch-procs.o", ebpfCfg.ModulesPath)
+	module := eventsModules[eventsBufferType(ebpfCfg.EventsBuffer)]
+	eventsColl, err := core.LoadEbpfModule(module, ebpfCfg.ModulesPath)
 	if err != nil {
 		return &Error{err, EventsNotAvailable}
 	}
@@ -150,30 +151,28 @@ func initEventsStreamer() *Error {
 
 func initPerfMap(events *ebpf.Map) error {
 	var err error
-	eventsReader, err = ringbuf.NewReader(events)
+	eventsReader, err = newEventsBuffer(events)
 	if err != nil {
 		return err
 	}
-	perfChan := make(chan []byte, ebpfCfg.QueueEventsSize)
-
-	for i := 0; i < ebpfCfg.EventsWorkers; i++ {
-		go streamEventsWorker(i, perfChan, kernelEvents)
-	}
-
-	go func(perfChan chan []byte, rd *ringbuf.Reader) {
-		// drainPerfChain drains the channel if it gets full.
-		// This can happen when there're too much events and the queue size is
-		// not big enough to hold all the events.
-		// To prevent blocking the ringbuf channel, we need to discard the events
-		// from the queue, so the ringbuf can continue sending events from kernel space.
-		drainPerfChan := func() {
-			for {
-				select {
-				case <-perfChan:
-				default:
-					return
-				}
+	log.Debug("[eBPF events] receiving events via %s", events.Type())
+	queue := newEventsQueue(ebpfCfg.EventsWorkers, ebpfCfg.QueueEventsSize)
+	queueSize.Store(int64(queue.size()))
+
+	eventsStreaming.Store(true)
+	go func(queue *eventsQueue, rd eventsBuffer) {
+		// growQueue replaces the queue by a bigger one, with its own
+		// workers. The workers of the previous queue exit once it's empty.
+		growQueue := func() bool {
+			size := nextQueueSize(queue.size(), ebpfCfg.MaxQueueEventsSize)
+			if size <= queue.size() {
+				return false
 			}
+			log.Info("[eBPF] events queue full (%d/%d), increasing its size to %d", queue.len(), queue.size(), size)
+			queue.close()
+			queue = newEventsQueue(ebpfCfg.EventsWorkers, size)
+			queueSize.Store(int64(size))
+			return true
 		}
 
 		for {
@@ -181,27 +180,40 @@ func initPerfMap(events *ebpf.Map) error {
 			case <-ctxTasks.Done():
 				goto Exit
 			default:
-				record, err := rd.Read()
+				sample, err := rd.Read()
 				if err != nil {
-					if errors.Is(err, ringbuf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						goto Exit
 					}
 					// XXX: control max errors?
+					readErrors.Add(1)
 					log.Trace("[eBPF events] reader error: %s", err)
 					continue
 				}
+				if sample == nil {
+					continue
+				}
+				eventsRead.Add(1)
 
-				select {
-				case perfChan <- record.RawSample:
-				default:
-					log.Debug("[eBPF] events queue full (%d/%d), ringbuf record lost. Try increasing the queue size and/or the number of workers", len(perfChan), cap(perfChan))
-					drainPerfChan()
+				if queue.push(sample) {
+					continue
+				}
+				if growQueue() && queue.push(sample) {
+					continue
 				}
+				// The queue gets full when there're too much events and the
+				// queue size is not big enough to hold all the events.
+				// To prevent blocking the ring buffer, we need to discard the
+				// events from the queue, so the kernel can continue sending events.
+				log.Debug("[eBPF] events queue full (%d/%d), event lost. Try increasing the queue size and/or the number of workers", queue.len(), queue.size())
+				eventsLost.Add(1 + queue.drain(sample))
 			}
 		}
 	Exit:
+		queue.close()
+		eventsStreaming.Store(false)
 		log.Debug("[eBPF events] reader closed")
-	}(perfChan, eventsReader)
+	}(queue, eventsReader)
 
 	return nil
 }
@@ -336,6 +348,7 @@ func getProcDetails(event *execEvent, proc *procmon.Process) {
 	proc.BuildTree()
 	proc.ReadCwd()
 	proc.ReadEnv()
+	proc.ReadNamespaces()
 }
 
 func processExitEvent(event *execEvent) {
@@ -0,0 +1,180 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	ebpffeatures "github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// types of buffers to receive the events from kernel space.
+const (
+	EventsBufferAuto    = "auto"
+	EventsBufferRingBuf = "ringbuf"
+	EventsBufferPerf    = "perf"
+)
+
+// modules of each type of buffer.
+var eventsModules = map[string]string{
+	EventsBufferRingBuf: "opensnitch-procs.o",
+	EventsBufferPerf:    "opensnitch-procs-perf.o",
+}
+
+// eventsBuffer reads the events sent from kernel space.
+type eventsBuffer interface {
+	// Read returns the next event, or nil if the event has been lost.
+	Read() ([]byte, error)
+	Close() error
+	BufferSize() int
+	AvailableBytes() int
+}
+
+// ringbufReader reads the events from a ring buffer (kernel >= 5.8).
+// The events are received in the same order they were sent.
+type ringbufReader struct {
+	*ringbuf.Reader
+}
+
+func (r ringbufReader) Read() ([]byte, error) {
+	record, err := r.Reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return record.RawSample, nil
+}
+
+// perfReader reads the events from the perf buffers of every CPU.
+type perfReader struct {
+	*perf.Reader
+}
+
+func (r perfReader) Read() ([]byte, error) {
+	record, err := r.Reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if record.LostSamples > 0 {
+		eventsLost.Add(record.LostSamples)
+		return nil, nil
+	}
+	return record.RawSample, nil
+}
+
+// the perf reader doesn't expose the unread bytes of every CPU.
+func (r perfReader) AvailableBytes() int {
+	return 0
+}
+
+// eventsBufferType returns the type of buffer to use, ring buffers if the
+// kernel supports them, unless a type has been configured.
+func eventsBufferType(configured string) string {
+	switch configured {
+	case EventsBufferRingBuf, EventsBufferPerf:
+		return configured
+	case "", EventsBufferAuto:
+	default:
+		log.Warning("[eBPF] invalid EventsBuffer %s, using %s", configured, EventsBufferAuto)
+	}
+	if err := ebpffeatures.HaveMapType(ebpf.RingBuf); err != nil {
+		log.Debug("[eBPF] ring buffers not supported, using perf buffers: %s", err)
+		return EventsBufferPerf
+	}
+	return EventsBufferRingBuf
+}
+
+func newEventsBuffer(events *ebpf.Map) (eventsBuffer, error) {
+	switch events.Type() {
+	case ebpf.RingBuf:
+		rd, err := ringbuf.NewReader(events)
+		if err != nil {
+			return nil, err
+		}
+		return ringbufReader{rd}, nil
+	case ebpf.PerfEventArray:
+		rd, err := perf.NewReader(events, ebpfCfg.RingBuffSize*os.Getpagesize())
+		if err != nil {
+			return nil, err
+		}
+		return perfReader{rd}, nil
+	}
+	return nil, fmt.Errorf("[eBPF events] unsupported events map type: %s", events.Type())
+}
+
+// eventsQueue dispatches the events to the workers. The events of a PID are
+// always dispatched to the same worker, so they're processed in the same
+// order they were received (exec -> exit), no matter how many workers there
+// are.
+type eventsQueue struct {
+	chans []chan []byte
+}
+
+// newEventsQueue creates a queue per worker, and starts the workers.
+func newEventsQueue(workers, size int) *eventsQueue {
+	// with unbuffered queues, an event would be lost whenever its worker is
+	// busy, instead of when all of them are busy.
+	if size < 1 {
+		size = 1
+	}
+	q := &eventsQueue{chans: make([]chan []byte, workers)}
+	for i := range q.chans {
+		q.chans[i] = make(chan []byte, size)
+		go streamEventsWorker(i, q.chans[i], kernelEvents)
+	}
+	return q
+}
+
+func (q *eventsQueue) shard(raw []byte) chan []byte {
+	// execEvent.PID
+	if len(raw) < 12 || len(q.chans) == 1 {
+		return q.chans[0]
+	}
+	pid := hostByteOrder.Uint32(raw[8:12])
+	return q.chans[int(pid%uint32(len(q.chans)))]
+}
+
+// push queues an event, and returns false if the queue of its worker is full.
+func (q *eventsQueue) push(raw []byte) bool {
+	select {
+	case q.shard(raw) <- raw:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain discards the events queued for the worker of the given event, and
+// returns how many have been discarded.
+func (q *eventsQueue) drain(raw []byte) (discarded uint64) {
+	chn := q.shard(raw)
+	for {
+		select {
+		case <-chn:
+			discarded++
+		default:
+			return
+		}
+	}
+}
+
+// size returns the size of the queue of every worker.
+func (q *eventsQueue) size() int {
+	return cap(q.chans[0])
+}
+
+func (q *eventsQueue) len() (n int) {
+	for _, c := range q.chans {
+		n += len(c)
+	}
+	return n
+}
+
+// close stops the workers once they've processed the queued events.
+func (q *eventsQueue) close() {
+	for _, c := range q.chans {
+		close(c)
+	}
+}
@@ -0,0 +1,54 @@
+package ebpf
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestEventsQueueShard(t *testing.T) {
+	hostByteOrder = binary.NativeEndian
+	q := &eventsQueue{chans: make([]chan []byte, 4)}
+	for i := range q.chans {
+		q.chans[i] = make(chan []byte, 2)
+	}
+
+	event := func(pid uint32) []byte {
+		raw := make([]byte, 16)
+		hostByteOrder.PutUint32(raw[8:12], pid)
+		return raw
+	}
+
+	// the events of a PID must be dispatched to the same worker.
+	if !q.push(event(6)) || !q.push(event(6)) {
+		t.Fatal("events not queued")
+	}
+	if len(q.chans[2]) != 2 {
+		t.Errorf("events of the same PID dispatched to different workers: %d", len(q.chans[2]))
+	}
+	if q.push(event(10)) {
+		t.Error("event queued in a full queue")
+	}
+	if !q.push(event(7)) {
+		t.Error("event not queued to the worker of its PID")
+	}
+	if q.len() != 3 {
+		t.Errorf("unexpected queue len: %d", q.len())
+	}
+	if discarded := q.drain(event(2)); discarded != 2 {
+		t.Errorf("unexpected events discarded: %d", discarded)
+	}
+	if q.len() != 1 {
+		t.Errorf("events of other workers discarded, len: %d", q.len())
+	}
+}
+
+func TestEventsBufferType(t *testing.T) {
+	for _, typ := range []string{EventsBufferRingBuf, EventsBufferPerf} {
+		if got := eventsBufferType(typ); got != typ {
+			t.Errorf("configured %s, got %s", typ, got)
+		}
+		if _, found := eventsModules[typ]; !found {
+			t.Errorf("module of %s not defined", typ)
+		}
+	}
+}
@@ -79,21 +79,8 @@ func getPidFromEbpf(proto string, srcPort uint, srcIP net.IP, dstIP net.IP, dstP
 	}
 
 	var value networkEventT
-	var key []byte
 	var isIP4 bool = (proto == "tcp") || (proto == "udp") || (proto == "udplite")
-
-	if isIP4 {
-		key = make([]byte, 12)
-		copy(key[2:6], dstIP)
-		binary.BigEndian.PutUint16(key[6:8], uint16(dstPort))
-		copy(key[8:12], srcIP)
-	} else { // IPv6
-		key = make([]byte, 36)
-		copy(key[2:18], dstIP)
-		binary.BigEndian.PutUint16(key[18:20], uint16(dstPort))
-		copy(key[20:36], srcIP)
-	}
-	hostByteOrder.PutUint16(key[0:2], uint16(srcPort))
+	key := newMapKey(isIP4, srcPort, srcIP, dstIP, dstPort)
 
 	k := core.ConcatStrings(
 		proto,
@@ -169,6 +156,23 @@ func getPidFromEbpf(proto string, srcPort uint, srcIP net.IP, dstIP net.IP, dstP
 	return
 }
 
+// newMapKey builds the key of a connection, as defined in opensnitch.c
+func newMapKey(isIP4 bool, srcPort uint, srcIP net.IP, dstIP net.IP, dstPort uint) (key []byte) {
+	if isIP4 {
+		key = make([]byte, 12)
+		copy(key[2:6], dstIP)
+		binary.BigEndian.PutUint16(key[6:8], uint16(dstPort))
+		copy(key[8:12], srcIP)
+	} else { // IPv6
+		key = make([]byte, 36)
+		copy(key[2:18], dstIP)
+		binary.BigEndian.PutUint16(key[18:20], uint16(dstPort))
+		copy(key[20:36], srcIP)
+	}
+	hostByteOrder.PutUint16(key[0:2], uint16(srcPort))
+	return key
+}
+
 // Check if the PID of the connection is in the cache.
 func isPIDinEventsCache(pid, uid int) (proc *procmon.Process) {
 	if ev, found := procmon.EventsCache.IsInStoreByPID(pid); found {
@@ -0,0 +1,69 @@
+package ebpf
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"golang.org/x/sys/unix"
+)
+
+// names of the connections maps in the module, by protocol.
+var mapNames = map[string]string{
+	"tcp":  "tcpMap",
+	"udp":  "udpMap",
+	"tcp6": "tcpv6Map",
+	"udp6": "udpv6Map",
+}
+
+// MapFiles returns a duplicate of the file descriptors of the connections
+// maps, to hand them over to a new instance of the daemon.
+// The files are named after the maps of the module (tcpMap, udpMap, ...).
+func MapFiles() map[string]*os.File {
+	files := make(map[string]*os.File)
+	if !IsRunning() {
+		return files
+	}
+
+	lock.RLock()
+	defer lock.RUnlock()
+	for proto, mfp := range ebpfMaps {
+		name, found := mapNames[proto]
+		if !found || mfp.bpfMap == nil {
+			continue
+		}
+		fd, err := unix.FcntlInt(uintptr(mfp.bpfMap.FD()), unix.F_DUPFD_CLOEXEC, 0)
+		if err != nil {
+			log.Debug("[eBPF] handover, unable to duplicate map %s: %s", name, err)
+			continue
+		}
+		files[name] = os.NewFile(uintptr(fd), name)
+	}
+	return files
+}
+
+// InheritMaps configures the maps handed over by a previous instance of the
+// daemon, to be reused when loading the module, keeping the connections
+// already tracked.
+func InheritMaps(files map[string]*os.File) error {
+	maps := make(map[string]*ebpf.Map)
+	for name, f := range files {
+		fd, err := unix.FcntlInt(f.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("[eBPF] unable to duplicate inherited map %s: %s", name, err)
+		}
+		m, err := ebpf.NewMapFromFD(fd)
+		if err != nil {
+			unix.Close(fd)
+			return fmt.Errorf("[eBPF] invalid inherited map %s: %s", name, err)
+		}
+		maps[name] = m
+	}
+	if len(maps) > 0 {
+		core.SetMapReplacements("opensnitch.o", maps)
+	}
+	return nil
+}
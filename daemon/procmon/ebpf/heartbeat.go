@@ -0,0 +1,134 @@
+package ebpf
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+var (
+	// number of times the maps are checked, waiting for the heartbeat.
+	heartbeatRetries = 10
+	heartbeatWait    = 20 * time.Millisecond
+
+	// number of records read from the events ring buffer, and if the reader
+	// is running.
+	eventsRead      atomic.Uint64
+	eventsStreaming atomic.Bool
+)
+
+// IsRunning returns if the eBPF hooks are loaded.
+func IsRunning() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return running
+}
+
+// Heartbeat verifies that the hooks are still attached, and that the maps are
+// being updated, by opening a TCP and a UDP connection to localhost and
+// looking for them in the maps.
+func Heartbeat() error {
+	if !IsRunning() {
+		return fmt.Errorf("[eBPF] not running")
+	}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("[eBPF] heartbeat, unable to listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+
+	for _, proto := range []string{"tcp", "udp"} {
+		if err := heartbeat(proto, ln.Addr().String()); err != nil {
+			return err
+		}
+	}
+	return eventsHeartbeat()
+}
+
+// eventsHeartbeat verifies that the events of opensnitch-procs.o are still
+// being delivered, by executing a process and waiting for a new record on the
+// ring buffer.
+func eventsHeartbeat() error {
+	if !eventsStreaming.Load() {
+		return nil
+	}
+	before := eventsRead.Load()
+	if err := exec.Command("true").Run(); err != nil {
+		log.Debug("[eBPF] heartbeat, unable to verify the events: %s", err)
+		return nil
+	}
+	for i := 0; i < heartbeatRetries; i++ {
+		if eventsRead.Load() != before {
+			return nil
+		}
+		time.Sleep(heartbeatWait)
+	}
+
+	return fmt.Errorf("[eBPF] heartbeat, no events received from the ring buffer, stalled?")
+}
+
+func heartbeat(proto, addr string) error {
+	conn, err := net.DialTimeout(proto+"4", addr, time.Second)
+	if err != nil {
+		return fmt.Errorf("[eBPF] heartbeat, unable to connect (%s): %s", proto, err)
+	}
+	defer conn.Close()
+	// udp_sendmsg() is only called when sending data.
+	if proto == "udp" {
+		conn.Write([]byte{0})
+	}
+
+	var src, dst *net.TCPAddr
+	if proto == "tcp" {
+		src, dst = conn.LocalAddr().(*net.TCPAddr), conn.RemoteAddr().(*net.TCPAddr)
+	} else {
+		lAddr, rAddr := conn.LocalAddr().(*net.UDPAddr), conn.RemoteAddr().(*net.UDPAddr)
+		src, dst = &net.TCPAddr{IP: lAddr.IP, Port: lAddr.Port}, &net.TCPAddr{IP: rAddr.IP, Port: rAddr.Port}
+	}
+	key := newMapKey(true, uint(src.Port), src.IP.To4(), dst.IP.To4(), uint(dst.Port))
+
+	lock.RLock()
+	bpfMap, found := ebpfMaps[proto]
+	lock.RUnlock()
+	if !found || bpfMap.bpfMap == nil {
+		return fmt.Errorf("[eBPF] heartbeat, %s map not loaded", proto)
+	}
+
+	var value networkEventT
+	for i := 0; i < heartbeatRetries; i++ {
+		if err = bpfMap.bpfMap.Lookup(&key, &value); err == nil {
+			deleteEbpfEntry(proto, key)
+			if int(value.Pid) != os.Getpid() {
+				return fmt.Errorf("[eBPF] heartbeat, invalid %s pid: %d", proto, value.Pid)
+			}
+			return nil
+		}
+		time.Sleep(heartbeatWait)
+	}
+
+	return fmt.Errorf("[eBPF] heartbeat, %s connection not found in the map, hooks detached?", proto)
+}
+
+// DispatchErrorEvent logs an error and sends it to the listeners of the
+// kernel events.
+func DispatchErrorEvent(what string) {
+	dispatchErrorEvent(what)
+}
+
+// DispatchEvent logs a message and sends it to the listeners of the kernel
+// events.
+func DispatchEvent(what string) {
+	log.Important("%s", what)
+	dispatchEvent(what)
+}
@@ -24,6 +24,7 @@ func monitorMaps() {
 				// bpftool still counts them.
 				if items := getItems(name, name == "tcp6" || name == "udp6"); items > 500 {
 					deleted := deleteOldItems(name, name == "tcp6" || name == "udp6", items/2)
+					addEvicted(name, deleted)
 					log.Debug("[ebpf] old items deleted: %d", deleted)
 				}
 			}
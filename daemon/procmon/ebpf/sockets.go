@@ -0,0 +1,68 @@
+package ebpf
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+// names of the definitions of opensnitch.o to learn the owners of the sockets.
+// They're optional, so the modules of previous versions can still be loaded.
+const (
+	sockOwnerProgName = "tracepoint__sock_inet_sock_set_state"
+	sockOwnerMapName  = "sockOwnerMap"
+)
+
+var (
+	// owners of the TCP sockets by inode, filled by the tracepoint
+	// sock/inet_sock_set_state. nil if it's not available.
+	sockOwnerMap *ebpf.Map
+
+	sockOwnerHits   atomic.Uint64
+	sockOwnerMisses atomic.Uint64
+)
+
+// attachSockOwners attaches the tracepoint that learns the process that
+// connects every TCP socket, if the module and the kernel (>= 4.16) support it.
+func attachSockOwners(coll *ebpf.Collection) {
+	prog, found := coll.Programs[sockOwnerProgName]
+	owners, mapFound := coll.Maps[sockOwnerMapName]
+	if !found || !mapFound {
+		log.Debug("[eBPF] sockets owners not available in opensnitch.o, falling back to /proc")
+		return
+	}
+	tp, err := link.Tracepoint("sock", "inet_sock_set_state", prog, nil)
+	if err != nil {
+		log.Debug("[eBPF] sockets owners tracepoint not available, falling back to /proc: %s", err)
+		return
+	}
+	hooks = append(hooks, tp)
+	sockOwnerMap = owners
+}
+
+// GetPidByInode returns the process that connected a TCP socket, as learnt
+// from the kernel, without scanning the descriptors of every process.
+// It returns nil if it's not known.
+func GetPidByInode(inode int) *procmon.Process {
+	lock.RLock()
+	owners := sockOwnerMap
+	lock.RUnlock()
+	if owners == nil || inode <= 0 {
+		return nil
+	}
+
+	var value networkEventT
+	key := uint64(inode)
+	if err := owners.Lookup(&key, &value); err != nil {
+		sockOwnerMisses.Add(1)
+		return nil
+	}
+	sockOwnerHits.Add(1)
+	proc := findConnProcess(&value, fmt.Sprint("inode ", inode))
+	log.Debug("[ebpf conn] socket inode %d owned by %d (%s)", inode, proc.ID, proc.Path)
+	return proc
+}
@@ -0,0 +1,161 @@
+package ebpf
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// MapStats holds the occupancy of a connections map.
+type MapStats struct {
+	Name       string `json:"name"`
+	Entries    uint   `json:"entries"`
+	MaxEntries uint32 `json:"max_entries"`
+	// number of entries deleted to keep the map below its capacity.
+	Evicted uint64 `json:"evicted"`
+}
+
+// Stats holds the counters of the connections maps and of the events ring
+// buffer.
+type Stats struct {
+	Maps []MapStats `json:"maps"`
+	// records read from the ring buffer, and lost because the queue of
+	// events was full.
+	EventsRead   uint64 `json:"events_read"`
+	EventsLost   uint64 `json:"events_lost"`
+	ReadErrors   uint64 `json:"read_errors"`
+	QueueSize    int    `json:"queue_size"`
+	RingBufUsed  int    `json:"ringbuf_used"`
+	RingBufSize  int    `json:"ringbuf_size"`
+	EventsActive bool   `json:"events_active"`
+	// connections whose process has been found, or not, by the inode of
+	// their socket, instead of scanning /proc.
+	SockOwnerHits   uint64 `json:"sock_owner_hits"`
+	SockOwnerMisses uint64 `json:"sock_owner_misses"`
+}
+
+var (
+	// interval to verify if the maps are full, or if events are being lost.
+	pressureInterval = 30 * time.Second
+	// percentage of a map or of the ring buffer from which it's considered full.
+	pressureThreshold = 90
+
+	eventsLost atomic.Uint64
+	readErrors atomic.Uint64
+	// current size of the queue of events, it grows when events are lost.
+	queueSize atomic.Int64
+
+	evicted   = make(map[string]uint64)
+	evictedMu sync.Mutex
+)
+
+func addEvicted(proto string, n uint) {
+	evictedMu.Lock()
+	evicted[proto] += uint64(n)
+	evictedMu.Unlock()
+}
+
+// GetStats returns the occupancy of the connections maps, and the counters of
+// the events.
+func GetStats() Stats {
+	stats := Stats{
+		EventsRead:   eventsRead.Load(),
+		EventsLost:   eventsLost.Load(),
+		ReadErrors:   readErrors.Load(),
+		QueueSize:    int(queueSize.Load()),
+		EventsActive: eventsStreaming.Load(),
+
+		SockOwnerHits:   sockOwnerHits.Load(),
+		SockOwnerMisses: sockOwnerMisses.Load(),
+	}
+	if !IsRunning() {
+		return stats
+	}
+
+	lock.RLock()
+	defer lock.RUnlock()
+	evictedMu.Lock()
+	for proto, mfp := range ebpfMaps {
+		if mfp.bpfMap == nil {
+			continue
+		}
+		stats.Maps = append(stats.Maps, MapStats{
+			Name:       proto,
+			Entries:    getItems(proto, proto == "tcp6" || proto == "udp6"),
+			MaxEntries: mfp.bpfMap.MaxEntries(),
+			Evicted:    evicted[proto],
+		})
+	}
+	evictedMu.Unlock()
+	sort.Slice(stats.Maps, func(i, j int) bool {
+		return stats.Maps[i].Name < stats.Maps[j].Name
+	})
+	if eventsReader != nil && stats.EventsActive {
+		stats.RingBufSize = eventsReader.BufferSize()
+		stats.RingBufUsed = eventsReader.AvailableBytes()
+	}
+	return stats
+}
+
+// pressureWarnings compares the current stats with the previous ones, and
+// returns the reasons why processes may be reported as unknown.
+func pressureWarnings(prev, cur Stats) []string {
+	warnings := make([]string, 0)
+	if cur.EventsLost > prev.EventsLost {
+		warnings = append(warnings, fmt.Sprintf("%d process events lost (queue size: %d), some connections may be reported as unknown processes. Try increasing Ebpf.MaxQueueEventsSize and/or Ebpf.EventsWorkers",
+			cur.EventsLost-prev.EventsLost, cur.QueueSize))
+	}
+	for _, m := range cur.Maps {
+		if m.MaxEntries > 0 && m.Entries*100 >= uint(m.MaxEntries)*uint(pressureThreshold) {
+			warnings = append(warnings, fmt.Sprintf("%s map almost full (%d/%d)", m.Name, m.Entries, m.MaxEntries))
+		}
+	}
+	if cur.RingBufSize > 0 && cur.RingBufUsed*100 >= cur.RingBufSize*pressureThreshold {
+		warnings = append(warnings, fmt.Sprintf("events ring buffer almost full (%d/%d bytes)", cur.RingBufUsed, cur.RingBufSize))
+	}
+	return warnings
+}
+
+// monitorPressure periodically verifies if the maps or the ring buffer are
+// full, or if events are being lost, and notifies it.
+func monitorPressure() {
+	ticker := time.NewTicker(pressureInterval)
+	defer ticker.Stop()
+
+	prev := GetStats()
+	for {
+		select {
+		case <-ctxTasks.Done():
+			goto Exit
+		case <-ticker.C:
+			cur := GetStats()
+			for _, w := range pressureWarnings(prev, cur) {
+				log.Warning("[eBPF] %s", w)
+				dispatchEvent(fmt.Sprint("[eBPF] ", w))
+			}
+			prev = cur
+		}
+	}
+Exit:
+	log.Debug("[eBPF] monitorPressure exited")
+}
+
+// nextQueueSize returns the new size of the queue of events, when events are
+// being lost, or the current size if it can't grow anymore.
+func nextQueueSize(cur, max int) int {
+	if cur >= max {
+		return cur
+	}
+	next := cur * 2
+	if next < 64 {
+		next = 64
+	}
+	if next > max {
+		next = max
+	}
+	return next
+}
@@ -0,0 +1,57 @@
+package ebpf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNextQueueSize(t *testing.T) {
+	tests := []struct {
+		cur, max, want int
+	}{
+		{0, 4096, 64},
+		{64, 4096, 128},
+		{3000, 4096, 4096},
+		{4096, 4096, 4096},
+		// growing disabled
+		{0, 0, 0},
+		{128, 0, 128},
+	}
+	for _, tt := range tests {
+		if got := nextQueueSize(tt.cur, tt.max); got != tt.want {
+			t.Errorf("nextQueueSize(%d, %d) = %d, want %d", tt.cur, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestPressureWarnings(t *testing.T) {
+	prev := Stats{EventsLost: 10}
+	cur := Stats{
+		EventsLost: 15,
+		QueueSize:  64,
+		Maps: []MapStats{
+			{Name: "tcp", Entries: 11000, MaxEntries: 12000},
+			{Name: "udp", Entries: 100, MaxEntries: 12000},
+		},
+		RingBufSize: 1000,
+		RingBufUsed: 950,
+	}
+
+	warnings := pressureWarnings(prev, cur)
+	if len(warnings) != 3 {
+		t.Fatalf("expected 3 warnings, got: %v", warnings)
+	}
+	if !strings.HasPrefix(warnings[0], "5 process events lost") {
+		t.Errorf("unexpected lost events warning: %s", warnings[0])
+	}
+	if !strings.HasPrefix(warnings[1], "tcp map almost full") {
+		t.Errorf("unexpected map warning: %s", warnings[1])
+	}
+	if !strings.HasPrefix(warnings[2], "events ring buffer almost full") {
+		t.Errorf("unexpected ring buffer warning: %s", warnings[2])
+	}
+
+	if warnings := pressureWarnings(cur, Stats{EventsLost: 15}); len(warnings) != 0 {
+		t.Errorf("expected no warnings, got: %v", warnings)
+	}
+}
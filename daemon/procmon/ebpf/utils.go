@@ -119,7 +119,9 @@ func deleteOldItems(proto string, isIPv6 bool, maxToDelete uint) (deleted uint)
 			break
 		}
 		log.Trace("[eBPF] DELETE ITEMS %s: %+v -> %+v", proto, lookupKey, nextKey)
-		prot.bpfMap.Delete(&lookupKey)
+		if prot.bpfMap.Delete(&lookupKey) == nil {
+			deleted++
+		}
 		lookupKey = nextKey
 	}
 
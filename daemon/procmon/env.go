@@ -0,0 +1,66 @@
+package procmon
+
+import (
+	"strings"
+	"sync"
+)
+
+// EnvConfig holds the environment variables of the processes to capture.
+type EnvConfig struct {
+	// Vars is the list of variables to capture, to be used with the
+	// process.env.<NAME> rules operand, and sent to the GUI.
+	// Names ending in * match by prefix (KUBERNETES_*).
+	// An empty list captures all the variables.
+	Vars []string `json:"Vars"`
+}
+
+type envFilter struct {
+	names    map[string]struct{}
+	prefixes []string
+	sync.RWMutex
+}
+
+var procEnv envFilter
+
+// SetEnvConfig configures the environment variables to capture from the
+// processes. It only applies to the processes read from now on.
+func SetEnvConfig(cfg EnvConfig) {
+	procEnv.Lock()
+	defer procEnv.Unlock()
+
+	procEnv.names = nil
+	procEnv.prefixes = nil
+	for _, name := range cfg.Vars {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if procEnv.names == nil {
+			procEnv.names = make(map[string]struct{})
+		}
+		if strings.HasSuffix(name, "*") {
+			procEnv.prefixes = append(procEnv.prefixes, strings.TrimSuffix(name, "*"))
+			continue
+		}
+		procEnv.names[name] = struct{}{}
+	}
+}
+
+// captureEnvVar returns true if the given variable must be captured.
+func captureEnvVar(name string) bool {
+	procEnv.RLock()
+	defer procEnv.RUnlock()
+
+	if procEnv.names == nil {
+		return true
+	}
+	if _, found := procEnv.names[name]; found {
+		return true
+	}
+	for _, prefix := range procEnv.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package procmon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrPIDReused is returned when the PID of a process belongs to other process.
+var ErrPIDReused = errors.New("the PID has been reused by other process")
+
+// parseStartTicks returns the starttime field of /proc/<pid>/stat (22nd),
+// given the fields after the comm name. 0 if it can't be parsed.
+func parseStartTicks(fields []byte) uint64 {
+	f := bytes.Fields(fields)
+	// the fields start with the 3rd one (state)
+	if len(f) < 20 {
+		return 0
+	}
+	ticks, _ := strconv.ParseUint(string(f[19]), 10, 64)
+	return ticks
+}
+
+// readStartTicks returns the start time of a process, from /proc/<pid>/stat
+func readStartTicks(pid int) (uint64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprint("/proc/", pid, "/stat"))
+	if err != nil {
+		return 0, err
+	}
+	// the comm name may contain ')'
+	if i := bytes.LastIndexByte(data, ')'); i >= 0 {
+		if ticks := parseStartTicks(data[i+1:]); ticks > 0 {
+			return ticks, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid /proc/%d/stat", pid)
+}
+
+// Kill sends a signal to the process, only if the PID still belongs to it:
+// the PID is pinned with a pidfd, and the start time of the process running
+// with that PID is compared with the start time of this process.
+func (p *Process) Kill(sig syscall.Signal) error {
+	if p.ID <= 1 || p.ID == os.Getpid() {
+		return fmt.Errorf("refusing to kill the process %d", p.ID)
+	}
+	if p.StartTicks == 0 {
+		return fmt.Errorf("unknown start time of the process %d", p.ID)
+	}
+
+	pidfd, err := unix.PidfdOpen(p.ID, 0)
+	if errors.Is(err, syscall.ENOSYS) {
+		pidfd = -1
+	} else if err != nil {
+		return err
+	} else {
+		defer unix.Close(pidfd)
+	}
+
+	// from here on, the PID can't be reused while the pidfd is open.
+	ticks, err := readStartTicks(p.ID)
+	if err != nil {
+		return err
+	}
+	if ticks != p.StartTicks {
+		return ErrPIDReused
+	}
+
+	if pidfd < 0 {
+		// kernels < 5.3
+		return syscall.Kill(p.ID, sig)
+	}
+	return unix.PidfdSendSignal(pidfd, sig, nil, 0)
+}
@@ -0,0 +1,44 @@
+package procmon
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKill(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip("unable to start the process:", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	defer cmd.Process.Kill()
+
+	p := NewProcessEmpty(cmd.Process.Pid, "sleep")
+	ticks, err := readStartTicks(p.ID)
+	if err != nil || ticks == 0 {
+		t.Fatal("readStartTicks() error:", ticks, err)
+	}
+
+	p.StartTicks = ticks + 1
+	if err := p.Kill(syscall.SIGTERM); !errors.Is(err, ErrPIDReused) {
+		t.Fatal("a process with a different start time should not be killed:", err)
+	}
+
+	p.StartTicks = ticks
+	if err := p.Kill(syscall.SIGTERM); err != nil {
+		t.Fatal("Kill() error:", err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Error("the process has not been killed")
+	}
+
+	if err := NewProcessEmpty(1, "init").Kill(syscall.SIGTERM); err == nil {
+		t.Error("init should never be killed")
+	}
+}
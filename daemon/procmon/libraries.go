@@ -0,0 +1,130 @@
+package procmon
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// captureLibraries enables reading the shared libraries mapped by the
+	// processes, to use the operand process.library.path.
+	captureLibraries atomic.Bool
+	// librariesLock guards the libraries of the processes. It's not the lock
+	// of the process, held by who serializes it.
+	librariesLock sync.Mutex
+)
+
+// SetCaptureLibraries enables or disables reading the shared libraries mapped
+// by the processes.
+func SetCaptureLibraries(enabled bool) {
+	captureLibraries.Store(enabled)
+}
+
+// CaptureLibraries returns true if the shared libraries mapped by the
+// processes are read.
+func CaptureLibraries() bool {
+	return captureLibraries.Load()
+}
+
+// mapsState identifies a version of the mappings of a process.
+// The files of /proc don't have size, and their modification time doesn't
+// change, so the size of the address space of the process (/proc/<pid>/statm)
+// is compared too: it changes when the process maps or unmaps a library.
+type mapsState struct {
+	mtime  time.Time
+	size   int64
+	vmSize string
+}
+
+func (p *Process) readMapsState() (mapsState, error) {
+	fi, err := os.Stat(p.pathMaps)
+	if err != nil {
+		return mapsState{}, err
+	}
+	state := mapsState{mtime: fi.ModTime(), size: fi.Size()}
+	if raw, err := ioutil.ReadFile(p.pathStatm); err == nil {
+		if fields := bytes.Fields(raw); len(fields) > 0 {
+			state.vmSize = string(fields[0])
+		}
+	}
+	return state, nil
+}
+
+// GetLibraries returns the paths of the shared libraries mapped by the
+// process, sorted: the ones it's linked with, preloaded (LD_PRELOAD) or
+// loaded with dlopen().
+// They're read from /proc/<pid>/maps, and read again when the mappings of the
+// process change (see mapsState), so the libraries loaded after the first
+// connection of the process are seen too. If the process has exited, the
+// last libraries read are returned.
+// They're not read if reading the libraries is disabled.
+func (p *Process) GetLibraries() []string {
+	librariesLock.Lock()
+	defer librariesLock.Unlock()
+	if p.librariesSet || !captureLibraries.Load() {
+		return p.Libraries
+	}
+	state, err := p.readMapsState()
+	if err != nil || (p.librariesState != nil && *p.librariesState == state) {
+		return p.Libraries
+	}
+	p.Libraries = readLibraries(p.pathMaps)
+	p.librariesState = &state
+	return p.Libraries
+}
+
+// SetLibraries sets the libraries of a process, not read from this system.
+func (p *Process) SetLibraries(libs []string) {
+	librariesLock.Lock()
+	defer librariesLock.Unlock()
+	p.Libraries = libs
+	p.librariesSet = true
+}
+
+// readLibraries returns the shared libraries of a maps file:
+// 7f2c1a000000-7f2c1a022000 r--p 00000000 08:01 1835058  /usr/lib/x86_64-linux-gnu/libc.so.6
+func readLibraries(pathMaps string) []string {
+	f, err := os.Open(pathMaps)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var libs []string
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// the path is the last field, and the only one with slashes.
+		line := scanner.Text()
+		start := strings.IndexByte(line, '/')
+		if start == -1 {
+			continue
+		}
+		path := strings.TrimSuffix(line[start:], " (deleted)")
+		if !isSharedLibrary(path) {
+			continue
+		}
+		if _, found := seen[path]; found {
+			continue
+		}
+		seen[path] = struct{}{}
+		libs = append(libs, path)
+	}
+	sort.Strings(libs)
+	return libs
+}
+
+// isSharedLibrary returns true for the files named *.so or *.so.<version>.
+func isSharedLibrary(path string) bool {
+	name := filepath.Base(path)
+	pos := strings.Index(name, ".so")
+	return pos > 0 && (len(name) == pos+3 || name[pos+3] == '.')
+}
@@ -0,0 +1,72 @@
+package procmon
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetLibraries(t *testing.T) {
+	proc := NewProcessEmpty(1234, "curl")
+	proc.pathMaps = "testdata/proc-maps"
+
+	SetCaptureLibraries(false)
+	if libs := proc.GetLibraries(); libs != nil {
+		t.Fatal("libraries read with the capture disabled:", libs)
+	}
+
+	SetCaptureLibraries(true)
+	defer SetCaptureLibraries(false)
+	expected := []string{
+		"/tmp/my libs/libhook.so",
+		"/usr/lib/x86_64-linux-gnu/ld-linux-x86-64.so.2",
+		"/usr/lib/x86_64-linux-gnu/libc.so.6",
+		"/usr/lib/x86_64-linux-gnu/libproxychains.so.4",
+	}
+	if libs := proc.GetLibraries(); !reflect.DeepEqual(libs, expected) {
+		t.Errorf("invalid libraries, expected %v, got %v", expected, libs)
+	}
+
+	// they're read again only when the mappings change.
+	maps := filepath.Join(t.TempDir(), "maps")
+	raw, _ := os.ReadFile("testdata/proc-maps")
+	os.WriteFile(maps, raw, 0600)
+	proc = NewProcessEmpty(1234, "curl")
+	proc.pathMaps = maps
+	if libs := proc.GetLibraries(); !reflect.DeepEqual(libs, expected) {
+		t.Fatalf("invalid libraries, expected %v, got %v", expected, libs)
+	}
+	dlopen := "7f2c1b000000-7f2c1b022000 r--p 00000000 08:01 1835060  /usr/lib/x86_64-linux-gnu/libnss_dns.so.2\n"
+	f, _ := os.OpenFile(maps, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(dlopen)
+	f.Close()
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(maps, later, later)
+	if libs := proc.GetLibraries(); len(libs) != len(expected)+1 {
+		t.Error("libraries not read again after loading a new one:", libs)
+	}
+
+	// the last ones are kept once the process exits.
+	proc.pathMaps = "testdata/not-found"
+	if libs := proc.GetLibraries(); len(libs) != len(expected)+1 {
+		t.Error("libraries lost after the process exited:", libs)
+	}
+}
+
+func TestIsSharedLibrary(t *testing.T) {
+	for path, expected := range map[string]bool{
+		"/usr/lib/libc.so.6":                   true,
+		"/usr/lib/libhook.so":                  true,
+		"/usr/lib/ld-linux-x86-64.so.2":        true,
+		"/usr/lib/gconv/gconv-modules.cache":   false,
+		"/usr/bin/curl":                        false,
+		"/usr/lib/python3/dist-packages/.so":   false,
+		"/home/user/solutions/notes.something": false,
+	} {
+		if isSharedLibrary(path) != expected {
+			t.Errorf("isSharedLibrary(%s) != %v", path, expected)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package monitor
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
+	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	netlinkProcmon "github.com/evilsocket/opensnitch/daemon/netlink/procmon"
 	"github.com/evilsocket/opensnitch/daemon/procmon"
@@ -14,6 +17,8 @@ var (
 	cacheMonitorsRunning  = false
 	netlinkProcmonRunning = false
 	ctx, cancelTasks      = context.WithCancel(context.Background())
+	// guards the proc monitors, started and stopped by the supervisor too.
+	procMonitorsLock sync.Mutex
 )
 
 // List of errors that this package may return.
@@ -32,6 +37,8 @@ type Error struct {
 }
 
 func startProcMonitors() {
+	procMonitorsLock.Lock()
+	defer procMonitorsLock.Unlock()
 	if netlinkProcmonRunning == false {
 		ctx, cancelTasks = context.WithCancel(context.Background())
 		for i := 0; i < 4; i++ {
@@ -43,6 +50,8 @@ func startProcMonitors() {
 }
 
 func stopProcMonitors() {
+	procMonitorsLock.Lock()
+	defer procMonitorsLock.Unlock()
 	if netlinkProcmonRunning {
 		cancelTasks()
 		netlinkProcmonRunning = false
@@ -73,6 +82,7 @@ func ReconfigureMonitorMethod(newMonitorMethod string, ebpfCfg ebpf.Config, audi
 // End stops the way of parsing new connections.
 func End() {
 	log.Debug("monitor.End()")
+	stopSupervisor()
 	stopProcMonitors()
 	if procmon.MethodIsAudit() {
 		audit.Stop()
@@ -90,10 +100,18 @@ func Init(ebpfCfg ebpf.Config, auditCfg audit.Config) (errm *Error) {
 		cacheMonitorsRunning = true
 	}
 
-	if procmon.MethodIsEbpf() {
+	if procmon.MethodIsEbpf() && !core.EbpfAllowedByLockdown() {
+		// loading the modules would fail with cryptic permission errors.
+		errm.What = EbpfErr
+		errm.Msg = fmt.Errorf("kernel lockdown is in %s mode (secure boot enabled: %v), eBPF can't be used. Falling back to /proc as process monitor method",
+			core.LockdownConfidentiality, core.IsSecureBootEnabled())
+		log.Warning("%s", errm.Msg)
+
+	} else if procmon.MethodIsEbpf() {
 		err := ebpf.Start(ebpfCfg)
 		if err == nil {
 			log.Info("Process monitor method ebpf")
+			startSupervisor(newEbpfMethod(ebpfCfg))
 			return errm
 		}
 		// ebpf main module loaded, we can use ebpf
@@ -103,9 +121,10 @@ func Init(ebpfCfg ebpf.Config, auditCfg audit.Config) (errm *Error) {
 			errm.What = EbpfEventsErr
 			errm.Msg = err.Msg
 			log.Info("Process monitor method ebpf")
-			log.Warning("opensnitch-procs.o not available: %s", err.Msg)
+			log.Warning("process events module not available: %s", err.Msg)
 
 			startProcMonitors()
+			startSupervisor(newEbpfMethod(ebpfCfg))
 			return errm
 		}
 		// we need to stop this method even if it has failed to start, in order to clean up the kprobes
@@ -113,6 +132,9 @@ func Init(ebpfCfg ebpf.Config, auditCfg audit.Config) (errm *Error) {
 		ebpf.Stop()
 		errm.What = EbpfErr
 		errm.Msg = err.Msg
+		if lockdown := core.GetLockdownMode(); lockdown != core.LockdownNone {
+			errm.Msg = fmt.Errorf("%s (kernel lockdown mode: %s, secure boot enabled: %v)", err.Msg, lockdown, core.IsSecureBootEnabled())
+		}
 		log.Warning("error starting ebpf monitor method: %v", err)
 
 	} else if procmon.MethodIsAudit() {
@@ -120,7 +142,8 @@ func Init(ebpfCfg ebpf.Config, auditCfg audit.Config) (errm *Error) {
 		if err == nil {
 			log.Info("Process monitor method audit")
 			go audit.Reader(auditConn, (chan<- audit.Event)(audit.EventChan))
-			return &Error{AuditdErr, err}
+			startSupervisor(newAuditMethod(auditCfg))
+			return errm
 		}
 		errm.What = AuditdErr
 		errm.Msg = err
@@ -0,0 +1,225 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/procmon/audit"
+	"github.com/evilsocket/opensnitch/daemon/procmon/ebpf"
+)
+
+var (
+	defaultWatchdogInterval = 30 * time.Second
+	defaultRecoveryInterval = 5 * time.Minute
+
+	// consecutive failed heartbeats before trying to restart the method.
+	maxHeartbeatFailures = 3
+
+	supervisorCancel = context.CancelFunc(nil)
+	// closed when the supervisor exits.
+	supervisorDone = chan struct{}(nil)
+	supervisorLock sync.Mutex
+
+	// overridden in tests
+	fallback   = fallbackToProc
+	restore    = restoreMethod
+	resumeProc = startProcMonitors
+)
+
+// monitorMethod holds the functions to verify the health of a process monitor
+// method, and to restart it.
+type monitorMethod struct {
+	heartbeat func() error
+	restart   func() error
+	stop      func()
+	name      string
+	interval  time.Duration
+	recovery  time.Duration
+}
+
+func newEbpfMethod(cfg ebpf.Config) *monitorMethod {
+	return &monitorMethod{
+		name:      procmon.MethodEbpf,
+		interval:  parseInterval("WatchdogInterval", cfg.WatchdogInterval, defaultWatchdogInterval),
+		recovery:  parseInterval("RecoveryInterval", cfg.RecoveryInterval, defaultRecoveryInterval),
+		heartbeat: ebpf.Heartbeat,
+		stop:      ebpf.Stop,
+		restart: func() error {
+			ebpf.Stop()
+			err := ebpf.Start(cfg)
+			if err == nil {
+				stopProcMonitors()
+				return nil
+			}
+			if err.What == ebpf.EventsNotAvailable {
+				startProcMonitors()
+				return nil
+			}
+			return err.Msg
+		},
+	}
+}
+
+func newAuditMethod(cfg audit.Config) *monitorMethod {
+	return &monitorMethod{
+		name:      procmon.MethodAudit,
+		interval:  parseInterval("WatchdogInterval", cfg.WatchdogInterval, defaultWatchdogInterval),
+		recovery:  parseInterval("RecoveryInterval", cfg.RecoveryInterval, defaultRecoveryInterval),
+		heartbeat: audit.Heartbeat,
+		stop:      audit.Stop,
+		restart: func() error {
+			audit.Stop()
+			auditConn, err := audit.Start(cfg)
+			if err != nil {
+				return err
+			}
+			go audit.Reader(auditConn, (chan<- audit.Event)(audit.EventChan))
+			stopProcMonitors()
+			return nil
+		},
+	}
+}
+
+func parseInterval(name, value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	tm, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warning("[supervisor] invalid %s %s, using default: %s", name, value, def)
+		return def
+	}
+	return tm
+}
+
+// startSupervisor periodically verifies that the process monitor method is
+// working. If it stops working, it tries to restart it, and if it fails, falls
+// back to the proc monitor method. While using /proc, it'll try to use the
+// configured method again every recovery interval.
+func startSupervisor(m *monitorMethod) {
+	stopSupervisor()
+
+	if m.interval <= 0 {
+		log.Debug("[%s] supervisor disabled", m.name)
+		return
+	}
+
+	supervisorLock.Lock()
+	defer supervisorLock.Unlock()
+	var sctx context.Context
+	sctx, supervisorCancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	supervisorDone = done
+	go func() {
+		supervise(sctx, m)
+		close(done)
+	}()
+}
+
+// stopSupervisor stops the supervisor, and waits for it to exit, so it
+// doesn't start or stop the monitors afterwards.
+func stopSupervisor() {
+	supervisorLock.Lock()
+	cancel, done := supervisorCancel, supervisorDone
+	supervisorCancel, supervisorDone = nil, nil
+	supervisorLock.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func supervise(sctx context.Context, m *monitorMethod) {
+	for {
+		err := watch(sctx, m)
+		if err == nil {
+			break
+		}
+		fallback(m, fmt.Sprintf("[%s] the process monitor method stopped working, and couldn't be restarted (%s). Using /proc as process monitor method", m.name, err))
+
+		if m.recovery <= 0 || !waitRecovery(sctx, m) {
+			break
+		}
+		restore(m)
+	}
+	log.Debug("[%s] supervisor exited", m.name)
+}
+
+// watch checks the health of the method until it stops working and can't be
+// restarted, in which case the error is returned.
+// It returns nil if the supervisor has been stopped.
+func watch(sctx context.Context, m *monitorMethod) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	failures := 0
+
+	for {
+		select {
+		case <-sctx.Done():
+			return nil
+		case <-ticker.C:
+			err := m.heartbeat()
+			if err == nil {
+				failures = 0
+				continue
+			}
+			failures++
+			log.Debug("[%s] supervisor, heartbeat failed (%d/%d): %s", m.name, failures, maxHeartbeatFailures, err)
+			if failures < maxHeartbeatFailures {
+				continue
+			}
+
+			log.Warning("[%s] supervisor, method not working, restarting it: %s", m.name, err)
+			if err = m.restart(); err == nil {
+				err = m.heartbeat()
+			}
+			if err != nil {
+				return err
+			}
+			failures = 0
+			ebpf.DispatchErrorEvent(fmt.Sprintf("[%s] the process monitor method stopped working and has been restarted", m.name))
+		}
+	}
+}
+
+// waitRecovery tries to start the method again every recovery interval.
+// It returns true once the method is working again. Restarting the method
+// stops the proc monitors, so they're started again if it fails.
+func waitRecovery(sctx context.Context, m *monitorMethod) bool {
+	ticker := time.NewTicker(m.recovery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sctx.Done():
+			return false
+		case <-ticker.C:
+			err := m.restart()
+			if err == nil {
+				err = m.heartbeat()
+			}
+			if err == nil {
+				return true
+			}
+			m.stop()
+			resumeProc()
+			log.Debug("[%s] supervisor, method still not working: %s", m.name, err)
+		}
+	}
+}
+
+func fallbackToProc(m *monitorMethod, reason string) {
+	m.stop()
+	startProcMonitors()
+	procmon.SetMonitorMethod(procmon.MethodProc)
+	ebpf.DispatchErrorEvent(reason)
+}
+
+func restoreMethod(m *monitorMethod) {
+	procmon.SetMonitorMethod(m.name)
+	ebpf.DispatchEvent(fmt.Sprintf("[%s] the process monitor method is working again, and it's being used instead of /proc", m.name))
+}
@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	origFallback, origRestore, origResume := fallback, restore, resumeProc
+	defer func() {
+		fallback, restore, resumeProc = origFallback, origRestore, origResume
+	}()
+	var resumed atomic.Int32
+	resumeProc = func() { resumed.Add(1) }
+
+	tests := []struct {
+		name         string
+		restartFixes bool
+		recovers     bool
+		restarted    int32
+		fellBack     int32
+		restored     int32
+	}{
+		{"restart", true, false, 1, 0, 0},
+		{"fallback", false, false, 1, 1, 0},
+		{"recovery", false, true, 2, 1, 1},
+		{"recovery failed", false, false, -1, 1, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var working, restarted, fellBack, restored atomic.Int32
+			m := &monitorMethod{
+				name:     "test",
+				interval: time.Millisecond,
+				heartbeat: func() error {
+					if working.Load() == 1 {
+						return nil
+					}
+					return fmt.Errorf("hooks detached")
+				},
+				restart: func() error {
+					restarted.Add(1)
+					if test.restartFixes {
+						working.Store(1)
+					}
+					return nil
+				},
+				stop: func() {},
+			}
+			if test.recovers || test.restarted == -1 {
+				m.recovery = time.Millisecond
+			}
+			resumed.Store(0)
+			fallback = func(m *monitorMethod, reason string) {
+				fellBack.Add(1)
+				if test.recovers {
+					// the next restart will succeed
+					test.restartFixes = true
+				}
+			}
+			restore = func(m *monitorMethod) {
+				restored.Add(1)
+			}
+
+			sctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				supervise(sctx, m)
+				close(done)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			<-done
+
+			if test.restarted == -1 {
+				// the proc monitors stopped by every restart are started again.
+				if restarted.Load() < 2 || resumed.Load() != restarted.Load()-1 {
+					t.Error("proc monitors not resumed after the failed recoveries:", restarted.Load(), resumed.Load())
+				}
+			} else if restarted.Load() != test.restarted {
+				t.Error("method restarted", restarted.Load(), "times, expected", test.restarted)
+			}
+			if fellBack.Load() != test.fellBack {
+				t.Error("fallback called", fellBack.Load(), "times, expected", test.fellBack)
+			}
+			if restored.Load() != test.restored {
+				t.Error("method restored", restored.Load(), "times, expected", test.restored)
+			}
+		})
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	if tm := parseInterval("test", "", time.Second); tm != time.Second {
+		t.Error("empty interval, expected default:", tm)
+	}
+	if tm := parseInterval("test", "invalid", time.Second); tm != time.Second {
+		t.Error("invalid interval, expected default:", tm)
+	}
+	if tm := parseInterval("test", "0s", time.Second); tm != 0 {
+		t.Error("disabled interval, expected 0:", tm)
+	}
+}
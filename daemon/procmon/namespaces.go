@@ -0,0 +1,106 @@
+package procmon
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+)
+
+// namespaces of the host, i.e.: of the PID 1.
+var (
+	hostNS     Namespaces
+	hostNSOnce sync.Once
+)
+
+// Namespaces holds the IDs (inodes) of the namespaces of a process.
+// An ID of 0 means that it couldn't be read.
+type Namespaces struct {
+	Mnt  uint64
+	Net  uint64
+	User uint64
+}
+
+// HostNamespaces returns the namespaces of the host (PID 1).
+func HostNamespaces() Namespaces {
+	hostNSOnce.Do(func() {
+		hostNS = readNamespaces("/proc/1")
+	})
+	return hostNS
+}
+
+// ReadNamespaces reads the namespaces, the effective capabilities and the
+// security label of the process.
+func (p *Process) ReadNamespaces() {
+	p.NS = readNamespaces(p.pathProc)
+	p.CapEff = readCapEff(p.pathStatus)
+	p.SecurityLabel = readSecurityLabel(p.pathProc)
+}
+
+// InHostNetNS returns false if the process is in a different network
+// namespace than the host (containers, sandboxes, ...).
+// If the namespace of the process is unknown, it's considered to be the host.
+func (p *Process) InHostNetNS() bool {
+	host := HostNamespaces()
+	return p.NS.Net == 0 || host.Net == 0 || p.NS.Net == host.Net
+}
+
+func readNamespaces(pathProc string) Namespaces {
+	return Namespaces{
+		Mnt:  readNamespace(pathProc, "mnt"),
+		Net:  readNamespace(pathProc, "net"),
+		User: readNamespace(pathProc, "user"),
+	}
+}
+
+// readNamespace returns the inode of a namespace: net:[4026531840]
+func readNamespace(pathProc, ns string) uint64 {
+	link, err := os.Readlink(core.ConcatStrings(pathProc, "/ns/", ns))
+	if err != nil {
+		return 0
+	}
+	start := strings.IndexByte(link, '[')
+	end := strings.IndexByte(link, ']')
+	if start == -1 || end < start {
+		return 0
+	}
+	id, _ := strconv.ParseUint(link[start+1:end], 10, 64)
+	return id
+}
+
+// readCapEff returns the effective capabilities of a process, from
+// /proc/<pid>/status: CapEff:	000001ffffffffff
+func readCapEff(pathStatus string) uint64 {
+	f, err := os.Open(pathStatus)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, found := strings.CutPrefix(scanner.Text(), "CapEff:")
+		if !found {
+			continue
+		}
+		caps, _ := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		return caps
+	}
+	return 0
+}
+
+// readSecurityLabel returns the label of the LSM confining the process, from
+// /proc/<pid>/attr/current:
+// SELinux: system_u:system_r:httpd_t:s0, unconfined_u:unconfined_r:unconfined_t:s0
+// AppArmor: /usr/bin/evince (enforce), unconfined
+// Empty if there's no LSM with labels enabled.
+func readSecurityLabel(pathProc string) string {
+	label, err := os.ReadFile(core.ConcatStrings(pathProc, "/attr/current"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(label), "\x00\n")
+}
@@ -0,0 +1,53 @@
+package procmon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadNamespaces(t *testing.T) {
+	proc := NewProcessEmpty(os.Getpid(), "")
+	proc.ReadNamespaces()
+	if proc.NS.Mnt == 0 || proc.NS.Net == 0 || proc.NS.User == 0 {
+		t.Error("namespaces not read:", proc.NS)
+	}
+
+	host := HostNamespaces()
+	if host.Net == 0 {
+		t.Skip("unable to read the namespaces of the host")
+	}
+	proc.NS.Net = host.Net
+	if !proc.InHostNetNS() {
+		t.Error("process not in the host network namespace")
+	}
+	proc.NS.Net = host.Net + 1
+	if proc.InHostNetNS() {
+		t.Error("process in the host network namespace")
+	}
+}
+
+func TestReadCapEff(t *testing.T) {
+	status := filepath.Join(t.TempDir(), "status")
+	os.WriteFile(status, []byte("Name:\tping\nCapInh:\t0000000000000000\nCapPrm:\t0000000000002000\nCapEff:\t0000000000002000\n"), 0644)
+	// CAP_NET_RAW
+	if caps := readCapEff(status); caps != 1<<13 {
+		t.Errorf("invalid CapEff: %x", caps)
+	}
+}
+
+func TestReadSecurityLabel(t *testing.T) {
+	pathProc := t.TempDir()
+	if label := readSecurityLabel(pathProc); label != "" {
+		t.Errorf("label without LSM: %s", label)
+	}
+	os.Mkdir(filepath.Join(pathProc, "attr"), 0755)
+	os.WriteFile(filepath.Join(pathProc, "attr", "current"), []byte("/usr/bin/evince (enforce)\n"), 0644)
+	if label := readSecurityLabel(pathProc); label != "/usr/bin/evince (enforce)" {
+		t.Errorf("invalid AppArmor label: %q", label)
+	}
+	os.WriteFile(filepath.Join(pathProc, "attr", "current"), []byte("unconfined_u:unconfined_r:unconfined_t:s0\x00"), 0644)
+	if label := readSecurityLabel(pathProc); label != "unconfined_u:unconfined_r:unconfined_t:s0" {
+		t.Errorf("invalid SELinux label: %q", label)
+	}
+}
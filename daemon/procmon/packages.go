@@ -0,0 +1,242 @@
+package procmon
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// Status of a binary, compared with the files installed by the package manager.
+const (
+	// PkgStatusPackaged the binary matches the file installed by a package.
+	PkgStatusPackaged = "packaged"
+	// PkgStatusModified the binary belongs to a package, but its checksum
+	// doesn't match.
+	PkgStatusModified = "modified"
+	// PkgStatusUnpackaged the binary doesn't belong to any package.
+	PkgStatusUnpackaged = "unpackaged"
+)
+
+var (
+	// directory with the md5sums of the files installed by dpkg.
+	dpkgInfoPath = "/var/lib/dpkg/info"
+	// interval to check if the packages installed have changed.
+	pkgCheckInterval = 30 * time.Second
+	// max number of binaries verified to keep in cache.
+	maxPkgCache = 4096
+)
+
+type pkgCacheEntry struct {
+	mtime  time.Time
+	status string
+	size   int64
+	inode  uint64
+}
+
+// PackagesVerifier checks the binaries of the processes against the
+// checksums of the files installed by the package manager (dpkg or rpm).
+type PackagesVerifier struct {
+	// dpkg: path -> md5
+	dpkgSums  map[string]string
+	cache     map[string]pkgCacheEntry
+	dpkgMtime time.Time
+	lastCheck time.Time
+	dpkgPath  string
+	rpmBin    string
+	enabled   bool
+	sync.RWMutex
+}
+
+// Packages verifies the binaries of the processes.
+var Packages = NewPackagesVerifier(dpkgInfoPath)
+
+// NewPackagesVerifier returns a new verifier. If dpkgPath doesn't exist, the
+// rpm database is used if the rpm command is available.
+func NewPackagesVerifier(dpkgPath string) *PackagesVerifier {
+	return &PackagesVerifier{
+		dpkgPath: dpkgPath,
+		cache:    make(map[string]pkgCacheEntry),
+	}
+}
+
+// SetEnabled enables or disables the verification of binaries.
+func (v *PackagesVerifier) SetEnabled(enabled bool) {
+	v.Lock()
+	defer v.Unlock()
+
+	v.enabled = enabled
+	v.cache = make(map[string]pkgCacheEntry)
+	v.dpkgSums = nil
+	v.rpmBin = ""
+	if !enabled {
+		return
+	}
+	if _, err := os.Stat(v.dpkgPath); err == nil {
+		log.Info("[packages] verifying binaries against dpkg database")
+		return
+	}
+	if rpmBin, err := exec.LookPath("rpm"); err == nil {
+		log.Info("[packages] verifying binaries against rpm database")
+		v.rpmBin = rpmBin
+		return
+	}
+	log.Warning("[packages] dpkg or rpm databases not found, binaries won't be verified")
+}
+
+// Verify returns the status of the binary of a process: packaged, modified or
+// unpackaged. It returns an empty string if it's disabled or the status can't
+// be determined.
+// The binary hashed is the one being executed (/proc/<pid>/exe), which may
+// differ from the file on disk if it has been replaced.
+func (v *PackagesVerifier) Verify(p *Process) string {
+	v.RLock()
+	enabled := v.enabled
+	v.RUnlock()
+	if !enabled || p.Path == "" {
+		return ""
+	}
+
+	exe := p.pathExe
+	st, err := os.Stat(exe)
+	if err != nil {
+		exe = p.RealPath
+		if st, err = os.Stat(exe); err != nil {
+			return ""
+		}
+	}
+	entry := pkgCacheEntry{mtime: st.ModTime(), size: st.Size()}
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		entry.inode = sys.Ino
+	}
+
+	v.RLock()
+	cached, found := v.cache[p.Path]
+	v.RUnlock()
+	if found && cached.mtime.Equal(entry.mtime) && cached.size == entry.size && cached.inode == entry.inode {
+		return cached.status
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	if v.rpmBin != "" {
+		entry.status = v.verifyRpm(p.Path)
+	} else {
+		entry.status = v.verifyDpkg(p.Path, exe)
+	}
+	if entry.status == "" {
+		return ""
+	}
+	if len(v.cache) >= maxPkgCache {
+		v.cache = make(map[string]pkgCacheEntry)
+	}
+	v.cache[p.Path] = entry
+
+	return entry.status
+}
+
+func (v *PackagesVerifier) verifyDpkg(path, exe string) string {
+	v.loadDpkgSums()
+
+	sum, found := v.dpkgSums[path]
+	if !found {
+		// usrmerge: /usr/bin/ls may be installed as /bin/ls, and vice versa.
+		if strings.HasPrefix(path, "/usr/") {
+			sum, found = v.dpkgSums[strings.TrimPrefix(path, "/usr")]
+		} else {
+			sum, found = v.dpkgSums["/usr"+path]
+		}
+	}
+	if !found {
+		return PkgStatusUnpackaged
+	}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return PkgStatusModified
+	}
+	return PkgStatusPackaged
+}
+
+// loadDpkgSums reads the *.md5sums files of dpkg, if the packages installed
+// have changed.
+func (v *PackagesVerifier) loadDpkgSums() {
+	if v.dpkgSums != nil && time.Since(v.lastCheck) < pkgCheckInterval {
+		return
+	}
+	v.lastCheck = time.Now()
+	st, err := os.Stat(v.dpkgPath)
+	if err != nil {
+		return
+	}
+	if v.dpkgSums != nil && st.ModTime().Equal(v.dpkgMtime) {
+		return
+	}
+	v.dpkgMtime = st.ModTime()
+
+	files, _ := filepath.Glob(filepath.Join(v.dpkgPath, "*.md5sums"))
+	sums := make(map[string]string)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		// d41d8cd98f00b204e9800998ecf8427e  usr/bin/curl
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			sum, path, found := strings.Cut(scanner.Text(), "  ")
+			if found {
+				sums["/"+path] = sum
+			}
+		}
+		f.Close()
+	}
+	v.dpkgSums = sums
+	// binaries verified with the old checksums.
+	v.cache = make(map[string]pkgCacheEntry)
+	log.Debug("[packages] %d files of %d packages loaded", len(sums), len(files))
+}
+
+func (v *PackagesVerifier) verifyRpm(path string) string {
+	if err := exec.Command(v.rpmBin, "-qf", path).Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return PkgStatusUnpackaged
+		}
+		return ""
+	}
+	// rpm -V exits with error if any file of the package has changed, so we
+	// only check the output for our file:
+	// S.5....T.    /usr/bin/curl
+	out, _ := exec.Command(v.rpmBin, "-Vf", "--nodeps", "--noscripts", path).Output()
+	return parseRpmVerify(string(out), path)
+}
+
+func parseRpmVerify(out, path string) string {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[len(fields)-1] != path {
+			continue
+		}
+		if len(fields[0]) > 2 && fields[0][2] == '5' {
+			return PkgStatusModified
+		}
+	}
+	return PkgStatusPackaged
+}
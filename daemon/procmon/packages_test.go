@@ -0,0 +1,55 @@
+package procmon
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackagesVerifier(t *testing.T) {
+	exe, err := os.ReadFile(fmt.Sprint("/proc/", os.Getpid(), "/exe"))
+	if err != nil {
+		t.Skip("unable to read our own binary:", err)
+	}
+	sum := md5.Sum(exe)
+
+	dpkgDir := t.TempDir()
+	md5sums := hex.EncodeToString(sum[:]) + "  usr/bin/opensnitch-test\n" +
+		"d41d8cd98f00b204e9800998ecf8427e  bin/opensnitch-modified\n"
+	if err := os.WriteFile(filepath.Join(dpkgDir, "opensnitch.md5sums"), []byte(md5sums), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v := NewPackagesVerifier(dpkgDir)
+
+	proc := NewProcessEmpty(os.Getpid(), "")
+	proc.Path = "/usr/bin/opensnitch-test"
+	if status := v.Verify(proc); status != "" {
+		t.Error("binary verified while disabled:", status)
+	}
+
+	v.SetEnabled(true)
+	tests := map[string]string{
+		"/usr/bin/opensnitch-test":     PkgStatusPackaged,
+		"/usr/bin/opensnitch-modified": PkgStatusModified,
+		"/usr/local/bin/opensnitch":    PkgStatusUnpackaged,
+	}
+	for path, expected := range tests {
+		proc.Path = path
+		if status := v.Verify(proc); status != expected {
+			t.Errorf("%s, expected %s, got %s", path, expected, status)
+		}
+	}
+}
+
+func TestParseRpmVerify(t *testing.T) {
+	out := "S.5....T.  c /etc/curlrc\n.M.......    /usr/bin/curl\nmissing     /usr/share/doc/curl\n"
+	if status := parseRpmVerify(out, "/usr/bin/curl"); status != PkgStatusPackaged {
+		t.Error("expected packaged, got:", status)
+	}
+	if status := parseRpmVerify(out, "/etc/curlrc"); status != PkgStatusModified {
+		t.Error("expected modified, got:", status)
+	}
+}
@@ -115,10 +115,28 @@ type Process struct {
 	//   -> Path: /usr/bin/curl
 	//   -> Args: /usr/bin/curl https://....
 	Args      []string
+	NS        Namespaces
+	CapEff    uint64
 	Starttime int64
 	ID        int
 	PPID      int
 	UID       int
+
+	// StartTicks is the start time of the process in clock ticks since boot
+	// (22nd field of /proc/<pid>/stat). Along with the PID, it identifies the
+	// process, since PIDs are reused.
+	StartTicks uint64
+
+	// SecurityLabel is the SELinux or AppArmor label of the process.
+	SecurityLabel string
+
+	// Libraries are the shared libraries mapped by the process, see
+	// GetLibraries().
+	Libraries []string
+	// the libraries are not read from this system.
+	librariesSet bool
+	// version of the mappings the libraries were read from.
+	librariesState *mapsState
 }
 
 // NewProcessEmpty returns a new Process struct with no details.
@@ -227,6 +245,12 @@ func (p *Process) Serialize() *protocol.Process {
 		NetReads:    netStats.ReadBytes,
 		NetWrites:   netStats.WriteBytes,
 		ProcessTree: p.Tree,
+		CapEff:      p.CapEff,
+		MntNs:       p.NS.Mnt,
+		NetNs:       p.NS.Net,
+		UserNs:      p.NS.User,
+
+		SecurityLabel: p.SecurityLabel,
 	}
 }
 
@@ -82,6 +82,18 @@ func TestProcEnv(t *testing.T) {
 		}
 	}
 
+	t.Run("whitelist", func(t *testing.T) {
+		SetEnvConfig(EnvConfig{Vars: []string{"USER", "XDG_*"}})
+		defer SetEnvConfig(EnvConfig{})
+		proc.ReadEnv()
+
+		if len(proc.Env) != 6 || proc.Env["USER"] != "opensnitch" || proc.Env["XDG_SEAT"] != "seat0" {
+			t.Error("Proc Env whitelist error:", proc.Env)
+		}
+		if _, found := proc.Env["HOME"]; found {
+			t.Error("Proc Env captured a variable not whitelisted")
+		}
+	})
 }
 
 func TestProcIOStats(t *testing.T) {
@@ -0,0 +1,162 @@
+package procmon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// user-mode network stacks. They open on the host the connections of a VM or
+// a sandbox, so the traffic is attributed to them instead of to the guest.
+const (
+	SandboxSlirp4netns = "slirp4netns"
+	SandboxPasta       = "pasta"
+	SandboxPasst       = "passt"
+	SandboxQemu        = "qemu"
+	SandboxGvisor      = "gvisor"
+	SandboxVpnkit      = "vpnkit"
+	SandboxGvproxy     = "gvproxy"
+)
+
+// Sandbox is the VM or sandbox on whose behalf a user-mode network stack has
+// opened a connection.
+type Sandbox struct {
+	// Type is the network stack (slirp4netns, qemu, gvisor...).
+	Type string
+	// Name of the VM or container, if it's known.
+	Name string
+	// OwnerPath and OwnerPID are the process the traffic belongs to: the VM
+	// itself, or the program that launched the network stack (podman,
+	// rootlesskit...).
+	OwnerPath string
+	OwnerPID  int
+}
+
+// options of slirp4netns that take a value as the next argument.
+var slirpValueOpts = map[string]bool{
+	"-a": true, "--api-socket": true,
+	"-e": true, "--exit-fd": true,
+	"-r": true, "--ready-fd": true,
+	"-m": true, "--mtu": true,
+	"--cidr": true, "--netns-type": true, "--userns-path": true,
+	"--macaddress": true, "--outbound-addr": true, "--outbound-addr6": true,
+}
+
+// readExe returns the path to the binary of a process.
+var readExe = func(pid int) string {
+	path, _ := os.Readlink(fmt.Sprint("/proc/", pid, "/exe"))
+	return path
+}
+
+// DetectSandbox returns the VM or sandbox a process opens connections for, if
+// the process is a user-mode network stack. Otherwise it returns nil.
+// The caller must hold the lock of the process.
+func DetectSandbox(p *Process) *Sandbox {
+	if p == nil || p.Path == "" {
+		return nil
+	}
+	base := filepath.Base(p.Path)
+	switch {
+	case base == "slirp4netns":
+		return slirpSandbox(p)
+	case base == "pasta" || base == "pasta.avx2":
+		s := parentSandbox(p, SandboxPasta)
+		if netns := argValue(p.Args, "--netns"); netns != "" {
+			s.Name = filepath.Base(netns)
+		}
+		return s
+	case base == "passt" || base == "passt.avx2":
+		return parentSandbox(p, SandboxPasst)
+	case base == "vpnkit":
+		return parentSandbox(p, SandboxVpnkit)
+	case base == "gvproxy":
+		return parentSandbox(p, SandboxGvproxy)
+	case base == "runsc":
+		// runsc [flags] boot [flags] <container id>
+		s := &Sandbox{Type: SandboxGvisor, OwnerPath: p.Path, OwnerPID: p.ID}
+		if len(p.Args) > 1 {
+			s.Name = p.Args[len(p.Args)-1]
+		}
+		return s
+	case strings.HasPrefix(base, "qemu-system-") || base == "qemu-kvm":
+		if !qemuUserNet(p.Args) {
+			return nil
+		}
+		// -name guest=vm1,debug-threads=on or -name vm1
+		name := argValue(p.Args, "-name")
+		if n, found := strings.CutPrefix(name, "guest="); found {
+			name = n
+		}
+		name, _, _ = strings.Cut(name, ",")
+		return &Sandbox{Type: SandboxQemu, Name: name, OwnerPath: p.Path, OwnerPID: p.ID}
+	}
+	return nil
+}
+
+// slirpSandbox returns the owner of the network namespace slirp4netns is
+// attached to: slirp4netns [options] PID|PATH [TAPNAME]
+func slirpSandbox(p *Process) *Sandbox {
+	s := parentSandbox(p, SandboxSlirp4netns)
+	for i := 1; i < len(p.Args); i++ {
+		arg := p.Args[i]
+		if strings.HasPrefix(arg, "-") {
+			if slirpValueOpts[arg] {
+				i++
+			}
+			continue
+		}
+		if pid, err := strconv.Atoi(arg); err == nil && pid > 1 {
+			s.OwnerPID = pid
+			s.OwnerPath = readExe(pid)
+		} else {
+			// --netns-type=path
+			s.Name = filepath.Base(arg)
+		}
+		break
+	}
+	return s
+}
+
+// parentSandbox returns a sandbox owned by the parent of the process, i.e.:
+// podman or rootlesskit.
+func parentSandbox(p *Process, stack string) *Sandbox {
+	s := &Sandbox{Type: stack}
+	// the first item of the tree is the process itself.
+	if len(p.Tree) > 1 && p.Tree[1].Value > 1 {
+		s.OwnerPath = p.Tree[1].Key
+		s.OwnerPID = int(p.Tree[1].Value)
+	} else if p.Parent != nil && p.Parent.ID > 1 {
+		s.OwnerPath = p.Parent.Path
+		s.OwnerPID = p.Parent.ID
+	}
+	return s
+}
+
+// qemuUserNet returns true if qemu uses the user-mode network stack:
+// -netdev user,id=n0 / -nic user / -net user
+func qemuUserNet(args []string) bool {
+	for i := 1; i < len(args)-1; i++ {
+		switch args[i] {
+		case "-netdev", "-nic", "-net":
+			if v := args[i+1]; v == "user" || strings.HasPrefix(v, "user,") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// argValue returns the value of an option: -opt value, or -opt=value
+func argValue(args []string, opt string) string {
+	for i := 1; i < len(args); i++ {
+		if args[i] == opt && i+1 < len(args) {
+			return args[i+1]
+		}
+		if v, found := strings.CutPrefix(args[i], opt+"="); found {
+			return v
+		}
+	}
+	return ""
+}
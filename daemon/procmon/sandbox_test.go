@@ -0,0 +1,66 @@
+package procmon
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestDetectSandbox(t *testing.T) {
+	origReadExe := readExe
+	readExe = func(pid int) string { return "/usr/bin/catatonit" }
+	defer func() { readExe = origReadExe }()
+
+	newProc := func(path string, args ...string) *Process {
+		p := NewProcessEmpty(4321, "")
+		p.Path = path
+		p.Args = args
+		p.Tree = []*protocol.StringInt{
+			{Key: path, Value: 4321},
+			{Key: "/usr/bin/podman", Value: 1000},
+			{Key: "/usr/lib/systemd/systemd", Value: 1},
+		}
+		return p
+	}
+	tests := []struct {
+		name     string
+		proc     *Process
+		expected *Sandbox
+	}{
+		{"slirp4netns target pid",
+			newProc("/usr/bin/slirp4netns", "slirp4netns", "--mtu", "65520", "-r", "3", "--enable-sandbox", "2345", "tap0"),
+			&Sandbox{Type: SandboxSlirp4netns, OwnerPath: "/usr/bin/catatonit", OwnerPID: 2345}},
+		{"slirp4netns netns path",
+			newProc("/usr/bin/slirp4netns", "slirp4netns", "--netns-type=path", "/run/user/1000/netns/netns-1", "tap0"),
+			&Sandbox{Type: SandboxSlirp4netns, Name: "netns-1", OwnerPath: "/usr/bin/podman", OwnerPID: 1000}},
+		{"pasta",
+			newProc("/usr/bin/pasta", "/usr/bin/pasta", "--config-net", "--netns", "/run/user/1000/netns/netns-2"),
+			&Sandbox{Type: SandboxPasta, Name: "netns-2", OwnerPath: "/usr/bin/podman", OwnerPID: 1000}},
+		{"qemu user net",
+			newProc("/usr/bin/qemu-system-x86_64", "qemu-system-x86_64", "-name", "guest=win10,debug-threads=on", "-netdev", "user,id=n0"),
+			&Sandbox{Type: SandboxQemu, Name: "win10", OwnerPath: "/usr/bin/qemu-system-x86_64", OwnerPID: 4321}},
+		{"qemu tap net",
+			newProc("/usr/bin/qemu-system-x86_64", "qemu-system-x86_64", "-netdev", "tap,id=n0"),
+			nil},
+		{"gvisor",
+			newProc("/usr/local/bin/runsc", "runsc-sandbox", "--network=host", "boot", "--bundle=/b", "abcdef"),
+			&Sandbox{Type: SandboxGvisor, Name: "abcdef", OwnerPath: "/usr/local/bin/runsc", OwnerPID: 4321}},
+		{"gvproxy",
+			newProc("/usr/libexec/podman/gvproxy", "gvproxy", "-listen-qemu", "unix:///tmp/qemu.sock"),
+			&Sandbox{Type: SandboxGvproxy, OwnerPath: "/usr/bin/podman", OwnerPID: 1000}},
+		{"regular process", newProc("/usr/bin/curl", "curl", "https://opensnitch.io"), nil},
+	}
+
+	for _, tt := range tests {
+		s := DetectSandbox(tt.proc)
+		if tt.expected == nil {
+			if s != nil {
+				t.Errorf("%s: unexpected sandbox detected: %+v", tt.name, s)
+			}
+			continue
+		}
+		if s == nil || *s != *tt.expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, s)
+		}
+	}
+}
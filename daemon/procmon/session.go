@@ -0,0 +1,125 @@
+package procmon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+)
+
+// directories where logind saves the state of the sessions and users.
+var (
+	logindSessionsPath = "/run/systemd/sessions"
+	logindUsersPath    = "/run/systemd/users"
+)
+
+// Session holds the details of a logind session.
+type Session struct {
+	ID   string
+	User string
+	// Seat is empty for remote sessions (ssh).
+	Seat string
+	// Type is x11, wayland, tty, ...
+	Type    string
+	Class   string
+	Display string
+	UID     int
+	Active  bool
+	Remote  bool
+}
+
+// GetSession returns the logind session a process belongs to.
+// Processes launched as systemd user units (i.e. by the desktop environment)
+// don't belong to any session, so they're attributed to the graphical
+// session of the user.
+func GetSession(pid int) (*Session, error) {
+	return getSessionFromCgroup(pid, core.ConcatStrings("/proc/", strconv.Itoa(pid), "/cgroup"))
+}
+
+func getSessionFromCgroup(pid int, cgroupFile string) (*Session, error) {
+	f, err := os.Open(cgroupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	uid := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 0::/user.slice/user-1000.slice/session-3.scope
+		// 0::/user.slice/user-1000.slice/user@1000.service/app.slice/app-firefox.scope
+		for _, unit := range strings.Split(scanner.Text(), "/") {
+			switch {
+			case strings.HasPrefix(unit, "session-") && strings.HasSuffix(unit, ".scope"):
+				return ReadSession(strings.TrimSuffix(strings.TrimPrefix(unit, "session-"), ".scope"))
+			case strings.HasPrefix(unit, "user-") && strings.HasSuffix(unit, ".slice"):
+				uid = strings.TrimSuffix(strings.TrimPrefix(unit, "user-"), ".slice")
+			}
+		}
+	}
+	if uid == "" {
+		return nil, fmt.Errorf("process %d doesn't belong to any session", pid)
+	}
+
+	user, err := readLogindFile(filepath.Join(logindUsersPath, uid))
+	if err != nil {
+		return nil, err
+	}
+	if user["DISPLAY"] == "" {
+		return nil, fmt.Errorf("user %s of process %d has no graphical session", uid, pid)
+	}
+	return ReadSession(user["DISPLAY"])
+}
+
+// ReadSession returns the details of a session by its ID.
+func ReadSession(id string) (*Session, error) {
+	if id == "" || strings.ContainsAny(id, "/.") {
+		return nil, fmt.Errorf("invalid session ID: %s", id)
+	}
+	fields, err := readLogindFile(filepath.Join(logindSessionsPath, id))
+	if err != nil {
+		return nil, err
+	}
+	uid, err := strconv.Atoi(fields["UID"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid UID of session %s: %s", id, err)
+	}
+
+	return &Session{
+		ID:      id,
+		UID:     uid,
+		User:    fields["USER"],
+		Seat:    fields["SEAT"],
+		Type:    fields["TYPE"],
+		Class:   fields["CLASS"],
+		Display: fields["DISPLAY"],
+		Active:  fields["ACTIVE"] == "1",
+		Remote:  fields["REMOTE"] == "1",
+	}, nil
+}
+
+// readLogindFile parses the KEY=VALUE state files of logind.
+func readLogindFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if key, value, found := strings.Cut(line, "="); found {
+			fields[key] = value
+		}
+	}
+	return fields, scanner.Err()
+}
@@ -0,0 +1,51 @@
+package procmon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetSession(t *testing.T) {
+	dir := t.TempDir()
+	logindSessionsPath = filepath.Join(dir, "sessions")
+	logindUsersPath = filepath.Join(dir, "users")
+	defer func() {
+		logindSessionsPath = "/run/systemd/sessions"
+		logindUsersPath = "/run/systemd/users"
+	}()
+	files := map[string]string{
+		"sessions/3": "# This is private data. Do not parse.\nUID=1000\nUSER=opensnitch\nACTIVE=1\nTYPE=wayland\nCLASS=user\nSEAT=seat0\n",
+		"users/1000": "NAME=opensnitch\nDISPLAY=3\nSESSIONS=3 5\n",
+		"users/1001": "NAME=remote\nSESSIONS=7\n",
+		"scope":      "0::/user.slice/user-1000.slice/session-3.scope\n",
+		"app":        "0::/user.slice/user-1000.slice/user@1000.service/app.slice/app-firefox-1234.scope\n",
+		"remote":     "0::/user.slice/user-1001.slice/user@1001.service/app.slice/app-x.scope\n",
+		"service":    "0::/system.slice/ssh.service\n",
+	}
+	os.Mkdir(logindSessionsPath, 0700)
+	os.Mkdir(logindUsersPath, 0700)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, cgroup := range []string{"scope", "app"} {
+		s, err := getSessionFromCgroup(1, filepath.Join(dir, cgroup))
+		if err != nil {
+			t.Fatal(cgroup, err)
+		}
+		if s.ID != "3" || s.UID != 1000 || s.Seat != "seat0" || s.Type != "wayland" || !s.Active {
+			t.Errorf("%s: invalid session: %+v", cgroup, s)
+		}
+	}
+	for _, cgroup := range []string{"remote", "service"} {
+		if s, err := getSessionFromCgroup(1, filepath.Join(dir, cgroup)); err == nil {
+			t.Errorf("%s: session found: %+v", cgroup, s)
+		}
+	}
+	if _, err := ReadSession("../users/1000"); err == nil {
+		t.Error("invalid session ID accepted")
+	}
+}
@@ -0,0 +1,55 @@
+package procmon
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+)
+
+// FindTree returns the PIDs of the running processes of a binary, and of all
+// their descendants.
+func FindTree(path string) []int {
+	pids := getProcPids(core.ConcatStrings(ProcPrefix, "/"))
+	parents := make(map[int]int, len(pids))
+	inTree := make(map[int]bool)
+	for _, pid := range pids {
+		p := NewProcessEmpty(pid, "")
+		p.ReadPPID()
+		parents[pid] = p.PPID
+		if exe, err := os.Readlink(p.pathExe); err == nil && exe == path {
+			inTree[pid] = true
+		}
+	}
+
+	tree := make([]int, 0)
+	for _, pid := range pids {
+		// walk up the ancestors until one of the binary is found.
+		for p, depth := pid, 0; p > 0 && depth < len(pids); p, depth = parents[p], depth+1 {
+			if inTree[p] {
+				tree = append(tree, pid)
+				break
+			}
+		}
+	}
+	return tree
+}
+
+// SocketInodes returns the inodes of the sockets opened by a process.
+func SocketInodes(pid int) []uint32 {
+	fdPath := core.ConcatStrings(ProcPrefix, "/", strconv.Itoa(pid), "/fd/")
+	inodes := make([]uint32, 0)
+	for _, fd := range lookupPidDescriptors(fdPath, pid) {
+		link, err := os.Readlink(core.ConcatStrings(fdPath, fd))
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		inode, err := strconv.ParseUint(link[8:len(link)-1], 10, 32)
+		if err != nil {
+			continue
+		}
+		inodes = append(inodes, uint32(inode))
+	}
+	return inodes
+}
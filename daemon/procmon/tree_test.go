@@ -0,0 +1,38 @@
+package procmon
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestFindTree(t *testing.T) {
+	exe, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		t.Skip("unable to read the path of the test:", err)
+	}
+	found := false
+	for _, pid := range FindTree(exe) {
+		if pid == os.Getpid() {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("process not found in the tree of its binary")
+	}
+	if tree := FindTree("/non/existent/binary"); len(tree) != 0 {
+		t.Error("processes found for a binary not running:", tree)
+	}
+}
+
+func TestSocketInodes(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("unable to listen:", err)
+	}
+	defer l.Close()
+
+	if inodes := SocketInodes(os.Getpid()); len(inodes) == 0 {
+		t.Error("sockets of the process not found")
+	}
+}
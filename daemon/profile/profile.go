@@ -0,0 +1,218 @@
+// Package profile generates a minimal set of rules from the connections
+// established on the system, in order to run the daemon with a default-deny
+// policy on servers, allowing only the services and destinations observed.
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// files with the nameservers used by the system.
+var resolvConfFiles = []string{"/etc/resolv.conf", "/run/systemd/resolve/resolv.conf"}
+
+// Connection is an outbound connection established by a process.
+type Connection struct {
+	DstIP   net.IP
+	Path    string
+	Proto   string
+	DstPort uint16
+}
+
+type sockType struct {
+	name  string
+	fam   uint8
+	proto uint8
+}
+
+var sockTypes = []sockType{
+	{"tcp", syscall.AF_INET, syscall.IPPROTO_TCP},
+	{"tcp6", syscall.AF_INET6, syscall.IPPROTO_TCP},
+	{"udp", syscall.AF_INET, syscall.IPPROTO_UDP},
+	{"udp6", syscall.AF_INET6, syscall.IPPROTO_UDP},
+}
+
+// Observe returns the outbound connections currently established.
+// Connections accepted by local services are excluded, since they're not
+// intercepted.
+func Observe() ([]Connection, error) {
+	conns := []Connection{}
+	for _, st := range sockTypes {
+		socks, err := netlink.SocketsDump(st.fam, st.proto)
+		if err != nil {
+			return nil, fmt.Errorf("unable to dump %s sockets: %s", st.name, err)
+		}
+
+		// local ports of the services, to exclude inbound connections.
+		listening := make(map[uint16]struct{})
+		for _, s := range socks {
+			if s.State == netlink.TCP_LISTEN || (st.proto == syscall.IPPROTO_UDP && s.State == netlink.TCP_CLOSE) {
+				listening[s.ID.SourcePort] = struct{}{}
+			}
+		}
+
+		for _, s := range socks {
+			if s.State != netlink.TCP_ESTABLISHED || s.ID.Destination.IsUnspecified() {
+				continue
+			}
+			if _, inbound := listening[s.ID.SourcePort]; inbound {
+				continue
+			}
+			inodeKey := fmt.Sprint(s.INode, s.ID.Source, s.ID.SourcePort, s.ID.Destination, s.ID.DestinationPort)
+			pid := procmon.GetPIDFromINode(int(s.INode), inodeKey)
+			if pid == -1 {
+				log.Debug("[profile] process not found for connection %s:%d -> %s:%d", s.ID.Source, s.ID.SourcePort, s.ID.Destination, s.ID.DestinationPort)
+				continue
+			}
+			proc := procmon.FindProcess(pid, false)
+			if proc == nil || proc.Path == "" {
+				continue
+			}
+			conns = append(conns, Connection{
+				Path:    proc.Path,
+				Proto:   st.name,
+				DstIP:   s.ID.Destination,
+				DstPort: s.ID.DestinationPort,
+			})
+		}
+	}
+
+	return conns, nil
+}
+
+// Nameservers returns the nameservers configured on the system.
+func Nameservers() []net.IP {
+	found := make(map[string]net.IP)
+	for _, file := range resolvConfFiles {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 || fields[0] != "nameserver" {
+				continue
+			}
+			if ip := net.ParseIP(fields[1]); ip != nil {
+				found[ip.String()] = ip
+			}
+		}
+		f.Close()
+	}
+
+	ips := make([]net.IP, 0, len(found))
+	for _, ip := range found {
+		ips = append(ips, ip)
+	}
+	return ips
+}
+
+// Generate proposes the rules to allow the given connections:
+//   - a rule per process, protocol and destination port, limited to the
+//     destination IPs observed.
+//   - a rule to allow connections to localhost.
+//   - a rule to allow DNS queries to the nameservers, which are usually not
+//     observed because they're short lived.
+func Generate(conns []Connection, nameservers []net.IP) []*rule.Rule {
+	rules := []*rule.Rule{
+		newRule("000-profile-allow-localhost", "Allow connections to localhost",
+			[]rule.Operator{{Type: rule.Regexp, Operand: rule.OpDstIP, Data: `^(127\.[0-9.]+|::1)$`}}),
+	}
+
+	servers := []string{}
+	for _, ip := range nameservers {
+		if !ip.IsLoopback() {
+			servers = append(servers, ip.String())
+		}
+	}
+	if len(servers) > 0 {
+		rules = append(rules, newRule("000-profile-allow-dns", "Allow DNS queries to the nameservers of the system",
+			[]rule.Operator{
+				{Type: rule.Simple, Operand: rule.OpDstPort, Data: "53"},
+				destOperator(servers),
+			}))
+	}
+
+	type destKey struct {
+		path  string
+		proto string
+		port  uint16
+	}
+	dests := make(map[destKey]map[string]struct{})
+	for _, c := range conns {
+		if c.DstIP.IsLoopback() {
+			continue
+		}
+		key := destKey{c.Path, c.Proto, c.DstPort}
+		if dests[key] == nil {
+			dests[key] = make(map[string]struct{})
+		}
+		dests[key][c.DstIP.String()] = struct{}{}
+	}
+
+	keys := make([]destKey, 0, len(dests))
+	for key := range dests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		if keys[i].proto != keys[j].proto {
+			return keys[i].proto < keys[j].proto
+		}
+		return keys[i].port < keys[j].port
+	})
+	for _, key := range keys {
+		ips := make([]string, 0, len(dests[key]))
+		for ip := range dests[key] {
+			ips = append(ips, ip)
+		}
+		name := fmt.Sprint("profile-", ruleName(filepath.Base(key.path)), "-", key.proto, "-", key.port)
+		rules = append(rules, newRule(name, fmt.Sprint("Allow the connections observed from ", key.path),
+			[]rule.Operator{
+				{Type: rule.Simple, Operand: rule.OpProcessPath, Data: key.path},
+				{Type: rule.Simple, Operand: rule.OpProto, Data: key.proto},
+				{Type: rule.Simple, Operand: rule.OpDstPort, Data: strconv.Itoa(int(key.port))},
+				destOperator(ips),
+			}))
+	}
+
+	return rules
+}
+
+func newRule(name, description string, list []rule.Operator) *rule.Rule {
+	op, _ := rule.NewOperator(rule.List, false, rule.OpList, "", list)
+	return rule.Create(name, description, true, false, false, rule.Allow, rule.Always, op)
+}
+
+func destOperator(ips []string) rule.Operator {
+	if len(ips) == 1 {
+		return rule.Operator{Type: rule.Simple, Operand: rule.OpDstIP, Data: ips[0]}
+	}
+	sort.Strings(ips)
+	for i, ip := range ips {
+		ips[i] = regexp.QuoteMeta(ip)
+	}
+	return rule.Operator{Type: rule.Regexp, Operand: rule.OpDstIP, Data: "^(" + strings.Join(ips, "|") + ")$"}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func ruleName(name string) string {
+	return strings.Trim(invalidNameChars.ReplaceAllString(name, "-"), "-.")
+}
@@ -0,0 +1,54 @@
+package profile
+
+import (
+	"net"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+func TestGenerate(t *testing.T) {
+	conns := []Connection{
+		{Path: "/usr/sbin/ntpd", Proto: "udp", DstIP: net.ParseIP("10.0.0.2"), DstPort: 123},
+		{Path: "/usr/bin/apt (deleted)", Proto: "tcp", DstIP: net.ParseIP("1.1.1.1"), DstPort: 443},
+		{Path: "/usr/bin/apt (deleted)", Proto: "tcp", DstIP: net.ParseIP("1.0.0.1"), DstPort: 443},
+		{Path: "/usr/bin/apt (deleted)", Proto: "tcp", DstIP: net.ParseIP("1.0.0.1"), DstPort: 443},
+		{Path: "/usr/bin/redis-cli", Proto: "tcp", DstIP: net.ParseIP("127.0.0.1"), DstPort: 6379},
+	}
+	nameservers := []net.IP{net.ParseIP("127.0.0.53"), net.ParseIP("10.0.0.1")}
+
+	rules := Generate(conns, nameservers)
+	if len(rules) != 4 {
+		t.Fatal("expected 4 rules, got:", len(rules))
+	}
+	for _, r := range rules {
+		if err := rule.Validate(r); err != nil {
+			t.Error("invalid rule generated:", r.Name, err)
+		}
+		if r.Action != rule.Allow || r.Duration != rule.Always {
+			t.Error("invalid action or duration:", r.Name, r.Action, r.Duration)
+		}
+	}
+
+	if rules[0].Name != "000-profile-allow-localhost" {
+		t.Error("localhost rule not generated:", rules[0].Name)
+	}
+	dns := rules[1]
+	if dns.Name != "000-profile-allow-dns" || dns.Operator.List[1].Data != "10.0.0.1" {
+		t.Error("invalid DNS rule:", dns.Name, dns.Operator.List[1].Data)
+	}
+
+	apt := rules[2]
+	if apt.Name != "profile-apt-deleted-tcp-443" {
+		t.Error("invalid rule name:", apt.Name)
+	}
+	dst := &apt.Operator.List[3]
+	if dst.Type != rule.Regexp || dst.Data != `^(1\.0\.0\.1|1\.1\.1\.1)$` {
+		t.Error("invalid destination operator:", dst.Type, dst.Data)
+	}
+
+	ntp := rules[3]
+	if ntp.Name != "profile-ntpd-udp-123" || ntp.Operator.List[3].Type != rule.Simple {
+		t.Error("invalid rule:", ntp.Name, ntp.Operator.List[3].Type)
+	}
+}
@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// Timing holds how long it took to evaluate the connections against the
+// rules, with a way of matching them.
+type Timing struct {
+	Elapsed time.Duration
+	// connections evaluated: the connections of the capture, every round.
+	Evaluated int
+}
+
+// PerConnection returns the time it took to evaluate every connection.
+func (t Timing) PerConnection() time.Duration {
+	if t.Evaluated == 0 {
+		return 0
+	}
+	return t.Elapsed / time.Duration(t.Evaluated)
+}
+
+// BenchmarkReport holds the results of a benchmark.
+type BenchmarkReport struct {
+	// every rule evaluated against every connection.
+	Linear Timing
+	// only the rules that may match every connection.
+	Indexed Timing
+	// connections matched by a different rule with every way of matching.
+	// It must be 0.
+	Mismatches  int
+	Connections int
+	Rules       int
+	Rounds      int
+}
+
+// Benchmark evaluates the connections of the capture file against the given
+// rules, as many rounds as requested, evaluating all the rules against every
+// connection (linear) and only the rules that may match it (indexed).
+func Benchmark(path string, rules *rule.Loader, rounds int) (*BenchmarkReport, error) {
+	if rounds < 1 {
+		rounds = 1
+	}
+	report := &BenchmarkReport{Rules: rules.NumRules(), Rounds: rounds}
+
+	var cons []*conman.Connection
+	err := Read(path, func(line int, rec *Record) error {
+		cons = append(cons, conman.Deserialize(rec.Connection))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Connections = len(cons)
+
+	defer rules.SetLinearMatch(false)
+	run := func(linear bool) (Timing, []*rule.Rule) {
+		rules.SetLinearMatch(linear)
+		matches := make([]*rule.Rule, len(cons))
+		start := time.Now()
+		for i := 0; i < rounds; i++ {
+			for n, con := range cons {
+				matches[n] = rules.FindFirstMatch(con)
+			}
+		}
+		return Timing{Elapsed: time.Since(start), Evaluated: rounds * len(cons)}, matches
+	}
+	var linearMatches, indexedMatches []*rule.Rule
+	report.Linear, linearMatches = run(true)
+	report.Indexed, indexedMatches = run(false)
+	for i := range cons {
+		if linearMatches[i] != indexedMatches[i] {
+			report.Mismatches++
+		}
+	}
+
+	return report, nil
+}
+
+// Print writes the report in a human readable format.
+func (r *BenchmarkReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "connections: %d, rules: %d, rounds: %d\n", r.Connections, r.Rules, r.Rounds)
+	for _, t := range []struct {
+		name string
+		Timing
+	}{{"linear", r.Linear}, {"indexed", r.Indexed}} {
+		perSecond := 0.0
+		if t.Elapsed > 0 {
+			perSecond = float64(t.Evaluated) / t.Elapsed.Seconds()
+		}
+		fmt.Fprintf(w, "%-8s %s, %s/connection, %.0f connections/s\n", t.name, t.Elapsed, t.PerConnection(), perSecond)
+	}
+	if r.Mismatches > 0 {
+		fmt.Fprintf(w, "\nWARNING: %d connections matched by different rules\n", r.Mismatches)
+	}
+}
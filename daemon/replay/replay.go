@@ -0,0 +1,99 @@
+// Package replay writes the intercepted connections to a capture file, and
+// re-feeds them through the rule engine later (offline), in order to test
+// how a new set of rules would have treated them.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// Record is an entry of the capture file, one per line, in json format.
+type Record struct {
+	Connection *protocol.Connection `json:"connection"`
+	Action     string               `json:"action"`
+	Rule       string               `json:"rule"`
+	Unixnano   int64                `json:"unixnano"`
+}
+
+// Writer writes the intercepted connections to a capture file.
+type Writer struct {
+	file *os.File
+	enc  *json.Encoder
+	mu   sync.Mutex
+}
+
+// NewWriter opens the capture file for writing. New records are appended
+// to the existing ones.
+func NewWriter(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+// Write adds a new connection to the capture file, along with the action
+// applied and the rule that matched it (if any).
+func (w *Writer) Write(con *protocol.Connection, action, ruleName string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("capture file closed")
+	}
+	return w.enc.Encode(&Record{
+		Connection: con,
+		Action:     action,
+		Rule:       ruleName,
+		Unixnano:   time.Now().UnixNano(),
+	})
+}
+
+// Close closes the capture file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Read reads the records of a capture file, calling cb for each one of them.
+// Malformed lines are skipped.
+func Read(path string, cb func(line int, rec *Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Connection == nil {
+			continue
+		}
+		if err := cb(line, &rec); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
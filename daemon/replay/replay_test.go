@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func newConn(path, host string) *protocol.Connection {
+	return &protocol.Connection{
+		Protocol:    "tcp",
+		SrcIp:       "127.0.0.1",
+		SrcPort:     41234,
+		DstIp:       "185.53.178.14",
+		DstHost:     host,
+		DstPort:     443,
+		UserId:      1000,
+		ProcessId:   1234,
+		ProcessPath: path,
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	capture := filepath.Join(dir, "capture.jsonl")
+
+	w, err := NewWriter(capture)
+	if err != nil {
+		t.Fatal("NewWriter() error:", err)
+	}
+	w.Write(newConn("/usr/bin/curl", "opensnitch.io"), "allow", "allow-curl")
+	w.Write(newConn("/usr/bin/wget", "opensnitch.io"), "allow", "allow-wget")
+	w.Write(newConn("/usr/bin/nc", ""), "deny", "")
+	w.Write(newConn("/usr/bin/ssh", ""), "allow", "")
+	w.Close()
+
+	// append a malformed line, it must be skipped
+	f, _ := os.OpenFile(capture, os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString("{not json\n")
+	f.Close()
+
+	rules, err := rule.NewLoader(false)
+	if err != nil {
+		t.Fatal("NewLoader() error:", err)
+	}
+	if err := rules.Load(dir); err != nil {
+		t.Fatal("Load() error:", err)
+	}
+	op, _ := rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/wget", make([]rule.Operator, 0))
+	rules.Add(rule.Create("deny-wget", "", true, false, false, rule.Deny, rule.Always, op), false)
+	op, _ = rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/curl", make([]rule.Operator, 0))
+	rules.Add(rule.Create("allow-curl", "", true, false, false, rule.Allow, rule.Always, op), false)
+
+	// nc and ssh are not matched by any rule: nc was denied by default, and
+	// it's not a change. ssh was allowed with another default action.
+	defaultDeny := func(con *conman.Connection) rule.Action { return rule.Deny }
+	report, err := Run(capture, rules, defaultDeny)
+	if err != nil {
+		t.Fatal("Run() error:", err)
+	}
+	if report.Total != 4 || report.Matched != 2 || report.Unmatched != 2 {
+		t.Errorf("invalid report: %d total, %d matched, %d unmatched", report.Total, report.Matched, report.Unmatched)
+	}
+	if len(report.Changes) != 2 {
+		t.Fatal("invalid number of changes:", len(report.Changes))
+	}
+	if report.Changes[0].Line != 2 || report.Changes[0].NewAction != string(rule.Deny) || report.Changes[0].NewRule != "deny-wget" {
+		t.Errorf("invalid change: %+v", report.Changes[0])
+	}
+	if report.Changes[1].Line != 4 || report.Changes[1].NewAction != string(rule.Deny) || report.Changes[1].NewRule != NoMatch {
+		t.Errorf("invalid change: %+v", report.Changes[1])
+	}
+
+	// the same action, by another rule.
+	op, _ = rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/nc", make([]rule.Operator, 0))
+	rules.Add(rule.Create("deny-nc", "", true, false, false, rule.Deny, rule.Always, op), false)
+	report, _ = Run(capture, rules, defaultDeny)
+	if len(report.Changes) != 3 || report.Changes[1].Line != 3 || report.Changes[1].NewRule != "deny-nc" {
+		t.Errorf("connection matched by another rule not reported: %+v", report.Changes)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	dir := t.TempDir()
+	capture := filepath.Join(dir, "capture.jsonl")
+
+	w, err := NewWriter(capture)
+	if err != nil {
+		t.Fatal("NewWriter() error:", err)
+	}
+	w.Write(newConn("/usr/bin/curl", "opensnitch.io"), "allow", "allow-curl")
+	w.Write(newConn("/usr/bin/wget", "www.opensnitch.io"), "allow", "allow-wget")
+	w.Write(newConn("/usr/bin/nc", ""), "deny", "")
+	w.Close()
+
+	rules, _ := rule.NewLoader(false)
+	op, _ := rule.NewOperator(rule.Regexp, false, rule.OpDstHost, `(^|\.)opensnitch\.io$`, make([]rule.Operator, 0))
+	rules.Add(rule.Create("allow-opensnitch", "", true, false, false, rule.Allow, rule.Always, op), false)
+	op, _ = rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/wget", make([]rule.Operator, 0))
+	rules.Add(rule.Create("deny-wget", "", true, false, false, rule.Deny, rule.Always, op), false)
+
+	report, err := Benchmark(capture, rules, 10)
+	if err != nil {
+		t.Fatal("Benchmark() error:", err)
+	}
+	if report.Connections != 3 || report.Rules != 2 || report.Rounds != 10 {
+		t.Errorf("invalid report: %+v", report)
+	}
+	if report.Linear.Evaluated != 30 || report.Indexed.Evaluated != 30 {
+		t.Errorf("invalid number of connections evaluated: %d, %d", report.Linear.Evaluated, report.Indexed.Evaluated)
+	}
+	if report.Mismatches != 0 {
+		t.Error("connections matched by different rules:", report.Mismatches)
+	}
+}
@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// NoMatch is the rule reported when no rule matches a connection.
+// Live, the user would have been asked, or the default action applied.
+const NoMatch = "<no match>"
+
+// DefaultActionFunc returns the action applied to a connection that doesn't
+// match any rule.
+type DefaultActionFunc func(con *conman.Connection) rule.Action
+
+// Result holds the outcome of evaluating a captured connection against the
+// current rules.
+type Result struct {
+	Record *Record
+	Line   int
+	// action applied to the connection: the one of the rule matched, or the
+	// default action.
+	NewAction string
+	NewRule   string
+}
+
+// Changed returns true if the connection would have been treated differently:
+// by another rule, or with another action.
+// The connections not matched by any rule are recorded with the default
+// action and without rule, so they're not changed if they still don't match
+// any rule and the default action is the same.
+func (r *Result) Changed() bool {
+	newRule := r.NewRule
+	if newRule == NoMatch {
+		newRule = ""
+	}
+	return r.Record.Rule != newRule || r.Record.Action != r.NewAction
+}
+
+// Report holds the summary of a replay.
+type Report struct {
+	Changes   []*Result
+	Total     int
+	Matched   int
+	Unmatched int
+}
+
+// Run evaluates every connection of the capture file against the given rules.
+// The connections not matched by any rule get the action of defaultAction.
+func Run(path string, rules *rule.Loader, defaultAction DefaultActionFunc) (*Report, error) {
+	report := &Report{}
+
+	err := Read(path, func(line int, rec *Record) error {
+		res := &Result{
+			Record:  rec,
+			Line:    line,
+			NewRule: NoMatch,
+		}
+		report.Total++
+
+		con := conman.Deserialize(rec.Connection)
+		if r := rules.FindFirstMatch(con); r != nil {
+			res.NewAction = string(r.Action)
+			res.NewRule = r.Name
+			report.Matched++
+		} else {
+			res.NewAction = string(defaultAction(con))
+			report.Unmatched++
+		}
+		if res.Changed() {
+			report.Changes = append(report.Changes, res)
+		}
+		return nil
+	})
+
+	return report, err
+}
+
+// Print writes the report in a human readable format.
+func (r *Report) Print(w io.Writer) {
+	for _, res := range r.Changes {
+		con := res.Record.Connection
+		dst := con.DstIp
+		if con.DstHost != "" {
+			dst = con.DstHost
+		}
+		oldRule := res.Record.Rule
+		if oldRule == "" {
+			oldRule = NoMatch
+		}
+		fmt.Fprintf(w, "#%d %s -> %s:%d (%s): %s (%s) => %s (%s)\n",
+			res.Line, con.ProcessPath, dst, con.DstPort, con.Protocol,
+			res.Record.Action, oldRule, res.NewAction, res.NewRule)
+	}
+	fmt.Fprintf(w, "\nconnections: %d, matched: %d, not matched: %d, changed: %d\n",
+		r.Total, r.Matched, r.Unmatched, len(r.Changes))
+}
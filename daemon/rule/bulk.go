@@ -0,0 +1,315 @@
+package rule
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// Formats of the documents to export and import rules in bulk.
+const (
+	BulkJSON = "json"
+	BulkCSV  = "csv"
+)
+
+// Strategies to apply when an imported rule has the same name as a loaded rule.
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	// the imported rule is added with a new name: name-2, name-3, ...
+	ConflictRename = "rename"
+)
+
+// ErrUnknownConflict is returned when importing rules with an unknown strategy.
+var ErrUnknownConflict = errors.New("Unknown conflict strategy")
+
+// allowImportedHooks keeps the hooks of the rules imported, synced from other
+// hosts or restored from bundles.
+var allowImportedHooks atomic.Bool
+
+// SetAllowImportedHooks enables or disables the hooks of the rules imported,
+// synced from other hosts or restored from bundles. They're removed by
+// default, since the hooks run with the privileges of the daemon.
+func SetAllowImportedHooks(enabled bool) {
+	allowImportedHooks.Store(enabled)
+}
+
+// StripHooks removes the hooks of the rules that don't come from the local
+// rules, unless they're allowed. It returns the number of hooks removed.
+func StripHooks(rules []*Rule, source string) int {
+	if allowImportedHooks.Load() {
+		return 0
+	}
+	stripped := 0
+	for _, r := range rules {
+		if r == nil || r.Hook == nil {
+			continue
+		}
+		log.Warning("[rules] %s: hook %s of the rule %s removed, set Rules.AllowImportedHooks to keep it", source, r.Hook.Path, r.Name)
+		r.Hook = nil
+		stripped++
+	}
+	return stripped
+}
+
+// columns of the CSV documents. The operator is saved in json format.
+var csvHeader = []string{"name", "description", "enabled", "precedence", "nolog", "action", "duration", "priority", "created", "operator", "tags"}
+
+// ImportResult holds the rules imported, by name.
+type ImportResult struct {
+	Added    []string `json:"added"`
+	Replaced []string `json:"replaced"`
+	Skipped  []string `json:"skipped"`
+	// rules deleted when restoring a bundle.
+	Deleted []string `json:"deleted,omitempty"`
+	// rules added with a new name: old name -> new name
+	Renamed map[string]string `json:"renamed"`
+	// rules not imported: name -> error
+	Errors map[string]string `json:"errors"`
+}
+
+// BulkFormat returns the format of a document by its file name: csv if it
+// ends in .csv, json otherwise.
+func BulkFormat(fileName string) string {
+	if strings.EqualFold(filepath.Ext(fileName), ".csv") {
+		return BulkCSV
+	}
+	return BulkJSON
+}
+
+// Export writes all the loaded rules to a single document, in the order they're
+// evaluated.
+func (l *Loader) Export(w io.Writer, format string) error {
+	return WriteRules(w, l.GetOrdered(), format)
+}
+
+// WriteRules writes a list of rules to a single document.
+func WriteRules(w io.Writer, rules []*Rule, format string) error {
+	switch format {
+	case BulkJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rules)
+	case BulkCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, r := range rules {
+			op, err := json.Marshal(&r.Operator)
+			if err != nil {
+				return fmt.Errorf("Error exporting rule %s: %s", r.Name, err)
+			}
+			cw.Write([]string{
+				r.Name,
+				r.Description,
+				strconv.FormatBool(r.Enabled),
+				strconv.FormatBool(r.Precedence),
+				strconv.FormatBool(r.Nolog),
+				string(r.Action),
+				string(r.Duration),
+				strconv.FormatInt(int64(r.Priority), 10),
+				r.Created,
+				string(op),
+				strings.Join(r.Tags, ","),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	return fmt.Errorf("Unknown rules format: %s", format)
+}
+
+// ReadRules parses a document of rules exported with Export().
+func ReadRules(r io.Reader, format string) ([]*Rule, error) {
+	switch format {
+	case BulkJSON:
+		var rules []*Rule
+		if err := json.NewDecoder(r).Decode(&rules); err != nil {
+			return nil, fmt.Errorf("Error parsing rules: %s", err)
+		}
+		return rules, nil
+	case BulkCSV:
+		return readCSVRules(r)
+	}
+
+	return nil, fmt.Errorf("Unknown rules format: %s", format)
+}
+
+func readCSVRules(r io.Reader) ([]*Rule, error) {
+	cr := csv.NewReader(r)
+	// documents exported before the tags were added lack the last column.
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing rules: %s", err)
+	}
+	if len(records) == 0 || records[0][0] != csvHeader[0] {
+		return nil, fmt.Errorf("Error parsing rules: header not found")
+	}
+
+	rules := make([]*Rule, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		if len(rec) != len(csvHeader) && len(rec) != len(csvHeader)-1 {
+			return nil, fmt.Errorf("Error parsing rules, line %d: wrong number of fields", i+2)
+		}
+		rul := &Rule{
+			Name:        rec[0],
+			Description: rec[1],
+			Action:      Action(rec[5]),
+			Duration:    Duration(rec[6]),
+			Created:     rec[8],
+		}
+		bools := []*bool{&rul.Enabled, &rul.Precedence, &rul.Nolog}
+		for j, b := range bools {
+			if *b, err = strconv.ParseBool(rec[2+j]); err != nil {
+				return nil, fmt.Errorf("Error parsing rules, line %d, %s: %s", i+2, csvHeader[2+j], err)
+			}
+		}
+		prio, err := strconv.ParseInt(rec[7], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing rules, line %d, priority: %s", i+2, err)
+		}
+		rul.Priority = int32(prio)
+		if err := json.Unmarshal([]byte(rec[9]), &rul.Operator); err != nil {
+			return nil, fmt.Errorf("Error parsing rules, line %d, operator: %s", i+2, err)
+		}
+		if len(rec) == len(csvHeader) && rec[10] != "" {
+			rul.Tags = strings.Split(rec[10], ",")
+		}
+		rules = append(rules, rul)
+	}
+
+	return rules, nil
+}
+
+// Validate checks that the fields of a rule are valid, before adding it.
+// The operator is validated when the rule is compiled.
+func Validate(r *Rule) error {
+	if r.Name == "" {
+		return fmt.Errorf("the name of the rule is empty")
+	}
+	if r.Name != filepath.Base(r.Name) || strings.HasPrefix(r.Name, ".") {
+		return fmt.Errorf("invalid rule name: %s", r.Name)
+	}
+	switch r.Action {
+	case Allow, Deny, Reject, Audit, Proxy:
+	default:
+		return fmt.Errorf("invalid action: %s", r.Action)
+	}
+	if r.Action == Proxy && r.Proxy == "" {
+		return fmt.Errorf("the rules with action proxy must name an upstream proxy")
+	}
+	if r.Proxy != "" && r.Action != Proxy {
+		return fmt.Errorf("only the rules with action proxy can name an upstream proxy")
+	}
+	switch r.Duration {
+	case Restart, Always:
+	case Once:
+		return fmt.Errorf("rules with duration once can't be imported")
+	default:
+		if _, err := time.ParseDuration(string(r.Duration)); err != nil {
+			return fmt.Errorf("invalid duration: %s", r.Duration)
+		}
+	}
+	if r.Operator.Type == "" || r.Operator.Operand == "" {
+		return fmt.Errorf("invalid operator, type and operand are mandatory")
+	}
+	if r.Kill != "" {
+		if _, found := killSignals[r.Kill]; !found {
+			return fmt.Errorf("invalid kill signal: %s", r.Kill)
+		}
+		if r.Action.Allows() {
+			return fmt.Errorf("only the rules that deny connections can kill the process")
+		}
+	}
+	if r.Jail {
+		if path, _ := r.JailDestinations(); path == "" {
+			return fmt.Errorf("only the binaries of the operand process.path can be jailed")
+		}
+	}
+	for _, tag := range r.Tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t\n") {
+			return fmt.Errorf("invalid tag: '%s'", tag)
+		}
+	}
+	if r.Log != nil {
+		if err := r.Log.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Hook != nil {
+		if err := r.Hook.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import adds a list of rules, applying the given strategy to the rules with
+// the same name as a loaded rule.
+// All the rules are validated before adding any of them. Rules with duration
+// always are saved to disk. Their hooks are removed, unless they're allowed.
+func (l *Loader) Import(rules []*Rule, strategy string) (*ImportResult, error) {
+	switch strategy {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConflict, strategy)
+	}
+	StripHooks(rules, "import")
+	for _, r := range rules {
+		if err := Validate(r); err != nil {
+			return nil, fmt.Errorf("%w %s: %s", ErrInvalidRule, r.Name, err)
+		}
+	}
+
+	result := &ImportResult{
+		Renamed: make(map[string]string),
+		Errors:  make(map[string]string),
+	}
+	for _, r := range rules {
+		name := r.Name
+		l.RLock()
+		_, exists := l.rules[name]
+		l.RUnlock()
+
+		if exists {
+			switch strategy {
+			case ConflictSkip:
+				result.Skipped = append(result.Skipped, name)
+				continue
+			case ConflictRename:
+				l.setUniqueName(r)
+			}
+		}
+		if r.Created == "" {
+			r.Created = time.Now().Format(time.RFC3339)
+		}
+
+		if err := l.Replace(r, r.Duration == Always); err != nil {
+			result.Errors[name] = err.Error()
+			continue
+		}
+		switch {
+		case name != r.Name:
+			result.Renamed[name] = r.Name
+		case exists:
+			result.Replaced = append(result.Replaced, name)
+		default:
+			result.Added = append(result.Added, name)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package rule
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/hooks"
+	"github.com/evilsocket/opensnitch/daemon/log/loggers"
+)
+
+func newBulkRule(t *testing.T, name string, duration Duration, operand Operand, data string) *Rule {
+	op, err := NewOperator(Simple, false, operand, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Create(name, "desc, with comma", true, false, false, Allow, duration, op)
+}
+
+func TestBulkExportImport(t *testing.T) {
+	rulesDir := t.TempDir()
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(rulesDir); err != nil {
+		t.Fatal(err)
+	}
+	dns := newBulkRule(t, "000-allow-dns", Always, OpDstPort, "53")
+	dns.Tags = []string{"dns", "system"}
+	l.Replace(dns, false)
+	l.Replace(newBulkRule(t, "001-allow-curl", Restart, OpProcessPath, "/usr/bin/curl"), false)
+
+	for _, format := range []string{BulkJSON, BulkCSV} {
+		t.Run(format, func(t *testing.T) {
+			var doc bytes.Buffer
+			if err := l.Export(&doc, format); err != nil {
+				t.Fatal("Export() error:", err)
+			}
+			rules, err := ReadRules(&doc, format)
+			if err != nil {
+				t.Fatal("ReadRules() error:", err)
+			}
+			if len(rules) != 2 {
+				t.Fatal("expected 2 rules, got:", len(rules))
+			}
+			r := rules[0]
+			if r.Name != "000-allow-dns" || r.Description != "desc, with comma" || r.Duration != Always ||
+				r.Operator.Operand != OpDstPort || r.Operator.Data != "53" || !r.Enabled ||
+				strings.Join(r.Tags, ",") != "dns,system" {
+				t.Errorf("invalid rule read: %+v", r)
+			}
+			if len(rules[1].Tags) != 0 {
+				t.Errorf("invalid tags read: %v", rules[1].Tags)
+			}
+		})
+	}
+
+	t.Run("conflicts", func(t *testing.T) {
+		var doc bytes.Buffer
+		l.Export(&doc, BulkJSON)
+		rules, _ := ReadRules(bytes.NewReader(doc.Bytes()), BulkJSON)
+
+		result, err := l.Import(rules, ConflictSkip)
+		if err != nil || len(result.Skipped) != 2 || len(result.Added) != 0 {
+			t.Error("rules not skipped:", result, err)
+		}
+
+		rules, _ = ReadRules(bytes.NewReader(doc.Bytes()), BulkJSON)
+		result, err = l.Import(rules, ConflictRename)
+		if err != nil || result.Renamed["000-allow-dns"] != "000-allow-dns-2" {
+			t.Error("rules not renamed:", result, err)
+		}
+		if _, err := os.Stat(filepath.Join(rulesDir, "000-allow-dns-2.json")); err != nil {
+			t.Error("imported rule not saved to disk:", err)
+		}
+		if _, err := os.Stat(filepath.Join(rulesDir, "001-allow-curl-2.json")); err == nil {
+			t.Error("temporary rule saved to disk")
+		}
+
+		rules, _ = ReadRules(bytes.NewReader(doc.Bytes()), BulkJSON)
+		rules[0].Operator.Data = "853"
+		result, err = l.Import(rules, ConflictOverwrite)
+		if err != nil || len(result.Replaced) != 2 {
+			t.Error("rules not replaced:", result, err)
+		}
+		if l.GetAll()["000-allow-dns"].Operator.Data != "853" {
+			t.Error("rule not overwritten")
+		}
+		if l.NumRules() != 4 {
+			t.Error("expected 4 rules, got:", l.NumRules())
+		}
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		hooked := newBulkRule(t, "hooked", Always, OpTrue, "")
+		hooked.Hook = &hooks.Hook{Path: "ticket.sh"}
+		if _, err := l.Import([]*Rule{hooked}, ConflictSkip); err != nil {
+			t.Fatal("rule with a hook not imported:", err)
+		}
+		if r := l.GetAll()["hooked"]; r == nil || r.Hook != nil {
+			t.Errorf("hook of the imported rule not removed: %+v", r)
+		}
+		l.Delete("hooked")
+	})
+
+	// the hooks are validated if they're allowed.
+	SetAllowImportedHooks(true)
+	defer SetAllowImportedHooks(false)
+
+	t.Run("validation", func(t *testing.T) {
+		invalid := []*Rule{
+			newBulkRule(t, "", Always, OpTrue, ""),
+			newBulkRule(t, "../etc/x", Always, OpTrue, ""),
+			newBulkRule(t, "once", Once, OpTrue, ""),
+			newBulkRule(t, "duration", Duration("1x"), OpTrue, ""),
+		}
+		allowKill := newBulkRule(t, "allow-kill", Always, OpTrue, "")
+		allowKill.Kill = "SIGKILL"
+		denyKill := newBulkRule(t, "deny-kill", Always, OpTrue, "")
+		denyKill.Action, denyKill.Kill = Deny, "SIGHUP"
+		jailAny := newBulkRule(t, "jail-any", Always, OpTrue, "")
+		jailAny.Jail = true
+		proxyNone := newBulkRule(t, "proxy-none", Always, OpTrue, "")
+		proxyNone.Action = Proxy
+		allowProxy := newBulkRule(t, "allow-proxy", Always, OpTrue, "")
+		allowProxy.Proxy = "tor"
+		logTarget := newBulkRule(t, "log-target", Always, OpTrue, "")
+		logTarget.Log = &loggers.RuleLogConfig{Level: loggers.RuleLogFull, Target: "journal"}
+		hookRelative := newBulkRule(t, "hook-relative", Always, OpTrue, "")
+		hookRelative.Hook = &hooks.Hook{Path: "ticket.sh"}
+		invalid = append(invalid, allowKill, denyKill, jailAny, proxyNone, allowProxy, logTarget, hookRelative)
+		for _, r := range invalid {
+			if _, err := l.Import([]*Rule{newBulkRule(t, "valid", Always, OpTrue, ""), r}, ConflictSkip); err == nil {
+				t.Error("invalid rule imported:", r.Name)
+			}
+		}
+		if _, found := l.GetAll()["valid"]; found {
+			t.Error("rules imported, but the document has invalid rules")
+		}
+		if _, err := l.Import(nil, "merge"); err == nil {
+			t.Error("invalid conflict strategy accepted")
+		}
+		if _, err := ReadRules(strings.NewReader("name,description\nx,y\n"), BulkCSV); err == nil {
+			t.Error("invalid csv accepted")
+		}
+	})
+}
+
+func TestBulkFormat(t *testing.T) {
+	if BulkFormat("/tmp/rules.CSV") != BulkCSV || BulkFormat("rules.json") != BulkJSON || BulkFormat("-") != BulkJSON {
+		t.Error("invalid format detected")
+	}
+}
@@ -0,0 +1,254 @@
+package rule
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// BundleVersion is the version of the format of the bundles.
+const BundleVersion = 1
+
+// Bundle holds all the rules and the system firewall configuration of a node,
+// to backup and restore them, or to provision several nodes with the same
+// configuration.
+type Bundle struct {
+	Created string  `json:"created"`
+	Rules   []*Rule `json:"rules"`
+	// configuration of the system firewall (system-fw.json), if any.
+	SystemFirewall json.RawMessage `json:"system_firewall,omitempty"`
+	Version        int             `json:"version"`
+}
+
+// signedBundle is the document exchanged with the server: the bundle
+// serialized, its sha256 checksum, and optionally the ed25519 signature of
+// the bundle, encoded in base64.
+type signedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Checksum  string          `json:"checksum"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// NewBundle returns a new bundle with the given rules and system firewall
+// configuration.
+func NewBundle(rules []*Rule, sysfw []byte) *Bundle {
+	return &Bundle{
+		Version:        BundleVersion,
+		Created:        time.Now().Format(time.RFC3339),
+		Rules:          rules,
+		SystemFirewall: sysfw,
+	}
+}
+
+// Marshal serializes the bundle. It's signed if key is not nil.
+func (b *Bundle) Marshal(key ed25519.PrivateKey) ([]byte, error) {
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("Error serializing bundle: %s", err)
+	}
+	sum := sha256.Sum256(raw)
+	doc := signedBundle{
+		Bundle:   raw,
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+	if key != nil {
+		doc.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, raw))
+	}
+
+	return json.Marshal(&doc)
+}
+
+// UnmarshalBundle parses a bundle serialized with Marshal(), and verifies its
+// checksum. If trustedKeys is not empty, the bundle must be signed by one of
+// the keys.
+func UnmarshalBundle(data []byte, trustedKeys []ed25519.PublicKey) (*Bundle, error) {
+	var doc signedBundle
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Error parsing bundle: %s", err)
+	}
+	sum := sha256.Sum256(doc.Bundle)
+	if hex.EncodeToString(sum[:]) != doc.Checksum {
+		return nil, fmt.Errorf("Invalid bundle checksum")
+	}
+	if len(trustedKeys) > 0 {
+		if doc.Signature == "" {
+			return nil, fmt.Errorf("The bundle is not signed")
+		}
+		sig, err := base64.StdEncoding.DecodeString(doc.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid bundle signature: %s", err)
+		}
+		verified := false
+		for _, key := range trustedKeys {
+			if ed25519.Verify(key, doc.Bundle, sig) {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, fmt.Errorf("The bundle is not signed by a trusted key")
+		}
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(doc.Bundle, &b); err != nil {
+		return nil, fmt.Errorf("Error parsing bundle: %s", err)
+	}
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("Unsupported bundle version: %d", b.Version)
+	}
+	if len(b.SystemFirewall) > 0 && !json.Valid(b.SystemFirewall) {
+		return nil, fmt.Errorf("Invalid system firewall configuration")
+	}
+	for _, r := range b.Rules {
+		if err := Validate(r); err != nil {
+			return nil, fmt.Errorf("%w %s: %s", ErrInvalidRule, r.Name, err)
+		}
+	}
+
+	return &b, nil
+}
+
+// ReadBundleSigningKey reads an ed25519 private key, in PEM (PKCS8) format.
+func ReadBundleSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid private key %s: %s", path, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Invalid private key %s: not an ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// ReadTrustedKeys reads a list of ed25519 public keys, in PEM (PKIX)
+// format.
+func ReadTrustedKeys(paths []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(paths))
+	for _, path := range paths {
+		block, err := readPEM(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid public key %s: %s", path, err)
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("Invalid public key %s: not an ed25519 key", path)
+		}
+		keys = append(keys, edKey)
+	}
+	return keys, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block, nil
+}
+
+// Restore replaces the loaded rules with the rules of a bundle, atomically:
+// if any of the rules can't be added, the previous rules are restored.
+// If replace is true, the loaded rules not included in the bundle are deleted.
+// The hooks of the rules are removed, unless they're allowed.
+func (l *Loader) Restore(rules []*Rule, replace bool) (*ImportResult, error) {
+	StripHooks(rules, "bundle")
+	var remove func(r *Rule) bool
+	if replace {
+		remove = func(r *Rule) bool { return true }
+	}
+	return l.restore(rules, remove)
+}
+
+// restore replaces the loaded rules with the given rules, atomically. The
+// loaded rules not included for which remove returns true are deleted.
+func (l *Loader) restore(rules []*Rule, remove func(r *Rule) bool) (*ImportResult, error) {
+	for _, r := range rules {
+		if err := Validate(r); err != nil {
+			return nil, fmt.Errorf("%w %s: %s", ErrInvalidRule, r.Name, err)
+		}
+	}
+	previous := l.GetAll()
+	inBundle := make(map[string]bool, len(rules))
+
+	result := &ImportResult{
+		Renamed: make(map[string]string),
+		Errors:  make(map[string]string),
+	}
+	var err error
+	failed := ""
+	for _, r := range rules {
+		inBundle[r.Name] = true
+		if r.Created == "" {
+			r.Created = time.Now().Format(time.RFC3339)
+		}
+		_, exists := previous[r.Name]
+		if err = l.Replace(r, r.Duration == Always); err != nil {
+			result.Errors[r.Name] = err.Error()
+			// the rule may have been added, or the previous one modified.
+			failed = r.Name
+			break
+		}
+		if exists {
+			result.Replaced = append(result.Replaced, r.Name)
+		} else {
+			result.Added = append(result.Added, r.Name)
+		}
+	}
+	if err == nil && remove != nil {
+		for name, r := range previous {
+			if inBundle[name] || !remove(r) {
+				continue
+			}
+			if err = l.Delete(name); err != nil {
+				result.Errors[name] = err.Error()
+				failed = name
+				break
+			}
+			result.Deleted = append(result.Deleted, name)
+		}
+	}
+	if err == nil {
+		return result, nil
+	}
+
+	// rollback
+	restore := append(result.Replaced, result.Deleted...)
+	if _, exists := previous[failed]; exists {
+		restore = append(restore, failed)
+	} else if failed != "" {
+		l.Delete(failed)
+	}
+	for _, name := range result.Added {
+		l.Delete(name)
+	}
+	for _, name := range restore {
+		old := previous[name]
+		if rerr := l.Replace(old, old.Duration == Always); rerr != nil {
+			log.Error("Error restoring rule %s: %s", name, rerr)
+		}
+	}
+	return result, fmt.Errorf("Error restoring rules, changes reverted: %w", err)
+}
@@ -0,0 +1,91 @@
+package rule
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+)
+
+func TestBundleSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	rules := []*Rule{newBulkRule(t, "000-allow-dns", Always, OpDstPort, "53")}
+	sysfw := []byte(`{"Enabled":true,"Version":1,"SystemRules":[]}`)
+	unsigned, err := NewBundle(rules, sysfw).Marshal(nil)
+	if err != nil {
+		t.Fatal("Marshal() error:", err)
+	}
+	signed, err := NewBundle(rules, sysfw).Marshal(priv)
+	if err != nil {
+		t.Fatal("Marshal() error:", err)
+	}
+
+	b, err := UnmarshalBundle(unsigned, nil)
+	if err != nil {
+		t.Fatal("UnmarshalBundle() error:", err)
+	}
+	if len(b.Rules) != 1 || b.Rules[0].Name != "000-allow-dns" || string(b.SystemFirewall) != string(sysfw) {
+		t.Errorf("invalid bundle: %+v", b)
+	}
+	if _, err := UnmarshalBundle(signed, []ed25519.PublicKey{otherPub, pub}); err != nil {
+		t.Error("signed bundle not verified:", err)
+	}
+	if _, err := UnmarshalBundle(unsigned, []ed25519.PublicKey{pub}); err == nil {
+		t.Error("unsigned bundle accepted with trusted keys configured")
+	}
+	if _, err := UnmarshalBundle(signed, []ed25519.PublicKey{otherPub}); err == nil {
+		t.Error("bundle signed by an untrusted key accepted")
+	}
+
+	var doc signedBundle
+	json.Unmarshal(signed, &doc)
+	doc.Bundle = json.RawMessage(string(doc.Bundle[:len(doc.Bundle)-1]) + `,"x":1}`)
+	tampered, _ := json.Marshal(&doc)
+	if _, err := UnmarshalBundle(tampered, nil); err == nil {
+		t.Error("tampered bundle accepted")
+	}
+}
+
+func TestBundleRestore(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	l.Replace(newBulkRule(t, "000-allow-dns", Restart, OpDstPort, "53"), false)
+	l.Replace(newBulkRule(t, "001-allow-curl", Restart, OpProcessPath, "/usr/bin/curl"), false)
+
+	invalid := newBulkRule(t, "002-invalid", Restart, OpProcessPath, "")
+	invalid.Operator = Operator{Type: Regexp, Operand: OpProcessPath, Data: "("}
+	rules := []*Rule{
+		newBulkRule(t, "000-allow-dns", Restart, OpDstPort, "5353"),
+		newBulkRule(t, "003-allow-ssh", Restart, OpDstPort, "22"),
+		invalid,
+	}
+	if _, err := l.Restore(rules, true); err == nil {
+		t.Fatal("invalid rule restored")
+	}
+	all := l.GetAll()
+	if len(all) != 2 || all["000-allow-dns"].Operator.Data != "53" || all["001-allow-curl"] == nil {
+		t.Fatal("previous rules not restored:", all)
+	}
+
+	result, err := l.Restore(rules[:2], true)
+	if err != nil {
+		t.Fatal("Restore() error:", err)
+	}
+	if len(result.Added) != 1 || len(result.Replaced) != 1 || len(result.Deleted) != 1 || result.Deleted[0] != "001-allow-curl" {
+		t.Errorf("invalid restore result: %+v", result)
+	}
+	all = l.GetAll()
+	if len(all) != 2 || all["000-allow-dns"].Operator.Data != "5353" || all["003-allow-ssh"] == nil {
+		t.Error("rules not restored:", all)
+	}
+}
@@ -0,0 +1,144 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Prefixes of the patterns of the operators of type domain.
+const (
+	// *.example.com: the subdomains of example.com, not example.com.
+	SubdomainsPrefix = "*."
+	// +.example.com: example.com and its subdomains.
+	DomainAndSubdomainsPrefix = "+."
+)
+
+// rejectPublicSuffixes rejects the patterns that cover a public suffix.
+var rejectPublicSuffixes atomic.Bool
+
+// SetRejectPublicSuffixes enables or disables the rejection of the domain
+// patterns that cover a public suffix of the public suffix list (*.com,
+// +.co.uk, *.github.io), which would match the domains of unrelated owners.
+// The rules must be compiled again to apply it.
+func SetRejectPublicSuffixes(enabled bool) {
+	rejectPublicSuffixes.Store(enabled)
+}
+
+// domainMatch is what a node of the tree matches.
+type domainMatch uint8
+
+const (
+	matchDomain domainMatch = 1 << iota
+	matchSubdomains
+)
+
+// domainTree holds the domains of the patterns of an operator, by labels
+// from right to left (com -> example -> www).
+type domainTree struct {
+	match    domainMatch
+	children map[string]*domainTree
+}
+
+func (t *domainTree) insert(domain string, match domainMatch) {
+	node := t
+	labels := strings.Split(domain, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		if node.children == nil {
+			node.children = make(map[string]*domainTree)
+		}
+		next, found := node.children[labels[i]]
+		if !found {
+			next = &domainTree{}
+			node.children[labels[i]] = next
+		}
+		node = next
+	}
+	node.match |= match
+}
+
+// matches returns true if the host matches any of the patterns of the tree.
+func (t *domainTree) matches(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return false
+	}
+	node := t
+	for end := len(host); end >= 0; {
+		start := strings.LastIndexByte(host[:end], '.') + 1
+		next, found := node.children[host[start:end]]
+		if !found {
+			return false
+		}
+		node = next
+		if start == 0 {
+			return node.match&matchDomain != 0
+		}
+		if node.match&matchSubdomains != 0 {
+			return true
+		}
+		end = start - 1
+	}
+	return false
+}
+
+// compileDomains parses the patterns of the operator, separated by commas:
+//
+//	example.com, *.example.org, +.example.net
+func (o *Operator) compileDomains() error {
+	tree := &domainTree{}
+	for _, pattern := range strings.Split(o.Data, ",") {
+		domain, match, err := parseDomainPattern(pattern)
+		if err != nil {
+			return err
+		}
+		tree.insert(domain, match)
+	}
+	o.domains = tree
+	o.cb = o.domainsCmp
+	return nil
+}
+
+func (o *Operator) domainsCmp(host string) bool {
+	return o.domains.matches(strings.ToLower(host))
+}
+
+// parseDomainPattern returns the domain of a pattern, in lowercase, and what
+// it matches.
+func parseDomainPattern(pattern string) (string, domainMatch, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	match := matchDomain
+	if strings.HasPrefix(domain, SubdomainsPrefix) {
+		domain, match = domain[len(SubdomainsPrefix):], matchSubdomains
+	} else if strings.HasPrefix(domain, DomainAndSubdomainsPrefix) {
+		domain, match = domain[len(DomainAndSubdomainsPrefix):], matchDomain|matchSubdomains
+	}
+	if domain == "" {
+		return "", 0, fmt.Errorf("invalid domain pattern '%s'", pattern)
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || strings.ContainsAny(label, "*+?[] ") {
+			return "", 0, fmt.Errorf("invalid domain pattern '%s', only %s and %s are allowed at the beginning", pattern, SubdomainsPrefix, DomainAndSubdomainsPrefix)
+		}
+	}
+	if match&matchSubdomains != 0 && rejectPublicSuffixes.Load() {
+		// the unknown TLDs (lan, internal, ...) are not in the list.
+		suffix, icann := publicsuffix.PublicSuffix(domain)
+		if suffix == domain && (icann || strings.Contains(suffix, ".")) {
+			return "", 0, fmt.Errorf("invalid domain pattern '%s', %s is a public suffix", pattern, domain)
+		}
+	}
+	return domain, match, nil
+}
+
+// domainsSuffix returns the domain that all the domains matched by the
+// patterns of an operator are, or are subdomains of, if there's only one.
+func domainsSuffix(data string) (string, bool) {
+	if strings.Contains(data, ",") {
+		return "", false
+	}
+	domain, _, err := parseDomainPattern(data)
+	return domain, err == nil
+}
@@ -0,0 +1,89 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestDomainOperator(t *testing.T) {
+	compile := func(data string) (*Operator, error) {
+		op, _ := NewOperator(Domain, false, OpDstHost, data, nil)
+		return op, op.Compile()
+	}
+	op, err := compile("example.com, *.example.org, +.Example.NET")
+	if err != nil {
+		t.Fatal("Compile() error:", err)
+	}
+	tests := map[string]bool{
+		"example.com":         true,
+		"EXAMPLE.com.":        true,
+		"www.example.com":     false,
+		"example.org":         false,
+		"www.example.org":     true,
+		"a.b.example.org":     true,
+		"badexample.org":      false,
+		"example.net":         true,
+		"api.example.net":     true,
+		"example.net.evil.io": false,
+		"net":                 false,
+		"":                    false,
+	}
+	for host, match := range tests {
+		c := *conn
+		c.DstHost = host
+		if op.Match(&c, false) != match {
+			t.Errorf("%s: unexpected match, should be %v", host, match)
+		}
+	}
+
+	for _, data := range []string{"", "*.", "exa*mple.com", "www.*.example.com", "example..com", "+.", "example.com,"} {
+		if _, err := compile(data); err == nil {
+			t.Errorf("invalid pattern accepted: '%s'", data)
+		}
+	}
+	if op, _ := NewOperator(Domain, false, OpDstIP, "example.com", nil); op.Compile() == nil {
+		t.Error("type domain accepted with an operand other than dest.host")
+	}
+
+	t.Run("public suffixes", func(t *testing.T) {
+		defer SetRejectPublicSuffixes(false)
+		patterns := []string{"*.com", "+.co.uk", "*.github.io", "+.lan", "com", "+.example.co.uk"}
+		for _, data := range patterns {
+			if _, err := compile(data); err != nil {
+				t.Errorf("%s rejected without the check enabled: %s", data, err)
+			}
+		}
+		SetRejectPublicSuffixes(true)
+		for i, data := range patterns {
+			_, err := compile(data)
+			if rejected := i < 3; (err != nil) != rejected {
+				t.Errorf("%s: unexpected result, rejected: %v, error: %v", data, rejected, err)
+			}
+		}
+	})
+
+	t.Run("indexed", func(t *testing.T) {
+		l := &Loader{rules: make(map[string]*Rule)}
+		for name, data := range map[string]string{"000-deny-example": "+.example.com", "001-deny-others": "example.com, example.org"} {
+			op, _ := compile(data)
+			r := Create(name, "", true, false, false, Deny, Always, op)
+			l.rules[r.Name] = r
+		}
+		l.sortRules()
+		if m := l.activeSnapshot.Load().matcher; len(m.unindexed) != 1 {
+			t.Errorf("unexpected rules not indexed: %v", m.unindexed)
+		}
+		for host, expected := range map[string]string{"api.example.com.": "000-deny-example", "example.org": "001-deny-others", "example.net": ""} {
+			con := conman.Deserialize(&protocol.Connection{Protocol: "tcp", DstIp: "1.1.1.1", DstHost: host, ProcessPath: "/usr/bin/curl"})
+			name := ""
+			if r := l.FindFirstMatch(con); r != nil {
+				name = r.Name
+			}
+			if name != expected {
+				t.Errorf("%s: unexpected rule matched: '%s', expected: '%s'", host, name, expected)
+			}
+		}
+	})
+}
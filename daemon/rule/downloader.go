@@ -0,0 +1,325 @@
+package rule
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// RemoteListsFile is the file of a lists directory with the lists to download.
+// It's a hidden file, so it's not loaded as a list.
+const RemoteListsFile = ".sources.json"
+
+// Formats of the remote lists.
+const (
+	// 0.0.0.0 domain.com
+	FormatHosts = "hosts"
+	// domain.com
+	FormatDomains = "domains"
+	// one entry per line (IPs, networks, hashes, ...)
+	FormatPlain = "plain"
+)
+
+var (
+	defaultListsInterval = 24 * time.Hour
+	listsDownloadTimeout = 60 * time.Second
+	// max size of a list, and of the checksums and signatures files.
+	maxListSize = int64(256 * 1024 * 1024)
+	maxSumSize  = int64(4096)
+)
+
+// RemoteList is a list downloaded from an URL to a lists directory.
+type RemoteList struct {
+	URL string `json:"url"`
+	// file name of the list in the lists directory.
+	Name string `json:"name"`
+	// hosts, domains or plain. The content is converted to the format expected
+	// by the operand of the rule.
+	Format string `json:"format"`
+	// interval to check if the list has changed (24h by default).
+	Interval string `json:"interval"`
+	// sha256 of the list, or URL of a file with the sha256 (sha256sum format).
+	SHA256    string `json:"sha256"`
+	SHA256URL string `json:"sha256_url"`
+	// URL of the ed25519 signature of the list (raw or base64), and the public
+	// key to verify it (base64).
+	SignatureURL string `json:"signature_url"`
+	PublicKey    string `json:"public_key"`
+
+	etag         string
+	lastModified string
+}
+
+// Downloader keeps updated the remote lists of a lists directory.
+type Downloader struct {
+	client  *http.Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+	dir     string
+	operand Operand
+	lists   []*RemoteList
+	wg      sync.WaitGroup
+}
+
+// NewDownloader reads the remote lists configured for a lists directory.
+// It returns nil if there're no remote lists configured.
+func NewDownloader(dir string, operand Operand) (*Downloader, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, RemoteListsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	d := &Downloader{
+		client:  &http.Client{Timeout: listsDownloadTimeout},
+		ctx:     context.Background(),
+		dir:     dir,
+		operand: operand,
+	}
+	if err := json.Unmarshal(raw, &d.lists); err != nil {
+		return nil, fmt.Errorf("invalid remote lists %s: %s", filepath.Join(dir, RemoteListsFile), err)
+	}
+	for _, rl := range d.lists {
+		if rl.URL == "" || rl.Name == "" {
+			return nil, fmt.Errorf("invalid remote list, url and name are mandatory: %s", dir)
+		}
+		if rl.Name != filepath.Base(rl.Name) || rl.Name[:1] == "." {
+			return nil, fmt.Errorf("invalid remote list name: %s", rl.Name)
+		}
+	}
+
+	return d, nil
+}
+
+// Start downloads the lists, and checks periodically if they have changed.
+func (d *Downloader) Start() {
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for _, rl := range d.lists {
+		d.wg.Add(1)
+		go d.monitor(rl)
+	}
+}
+
+// Stop stops checking the lists, cancelling the downloads in progress.
+func (d *Downloader) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+		d.wg.Wait()
+		d.cancel = nil
+	}
+}
+
+func (d *Downloader) monitor(rl *RemoteList) {
+	defer d.wg.Done()
+
+	interval := defaultListsInterval
+	if rl.Interval != "" {
+		if tm, err := time.ParseDuration(rl.Interval); err == nil && tm > 0 {
+			interval = tm
+		} else {
+			log.Warning("[lists] invalid interval %s, using default: %s, %s", rl.Interval, interval, rl.URL)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if updated, err := d.Update(rl); err != nil {
+			log.Warning("[lists] error updating %s: %s", rl.URL, err)
+		} else if updated {
+			log.Info("[lists] list updated: %s -> %s", rl.URL, filepath.Join(d.dir, rl.Name))
+		}
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update downloads a list if it has changed, and saves it to the lists
+// directory once verified. The lists monitor reloads it afterwards.
+// If the verification fails, the previous list is kept.
+func (d *Downloader) Update(rl *RemoteList) (updated bool, err error) {
+	listFile := filepath.Join(d.dir, rl.Name)
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, rl.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	// the list may have been deleted.
+	if _, err := os.Stat(listFile); err == nil {
+		if rl.etag != "" {
+			req.Header.Set("If-None-Match", rl.etag)
+		}
+		if rl.lastModified != "" {
+			req.Header.Set("If-Modified-Since", rl.lastModified)
+		}
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		log.Debug("[lists] list not modified: %s", rl.URL)
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxListSize))
+	if err != nil {
+		return false, err
+	}
+	if len(raw) == 0 {
+		return false, fmt.Errorf("list empty")
+	}
+
+	if err := d.verify(rl, raw); err != nil {
+		return false, err
+	}
+
+	tmpFile := filepath.Join(d.dir, fmt.Sprint(".", rl.Name, ".tmp"))
+	if err := os.WriteFile(tmpFile, d.convert(rl, raw), 0600); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpFile, listFile); err != nil {
+		os.Remove(tmpFile)
+		return false, err
+	}
+	rl.etag = resp.Header.Get("ETag")
+	rl.lastModified = resp.Header.Get("Last-Modified")
+
+	return true, nil
+}
+
+// verify checks the sha256 and the signature of a list, if configured.
+func (d *Downloader) verify(rl *RemoteList, raw []byte) error {
+	expected := rl.SHA256
+	if expected == "" && rl.SHA256URL != "" {
+		sum, err := d.get(rl.SHA256URL)
+		if err != nil {
+			return fmt.Errorf("unable to get sha256: %s", err)
+		}
+		if fields := strings.Fields(string(sum)); len(fields) > 0 {
+			expected = fields[0]
+		}
+	}
+	if expected != "" {
+		sum := sha256.Sum256(raw)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(expected, got) {
+			return fmt.Errorf("sha256 mismatch, expected %s, got %s", expected, got)
+		}
+	}
+
+	if rl.SignatureURL == "" {
+		return nil
+	}
+	pubKey, err := base64.StdEncoding.DecodeString(rl.PublicKey)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key: %s", rl.PublicKey)
+	}
+	sig, err := d.get(rl.SignatureURL)
+	if err != nil {
+		return fmt.Errorf("unable to get signature: %s", err)
+	}
+	if sig, err = decodeSignature(sig); err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pubKey), raw, sig) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
+// decodeSignature returns an ed25519 signature, raw or base64 encoded.
+func decodeSignature(sig []byte) ([]byte, error) {
+	if len(sig) == ed25519.SignatureSize {
+		return sig, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %s", err)
+	}
+	return sig, nil
+}
+
+func (d *Downloader) get(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSumSize))
+}
+
+// convert transforms a list to the format expected by the operand, removing
+// comments and empty lines.
+func (d *Downloader) convert(rl *RemoteList, raw []byte) []byte {
+	format := rl.Format
+	if format == "" {
+		format = FormatPlain
+		if d.operand == OpDomainsLists {
+			format = FormatHosts
+		}
+	}
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(line, "#"); idx > -1 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		if line == "" {
+			continue
+		}
+		switch {
+		case d.operand == OpDomainsLists && format == FormatDomains:
+			line = "0.0.0.0 " + line
+		case d.operand == OpDomainsLists && format == FormatHosts:
+			// normalize separators, some lists use TABs or several spaces.
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			line = fields[0] + " " + fields[1]
+		case d.operand != OpDomainsLists && format == FormatHosts:
+			// extract the domains/IPs of a hosts file, for non-domains operands.
+			if fields := strings.Fields(line); len(fields) > 1 {
+				line = fields[1]
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
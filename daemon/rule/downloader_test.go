@@ -0,0 +1,143 @@
+package rule
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var remoteList = []byte(`# comment
+0.0.0.0	ads.example.com
+0.0.0.0 tracker.example.com # inline comment
+
+127.0.0.1 localhost
+`)
+
+func newListsServer(t *testing.T, requests *int) *httptest.Server {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(remoteList)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hosts", func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(remoteList)
+	})
+	mux.HandleFunc("/hosts.sha256", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  hosts\n", hex.EncodeToString(sum[:]))
+	})
+	mux.HandleFunc("/hosts.sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, remoteList))))
+	})
+	mux.HandleFunc("/pubkey", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(pub)))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDownloader(t *testing.T) {
+	requests := 0
+	srv := newListsServer(t, &requests)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if d, err := NewDownloader(dir, OpDomainsLists); d != nil || err != nil {
+		t.Fatal("downloader without remote lists:", d, err)
+	}
+
+	sources := fmt.Sprintf(`[{"url": "%s/hosts", "name": "hosts.txt", "sha256_url": "%s/hosts.sha256"}]`, srv.URL, srv.URL)
+	if err := os.WriteFile(filepath.Join(dir, RemoteListsFile), []byte(sources), 0600); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDownloader(dir, OpDomainsLists)
+	if err != nil || d == nil {
+		t.Fatal("NewDownloader() error:", err)
+	}
+
+	t.Run("download", func(t *testing.T) {
+		updated, err := d.Update(d.lists[0])
+		if err != nil || !updated {
+			t.Fatal("list not downloaded:", updated, err)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, "hosts.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "0.0.0.0 ads.example.com\n0.0.0.0 tracker.example.com\n127.0.0.1 localhost\n"
+		if string(raw) != expected {
+			t.Errorf("invalid list converted:\n%s", raw)
+		}
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		if updated, err := d.Update(d.lists[0]); err != nil || updated {
+			t.Error("list updated, but the etag didn't change:", updated, err)
+		}
+		if requests != 2 {
+			t.Error("list not requested:", requests)
+		}
+	})
+
+	t.Run("sha256 mismatch", func(t *testing.T) {
+		rl := &RemoteList{URL: srv.URL + "/hosts", Name: "bad.txt", SHA256: "0000"}
+		if _, err := d.Update(rl); err == nil {
+			t.Error("list with invalid sha256 saved")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bad.txt")); err == nil {
+			t.Error("list with invalid sha256 written to disk")
+		}
+	})
+
+	t.Run("signature", func(t *testing.T) {
+		pubKey, err := d.get(srv.URL + "/pubkey")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rl := &RemoteList{URL: srv.URL + "/hosts", Name: "signed.txt", SignatureURL: srv.URL + "/hosts.sig", PublicKey: string(pubKey)}
+		if _, err := d.Update(rl); err != nil {
+			t.Error("signed list not saved:", err)
+		}
+
+		_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+		rl = &RemoteList{URL: srv.URL + "/hosts", Name: "badsig.txt", SignatureURL: srv.URL + "/hosts.sig",
+			PublicKey: base64.StdEncoding.EncodeToString(otherKey.Public().(ed25519.PublicKey))}
+		if _, err := d.Update(rl); err == nil {
+			t.Error("list with invalid signature saved")
+		}
+	})
+
+	t.Run("formats", func(t *testing.T) {
+		raw := []byte("ads.example.com\n# comment\n10.0.0.0/8 # net\n")
+		if out := d.convert(&RemoteList{Format: FormatDomains}, raw); string(out) != "0.0.0.0 ads.example.com\n0.0.0.0 10.0.0.0/8\n" {
+			t.Errorf("invalid domains conversion:\n%s", out)
+		}
+		netsDl := &Downloader{operand: OpNetLists}
+		if out := netsDl.convert(&RemoteList{}, raw); string(out) != "ads.example.com\n10.0.0.0/8\n" {
+			t.Errorf("invalid plain conversion:\n%s", out)
+		}
+		if out := netsDl.convert(&RemoteList{Format: FormatHosts}, remoteList); string(out) != "ads.example.com\ntracker.example.com\nlocalhost\n" {
+			t.Errorf("invalid hosts conversion:\n%s", out)
+		}
+	})
+
+	for _, invalid := range []string{`[{"url": "http://x"}]`, `[{"url": "http://x", "name": "../x"}]`, `{`} {
+		os.WriteFile(filepath.Join(dir, RemoteListsFile), []byte(invalid), 0600)
+		if _, err := NewDownloader(dir, OpDomainsLists); err == nil {
+			t.Error("invalid remote lists accepted:", invalid)
+		}
+	}
+}
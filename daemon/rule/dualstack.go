@@ -0,0 +1,113 @@
+package rule
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// Address families of the networks of the rules.
+const (
+	FamilyIPv4 = "IPv4"
+	FamilyIPv6 = "IPv6"
+)
+
+// dualStack enables the expansion of the IPv4 networks of the rules to the
+// equivalent IPv6 networks.
+var dualStack atomic.Bool
+
+// ipv6Scopes holds the IPv6 networks with the same scope of an IPv4 network.
+// The subnets of these networks, and the public networks, don't have an
+// equivalent IPv6 network, so they're not expanded.
+var ipv6Scopes = map[string][]string{
+	"0.0.0.0/0":      {"::/0"},
+	"127.0.0.0/8":    {"::1/128"},
+	"10.0.0.0/8":     {"fc00::/7", "fe80::/10"},
+	"172.16.0.0/12":  {"fc00::/7", "fe80::/10"},
+	"192.168.0.0/16": {"fc00::/7", "fe80::/10"},
+	"169.254.0.0/16": {"fe80::/10"},
+	"224.0.0.0/4":    {"ff00::/8"},
+}
+
+// SetDualStack enables or disables the expansion of the IPv4 networks of the
+// rules (10.0.0.0/8, LAN, ...) to the IPv6 networks of the same scope
+// (fc00::/7, ...), so the rules apply to the connections of both families.
+// The rules must be compiled again to apply it.
+func SetDualStack(enabled bool) {
+	dualStack.Store(enabled)
+}
+
+// DualStack returns true if the IPv4 networks of the rules are expanded.
+func DualStack() bool {
+	return dualStack.Load()
+}
+
+// expandDualStack returns the networks, plus the IPv6 networks equivalent to
+// the IPv4 ones if the dual-stack expansion is enabled.
+func expandDualStack(nets []*net.IPNet) []*net.IPNet {
+	if !DualStack() {
+		return nets
+	}
+	expanded := append([]*net.IPNet{}, nets...)
+	seen := make(map[string]bool, len(nets))
+	for _, n := range nets {
+		seen[n.String()] = true
+	}
+	for _, n := range nets {
+		for _, cidr := range ipv6Scopes[n.String()] {
+			if seen[cidr] {
+				continue
+			}
+			seen[cidr] = true
+			_, ipNet, _ := net.ParseCIDR(cidr)
+			expanded = append(expanded, ipNet)
+		}
+	}
+	return expanded
+}
+
+// networksFamily returns the only address family of the networks, or an empty
+// string if they belong to both families.
+func networksFamily(nets []*net.IPNet) string {
+	ipv4, ipv6 := false, false
+	for _, n := range nets {
+		if n.IP.To4() != nil {
+			ipv4 = true
+		} else {
+			ipv6 = true
+		}
+	}
+	if ipv4 == ipv6 {
+		return ""
+	}
+	if ipv4 {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// AddressFamily returns the only address family (IPv4, IPv6) of the
+// connections the rule applies to, because of the networks of its operators,
+// or an empty string if it applies to both.
+// The networks are expanded to IPv6 if the dual-stack expansion is enabled.
+func (r *Rule) AddressFamily() string {
+	return r.Operator.addressFamily()
+}
+
+func (o *Operator) addressFamily() string {
+	if o.Type == List {
+		for i := range o.List {
+			if family := o.List[i].addressFamily(); family != "" {
+				return family
+			}
+		}
+		return ""
+	}
+	if o.Type != Network {
+		return ""
+	}
+	nets, err := o.parseNetworks()
+	if err != nil || len(nets) == 0 {
+		return ""
+	}
+	return networksFamily(expandDualStack(nets))
+}
@@ -0,0 +1,71 @@
+package rule
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDualStack(t *testing.T) {
+	aliasFile := filepath.Join(t.TempDir(), "aliases.json")
+	os.WriteFile(aliasFile, []byte(`{"LAN": ["192.168.0.0/16", "::1/128"], "DNS": ["9.9.9.9/32"]}`), 0600)
+	if err := LoadAliases(aliasFile); err != nil {
+		t.Fatal("LoadAliases() error:", err)
+	}
+	defer func() {
+		delete(AliasIPCache, "LAN")
+		delete(AliasIPCache, "DNS")
+		SetDualStack(false)
+	}()
+
+	match := func(data, ip string) bool {
+		op, err := NewOperator(Network, false, OpDstNetwork, data, nil)
+		if err != nil {
+			t.Fatal("NewOperator() error:", err)
+		}
+		if err := op.Compile(); err != nil {
+			t.Fatal("Compile() error:", err)
+		}
+		c := *conn
+		c.DstIP = net.ParseIP(ip)
+		return op.Match(&c, false)
+	}
+	family := func(data string) string {
+		r := Create("test", "", true, false, false, Deny, Always, &Operator{Type: Network, Operand: OpDstNetwork, Data: data})
+		return r.AddressFamily()
+	}
+
+	if match("192.168.0.0/16", "fd00::1") || match("LAN", "fe80::1") {
+		t.Error("IPv4 networks expanded with the dual-stack expansion disabled")
+	}
+	if !match("LAN", "::1") || !match("LAN", "192.168.1.1") {
+		t.Error("networks of the aliases not matched")
+	}
+	if family("192.168.0.0/16") != FamilyIPv4 || family("fc00::/7") != FamilyIPv6 || family("LAN") != "" {
+		t.Error("invalid address families")
+	}
+
+	SetDualStack(true)
+	for _, ip := range []string{"fd00::1", "fe80::1", "192.168.1.1"} {
+		if !match("192.168.0.0/16", ip) || !match("LAN", ip) {
+			t.Error("network not expanded to IPv6:", ip)
+		}
+	}
+	if !match("0.0.0.0/0", "2001:db8::1") || !match("224.0.0.0/4", "ff02::1") {
+		t.Error("network not expanded to IPv6")
+	}
+	if match("192.168.1.0/24", "fd00::1") || match("10.0.0.0/8", "2001:db8::1") {
+		t.Error("network expanded beyond its scope")
+	}
+	if family("192.168.0.0/16") != "" || family("DNS") != FamilyIPv4 || family("192.168.1.0/24") != FamilyIPv4 {
+		t.Error("invalid address families with the dual-stack expansion enabled")
+	}
+	list := Create("list", "", true, false, false, Deny, Always, &Operator{Type: List, Operand: OpList, List: []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: "/usr/bin/curl"},
+		{Type: Network, Operand: OpDstNetwork, Data: "8.8.8.0/24"},
+	}})
+	if list.AddressFamily() != FamilyIPv4 {
+		t.Error("address family of the operators of a list not reported")
+	}
+}
@@ -2,8 +2,8 @@ package rule
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -33,14 +33,60 @@ type Loader struct {
 	liveReloadRunning bool
 	checkSums         atomic.Bool
 	stopLiveReload    chan struct{}
+	tracer            tracer
+	narrower          narrower
+	scheduler         scheduler
+	quarantine        quarantine
+	scoring           scoring
+	promptTemplates   []*PromptTemplate
+	// snapshots of the rules, created on every change.
+	history history
+	// incremented every time the active rules change.
+	generation atomic.Uint64
+	// evaluate all the rules against every connection, without preselecting
+	// them with the matcher.
+	linearMatch atomic.Bool
+	// address family of the rules that only match one, already logged.
+	singleFamily map[string]string
 
 	sync.RWMutex
 }
 
 type activeRulesSnapshot struct {
-	rules []*Rule
+	rules   []*Rule
+	matcher *matcher
+	// false if any rule matches fields that vary on every connection of a
+	// process to the same destination (source port, interfaces, ...)
+	cacheable bool
 }
 
+// operands that vary on every connection of a process to the same destination.
+var nonCacheableOperands = map[Operand]bool{
+	OpSrcIP:      true,
+	OpSrcPort:    true,
+	OpSrcNetwork: true,
+	OpIfaceIn:    true,
+	OpIfaceOut:   true,
+	// the payload varies on every connection.
+	OpProtoMismatch: true,
+	// the verdict varies with the time of the day.
+	OpSchedule: true,
+	// the connections of a proxy to the same destination are for different apps.
+	OpProcessOriginPath: true,
+	// the libraries of a process change while it runs (dlopen()).
+	OpProcessLibraryPath: true,
+}
+
+// Errors of the operations on the rules, to check with errors.Is()
+var (
+	ErrInvalidRule  = errors.New("invalid rule")
+	ErrRuleNotFound = errors.New("rule not found")
+)
+
+// listsGeneration is incremented every time the lists of the rules are
+// reloaded.
+var listsGeneration atomic.Uint64
+
 // NewLoader loads rules from disk, and watches for changes made to the rules files
 // on disk.
 func NewLoader(liveReload bool) (*Loader, error) {
@@ -55,6 +101,8 @@ func NewLoader(liveReload bool) (*Loader, error) {
 		watcher:           watcher,
 		liveReloadRunning: false,
 		stopLiveReload:    make(chan struct{}),
+		history:           history{max: DefaultMaxSnapshots},
+		singleFamily:      make(map[string]string),
 	}, nil
 }
 
@@ -84,10 +132,67 @@ func (l *Loader) GetAll() map[string]*Rule {
 	return snapshot
 }
 
+// GetOrdered returns the loaded rules (enabled and disabled) in the order
+// they're evaluated.
+func (l *Loader) GetOrdered() []*Rule {
+	l.RLock()
+	defer l.RUnlock()
+	ordered := make([]*Rule, 0, len(l.rules))
+	for _, r := range l.rules {
+		ordered = append(ordered, r)
+	}
+	sortByPriority(ordered)
+	return ordered
+}
+
+// Reorder sets the priority of the given rules to their position in the list,
+// so they're evaluated in that order. The rules saved on disk are updated.
+// Nothing is changed if any of the rules doesn't exist.
+func (l *Loader) Reorder(names []string) error {
+	changed := false
+	defer func() {
+		if changed {
+			l.recordChange(SourceDaemon, "reorder rules")
+		}
+	}()
+	l.Lock()
+	defer l.Unlock()
+
+	for _, name := range names {
+		if _, found := l.rules[name]; !found {
+			return fmt.Errorf("%w: %s", ErrRuleNotFound, name)
+		}
+	}
+	changed = true
+
+	var err error
+	for i, name := range names {
+		oldRule := l.rules[name]
+		if oldRule.Priority == int32(i) {
+			continue
+		}
+		// rules are replaced, not modified (see GetAll()).
+		newRule := oldRule.clone()
+		newRule.Priority = int32(i)
+		l.rules[name] = newRule
+
+		if newRule.Duration == Always {
+			fileName := filepath.Join(l.Path, fmt.Sprintf("%s.json", name))
+			if e := l.Save(newRule, fileName); e != nil {
+				err = e
+			}
+		}
+	}
+	l.sortRules()
+
+	return err
+}
+
 // EnableChecksums enables checksums field for rules globally.
 func (l *Loader) EnableChecksums(enable bool) {
 	log.Debug("[rules loader] EnableChecksums: %v", enable)
 	l.checkSums.Store(enable)
+	l.generation.Add(1)
 	procmon.EventsCache.SetComputeChecksums(enable)
 	procmon.EventsCache.AddChecksumHash(string(OpProcessHashMD5))
 }
@@ -132,10 +237,77 @@ func (l *Loader) Reload(path string) error {
 	l.activeRules = make([]string, 0)
 	l.rules = make(map[string]*Rule)
 	l.activeSnapshot.Store(nil)
+	l.generation.Add(1)
 	l.Unlock()
 	return l.Load(path)
 }
 
+// ValidatePath checks that the rules of a directory can be loaded, without
+// loading them: every file must be a valid rule, and the operators of the
+// enabled rules must compile. The lists of the operators are not loaded.
+func ValidatePath(path string) error {
+	if path == "" {
+		path = DefaultPath
+	}
+	if core.Exists(path) == false {
+		return fmt.Errorf("Path '%s' does not exist", path)
+	}
+	path, err := core.ExpandPath(path)
+	if err != nil {
+		return fmt.Errorf("Error accessing rules path: %s", err)
+	}
+	expr := filepath.Join(path, "*.json")
+	matches, err := filepath.Glob(expr)
+	if err != nil {
+		return fmt.Errorf("Error globbing '%s': %s", expr, err)
+	}
+
+	errs := []string{}
+	for _, fileName := range matches {
+		raw, err := core.ReadSealedFile(fileName)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", fileName, err))
+			continue
+		}
+		var r Rule
+		if err := json.Unmarshal(raw, &r); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", fileName, err))
+			continue
+		}
+		if !r.Enabled {
+			continue
+		}
+		if err := validateOperator(&r.Operator); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", fileName, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid rules: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// validateOperator compiles an operator and its list of operators, except
+// the operators of type lists, which would load the lists from disk.
+func validateOperator(op *Operator) error {
+	if op.Type == Lists {
+		switch op.Operand {
+		case OpDomainsLists, OpDomainsRegexpLists, OpIPLists, OpNetLists, OpHashMD5Lists:
+			return nil
+		}
+		return fmt.Errorf("Unknown Lists operand %s", op.Operand)
+	}
+	if err := op.Compile(); err != nil {
+		return err
+	}
+	for i := 0; i < len(op.List); i++ {
+		if err := validateOperator(&op.List[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Load loads rules files from disk.
 func (l *Loader) Load(path string) error {
 	log.Debug("rules.Loader.Load(): %s", path)
@@ -167,6 +339,15 @@ func (l *Loader) Load(path string) error {
 		}
 	}
 
+	if err := l.loadTemplates(); err != nil {
+		log.Warning("[templates] %s", err)
+	}
+	if err := l.loadPromptTemplates(); err != nil {
+		log.Warning("[prompts] %s", err)
+	}
+
+	l.recordChange(SourceFile, "load rules")
+
 	if l.liveReload && l.isLiveReloadRunning() == false {
 		go l.liveReloadWorker()
 	}
@@ -176,6 +357,7 @@ func (l *Loader) Load(path string) error {
 
 // Add adds a rule to the list of rules, and optionally saves it to disk.
 func (l *Loader) Add(rule *Rule, saveToDisk bool) error {
+	defer l.recordChange(SourceDaemon, "add rule")
 	l.addUserRule(rule)
 	if saveToDisk {
 		fileName := filepath.Join(l.Path, fmt.Sprintf("%s.json", rule.Name))
@@ -189,6 +371,7 @@ func (l *Loader) Replace(rule *Rule, saveToDisk bool) error {
 	if err := l.replaceUserRule(rule); err != nil {
 		return err
 	}
+	defer l.recordChange(SourceDaemon, "change rule")
 	if saveToDisk {
 		l.Lock()
 		defer l.Unlock()
@@ -208,7 +391,7 @@ func (l *Loader) Save(rule *Rule, path string) error {
 		return fmt.Errorf("Error while saving rule %s to %s: %s", rule, path, err)
 	}
 
-	if err = ioutil.WriteFile(path, raw, 0600); err != nil {
+	if err = core.WriteSealedFile(path, raw, 0600); err != nil {
 		return fmt.Errorf("Error while saving rule %s to %s: %s", rule, path, err)
 	}
 
@@ -219,6 +402,7 @@ func (l *Loader) Save(rule *Rule, path string) error {
 // If the duration is Always (i.e: saved on disk), it'll attempt to delete
 // it from disk.
 func (l *Loader) Delete(ruleName string) error {
+	defer l.recordChange(SourceDaemon, "delete rule")
 	l.Lock()
 	defer l.Unlock()
 
@@ -229,9 +413,11 @@ func (l *Loader) Delete(ruleName string) error {
 	l.cleanListsRule(rule)
 
 	delete(l.rules, ruleName)
+	delete(l.singleFamily, ruleName)
 	l.sortRules()
+	l.narrower.forget(ruleName)
 
-	if rule.Duration != Always {
+	if rule.Duration != Always || rule.Template != "" {
 		return nil
 	}
 
@@ -240,7 +426,7 @@ func (l *Loader) Delete(ruleName string) error {
 }
 
 func (l *Loader) loadRule(fileName string) error {
-	raw, err := ioutil.ReadFile(fileName)
+	raw, err := core.ReadSealedFile(fileName)
 	if err != nil {
 		return fmt.Errorf("Error while reading %s: %s", fileName, err)
 	}
@@ -282,6 +468,14 @@ func (l *Loader) loadRule(fileName string) error {
 		l.deleteOldRuleFromDisk(oldRule, &r)
 	}
 
+	if family := r.AddressFamily(); r.Enabled && family != "" && l.singleFamily[r.Name] != family {
+		log.Debug("rule %s only applies to %s connections, the connections of the other address family are not matched", r.Name, family)
+		if l.singleFamily == nil {
+			l.singleFamily = make(map[string]string)
+		}
+		l.singleFamily[r.Name] = family
+	}
+
 	log.Debug("Loaded rule from %s: %s", fileName, r.String())
 	l.rules[r.Name] = &r
 	l.sortRules()
@@ -387,18 +581,63 @@ func (l *Loader) unmarshalOperatorList(op *Operator) error {
 func (l *Loader) sortRules() {
 	l.activeRules = make([]string, 0, len(l.rules))
 	orderedRules := make([]*Rule, 0, len(l.rules))
-	for k, r := range l.rules {
+	for _, r := range l.rules {
 		// exclude not enabled rules from the list of active rules
 		if !r.Enabled {
 			continue
 		}
-		l.activeRules = append(l.activeRules, k)
+		orderedRules = append(orderedRules, r)
+	}
+	sortByPriority(orderedRules)
+	cacheable := true
+	for _, r := range orderedRules {
+		l.activeRules = append(l.activeRules, r.Name)
+		cacheable = cacheable && isCacheable(&r.Operator)
+	}
+	l.activeSnapshot.Store(&activeRulesSnapshot{
+		rules:     orderedRules,
+		matcher:   newMatcher(orderedRules),
+		cacheable: cacheable,
+	})
+	l.scheduler.update(orderedRules)
+	l.generation.Add(1)
+}
+
+func isCacheable(op *Operator) bool {
+	if nonCacheableOperands[op.Operand] {
+		return false
 	}
-	sort.Strings(l.activeRules)
-	for _, name := range l.activeRules {
-		orderedRules = append(orderedRules, l.rules[name])
+	for i := range op.List {
+		if !isCacheable(&op.List[i]) {
+			return false
+		}
 	}
-	l.activeSnapshot.Store(&activeRulesSnapshot{rules: orderedRules})
+	return true
+}
+
+// Generation returns a number that changes every time the rules, or the lists
+// of the rules, change. The verdicts obtained with a different generation
+// are no longer valid.
+func (l *Loader) Generation() uint64 {
+	return l.generation.Load() + listsGeneration.Load()
+}
+
+// Cacheable returns true if the verdicts of the connections of a process to
+// the same destination can be cached, i.e.: there're no rules that match the
+// source port of the connections, and no connections are being traced.
+func (l *Loader) Cacheable() bool {
+	snapshot := l.activeSnapshot.Load()
+	return snapshot != nil && snapshot.cacheable && l.tracer.active.Load() == 0
+}
+
+// sortByPriority sorts the rules by priority, and then by name.
+func sortByPriority(rules []*Rule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+		return rules[i].Name < rules[j].Name
+	})
 }
 
 func (l *Loader) addUserRule(rule *Rule) {
@@ -431,14 +670,14 @@ func (l *Loader) replaceUserRule(rule *Rule) (err error) {
 	if rule.Enabled {
 		if err := rule.Operator.Compile(); err != nil {
 			log.Warning("Operator.Compile() error: %s: %s", err, rule.Operator.Data)
-			return fmt.Errorf("(2) error compiling rule: %s", err)
+			return fmt.Errorf("%w, (2) error compiling rule: %s", ErrInvalidRule, err)
 		}
 
 		if rule.Operator.Type == List {
 			for i := 0; i < len(rule.Operator.List); i++ {
 				if err := rule.Operator.List[i].Compile(); err != nil {
 					log.Warning("Operator.Compile() error: %s: ", err)
-					return fmt.Errorf("(2) error compiling list rule: %s", err)
+					return fmt.Errorf("%w, (2) error compiling list rule: %s", ErrInvalidRule, err)
 				}
 			}
 		}
@@ -449,7 +688,10 @@ func (l *Loader) replaceUserRule(rule *Rule) (err error) {
 	l.Unlock()
 
 	if rule.Enabled && l.isTemporary(rule) {
+		l.narrower.watch(rule)
 		err = l.scheduleTemporaryRule(*rule)
+	} else {
+		l.narrower.forget(rule.Name)
 	}
 
 	return err
@@ -462,6 +704,13 @@ func (l *Loader) scheduleTemporaryRule(rule Rule) error {
 	}
 
 	time.AfterFunc(tTime, func() {
+		expired := false
+		// propose the narrowed rule once the lock is released.
+		defer func() {
+			if expired {
+				l.narrower.expire(rule.Name)
+			}
+		}()
 		l.Lock()
 		defer l.Unlock()
 
@@ -473,6 +722,7 @@ func (l *Loader) scheduleTemporaryRule(rule Rule) error {
 			}
 			delete(l.rules, rule.Name)
 			l.sortRules()
+			expired = true
 		}
 	})
 	return nil
@@ -487,17 +737,50 @@ func (l *Loader) liveReloadWorker() {
 		log.Error("Could not watch path: %s", err)
 		return
 	}
+	templatesDir := filepath.Join(l.Path, TemplatesDir)
+	if core.Exists(templatesDir) {
+		if err := l.watcher.Add(templatesDir); err != nil {
+			log.Warning("Could not watch templates path: %s", err)
+		}
+	}
+	promptsDir := filepath.Join(l.Path, PromptsDir)
+	if core.Exists(promptsDir) {
+		if err := l.watcher.Add(promptsDir); err != nil {
+			log.Warning("Could not watch prompts path: %s", err)
+		}
+	}
 
 	for {
 		select {
 		case <-l.stopLiveReload:
 			goto Exit
 		case event := <-l.watcher.Events:
+			if l.isTemplateFile(event.Name) {
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					log.Important("Templates changed due to %s, expanding ...", path.Base(event.Name))
+					if err := l.loadTemplates(); err != nil {
+						log.Warning("[templates] %s", err)
+					}
+				}
+				continue
+			}
+			if l.isPromptFile(event.Name) {
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					log.Important("Prompt templates changed due to %s, reloading ...", path.Base(event.Name))
+					if err := l.loadPromptTemplates(); err != nil {
+						log.Warning("[prompts] %s", err)
+					}
+				}
+				continue
+			}
 			// a new rule json file has been created or updated
 			if event.Op&fsnotify.Write == fsnotify.Write {
 				if strings.HasSuffix(event.Name, ".json") {
 					log.Important("Ruleset changed due to %s, reloading ...", path.Base(event.Name))
-					if err := l.loadRule(event.Name); err != nil {
+					err := l.Track(SourceFile, "rule file changed: "+path.Base(event.Name), func() error {
+						return l.loadRule(event.Name)
+					})
+					if err != nil {
 						log.Warning("%s", err)
 					}
 				}
@@ -506,7 +789,10 @@ func (l *Loader) liveReloadWorker() {
 					log.Important("Rule deleted %s", path.Base(event.Name))
 					// we only need to delete from memory rules of type Always,
 					// because the Remove event is of a file, i.e.: Duration == Always
-					l.deleteRule(event.Name)
+					l.Track(SourceFile, "rule file deleted: "+path.Base(event.Name), func() error {
+						l.deleteRule(event.Name)
+						return nil
+					})
 				}
 			}
 		case err := <-l.watcher.Errors:
@@ -524,18 +810,114 @@ func (l *Loader) FindFirstMatch(con *conman.Connection) (match *Rule) {
 		return nil
 	}
 	hasChecksums := l.checkSums.Load()
-
-	for _, rule := range snapshot.rules {
+	defer func() {
+		l.narrower.record(match, con)
+		l.scheduler.record(match, con)
+	}()
+	if tr := l.tracer.take(con); tr != nil {
+		return l.findFirstMatchTraced(snapshot.rules, con, hasChecksums, tr)
+	}
+
+	scoringCfg := l.scoringConfig()
+	score := connScore{}
+	final := false
+	evaluate := func(rule *Rule) bool {
+		if rule.Score != 0 {
+			if scoringCfg.Enabled && rule.Match(con, hasChecksums) {
+				score.add(rule)
+			}
+			return true
+		}
 		if rule.Match(con, hasChecksums) {
 			// We have a match.
 			// Save the rule in order to don't ask the user to take action,
 			// and keep iterating until a Deny or a Priority rule appears.
 			match = rule
-			if rule.Action == Reject || rule.Action == Deny || rule.Precedence == true {
-				return rule
+			final = rule.Action == Reject || rule.Action == Deny || rule.Precedence == true
+		}
+		return !final
+	}
+	if l.linearMatch.Load() {
+		for _, rule := range snapshot.rules {
+			if !evaluate(rule) {
+				break
 			}
 		}
+	} else {
+		snapshot.matcher.each(con, evaluate)
+	}
+	if final {
+		return match
+	}
+	if scoringCfg.Enabled {
+		match = score.verdict(scoringCfg, match, con)
+	}
+	if match == nil && hasChecksums {
+		match = l.quarantineMismatch(snapshot.rules, con)
 	}
 
 	return match
 }
+
+// SetLinearMatch evaluates all the rules against every connection, one by
+// one, instead of only the rules that may match it. Both give the same
+// verdicts, it's meant to compare them.
+func (l *Loader) SetLinearMatch(linear bool) {
+	l.linearMatch.Store(linear)
+}
+
+// FindFirstListenerMatch matches a socket listening for connections against
+// the rules with the operand listener. The rest of the rules only apply to
+// outgoing connections.
+func (l *Loader) FindFirstListenerMatch(con *conman.Connection) (match *Rule) {
+	snapshot := l.activeSnapshot.Load()
+	if snapshot == nil {
+		return nil
+	}
+	hasChecksums := l.checkSums.Load()
+	for _, rule := range snapshot.rules {
+		if rule.Score != 0 || !hasOperand(&rule.Operator, OpListener) || !rule.Match(con, hasChecksums) {
+			continue
+		}
+		match = rule
+		if rule.Action == Reject || rule.Action == Deny || rule.Precedence == true {
+			return rule
+		}
+	}
+
+	return match
+}
+
+// Jails returns the binaries jailed by the enabled rules, with the
+// destinations they're allowed to connect to.
+func (l *Loader) Jails() map[string][]string {
+	jails := make(map[string][]string)
+	snapshot := l.activeSnapshot.Load()
+	if snapshot == nil {
+		return jails
+	}
+	for _, rule := range snapshot.rules {
+		if !rule.Jail {
+			continue
+		}
+		path, dsts := rule.JailDestinations()
+		if path == "" {
+			log.Warning("[rules] %s: only the binaries of the operand process.path can be jailed", rule.Name)
+			continue
+		}
+		jails[path] = append(jails[path], dsts...)
+	}
+	return jails
+}
+
+func hasOperand(op *Operator, operand Operand) bool {
+	if op.Operand == operand {
+		return true
+	}
+	for i := range op.List {
+		if hasOperand(&op.List[i], operand) {
+			return true
+		}
+	}
+	return false
+}
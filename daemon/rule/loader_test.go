@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"os"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
 )
 
 var tmpDir string
@@ -232,6 +236,61 @@ func TestRuleLoaderGetAllSnapshot(t *testing.T) {
 	wg.Wait()
 }
 
+func TestRuleLoaderPriority(t *testing.T) {
+	t.Parallel()
+	t.Log("Test rules priority")
+
+	rulesDir := t.TempDir()
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(rulesDir); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+
+	for _, name := range []string{"000-allow-curl", "001-deny-all", "002-allow-wget"} {
+		op, _ := NewOperator(Simple, false, OpTrue, "", make([]Operator, 0))
+		r := Create(name, "", true, false, false, Allow, Always, op)
+		if err = l.Add(r, true); err != nil {
+			t.Fatal("Error adding rule: ", err)
+		}
+	}
+	if l.activeRules[0] != "000-allow-curl" || l.activeRules[2] != "002-allow-wget" {
+		t.Error("Rules not ordered by name: ", l.activeRules)
+	}
+
+	if err = l.Reorder([]string{"002-allow-wget", "000-allow-curl", "001-deny-all"}); err != nil {
+		t.Error("Reorder() error: ", err)
+	}
+	ordered := l.GetOrdered()
+	if ordered[0].Name != "002-allow-wget" || ordered[1].Name != "000-allow-curl" || ordered[2].Name != "001-deny-all" {
+		t.Error("Rules not reordered: ", ordered[0].Name, ordered[1].Name, ordered[2].Name)
+	}
+	if l.activeRules[0] != "002-allow-wget" || l.activeRules[1] != "000-allow-curl" {
+		t.Error("Active rules not reordered: ", l.activeRules)
+	}
+	if l.rules["001-deny-all"].Serialize().Priority != 2 {
+		t.Error("Priority not serialized: ", l.rules["001-deny-all"].Serialize().Priority)
+	}
+
+	// the priority must be persisted to disk
+	l2, _ := NewLoader(false)
+	if err = l2.Load(rulesDir); err != nil {
+		t.Fatal("Error reloading rules path: ", err)
+	}
+	if l2.activeRules[0] != "002-allow-wget" {
+		t.Error("Priority not saved to disk: ", l2.activeRules)
+	}
+
+	if err = l.Reorder([]string{"001-deny-all", "non-existent"}); err == nil {
+		t.Error("Reorder() of a non existent rule should fail")
+	}
+	if ordered = l.GetOrdered(); ordered[0].Name != "002-allow-wget" || l.rules["001-deny-all"].Priority != 2 {
+		t.Error("Rules reordered partially: ", ordered[0].Name, l.rules["001-deny-all"].Priority)
+	}
+}
+
 func TestLiveReload(t *testing.T) {
 	t.Parallel()
 	t.Log("Test rules loader with live reload")
@@ -410,3 +469,204 @@ func testDurationChange(t *testing.T, l *Loader) {
 		t.Error("testDurationChange, error: rule has been deleted")
 	}
 }
+
+func TestRuleLoaderGeneration(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+
+	gen := l.Generation()
+	op, _ := NewOperator(Simple, false, OpDstPort, "443", make([]Operator, 0))
+	if err = l.Add(Create("000-allow-https", "", true, false, false, Allow, Always, op), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+	if l.Generation() == gen {
+		t.Error("the generation should change when the rules change")
+	}
+	if !l.Cacheable() {
+		t.Error("rules matching the destination should be cacheable")
+	}
+
+	gen = l.Generation()
+	listOp, _ := NewOperator(List, false, OpList, "", []Operator{
+		{Type: Simple, Operand: OpSrcPort, Data: "5353"},
+	})
+	compileListOperators(&listOp.List, t)
+	if err = l.Add(Create("001-deny-mdns", "", true, false, false, Deny, Always, listOp), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+	if l.Generation() == gen {
+		t.Error("the generation should change when the rules change")
+	}
+	if l.Cacheable() {
+		t.Error("rules matching the source port should not be cacheable")
+	}
+}
+
+func TestRuleLoaderListeners(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+
+	allOp, _ := NewOperator(Simple, false, OpTrue, "", make([]Operator, 0))
+	if err = l.Add(Create("000-deny-all", "", true, false, false, Deny, Always, allOp), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+	listOp, _ := NewOperator(List, false, OpList, "", []Operator{
+		{Type: Simple, Operand: OpListener, Data: "true"},
+		{Type: Simple, Operand: OpProcessPath, Data: defaultProcPath},
+	})
+	compileListOperators(&listOp.List, t)
+	if err = l.Add(Create("001-deny-listener", "", true, false, false, Deny, Always, listOp), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+
+	listener := conman.NewListener(proc, "tcp", net.IPv4zero, 8080, defaultUserID, 1234)
+	if r := l.FindFirstListenerMatch(listener); r == nil || r.Name != "001-deny-listener" {
+		t.Error("listener not matched by the rule with the operand listener:", r)
+	}
+	if r := l.FindFirstMatch(conn); r == nil || r.Name != "000-deny-all" {
+		t.Error("outgoing connection matched by the rule with the operand listener:", r)
+	}
+
+	other := conman.NewListener(&procmon.Process{ID: 1, Path: "/usr/sbin/sshd"}, "tcp", net.IPv4zero, 22, 0, 4321)
+	if r := l.FindFirstListenerMatch(other); r != nil {
+		t.Error("listener matched by a rule of the outgoing connections:", r.Name)
+	}
+}
+
+func TestRuleLoaderJails(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+	add := func(name string, action Action, jail bool, list []Operator) {
+		listOp, _ := NewOperator(List, false, OpList, "", list)
+		compileListOperators(&listOp.List, t)
+		r := Create(name, "", true, false, false, action, Always, listOp)
+		r.Jail = jail
+		if err := l.Add(r, false); err != nil {
+			t.Fatal("Error adding rule: ", err)
+		}
+	}
+	add("000-jail-dns", Allow, true, []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: defaultProcPath},
+		{Type: Simple, Operand: OpDstIP, Data: "9.9.9.9"},
+	})
+	add("001-jail-lan", Allow, true, []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: defaultProcPath},
+		{Type: Network, Operand: OpDstNetwork, Data: "192.168.1.0/24"},
+	})
+	add("002-jail-deny", Deny, true, []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: "/usr/bin/curl"},
+		{Type: Simple, Operand: OpDstIP, Data: "1.1.1.1"},
+	})
+	add("003-not-jailed", Allow, false, []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: "/usr/bin/wget"},
+		{Type: Simple, Operand: OpDstIP, Data: "1.1.1.1"},
+	})
+
+	jails := l.Jails()
+	if len(jails) != 2 {
+		t.Fatal("invalid jails:", jails)
+	}
+	if dsts := jails[defaultProcPath]; len(dsts) != 2 || dsts[0] != "9.9.9.9" || dsts[1] != "192.168.1.0/24" {
+		t.Error("invalid destinations of the jail:", dsts)
+	}
+	if dsts, found := jails["/usr/bin/curl"]; !found || len(dsts) != 0 {
+		t.Error("the rules that deny connections should jail the binary without destinations:", dsts)
+	}
+}
+
+func TestRuleLoaderChecksumQuarantine(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+	l.EnableChecksums(true)
+	listOp, _ := NewOperator(List, false, OpList, "", []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: "/usr/bin/curl"},
+		{Type: Simple, Operand: OpProcessHashMD5, Data: "aaa"},
+	})
+	compileListOperators(&listOp.List, t)
+	if err = l.Add(Create("000-allow-curl", "", true, false, false, Allow, Always, listOp), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+
+	curlProc := procmon.NewProcessEmpty(1, "curl")
+	curlProc.Path = "/usr/bin/curl"
+	curlProc.Checksums[procmon.HashMD5] = "bbb"
+	curl := &conman.Connection{
+		DstIP:   net.ParseIP(defaultDstIP),
+		DstPort: defaultDstPort,
+		Process: curlProc,
+		Entry:   netEntry,
+	}
+	if r := l.FindFirstMatch(curl); r != nil {
+		t.Error("binary quarantined with the quarantine disabled:", r.Name)
+	}
+
+	var quarantined, failed *Rule
+	l.OnChecksumQuarantine(func(q, f *Rule, con *conman.Connection) {
+		quarantined, failed = q, f
+	})
+	l.SetChecksumQuarantine(time.Hour)
+	if r := l.FindFirstMatch(conn); r != nil {
+		t.Error("binary of other rule quarantined:", r.Name)
+	}
+	r := l.FindFirstMatch(curl)
+	if r == nil || r.Name != QuarantineRulePrefix+"curl" || r.Action != Deny || r.Duration != "1h0m0s" {
+		t.Fatal("binary with a checksum mismatch not quarantined:", r)
+	}
+	if quarantined != r || failed == nil || failed.Name != "000-allow-curl" {
+		t.Error("quarantine not notified:", quarantined, failed)
+	}
+	if r := l.FindFirstMatch(curl); r == nil || r.Name != QuarantineRulePrefix+"curl" {
+		t.Error("connections of the binary quarantined not denied:", r)
+	}
+
+	curlProc.Checksums[procmon.HashMD5] = "aaa"
+	if r := l.FindFirstMatch(curl); r == nil || r.Name != QuarantineRulePrefix+"curl" {
+		t.Error("the quarantine should be evaluated before the rest of the rules:", r)
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	dir := t.TempDir()
+	if err := ValidatePath(dir + "/non-existent"); err == nil {
+		t.Error("non existent path validated")
+	}
+	if err := Copy("testdata/000-allow-chrome.json", dir+"/000-allow-chrome.json"); err != nil {
+		t.Fatal("Error copying rule into a temp dir")
+	}
+	if err := Copy("testdata/rule-disabled-operator-list.json", dir+"/rule-disabled-operator-list.json"); err != nil {
+		t.Fatal("Error copying rule into a temp dir")
+	}
+	if err := ValidatePath(dir); err != nil {
+		t.Error("valid rules not validated:", err)
+	}
+
+	if err := Copy("testdata/invalid-regexp-list.json", dir+"/invalid-regexp-list.json"); err != nil {
+		t.Fatal("Error copying rule into a temp dir")
+	}
+	if err := ValidatePath(dir); err == nil {
+		t.Error("invalid rule validated")
+	}
+	if err := ValidatePath("testdata/"); err == nil {
+		t.Error("invalid rules of testdata/ validated")
+	}
+}
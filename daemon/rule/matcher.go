@@ -0,0 +1,350 @@
+package rule
+
+import (
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+)
+
+// indexKind is how the value of a connection is compared with the value of
+// an indexed condition.
+type indexKind uint8
+
+const (
+	// simple: the value of the connection is the value of the condition.
+	exactIndex indexKind = iota
+	// regexp ^/usr/lib/firefox/...: the path is in the directory of the
+	// condition, or in any of its subdirectories.
+	prefixIndex
+	// regexp ...example\.com$, domain +.example.com: the domain is the domain
+	// of the condition, or any of its subdomains.
+	suffixIndex
+)
+
+type indexedCondition struct {
+	operand Operand
+	kind    indexKind
+}
+
+// indexedConditions are the conditions the rules are indexed by, the most
+// selective first.
+var indexedConditions = [...]indexedCondition{
+	{OpProcessPath, exactIndex},
+	{OpDstHost, exactIndex},
+	{OpDstIP, exactIndex},
+	{OpDstHost, suffixIndex},
+	{OpProcessPath, prefixIndex},
+	{OpUserID, exactIndex},
+	{OpDstPort, exactIndex},
+	{OpProto, exactIndex},
+}
+
+// matcher preselects the rules that may match a connection, so they're not
+// evaluated one by one: the rules are indexed by a condition that all the
+// connections they match must meet (process.path == /usr/bin/curl, ...), and
+// only the rules indexed by the values of the connection are evaluated,
+// in the same order (priority and name) as the rest of the rules.
+//
+// The rest of the rules (networks, lists, regexps not anchored, ...) are
+// evaluated against all the connections.
+type matcher struct {
+	// condition -> value (case folded) -> positions of the rules.
+	index map[indexedCondition]map[string][]int
+	// positions of the rules without any condition indexed.
+	unindexed []int
+	rules     []*Rule
+}
+
+// newMatcher indexes the rules, sorted in the order they're evaluated.
+func newMatcher(rules []*Rule) *matcher {
+	m := &matcher{
+		index: make(map[indexedCondition]map[string][]int),
+		rules: rules,
+	}
+	for pos, r := range rules {
+		cond, key, found := indexCondition(&r.Operator)
+		if !found {
+			m.unindexed = append(m.unindexed, pos)
+			continue
+		}
+		if m.index[cond] == nil {
+			m.index[cond] = make(map[string][]int)
+		}
+		m.index[cond][key] = append(m.index[cond][key], pos)
+	}
+	return m
+}
+
+// each calls fn with the rules that may match the connection, in order,
+// until it returns false.
+func (m *matcher) each(con *conman.Connection, fn func(r *Rule) bool) {
+	var buf [16][]int
+	lists := buf[:0]
+	if len(m.unindexed) > 0 {
+		lists = append(lists, m.unindexed)
+	}
+	for _, cond := range indexedConditions {
+		values, found := m.index[cond]
+		if !found {
+			continue
+		}
+		value, found := connValue(cond.operand, con)
+		if !found {
+			continue
+		}
+		switch cond.kind {
+		case exactIndex:
+			if pos := values[foldKey(value)]; len(pos) > 0 {
+				lists = append(lists, pos)
+			}
+		case prefixIndex:
+			// /usr/lib/firefox/firefox: "", /usr, /usr/lib, /usr/lib/firefox
+			key := foldKey(strings.ToLower(value))
+			for i := 0; i < len(key); i++ {
+				if key[i] != '/' {
+					continue
+				}
+				if pos := values[key[:i]]; len(pos) > 0 {
+					lists = append(lists, pos)
+				}
+			}
+		case suffixIndex:
+			// www.example.com: www.example.com, example.com, com
+			key := strings.TrimSuffix(foldKey(strings.ToLower(value)), ".")
+			if pos := values[key]; len(pos) > 0 {
+				lists = append(lists, pos)
+			}
+			for i := 0; i < len(key); i++ {
+				if key[i] != '.' {
+					continue
+				}
+				if pos := values[key[i+1:]]; len(pos) > 0 {
+					lists = append(lists, pos)
+				}
+			}
+		}
+	}
+
+	// merge the positions of the rules of every list.
+	for {
+		next := -1
+		for i := range lists {
+			if len(lists[i]) > 0 && (next == -1 || lists[i][0] < lists[next][0]) {
+				next = i
+			}
+		}
+		if next == -1 {
+			return
+		}
+		r := m.rules[lists[next][0]]
+		lists[next] = lists[next][1:]
+		if !fn(r) {
+			return
+		}
+	}
+}
+
+// indexCondition returns the most selective condition of an operator that
+// can be indexed, and its key, looking into the operators of the lists too
+// (all of them must match).
+func indexCondition(op *Operator) (cond indexedCondition, key string, found bool) {
+	if op.Type == List {
+		best := len(indexedConditions)
+		for i := range op.List {
+			c, k, ok := indexCondition(&op.List[i])
+			if !ok {
+				continue
+			}
+			if rank := conditionRank(c); rank < best {
+				best, cond, key, found = rank, c, k, true
+			}
+		}
+		return
+	}
+
+	switch {
+	case op.Type == Simple:
+		cond = indexedCondition{op.Operand, exactIndex}
+		if op.Operand == OpDstPort && isPortsExpr(op.Data) {
+			return cond, "", false
+		}
+		key = foldKey(op.Data)
+	case op.Type == Regexp && op.Operand == OpProcessPath:
+		cond = indexedCondition{op.Operand, prefixIndex}
+		if key, found = pathPrefix(op.Data); !found {
+			return
+		}
+		key = foldKey(strings.ToLower(key))
+	case op.Type == Regexp && op.Operand == OpDstHost:
+		cond = indexedCondition{op.Operand, suffixIndex}
+		if key, found = domainSuffix(op.Data); !found {
+			return
+		}
+		key = foldKey(strings.ToLower(key))
+	case op.Type == Domain && op.Operand == OpDstHost:
+		cond = indexedCondition{op.Operand, suffixIndex}
+		if key, found = domainsSuffix(op.Data); !found {
+			return
+		}
+		key = foldKey(key)
+	default:
+		return
+	}
+	return cond, key, conditionRank(cond) < len(indexedConditions)
+}
+
+func conditionRank(cond indexedCondition) int {
+	for i, c := range indexedConditions {
+		if c == cond {
+			return i
+		}
+	}
+	return len(indexedConditions)
+}
+
+// connValue returns the value of an operand of a connection, as compared by
+// Operator.Match().
+func connValue(operand Operand, con *conman.Connection) (string, bool) {
+	switch operand {
+	case OpProcessPath:
+		if con.Process == nil {
+			return "", false
+		}
+		return con.Process.Path, true
+	case OpDstHost:
+		return con.DstHost, true
+	case OpDstIP:
+		return con.DstIP.String(), true
+	case OpUserID:
+		if con.Entry == nil {
+			return "", false
+		}
+		return strconv.Itoa(con.Entry.UserId), true
+	case OpDstPort:
+		return strconv.FormatUint(uint64(con.DstPort), 10), true
+	case OpProto:
+		return con.Protocol, true
+	}
+	return "", false
+}
+
+// pathPrefix returns the directory all the paths matched by a regexp are in:
+// ^/usr/lib/firefox/.* -> /usr/lib/firefox
+func pathPrefix(expr string) (string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[0].Op != syntax.OpBeginText {
+		return "", false
+	}
+	var literal []rune
+	for _, sub := range re.Sub[1:] {
+		if !isLiteral(sub) {
+			break
+		}
+		literal = append(literal, sub.Rune...)
+	}
+	prefix := string(literal)
+	if !strings.HasPrefix(prefix, "/") {
+		return "", false
+	}
+	return prefix[:strings.LastIndexByte(prefix, '/')], true
+}
+
+// domainSuffix returns the domain all the domains matched by a regexp are, or
+// are subdomains of:
+// (^|\.)example\.com$ -> example.com
+// .*example\.com$ -> com
+func domainSuffix(expr string) (string, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 || re.Sub[len(re.Sub)-1].Op != syntax.OpEndText {
+		return "", false
+	}
+	var literal []rune
+	i := len(re.Sub) - 2
+	for ; i >= 0 && isLiteral(re.Sub[i]); i-- {
+		literal = append(append([]rune{}, re.Sub[i].Rune...), literal...)
+	}
+	suffix := string(literal)
+	// the first label may be partial (xexample.com), unless it's preceded by
+	// the start of the domain or a dot.
+	if !atLabelStart(re.Sub[:i+1]) {
+		dot := strings.IndexByte(suffix, '.')
+		if dot == -1 {
+			return "", false
+		}
+		suffix = suffix[dot+1:]
+	}
+	return suffix, suffix != ""
+}
+
+// atLabelStart returns true if the text matched by a sequence of expressions
+// can only end at the start of a domain label: at the start of the domain,
+// or after a dot.
+func atLabelStart(seq []*syntax.Regexp) bool {
+	if len(seq) == 0 {
+		return false
+	}
+	last := seq[len(seq)-1]
+	prev := seq[: len(seq)-1 : len(seq)-1]
+	switch last.Op {
+	case syntax.OpBeginText:
+		return true
+	case syntax.OpLiteral:
+		return isLiteral(last) && last.Rune[len(last.Rune)-1] == '.'
+	case syntax.OpCapture, syntax.OpPlus:
+		return atLabelStart(append(prev, last.Sub[0]))
+	case syntax.OpConcat:
+		return atLabelStart(append(prev, last.Sub...))
+	case syntax.OpQuest, syntax.OpStar:
+		return atLabelStart(append(prev, last.Sub[0])) && atLabelStart(prev)
+	case syntax.OpAlternate:
+		for _, alt := range last.Sub {
+			if !atLabelStart(append(prev, alt)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isLiteral(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0 && len(re.Rune) > 0
+}
+
+// foldKey returns the same key for the strings that are equal under case
+// folding (strings.EqualFold), which is how the simple conditions are
+// compared by default: every rune is replaced by the lowest rune of its
+// folding orbit (k, K, and the Kelvin sign -> K).
+func foldKey(s string) string {
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return strings.ToUpper(s)
+	}
+	return strings.Map(func(r rune) rune {
+		min := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < min {
+				min = f
+			}
+		}
+		return min
+	}, s)
+}
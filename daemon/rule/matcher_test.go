@@ -0,0 +1,263 @@
+package rule
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+var (
+	benchPaths = []string{"/usr/bin/curl", "/usr/bin/wget", "/usr/lib/firefox/firefox", "/usr/bin/ssh", "/opt/app/app"}
+	benchHosts = []string{"opensnitch.io", "github.com", "example.org", "debian.org", ""}
+	benchIPs   = []string{"1.1.1.1", "9.9.9.9", "192.168.1.1", "2606:4700::1111"}
+	benchPorts = []string{"53", "80", "443", "22", "8080"}
+)
+
+// newBenchRule returns a rule with one or more conditions, of different
+// types, picked randomly.
+func newRandomRule(t testing.TB, rnd *rand.Rand, i int) *Rule {
+	conditions := []func() Operator{
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpProcessPath, Data: benchPaths[rnd.Intn(len(benchPaths))]}
+		},
+		func() Operator {
+			// case insensitive by default.
+			return Operator{Type: Simple, Operand: OpDstHost, Data: "GitHub.com"}
+		},
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpDstHost, Data: benchHosts[rnd.Intn(len(benchHosts))], Sensitive: true}
+		},
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpDstIP, Data: benchIPs[rnd.Intn(len(benchIPs))]}
+		},
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpDstPort, Data: benchPorts[rnd.Intn(len(benchPorts))]}
+		},
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpDstPort, Data: "80,443,8000-8100"}
+		},
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpProto, Data: []string{"tcp", "udp", "TCP6"}[rnd.Intn(3)]}
+		},
+		func() Operator {
+			return Operator{Type: Simple, Operand: OpUserID, Data: []string{"0", "1000"}[rnd.Intn(2)]}
+		},
+		func() Operator {
+			return Operator{Type: Regexp, Operand: OpDstHost, Data: `(^|\.)(debian|example)\.org$`}
+		},
+		func() Operator {
+			return Operator{Type: Regexp, Operand: OpDstHost, Data: []string{`^(.*\.)?GitHub\.com$`, `.*hub\.com$`, `^debian\.org$`, `(?i)\.ORG$`}[rnd.Intn(4)]}
+		},
+		func() Operator {
+			return Operator{Type: Regexp, Operand: OpProcessPath, Data: []string{`^/usr/lib/`, `^/usr/bin/(curl|wget)$`, `^/USR/bin`, `/bin/`}[rnd.Intn(4)], Sensitive: rnd.Intn(2) == 0}
+		},
+		func() Operator {
+			return Operator{Type: Domain, Operand: OpDstHost, Data: []string{"+.github.com", "*.debian.org", "example.org, opensnitch.io"}[rnd.Intn(3)]}
+		},
+		func() Operator {
+			return Operator{Type: Network, Operand: OpDstNetwork, Data: "192.168.0.0/16"}
+		},
+	}
+	var op Operator
+	if n := rnd.Intn(4); n == 0 {
+		op = conditions[rnd.Intn(len(conditions))]()
+	} else {
+		op = Operator{Type: List, Operand: OpList}
+		for j := 0; j <= n; j++ {
+			op.List = append(op.List, conditions[rnd.Intn(len(conditions))]())
+		}
+	}
+	if rnd.Intn(50) == 0 {
+		op = Operator{Type: Simple, Operand: OpTrue}
+	}
+	action := []Action{Allow, Allow, Deny, Reject}[rnd.Intn(4)]
+	r := Create(fmt.Sprintf("%04d-rule", i), "", true, rnd.Intn(10) == 0, false, action, Always, &op)
+	if err := compileRule(&r.Operator); err != nil {
+		t.Fatal("error compiling rule:", err)
+	}
+	return r
+}
+
+func compileRule(op *Operator) error {
+	if err := op.Compile(); err != nil {
+		return err
+	}
+	for i := range op.List {
+		if err := compileRule(&op.List[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRandomLoader(t testing.TB, numRules int) *Loader {
+	rnd := rand.New(rand.NewSource(int64(numRules)))
+	l := &Loader{rules: make(map[string]*Rule)}
+	for i := 0; i < numRules; i++ {
+		r := newRandomRule(t, rnd, i)
+		l.rules[r.Name] = r
+	}
+	l.sortRules()
+	return l
+}
+
+func newRandomConnections(n int) []*conman.Connection {
+	rnd := rand.New(rand.NewSource(1))
+	cons := make([]*conman.Connection, n)
+	for i := range cons {
+		cons[i] = conman.Deserialize(&protocol.Connection{
+			Protocol:    []string{"tcp", "udp", "tcp6", "TCP"}[rnd.Intn(4)],
+			DstIp:       benchIPs[rnd.Intn(len(benchIPs))],
+			DstHost:     []string{"opensnitch.io", "github.com", "www.GITHUB.COM", "www.debian.org", "example.org", "debian.org", "xgithub.com", ""}[rnd.Intn(8)],
+			DstPort:     uint32([]int{53, 80, 443, 22, 8080, 8050}[rnd.Intn(6)]),
+			UserId:      uint32([]int{0, 1000}[rnd.Intn(2)]),
+			ProcessPath: benchPaths[rnd.Intn(len(benchPaths))],
+		})
+	}
+	return cons
+}
+
+func TestMatcher(t *testing.T) {
+	l := newRandomLoader(t, 500)
+	matched := 0
+	for i, con := range newRandomConnections(2000) {
+		l.SetLinearMatch(true)
+		expected := l.FindFirstMatch(con)
+		l.SetLinearMatch(false)
+		got := l.FindFirstMatch(con)
+		if got != expected {
+			t.Fatalf("connection %d (%s): unexpected rule matched: %v, expected: %v", i, con, got, expected)
+		}
+		if got != nil {
+			matched++
+		}
+	}
+	if matched == 0 {
+		t.Error("no connection matched")
+	}
+
+	t.Run("unindexed", func(t *testing.T) {
+		m := newMatcher(l.activeSnapshot.Load().rules)
+		for _, pos := range m.unindexed {
+			if _, _, found := indexCondition(&m.rules[pos].Operator); found {
+				t.Errorf("rule %s not indexed", m.rules[pos].Name)
+			}
+		}
+		if len(m.unindexed) == len(m.rules) {
+			t.Error("no rule indexed")
+		}
+	})
+}
+
+func TestIndexCondition(t *testing.T) {
+	tests := []struct {
+		op    *Operator
+		cond  indexedCondition
+		key   string
+		found bool
+	}{
+		{&Operator{Type: Simple, Operand: OpDstHost, Data: "GitHub.com"}, indexedCondition{OpDstHost, exactIndex}, "GITHUB.COM", true},
+		{&Operator{Type: Simple, Operand: OpDstPort, Data: "443"}, indexedCondition{OpDstPort, exactIndex}, "443", true},
+		{&Operator{Type: Simple, Operand: OpDstPort, Data: "80,443"}, indexedCondition{}, "", false},
+		{&Operator{Type: Simple, Operand: OpProcessCmd, Data: "curl"}, indexedCondition{}, "", false},
+		{&Operator{Type: Regexp, Operand: OpDstHost, Data: `(^|\.)example\.com$`}, indexedCondition{OpDstHost, suffixIndex}, "EXAMPLE.COM", true},
+		{&Operator{Type: Regexp, Operand: OpDstHost, Data: `^(.*\.)?example\.com$`}, indexedCondition{OpDstHost, suffixIndex}, "EXAMPLE.COM", true},
+		{&Operator{Type: Regexp, Operand: OpDstHost, Data: `.*example\.com$`}, indexedCondition{OpDstHost, suffixIndex}, "COM", true},
+		{&Operator{Type: Regexp, Operand: OpDstHost, Data: `example\.com`}, indexedCondition{}, "", false},
+		{&Operator{Type: Regexp, Operand: OpDstHost, Data: `(?i)example\.com$`}, indexedCondition{}, "", false},
+		{&Operator{Type: Regexp, Operand: OpProcessPath, Data: `^/usr/lib/firefox/.*`}, indexedCondition{OpProcessPath, prefixIndex}, "/USR/LIB/FIREFOX", true},
+		{&Operator{Type: Regexp, Operand: OpProcessPath, Data: `^/usr/bin/(curl|wget)$`}, indexedCondition{OpProcessPath, prefixIndex}, "/USR/BIN", true},
+		{&Operator{Type: Regexp, Operand: OpProcessPath, Data: `/usr/bin/`}, indexedCondition{}, "", false},
+		{&Operator{Type: List, Operand: OpList, List: []Operator{
+			{Type: Simple, Operand: OpDstPort, Data: "443"},
+			{Type: Regexp, Operand: OpDstHost, Data: `(^|\.)example\.com$`},
+			{Type: Simple, Operand: OpProcessCmd, Data: "curl"},
+		}}, indexedCondition{OpDstHost, suffixIndex}, "EXAMPLE.COM", true},
+	}
+	for i, test := range tests {
+		cond, key, found := indexCondition(test.op)
+		if found != test.found || (found && (cond != test.cond || key != test.key)) {
+			t.Errorf("%d, %s: unexpected condition: %v '%s' %v", i, test.op.Data, cond, key, found)
+		}
+	}
+}
+
+func TestFoldKey(t *testing.T) {
+	equal := [][2]string{
+		{"GitHub.com", "github.COM"},
+		{"/usr/bin/sh", "/usr/bin/ſh"},
+		{"Kelvin", "kelvin"},
+		{"ÁRBOL", "árbol"},
+	}
+	for _, test := range equal {
+		if foldKey(test[0]) != foldKey(test[1]) {
+			t.Errorf("%s and %s should have the same key: %s, %s", test[0], test[1], foldKey(test[0]), foldKey(test[1]))
+		}
+	}
+	if foldKey("curl") == foldKey("wget") {
+		t.Error("different strings with the same key")
+	}
+}
+
+// newBenchLoader returns a loader with rules like the ones created from the
+// prompts: an app allowed to connect to a host and port, and a few regexps.
+func newBenchLoader(b *testing.B, numRules int) *Loader {
+	l := &Loader{rules: make(map[string]*Rule)}
+	for i := 0; i < numRules; i++ {
+		var op Operator
+		if i%20 == 0 {
+			op = Operator{Type: Regexp, Operand: OpDstHost, Data: fmt.Sprintf(`(^|\.)tracker%d\.example\.net$`, i)}
+		} else {
+			op = Operator{Type: List, Operand: OpList, List: []Operator{
+				{Type: Simple, Operand: OpProcessPath, Data: fmt.Sprintf("/usr/bin/app%d", i%100)},
+				{Type: Simple, Operand: OpDstHost, Data: fmt.Sprintf("host%d.example.com", i)},
+				{Type: Simple, Operand: OpDstPort, Data: "443"},
+			}}
+		}
+		r := Create(fmt.Sprintf("%04d-rule", i), "", true, false, false, Allow, Always, &op)
+		if err := compileRule(&r.Operator); err != nil {
+			b.Fatal("error compiling rule:", err)
+		}
+		l.rules[r.Name] = r
+	}
+	l.sortRules()
+	return l
+}
+
+func benchmarkFindFirstMatch(b *testing.B, numRules int, linear bool) {
+	l := newBenchLoader(b, numRules)
+	l.SetLinearMatch(linear)
+	// half of the connections match a rule.
+	cons := make([]*conman.Connection, 1024)
+	for i := range cons {
+		host := fmt.Sprintf("host%d.example.com", i%numRules)
+		if i%2 == 1 {
+			host = fmt.Sprintf("unknown%d.example.com", i)
+		}
+		cons[i] = conman.Deserialize(&protocol.Connection{
+			Protocol:    "tcp",
+			DstIp:       "1.1.1.1",
+			DstHost:     host,
+			DstPort:     443,
+			ProcessPath: fmt.Sprintf("/usr/bin/app%d", i%numRules%100),
+		})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.FindFirstMatch(cons[i%len(cons)])
+	}
+}
+
+func BenchmarkFindFirstMatch(b *testing.B) {
+	for _, numRules := range []int{50, 500, 2000} {
+		b.Run(fmt.Sprintf("linear-%d", numRules), func(b *testing.B) {
+			benchmarkFindFirstMatch(b, numRules, true)
+		})
+		b.Run(fmt.Sprintf("indexed-%d", numRules), func(b *testing.B) {
+			benchmarkFindFirstMatch(b, numRules, false)
+		})
+	}
+}
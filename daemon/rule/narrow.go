@@ -0,0 +1,214 @@
+package rule
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// maxNarrowDestinations is the max number of destinations recorded for a
+// temporary rule. Above this number the rule is not narrowed.
+const maxNarrowDestinations = 32
+
+// operands that restrict the destinations of a rule.
+var destinationOperands = map[Operand]bool{
+	OpDstIP:              true,
+	OpDstHost:            true,
+	OpDstNetwork:         true,
+	OpDstCountry:         true,
+	OpDstASN:             true,
+	OpDstASOrg:           true,
+	OpDomainsLists:       true,
+	OpDomainsRegexpLists: true,
+	OpIPLists:            true,
+	OpNetLists:           true,
+}
+
+type narrowDestination struct {
+	host string
+	ip   string
+	port uint
+}
+
+type narrowRecord struct {
+	rule         *Rule
+	destinations map[narrowDestination]bool
+	overflow     bool
+}
+
+// narrower records the destinations of the connections allowed by temporary
+// rules that don't restrict the destination (i.e.: allow firefox to connect to
+// any destination for 30s), in order to propose a narrowed permanent rule
+// once they expire.
+type narrower struct {
+	records      map[string]*narrowRecord
+	onSuggestion func(suggested *Rule)
+	active       atomic.Int32
+	sync.Mutex
+}
+
+// OnNarrowedRule registers the function to call with the rule proposed to
+// replace a temporary rule that has expired, restricted to the destinations
+// it has allowed.
+func (l *Loader) OnNarrowedRule(cb func(suggested *Rule)) {
+	l.narrower.Lock()
+	l.narrower.onSuggestion = cb
+	l.narrower.Unlock()
+}
+
+// isBroad returns true if the rule doesn't restrict the destinations.
+func isBroad(op *Operator) bool {
+	if destinationOperands[op.Operand] {
+		return false
+	}
+	for i := range op.List {
+		if !isBroad(&op.List[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// watch starts recording the destinations allowed by a temporary rule.
+func (n *narrower) watch(r *Rule) {
+	n.Lock()
+	defer n.Unlock()
+
+	if !r.Action.Allows() || !isBroad(&r.Operator) {
+		delete(n.records, r.Name)
+		n.active.Store(int32(len(n.records)))
+		return
+	}
+	if n.records == nil {
+		n.records = make(map[string]*narrowRecord)
+	}
+	n.records[r.Name] = &narrowRecord{
+		rule:         r,
+		destinations: make(map[narrowDestination]bool),
+	}
+	n.active.Store(int32(len(n.records)))
+}
+
+// record saves the destination of a connection matched by a rule.
+func (n *narrower) record(r *Rule, con *conman.Connection) {
+	if r == nil || n.active.Load() == 0 {
+		return
+	}
+	n.Lock()
+	defer n.Unlock()
+
+	rec, found := n.records[r.Name]
+	if !found || rec.rule != r || rec.overflow {
+		return
+	}
+	dst := narrowDestination{host: con.DstHost, ip: con.DstIP.String(), port: con.DstPort}
+	if rec.destinations[dst] {
+		return
+	}
+	if len(rec.destinations) >= maxNarrowDestinations {
+		log.Debug("[narrow] too many destinations allowed by %s, it won't be narrowed", r.Name)
+		rec.overflow = true
+		rec.destinations = nil
+		return
+	}
+	rec.destinations[dst] = true
+}
+
+// forget stops recording the destinations of a rule.
+func (n *narrower) forget(name string) {
+	n.Lock()
+	delete(n.records, name)
+	n.active.Store(int32(len(n.records)))
+	n.Unlock()
+}
+
+// expire stops recording the destinations of a rule, and proposes the
+// narrowed rule.
+func (n *narrower) expire(name string) {
+	n.Lock()
+	rec, found := n.records[name]
+	delete(n.records, name)
+	n.active.Store(int32(len(n.records)))
+	cb := n.onSuggestion
+	n.Unlock()
+
+	if !found || rec.overflow || len(rec.destinations) == 0 {
+		return
+	}
+	suggested := narrowRule(rec.rule, rec.destinations)
+	log.Info("[narrow] temporary rule %s expired, proposed rule: %s", name, suggested.Operator.String())
+	if cb != nil {
+		cb(suggested)
+	}
+}
+
+// narrowRule returns a permanent copy of the rule, restricted to the
+// destinations (and ports) the connections were allowed to.
+func narrowRule(r *Rule, destinations map[narrowDestination]bool) *Rule {
+	var ops []Operator
+	hasPort := false
+	// copy only the fields of the operators, not the compiled state.
+	addOp := func(o *Operator) {
+		hasPort = hasPort || o.Operand == OpDstPort
+		ops = append(ops, Operator{Type: o.Type, Sensitive: o.Sensitive, Operand: o.Operand, Data: o.Data})
+	}
+	if r.Operator.Type == List {
+		for i := range r.Operator.List {
+			addOp(&r.Operator.List[i])
+		}
+	} else if r.Operator.Operand != OpTrue {
+		addOp(&r.Operator)
+	}
+
+	hosts := make(map[string]bool)
+	ips := make(map[string]bool)
+	ports := make(map[string]bool)
+	useIPs := false
+	for dst := range destinations {
+		if dst.host == "" {
+			useIPs = true
+		}
+		hosts[dst.host] = true
+		ips[dst.ip] = true
+		ports[fmt.Sprint(dst.port)] = true
+	}
+	if useIPs {
+		ops = append(ops, matchAny(OpDstIP, ips))
+	} else {
+		ops = append(ops, matchAny(OpDstHost, hosts))
+	}
+	if !hasPort {
+		ops = append(ops, matchAny(OpDstPort, ports))
+	}
+
+	op, _ := NewOperator(List, false, OpList, "", ops)
+	narrowed := Create(
+		fmt.Sprintf("%s-narrowed", r.Name),
+		fmt.Sprintf("narrowed from the temporary rule %s (%s), %d destinations allowed", r.Name, r.Duration, len(destinations)),
+		true, r.Precedence, r.Nolog, r.Action, Always, op)
+	narrowed.Priority = r.Priority
+	narrowed.Tags = r.Tags
+	return narrowed
+}
+
+// matchAny returns an operator that matches any of the values.
+func matchAny(operand Operand, values map[string]bool) Operator {
+	list := make([]string, 0, len(values))
+	for v := range values {
+		list = append(list, v)
+	}
+	if len(list) == 1 {
+		return Operator{Type: Simple, Operand: operand, Data: list[0]}
+	}
+	sort.Strings(list)
+	for i := range list {
+		list[i] = regexp.QuoteMeta(list[i])
+	}
+	return Operator{Type: Regexp, Operand: operand, Data: fmt.Sprintf("^(%s)$", strings.Join(list, "|"))}
+}
@@ -0,0 +1,75 @@
+package rule
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNarrowTemporaryRule(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+	suggestions := make(chan *Rule, 1)
+	l.OnNarrowedRule(func(suggested *Rule) {
+		suggestions <- suggested
+	})
+
+	op, _ := NewOperator(Simple, false, OpProcessPath, defaultProcPath, make([]Operator, 0))
+	if err = l.Add(Create("000-allow-curl", "", true, false, false, Allow, Duration("200ms"), op), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+
+	for _, host := range []string{"opensnitch.io", "github.com", "opensnitch.io"} {
+		con := *conn
+		con.DstHost = host
+		con.DstIP = net.ParseIP("1.1.1.1")
+		con.DstPort = 443
+		if match := l.FindFirstMatch(&con); match == nil || match.Name != "000-allow-curl" {
+			t.Fatalf("unexpected rule matched: %v", match)
+		}
+	}
+
+	select {
+	case suggested := <-suggestions:
+		if suggested.Duration != Always || suggested.Action != Allow || suggested.Operator.Type != List {
+			t.Fatalf("unexpected rule proposed: %s", suggested)
+		}
+		list := suggested.Operator.List
+		if len(list) != 3 {
+			t.Fatalf("unexpected operators proposed: %s", suggested)
+		}
+		if list[0].Operand != OpProcessPath || list[0].Data != defaultProcPath {
+			t.Errorf("the operators of the temporary rule should be kept: %s", list[0].String())
+		}
+		if list[1].Operand != OpDstHost || list[1].Type != Regexp || list[1].Data != `^(github\.com|opensnitch\.io)$` {
+			t.Errorf("unexpected destinations proposed: %s", list[1].String())
+		}
+		if list[2].Operand != OpDstPort || list[2].Type != Simple || list[2].Data != "443" {
+			t.Errorf("unexpected ports proposed: %s", list[2].String())
+		}
+		if err := suggested.Operator.Compile(); err != nil {
+			t.Errorf("the rule proposed should compile: %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no rule proposed after the temporary rule expired")
+	}
+
+	// rules restricted to some destinations are not narrowed
+	hostOp, _ := NewOperator(Simple, false, OpDstHost, "opensnitch.io", make([]Operator, 0))
+	if err = l.Add(Create("001-allow-host", "", true, false, false, Allow, Duration("50ms"), hostOp), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+	con := *conn
+	con.DstHost = "opensnitch.io"
+	l.FindFirstMatch(&con)
+	select {
+	case suggested := <-suggestions:
+		t.Errorf("rules restricted to some destinations should not be narrowed: %s", suggested)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
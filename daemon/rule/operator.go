@@ -12,6 +12,7 @@ import (
 
 	"github.com/evilsocket/opensnitch/daemon/conman"
 	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/geoip"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/procmon"
 )
@@ -35,6 +36,12 @@ const (
 	Network = Type("network")
 	Lists   = Type("lists")
 	Range   = Type("range")
+	// domains of dest.host: example.com, *.example.com (subdomains),
+	// +.example.com (example.com and subdomains).
+	Domain = Type("domain")
+	// the GUI replies to a prompt with a rule of this type to pick a prompt
+	// template (Data), expanded by the daemon with the connection's metadata.
+	PromptTemplateType = Type("template")
 )
 
 // Available operands
@@ -43,11 +50,17 @@ const (
 	OpProcessID           = Operand("process.id")
 	OpProcessPath         = Operand("process.path")
 	OpProcessParentPath   = Operand("process.parent.path")
+	OpProcessTreePath     = Operand("process.tree.path")
 	OpProcessCmd          = Operand("process.command")
 	OpProcessEnvPrefix    = Operand("process.env.")
 	OpProcessEnvPrefixLen = 12
 	OpProcessHashMD5      = Operand("process.hash.md5")
 	OpProcessHashSHA1     = Operand("process.hash.sha1")
+	OpProcessPkgStatus    = Operand("process.package.status")
+	OpProcessHostNetNS    = Operand("process.namespace.net.host")
+	OpProcessSecLabel     = Operand("process.security.label")
+	OpProcessOriginPath   = Operand("process.origin.path")
+	OpProcessLibraryPath  = Operand("process.library.path")
 	OpUserID              = Operand("user.id")
 	OpUserName            = Operand("user.name")
 	OpSrcIP               = Operand("source.ip")
@@ -55,9 +68,17 @@ const (
 	OpDstIP               = Operand("dest.ip")
 	OpDstHost             = Operand("dest.host")
 	OpDstPort             = Operand("dest.port")
+	OpDstMAC              = Operand("dest.mac")
+	OpDstScope            = Operand("dest.scope")
 	OpDstNetwork          = Operand("dest.network")
+	OpDstCountry          = Operand("dest.country")
+	OpDstASN              = Operand("dest.asn")
+	OpDstASOrg            = Operand("dest.asorg")
 	OpSrcNetwork          = Operand("source.network")
 	OpProto               = Operand("protocol")
+	OpProtoMismatch       = Operand("protocol.mismatch")
+	OpListener            = Operand("listener")
+	OpSchedule            = Operand("time.schedule")
 	OpIfaceIn             = Operand("iface.in")
 	OpIfaceOut            = Operand("iface.out")
 	OpList                = Operand("list")
@@ -103,8 +124,12 @@ type Operator struct {
 	listNets        []*net.IPNet
 	listSnapshot    atomic.Pointer[listCacheSnapshot]
 	exitMonitorChan chan (struct{})
+	downloader      *Downloader
 	rangeMin        uint64
 	rangeMax        uint64
+	schedule        *schedule
+	ports           *portSet
+	domains         *domainTree
 
 	Operand             Operand    `json:"operand"`
 	Data                string     `json:"data"`
@@ -129,6 +154,38 @@ func NewOperator(t Type, s Sensitive, o Operand, data string, list []Operator) (
 	return &op, nil
 }
 
+// copyTo copies the operator to c, except its lock. The compiled state (lists,
+// regexps, callbacks) is shared.
+func (o *Operator) copyTo(c *Operator) {
+	o.RLock()
+	defer o.RUnlock()
+
+	c.cb = o.cb
+	c.cbGeneric = o.cbGeneric
+	c.re = o.re
+	c.netMask = o.netMask
+	c.lists = o.lists
+	c.domainWildcards = o.domainWildcards
+	c.domainGlobs = o.domainGlobs
+	c.listExact = o.listExact
+	c.listNets = o.listNets
+	c.listSnapshot.Store(o.listSnapshot.Load())
+	c.exitMonitorChan = o.exitMonitorChan
+	c.downloader = o.downloader
+	c.rangeMin = o.rangeMin
+	c.rangeMax = o.rangeMax
+	c.schedule = o.schedule
+	c.ports = o.ports
+	c.domains = o.domains
+	c.Operand = o.Operand
+	c.Data = o.Data
+	c.Type = o.Type
+	c.List = o.List
+	c.Sensitive = o.Sensitive
+	c.isCompiled = o.isCompiled
+	c.listsMonitorRunning = o.listsMonitorRunning
+}
+
 // Compile translates the operator type field to its callback counterpart
 func (o *Operator) Compile() error {
 	if o.isCompiled {
@@ -148,6 +205,10 @@ func (o *Operator) Compile() error {
 		return fmt.Errorf("Operand %s cannot be empty (%s)", o.Operand, o.Type)
 	}
 
+	if o.Operand == OpSchedule && o.Type != Simple {
+		return fmt.Errorf("operand %s is only allowed with type %s", OpSchedule, Simple)
+	}
+
 	if o.Type == Simple {
 		if o.Operand == OpUserName {
 			// TODO: allow regexps, take into account users from containers.
@@ -161,6 +222,21 @@ func (o *Operator) Compile() error {
 		} else if o.Operand == OpProcessHashMD5 || o.Operand == OpProcessHashSHA1 {
 			o.cb = o.hashCmp
 			return nil
+		} else if o.Operand == OpSchedule {
+			sched, err := parseSchedule(o.Data)
+			if err != nil {
+				return fmt.Errorf("time.schedule Operand error: %s", err)
+			}
+			o.schedule = sched
+		} else if (o.Operand == OpDstPort || o.Operand == OpSrcPort) && isPortsExpr(o.Data) {
+			// 80,443,8000-8100,imaps
+			ports, err := parsePorts(o.Data)
+			if err != nil {
+				return fmt.Errorf("%s Operand error: %s", o.Operand, err)
+			}
+			o.ports = ports
+			o.cb = o.portsCmp
+			return nil
 		}
 
 		o.cb = o.simpleCmp
@@ -189,6 +265,13 @@ func (o *Operator) Compile() error {
 		if err := o.compileNetwork(); err != nil {
 			return err
 		}
+	} else if o.Type == Domain {
+		if o.Operand != OpDstHost {
+			return fmt.Errorf("type %s is only allowed with operand %s", Domain, OpDstHost)
+		}
+		if err := o.compileDomains(); err != nil {
+			return err
+		}
 	} else if o.Type == Lists {
 		if o.Operand == OpDomainsLists {
 			o.loadLists()
@@ -220,33 +303,42 @@ func (o *Operator) Compile() error {
 }
 
 func (o *Operator) compileNetwork() error {
-	// Check if the operator's data is an alias present in the cache
-	if ipNets, found := AliasIPCache[o.Data]; found {
-		o.cbGeneric = func(value interface{}) bool {
-			ip := value.(net.IP)
-			matchFound := false
-
-			for _, ipNet := range ipNets {
-				if ipNet.Contains(ip) {
-					matchFound = true
-					break
-				}
+	ipNets, err := o.parseNetworks()
+	if err != nil {
+		return err
+	}
+	ipNets = expandDualStack(ipNets)
+	if len(ipNets) == 1 {
+		o.netMask = ipNets[0]
+		o.cbGeneric = o.cmpNetwork
+		return nil
+	}
+	o.cbGeneric = func(value interface{}) bool {
+		ip := value.(net.IP)
+		for _, ipNet := range ipNets {
+			if ipNet.Contains(ip) {
+				return true
 			}
-			return matchFound
 		}
-	} else {
-		// Parse the data as a CIDR if it's not an alias
-		_, netMask, err := net.ParseCIDR(o.Data)
-		if err != nil {
-			return fmt.Errorf("CIDR parsing error: %s", err)
-		}
-		o.netMask = netMask
-		o.cbGeneric = o.cmpNetwork
+		return false
 	}
 
 	return nil
 }
 
+// parseNetworks returns the networks of the operator: the ones of an alias
+// (LAN, MULTICAST, ...), or the CIDR of the operator.
+func (o *Operator) parseNetworks() ([]*net.IPNet, error) {
+	if ipNets, found := aliasNetworks(o.Data); found {
+		return ipNets, nil
+	}
+	_, netMask, err := net.ParseCIDR(o.Data)
+	if err != nil {
+		return nil, fmt.Errorf("CIDR parsing error: %s", err)
+	}
+	return []*net.IPNet{netMask}, nil
+}
+
 func (o *Operator) compileRange() error {
 	parts := strings.Split(strings.ReplaceAll(o.Data, " ", ""), "-")
 	if len(parts) != 2 {
@@ -302,6 +394,11 @@ func (o *Operator) rangeCmp(value string) bool {
 	return v >= o.rangeMin && v <= o.rangeMax
 }
 
+func (o *Operator) portsCmp(value string) bool {
+	port, err := strconv.ParseUint(value, 10, 16)
+	return err == nil && o.ports.contains(uint16(port))
+}
+
 func (o *Operator) cmpNetwork(destIP interface{}) bool {
 	// 192.0.2.1/24, 2001:db8:a0b:12f0::1/32
 	if o.netMask == nil {
@@ -479,6 +576,24 @@ func (o *Operator) Match(con *conman.Connection, hasChecksums bool) bool {
 		return o.cb(strconv.Itoa(con.Entry.UserId))
 	} else if o.Operand == OpDstNetwork {
 		return o.cbGeneric(con.DstIP)
+	} else if o.Operand == OpDstScope {
+		// local, lan, multicast, broadcast or wan
+		return o.cb(con.DstScope())
+	} else if o.Operand == OpDstMAC {
+		// only known for destinations on directly connected networks.
+		return o.cb(con.DstMAC())
+	} else if o.Operand == OpDstCountry {
+		// ISO code: ES, US, ... Empty if unknown or the geoip db is not configured.
+		return o.cb(geoip.Default.Country(con.DstIP))
+	} else if o.Operand == OpDstASN {
+		// number of the AS, without the AS prefix: 13335
+		if asn, found := geoip.Default.ASN(con.DstIP); found {
+			return o.cb(strconv.FormatUint(asn.Number, 10))
+		}
+		return o.cb("")
+	} else if o.Operand == OpDstASOrg {
+		asn, _ := geoip.Default.ASN(con.DstIP)
+		return o.cb(asn.Org)
 	} else if o.Operand == OpSrcNetwork {
 		return o.cbGeneric(con.SrcIP)
 	} else if o.Operand == OpNetLists {
@@ -507,8 +622,41 @@ func (o *Operator) Match(con *conman.Connection, hasChecksums bool) bool {
 		}
 		con.Process.RUnlock()
 		return ret
+	} else if o.Operand == OpProcessPkgStatus {
+		return o.cb(procmon.Packages.Verify(con.Process))
+	} else if o.Operand == OpProcessSecLabel {
+		// SELinux or AppArmor label: unconfined, /usr/bin/evince (enforce), ...
+		return o.cb(con.Process.SecurityLabel)
+	} else if o.Operand == OpProcessOriginPath {
+		// the app that sent the connection through a local proxy. Empty if
+		// it's not known.
+		if con.Origin == nil {
+			return o.cb("")
+		}
+		return o.cb(con.Origin.Path)
+	} else if o.Operand == OpProcessLibraryPath {
+		// any of the shared libraries mapped by the process when the
+		// connection is made. None if reading them is disabled
+		// (Rules.CaptureLibraries).
+		for _, lib := range con.Process.GetLibraries() {
+			if o.cb(lib) {
+				return true
+			}
+		}
+		return false
+	} else if o.Operand == OpProcessHostNetNS {
+		// true or false
+		return o.cb(strconv.FormatBool(con.Process.InHostNetNS()))
 	} else if o.Operand == OpProto {
 		return o.cb(con.Protocol)
+	} else if o.Operand == OpSchedule {
+		return o.schedule != nil && o.schedule.active(timeNow())
+	} else if o.Operand == OpProtoMismatch {
+		// true if the payload is not the protocol expected on the port.
+		return o.cb(strconv.FormatBool(con.ProtocolMismatch()))
+	} else if o.Operand == OpListener {
+		// true if it's a socket listening for connections.
+		return o.cb(strconv.FormatBool(con.Listener))
 	} else if o.Operand == OpSrcIP {
 		return o.cb(con.SrcIP.String())
 	} else if o.Operand == OpSrcPort {
@@ -523,6 +671,14 @@ func (o *Operator) Match(con *conman.Connection, hasChecksums bool) bool {
 			}
 		}
 		return false
+	} else if o.Operand == OpProcessTreePath {
+		// the process, or any of its ancestors.
+		for p := con.Process; p != nil; p = p.Parent {
+			if o.cb(p.Path) {
+				return true
+			}
+		}
+		return false
 	} else if strings.HasPrefix(string(o.Operand), string(OpProcessEnvPrefix)) {
 		envVarName := core.Trim(string(o.Operand[OpProcessEnvPrefixLen:]))
 		envVarValue, _ := con.Process.Env[envVarName]
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net"
 	"os"
+	"strings"
 )
 
 var NetworkAliases = make(map[string][]string)
@@ -25,8 +26,16 @@ func LoadAliases(filename string) error {
 		for _, network := range networks {
 			_, ipNet, err := net.ParseCIDR(network)
 			if err != nil {
-				// fmt.Printf("Error parsing CIDR for %s: %v\n", network, err)
-				continue
+				// a single address: ::1
+				ip := net.ParseIP(network)
+				if ip == nil {
+					continue
+				}
+				bits := 8 * net.IPv6len
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
 			}
 			ipNets = append(ipNets, ipNet)
 		}
@@ -38,6 +47,16 @@ func LoadAliases(filename string) error {
 	return nil
 }
 
+// aliasNetworks returns the networks of an alias. The names of the aliases
+// are case insensitive: LAN, lan.
+func aliasNetworks(alias string) ([]*net.IPNet, bool) {
+	if ipNets, found := AliasIPCache[alias]; found {
+		return ipNets, true
+	}
+	ipNets, found := AliasIPCache[strings.ToUpper(alias)]
+	return ipNets, found
+}
+
 func GetAliasByIP(ip string) string {
 	ipAddr := net.ParseIP(ip)
 	for alias, ipNets := range AliasIPCache {
@@ -1,19 +1,46 @@
 package rule
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
 )
 
+// Lists can be compressed with gzip (<list>.gz), and signed with a detached
+// ed25519 signature (<list>.sig, raw or base64 encoded).
+const (
+	listGzipExt      = ".gz"
+	listSignatureExt = ".sig"
+)
+
+// public keys allowed to sign lists. If there're keys configured, only signed
+// lists are loaded.
+var listsTrustedKeys atomic.Pointer[[]ed25519.PublicKey]
+
+// SetListsTrustedKeys sets the public keys (PEM files) allowed to sign the
+// lists. It applies to the lists loaded afterwards.
+func SetListsTrustedKeys(paths []string) error {
+	keys, err := ReadTrustedKeys(paths)
+	if err != nil {
+		return err
+	}
+	listsTrustedKeys.Store(&keys)
+	return nil
+}
+
 type domainWildcardTrieNode struct {
 	terminal bool
 	children map[string]*domainWildcardTrieNode
@@ -152,11 +179,16 @@ func (o *Operator) ClearLists() {
 	o.listExact = nil
 	o.listNets = nil
 	o.listSnapshot.Store(nil)
+	listsGeneration.Add(1)
 	debug.FreeOSMemory()
 }
 
 // StopMonitoringLists stops the monitoring lists goroutine.
 func (o *Operator) StopMonitoringLists() {
+	if o.downloader != nil {
+		o.downloader.Stop()
+		o.downloader = nil
+	}
 	if o.listsMonitorRunning == true {
 		o.exitMonitorChan <- struct{}{}
 		o.exitMonitorChan = nil
@@ -332,13 +364,13 @@ func (o *Operator) readLists() error {
 	for _, fileName := range fileList {
 		// ignore hidden files
 		name := filepath.Base(fileName)
-		if name[:1] == "." {
+		if name[:1] == "." || strings.HasSuffix(name, listSignatureExt) {
 			continue
 		}
 
-		raw, err := os.ReadFile(fileName)
+		raw, err := readListFile(fileName)
 		if err != nil {
-			log.Warning("Error reading list of IPs (%s): %s", fileName, err)
+			log.Warning("Error reading list (%s): %s", fileName, err)
 			continue
 		}
 
@@ -357,10 +389,72 @@ func (o *Operator) readLists() error {
 		}
 	}
 	o.listSnapshot.Store(o.buildListSnapshot())
+	listsGeneration.Add(1)
 	log.Info("%d lists loaded, %d domains, %d duplicated", len(fileList), len(o.lists), dups)
 	return nil
 }
 
+// readListFile reads a list from disk, verifying its signature, and
+// decompressing it if needed.
+func readListFile(fileName string) ([]byte, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyListSignature(fileName, raw); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(fileName, listGzipExt) {
+		return raw, nil
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip file: %s", err)
+	}
+	defer gz.Close()
+	raw, err = io.ReadAll(io.LimitReader(gz, maxListSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing list: %s", err)
+	}
+	if int64(len(raw)) > maxListSize {
+		return nil, fmt.Errorf("list too big once decompressed (> %d bytes)", maxListSize)
+	}
+	return raw, nil
+}
+
+// verifyListSignature verifies the detached signature of a list
+// (<list>.sig), if any. The signature is of the file as saved on disk
+// (i.e.: compressed).
+func verifyListSignature(fileName string, raw []byte) error {
+	var keys []ed25519.PublicKey
+	if k := listsTrustedKeys.Load(); k != nil {
+		keys = *k
+	}
+	sig, err := os.ReadFile(fileName + listSignatureExt)
+	if os.IsNotExist(err) {
+		if len(keys) > 0 {
+			return fmt.Errorf("the list is not signed")
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading signature: %s", err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("the list is signed, but there're no trusted keys configured")
+	}
+	if sig, err = decodeSignature(sig); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, raw, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the list is not signed by a trusted key")
+}
+
 func (o *Operator) buildListSnapshot() *listCacheSnapshot {
 	snapshot := &listCacheSnapshot{
 		lists:           o.lists,
@@ -448,4 +542,15 @@ func (o *Operator) loadLists() {
 		o.listsMonitorRunning = true
 		go o.monitorLists()
 	}
+
+	// lists downloaded from remote URLs, saved to the lists directory.
+	if o.downloader == nil {
+		downloader, err := NewDownloader(o.Data, o.Operand)
+		if err != nil {
+			log.Warning("[lists] %s", err)
+		} else if downloader != nil {
+			o.downloader = downloader
+			o.downloader.Start()
+		}
+	}
 }
@@ -1,9 +1,15 @@
 package rule
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sync"
 	"testing"
@@ -13,6 +19,7 @@ import (
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/netstat"
 	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 )
 
 var (
@@ -649,6 +656,28 @@ func TestNewOperatorSimple(t *testing.T) {
 		}
 	})
 
+	t.Run("Operator Simple proc.tree.path", func(t *testing.T) {
+		opSimple, err = NewOperator(Simple, false, OpProcessTreePath, "/usr/bin/bash", list)
+		if err != nil {
+			t.Error("NewOperator simple proc.tree.path err should be nil: ", err)
+		}
+		if err = opSimple.Compile(); err != nil {
+			t.Error("NewOperator simple proc.tree.path Compile() err: ", err)
+		}
+		if opSimple.Match(conn, false) {
+			t.Error("Test NewOperator() simple proc.tree.path matches a process not in the tree")
+		}
+		conn.Process.Parent = &procmon.Process{ID: 1, Path: "/usr/bin/bash"}
+		if !opSimple.Match(conn, false) {
+			t.Error("Test NewOperator() simple proc.tree.path doesn't match a child")
+		}
+		conn.Process.Parent = nil
+		conn.Process.Path = "/usr/bin/bash"
+		if !opSimple.Match(conn, false) {
+			t.Error("Test NewOperator() simple proc.tree.path doesn't match the process")
+		}
+	})
+
 	restoreConnection()
 }
 
@@ -685,6 +714,32 @@ func TestNewOperatorNetwork(t *testing.T) {
 	restoreConnection()
 }
 
+func TestNewOperatorNetworkAliases(t *testing.T) {
+	t.Log("Test NewOperator() network aliases")
+	aliasFile := filepath.Join(t.TempDir(), "aliases.json")
+	os.WriteFile(aliasFile, []byte(`{"TEST-HOSTS": ["185.53.178.14", "::1", "10.0.0.0/8"]}`), 0600)
+	if err := LoadAliases(aliasFile); err != nil {
+		t.Fatal("LoadAliases() error:", err)
+	}
+	defer delete(AliasIPCache, "TEST-HOSTS")
+
+	for _, alias := range []string{"TEST-HOSTS", "test-hosts"} {
+		op, err := NewOperator(Network, false, OpDstNetwork, alias, nil)
+		if err != nil {
+			t.Fatal("NewOperator network.err should be nil: ", err)
+		}
+		if err = op.Compile(); err != nil {
+			t.Fatal("Compile() error:", alias, err)
+		}
+		if !op.Match(conn, false) {
+			t.Error("single address of the alias not matched:", alias)
+		}
+	}
+	if nets := AliasIPCache["TEST-HOSTS"]; len(nets) != 3 || nets[1].String() != "::1/128" {
+		t.Error("invalid networks of the alias:", nets)
+	}
+}
+
 func TestNewOperatorRegexp(t *testing.T) {
 	t.Log("Test NewOperator() regexp")
 	var dummyList []Operator
@@ -705,6 +760,106 @@ func TestNewOperatorRegexp(t *testing.T) {
 	restoreConnection()
 }
 
+func TestNewOperatorSecurityLabel(t *testing.T) {
+	t.Log("Test NewOperator() process.security.label")
+
+	opRE, err := NewOperator(Regexp, false, OpProcessSecLabel, "^unconfined$|:unconfined_t:", nil)
+	if err != nil {
+		t.Error("NewOperator regexp.err should be nil: ", err)
+	}
+	if err = opRE.Compile(); err != nil {
+		t.Error("NewOperator process.security.label Compile() err: ", err)
+	}
+	conn.Process.SecurityLabel = "unconfined_u:unconfined_r:unconfined_t:s0"
+	if !opRE.Match(conn, false) {
+		t.Error("Test NewOperator() process.security.label doesn't match an unconfined process")
+	}
+	conn.Process.SecurityLabel = "/usr/bin/evince (enforce)"
+	if opRE.Match(conn, false) {
+		t.Error("Test NewOperator() process.security.label matches a confined process")
+	}
+	conn.Process.SecurityLabel = ""
+
+	restoreConnection()
+}
+
+func TestNewOperatorDstScope(t *testing.T) {
+	t.Log("Test NewOperator() dest.scope, dest.mac")
+
+	lanCon := conman.Deserialize(&protocol.Connection{
+		Protocol: "tcp", DstIp: "192.168.1.20", DstPort: 445,
+		ProcessPath: defaultProcPath, DstScope: "lan", DstMac: "aa:bb:cc:dd:ee:ff",
+	})
+	wanCon := conman.Deserialize(&protocol.Connection{
+		Protocol: "tcp", DstIp: "1.1.1.1", DstPort: 443,
+		ProcessPath: defaultProcPath, DstScope: "wan",
+	})
+
+	opScope, _ := NewOperator(Simple, false, OpDstScope, "lan", nil)
+	if err := opScope.Compile(); err != nil {
+		t.Fatal("NewOperator dest.scope Compile() err: ", err)
+	}
+	if !opScope.Match(lanCon, false) || opScope.Match(wanCon, false) {
+		t.Error("Test NewOperator() dest.scope lan doesn't match only the LAN destination")
+	}
+
+	opMAC, _ := NewOperator(Simple, false, OpDstMAC, "AA:BB:CC:DD:EE:FF", nil)
+	if err := opMAC.Compile(); err != nil {
+		t.Fatal("NewOperator dest.mac Compile() err: ", err)
+	}
+	if !opMAC.Match(lanCon, false) || opMAC.Match(wanCon, false) {
+		t.Error("Test NewOperator() dest.mac doesn't match only the destination with that MAC")
+	}
+}
+
+func TestNewOperatorOriginPath(t *testing.T) {
+	t.Log("Test NewOperator() process.origin.path")
+
+	torCon := conman.Deserialize(&protocol.Connection{
+		Protocol: "tcp", DstIp: "185.220.101.1", DstPort: 443,
+		ProcessPath: "/usr/bin/tor", OriginPath: "/usr/bin/curl", OriginPid: 1234,
+	})
+	unknownCon := conman.Deserialize(&protocol.Connection{
+		Protocol: "tcp", DstIp: "185.220.101.1", DstPort: 443,
+		ProcessPath: "/usr/bin/tor",
+	})
+
+	opOrigin, _ := NewOperator(Simple, false, OpProcessOriginPath, "/usr/bin/curl", nil)
+	if err := opOrigin.Compile(); err != nil {
+		t.Fatal("NewOperator process.origin.path Compile() err: ", err)
+	}
+	if !opOrigin.Match(torCon, false) || opOrigin.Match(unknownCon, false) {
+		t.Error("Test NewOperator() process.origin.path doesn't match only the connection of the origin")
+	}
+	if torCon.Serialize().OriginPath != "/usr/bin/curl" {
+		t.Error("origin not serialized")
+	}
+}
+
+func TestNewOperatorLibraryPath(t *testing.T) {
+	t.Log("Test NewOperator() process.library.path")
+
+	hookedCon := conman.Deserialize(&protocol.Connection{
+		Protocol: "tcp", DstIp: "185.220.101.1", DstPort: 443, ProcessPath: "/usr/bin/curl",
+		ProcessLibraries: []string{"/usr/lib/libc.so.6", "/usr/lib/x86_64-linux-gnu/libproxychains.so.4"},
+	})
+	cleanCon := conman.Deserialize(&protocol.Connection{
+		Protocol: "tcp", DstIp: "185.220.101.1", DstPort: 443, ProcessPath: "/usr/bin/curl",
+		ProcessLibraries: []string{"/usr/lib/libc.so.6"},
+	})
+
+	opLib, _ := NewOperator(Regexp, false, OpProcessLibraryPath, "/libproxychains[^/]*$", nil)
+	if err := opLib.Compile(); err != nil {
+		t.Fatal("NewOperator process.library.path Compile() err: ", err)
+	}
+	if !opLib.Match(hookedCon, false) || opLib.Match(cleanCon, false) {
+		t.Error("Test NewOperator() process.library.path doesn't match only the process with the library mapped")
+	}
+	if libs := hookedCon.Serialize().ProcessLibraries; len(libs) != 2 {
+		t.Error("libraries not serialized:", libs)
+	}
+}
+
 func TestNewOperatorInvalidRegexp(t *testing.T) {
 	t.Log("Test NewOperator() invalid regexp")
 	var dummyList []Operator
@@ -1362,3 +1517,51 @@ func TestNewOperatorRange(t *testing.T) {
 
 	restoreConnection()
 }
+
+func TestListsCompressedAndSigned(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("185.53.178.14\n1.1.1.1\n"))
+	w.Close()
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ips.txt.gz"), gz.Bytes(), 0644)
+	os.WriteFile(filepath.Join(dir, "ips.txt.gz.sig"), ed25519.Sign(priv, gz.Bytes()), 0644)
+	os.WriteFile(filepath.Join(dir, "unsigned.txt"), []byte("8.8.8.8\n"), 0644)
+
+	op := &Operator{Type: Lists, Operand: OpIPLists, Data: dir}
+	loaded := func() map[string]interface{} {
+		if err := op.readLists(); err != nil {
+			t.Fatal("readLists() error:", err)
+		}
+		return op.lists
+	}
+
+	t.Run("no trusted keys", func(t *testing.T) {
+		lists := loaded()
+		// signed lists are not loaded without trusted keys.
+		if len(lists) != 1 || lists["8.8.8.8"] == nil {
+			t.Error("invalid lists loaded:", lists)
+		}
+	})
+	t.Run("trusted keys", func(t *testing.T) {
+		listsTrustedKeys.Store(&[]ed25519.PublicKey{pub})
+		defer listsTrustedKeys.Store(nil)
+		lists := loaded()
+		if len(lists) != 2 || lists["185.53.178.14"] == nil || lists["1.1.1.1"] == nil {
+			t.Error("invalid lists loaded:", lists)
+		}
+	})
+	t.Run("untrusted key", func(t *testing.T) {
+		otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+		listsTrustedKeys.Store(&[]ed25519.PublicKey{otherPub})
+		defer listsTrustedKeys.Store(nil)
+		if lists := loaded(); len(lists) != 0 {
+			t.Error("lists not signed by a trusted key loaded:", lists)
+		}
+	})
+}
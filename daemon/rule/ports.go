@@ -0,0 +1,167 @@
+package rule
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// servicesFile is where the names of the services are resolved to ports.
+var servicesFile = "/etc/services"
+
+type portRange struct {
+	min, max uint16
+}
+
+// portSet matches the ports of a dest.port or source.port operator defined
+// as a list of ports, ranges and names of services:
+//
+//	80,443,8000-8100,imaps
+//	*
+//
+// The ranges are sorted and merged when compiled, and looked up with a
+// binary search.
+type portSet struct {
+	ranges []portRange
+}
+
+// isPortsExpr returns true if the data of an operator is not a single port.
+func isPortsExpr(data string) bool {
+	_, err := strconv.ParseUint(data, 10, 16)
+	return err != nil && data != ""
+}
+
+// parsePorts parses a list of ports separated by commas.
+func parsePorts(expr string) (*portSet, error) {
+	var services map[string][]uint16
+	set := &portSet{}
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			return nil, fmt.Errorf("invalid ports '%s', empty item", expr)
+		case item == "*":
+			set.ranges = append(set.ranges, portRange{0, 65535})
+		case strings.Contains(item, "-"):
+			min, max, err := parsePortRange(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ports '%s': %s", expr, err)
+			}
+			set.ranges = append(set.ranges, portRange{min, max})
+		default:
+			if port, err := strconv.ParseUint(item, 10, 16); err == nil {
+				set.ranges = append(set.ranges, portRange{uint16(port), uint16(port)})
+				break
+			}
+			if services == nil {
+				var err error
+				if services, err = loadServices(servicesFile); err != nil {
+					return nil, fmt.Errorf("invalid ports '%s', unable to resolve %s: %s", expr, item, err)
+				}
+			}
+			ports, found := services[strings.ToLower(item)]
+			if !found {
+				return nil, fmt.Errorf("invalid ports '%s', unknown service: %s", expr, item)
+			}
+			for _, port := range ports {
+				set.ranges = append(set.ranges, portRange{port, port})
+			}
+		}
+	}
+	set.merge()
+	return set, nil
+}
+
+func parsePortRange(item string) (uint16, uint16, error) {
+	parts := strings.Split(item, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected 'min-max', got '%s'", item)
+	}
+	min, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range min: %s", parts[0])
+	}
+	max, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range max: %s", parts[1])
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("range min (%d) cannot be greater than max (%d)", min, max)
+	}
+	return uint16(min), uint16(max), nil
+}
+
+// merge sorts the ranges, and joins the ones that overlap or are contiguous.
+func (s *portSet) merge() {
+	sort.Slice(s.ranges, func(i, j int) bool {
+		return s.ranges[i].min < s.ranges[j].min
+	})
+	merged := s.ranges[:0]
+	for _, r := range s.ranges {
+		last := len(merged) - 1
+		if last >= 0 && uint32(r.min) <= uint32(merged[last].max)+1 {
+			if r.max > merged[last].max {
+				merged[last].max = r.max
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	s.ranges = merged
+}
+
+func (s *portSet) contains(port uint16) bool {
+	i := sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].max >= port
+	})
+	return i < len(s.ranges) && s.ranges[i].min <= port
+}
+
+// loadServices reads the names and aliases of the services, and their ports,
+// from a file with the format of /etc/services:
+//
+//	http		80/tcp		www		# WorldWideWeb HTTP
+func loadServices(path string) (map[string][]uint16, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	services := make(map[string][]uint16)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		portProto := strings.SplitN(fields[1], "/", 2)
+		port, err := strconv.ParseUint(portProto[0], 10, 16)
+		if err != nil {
+			continue
+		}
+		for _, name := range append([]string{fields[0]}, fields[2:]...) {
+			name = strings.ToLower(name)
+			if !hasPort(services[name], uint16(port)) {
+				services[name] = append(services[name], uint16(port))
+			}
+		}
+	}
+	return services, scanner.Err()
+}
+
+func hasPort(ports []uint16, port uint16) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	servicesFile = "testdata/services"
+	defer func() { servicesFile = "/etc/services" }()
+
+	tests := []struct {
+		expr    string
+		match   []uint16
+		noMatch []uint16
+	}{
+		{"80,443", []uint16{80, 443}, []uint16{0, 81, 442, 444}},
+		{"1024-65535", []uint16{1024, 8080, 65535}, []uint16{0, 80, 1023}},
+		{"8000-8100, 22 ,443", []uint16{22, 443, 8000, 8050, 8100}, []uint16{23, 7999, 8101}},
+		{"www,https,imaps", []uint16{80, 443, 993}, []uint16{22, 53}},
+		{"DOMAIN", []uint16{53}, []uint16{80}},
+		{"*", []uint16{0, 1, 443, 65535}, nil},
+		// overlapping and contiguous ranges are merged.
+		{"10-20,15-30,31,40-50", []uint16{10, 25, 30, 31, 40, 50}, []uint16{9, 32, 39, 51}},
+	}
+	for _, tt := range tests {
+		set, err := parsePorts(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %s", tt.expr, err)
+			continue
+		}
+		for _, p := range tt.match {
+			if !set.contains(p) {
+				t.Errorf("%s should match %d", tt.expr, p)
+			}
+		}
+		for _, p := range tt.noMatch {
+			if set.contains(p) {
+				t.Errorf("%s should not match %d", tt.expr, p)
+			}
+		}
+	}
+	if set, _ := parsePorts("10-20,15-30,31,40-50"); len(set.ranges) != 2 {
+		t.Errorf("ranges not merged: %v", set.ranges)
+	}
+
+	for _, expr := range []string{"80,", "100-10", "1-", "65536", "80-90-100", "gopher"} {
+		if _, err := parsePorts(expr); err == nil {
+			t.Errorf("%s should be invalid", expr)
+		}
+	}
+}
+
+func TestNewOperatorPorts(t *testing.T) {
+	servicesFile = "testdata/services"
+	defer func() { servicesFile = "/etc/services" }()
+
+	tests := map[string]bool{
+		"443":          true,
+		"80,443":       true,
+		"80,8080":      false,
+		"400-500":      true,
+		"1024-65535":   false,
+		"http,https":   true,
+		"ssh,domain":   false,
+		"*":            true,
+		"22,440-450,*": true,
+	}
+	for data, expected := range tests {
+		op, _ := NewOperator(Simple, false, OpDstPort, data, nil)
+		if err := op.Compile(); err != nil {
+			t.Errorf("%s doesn't compile: %s", data, err)
+			continue
+		}
+		if op.Match(conn, false) != expected {
+			t.Errorf("%s match should be %v", data, expected)
+		}
+	}
+
+	op, _ := NewOperator(Simple, false, OpDstPort, "80,nonexistent", nil)
+	if err := op.Compile(); err == nil {
+		t.Error("unknown services should not compile")
+	}
+}
@@ -0,0 +1,241 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Prompt templates.
+//
+// The prompt templates are rules saved to <rules path>/prompts/*.json, offered
+// as options when asking the user about a connection. Their strings may
+// reference the metadata of the connection, expanded into a concrete rule
+// when the user picks the template:
+//
+//	{
+//	    "name": "allow-$PROCESS_NAME-vendor-domains",
+//	    "description": "allow the application to its own vendor domains only",
+//	    "enabled": true,
+//	    "action": "allow",
+//	    "duration": "always",
+//	    "operator": {
+//	        "type": "list",
+//	        "operand": "list",
+//	        "list": [
+//	            {"type": "simple", "operand": "process.path", "data": "$PROCESS_PATH"},
+//	            {"type": "regexp", "operand": "dest.host", "data": "^(.*\\.)?$DST_DOMAIN_REGEXP$$"}
+//	        ]
+//	    }
+//	}
+//
+// The action and the duration selected by the user replace the ones of the
+// template. A template is not offered if it references metadata that the
+// connection doesn't have (i.e.: $DST_HOST of a connection to an IP).
+const PromptsDir = "prompts"
+
+// Variables of the metadata of the connections.
+const (
+	VarProcessPath     = "PROCESS_PATH"
+	VarProcessName     = "PROCESS_NAME"
+	VarUserID          = "USER_ID"
+	VarProtocol        = "PROTOCOL"
+	VarDstIP           = "DST_IP"
+	VarDstPort         = "DST_PORT"
+	VarDstHost         = "DST_HOST"
+	VarDstDomain       = "DST_DOMAIN"
+	VarDstDomainRegexp = "DST_DOMAIN_REGEXP"
+)
+
+// PromptTemplate is a rule template offered when asking about a connection.
+type PromptTemplate struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+
+	raw []byte
+}
+
+// NewPromptTemplate parses a prompt template.
+func NewPromptTemplate(raw []byte) (*PromptTemplate, error) {
+	t := &PromptTemplate{}
+	if err := json.Unmarshal(raw, t); err != nil {
+		return nil, err
+	}
+	if t.Name == "" {
+		return nil, fmt.Errorf("the name of the template is empty")
+	}
+	t.raw = raw
+	return t, nil
+}
+
+// ConnectionVariables returns the values of the variables of a connection.
+// The metadata not available is not defined.
+func ConnectionVariables(con *conman.Connection) map[string]string {
+	vars := map[string]string{
+		VarProtocol: con.Protocol,
+		VarDstPort:  strconv.FormatUint(uint64(con.DstPort), 10),
+	}
+	if con.DstIP != nil {
+		vars[VarDstIP] = con.DstIP.String()
+	}
+	if con.Process != nil && con.Process.Path != "" {
+		vars[VarProcessPath] = con.Process.Path
+		vars[VarProcessName] = filepath.Base(con.Process.Path)
+	}
+	if con.Entry != nil {
+		vars[VarUserID] = strconv.Itoa(con.Entry.UserId)
+	}
+	if host := strings.TrimSuffix(con.DstHost, "."); host != "" {
+		vars[VarDstHost] = host
+		domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+		if err != nil {
+			domain = host
+		}
+		vars[VarDstDomain] = domain
+		vars[VarDstDomainRegexp] = regexp.QuoteMeta(domain)
+	}
+	return vars
+}
+
+// Expand expands the template with the metadata of a connection into a
+// rule, with the given action and duration.
+func (t *PromptTemplate) Expand(con *conman.Connection, action Action, duration Duration) (*Rule, error) {
+	vars := ConnectionVariables(con)
+
+	var tmpl map[string]interface{}
+	if err := json.Unmarshal(t.raw, &tmpl); err != nil {
+		return nil, err
+	}
+	var undefined []string
+	walkStrings(tmpl, func(s string) string {
+		for _, ref := range varRefs(s) {
+			if _, found := vars[ref]; !found {
+				undefined = append(undefined, ref)
+			}
+		}
+		return expandVars(s, vars)
+	})
+	if len(undefined) > 0 {
+		return nil, fmt.Errorf("template %s: the connection has no %s", t.Name, strings.Join(undefined, ", "))
+	}
+	buf, err := json.Marshal(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	r := &Rule{}
+	if err := json.Unmarshal(buf, r); err != nil {
+		return nil, err
+	}
+
+	if action != "" {
+		r.Action = action
+	}
+	if duration != "" {
+		r.Duration = duration
+	}
+	// the rules expanded from the same template for different applications
+	// must not replace each other.
+	if r.Name == t.Name {
+		r.Name = core.ConcatStrings(t.Name, "-", ruleNameSuffix(vars[VarProcessPath]))
+	}
+	r.Name = strings.ToLower(invalidNameRune.ReplaceAllString(r.Name, "-"))
+	r.Created = time.Now().Format(time.RFC3339)
+	// unlike the imported rules, the rules of the prompts may apply to this
+	// connection only.
+	duration = r.Duration
+	if duration == Once {
+		r.Duration = Always
+	}
+	err = Validate(r)
+	r.Duration = duration
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %s", t.Name, err)
+	}
+	return r, nil
+}
+
+// LoadPromptTemplates reads the prompt templates of a directory, sorted by
+// name. The templates with errors are skipped.
+func LoadPromptTemplates(dir string) ([]*PromptTemplate, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]*PromptTemplate, 0, len(matches))
+	names := make(map[string]bool, len(matches))
+	for _, path := range matches {
+		raw, err := core.ReadSealedFile(path)
+		if err != nil {
+			log.Warning("[prompts] error reading %s: %s", path, err)
+			continue
+		}
+		t, err := NewPromptTemplate(raw)
+		if err != nil {
+			log.Warning("[prompts] error parsing %s: %s", path, err)
+			continue
+		}
+		if names[t.Name] {
+			log.Warning("[prompts] template %s of %s already defined", t.Name, path)
+			continue
+		}
+		names[t.Name] = true
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// loadPromptTemplates reads the prompt templates of the rules path.
+func (l *Loader) loadPromptTemplates() error {
+	var templates []*PromptTemplate
+	dir := filepath.Join(l.Path, PromptsDir)
+	if core.Exists(dir) {
+		var err error
+		if templates, err = LoadPromptTemplates(dir); err != nil {
+			return err
+		}
+	}
+	l.Lock()
+	l.promptTemplates = templates
+	l.Unlock()
+	if len(templates) > 0 {
+		log.Info("[prompts] %d templates loaded from %s", len(templates), dir)
+	}
+	return nil
+}
+
+// PromptTemplates returns the templates to offer when asking about a
+// connection.
+func (l *Loader) PromptTemplates() []*PromptTemplate {
+	l.RLock()
+	defer l.RUnlock()
+	return l.promptTemplates
+}
+
+// ExpandPromptTemplate expands the prompt template with the given name with
+// the metadata of a connection.
+func (l *Loader) ExpandPromptTemplate(name string, con *conman.Connection, action Action, duration Duration) (*Rule, error) {
+	for _, t := range l.PromptTemplates() {
+		if t.Name == name {
+			return t.Expand(con, action, duration)
+		}
+	}
+	return nil, fmt.Errorf("prompt template %s not found", name)
+}
+
+// isPromptFile returns true if a file belongs to the prompt templates.
+func (l *Loader) isPromptFile(path string) bool {
+	return filepath.Dir(path) == filepath.Join(l.Path, PromptsDir) && strings.HasSuffix(path, ".json")
+}
@@ -0,0 +1,134 @@
+package rule
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+)
+
+const vendorPrompt = `{
+    "name": "allow-$PROCESS_NAME-vendor-domains",
+    "description": "own vendor domains only",
+    "enabled": true,
+    "action": "allow",
+    "duration": "always",
+    "operator": {
+        "type": "list",
+        "operand": "list",
+        "list": [
+            {"type": "simple", "operand": "process.path", "data": "$PROCESS_PATH"},
+            {"type": "regexp", "operand": "dest.host", "data": "^(.*\\.)?${DST_DOMAIN_REGEXP}$$"}
+        ]
+    }
+}`
+
+const portPrompt = `{
+    "name": "app-port",
+    "enabled": true,
+    "action": "deny",
+    "duration": "once",
+    "operator": {"type": "simple", "operand": "dest.port", "data": "$DST_PORT"}
+}`
+
+func newPromptConn(host string) *conman.Connection {
+	proc := procmon.NewProcessEmpty(1234, "firefox")
+	proc.Path = "/usr/lib/firefox/firefox"
+	return &conman.Connection{
+		Protocol: "tcp",
+		DstHost:  host,
+		DstIP:    net.ParseIP("34.117.65.55"),
+		DstPort:  443,
+		Process:  proc,
+		Entry:    &netstat.Entry{UserId: 1000},
+	}
+}
+
+func TestPromptTemplates(t *testing.T) {
+	rulesDir := t.TempDir()
+	dir := filepath.Join(rulesDir, PromptsDir)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "vendor.json"), vendorPrompt)
+	writeFile(t, filepath.Join(dir, "port.json"), portPrompt)
+	writeFile(t, filepath.Join(dir, "invalid.json"), `{"action": "allow"}`)
+
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(rulesDir); err != nil {
+		t.Fatal(err)
+	}
+	templates := l.PromptTemplates()
+	if len(templates) != 2 || templates[0].Name != "allow-$PROCESS_NAME-vendor-domains" || templates[1].Name != "app-port" {
+		t.Fatalf("unexpected templates: %v", templates)
+	}
+	if l.NumRules() != 0 {
+		t.Error("the prompt templates should not be loaded as rules")
+	}
+
+	r, err := l.ExpandPromptTemplate(templates[0].Name, newPromptConn("incoming.telemetry.mozilla.org"), Deny, Restart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "allow-firefox-vendor-domains" || r.Action != Deny || r.Duration != Restart {
+		t.Errorf("unexpected rule: %s, %s, %s", r.Name, r.Action, r.Duration)
+	}
+	if err := l.unmarshalOperatorList(&r.Operator); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Operator.Compile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := compileList(&r.Operator); err != nil {
+		t.Fatal(err)
+	}
+	if data := r.Operator.List[1].Data; data != `^(.*\.)?mozilla\.org$` {
+		t.Errorf("unexpected domain regexp: %s", data)
+	}
+	for host, match := range map[string]bool{
+		"mozilla.org":             true,
+		"addons.mozilla.org":      true,
+		"mozilla.org.example.com": false,
+		"mozillaxorg":             false,
+	} {
+		if r.Match(newPromptConn(host), false) != match {
+			t.Errorf("%s: the rule should match: %v", host, match)
+		}
+	}
+
+	// the connection has no host
+	if _, err := templates[0].Expand(newPromptConn(""), Allow, Once); err == nil {
+		t.Error("the template should not apply to connections without host")
+	}
+
+	// the name of the template doesn't reference the connection
+	r, err = templates[1].Expand(newPromptConn(""), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "app-port-firefox" || r.Operator.Data != "443" || r.Action != Deny || r.Duration != Once {
+		t.Errorf("unexpected rule: %s, %s, %s, %s", r.Name, r.Operator.Data, r.Action, r.Duration)
+	}
+
+	if _, err := l.ExpandPromptTemplate("not-found", newPromptConn(""), Allow, Once); err == nil {
+		t.Error("expanding a template not loaded should fail")
+	}
+
+	// the templates shipped with the daemon
+	shipped, err := LoadPromptTemplates("../data/prompts")
+	if err != nil || len(shipped) == 0 {
+		t.Fatal("error loading the prompt templates of the daemon:", err)
+	}
+	for _, tmpl := range shipped {
+		if _, err := tmpl.Expand(newPromptConn("incoming.telemetry.mozilla.org"), "", ""); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// QuarantineRulePrefix is the prefix of the names of the rules that deny the
+// connections of the binaries whose checksum doesn't match their rules.
+const QuarantineRulePrefix = "quarantine-"
+
+// quarantine denies the connections of a binary for some time, when the only
+// reason why a rule doesn't match its connections is the checksum of the
+// binary (the binary has been modified, or just updated), instead of applying
+// the default action to them.
+type quarantine struct {
+	duration time.Duration
+	onAdd    func(quarantined, failed *Rule, con *conman.Connection)
+	sync.RWMutex
+}
+
+// SetChecksumQuarantine configures for how long the connections of a binary
+// are denied when its checksum doesn't match the one of its rules.
+// 0 disables it.
+func (l *Loader) SetChecksumQuarantine(duration time.Duration) {
+	l.quarantine.Lock()
+	l.quarantine.duration = duration
+	l.quarantine.Unlock()
+}
+
+// OnChecksumQuarantine registers the function to call with the rule added to
+// deny the connections of a binary, and the rule that failed to match them.
+func (l *Loader) OnChecksumQuarantine(cb func(quarantined, failed *Rule, con *conman.Connection)) {
+	l.quarantine.Lock()
+	l.quarantine.onAdd = cb
+	l.quarantine.Unlock()
+}
+
+// quarantineMismatch looks for a rule that only fails to match a connection
+// because of the checksum of the binary, and if it's found, adds a temporary
+// rule to deny the connections of the binary, and returns it.
+func (l *Loader) quarantineMismatch(rules []*Rule, con *conman.Connection) *Rule {
+	l.quarantine.RLock()
+	duration, onAdd := l.quarantine.duration, l.quarantine.onAdd
+	l.quarantine.RUnlock()
+	if duration <= 0 || con.Process == nil || con.Process.Path == "" {
+		return nil
+	}
+
+	for _, failed := range rules {
+		if !checksumMismatch(&failed.Operator, con) {
+			continue
+		}
+		path := con.Process.Path
+		op, err := NewOperator(Simple, false, OpProcessPath, path, nil)
+		if err != nil {
+			log.Warning("[quarantine] %s: %s", path, err)
+			return nil
+		}
+		r := Create(
+			QuarantineRulePrefix+filepath.Base(path),
+			fmt.Sprintf("the checksum of %s doesn't match the rule %s", path, failed.Name),
+			true, true, false, Deny, Duration(duration.String()), op)
+		r.Priority = math.MinInt32
+		if err := l.replaceUserRule(r); err != nil {
+			log.Warning("[quarantine] error adding the rule of %s: %s", path, err)
+			return nil
+		}
+		log.Important("[quarantine] checksum of %s mismatch, rule %s, connections denied for %s", path, failed.Name, duration)
+		if onAdd != nil {
+			onAdd(r, failed, con)
+		}
+		return r
+	}
+	return nil
+}
+
+// checksumMismatch returns true if the process path of a list operator
+// matches the connection, and the only operators that don't match are the
+// checksums of the binary.
+func checksumMismatch(op *Operator, con *conman.Connection) bool {
+	if op.Operand != OpList || !hasOperand(op, OpProcessPath) {
+		return false
+	}
+	mismatch := false
+	for i := range op.List {
+		o := &op.List[i]
+		if o.Operand == OpProcessHashMD5 || o.Operand == OpProcessHashSHA1 {
+			mismatch = mismatch || !o.Match(con, true)
+			continue
+		}
+		if !o.Match(con, true) {
+			return false
+		}
+	}
+	return mismatch
+}
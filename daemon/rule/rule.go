@@ -2,10 +2,13 @@ package rule
 
 import (
 	"fmt"
+	"syscall"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/hooks"
 	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/log/loggers"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 )
 
@@ -22,8 +25,23 @@ const (
 	Allow  = Action("allow")
 	Deny   = Action("deny")
 	Reject = Action("reject")
+	// Audit allows the connection, and writes an audit record of it.
+	Audit = Action("audit")
+	// Proxy allows the connection through the upstream proxy of the rule.
+	Proxy = Action("proxy")
 )
 
+// Allows returns true if the action lets the connection through.
+func (a Action) Allows() bool {
+	return a == Allow || a == Audit || a == Proxy
+}
+
+// Signals that can be sent to the process of the connections denied by a rule.
+var killSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+}
+
 // Duration of a rule
 type Duration string
 
@@ -50,6 +68,47 @@ type Rule struct {
 	Enabled     bool     `json:"enabled"`
 	Precedence  bool     `json:"precedence"`
 	Nolog       bool     `json:"nolog"`
+
+	// Priority defines the order of evaluation of the rules: lower values are
+	// evaluated first. Rules with the same priority are ordered by name.
+	Priority int32 `json:"priority"`
+
+	// Tags are labels attached to the connections matched by the rule
+	// (telemetry, updates, ...), to aggregate and filter them.
+	Tags []string `json:"tags,omitempty"`
+
+	// Kill is the signal to send to the process of the connections denied by
+	// the rule (SIGTERM or SIGKILL), to terminate it. Empty to not kill it.
+	Kill string `json:"kill,omitempty"`
+
+	// Jail restricts in kernel the connections of the binary of the rule
+	// (operand process.path): its processes are moved to a cgroup whose new
+	// connections only can go to the loopback interface and to the
+	// destinations of the rule (operands dest.ip and dest.network).
+	Jail bool `json:"jail,omitempty"`
+
+	// Score is added to the score of the connections matched by the rule,
+	// when the rules are evaluated by score (positive values towards denying
+	// them, negative towards allowing them). These rules don't allow or deny
+	// connections by themselves, and are ignored when scoring is disabled.
+	Score int32 `json:"score,omitempty"`
+
+	// Proxy is the name of the upstream proxy of the forwarder the TCP
+	// connections of the rules with action proxy are sent through.
+	Proxy string `json:"proxy,omitempty"`
+
+	// Log is the log directive of the rule: how the connections it matches
+	// are logged (none, summary or full), and where (main, file or syslog).
+	// Empty to log them only to the configured loggers.
+	Log *loggers.RuleLogConfig `json:"log,omitempty"`
+
+	// Hook is the executable run when the rule matches a connection, with
+	// the connection on stdin, to automate actions outside of the daemon.
+	Hook *hooks.Hook `json:"hook,omitempty"`
+
+	// Template is the name of the template the rule has been expanded from.
+	// These rules are not saved to disk.
+	Template string `json:"template,omitempty"`
 }
 
 // Create creates a new rule object with the specified parameters.
@@ -67,6 +126,75 @@ func Create(name, description string, enabled, precedence, nolog bool, action Ac
 	}
 }
 
+// clone returns a copy of the rule, to be modified and replace it.
+// The compiled state of the operator is shared with the rule copied.
+func (r *Rule) clone() *Rule {
+	c := &Rule{
+		Created:     r.Created,
+		Updated:     r.Updated,
+		Name:        r.Name,
+		Description: r.Description,
+		Action:      r.Action,
+		Duration:    r.Duration,
+		Enabled:     r.Enabled,
+		Precedence:  r.Precedence,
+		Nolog:       r.Nolog,
+		Priority:    r.Priority,
+		Tags:        r.Tags,
+		Kill:        r.Kill,
+		Jail:        r.Jail,
+		Score:       r.Score,
+		Proxy:       r.Proxy,
+		Log:         r.Log,
+		Hook:        r.Hook,
+		Template:    r.Template,
+	}
+	r.Operator.copyTo(&c.Operator)
+	return c
+}
+
+// KillSignal returns the signal to send to the process of a connection denied
+// by the rule, if the rule kills it.
+func (r *Rule) KillSignal() (syscall.Signal, bool) {
+	if r.Kill == "" || r.Action.Allows() {
+		return 0, false
+	}
+	sig, found := killSignals[r.Kill]
+	return sig, found
+}
+
+// JailDestinations returns the binary jailed by the rule, and the
+// destinations it's allowed to connect to. The rules that don't allow
+// connections jail the binary without destinations.
+func (r *Rule) JailDestinations() (path string, dsts []string) {
+	ops := []*Operator{&r.Operator}
+	if r.Operator.Type == List {
+		ops = make([]*Operator, len(r.Operator.List))
+		for i := range r.Operator.List {
+			ops[i] = &r.Operator.List[i]
+		}
+	}
+	dsts = make([]string, 0)
+	for _, op := range ops {
+		switch {
+		case op.Operand == OpProcessPath && op.Type == Simple:
+			path = op.Data
+		case !r.Action.Allows():
+		case op.Operand == OpDstIP && op.Type == Simple:
+			dsts = append(dsts, op.Data)
+		case op.Operand == OpDstNetwork && op.Type == Network:
+			if ipNets, found := AliasIPCache[op.Data]; found {
+				for _, ipNet := range ipNets {
+					dsts = append(dsts, ipNet.String())
+				}
+				continue
+			}
+			dsts = append(dsts, op.Data)
+		}
+	}
+	return path, dsts
+}
+
 func (r *Rule) String() string {
 	enabled := "Disabled"
 	if r.Enabled {
@@ -109,6 +237,26 @@ func Deserialize(reply *protocol.Rule) (*Rule, error) {
 		Duration(reply.Duration),
 		operator,
 	)
+	newRule.Priority = reply.Priority
+	newRule.Tags = reply.Tags
+	newRule.Kill = reply.Kill
+	newRule.Jail = reply.Jail
+	newRule.Score = reply.Score
+	newRule.Proxy = reply.Proxy
+	if reply.LogLevel != "" || reply.LogTarget != "" {
+		newRule.Log = &loggers.RuleLogConfig{
+			Level:  reply.LogLevel,
+			Target: reply.LogTarget,
+			File:   reply.LogFile,
+		}
+	}
+	if reply.HookPath != "" {
+		newRule.Hook = &hooks.Hook{
+			Path:    reply.HookPath,
+			On:      reply.HookOn,
+			Timeout: reply.HookTimeout,
+		}
+	}
 
 	if Type(reply.Operator.Type) == List {
 		newRule.Operator.Data = ""
@@ -151,6 +299,12 @@ func (r *Rule) Serialize() *protocol.Rule {
 		Enabled:     bool(r.Enabled),
 		Precedence:  bool(r.Precedence),
 		Nolog:       bool(r.Nolog),
+		Priority:    r.Priority,
+		Tags:        r.Tags,
+		Kill:        r.Kill,
+		Jail:        r.Jail,
+		Score:       r.Score,
+		Proxy:       r.Proxy,
 		Action:      string(r.Action),
 		Duration:    string(r.Duration),
 		Operator: &protocol.Operator{
@@ -160,6 +314,16 @@ func (r *Rule) Serialize() *protocol.Rule {
 			Data:      string(r.Operator.Data),
 		},
 	}
+	if r.Log != nil {
+		protoRule.LogLevel = r.Log.Level
+		protoRule.LogTarget = r.Log.Target
+		protoRule.LogFile = r.Log.File
+	}
+	if r.Hook != nil {
+		protoRule.HookPath = r.Hook.Path
+		protoRule.HookOn = r.Hook.On
+		protoRule.HookTimeout = r.Hook.Timeout
+	}
 	if r.Operator.Type == List {
 		r.Operator.Data = ""
 		for i := 0; i < len(r.Operator.List); i++ {
@@ -0,0 +1,282 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeNow returns the time the schedules are evaluated with.
+var timeNow = time.Now
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// timeWindow is a range of minutes of the day, [start, end). If end is lower
+// than start, the window ends the next day.
+type timeWindow struct {
+	start int
+	end   int
+}
+
+// cronField is a bitmask of the values allowed for a field of a cron expression.
+type cronField uint64
+
+type cronSpec struct {
+	minute cronField
+	hour   cronField
+	dom    cronField
+	month  cronField
+	dow    cronField
+	// true if the day of the month or the weekday are not restricted (*).
+	domAny bool
+	dowAny bool
+}
+
+// schedule is the set of times when an operator with operand time.schedule matches,
+// evaluated with the local time of the daemon:
+//
+//	18:00-23:00                  every day, from 18:00 to 23:00
+//	mon-fri 09:00-13:00,15:00-18:00
+//	sat,sun                      the whole weekend
+//	fri 22:00-02:00              from friday 22:00 to saturday 02:00
+//	* 18-22 * * 1-5              cron expression: minute hour day month weekday
+type schedule struct {
+	cron    *cronSpec
+	windows []timeWindow
+	// allowed weekdays. All if nil.
+	days *[7]bool
+}
+
+// parseSchedule parses the expression of a time.schedule operator.
+func parseSchedule(expr string) (*schedule, error) {
+	fields := strings.Fields(strings.ToLower(expr))
+	switch len(fields) {
+	case 5:
+		cron, err := parseCron(fields)
+		if err != nil {
+			return nil, err
+		}
+		return &schedule{cron: cron}, nil
+	case 1, 2:
+	default:
+		return nil, fmt.Errorf("invalid schedule '%s', expected: [days] [HH:MM-HH:MM,...] or a cron expression", expr)
+	}
+
+	s := &schedule{}
+	for _, f := range fields {
+		var err error
+		if strings.Contains(f, ":") {
+			if s.windows != nil {
+				return nil, fmt.Errorf("invalid schedule '%s', duplicated time windows", expr)
+			}
+			s.windows, err = parseTimeWindows(f)
+		} else {
+			if s.days != nil {
+				return nil, fmt.Errorf("invalid schedule '%s', duplicated days", expr)
+			}
+			s.days, err = parseWeekdays(f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func parseWeekdays(spec string) (*[7]bool, error) {
+	days := &[7]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, found := weekdays[from]
+		if !found {
+			return nil, fmt.Errorf("invalid weekday '%s'", from)
+		}
+		last := first
+		if isRange {
+			if last, found = weekdays[to]; !found {
+				return nil, fmt.Errorf("invalid weekday '%s'", to)
+			}
+		}
+		// sat-mon wraps around the end of the week
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseTimeWindows(spec string) ([]timeWindow, error) {
+	var windows []timeWindow
+	for _, part := range strings.Split(spec, ",") {
+		from, to, found := strings.Cut(part, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid time window '%s', expected HH:MM-HH:MM", part)
+		}
+		start, err := parseClock(from)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(to)
+		if err != nil {
+			return nil, err
+		}
+		if start == end || start == 24*60 {
+			return nil, fmt.Errorf("invalid time window '%s'", part)
+		}
+		windows = append(windows, timeWindow{start: start, end: end})
+	}
+	return windows, nil
+}
+
+// parseClock returns the minute of the day of HH:MM. 24:00 is the end of the day.
+func parseClock(clock string) (int, error) {
+	h, m, found := strings.Cut(clock, ":")
+	hour, errH := strconv.Atoi(h)
+	min, errM := strconv.Atoi(m)
+	if !found || errH != nil || errM != nil || hour < 0 || min < 0 || min > 59 ||
+		hour > 24 || (hour == 24 && min > 0) {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", clock)
+	}
+	return hour*60 + min, nil
+}
+
+func parseCron(fields []string) (*cronSpec, error) {
+	var err error
+	c := &cronSpec{}
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	// 0 and 7 are sunday
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+// parseCronField parses a field of a cron expression: *, */n, a, a-b, a-b/n
+// and lists of them. Weekdays can be also written by name: mon-fri.
+func parseCronField(field string, min, max int) (cronField, error) {
+	var bits cronField
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, s, found := strings.Cut(part, "/"); found {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid cron step '%s'", part)
+			}
+			rng, step = r, n
+		}
+		first, last := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if first, err = cronValue(from, max); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = cronValue(to, max); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if first < min || last > max || first > last {
+			return 0, fmt.Errorf("invalid cron field '%s', values must be between %d and %d", part, min, max)
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(v string, max int) (int, error) {
+	// weekdays by name
+	if max == 7 {
+		if d, found := weekdays[v]; found {
+			return int(d), nil
+		}
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cron value '%s'", v)
+	}
+	return n, nil
+}
+
+func (f cronField) has(v int) bool {
+	return f&(1<<uint(v)) != 0
+}
+
+func (c *cronSpec) active(t time.Time) bool {
+	if !c.minute.has(t.Minute()) || !c.hour.has(t.Hour()) || !c.month.has(int(t.Month())) {
+		return false
+	}
+	dom, dow := c.dom.has(t.Day()), c.dow.has(int(t.Weekday()))
+	// like cron, if both fields are restricted, any of them must match.
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+func (s *schedule) dayAllowed(d time.Weekday) bool {
+	return s.days == nil || s.days[d]
+}
+
+// active returns true if the given time is within the schedule.
+func (s *schedule) active(t time.Time) bool {
+	if s.cron != nil {
+		return s.cron.active(t)
+	}
+	if len(s.windows) == 0 {
+		return s.dayAllowed(t.Weekday())
+	}
+	minute := t.Hour()*60 + t.Minute()
+	yesterday := (t.Weekday() + 6) % 7
+	for _, w := range s.windows {
+		if w.start < w.end {
+			if s.dayAllowed(t.Weekday()) && minute >= w.start && minute < w.end {
+				return true
+			}
+			continue
+		}
+		// the window started the day before
+		if (s.dayAllowed(t.Weekday()) && minute >= w.start) ||
+			(s.dayAllowed(yesterday) && minute < w.end) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+)
+
+// 2024-01-05 is a friday
+func at(day, hour, min int) time.Time {
+	return time.Date(2024, time.January, day, hour, min, 0, 0, time.Local)
+}
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		expr   string
+		time   time.Time
+		active bool
+	}{
+		{"18:00-23:00", at(5, 18, 0), true},
+		{"18:00-23:00", at(5, 22, 59), true},
+		{"18:00-23:00", at(5, 23, 0), false},
+		{"18:00-23:00", at(5, 17, 59), false},
+		{"mon-fri 09:00-13:00,15:00-18:00", at(5, 16, 30), true},
+		{"mon-fri 09:00-13:00,15:00-18:00", at(5, 14, 0), false},
+		{"mon-fri 09:00-13:00,15:00-18:00", at(6, 10, 0), false},
+		{"sat,sun", at(7, 12, 0), true},
+		{"sat,sun", at(8, 12, 0), false},
+		{"fri-mon", at(8, 12, 0), true},
+		{"fri-mon", at(9, 12, 0), false},
+		// the window continues the next day
+		{"fri 22:00-02:00", at(5, 23, 0), true},
+		{"fri 22:00-02:00", at(6, 1, 59), true},
+		{"fri 22:00-02:00", at(6, 2, 0), false},
+		{"fri 22:00-02:00", at(5, 1, 0), false},
+		{"22:00-24:00", at(5, 23, 59), true},
+		// cron expressions
+		{"* 18-22 * * 1-5", at(5, 18, 30), true},
+		{"* 18-22 * * mon-fri", at(6, 18, 30), false},
+		{"*/15 * * * *", at(5, 10, 45), true},
+		{"*/15 * * * *", at(5, 10, 46), false},
+		{"0 9 1 * 7", at(1, 9, 0), true},
+		{"0 9 1 * 7", at(7, 9, 0), true},
+		{"0 9 1 * 7", at(5, 9, 0), false},
+	}
+
+	for _, tt := range tests {
+		s, err := parseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %s", tt.expr, err)
+			continue
+		}
+		if active := s.active(tt.time); active != tt.active {
+			t.Errorf("%s at %s: expected %v, got %v", tt.expr, tt.time, tt.active, active)
+		}
+	}
+
+	for _, expr := range []string{"", "18:00", "25:00-26:00", "10:00-10:00", "mon-xyz", "mon 10:00-11:00 tue", "* * *", "60 * * * *", "*/0 * * * *"} {
+		if _, err := parseSchedule(expr); err == nil {
+			t.Errorf("%s: invalid schedule parsed", expr)
+		}
+	}
+}
+
+func TestScheduleClosed(t *testing.T) {
+	now := at(5, 22, 30)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+	closed := make(chan []*conman.Connection, 1)
+	l.OnScheduleClosed(func(r *Rule, cons []*conman.Connection) {
+		closed <- cons
+	})
+
+	list := []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: defaultProcPath},
+		{Type: Simple, Operand: OpSchedule, Data: "18:00-23:00"},
+	}
+	op, _ := NewOperator(List, false, OpList, "", list)
+	if err = l.Add(Create("000-allow-curl-evenings", "", true, false, false, Allow, Always, op), false); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+	if l.Cacheable() {
+		t.Error("the verdicts of scheduled rules should not be cached")
+	}
+	if match := l.FindFirstMatch(conn); match == nil || match.Name != "000-allow-curl-evenings" {
+		t.Fatalf("the rule should match within its schedule: %v", match)
+	}
+
+	l.scheduler.tick(now)
+	select {
+	case <-closed:
+		t.Fatal("the schedule should not be closed yet")
+	default:
+	}
+
+	now = at(5, 23, 0)
+	if match := l.FindFirstMatch(conn); match != nil {
+		t.Errorf("the rule should not match outside of its schedule: %s", match.Name)
+	}
+	l.scheduler.tick(now)
+	select {
+	case cons := <-closed:
+		if len(cons) != 1 || cons[0] != conn {
+			t.Errorf("unexpected connections to re-evaluate: %v", cons)
+		}
+	default:
+		t.Fatal("the connections of the rule should be re-evaluated when the schedule closes")
+	}
+}
@@ -0,0 +1,181 @@
+package rule
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// maxScheduledConnections is the max number of connections recorded for a
+// scheduled rule. Above this number the oldest connections are not
+// re-evaluated when the schedule closes.
+const maxScheduledConnections = 1024
+
+type scheduleRecord struct {
+	rule *Rule
+	// connections allowed while the schedule was open, by 5-tuple.
+	cons  map[string]*conman.Connection
+	order []string
+	open  bool
+}
+
+// scheduler records the connections allowed by rules with a time.schedule
+// operand, and re-evaluates them when the schedule of the rule closes, so the
+// connections established during the time window don't outlive it.
+type scheduler struct {
+	records map[string]*scheduleRecord
+	onClose func(r *Rule, cons []*conman.Connection)
+	stop    chan struct{}
+	active  atomic.Int32
+	sync.Mutex
+}
+
+// OnScheduleClosed registers the function to call with the connections allowed
+// by a rule, when its schedule closes.
+func (l *Loader) OnScheduleClosed(cb func(r *Rule, cons []*conman.Connection)) {
+	l.scheduler.Lock()
+	l.scheduler.onClose = cb
+	l.scheduler.Unlock()
+}
+
+// hasSchedule returns true if the rule is restricted to a schedule.
+func hasSchedule(op *Operator) bool {
+	if op.Operand == OpSchedule {
+		return true
+	}
+	for i := range op.List {
+		if hasSchedule(&op.List[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleOpen returns true if all the schedules of an operator are active at
+// the given time.
+func scheduleOpen(op *Operator, t time.Time) bool {
+	if op.Operand == OpSchedule {
+		return op.schedule != nil && op.schedule.active(t)
+	}
+	for i := range op.List {
+		if !scheduleOpen(&op.List[i], t) {
+			return false
+		}
+	}
+	return true
+}
+
+func connectionKey(con *conman.Connection) string {
+	return fmt.Sprintf("%s:%s:%d:%s:%d", con.Protocol, con.SrcIP, con.SrcPort, con.DstIP, con.DstPort)
+}
+
+// update watches the scheduled rules among the active rules, and starts or
+// stops the clock.
+func (s *scheduler) update(rules []*Rule) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := timeNow()
+	records := make(map[string]*scheduleRecord)
+	for _, r := range rules {
+		if !r.Action.Allows() || !hasSchedule(&r.Operator) {
+			continue
+		}
+		if rec, found := s.records[r.Name]; found && rec.rule == r {
+			records[r.Name] = rec
+			continue
+		}
+		records[r.Name] = &scheduleRecord{
+			rule: r,
+			cons: make(map[string]*conman.Connection),
+			open: scheduleOpen(&r.Operator, now),
+		}
+	}
+	s.records = records
+	s.active.Store(int32(len(records)))
+
+	if len(records) > 0 && s.stop == nil {
+		s.stop = make(chan struct{})
+		go s.run(s.stop)
+	} else if len(records) == 0 && s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// record saves a connection allowed by a scheduled rule.
+func (s *scheduler) record(r *Rule, con *conman.Connection) {
+	if r == nil || s.active.Load() == 0 {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	rec, found := s.records[r.Name]
+	if !found || rec.rule != r {
+		return
+	}
+	key := connectionKey(con)
+	if _, found := rec.cons[key]; found {
+		return
+	}
+	if len(rec.order) >= maxScheduledConnections {
+		delete(rec.cons, rec.order[0])
+		rec.order = rec.order[1:]
+	}
+	rec.cons[key] = con
+	rec.order = append(rec.order, key)
+}
+
+// run checks the schedules every minute, which is their resolution.
+func (s *scheduler) run(stop chan struct{}) {
+	log.Debug("[scheduler] started")
+	for {
+		now := timeNow()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-stop:
+			log.Debug("[scheduler] stopped")
+			return
+		case <-time.After(next.Sub(now)):
+			s.tick(timeNow())
+		}
+	}
+}
+
+// tick re-evaluates the connections of the rules whose schedule has closed.
+func (s *scheduler) tick(now time.Time) {
+	type closed struct {
+		rule *Rule
+		cons []*conman.Connection
+	}
+	var closedRules []closed
+
+	s.Lock()
+	for _, rec := range s.records {
+		open := scheduleOpen(&rec.rule.Operator, now)
+		if rec.open && !open {
+			cons := make([]*conman.Connection, 0, len(rec.order))
+			for _, key := range rec.order {
+				cons = append(cons, rec.cons[key])
+			}
+			closedRules = append(closedRules, closed{rec.rule, cons})
+			rec.cons = make(map[string]*conman.Connection)
+			rec.order = nil
+		}
+		rec.open = open
+	}
+	cb := s.onClose
+	s.Unlock()
+
+	for _, c := range closedRules {
+		log.Info("[scheduler] schedule of rule %s closed, re-evaluating %d connections", c.rule.Name, len(c.cons))
+		if cb != nil && len(c.cons) > 0 {
+			cb(c.rule, c.cons)
+		}
+	}
+}
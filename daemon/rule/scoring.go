@@ -0,0 +1,94 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+)
+
+// ScoringRuleName is the name of the rules returned when the verdict of a
+// connection is decided by its score.
+const ScoringRuleName = "scoring"
+
+// ScoringConfig configures the evaluation of the rules by score.
+//
+// The rules with a score (reputation of the destination, status of the
+// package of the binary, category of the destination, ...) add it to the
+// score of the connections they match. The connections whose score reaches
+// DenyThreshold are denied, unless a rule with precedence allows them, and
+// the ones whose score is equal or lower than AllowThreshold are allowed,
+// unless another rule matches them.
+type ScoringConfig struct {
+	Enabled        bool  `json:"Enabled"`
+	DenyThreshold  int32 `json:"DenyThreshold"`
+	AllowThreshold int32 `json:"AllowThreshold"`
+	// Action of the connections denied by score: deny (default) or reject.
+	DenyAction Action `json:"DenyAction"`
+}
+
+type scoring struct {
+	cfg ScoringConfig
+	sync.RWMutex
+}
+
+// SetScoring configures the evaluation of the rules by score.
+func (l *Loader) SetScoring(cfg ScoringConfig) error {
+	switch cfg.DenyAction {
+	case "":
+		cfg.DenyAction = Deny
+	case Deny, Reject:
+	default:
+		return fmt.Errorf("invalid scoring DenyAction %s, it must be %s or %s", cfg.DenyAction, Deny, Reject)
+	}
+	if cfg.Enabled && cfg.AllowThreshold >= cfg.DenyThreshold {
+		return fmt.Errorf("invalid scoring thresholds, AllowThreshold (%d) must be lower than DenyThreshold (%d)", cfg.AllowThreshold, cfg.DenyThreshold)
+	}
+	l.scoring.Lock()
+	l.scoring.cfg = cfg
+	l.scoring.Unlock()
+	return nil
+}
+
+func (l *Loader) scoringConfig() ScoringConfig {
+	l.scoring.RLock()
+	defer l.scoring.RUnlock()
+	return l.scoring.cfg
+}
+
+// connScore accumulates the scores of the rules that match a connection.
+type connScore struct {
+	rules []string
+	total int32
+}
+
+func (s *connScore) add(r *Rule) {
+	s.total += r.Score
+	s.rules = append(s.rules, fmt.Sprintf("%s (%+d)", r.Name, r.Score))
+}
+
+// verdict returns the rule to apply to a connection given its score, and the
+// rule that matched it, if any.
+func (s *connScore) verdict(cfg ScoringConfig, match *Rule, con *conman.Connection) *Rule {
+	if len(s.rules) == 0 {
+		return match
+	}
+	var action Action
+	switch {
+	case s.total >= cfg.DenyThreshold:
+		action = cfg.DenyAction
+	case match != nil:
+		return match
+	case s.total <= cfg.AllowThreshold:
+		action = Allow
+	default:
+		return nil
+	}
+
+	op, _ := NewOperator(Simple, false, OpTrue, "", nil)
+	return Create(
+		ScoringRuleName,
+		fmt.Sprintf("score %d of %s -> %s: %s", s.total, con.Process.Path, con.To(), strings.Join(s.rules, ", ")),
+		true, false, false, action, Once, op)
+}
@@ -0,0 +1,75 @@
+package rule
+
+import (
+	"testing"
+)
+
+func TestRuleLoaderScoring(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+
+	addRule := func(name string, action Action, score int32, operand Operand, data string) *Rule {
+		op, _ := NewOperator(Simple, false, operand, data, nil)
+		r := Create(name, "", true, false, false, action, Always, op)
+		r.Score = score
+		if err := l.Add(r, false); err != nil {
+			t.Fatal("Error adding rule: ", err)
+		}
+		return r
+	}
+	addRule("score-host", Allow, 60, OpDstHost, defaultDstHost)
+	addRule("score-port", Allow, 50, OpDstPort, "443")
+
+	if r := l.FindFirstMatch(conn); r != nil {
+		t.Error("rules with score applied with scoring disabled:", r.Name)
+	}
+
+	if err := l.SetScoring(ScoringConfig{Enabled: true, DenyThreshold: 100, AllowThreshold: 100}); err == nil {
+		t.Error("invalid thresholds accepted")
+	}
+	if err := l.SetScoring(ScoringConfig{Enabled: true, DenyThreshold: 100, DenyAction: Allow}); err == nil {
+		t.Error("invalid DenyAction accepted")
+	}
+	if err := l.SetScoring(ScoringConfig{Enabled: true, DenyThreshold: 100, AllowThreshold: -10}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := l.FindFirstMatch(conn)
+	if r == nil || r.Name != ScoringRuleName || r.Action != Deny {
+		t.Fatal("connection over the deny threshold not denied:", r)
+	}
+
+	// the score prevails over the rules without precedence.
+	allow := addRule("allow-opensnitchd", Allow, 0, OpProcessPath, defaultProcPath)
+	if r := l.FindFirstMatch(conn); r == nil || r.Name != ScoringRuleName {
+		t.Error("the score should prevail over rules without precedence:", r)
+	}
+	allow.Precedence = true
+	if err := l.Replace(allow, false); err != nil {
+		t.Fatal(err)
+	}
+	if r := l.FindFirstMatch(conn); r == nil || r.Name != allow.Name {
+		t.Error("the rules with precedence should prevail over the score:", r)
+	}
+	allow.Enabled = false
+	if err := l.Replace(allow, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// below the deny threshold, the connection is not decided by score.
+	addRule("trust-opensnitchd", Allow, -80, OpProcessPath, defaultProcPath)
+	if r := l.FindFirstMatch(conn); r != nil {
+		t.Error("connection between thresholds decided by score:", r)
+	}
+
+	addRule("trust-user", Allow, -50, OpUserID, "666")
+	r = l.FindFirstMatch(conn)
+	if r == nil || r.Name != ScoringRuleName || r.Action != Allow {
+		t.Error("connection under the allow threshold not allowed:", r)
+	}
+}
@@ -0,0 +1,289 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// Sources of the changes of the rules.
+const (
+	SourceDaemon = "daemon"
+	SourceFile   = "file"
+	SourceGUI    = "gui"
+	SourcePrompt = "prompt"
+	SourceSync   = "rulesync"
+)
+
+// DefaultMaxSnapshots is the number of snapshots of the rules kept by default.
+const DefaultMaxSnapshots = 50
+
+// Snapshot is a version of the rules saved on disk (duration always), created
+// by a change of the rules: who made the change (source), when, and the rules
+// added, modified and deleted.
+// The temporary rules are not versioned, they expire by themselves.
+type Snapshot struct {
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`
+	Action   string    `json:"action"`
+	Added    []string  `json:"added,omitempty"`
+	Modified []string  `json:"modified,omitempty"`
+	Deleted  []string  `json:"deleted,omitempty"`
+	// rules of the snapshot, only returned by GetSnapshot().
+	Rules    []*Rule `json:"rules,omitempty"`
+	ID       uint64  `json:"id"`
+	NumRules int     `json:"num_rules"`
+
+	// rules serialized, by name.
+	rules map[string][]byte
+}
+
+// ruleVersion is the serialized form of a rule to compare its versions,
+// without the time of the last update.
+type ruleVersion struct {
+	*Rule
+	Updated string `json:"updated,omitempty"`
+}
+
+// history holds the snapshots of the rules, the oldest first.
+type history struct {
+	snapshots []*Snapshot
+	// rules serialized by the last snapshot. The rules are replaced when
+	// they change, not modified.
+	serialized map[*Rule][]byte
+	lastID     uint64
+	max        int
+	// number of Track() calls running.
+	tracking int
+	onChange func(s *Snapshot)
+	sync.Mutex
+}
+
+// OnChange sets the function called after every change of the rules, with the
+// snapshot of the change, without its rules.
+// If the snapshots are disabled, or only the temporary rules have changed, the
+// snapshot has no ID nor rules added, modified or deleted.
+func (l *Loader) OnChange(fn func(s *Snapshot)) {
+	l.history.Lock()
+	l.history.onChange = fn
+	l.history.Unlock()
+}
+
+// SetMaxSnapshots sets the max number of snapshots of the rules kept, the
+// oldest are deleted. 0 disables the snapshots.
+func (l *Loader) SetMaxSnapshots(max int) {
+	h := &l.history
+	h.Lock()
+	defer h.Unlock()
+	h.max = max
+	if max <= 0 {
+		h.snapshots = nil
+		h.serialized = nil
+		return
+	}
+	if len(h.snapshots) > max {
+		h.snapshots = append([]*Snapshot{}, h.snapshots[len(h.snapshots)-max:]...)
+	}
+}
+
+// Snapshots returns the snapshots of the rules, the newest first, without
+// their rules.
+func (l *Loader) Snapshots() []*Snapshot {
+	h := &l.history
+	h.Lock()
+	defer h.Unlock()
+	list := make([]*Snapshot, 0, len(h.snapshots))
+	for i := len(h.snapshots) - 1; i >= 0; i-- {
+		s := *h.snapshots[i]
+		s.rules = nil
+		list = append(list, &s)
+	}
+	return list
+}
+
+// GetSnapshot returns a snapshot of the rules, with its rules in the order
+// they're evaluated.
+func (l *Loader) GetSnapshot(id uint64) (*Snapshot, error) {
+	h := &l.history
+	h.Lock()
+	var found *Snapshot
+	for _, s := range h.snapshots {
+		if s.ID == id {
+			found = s
+			break
+		}
+	}
+	h.Unlock()
+	if found == nil {
+		return nil, fmt.Errorf("snapshot %d not found", id)
+	}
+
+	snapshot := *found
+	snapshot.Rules = make([]*Rule, 0, len(found.rules))
+	for name, raw := range found.rules {
+		var r Rule
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, fmt.Errorf("snapshot %d, invalid rule %s: %s", id, name, err)
+		}
+		snapshot.Rules = append(snapshot.Rules, &r)
+	}
+	sortByPriority(snapshot.Rules)
+	return &snapshot, nil
+}
+
+// Track runs fn, which changes the rules, and records all its changes in a
+// single snapshot, attributed to source.
+func (l *Loader) Track(source, action string, fn func() error) error {
+	l.history.Lock()
+	l.history.tracking++
+	l.history.Unlock()
+
+	err := fn()
+
+	l.history.Lock()
+	l.history.tracking--
+	l.history.Unlock()
+	l.recordChange(source, action)
+	return err
+}
+
+// Rollback restores the rules of a snapshot, atomically: if any of the rules
+// can't be restored, the current rules are restored.
+// The rules saved on disk not included in the snapshot are deleted, and the
+// temporary rules are kept.
+func (l *Loader) Rollback(id uint64, source string) (*ImportResult, error) {
+	snapshot, err := l.GetSnapshot(id)
+	if err != nil {
+		return nil, err
+	}
+	current := l.serializeRules()
+	changed := make([]*Rule, 0, len(snapshot.Rules))
+	for _, r := range snapshot.Rules {
+		if raw, found := current[r.Name]; !found || string(raw) != string(snapshot.rules[r.Name]) {
+			changed = append(changed, r)
+		}
+	}
+
+	var result *ImportResult
+	err = l.Track(source, fmt.Sprintf("rollback to snapshot %d", id), func() (err error) {
+		result, err = l.restore(changed, func(r *Rule) bool {
+			_, inSnapshot := snapshot.rules[r.Name]
+			return !inSnapshot && r.Duration == Always
+		})
+		return err
+	})
+	return result, err
+}
+
+// recordChange creates a snapshot of the rules if they have changed since the
+// last one, unless the changes are being tracked by Track().
+func (l *Loader) recordChange(source, action string) {
+	h := &l.history
+	h.Lock()
+	skip, onChange := h.tracking > 0, h.onChange
+	h.Unlock()
+	if skip {
+		return
+	}
+
+	snapshot := l.takeSnapshot(source, action)
+	if onChange == nil {
+		return
+	}
+	if snapshot == nil {
+		snapshot = &Snapshot{Time: time.Now(), Source: source, Action: action}
+	}
+	change := *snapshot
+	change.rules = nil
+	onChange(&change)
+}
+
+// takeSnapshot returns the snapshot of the rules created, or nil if they have
+// not changed since the last one, or the snapshots are disabled.
+func (l *Loader) takeSnapshot(source, action string) *Snapshot {
+	h := &l.history
+	h.Lock()
+	disabled := h.max <= 0
+	h.Unlock()
+	if disabled {
+		return nil
+	}
+
+	rules := l.serializeRules()
+
+	h.Lock()
+	defer h.Unlock()
+	var previous map[string][]byte
+	if len(h.snapshots) > 0 {
+		previous = h.snapshots[len(h.snapshots)-1].rules
+	}
+	snapshot := &Snapshot{
+		Time:     time.Now(),
+		Source:   source,
+		Action:   action,
+		NumRules: len(rules),
+		rules:    rules,
+	}
+	for name, raw := range rules {
+		if old, found := previous[name]; !found {
+			snapshot.Added = append(snapshot.Added, name)
+		} else if string(old) != string(raw) {
+			snapshot.Modified = append(snapshot.Modified, name)
+		}
+	}
+	for name := range previous {
+		if _, found := rules[name]; !found {
+			snapshot.Deleted = append(snapshot.Deleted, name)
+		}
+	}
+	if len(snapshot.Added)+len(snapshot.Modified)+len(snapshot.Deleted) == 0 {
+		return nil
+	}
+	sort.Strings(snapshot.Added)
+	sort.Strings(snapshot.Modified)
+	sort.Strings(snapshot.Deleted)
+
+	h.lastID++
+	snapshot.ID = h.lastID
+	h.snapshots = append(h.snapshots, snapshot)
+	if len(h.snapshots) > h.max {
+		h.snapshots = h.snapshots[1:]
+	}
+	log.Debug("[rules] snapshot %d (%s, %s): added %v, modified %v, deleted %v",
+		snapshot.ID, source, action, snapshot.Added, snapshot.Modified, snapshot.Deleted)
+	return snapshot
+}
+
+// serializeRules returns the rules saved on disk serialized, by name.
+// The rules not replaced since the last call are not serialized again.
+func (l *Loader) serializeRules() map[string][]byte {
+	l.RLock()
+	defer l.RUnlock()
+	h := &l.history
+	h.Lock()
+	defer h.Unlock()
+
+	serialized := make(map[*Rule][]byte, len(l.rules))
+	rules := make(map[string][]byte, len(l.rules))
+	for name, r := range l.rules {
+		if r.Duration != Always {
+			continue
+		}
+		raw, found := h.serialized[r]
+		if !found {
+			var err error
+			if raw, err = json.Marshal(&ruleVersion{Rule: r}); err != nil {
+				log.Warning("[rules] snapshot, error serializing rule %s: %s", name, err)
+				continue
+			}
+		}
+		serialized[r] = raw
+		rules[name] = raw
+	}
+	h.serialized = serialized
+	return rules
+}
@@ -0,0 +1,123 @@
+package rule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Snapshots()) != 0 {
+		t.Fatal("snapshot created without rules")
+	}
+
+	l.Add(newBulkRule(t, "000-allow-dns", Always, OpDstPort, "53"), true)
+	l.Replace(newBulkRule(t, "001-allow-curl", Always, OpProcessPath, "/usr/bin/curl"), true)
+	// the temporary rules are not versioned.
+	l.Replace(newBulkRule(t, "002-allow-ssh", Restart, OpDstPort, "22"), false)
+	dns := newBulkRule(t, "000-allow-dns", Always, OpDstPort, "5353")
+	l.Track(SourceGUI, "change rule", func() error {
+		l.Replace(dns, true)
+		return l.Delete("001-allow-curl")
+	})
+	// the rules not modified don't create snapshots.
+	same := newBulkRule(t, "000-allow-dns", Always, OpDstPort, "5353")
+	same.Created = dns.Created
+	l.Replace(same, true)
+
+	list := l.Snapshots()
+	if len(list) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d: %+v", len(list), list)
+	}
+	if s := list[0]; s.ID != 3 || s.Source != SourceGUI || s.Action != "change rule" || s.NumRules != 1 ||
+		len(s.Modified) != 1 || s.Modified[0] != "000-allow-dns" || len(s.Deleted) != 1 || s.Deleted[0] != "001-allow-curl" {
+		t.Errorf("invalid snapshot: %+v", s)
+	}
+	if s := list[2]; s.ID != 1 || s.Source != SourceDaemon || len(s.Added) != 1 || s.Rules != nil {
+		t.Errorf("invalid snapshot: %+v", s)
+	}
+
+	snapshot, err := l.GetSnapshot(2)
+	if err != nil {
+		t.Fatal("GetSnapshot() error:", err)
+	}
+	if len(snapshot.Rules) != 2 || snapshot.Rules[0].Operator.Data != "53" || snapshot.Rules[1].Name != "001-allow-curl" {
+		t.Errorf("invalid rules of the snapshot: %+v", snapshot.Rules)
+	}
+	if _, err := l.GetSnapshot(10); err == nil {
+		t.Error("unknown snapshot found")
+	}
+
+	l.Add(newBulkRule(t, "003-allow-http", Always, OpDstPort, "80"), true)
+	result, err := l.Rollback(2, "opensnitch-cli")
+	if err != nil {
+		t.Fatal("Rollback() error:", err)
+	}
+	if len(result.Added) != 1 || len(result.Replaced) != 1 || len(result.Deleted) != 1 || result.Deleted[0] != "003-allow-http" {
+		t.Errorf("invalid rollback result: %+v", result)
+	}
+	all := l.GetAll()
+	if len(all) != 3 || all["000-allow-dns"].Operator.Data != "53" || all["001-allow-curl"] == nil || all["002-allow-ssh"] == nil {
+		t.Error("rules not rolled back:", all)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "003-allow-http.json")); !os.IsNotExist(err) {
+		t.Error("rule deleted by the rollback not deleted from disk")
+	}
+	if s := l.Snapshots()[0]; s.ID != 5 || s.Source != "opensnitch-cli" || s.Action != "rollback to snapshot 2" {
+		t.Errorf("rollback not recorded: %+v", s)
+	}
+
+	l.SetMaxSnapshots(2)
+	if list = l.Snapshots(); len(list) != 2 || list[1].ID != 4 {
+		t.Errorf("old snapshots not deleted: %+v", list)
+	}
+	l.SetMaxSnapshots(0)
+	l.Delete("000-allow-dns")
+	if len(l.Snapshots()) != 0 {
+		t.Error("snapshot created with the snapshots disabled")
+	}
+}
+
+func TestSnapshotsOnChange(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	var changes []*Snapshot
+	l.OnChange(func(s *Snapshot) {
+		changes = append(changes, s)
+	})
+
+	l.Track(SourceGUI, "import rules", func() error {
+		l.Add(newBulkRule(t, "000-allow-dns", Always, OpDstPort, "53"), true)
+		return l.Add(newBulkRule(t, "001-allow-curl", Always, OpProcessPath, "/usr/bin/curl"), true)
+	})
+	// the changes of the temporary rules are notified, without snapshot.
+	l.Replace(newBulkRule(t, "002-allow-ssh", Restart, OpDstPort, "22"), false)
+	l.SetMaxSnapshots(0)
+	l.Delete("000-allow-dns")
+
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d: %+v", len(changes), changes)
+	}
+	if s := changes[0]; s.ID != 1 || s.Source != SourceGUI || s.Action != "import rules" || len(s.Added) != 2 || s.rules != nil {
+		t.Errorf("invalid change: %+v", s)
+	}
+	if s := changes[1]; s.ID != 0 || s.Action != "change rule" || len(s.Added) != 0 {
+		t.Errorf("invalid change of a temporary rule: %+v", s)
+	}
+	if s := changes[2]; s.ID != 0 || s.Action != "delete rule" || s.Time.IsZero() {
+		t.Errorf("invalid change with the snapshots disabled: %+v", s)
+	}
+}
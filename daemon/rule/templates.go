@@ -0,0 +1,401 @@
+package rule
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+)
+
+// Rules templates.
+//
+// The templates are rules saved to <rules path>/templates/*.json, whose
+// strings may reference variables: $NAME or ${NAME} ($$ for a literal $).
+// The variables are defined in <rules path>/templates/variables.vars:
+//
+//	{
+//	    "include": ["networks.vars"],
+//	    "variables": {
+//	        "HOME_NET": "192.168.1.0/24",
+//	        "BROWSERS": ["/usr/bin/firefox", "/usr/bin/chromium"]
+//	    }
+//	}
+//
+// A variable is a string or a list of strings, and its values may reference
+// other variables. The included files are read first, so the variables of
+// the file that includes them take precedence.
+//
+// Every template expands into one rule per combination of the values of the
+// variables it references: allow-browsers, with process.path = $BROWSERS,
+// expands into allow-browsers-firefox and allow-browsers-chromium. The rules
+// are expanded again when the templates or the variables change. They're not
+// saved to disk, and a rule saved to disk with the same name takes precedence.
+const (
+	TemplatesDir  = "templates"
+	VariablesFile = "variables.vars"
+)
+
+var (
+	varRefRegexp    = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+	invalidNameRune = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+	// max number of rules a template can expand into.
+	maxTemplateRules = 1024
+	// max depth of includes and references between variables.
+	maxVariablesDepth = 16
+)
+
+// variablesFile is the format of the variables files.
+type variablesFile struct {
+	Include   []string                   `json:"include"`
+	Variables map[string]json.RawMessage `json:"variables"`
+}
+
+// Variables are the values of the variables of the templates.
+type Variables map[string][]string
+
+// LoadVariables reads a variables file, and the files it includes, and
+// resolves the references between the variables.
+func LoadVariables(path string) (Variables, error) {
+	raw := make(map[string][]string)
+	if err := readVariables(path, raw, 0); err != nil {
+		return nil, err
+	}
+	vars := make(Variables, len(raw))
+	for name := range raw {
+		values, err := resolveVariable(name, raw, 0)
+		if err != nil {
+			return nil, err
+		}
+		vars[name] = values
+	}
+	return vars, nil
+}
+
+func readVariables(path string, vars map[string][]string, depth int) error {
+	if depth > maxVariablesDepth {
+		return fmt.Errorf("too many nested includes: %s", path)
+	}
+	raw, err := core.ReadSealedFile(path)
+	if err != nil {
+		return err
+	}
+	var file variablesFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("error parsing %s: %s", path, err)
+	}
+	for _, inc := range file.Include {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		if err := readVariables(inc, vars, depth+1); err != nil {
+			return err
+		}
+	}
+	for name, value := range file.Variables {
+		var values []string
+		if err := json.Unmarshal(value, &values); err != nil {
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return fmt.Errorf("%s: variable %s must be a string or a list of strings", path, name)
+			}
+			values = []string{s}
+		}
+		vars[name] = values
+	}
+	return nil
+}
+
+// resolveVariable expands the references to other variables of the values of
+// a variable. A value that only references a list inserts all its values.
+func resolveVariable(name string, raw map[string][]string, depth int) ([]string, error) {
+	if depth > maxVariablesDepth {
+		return nil, fmt.Errorf("variable %s: too many nested references, or circular reference", name)
+	}
+	values, found := raw[name]
+	if !found {
+		return nil, fmt.Errorf("undefined variable: %s", name)
+	}
+	resolved := make([]string, 0, len(values))
+	for _, v := range values {
+		refs := varRefs(v)
+		// a value that only references a variable inserts all its values.
+		if len(refs) == 1 && (v == "$"+refs[0] || v == "${"+refs[0]+"}") {
+			refValues, err := resolveVariable(refs[0], raw, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, refValues...)
+			continue
+		}
+		sub := make(map[string]string, len(refs))
+		for _, ref := range refs {
+			refValues, err := resolveVariable(ref, raw, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if len(refValues) != 1 {
+				return nil, fmt.Errorf("variable %s: the list %s can only be referenced alone", name, ref)
+			}
+			sub[ref] = refValues[0]
+		}
+		resolved = append(resolved, expandVars(v, sub))
+	}
+	return resolved, nil
+}
+
+// varRefs returns the names of the variables referenced by a string.
+func varRefs(s string) []string {
+	var refs []string
+	for _, m := range varRefRegexp.FindAllStringSubmatch(s, -1) {
+		name := m[1] + m[2]
+		if name != "" && !slices.Contains(refs, name) {
+			refs = append(refs, name)
+		}
+	}
+	return refs
+}
+
+// expandVars replaces the references to variables of a string.
+func expandVars(s string, values map[string]string) string {
+	return varRefRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		return values[strings.Trim(ref, "${}")]
+	})
+}
+
+// walkStrings calls fn with every string value of a decoded JSON document,
+// replacing the value with the returned one.
+func walkStrings(v interface{}, fn func(string) string) interface{} {
+	switch val := v.(type) {
+	case string:
+		return fn(val)
+	case []interface{}:
+		for i := range val {
+			val[i] = walkStrings(val[i], fn)
+		}
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = walkStrings(val[k], fn)
+		}
+	}
+	return v
+}
+
+// ExpandTemplate expands a template into the rules of every combination of
+// the values of the variables it references.
+func ExpandTemplate(raw []byte, vars Variables) ([]*Rule, error) {
+	var tmpl map[string]interface{}
+	if err := json.Unmarshal(raw, &tmpl); err != nil {
+		return nil, err
+	}
+	name, _ := tmpl["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("the name of the template is empty")
+	}
+	var refs []string
+	walkStrings(tmpl, func(s string) string {
+		for _, ref := range varRefs(s) {
+			if !slices.Contains(refs, ref) {
+				refs = append(refs, ref)
+			}
+		}
+		return s
+	})
+	sort.Strings(refs)
+
+	total := 1
+	for _, ref := range refs {
+		values, found := vars[ref]
+		if !found {
+			return nil, fmt.Errorf("undefined variable: %s", ref)
+		}
+		total *= len(values)
+		if total > maxTemplateRules {
+			return nil, fmt.Errorf("the template expands into more than %d rules", maxTemplateRules)
+		}
+	}
+
+	rules := make([]*Rule, 0, total)
+	names := make(map[string]bool, total)
+	for i := 0; i < total; i++ {
+		// the values of this combination, and the ones that name the rule.
+		values := make(map[string]string, len(refs))
+		var suffix []string
+		n := i
+		for _, ref := range refs {
+			list := vars[ref]
+			values[ref] = list[n%len(list)]
+			n /= len(list)
+			if len(list) > 1 {
+				suffix = append(suffix, ruleNameSuffix(values[ref]))
+			}
+		}
+
+		var expanded map[string]interface{}
+		json.Unmarshal(raw, &expanded)
+		walkStrings(expanded, func(s string) string {
+			return expandVars(s, values)
+		})
+		buf, err := json.Marshal(expanded)
+		if err != nil {
+			return nil, err
+		}
+		r := &Rule{}
+		if err := json.Unmarshal(buf, r); err != nil {
+			return nil, err
+		}
+
+		// the name of the template may reference the variables too.
+		if len(suffix) > 0 && r.Name == name {
+			r.Name = core.ConcatStrings(name, "-", strings.Join(suffix, "-"))
+		}
+		for base, idx := r.Name, 2; names[r.Name]; idx++ {
+			r.Name = fmt.Sprintf("%s-%d", base, idx)
+		}
+		names[r.Name] = true
+		r.Template = name
+		if r.Created == "" {
+			r.Created = time.Now().Format(time.RFC3339)
+		}
+		if err := Validate(r); err != nil {
+			return nil, fmt.Errorf("rule %s: %s", r.Name, err)
+		}
+		if r.Duration != Always && r.Duration != Restart {
+			return nil, fmt.Errorf("rule %s: the templates can't be temporary rules", r.Name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// ruleNameSuffix returns the part of the name of a rule that identifies the
+// value of a variable: /usr/bin/firefox -> firefox
+func ruleNameSuffix(value string) string {
+	if filepath.IsAbs(value) {
+		value = filepath.Base(value)
+	}
+	value = strings.Trim(invalidNameRune.ReplaceAllString(value, "-"), "-.")
+	if value == "" {
+		value = "value"
+	}
+	return value
+}
+
+// ExpandTemplates expands all the templates of a directory.
+// The templates with errors are skipped.
+func ExpandTemplates(dir string) ([]*Rule, error) {
+	vars := Variables{}
+	varsPath := filepath.Join(dir, VariablesFile)
+	if core.Exists(varsPath) {
+		var err error
+		if vars, err = LoadVariables(varsPath); err != nil {
+			return nil, fmt.Errorf("error loading variables: %s", err)
+		}
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var rules []*Rule
+	names := make(map[string]string)
+	for _, path := range matches {
+		raw, err := core.ReadSealedFile(path)
+		if err != nil {
+			log.Warning("[templates] error reading %s: %s", path, err)
+			continue
+		}
+		expanded, err := ExpandTemplate(raw, vars)
+		if err != nil {
+			log.Warning("[templates] error expanding %s: %s", path, err)
+			continue
+		}
+		for _, r := range expanded {
+			if tmpl, found := names[r.Name]; found {
+				log.Warning("[templates] rule %s of the template %s already defined by the template %s", r.Name, r.Template, tmpl)
+				continue
+			}
+			names[r.Name] = r.Template
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// loadTemplates expands the templates of the rules path, replacing the rules
+// of the previous expansion.
+func (l *Loader) loadTemplates() error {
+	var rules []*Rule
+	dir := filepath.Join(l.Path, TemplatesDir)
+	if core.Exists(dir) {
+		var err error
+		if rules, err = ExpandTemplates(dir); err != nil {
+			// keep the rules of the previous expansion.
+			return err
+		}
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	for name, r := range l.rules {
+		if r.Template != "" {
+			l.cleanListsRule(r)
+			delete(l.rules, name)
+		}
+	}
+	for _, r := range rules {
+		if _, found := l.rules[r.Name]; found {
+			log.Warning("[templates] rule %s already exists, not replaced by the template %s", r.Name, r.Template)
+			continue
+		}
+		if err := l.unmarshalOperatorList(&r.Operator); err != nil {
+			log.Warning("[templates] %s: %s", r.Name, err)
+			continue
+		}
+		if r.Enabled {
+			if err := r.Operator.Compile(); err != nil {
+				log.Warning("[templates] error compiling rule %s: %s", r.Name, err)
+				continue
+			}
+			if err := compileList(&r.Operator); err != nil {
+				log.Warning("[templates] error compiling list rule %s: %s", r.Name, err)
+				continue
+			}
+		}
+		l.rules[r.Name] = r
+	}
+	l.sortRules()
+	if len(rules) > 0 {
+		log.Info("[templates] %d rules expanded from %s", len(rules), dir)
+	}
+	return nil
+}
+
+func compileList(op *Operator) error {
+	if op.Type != List {
+		return nil
+	}
+	for i := 0; i < len(op.List); i++ {
+		if err := op.List[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTemplateFile returns true if a file belongs to the templates of the
+// rules.
+func (l *Loader) isTemplateFile(path string) bool {
+	return filepath.Dir(path) == filepath.Join(l.Path, TemplatesDir) &&
+		(strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".vars"))
+}
@@ -0,0 +1,197 @@
+package rule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const browsersTemplate = `{
+    "name": "allow-browsers",
+    "enabled": true,
+    "action": "allow",
+    "duration": "always",
+    "operator": {
+        "type": "list",
+        "operand": "list",
+        "list": [
+            {"type": "simple", "operand": "process.path", "data": "$BROWSERS"},
+            {"type": "network", "operand": "dest.network", "data": "${HOME_NET}"}
+        ]
+    }
+}`
+
+const dnsTemplate = `{
+    "name": "deny-dns-$RESOLVER",
+    "description": "not $$RESOLVER",
+    "enabled": true,
+    "action": "deny",
+    "duration": "always",
+    "operator": {"type": "simple", "operand": "dest.ip", "data": "$RESOLVER"}
+}`
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadVariables(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "common.vars"), `{"variables": {"HOME_NET": "10.0.0.0/8", "FIREFOX": "/usr/bin/firefox"}}`)
+	writeFile(t, filepath.Join(dir, VariablesFile), `{
+        "include": ["common.vars"],
+        "variables": {
+            "HOME_NET": "192.168.1.0/24",
+            "BROWSERS": ["$FIREFOX", "/usr/bin/chromium"],
+            "WEB": ["$BROWSERS", "/usr/bin/curl"],
+            "FIREFOX_ESR": "${FIREFOX}-esr"
+        }
+    }`)
+	vars, err := LoadVariables(filepath.Join(dir, VariablesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Variables{
+		"HOME_NET":    {"192.168.1.0/24"},
+		"FIREFOX":     {"/usr/bin/firefox"},
+		"BROWSERS":    {"/usr/bin/firefox", "/usr/bin/chromium"},
+		"WEB":         {"/usr/bin/firefox", "/usr/bin/chromium", "/usr/bin/curl"},
+		"FIREFOX_ESR": {"/usr/bin/firefox-esr"},
+	}
+	for name, values := range expected {
+		if len(vars[name]) != len(values) {
+			t.Errorf("%s: unexpected values %v, expected %v", name, vars[name], values)
+			continue
+		}
+		for i := range values {
+			if vars[name][i] != values[i] {
+				t.Errorf("%s: unexpected values %v, expected %v", name, vars[name], values)
+			}
+		}
+	}
+
+	invalid := map[string]string{
+		"circular":      `{"variables": {"A": "$B", "B": "$A"}}`,
+		"undefined":     `{"variables": {"A": "$B"}}`,
+		"embedded list": `{"variables": {"A": ["1", "2"], "B": "x$A"}}`,
+		"invalid type":  `{"variables": {"A": 1}}`,
+	}
+	for name, content := range invalid {
+		path := filepath.Join(dir, name+".vars")
+		writeFile(t, path, content)
+		if _, err := LoadVariables(path); err == nil {
+			t.Errorf("%s: variables should not be valid", name)
+		}
+	}
+}
+
+func TestRuleTemplates(t *testing.T) {
+	rulesDir := t.TempDir()
+	dir := filepath.Join(rulesDir, TemplatesDir)
+	os.Mkdir(dir, 0700)
+	writeFile(t, filepath.Join(dir, VariablesFile), `{"variables": {
+        "HOME_NET": "192.168.1.0/24",
+        "BROWSERS": ["/usr/bin/firefox", "/usr/bin/chromium"],
+        "RESOLVER": "8.8.8.8"
+    }}`)
+	writeFile(t, filepath.Join(dir, "browsers.json"), browsersTemplate)
+	writeFile(t, filepath.Join(dir, "dns.json"), dnsTemplate)
+	writeFile(t, filepath.Join(dir, "undefined.json"), `{"name": "x", "action": "allow", "duration": "always",
+        "operator": {"type": "simple", "operand": "process.path", "data": "$UNDEFINED"}}`)
+
+	// rules saved to disk take precedence
+	writeFile(t, filepath.Join(rulesDir, "allow-browsers-chromium.json"), `{"name": "allow-browsers-chromium",
+        "enabled": true, "action": "deny", "duration": "always",
+        "operator": {"type": "simple", "operand": "process.path", "data": "/usr/bin/chromium"}}`)
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(rulesDir); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := l.GetAll()
+	firefox := rules["allow-browsers-firefox"]
+	if firefox == nil || firefox.Template != "allow-browsers" ||
+		firefox.Operator.List[0].Data != "/usr/bin/firefox" || firefox.Operator.List[1].Data != "192.168.1.0/24" {
+		t.Fatalf("the template has not been expanded: %v", rules)
+	}
+	if dns := rules["deny-dns-8.8.8.8"]; dns == nil || dns.Operator.Data != "8.8.8.8" || dns.Description != "not $RESOLVER" {
+		t.Errorf("the name of the template has not been expanded: %v", rules)
+	}
+	if _, found := rules["x"]; found {
+		t.Error("template with undefined variables expanded")
+	}
+
+	// the rules are expanded again when the variables change
+	writeFile(t, filepath.Join(dir, VariablesFile), `{"variables": {
+        "HOME_NET": "10.0.0.0/8",
+        "BROWSERS": ["/usr/bin/firefox", "/usr/bin/epiphany"],
+        "RESOLVER": "8.8.8.8"
+    }}`)
+	if err := l.loadTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	rules = l.GetAll()
+	if r := rules["allow-browsers-firefox"]; r == nil || r.Operator.List[1].Data != "10.0.0.0/8" {
+		t.Errorf("the template has not been expanded again: %v", r)
+	}
+	if _, found := rules["allow-browsers-epiphany"]; !found {
+		t.Error("the new values have not been expanded")
+	}
+	if r := rules["allow-browsers-chromium"]; r == nil || r.Template != "" {
+		t.Error("the rule saved to disk should not be deleted")
+	}
+
+	// the rules of the templates are not saved to disk
+	if err := l.Delete("allow-browsers-firefox"); err != nil {
+		t.Error("error deleting a rule of a template: ", err)
+	}
+
+	// invalid variables don't delete the rules expanded
+	writeFile(t, filepath.Join(dir, VariablesFile), `{"variables": `)
+	if err := l.loadTemplates(); err == nil {
+		t.Error("invalid variables should not be loaded")
+	}
+	if _, found := l.GetAll()["allow-browsers-epiphany"]; !found {
+		t.Error("the rules expanded should be kept if the variables are invalid")
+	}
+
+	// deleting the templates deletes its rules
+	os.RemoveAll(dir)
+	l.loadTemplates()
+	for name, r := range l.GetAll() {
+		if r.Template != "" {
+			t.Errorf("rule %s of a deleted template still loaded", name)
+		}
+	}
+}
+
+func TestRuleTemplatesLiveReload(t *testing.T) {
+	rulesDir := t.TempDir()
+	dir := filepath.Join(rulesDir, TemplatesDir)
+	os.Mkdir(dir, 0700)
+	writeFile(t, filepath.Join(dir, VariablesFile), `{"variables": {"RESOLVER": "8.8.8.8"}}`)
+	writeFile(t, filepath.Join(dir, "dns.json"), dnsTemplate)
+
+	l, err := NewLoader(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Load(rulesDir); err != nil {
+		t.Fatal(err)
+	}
+	// wait for the watcher to start
+	time.Sleep(500 * time.Millisecond)
+	writeFile(t, filepath.Join(dir, VariablesFile), `{"variables": {"RESOLVER": "1.1.1.1"}}`)
+	for i := 0; i < 30; i++ {
+		if _, found := l.GetAll()["deny-dns-1.1.1.1"]; found {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Errorf("the templates have not been expanded again after changing the variables: %v", l.GetAll())
+}
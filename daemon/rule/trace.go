@@ -0,0 +1,240 @@
+package rule
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+)
+
+// max number of connections that can be traced per request.
+var maxTraceCount = 100
+
+// TraceRequest selects the connections to trace: the next Count connections
+// of the process with the given PID and/or path.
+type TraceRequest struct {
+	ProcessPath string `json:"process_path"`
+	PID         int    `json:"pid"`
+	Count       int    `json:"count"`
+}
+
+// Trace holds how the rules were evaluated for a connection.
+// The rules are listed in the order they were evaluated. The evaluation stops
+// on the first Deny, Reject or Precedence rule that matches.
+type Trace struct {
+	Time        time.Time     `json:"time"`
+	Process     string        `json:"process"`
+	Destination string        `json:"destination"`
+	Protocol    string        `json:"protocol"`
+	Match       string        `json:"match,omitempty"`
+	Action      string        `json:"action,omitempty"`
+	Rules       []RuleTrace   `json:"rules"`
+	Duration    time.Duration `json:"duration"`
+	PID         int           `json:"pid"`
+	// score of the connection, when the rules are evaluated by score.
+	Score int32 `json:"score,omitempty"`
+}
+
+// RuleTrace holds the result of evaluating a rule.
+// If the rule didn't match, Failed is the operator that didn't match.
+type RuleTrace struct {
+	Failed   *OperatorTrace `json:"failed,omitempty"`
+	Name     string         `json:"name"`
+	Duration time.Duration  `json:"duration"`
+	Score    int32          `json:"score,omitempty"`
+	Matched  bool           `json:"matched"`
+}
+
+// OperatorTrace identifies an operator of a rule.
+type OperatorTrace struct {
+	Type      Type      `json:"type"`
+	Operand   Operand   `json:"operand"`
+	Data      string    `json:"data"`
+	Sensitive Sensitive `json:"sensitive"`
+}
+
+type traceRequest struct {
+	traces chan *Trace
+	TraceRequest
+	// connections pending to trace, and being traced.
+	pending  int
+	inflight int
+}
+
+// tracer keeps the requests of connections to trace.
+type tracer struct {
+	requests []*traceRequest
+	// number of requests, to not lock the mutex if there're none.
+	active atomic.Int32
+	mu     sync.Mutex
+}
+
+// TraceConnections starts tracing the evaluation of the rules for the
+// connections of the request.
+// The traces are delivered to the returned channel, which is closed when all
+// the connections requested have been traced, or the cancel function is called.
+func (l *Loader) TraceConnections(req TraceRequest) (<-chan *Trace, func(), error) {
+	if req.PID == 0 && req.ProcessPath == "" {
+		return nil, nil, fmt.Errorf("a process path or PID is required to trace connections")
+	}
+	if req.Count <= 0 {
+		req.Count = 1
+	}
+	if req.Count > maxTraceCount {
+		return nil, nil, fmt.Errorf("max number of connections to trace exceeded: %d > %d", req.Count, maxTraceCount)
+	}
+	tr := &traceRequest{
+		TraceRequest: req,
+		traces:       make(chan *Trace, req.Count),
+		pending:      req.Count,
+	}
+
+	t := &l.tracer
+	t.mu.Lock()
+	t.requests = append(t.requests, tr)
+	t.active.Store(int32(len(t.requests)))
+	t.mu.Unlock()
+
+	cancel := func() {
+		t.mu.Lock()
+		t.remove(tr)
+		t.mu.Unlock()
+	}
+	return tr.traces, cancel, nil
+}
+
+// remove deletes a request, closing its channel.
+// The caller must hold the lock.
+func (t *tracer) remove(tr *traceRequest) {
+	for i, r := range t.requests {
+		if r == tr {
+			t.requests = append(t.requests[:i], t.requests[i+1:]...)
+			close(tr.traces)
+			break
+		}
+	}
+	t.active.Store(int32(len(t.requests)))
+}
+
+// take returns the request that matches the connection, if any, and
+// decreases the number of connections pending to trace.
+func (t *tracer) take(con *conman.Connection) *traceRequest {
+	if t.active.Load() == 0 || con.Process == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tr := range t.requests {
+		if tr.pending <= 0 ||
+			(tr.PID != 0 && tr.PID != con.Process.ID) ||
+			(tr.ProcessPath != "" && tr.ProcessPath != con.Process.Path) {
+			continue
+		}
+		tr.pending--
+		tr.inflight++
+		return tr
+	}
+	return nil
+}
+
+// deliver sends a trace to the requester, and removes the request once all
+// the connections have been traced.
+func (t *tracer) deliver(tr *traceRequest, trace *Trace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// the request may have been cancelled meanwhile.
+	for _, r := range t.requests {
+		if r != tr {
+			continue
+		}
+		// never blocks, the channel has room for all the traces.
+		tr.traces <- trace
+		tr.inflight--
+		if tr.pending <= 0 && tr.inflight == 0 {
+			t.remove(tr)
+		}
+		return
+	}
+}
+
+// findFirstMatchTraced evaluates the rules like FindFirstMatch(), recording
+// the result of every rule.
+func (l *Loader) findFirstMatchTraced(rules []*Rule, con *conman.Connection, hasChecksums bool, tr *traceRequest) (match *Rule) {
+	trace := &Trace{
+		Time:        time.Now(),
+		PID:         con.Process.ID,
+		Process:     con.Process.Path,
+		Destination: fmt.Sprintf("%s:%d (%s)", con.DstIP, con.DstPort, con.DstHost),
+		Protocol:    con.Protocol,
+		Rules:       make([]RuleTrace, 0, len(rules)),
+	}
+	defer func() {
+		trace.Duration = time.Since(trace.Time)
+		if match != nil {
+			trace.Match = match.Name
+			trace.Action = string(match.Action)
+		}
+		l.tracer.deliver(tr, trace)
+	}()
+
+	scoringCfg := l.scoringConfig()
+	score := connScore{}
+	for _, rule := range rules {
+		if rule.Score != 0 && !scoringCfg.Enabled {
+			continue
+		}
+		start := time.Now()
+		matched, failed := rule.Operator.matchTraced(con, hasChecksums)
+		rt := RuleTrace{
+			Name:     rule.Name,
+			Matched:  matched,
+			Score:    rule.Score,
+			Duration: time.Since(start),
+		}
+		if failed != nil {
+			rt.Failed = &OperatorTrace{
+				Type:      failed.Type,
+				Operand:   failed.Operand,
+				Data:      failed.Data,
+				Sensitive: failed.Sensitive,
+			}
+		}
+		trace.Rules = append(trace.Rules, rt)
+
+		if matched && rule.Score != 0 {
+			score.add(rule)
+			continue
+		}
+		if matched {
+			match = rule
+			if rule.Action == Reject || rule.Action == Deny || rule.Precedence == true {
+				return rule
+			}
+		}
+	}
+	if scoringCfg.Enabled {
+		trace.Score = score.total
+		match = score.verdict(scoringCfg, match, con)
+	}
+
+	return match
+}
+
+// matchTraced evaluates the operator like Match(), and returns the operator
+// that didn't match, if any.
+func (o *Operator) matchTraced(con *conman.Connection, hasChecksums bool) (bool, *Operator) {
+	if o.Operand == OpList {
+		for i := 0; i < len(o.List); i++ {
+			if matched, failed := o.List[i].matchTraced(con, hasChecksums); !matched {
+				return false, failed
+			}
+		}
+		return true, nil
+	}
+	if o.Match(con, hasChecksums) {
+		return true, nil
+	}
+	return false, o
+}
@@ -0,0 +1,71 @@
+package rule
+
+import "testing"
+
+func TestTraceConnections(t *testing.T) {
+	l, err := NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Load(t.TempDir()); err != nil {
+		t.Fatal("Error loading rules path: ", err)
+	}
+
+	listOp, _ := NewOperator(List, false, OpList, "", []Operator{
+		{Type: Simple, Operand: OpProcessPath, Data: defaultProcPath},
+		{Type: Simple, Operand: OpDstPort, Data: "80"},
+	})
+	compileListOperators(&listOp.List, t)
+	allowOp, _ := NewOperator(Simple, false, OpDstHost, defaultDstHost, make([]Operator, 0))
+	for _, r := range []*Rule{
+		Create("000-deny-http", "", true, false, false, Deny, Always, listOp),
+		Create("001-allow-host", "", true, false, false, Allow, Always, allowOp),
+	} {
+		if err = l.Add(r, false); err != nil {
+			t.Fatal("Error adding rule: ", err)
+		}
+	}
+
+	if _, _, err := l.TraceConnections(TraceRequest{}); err == nil {
+		t.Error("a trace request without process should fail")
+	}
+	if _, _, err := l.TraceConnections(TraceRequest{PID: 1, Count: maxTraceCount + 1}); err == nil {
+		t.Error("a trace request exceeding the max count should fail")
+	}
+
+	// connections of other processes are not traced
+	other, _, _ := l.TraceConnections(TraceRequest{PID: conn.Process.ID + 1})
+	traces, _, err := l.TraceConnections(TraceRequest{ProcessPath: defaultProcPath, Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if match := l.FindFirstMatch(conn); match == nil || match.Name != "001-allow-host" {
+			t.Fatalf("unexpected rule matched: %v", match)
+		}
+	}
+
+	n := 0
+	for trace := range traces {
+		n++
+		if trace.PID != conn.Process.ID || trace.Match != "001-allow-host" || trace.Action != string(Allow) {
+			t.Errorf("unexpected trace: %+v", trace)
+		}
+		if len(trace.Rules) != 2 {
+			t.Fatalf("unexpected rules traced: %+v", trace.Rules)
+		}
+		deny := trace.Rules[0]
+		if deny.Matched || deny.Failed == nil || deny.Failed.Operand != OpDstPort || deny.Failed.Data != "80" {
+			t.Errorf("the operator that failed should have been dest.port: %+v, %+v", deny, deny.Failed)
+		}
+		if allow := trace.Rules[1]; !allow.Matched || allow.Failed != nil {
+			t.Errorf("unexpected trace of the rule matched: %+v", allow)
+		}
+	}
+	if n != 2 {
+		t.Errorf("expected 2 traces, got %d", n)
+	}
+	if len(other) != 0 {
+		t.Errorf("connections of other processes should not be traced: %d", len(other))
+	}
+}
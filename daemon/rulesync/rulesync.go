@@ -0,0 +1,429 @@
+// Package rulesync keeps the same rules on several trusted daemons, without a
+// central server.
+//
+// Every daemon serves over mutual TLS the rules of the groups (tags) being
+// synced, and pulls periodically the rules of its peers. The certificates of
+// the peers must be signed by the configured CA, and their names (CN or SAN)
+// must be one of the configured peers.
+//
+// Conflicts are resolved by the last writer: the rule updated last wins. If
+// both rules were updated at the same time, the one with the highest checksum
+// wins, so all the daemons pick the same one. Deleted rules are not synced, they
+// must be deleted on every daemon (or removed from the synced groups).
+package rulesync
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+var (
+	defaultInterval = 5 * time.Minute
+	requestTimeout  = 30 * time.Second
+	maxRulesSize    = int64(16 * 1024 * 1024)
+
+	rulesPath = "/v1/rules"
+)
+
+// Peer is a daemon to sync the rules with.
+type Peer struct {
+	// Name must match the CN or a SAN of the certificate of the peer.
+	Name string `json:"Name"`
+	// Address of the peer, host:port
+	Address string `json:"Address"`
+}
+
+// Config holds the configuration of the rules sync.
+type Config struct {
+	// Listen is the address where the rules are served to the peers
+	// (host:port). If it's empty, the rules are only pulled from the peers.
+	Listen string `json:"Listen"`
+
+	// Interval between syncs (5m by default).
+	Interval string `json:"Interval"`
+
+	// Certificate and key of the daemon, used as the server and client
+	// certificate, and the CA that signs the certificates of the peers.
+	CertFile string `json:"CertFile"`
+	KeyFile  string `json:"KeyFile"`
+	CAFile   string `json:"CAFile"`
+
+	Peers []Peer `json:"Peers"`
+
+	// Tags of the rules to sync. Only the rules with duration always are
+	// synced.
+	Tags []string `json:"Tags"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+// Syncer serves and pulls the rules.
+type Syncer struct {
+	rules     *rule.Loader
+	server    *http.Server
+	listener  net.Listener
+	clientTLS *tls.Config
+	stop      chan struct{}
+
+	cfg Config
+	sync.Mutex
+}
+
+// Default is the rules syncer of the daemon.
+var Default = New()
+
+// New returns a new syncer, disabled until it's configured.
+func New() *Syncer {
+	return &Syncer{}
+}
+
+// SetLoader sets the rules to sync.
+func (s *Syncer) SetLoader(rules *rule.Loader) {
+	s.Lock()
+	defer s.Unlock()
+	s.rules = rules
+}
+
+// SetConfig applies a new configuration, restarting the server and the syncs.
+func (s *Syncer) SetConfig(cfg Config) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.stopLocked()
+	s.cfg = cfg
+	if !cfg.Enabled {
+		return nil
+	}
+
+	interval := defaultInterval
+	if cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid sync interval '%s'", cfg.Interval)
+		}
+	}
+	if len(cfg.Tags) == 0 {
+		return fmt.Errorf("no rule groups (Tags) to sync")
+	}
+	for _, p := range cfg.Peers {
+		if p.Name == "" {
+			return fmt.Errorf("the name of the peer %s is empty", p.Address)
+		}
+	}
+	serverTLS, clientTLS, err := tlsConfigs(cfg)
+	if err != nil {
+		return err
+	}
+
+	s.clientTLS = clientTLS
+	if cfg.Listen != "" {
+		ln, err := tls.Listen("tcp", cfg.Listen, serverTLS)
+		if err != nil {
+			return fmt.Errorf("listening on %s: %s", cfg.Listen, err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc(rulesPath, s.serveRules)
+		s.listener = ln
+		s.server = &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: requestTimeout,
+		}
+		go func(srv *http.Server) {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Warning("[rulesync] server error: %s", err)
+			}
+		}(s.server)
+		log.Info("[rulesync] serving rules on %s", cfg.Listen)
+	}
+
+	s.stop = make(chan struct{})
+	go s.run(s.stop, interval)
+	log.Info("[rulesync] syncing %v with %d peers every %s", cfg.Tags, len(cfg.Peers), interval)
+	return nil
+}
+
+// Stop stops serving and pulling the rules.
+func (s *Syncer) Stop() {
+	s.Lock()
+	defer s.Unlock()
+	s.stopLocked()
+}
+
+func (s *Syncer) stopLocked() {
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	if s.server != nil {
+		s.server.Close()
+		s.server = nil
+		s.listener = nil
+	}
+	s.clientTLS = nil
+}
+
+func (s *Syncer) run(stop chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.Sync()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync pulls the rules from all the peers, and applies the changes.
+func (s *Syncer) Sync() {
+	s.Lock()
+	peers, clientTLS := s.cfg.Peers, s.clientTLS
+	s.Unlock()
+	if clientTLS == nil {
+		return
+	}
+
+	for _, p := range peers {
+		remote, err := pull(clientTLS, p)
+		if err != nil {
+			log.Warning("[rulesync] error syncing with %s (%s): %s", p.Name, p.Address, err)
+			continue
+		}
+		if n := s.Merge(remote); n > 0 {
+			log.Info("[rulesync] %d rules updated from %s", n, p.Name)
+		}
+	}
+}
+
+// pull requests the synced rules of a peer.
+func pull(clientTLS *tls.Config, p Peer) ([]*rule.Rule, error) {
+	// the certificate of the peer must be issued for its name, not for its
+	// address.
+	tlsCfg := clientTLS.Clone()
+	tlsCfg.ServerName = p.Name
+	transport := &http.Transport{TLSClientConfig: tlsCfg}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Timeout: requestTimeout, Transport: transport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+p.Address+rulesPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	var remote []*rule.Rule
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRulesSize)).Decode(&remote); err != nil {
+		return nil, fmt.Errorf("invalid rules: %s", err)
+	}
+	return remote, nil
+}
+
+// serveRules sends the synced rules to a peer.
+func (s *Syncer) serveRules(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.Lock()
+	peers := s.cfg.Peers
+	s.Unlock()
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 || !isPeer(req.TLS.PeerCertificates[0], peers) {
+		log.Warning("[rulesync] rules requested by an unknown peer: %s", req.RemoteAddr)
+		http.Error(w, "unknown peer", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Synced()); err != nil {
+		log.Debug("[rulesync] error sending rules to %s: %s", req.RemoteAddr, err)
+	}
+}
+
+// Synced returns the rules of the groups being synced.
+func (s *Syncer) Synced() []*rule.Rule {
+	s.Lock()
+	rules, tags := s.rules, s.cfg.Tags
+	s.Unlock()
+
+	synced := []*rule.Rule{}
+	if rules == nil {
+		return synced
+	}
+	for _, r := range rules.GetOrdered() {
+		if isSynced(r, tags) {
+			synced = append(synced, r)
+		}
+	}
+	return synced
+}
+
+// Merge applies the rules of a peer that are newer than the local ones.
+// It returns the number of rules added or replaced.
+func (s *Syncer) Merge(remote []*rule.Rule) int {
+	s.Lock()
+	rules, tags := s.rules, s.cfg.Tags
+	s.Unlock()
+	if rules == nil {
+		return 0
+	}
+
+	// the hooks of the peers would run here, with the privileges of the
+	// daemon.
+	rule.StripHooks(remote, rule.SourceSync)
+	loaded := rules.GetAll()
+	updated := 0
+	// all the rules of a peer are recorded in a single snapshot.
+	rules.Track(rule.SourceSync, "sync rules", func() error {
+		for _, r := range remote {
+			if r == nil || !isSynced(r, tags) {
+				continue
+			}
+			if err := rule.Validate(r); err != nil {
+				log.Warning("[rulesync] invalid rule %s: %s", r.Name, err)
+				continue
+			}
+			local, found := loaded[r.Name]
+			if found {
+				if !isSynced(local, tags) {
+					log.Debug("[rulesync] rule %s not synced locally, ignoring", r.Name)
+					continue
+				}
+				if !newer(r, local) {
+					continue
+				}
+			}
+			if err := rules.Replace(r, true); err != nil {
+				log.Warning("[rulesync] error applying rule %s: %s", r.Name, err)
+				continue
+			}
+			log.Debug("[rulesync] rule %s updated", r.Name)
+			updated++
+		}
+		return nil
+	})
+	return updated
+}
+
+// isSynced returns true if the rule is persisted and belongs to one of the
+// groups being synced.
+func isSynced(r *rule.Rule, tags []string) bool {
+	// the rules of the templates are expanded on every daemon.
+	if r.Duration != rule.Always || r.Template != "" {
+		return false
+	}
+	for _, t := range r.Tags {
+		if slices.Contains(tags, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// newer returns true if the remote rule must replace the local one.
+func newer(remote, local *rule.Rule) bool {
+	remoteSum, localSum := checksum(remote), checksum(local)
+	// saving a rule updates its date, so the same rule received back from
+	// the peer is not a conflict.
+	if remoteSum == localSum {
+		return false
+	}
+	remoteTime, _ := time.Parse(time.RFC3339, remote.Updated)
+	localTime, _ := time.Parse(time.RFC3339, local.Updated)
+	if !remoteTime.Equal(localTime) {
+		return remoteTime.After(localTime)
+	}
+	return remoteSum > localSum
+}
+
+// checksum returns the hash of the content of a rule, ignoring the dates.
+func checksum(r *rule.Rule) string {
+	raw, _ := json.Marshal(r)
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return ""
+	}
+	delete(fields, "created")
+	delete(fields, "updated")
+	// map keys are marshalled sorted.
+	raw, _ = json.Marshal(fields)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// isPeer returns true if the certificate belongs to one of the peers.
+func isPeer(cert *x509.Certificate, peers []Peer) bool {
+	for _, p := range peers {
+		if cert.VerifyHostname(p.Name) == nil || cert.Subject.CommonName == p.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsConfigs builds the TLS configuration of the server and of the client.
+// Both of them require the certificate of the other end to be signed by the CA.
+func tlsConfigs(cfg Config) (server *tls.Config, client *tls.Config, err error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.CAFile == "" {
+		return nil, nil, fmt.Errorf("the certificate, key and CA are mandatory")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading certificate: %s", err)
+	}
+	caPem, err := os.ReadFile(cfg.CAFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading CA certificate: %s", err)
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caPem) {
+		return nil, nil, fmt.Errorf("invalid CA certificate: %s", cfg.CAFile)
+	}
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    certPool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      certPool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return server, client, nil
+}
+
+// Addr returns the address the rules are served on, if any.
+func (s *Syncer) Addr() net.Addr {
+	s.Lock()
+	defer s.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
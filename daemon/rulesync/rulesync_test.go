@@ -0,0 +1,175 @@
+package rulesync
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// writeCert generates a certificate signed by the CA (or self-signed if ca is
+// nil), and saves it to dir/name.crt and dir/name.key
+func writeCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parent, signer := ca, caKey
+	if ca == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, signer = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func newDaemon(t *testing.T, dir, name, listen string, peers []Peer) (*Syncer, *rule.Loader) {
+	rules, err := rule.NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rules.Load(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	s := New()
+	s.SetLoader(rules)
+	err = s.SetConfig(Config{
+		Enabled:  true,
+		Listen:   listen,
+		Interval: "1h",
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+		Peers:    peers,
+		Tags:     []string{"shared"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Stop)
+	return s, rules
+}
+
+func addRule(t *testing.T, rules *rule.Loader, name, data string, duration rule.Duration, tags ...string) {
+	op, _ := rule.NewOperator(rule.Simple, false, rule.OpProcessPath, data, nil)
+	r := rule.Create(name, "", true, false, false, rule.Allow, duration, op)
+	r.Tags = tags
+	if err := rules.Replace(r, duration == rule.Always); err != nil {
+		t.Fatal("Error adding rule: ", err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil)
+	writeCert(t, dir, "laptop", ca, caKey)
+	writeCert(t, dir, "desktop", ca, caKey)
+	writeCert(t, dir, "intruder", ca, caKey)
+
+	laptopAddr, desktopAddr := freeAddr(t), freeAddr(t)
+	laptop, laptopRules := newDaemon(t, dir, "laptop", laptopAddr, []Peer{{Name: "desktop", Address: desktopAddr}})
+	desktop, desktopRules := newDaemon(t, dir, "desktop", desktopAddr, []Peer{{Name: "laptop", Address: laptopAddr}})
+
+	if laptop.Addr() == nil {
+		t.Fatal("the rules are not served")
+	}
+
+	addRule(t, laptopRules, "allow-firefox", "/usr/bin/firefox", rule.Always, "shared")
+	addRule(t, laptopRules, "allow-curl", "/usr/bin/curl", rule.Always, "other")
+	addRule(t, laptopRules, "allow-wget", "/usr/bin/wget", rule.Restart, "shared")
+
+	if synced := laptop.Synced(); len(synced) != 1 || synced[0].Name != "allow-firefox" {
+		t.Fatalf("unexpected rules synced: %v", synced)
+	}
+
+	desktop.Sync()
+	all := desktopRules.GetAll()
+	if len(all) != 1 || all["allow-firefox"] == nil {
+		t.Fatalf("the rules have not been synced: %v", all)
+	}
+
+	// the same rule received back is not applied again
+	if n := laptop.Merge(desktop.Synced()); n != 0 {
+		t.Errorf("%d rules updated, expected 0", n)
+	}
+
+	// the last change wins
+	time.Sleep(1100 * time.Millisecond)
+	addRule(t, desktopRules, "allow-firefox", "/opt/firefox/firefox", rule.Always, "shared")
+	laptop.Sync()
+	if r := laptopRules.GetAll()["allow-firefox"]; r == nil || r.Operator.Data != "/opt/firefox/firefox" {
+		t.Errorf("the newest rule has not been synced: %v", r)
+	}
+
+	// rules not synced locally are not overwritten
+	addRule(t, desktopRules, "allow-curl", "/opt/curl", rule.Always, "shared")
+	laptop.Sync()
+	if r := laptopRules.GetAll()["allow-curl"]; r.Operator.Data != "/usr/bin/curl" {
+		t.Errorf("a rule not synced has been overwritten: %v", r)
+	}
+
+	// the peers must be configured on both ends
+	intruder, intruderRules := newDaemon(t, dir, "intruder", "", []Peer{{Name: "laptop", Address: laptopAddr}})
+	intruder.Sync()
+	if n := len(intruderRules.GetAll()); n != 0 {
+		t.Errorf("rules sent to an unknown peer: %d", n)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	op, _ := rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/ssh", nil)
+	a := rule.Create("allow-ssh", "", true, false, false, rule.Allow, rule.Always, op)
+	a.Updated = "2024-01-01T10:00:00Z"
+	b := rule.Create("allow-ssh", "", true, false, false, rule.Deny, rule.Always, op)
+	b.Updated = "2024-01-01T10:00:00Z"
+	b.Created = "2023-01-01T10:00:00Z"
+
+	if newer(a, b) == newer(b, a) {
+		t.Error("conflicts with the same date must be resolved in the same way by both peers")
+	}
+	b.Updated = "2024-01-01T11:00:00Z"
+	if newer(a, b) || !newer(b, a) {
+		t.Error("the last change should win")
+	}
+	b.Action = rule.Allow
+	if newer(a, b) || newer(b, a) {
+		t.Error("the same rule should not be a conflict")
+	}
+}
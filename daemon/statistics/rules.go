@@ -0,0 +1,76 @@
+package statistics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// RuleStats holds the statistics of a rule since the daemon started.
+type RuleStats struct {
+	LastHit time.Time
+	Hits    uint64
+	// bytes and packets of the connections allowed.
+	Bytes     uint64
+	BytesSent uint64
+	BytesRecv uint64
+	Packets   uint64
+}
+
+// OnRuleHit increases the counter of connections matched by a rule.
+// The connections of rules with the option nolog are not added to the stats,
+// but the hits of these rules are counted anyway.
+func (s *Statistics) OnRuleHit(name string) {
+	s.Lock()
+	defer s.Unlock()
+	s.onRuleHit(name)
+}
+
+func (s *Statistics) onRuleHit(name string) {
+	rs, found := s.ByRule[name]
+	if !found {
+		rs = &RuleStats{}
+		s.ByRule[name] = rs
+	}
+	rs.Hits++
+	rs.LastHit = time.Now()
+}
+
+// SerializeRules returns the statistics of the loaded rules, sorted by name.
+// If unused is true, only the rules that haven't matched any connection are
+// returned.
+func (s *Statistics) SerializeRules(unused bool) []*protocol.RuleStats {
+	loaded := s.rules.GetAll()
+
+	s.Lock()
+	defer s.Unlock()
+	// forget the rules deleted
+	for name := range s.ByRule {
+		if _, found := loaded[name]; !found {
+			delete(s.ByRule, name)
+		}
+	}
+
+	serialized := make([]*protocol.RuleStats, 0, len(loaded))
+	for name := range loaded {
+		rs := &protocol.RuleStats{Name: name}
+		if st, found := s.ByRule[name]; found {
+			if unused {
+				continue
+			}
+			rs.Hits = st.Hits
+			rs.LastHit = st.LastHit.UnixNano()
+			rs.Bytes = st.Bytes
+			rs.Packets = st.Packets
+			rs.BytesSent = st.BytesSent
+			rs.BytesRecv = st.BytesRecv
+		}
+		serialized = append(serialized, rs)
+	}
+	sort.Slice(serialized, func(i, j int) bool {
+		return serialized[i].Name < serialized[j].Name
+	})
+
+	return serialized
+}
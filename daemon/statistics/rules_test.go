@@ -0,0 +1,71 @@
+package statistics
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+func TestRulesStats(t *testing.T) {
+	loader, err := rule.NewLoader(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"000-allow-dns", "001-deny-ads", "002-allow-curl"} {
+		op, err := rule.NewOperator(rule.Simple, false, rule.OpTrue, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := loader.Add(rule.Create(name, "", true, false, false, rule.Allow, rule.Always, op), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := New(loader)
+	s.OnRuleHit("000-allow-dns")
+	s.OnRuleHit("000-allow-dns")
+	s.OnRuleHit("002-allow-curl")
+	s.OnRuleHit("deleted-rule")
+
+	all := s.SerializeRules(false)
+	if len(all) != 3 {
+		t.Fatal("expected 3 rules, got:", len(all))
+	}
+	if all[0].Name != "000-allow-dns" || all[0].Hits != 2 || all[0].LastHit == 0 {
+		t.Error("invalid rule stats:", all[0])
+	}
+	if all[1].Hits != 0 || all[1].LastHit != 0 {
+		t.Error("unused rule with hits:", all[1])
+	}
+	if _, found := s.ByRule["deleted-rule"]; found {
+		t.Error("stats of deleted rules not removed")
+	}
+
+	unused := s.SerializeRules(true)
+	if len(unused) != 1 || unused[0].Name != "001-deny-ads" {
+		t.Error("invalid unused rules:", unused)
+	}
+
+	curl := &conman.Connection{Process: &procmon.Process{Path: "/usr/bin/curl"}}
+	for i := 0; i < 2; i++ {
+		s.OnFlowUsage(&conman.FlowUsage{Con: curl, Rule: "002-allow-curl", BytesSent: 100, BytesRecv: 1000, PacketsSent: 2, PacketsRecv: 3, Ended: i == 1})
+	}
+	if all = s.SerializeRules(false); all[2].Bytes != 2200 || all[2].Packets != 10 || all[2].BytesSent != 200 || all[2].BytesRecv != 2000 {
+		t.Error("bytes and packets of the connections not added to the rule:", all[2])
+	}
+	if s.BytesByExecutable["/usr/bin/curl"] != 2200 {
+		t.Error("bytes of the connections not added to the executable:", s.BytesByExecutable)
+	}
+
+	wget := &conman.Connection{Process: &procmon.Process{Path: "/usr/bin/wget"}}
+	s.OnFlowUsage(&conman.FlowUsage{Con: wget, BytesRecv: 5000, PacketsRecv: 5, Ended: true})
+	traffic := s.serializeTraffic()
+	if len(traffic) != 2 || traffic[0].Path != "/usr/bin/wget" || traffic[0].Connections != 1 {
+		t.Fatal("traffic by executable not sorted by bytes:", traffic)
+	}
+	if traffic[1].BytesSent != 200 || traffic[1].BytesRecv != 2000 || traffic[1].PacketsSent != 4 || traffic[1].PacketsRecv != 6 || traffic[1].Connections != 1 {
+		t.Error("invalid traffic of the executable:", traffic[1])
+	}
+}
@@ -2,14 +2,17 @@ package statistics
 
 import (
 	"context"
+	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/conman"
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/log/loggers"
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
 	"github.com/evilsocket/opensnitch/daemon/rule"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 )
@@ -19,8 +22,16 @@ type StatsConfig struct {
 	MaxEvents int `json:"MaxEvents"`
 	MaxStats  int `json:"MaxStats"`
 	Workers   int `json:"Workers"`
+
+	// QueueSize is the max number of connections waiting to be added to the
+	// stats, and QueuePolicy what to do when the queue is full: drop-oldest
+	// (default) or drop-newest.
+	QueueSize   int    `json:"QueueSize"`
+	QueuePolicy string `json:"QueuePolicy"`
 }
 
+var defaultQueueSize = 1024
+
 type conEvent struct {
 	con       *conman.Connection
 	match     *rule.Rule
@@ -41,9 +52,16 @@ type Statistics struct {
 	ByPort       map[string]uint64
 	ByHost       map[string]uint64
 	ByProto      map[string]uint64
-	jobs         chan conEvent
+	ByTag        map[string]uint64
+	ByRule       map[string]*RuleStats
+	jobs         atomic.Pointer[core.Queue[conEvent]]
 	Events       []*Event
 
+	// bytes sent and received by the connections allowed, by executable.
+	BytesByExecutable map[string]uint64
+	// bytes and packets of the connections allowed, by executable.
+	TrafficByExecutable map[string]*TrafficStats
+
 	RuleHits     int
 	Accepted     int
 	Ignored      int
@@ -56,10 +74,18 @@ type Statistics struct {
 	maxStats   int
 	maxWorkers int
 	Dropped    int
+	// events discarded by the queues that were replaced.
+	droppedJobs uint64
 
 	// flag to indicate if there're new events available
 	newEvents bool
 
+	// netfilter queues to report the counters of, by name.
+	queues map[string]uint16
+
+	// called with every event added.
+	onEvent func(e *Event)
+
 	sync.RWMutex
 }
 
@@ -77,12 +103,19 @@ func New(rules *rule.Loader) (stats *Statistics) {
 		ByPort:       make(map[string]uint64),
 		ByUID:        make(map[string]uint64),
 		ByExecutable: make(map[string]uint64),
+		ByTag:        make(map[string]uint64),
+		ByRule:       make(map[string]*RuleStats),
+		queues:       make(map[string]uint16),
+
+		BytesByExecutable:   make(map[string]uint64),
+		TrafficByExecutable: make(map[string]*TrafficStats),
 
 		rules:     rules,
-		jobs:      make(chan conEvent),
 		maxEvents: 150,
 		maxStats:  25,
 	}
+	jobs, _ := core.NewQueue[conEvent](defaultQueueSize, core.DropOldest)
+	stats.jobs.Store(jobs)
 
 	return stats
 }
@@ -94,6 +127,29 @@ func (s *Statistics) SetLoggers(loggermgr *loggers.LoggerManager) {
 	s.Unlock()
 }
 
+// OnEvent registers a function to call with every connection event added to
+// the stats. It's called with the stats locked, so it must not use them.
+func (s *Statistics) OnEvent(cb func(e *Event)) {
+	s.Lock()
+	defer s.Unlock()
+	s.onEvent = cb
+}
+
+// SetNewEvents makes the next call to Serialize return the stats, even if
+// there're no new events, e.g. for a GUI just connected.
+func (s *Statistics) SetNewEvents() {
+	s.Lock()
+	defer s.Unlock()
+	s.newEvents = true
+}
+
+// AddQueue adds a netfilter queue to report its counters (backlog, drops).
+func (s *Statistics) AddQueue(name string, num uint16) {
+	s.Lock()
+	s.queues[name] = num
+	s.Unlock()
+}
+
 // SetLimits configures the max events to keep in the backlog before sending
 // the stats to the UI, or while the UI is not connected.
 // if the backlog is full, it'll be shifted by one.
@@ -110,20 +166,37 @@ func (s *Statistics) SetLimits(config StatsConfig) {
 	if s.maxWorkers == 0 {
 		s.maxWorkers = 6
 	}
-	log.Info("Stats, max events: %d, max stats: %d, max workers: %d", s.maxStats, s.maxEvents, s.maxWorkers)
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	jobs, err := core.NewQueue[conEvent](queueSize, config.QueuePolicy)
+	if err != nil {
+		log.Warning("Stats, %s, using the default queue", err)
+		jobs, _ = core.NewQueue[conEvent](queueSize, core.DropOldest)
+	}
+	if old := s.jobs.Swap(jobs); old != nil {
+		atomic.AddUint64(&s.droppedJobs, old.Dropped())
+	}
+	log.Info("Stats, max events: %d, max stats: %d, max workers: %d, queue: %d (%s)",
+		s.maxStats, s.maxEvents, s.maxWorkers, jobs.Cap(), jobs.Policy())
 	for i := 0; i < s.maxWorkers; i++ {
-		go s.eventWorker(i, s.ctx.Done())
+		go s.eventWorker(i, jobs.C(), s.ctx.Done())
 	}
 
 }
 
-// OnConnectionEvent sends the details of a new connection throughout a channel,
-// in order to add the connection to the stats.
+// OnConnectionEvent queues the details of a new connection, in order to add
+// the connection to the stats.
+// It never blocks: if the workers can't keep up, events are discarded according
+// to the policy of the queue, and counted as dropped.
 func (s *Statistics) OnConnectionEvent(con *conman.Connection, match *rule.Rule, wasMissed bool) {
-	s.jobs <- conEvent{
+	if !s.jobs.Load().Push(conEvent{
 		con:       con,
 		match:     match,
 		wasMissed: wasMissed,
+	}) {
+		log.Trace("Stats, queue full, event dropped")
 	}
 	action := "<nil>"
 	rname := "<nil>"
@@ -132,6 +205,17 @@ func (s *Statistics) OnConnectionEvent(con *conman.Connection, match *rule.Rule,
 		rname = string(match.Name)
 	}
 
+	// the log directive of the rule can silence its connections, or write
+	// them also somewhere else.
+	if match != nil && match.Log != nil {
+		if match.Log.Silenced() {
+			return
+		}
+		pcon := con.Serialize()
+		s.logger.LogRule(match.Log, pcon, action, rname)
+		s.logger.Log(pcon, action, rname)
+		return
+	}
 	s.logger.Log(con.Serialize(), action, rname)
 }
 
@@ -152,12 +236,16 @@ func (s *Statistics) OnIgnored() {
 }
 
 func (s *Statistics) incMap(m *map[string]uint64, key string) {
+	s.addMap(m, key, 1)
+}
+
+func (s *Statistics) addMap(m *map[string]uint64, key string, n uint64) {
 	if val, found := (*m)[key]; found == false {
 		// do we have enough space left?
 		nElems := len(*m)
 		if nElems >= s.maxStats {
 			// find the element with less hits
-			nMin := uint64(9999999999)
+			nMin := uint64(math.MaxUint64)
 			minKey := ""
 			for k, v := range *m {
 				if v < nMin {
@@ -171,20 +259,20 @@ func (s *Statistics) incMap(m *map[string]uint64, key string) {
 			}
 		}
 
-		(*m)[key] = 1
+		(*m)[key] = n
 	} else {
-		(*m)[key] = val + 1
+		(*m)[key] = val + n
 	}
 }
 
-func (s *Statistics) eventWorker(id int, done <-chan struct{}) {
+func (s *Statistics) eventWorker(id int, jobs <-chan conEvent, done <-chan struct{}) {
 	log.Debug("Stats worker #%d started.", id)
 
 	for true {
 		select {
 		case <-done:
 			goto Exit
-		case job := <-s.jobs:
+		case job := <-jobs:
 			s.onConnection(job.con, job.match, job.wasMissed)
 		}
 	}
@@ -202,9 +290,10 @@ func (s *Statistics) onConnection(con *conman.Connection, match *rule.Rule, wasM
 		s.RuleMisses++
 	} else {
 		s.RuleHits++
+		s.onRuleHit(match.Name)
 	}
 
-	if wasMissed == false && match.Action == rule.Allow {
+	if wasMissed == false && match.Action.Allows() {
 		s.Accepted++
 	} else {
 		s.Dropped++
@@ -218,6 +307,9 @@ func (s *Statistics) onConnection(con *conman.Connection, match *rule.Rule, wasM
 	s.incMap(&s.ByPort, strconv.FormatUint(uint64(con.DstPort), 10))
 	s.incMap(&s.ByUID, strconv.Itoa(con.Entry.UserId))
 	s.incMap(&s.ByExecutable, con.Process.Path)
+	for _, tag := range con.Tags {
+		s.incMap(&s.ByTag, tag)
+	}
 
 	// if we reached the limit, shift everything back
 	// by one position
@@ -228,7 +320,11 @@ func (s *Statistics) onConnection(con *conman.Connection, match *rule.Rule, wasM
 	if wasMissed {
 		return
 	}
-	s.Events = append(s.Events, NewEvent(con, match))
+	ev := NewEvent(con, match)
+	s.Events = append(s.Events, ev)
+	if s.onEvent != nil {
+		s.onEvent(ev)
+	}
 
 	s.newEvents = true
 }
@@ -244,6 +340,47 @@ func (s *Statistics) serializeEvents() []*protocol.Event {
 	return serialized
 }
 
+func (s *Statistics) serializeQueues() []*protocol.QueueStats {
+	if len(s.queues) == 0 {
+		return nil
+	}
+	qStats, err := netfilter.GetQueuesStats()
+	if err != nil {
+		log.Debug("Stats, unable to get queues stats: %s", err)
+		return nil
+	}
+	serialized := make([]*protocol.QueueStats, 0, len(s.queues))
+	for name, num := range s.queues {
+		qs, found := qStats[num]
+		if !found {
+			continue
+		}
+		serialized = append(serialized, &protocol.QueueStats{
+			Name:        name,
+			Num:         uint32(num),
+			Total:       qs.Total,
+			Dropped:     qs.Dropped,
+			UserDropped: qs.UserDropped,
+			IdSequence:  qs.IDSequence,
+		})
+	}
+	return serialized
+}
+
+// droppedEvents returns the number of events discarded by every sink, because
+// they couldn't keep up with the connections intercepted.
+func (s *Statistics) droppedEvents() map[string]uint64 {
+	dropped := map[string]uint64{
+		"stats": atomic.LoadUint64(&s.droppedJobs) + s.jobs.Load().Dropped(),
+	}
+	if s.logger != nil {
+		for name, n := range s.logger.Dropped() {
+			dropped["logger:"+name] = n
+		}
+	}
+	return dropped
+}
+
 // emptyStats empties the stats once we've sent them to the GUI.
 // We don't need them anymore here.
 func (s *Statistics) emptyStats() {
@@ -285,5 +422,11 @@ func (s *Statistics) Serialize() *protocol.Statistics {
 		ByPort:        s.ByPort,
 		ByUid:         s.ByUID,
 		ByExecutable:  s.ByExecutable,
+		ByTag:         s.ByTag,
+		Queues:        s.serializeQueues(),
+		DroppedEvents: s.droppedEvents(),
+
+		BytesByExecutable:   s.BytesByExecutable,
+		TrafficByExecutable: s.serializeTraffic(),
 	}
 }
@@ -0,0 +1,98 @@
+package statistics
+
+import (
+	"sort"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// TrafficStats holds the bytes and packets of the connections allowed of an
+// executable.
+type TrafficStats struct {
+	BytesSent   uint64
+	BytesRecv   uint64
+	PacketsSent uint64
+	PacketsRecv uint64
+	// connections ended.
+	Connections uint64
+}
+
+// Bytes returns the bytes sent and received.
+func (t *TrafficStats) Bytes() uint64 {
+	return t.BytesSent + t.BytesRecv
+}
+
+// OnFlowUsage adds the bytes and packets carried by a connection to the stats
+// of the rule that allowed it, and of its executable.
+// It's called periodically while the connection is active, and when it ends.
+func (s *Statistics) OnFlowUsage(u *conman.FlowUsage) {
+	s.Lock()
+	defer s.Unlock()
+	bytes := u.BytesSent + u.BytesRecv
+	if rs, found := s.ByRule[u.Rule]; found {
+		rs.Bytes += bytes
+		rs.BytesSent += u.BytesSent
+		rs.BytesRecv += u.BytesRecv
+		rs.Packets += u.PacketsSent + u.PacketsRecv
+	}
+	if u.Con == nil || u.Con.Process == nil || (bytes == 0 && !u.Ended) {
+		return
+	}
+	path := u.Con.Process.Path
+	s.addMap(&s.BytesByExecutable, path, bytes)
+
+	ts, found := s.TrafficByExecutable[path]
+	if !found {
+		s.evictTraffic()
+		ts = &TrafficStats{}
+		s.TrafficByExecutable[path] = ts
+	}
+	ts.BytesSent += u.BytesSent
+	ts.BytesRecv += u.BytesRecv
+	ts.PacketsSent += u.PacketsSent
+	ts.PacketsRecv += u.PacketsRecv
+	if u.Ended {
+		ts.Connections++
+	}
+	s.newEvents = true
+}
+
+// evictTraffic deletes the executable with less traffic if the max number of
+// entries has been reached.
+func (s *Statistics) evictTraffic() {
+	if len(s.TrafficByExecutable) < s.maxStats {
+		return
+	}
+	minPath := ""
+	for path, ts := range s.TrafficByExecutable {
+		if minPath == "" || ts.Bytes() < s.TrafficByExecutable[minPath].Bytes() {
+			minPath = path
+		}
+	}
+	delete(s.TrafficByExecutable, minPath)
+}
+
+// serializeTraffic returns the traffic of the executables, sorted by the
+// bytes sent and received.
+func (s *Statistics) serializeTraffic() []*protocol.TrafficStats {
+	traffic := make([]*protocol.TrafficStats, 0, len(s.TrafficByExecutable))
+	for path, ts := range s.TrafficByExecutable {
+		traffic = append(traffic, &protocol.TrafficStats{
+			Path:        path,
+			BytesSent:   ts.BytesSent,
+			BytesRecv:   ts.BytesRecv,
+			PacketsSent: ts.PacketsSent,
+			PacketsRecv: ts.PacketsRecv,
+			Connections: ts.Connections,
+		})
+	}
+	sort.Slice(traffic, func(i, j int) bool {
+		bi, bj := traffic[i].BytesSent+traffic[i].BytesRecv, traffic[j].BytesSent+traffic[j].BytesRecv
+		if bi != bj {
+			return bi > bj
+		}
+		return traffic[i].Path < traffic[j].Path
+	})
+	return traffic
+}
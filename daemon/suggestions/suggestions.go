@@ -0,0 +1,297 @@
+// Package suggestions keeps the destinations the processes have connected to
+// lately, to suggest broader rules in the prompts of their connections:
+// a process that has connected to 14 subdomains of example.com this week is
+// suggested a rule for *.example.com, instead of one rule per host.
+package suggestions
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+var (
+	defaultWindow          = 7 * 24 * time.Hour
+	defaultMinDestinations = 3
+	defaultMaxProcesses    = 500
+	defaultMaxDestinations = 1000
+
+	timeNow = time.Now
+)
+
+// Config holds the configuration of the suggestions.
+type Config struct {
+	// Window is how long the destinations are remembered (168h by default).
+	Window string `json:"Window"`
+	// MinDestinations is the number of different hosts of a domain, or on a
+	// port, needed to suggest a rule for all of them (3 by default).
+	MinDestinations int `json:"MinDestinations"`
+	// MaxProcesses and MaxDestinations limit the processes remembered, and
+	// the destinations of each one (500 and 1000 by default).
+	MaxProcesses    int `json:"MaxProcesses"`
+	MaxDestinations int `json:"MaxDestinations"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+type destination struct {
+	host string
+	port uint
+}
+
+// process holds the destinations of a process, and when they were last seen.
+type process struct {
+	dsts     map[destination]time.Time
+	lastSeen time.Time
+}
+
+// History remembers the destinations of the processes.
+type History struct {
+	procs map[string]*process
+
+	window  time.Duration
+	minDsts int
+	maxProc int
+	maxDsts int
+	enabled bool
+	sync.Mutex
+}
+
+// Default is the history of the daemon.
+var Default = New()
+
+// New returns a new history, disabled until it's configured.
+func New() *History {
+	return &History{procs: make(map[string]*process)}
+}
+
+// SetConfig applies a new configuration. The destinations remembered are
+// kept, unless it's disabled.
+func (h *History) SetConfig(cfg Config) error {
+	window := defaultWindow
+	if cfg.Window != "" {
+		w, err := time.ParseDuration(cfg.Window)
+		if err != nil || w <= 0 {
+			return fmt.Errorf("invalid window: %s", cfg.Window)
+		}
+		window = w
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	h.enabled = cfg.Enabled
+	h.window = window
+	h.minDsts = cfg.MinDestinations
+	if h.minDsts <= 1 {
+		h.minDsts = defaultMinDestinations
+	}
+	h.maxProc = cfg.MaxProcesses
+	if h.maxProc <= 0 {
+		h.maxProc = defaultMaxProcesses
+	}
+	h.maxDsts = cfg.MaxDestinations
+	if h.maxDsts <= 0 {
+		h.maxDsts = defaultMaxDestinations
+	}
+	if !h.enabled {
+		h.procs = make(map[string]*process)
+	}
+	return nil
+}
+
+// Record adds the destination of a connection to the history of its process.
+func (h *History) Record(con *conman.Connection) {
+	if con == nil || con.Process == nil || con.Process.Path == "" {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	if !h.enabled {
+		return
+	}
+
+	now := timeNow()
+	p, found := h.procs[con.Process.Path]
+	if !found {
+		if len(h.procs) >= h.maxProc {
+			h.evictProcess()
+		}
+		p = &process{dsts: make(map[destination]time.Time)}
+		h.procs[con.Process.Path] = p
+	}
+	p.lastSeen = now
+
+	dst := destination{host: hostOf(con), port: con.DstPort}
+	if _, found := p.dsts[dst]; !found && len(p.dsts) >= h.maxDsts {
+		h.prune(p, now)
+		if len(p.dsts) >= h.maxDsts {
+			evictOldest(p.dsts)
+		}
+	}
+	p.dsts[dst] = now
+}
+
+// Suggest returns the rules suggested for a connection, from the destinations
+// its process has connected to within the window: the domains with several
+// hosts, from the most specific one, and the port if it's been used to
+// connect to several hosts.
+func (h *History) Suggest(con *conman.Connection) []*protocol.RuleSuggestion {
+	if con == nil || con.Process == nil {
+		return nil
+	}
+	h.Lock()
+	defer h.Unlock()
+	if !h.enabled {
+		return nil
+	}
+	p, found := h.procs[con.Process.Path]
+	if !found {
+		return nil
+	}
+	h.prune(p, timeNow())
+
+	path := con.Process.Path
+	window := formatWindow(h.window)
+	var list []*protocol.RuleSuggestion
+
+	lastCount := 0
+	for _, domain := range parentDomains(con.DstHost) {
+		count := countHosts(p.dsts, func(host string) bool {
+			return host == domain || strings.HasSuffix(host, "."+domain)
+		})
+		// a broader domain is only suggested if it has more hosts.
+		if count < h.minDsts || count <= lastCount {
+			continue
+		}
+		lastCount = count
+		list = append(list, &protocol.RuleSuggestion{
+			Description: i18n.New(i18n.SuggestDomain, "path", path, "count", count, "domain", domain, "window", window).Encode(),
+			Operator: processOperator(path, &protocol.Operator{
+				Type:    string(rule.Domain),
+				Operand: string(rule.OpDstHost),
+				Data:    rule.DomainAndSubdomainsPrefix + domain,
+			}),
+			Hits: uint32(count),
+		})
+	}
+
+	if con.DstPort != 0 {
+		count := 0
+		for dst := range p.dsts {
+			if dst.port == con.DstPort {
+				count++
+			}
+		}
+		if count >= h.minDsts {
+			port := strconv.FormatUint(uint64(con.DstPort), 10)
+			list = append(list, &protocol.RuleSuggestion{
+				Description: i18n.New(i18n.SuggestPort, "path", path, "count", count, "port", port, "window", window).Encode(),
+				Operator: processOperator(path, &protocol.Operator{
+					Type:    string(rule.Simple),
+					Operand: string(rule.OpDstPort),
+					Data:    port,
+				}),
+				Hits: uint32(count),
+			})
+		}
+	}
+
+	return list
+}
+
+// prune deletes the destinations not seen within the window.
+func (h *History) prune(p *process, now time.Time) {
+	for dst, seen := range p.dsts {
+		if now.Sub(seen) > h.window {
+			delete(p.dsts, dst)
+		}
+	}
+}
+
+// evictProcess deletes the process seen the longest time ago.
+func (h *History) evictProcess() {
+	oldest := ""
+	for path, p := range h.procs {
+		if oldest == "" || p.lastSeen.Before(h.procs[oldest].lastSeen) {
+			oldest = path
+		}
+	}
+	delete(h.procs, oldest)
+}
+
+func evictOldest(dsts map[destination]time.Time) {
+	var oldest destination
+	var oldestSeen time.Time
+	for dst, seen := range dsts {
+		if oldestSeen.IsZero() || seen.Before(oldestSeen) {
+			oldest, oldestSeen = dst, seen
+		}
+	}
+	delete(dsts, oldest)
+}
+
+// countHosts returns the number of different hosts that match.
+func countHosts(dsts map[destination]time.Time, match func(string) bool) int {
+	hosts := make(map[string]struct{})
+	for dst := range dsts {
+		if match(dst.host) {
+			hosts[dst.host] = struct{}{}
+		}
+	}
+	return len(hosts)
+}
+
+func hostOf(con *conman.Connection) string {
+	if con.DstHost != "" {
+		return strings.ToLower(strings.TrimSuffix(con.DstHost, "."))
+	}
+	return con.DstIP.String()
+}
+
+// parentDomains returns the parent domains of a host, from the most specific
+// one, excluding the top level domain:
+// www.api.example.com -> api.example.com, example.com
+func parentDomains(host string) []string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	labels := strings.Split(host, ".")
+	domains := make([]string, 0, len(labels))
+	for i := 1; i < len(labels)-1; i++ {
+		domains = append(domains, strings.Join(labels[i:], "."))
+	}
+	return domains
+}
+
+// processOperator returns the operator of the process and the destination
+// suggested.
+func processOperator(path string, dst *protocol.Operator) *protocol.Operator {
+	return &protocol.Operator{
+		Type:    string(rule.List),
+		Operand: string(rule.OpList),
+		List: []*protocol.Operator{
+			{Type: string(rule.Simple), Operand: string(rule.OpProcessPath), Data: path},
+			dst,
+		},
+	}
+}
+
+// formatWindow returns the window in days or hours, if possible: 7d, 12h.
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return d.String()
+}
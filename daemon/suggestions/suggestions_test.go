@@ -0,0 +1,140 @@
+package suggestions
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+func newTestConnection(path, host string, port uint) *conman.Connection {
+	p := procmon.NewProcessEmpty(1234, "curl")
+	p.Path = path
+	return &conman.Connection{
+		Process: p,
+		DstIP:   net.ParseIP("185.53.178.14"),
+		DstPort: port,
+		DstHost: host,
+	}
+}
+
+func setNow(t *testing.T, now time.Time) {
+	orig := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = orig })
+}
+
+func TestSuggestDomains(t *testing.T) {
+	now := time.Now()
+	setNow(t, now)
+
+	h := New()
+	if err := h.SetConfig(Config{Enabled: true}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	for _, host := range []string{"a.api.example.com", "b.api.example.com", "c.api.example.com", "www.example.com", "example.com"} {
+		h.Record(newTestConnection("/usr/bin/curl", host, 443))
+	}
+	h.Record(newTestConnection("/usr/bin/wget", "d.api.example.com", 80))
+
+	list := h.Suggest(newTestConnection("/usr/bin/curl", "e.api.example.com", 8443))
+	if len(list) != 2 {
+		t.Fatalf("unexpected suggestions: %v", list)
+	}
+	if list[0].Hits != 3 || list[1].Hits != 5 {
+		t.Errorf("unexpected hits: %d, %d", list[0].Hits, list[1].Hits)
+	}
+	m, ok := i18n.Decode(list[1].Description)
+	if !ok || m.ID != i18n.SuggestDomain || m.Params["domain"] != "example.com" || m.Params["window"] != "7d" {
+		t.Errorf("unexpected description: %s", list[1].Description)
+	}
+
+	op := list[1].Operator
+	if op.Operand != string(rule.OpList) || len(op.List) != 2 || op.List[0].Data != "/usr/bin/curl" {
+		t.Fatalf("unexpected operator: %v", op)
+	}
+	o, err := rule.NewOperator(rule.Type(op.List[1].Type), false, rule.Operand(op.List[1].Operand), op.List[1].Data, nil)
+	if err != nil {
+		t.Fatal("suggested operator error:", err)
+	}
+	if err := o.Compile(); err != nil {
+		t.Fatal("suggested operator compile error:", err)
+	}
+	for host, match := range map[string]bool{"example.com": true, "x.api.example.com": true, "badexample.com": false} {
+		con := newTestConnection("/usr/bin/curl", host, 443)
+		if o.Match(con, false) != match {
+			t.Errorf("%s: unexpected match, should be %v", host, match)
+		}
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		setNow(t, now.Add(8*24*time.Hour))
+		if list := h.Suggest(newTestConnection("/usr/bin/curl", "e.api.example.com", 8443)); len(list) != 0 {
+			t.Errorf("no suggestions expected: %v", list)
+		}
+	})
+}
+
+func TestSuggestPort(t *testing.T) {
+	h := New()
+	h.SetConfig(Config{Enabled: true, MinDestinations: 2})
+	h.Record(newTestConnection("/usr/bin/ssh", "host1", 22))
+	h.Record(newTestConnection("/usr/bin/ssh", "host2", 22))
+
+	list := h.Suggest(newTestConnection("/usr/bin/ssh", "host3", 22))
+	if len(list) != 1 || list[0].Hits != 2 {
+		t.Fatalf("unexpected suggestions: %v", list)
+	}
+	if dst := list[0].Operator.List[1]; dst.Operand != string(rule.OpDstPort) || dst.Data != "22" {
+		t.Errorf("unexpected operator: %v", dst)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	h := New()
+	h.SetConfig(Config{Enabled: true, MaxProcesses: 2, MaxDestinations: 2})
+
+	start := time.Now()
+	for i, path := range []string{"/bin/a", "/bin/b", "/bin/c"} {
+		setNow(t, start.Add(time.Duration(i)*time.Second))
+		h.Record(newTestConnection(path, "x.example.com", 443))
+	}
+	if len(h.procs) != 2 || h.procs["/bin/a"] != nil {
+		t.Errorf("the oldest process should have been evicted: %v", h.procs)
+	}
+
+	for i, host := range []string{"y.example.com", "z.example.com"} {
+		setNow(t, start.Add(time.Duration(10+i)*time.Second))
+		h.Record(newTestConnection("/bin/c", host, 443))
+	}
+	dsts := h.procs["/bin/c"].dsts
+	if len(dsts) != 2 {
+		t.Errorf("unexpected destinations: %v", dsts)
+	}
+	if _, found := dsts[destination{host: "x.example.com", port: 443}]; found {
+		t.Error("the oldest destination should have been evicted")
+	}
+
+	h.SetConfig(Config{Enabled: false})
+	h.Record(newTestConnection("/bin/d", "x.example.com", 443))
+	if len(h.procs) != 0 {
+		t.Errorf("nothing should be recorded when disabled: %v", h.procs)
+	}
+	if err := h.SetConfig(Config{Enabled: true, Window: "1x"}); err == nil {
+		t.Error("invalid window should fail")
+	}
+}
+
+func TestParentDomains(t *testing.T) {
+	got := parentDomains("www.api.Example.com.")
+	if len(got) != 2 || got[0] != "api.example.com" || got[1] != "example.com" {
+		t.Errorf("unexpected domains: %v", got)
+	}
+	if got := parentDomains("1.1.1.1"); len(got) != 0 {
+		t.Errorf("no domains expected for IPs: %v", got)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -32,6 +34,12 @@ func NewAlert(atype protocol.Alert_Type, what protocol.Alert_What, action protoc
 		Priority: prio,
 	}
 
+	// the messages are sent with their ID, and translated by the GUI.
+	if msg, ok := data.(i18n.Message); ok {
+		a.Data = &protocol.Alert_Text{Text: msg.Encode()}
+		return a
+	}
+
 	switch what {
 	case protocol.Alert_KERNEL_EVENT:
 
@@ -50,6 +58,14 @@ func NewAlert(atype protocol.Alert_Type, what protocol.Alert_What, action protoc
 		}
 	case protocol.Alert_GENERIC:
 		a.Data = &protocol.Alert_Text{data.(string)}
+	case protocol.Alert_RULE_SUGGESTION:
+		a.Data = &protocol.Alert_Rule{
+			Rule: data.(*rule.Rule).Serialize(),
+		}
+	case protocol.Alert_CONNECTION_CLOSED:
+		a.Data = &protocol.Alert_Flow{
+			Flow: data.(*conman.FlowEnd).Serialize(),
+		}
 	}
 
 	return a
@@ -115,6 +131,23 @@ func (c *Client) alertsDispatcher() {
 	}
 }
 
+// localize sends the English text of the messages to the GUIs that don't
+// translate them.
+func localize(a *protocol.Alert, localized bool) {
+	if localized {
+		return
+	}
+	if msg, ok := i18n.Decode(a.GetText()); ok {
+		a.Data = &protocol.Alert_Text{Text: msg.String()}
+	}
+}
+
+func (c *Client) localizedMessages() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.config.Server.LocalizedMessages
+}
+
 func (c *Client) dispatchAlert(pbAlert protocol.Alert) {
 	c.RLock()
 	isDisconnected := c.client == nil
@@ -122,6 +155,7 @@ func (c *Client) dispatchAlert(pbAlert protocol.Alert) {
 	if isDisconnected {
 		return
 	}
+	localize(&pbAlert, c.localizedMessages())
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	c.client.PostAlert(ctx, &pbAlert, grpc.UseCompressor(gzip.Name))
 	cancel()
@@ -1,9 +1,15 @@
 package auth
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
+	"os/user"
+	"strconv"
+	"strings"
 
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/ui/config"
@@ -38,11 +44,58 @@ const (
 
 // New returns the configuration that the UI will use
 // to connect with the server.
-func New(config *config.Config) (grpc.DialOption, error) {
+func New(config *config.Config) ([]grpc.DialOption, error) {
+	opts := []grpc.DialOption{}
+	if tokenFile := config.Server.Authentication.TokenFile; tokenFile != "" {
+		token, err := ReadToken(tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Debug("UI auth: using token %s", tokenFile)
+		opts = append(opts, tokenDialOptions(token)...)
+	}
+
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, transport), nil
+}
+
+// AllowedUIDs returns the UIDs allowed to listen on the unix socket of the GUI:
+// the ones configured, or root and the GUIUser. If none of them is configured,
+// any user is allowed.
+func AllowedUIDs(config *config.Config) ([]uint32, error) {
+	auth := config.Server.Authentication
+	if len(auth.AllowedUIDs) > 0 {
+		return auth.AllowedUIDs, nil
+	}
+	if auth.GUIUser == "" {
+		return nil, nil
+	}
+	allowed := []uint32{0}
+	if uid, err := strconv.ParseUint(auth.GUIUser, 10, 32); err == nil {
+		return append(allowed, uint32(uid)), nil
+	}
+	u, err := user.Lookup(auth.GUIUser)
+	if err != nil {
+		return nil, fmt.Errorf("UI auth: GUIUser %s: %s", auth.GUIUser, err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("UI auth: GUIUser %s: invalid UID %s", auth.GUIUser, u.Uid)
+	}
+	return append(allowed, uint32(uid)), nil
+}
+
+func newTransport(config *config.Config) (grpc.DialOption, error) {
 	credsType := config.Server.Authentication.Type
 	tlsOpts := config.Server.Authentication.TLSOptions
 
 	if credsType == "" || credsType == AuthSimple {
+		if len(tlsOpts.PinnedKeys) > 0 {
+			return nil, fmt.Errorf("UI auth: PinnedKeys needs a TLS authentication type, not %s", AuthSimple)
+		}
 		log.Debug("UI auth: simple")
 		return grpc.WithInsecure(), nil
 	}
@@ -76,6 +129,15 @@ func New(config *config.Config) (grpc.DialOption, error) {
 		InsecureSkipVerify: tlsOpts.SkipVerify,
 		RootCAs:            certPool,
 	}
+	if len(tlsOpts.PinnedKeys) > 0 {
+		verify, err := verifyPinnedKeys(tlsOpts.PinnedKeys)
+		if err != nil {
+			return nil, err
+		}
+		// called after the verification of the chain, or instead of it if
+		// SkipVerify is set.
+		tlsCfg.VerifyPeerCertificate = verify
+	}
 
 	// https://pkg.go.dev/google.golang.org/grpc/credentials#SecurityLevel
 	if credsType == AuthTLSMutual {
@@ -96,3 +158,31 @@ func New(config *config.Config) (grpc.DialOption, error) {
 		credentials.NewTLS(tlsCfg),
 	), nil
 }
+
+// verifyPinnedKeys returns a function that checks that the certificate of the
+// server has one of the public keys pinned (sha256 of the SubjectPublicKeyInfo).
+func verifyPinnedKeys(pins []string) (func([][]byte, [][]*x509.Certificate) error, error) {
+	pinned := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pin = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(pin), ":", ""))
+		if raw, err := hex.DecodeString(pin); err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("UI auth: invalid pinned key %s, it must be a sha256 in hex", pin)
+		}
+		pinned[pin] = true
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("UI auth: the server didn't present a certificate")
+		}
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("UI auth: invalid server certificate: %s", err)
+		}
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if !pinned[hex.EncodeToString(sum[:])] {
+			return fmt.Errorf("UI auth: the key of the server certificate (%s) is not pinned", cert.Subject)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTokenProof(t *testing.T) {
+	dir := t.TempDir()
+	short := filepath.Join(dir, "short")
+	ioutil.WriteFile(short, []byte("secret"), 0600)
+	if _, err := ReadToken(short); err == nil {
+		t.Error("short token accepted")
+	}
+	file := filepath.Join(dir, "token")
+	ioutil.WriteFile(file, []byte("a shared secret between the daemon and the GUI\n"), 0600)
+	token, err := ReadToken(file)
+	if err != nil {
+		t.Fatal("ReadToken() error:", err)
+	}
+
+	proof, err := tokenProof(token)
+	if err != nil {
+		t.Fatal("tokenProof() error:", err)
+	}
+	if err := NewTokenVerifier([]byte("another secret of the same size")).Verify(proof); err == nil {
+		t.Error("proof of another token accepted")
+	}
+	verifier := NewTokenVerifier(token)
+	last := "0"
+	if proof[len(proof)-1] == '0' {
+		last = "1"
+	}
+	if err := verifier.Verify(proof[:len(proof)-1] + last); err == nil {
+		t.Error("modified proof accepted")
+	}
+	if err := verifier.Verify(string(token)); err == nil {
+		t.Error("token accepted instead of a proof")
+	}
+	if err := verifier.Verify(proof); err != nil {
+		t.Error("valid proof rejected:", err)
+	}
+	if err := verifier.Verify(proof); err == nil {
+		t.Error("replayed proof accepted")
+	}
+
+	orig := timeNow
+	defer func() { timeNow = orig }()
+	timeNow = func() time.Time { return time.Now().Add(10 * time.Minute) }
+	if err := NewTokenVerifier(token).Verify(proof); err == nil {
+		t.Error("expired proof accepted")
+	}
+}
+
+func TestTokenReply(t *testing.T) {
+	token := []byte("a shared secret between the daemon and the GUI")
+	verifier := NewTokenVerifier(token)
+	// the server replies with the proof of the token it knows.
+	server := func(serverToken []byte) grpc.UnaryInvoker {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			proof := md.Get(TokenHeader)
+			if len(proof) == 0 || verifier.Verify(proof[0]) != nil {
+				return fmt.Errorf("request rejected: %v", md)
+			}
+			for _, opt := range opts {
+				if h, ok := opt.(grpc.HeaderCallOption); ok && serverToken != nil {
+					*h.HeaderAddr = metadata.Pairs(TokenReplyHeader, tokenReply(serverToken, proof[0]))
+				}
+			}
+			return nil
+		}
+	}
+	intercept := tokenUnaryInterceptor(token)
+	if err := intercept(context.Background(), "/protocol.UI/Ping", nil, nil, nil, server(token)); err != nil {
+		t.Error("server that knows the token rejected:", err)
+	}
+	if err := intercept(context.Background(), "/protocol.UI/Ping", nil, nil, nil, server(nil)); err == nil {
+		t.Error("server that doesn't reply accepted")
+	}
+	if err := intercept(context.Background(), "/protocol.UI/Ping", nil, nil, nil, server([]byte("another secret of the same size"))); err == nil {
+		t.Error("server with another token accepted")
+	}
+}
+
+func TestPinnedKeys(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "opensnitch-ui"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(raw)
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	pin := hex.EncodeToString(sum[:])
+
+	if _, err := verifyPinnedKeys([]string{"not-a-sha256"}); err == nil {
+		t.Error("invalid pin accepted")
+	}
+	verify, err := verifyPinnedKeys([]string{pin})
+	if err != nil {
+		t.Fatal("verifyPinnedKeys() error:", err)
+	}
+	if err := verify([][]byte{raw}, nil); err != nil {
+		t.Error("pinned certificate rejected:", err)
+	}
+	other, _ := verifyPinnedKeys([]string{hex.EncodeToString(make([]byte, sha256.Size))})
+	if err := other([][]byte{raw}, nil); err == nil {
+		t.Error("certificate not pinned accepted")
+	}
+
+	cfg := &config.Config{}
+	cfg.Server.Authentication.Type = AuthSimple
+	cfg.Server.Authentication.TLSOptions.PinnedKeys = []string{pin}
+	if _, err := New(cfg); err == nil {
+		t.Error("pinned keys accepted without TLS")
+	}
+}
+
+func TestCheckPeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "osui.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	uid := uint32(os.Getuid())
+	if err := CheckPeer(conn, nil); err != nil {
+		t.Error("peer rejected without UIDs configured:", err)
+	}
+	if err := CheckPeer(conn, []uint32{uid + 1, uid}); err != nil {
+		t.Error("allowed peer rejected:", err)
+	}
+	if err := CheckPeer(conn, []uint32{uid + 1}); err == nil {
+		t.Error("peer not allowed accepted")
+	}
+}
+
+func TestAllowedUIDs(t *testing.T) {
+	cfg := &config.Config{}
+	if uids, err := AllowedUIDs(cfg); err != nil || uids != nil {
+		t.Errorf("any user expected by default: %v, %v", uids, err)
+	}
+	cfg.Server.Authentication.GUIUser = "1000"
+	if uids, _ := AllowedUIDs(cfg); len(uids) != 2 || uids[1] != 1000 {
+		t.Errorf("GUIUser not allowed: %v", uids)
+	}
+	cfg.Server.Authentication.GUIUser = "root"
+	if uids, err := AllowedUIDs(cfg); err != nil || len(uids) != 2 || uids[1] != 0 {
+		t.Errorf("GUIUser name not resolved: %v, %v", uids, err)
+	}
+	cfg.Server.Authentication.GUIUser = "nonexistent-opensnitch-user"
+	if _, err := AllowedUIDs(cfg); err == nil {
+		t.Error("unknown GUIUser accepted")
+	}
+	cfg.Server.Authentication.AllowedUIDs = []uint32{1001}
+	if uids, err := AllowedUIDs(cfg); err != nil || len(uids) != 1 || uids[0] != 1001 {
+		t.Errorf("AllowedUIDs not used: %v, %v", uids, err)
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// PeerCredentials returns the credentials of the process at the other end of
+// a unix socket (SO_PEERCRED).
+func PeerCredentials(conn net.Conn) (*unix.Ucred, error) {
+	uconn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, fmt.Errorf("not a unix socket")
+	}
+	raw, err := uconn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
+
+// CheckPeer checks that the process at the other end of a unix socket runs as
+// one of the allowed UIDs. Other sockets, or an empty list, are always allowed.
+func CheckPeer(conn net.Conn, allowed []uint32) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	if _, ok := conn.(*net.UnixConn); !ok {
+		return nil
+	}
+	cred, err := PeerCredentials(conn)
+	if err != nil {
+		return fmt.Errorf("unable to get the credentials of the peer: %s", err)
+	}
+	for _, uid := range allowed {
+		if cred.Uid == uid {
+			return nil
+		}
+	}
+	return fmt.Errorf("peer not allowed: pid %d, uid %d", cred.Pid, cred.Uid)
+}
+
+// PeerListener accepts only the connections of processes running as one of the
+// allowed UIDs, on unix sockets.
+type PeerListener struct {
+	net.Listener
+	Allowed []uint32
+	// called with the connections rejected.
+	OnReject func(err error)
+}
+
+// Accept waits for the next connection allowed.
+func (l *PeerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := CheckPeer(conn, l.Allowed); err != nil {
+			conn.Close()
+			if l.OnReject != nil {
+				l.OnReject(err)
+			}
+			continue
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenHeader is the metadata of the requests with the proof that the client
+// knows the token: <unix time>.<nonce>.<HMAC-SHA256 of the time and nonce>.
+// The token itself is never sent, so a process impersonating the server
+// can't learn it.
+const TokenHeader = "x-opensnitch-auth"
+
+// TokenReplyHeader is the metadata of the responses with the proof that the
+// server knows the token too: the HMAC-SHA256 of "reply." and the proof of the
+// request. The client rejects the servers that don't send it.
+const TokenReplyHeader = "x-opensnitch-auth-reply"
+
+var (
+	// max difference between the time of the proof and the time of the
+	// server.
+	maxTokenSkew = 5 * time.Minute
+	minTokenSize = 16
+
+	timeNow = time.Now
+)
+
+// ReadToken reads a token shared between the daemon and the GUI. It must have
+// at least 16 bytes.
+func ReadToken(path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("UI auth token: %s", err)
+	}
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < minTokenSize {
+		return nil, fmt.Errorf("UI auth token %s: too short, at least %d bytes are needed", path, minTokenSize)
+	}
+	return raw, nil
+}
+
+func tokenMAC(token []byte, msg string) string {
+	mac := hmac.New(sha256.New, token)
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tokenProof returns a new proof of the token.
+func tokenProof(token []byte) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	msg := fmt.Sprint(timeNow().Unix(), ".", hex.EncodeToString(nonce))
+	return msg + "." + tokenMAC(token, msg), nil
+}
+
+// tokenReply returns the proof of the token of the server, for the proof of a
+// request.
+func tokenReply(token []byte, proof string) string {
+	return tokenMAC(token, "reply."+proof)
+}
+
+// verifyTokenReply checks the proof of the token sent by the server, in the
+// headers of the response.
+func verifyTokenReply(token []byte, proof string, header metadata.MD) error {
+	replies := header.Get(TokenReplyHeader)
+	if len(replies) == 0 {
+		return status.Error(codes.Unauthenticated, "the server didn't prove it knows the token")
+	}
+	if !hmac.Equal([]byte(replies[0]), []byte(tokenReply(token, proof))) {
+		return status.Error(codes.Unauthenticated, "invalid token of the server")
+	}
+	return nil
+}
+
+// TokenVerifier checks the proofs of the token sent by the clients.
+// Every proof is accepted only once, to reject the requests replayed by a
+// process that captured them.
+type TokenVerifier struct {
+	token []byte
+	// nonces of the proofs accepted, and their time.
+	seen map[string]time.Time
+	sync.Mutex
+}
+
+// NewTokenVerifier returns a new verifier of the proofs of a token.
+func NewTokenVerifier(token []byte) *TokenVerifier {
+	return &TokenVerifier{
+		token: token,
+		seen:  make(map[string]time.Time),
+	}
+}
+
+// Verify checks a proof of the token sent by a client.
+func (v *TokenVerifier) Verify(proof string) error {
+	i := strings.LastIndex(proof, ".")
+	if i < 0 {
+		return fmt.Errorf("invalid token proof")
+	}
+	msg, mac := proof[:i], proof[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(tokenMAC(v.token, msg))) {
+		return fmt.Errorf("invalid token")
+	}
+	fields := strings.SplitN(msg, ".", 2)
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid token proof")
+	}
+	ts, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid token proof")
+	}
+	now := timeNow()
+	if skew := now.Sub(time.Unix(ts, 0)); skew > maxTokenSkew || skew < -maxTokenSkew {
+		return fmt.Errorf("token proof expired, check the clock of the hosts")
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	// the proofs older than the skew are rejected anyway.
+	for nonce, t := range v.seen {
+		if now.Sub(t) > maxTokenSkew {
+			delete(v.seen, nonce)
+		}
+	}
+	if _, found := v.seen[fields[1]]; found {
+		return fmt.Errorf("token proof already used")
+	}
+	v.seen[fields[1]] = time.Unix(ts, 0)
+	return nil
+}
+
+// tokenDialOptions returns the options of a gRPC client to add a proof of the
+// token to every request, and to reject the responses of the servers that
+// don't prove they know it too.
+func tokenDialOptions(token []byte) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(tokenUnaryInterceptor(token)),
+		grpc.WithStreamInterceptor(tokenStreamInterceptor(token)),
+	}
+}
+
+func tokenUnaryInterceptor(token []byte) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		proof, err := tokenProof(token)
+		if err != nil {
+			return err
+		}
+		var header metadata.MD
+		ctx = metadata.AppendToOutgoingContext(ctx, TokenHeader, proof)
+		if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header))...); err != nil {
+			return err
+		}
+		return verifyTokenReply(token, proof, header)
+	}
+}
+
+func tokenStreamInterceptor(token []byte) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		proof, err := tokenProof(token)
+		if err != nil {
+			return nil, err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, TokenHeader, proof)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, err
+		}
+		// the server sends the headers when the stream is opened.
+		header, err := stream.Header()
+		if err == nil {
+			err = verifyTokenReply(token, proof, header)
+		}
+		if err != nil {
+			stream.CloseSend()
+			return nil, err
+		}
+		return stream, nil
+	}
+}
+
+// TokenServerOptions returns the options of a gRPC server to reject the
+// requests of the clients that don't prove they know the token, and to prove
+// to the clients that the server knows it too.
+func TokenServerOptions(token []byte) []grpc.ServerOption {
+	verifier := NewTokenVerifier(token)
+	check := func(ctx context.Context) (metadata.MD, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		proofs := md.Get(TokenHeader)
+		if len(proofs) == 0 {
+			return nil, status.Error(codes.Unauthenticated, "token required")
+		}
+		if err := verifier.Verify(proofs[0]); err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return metadata.Pairs(TokenReplyHeader, tokenReply(token, proofs[0])), nil
+	}
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			reply, err := check(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if err := grpc.SetHeader(ctx, reply); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			reply, err := check(ss.Context())
+			if err != nil {
+				return err
+			}
+			if err := ss.SendHeader(reply); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
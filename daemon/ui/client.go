@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/conman"
@@ -13,9 +14,11 @@ import (
 	"github.com/evilsocket/opensnitch/daemon/procmon"
 	"github.com/evilsocket/opensnitch/daemon/rule"
 	"github.com/evilsocket/opensnitch/daemon/statistics"
+	"github.com/evilsocket/opensnitch/daemon/suggestions"
 	"github.com/evilsocket/opensnitch/daemon/tasks"
 	"github.com/evilsocket/opensnitch/daemon/ui/auth"
 	"github.com/evilsocket/opensnitch/daemon/ui/config"
+	"github.com/evilsocket/opensnitch/daemon/ui/prompt"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
 
 	"github.com/fsnotify/fsnotify"
@@ -46,23 +49,40 @@ type Client struct {
 	clientCancel        context.CancelFunc
 	config              config.Config
 
-	loggers       *loggers.LoggerManager
-	stats         *statistics.Statistics
-	rules         *rule.Loader
-	con           *grpc.ClientConn
-	configWatcher *fsnotify.Watcher
+	loggers          *loggers.LoggerManager
+	stats            *statistics.Statistics
+	rules            *rule.Loader
+	con              *grpc.ClientConn
+	configWatcher    *fsnotify.Watcher
+	ttyPrompt        *prompt.Tty
+	promptPolicy     *prompt.Policies
+	protocolDefaults *prompt.ProtocolDefaults
+	originDefaults   *prompt.OriginDefaults
+	decisions        *prompt.Decisions
+	history          *history
 
 	alertsChan  chan protocol.Alert
+	stateEvents chan *protocol.StateEvent
 	isConnected chan bool
 
+	// logind session of the GUI, when it's connected through a unix socket.
+	uiSession atomic.Pointer[procmon.Session]
+
 	socketPath     string
 	unixSockPrefix string
 
+	// time the GUI connected, and whether the history of the events must be
+	// replayed to it with the next ping.
+	connectedAt   time.Time
+	replayHistory bool
+
 	//isAsking is set to true if the client is awaiting a decision from the GUI
 	isAsking     bool
 	isUnixSocket bool
 	isPolling    bool
 
+	// serializes the writes to the notifications stream.
+	sendLock sync.Mutex
 	sync.RWMutex
 }
 
@@ -79,12 +99,17 @@ func NewClient(socketPath, localConfigFile string, stats *statistics.Statistics,
 		isAsking:     false,
 		isConnected:  make(chan bool),
 		alertsChan:   make(chan protocol.Alert, maxQueuedAlerts),
+		stateEvents:  make(chan *protocol.StateEvent, maxQueuedEvents),
+		history:      newHistory(),
 	}
 	c.config.Rules.Path = rules.Path
+	stats.OnEvent(c.history.add)
 	//for i := 0; i < 4; i++ {
 	go c.alertsDispatcher()
 
 	c.clientCtx, c.clientCancel = context.WithCancel(context.Background())
+	go c.eventsDispatcher()
+	rules.OnChange(c.onRulesChange)
 
 	if watcher, err := fsnotify.NewWatcher(); err == nil {
 		c.configWatcher = watcher
@@ -95,6 +120,7 @@ func NewClient(socketPath, localConfigFile string, stats *statistics.Statistics,
 	}
 	procmon.EventsCache.SetComputeChecksums(c.config.Rules.EnableChecksums)
 	rules.EnableChecksums(c.config.Rules.EnableChecksums)
+	procmon.Packages.SetEnabled(c.config.Rules.VerifyPackages)
 
 	TaskMgr = tasks.NewTaskManager()
 	go c.monitorTaskManager(TaskMgr)
@@ -133,6 +159,20 @@ func (c *Client) InterceptUnknown() bool {
 	return c.config.InterceptUnknown
 }
 
+// MonitorMode returns true if the verdicts of the rules must not be enforced,
+// only reported.
+func (c *Client) MonitorMode() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.config.MonitorMode
+}
+
+// MonitorRule returns the rule reported for a connection not matched by any
+// rule in monitor mode, with the action that would have been applied to it.
+func (c *Client) MonitorRule(con *conman.Connection) *rule.Rule {
+	return rule.Create("ui.monitor.default", "", true, false, false, c.DefaultActionFor(con), rule.Once, dummyOperator)
+}
+
 // GetFirewallType returns the firewall to use
 func (c *Client) GetFirewallType() string {
 	c.RLock()
@@ -157,6 +197,53 @@ func (c *Client) DefaultAction() rule.Action {
 	return clientDisconnectedRule.Action
 }
 
+// DefaultActionFor returns the action to apply to a connection that hasn't
+// been answered, or that can't be prompted: the default action of its class
+// of protocols, the action of the prompt policy of its destination, or the
+// default action.
+func (c *Client) DefaultActionFor(con *conman.Connection) rule.Action {
+	if action, found := c.ProtocolDefault(con); found {
+		return action
+	}
+	if action, found := c.OriginDefault(con); found {
+		return action
+	}
+	if _, action, found := c.promptPolicyFor(con); found {
+		return action
+	}
+	return c.DefaultAction()
+}
+
+// ProtocolDefault returns the default action of the class of protocols of a
+// connection (multicast, udp, ...), and false if it's prompted as the rest of
+// connections.
+func (c *Client) ProtocolDefault(con *conman.Connection) (rule.Action, bool) {
+	c.RLock()
+	defaults := c.protocolDefaults
+	c.RUnlock()
+	return defaults.For(con)
+}
+
+// OriginDefault returns the default action of the origin of the binary of a
+// connection (packaged, modified, unpackaged), and false if it's prompted as
+// the rest of connections.
+func (c *Client) OriginDefault(con *conman.Connection) (rule.Action, bool) {
+	c.RLock()
+	defaults := c.originDefaults
+	c.RUnlock()
+	return defaults.For(con)
+}
+
+func (c *Client) promptPolicyFor(con *conman.Connection) (time.Duration, rule.Action, bool) {
+	if con == nil {
+		return 0, "", false
+	}
+	c.RLock()
+	policies := c.promptPolicy
+	c.RUnlock()
+	return policies.For(con.DstIP)
+}
+
 // DefaultDuration returns the default duration configured for a rule.
 // For example it can be: once, always, "until restart".
 func (c *Client) DefaultDuration() rule.Duration {
@@ -175,6 +262,17 @@ func (c *Client) Connected() bool {
 	return true
 }
 
+// CanAsk checks if the user can be asked about a connection, either from the
+// GUI or from a terminal.
+func (c *Client) CanAsk() bool {
+	if c.Connected() {
+		return true
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.ttyPrompt != nil
+}
+
 //GetIsAsking returns the isAsking flag
 func (c *Client) GetIsAsking() bool {
 	c.RLock()
@@ -235,6 +333,13 @@ Exit:
 func (c *Client) onStatusChange(connected bool) {
 	if connected {
 		log.Info("Connected to the UI service on %s", c.socketPath)
+		c.Lock()
+		c.connectedAt = time.Now()
+		c.replayHistory = true
+		c.Unlock()
+		// send the stats and the history right away, even if there're no
+		// new events.
+		c.stats.SetNewEvents()
 		go c.Subscribe()
 
 		select {
@@ -276,15 +381,31 @@ func (c *Client) openSocket() (err error) {
 	c.Lock()
 	defer c.Unlock()
 
-	dialOption, err := auth.New(&c.config)
+	dialOptions, err := auth.New(&c.config)
 	if err != nil {
 		return fmt.Errorf("Invalid client auth options: %s", err)
 	}
 	if c.isUnixSocket {
-		c.con, err = grpc.Dial(c.socketPath, dialOption,
+		var allowedUIDs []uint32
+		if allowedUIDs, err = auth.AllowedUIDs(&c.config); err != nil {
+			return err
+		}
+		c.con, err = grpc.Dial(c.socketPath, append(dialOptions,
 			grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
-				return net.DialTimeout(c.unixSockPrefix, addr, timeout)
-			}))
+				conn, err := net.DialTimeout(c.unixSockPrefix, addr, timeout)
+				if err != nil {
+					return nil, err
+				}
+				// a process listening on the socket instead of the GUI
+				// could push rules.
+				if err := auth.CheckPeer(conn, allowedUIDs); err != nil {
+					conn.Close()
+					log.Warning("UI socket %s rejected, %s (allowed: %v, see Server.Authentication.AllowedUIDs and GUIUser)", addr, err, allowedUIDs)
+					return nil, err
+				}
+				c.setUISession(conn)
+				return conn, nil
+			}))...)
 	} else {
 		// https://pkg.go.dev/google.golang.org/grpc/keepalive#ClientParameters
 		var kacp = keepalive.ClientParameters{
@@ -296,7 +417,7 @@ func (c *Client) openSocket() (err error) {
 			PermitWithoutStream: true,
 		}
 
-		c.con, err = grpc.Dial(c.socketPath, dialOption, grpc.WithKeepaliveParams(kacp))
+		c.con, err = grpc.Dial(c.socketPath, append(dialOptions, grpc.WithKeepaliveParams(kacp))...)
 	}
 
 	return err
@@ -316,6 +437,47 @@ func (c *Client) disconnect() {
 		log.Debug("client.disconnect()")
 	}
 	c.client = nil
+	c.uiSession.Store(nil)
+}
+
+// setUISession saves the session of the GUI, from the credentials of the
+// process listening on the unix socket.
+func (c *Client) setUISession(conn net.Conn) {
+	if _, ok := conn.(*net.UnixConn); !ok {
+		return
+	}
+	cred, err := auth.PeerCredentials(conn)
+	if err != nil {
+		log.Debug("unable to get the credentials of the GUI: %s", err)
+		return
+	}
+	session, err := procmon.GetSession(int(cred.Pid))
+	if err != nil {
+		log.Debug("unable to get the session of the GUI (pid %d): %s", cred.Pid, err)
+		c.uiSession.Store(nil)
+		return
+	}
+	log.Info("GUI running on session %s, user %s, seat %s", session.ID, session.User, session.Seat)
+	c.uiSession.Store(session)
+}
+
+// isSameSession checks if the process of a connection belongs to the same
+// user and seat as the GUI.
+// Processes without a session (system services), or connections asked to a
+// GUI with an unknown session, are always asked.
+func (c *Client) isSameSession(con *conman.Connection) bool {
+	uiSession := c.uiSession.Load()
+	if uiSession == nil {
+		return true
+	}
+	session, err := procmon.GetSession(con.Process.ID)
+	if err != nil {
+		return true
+	}
+	if session.UID != uiSession.UID {
+		return false
+	}
+	return session.Seat == "" || uiSession.Seat == "" || session.Seat == uiSession.Seat
 }
 
 func (c *Client) ping(ts time.Time) (err error) {
@@ -332,6 +494,10 @@ func (c *Client) ping(ts time.Time) (err error) {
 		return nil
 	}
 
+	if c.replayHistory {
+		serializedStats.Events = c.history.replay(serializedStats.Events, c.connectedAt, ts)
+	}
+
 	reqID := uint64(ts.UnixNano())
 	pReq := &protocol.PingRequest{
 		Id:    reqID,
@@ -351,6 +517,7 @@ func (c *Client) ping(ts time.Time) (err error) {
 	if pong.Id != reqID {
 		return fmt.Errorf("Expected pong with id 0x%x, got 0x%x", reqID, pong.Id)
 	}
+	c.replayHistory = false
 
 	return nil
 }
@@ -358,26 +525,110 @@ func (c *Client) ping(ts time.Time) (err error) {
 // Ask sends a request to the server, with the values of a connection to be
 // allowed or denied.
 func (c *Client) Ask(con *conman.Connection) *rule.Rule {
+	if !c.Connected() {
+		return c.askTty(con)
+	}
 	if c.client == nil {
 		return nil
 	}
+	c.RLock()
+	sameSession := c.config.Prompt.SameSession
+	c.RUnlock()
+	if sameSession && !c.isSameSession(con) {
+		log.Debug("not asking the GUI, the process %d belongs to another session: %s", con.Process.ID, con.Process.Path)
+		return c.askTty(con)
+	}
 
+	timeout := time.Second * 120
+	if tm, _, found := c.promptPolicyFor(con); found {
+		timeout = tm
+	}
 	// FIXME: if timeout is fired, the rule is not added to the list in the GUI
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*120)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	reply, err := c.client.AskRule(ctx, con.Serialize())
+	pc := con.Serialize()
+	pc.Suggestions = suggestions.Default.Suggest(con)
+	reply, err := c.client.AskRule(ctx, pc)
 	if err != nil {
 		log.Warning("Error while asking for rule: %s - %v", err, con)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = prompt.ErrTimeout
+		}
+		c.recordDecision(con, nil, prompt.PromptGUI, err)
 		return nil
 	}
 
 	r, err := rule.Deserialize(reply)
 	if err != nil {
+		c.recordDecision(con, nil, prompt.PromptGUI, err)
 		return nil
 	}
+	if r.Operator.Type == rule.PromptTemplateType {
+		tmpl := r.Operator.Data
+		if r, err = c.rules.ExpandPromptTemplate(tmpl, con, r.Action, r.Duration); err != nil {
+			log.Warning("Error expanding the prompt template %s: %s", tmpl, err)
+			c.recordDecision(con, nil, prompt.PromptGUI, err)
+			return nil
+		}
+	}
+	c.recordDecision(con, r, prompt.PromptGUI, nil)
 	return r
 }
 
+// askTty asks the user on the configured terminal, while the GUI is not
+// connected.
+func (c *Client) askTty(con *conman.Connection) *rule.Rule {
+	c.RLock()
+	tty := c.ttyPrompt
+	c.RUnlock()
+	if tty == nil {
+		return nil
+	}
+
+	timeout := tty.Timeout
+	if tm, _, found := c.promptPolicyFor(con); found {
+		timeout = tm
+	}
+	r, err := tty.AskWithin(timeout, con, c.rules.PromptTemplates()...)
+	if err != nil {
+		log.Warning("Error while asking for rule on %s: %s - %v", tty.Path, err, con)
+		c.recordDecision(con, nil, tty.Path, err)
+		return nil
+	}
+	c.recordDecision(con, r, tty.Path, nil)
+	return r
+}
+
+// recordDecision saves a connection prompted and the answer, or the default
+// action applied if it was not answered, to review it later.
+func (c *Client) recordDecision(con *conman.Connection, r *rule.Rule, where string, err error) {
+	c.RLock()
+	decisions := c.decisions
+	c.RUnlock()
+	if decisions == nil {
+		return
+	}
+
+	dec := &prompt.Decision{
+		Time:       time.Now(),
+		Connection: con.Serialize(),
+		Prompt:     where,
+		Outcome:    prompt.OutcomeAnswered,
+	}
+	switch {
+	case r != nil:
+		dec.Rule = r.Serialize()
+	case err == prompt.ErrTimeout:
+		dec.Outcome = prompt.OutcomeTimeout
+	default:
+		dec.Outcome = prompt.OutcomeError
+	}
+	if r == nil {
+		dec.DefaultAction = string(c.DefaultActionFor(con))
+	}
+	decisions.Record(dec)
+}
+
 // PostAlert queues a new message to be delivered to the server
 func (c *Client) PostAlert(atype protocol.Alert_Type, awhat protocol.Alert_What, action protocol.Alert_Action, prio protocol.Alert_Priority, data interface{}) {
 	if len(c.alertsChan) > maxQueuedAlerts-1 {
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"testing"
 	"time"
 
@@ -72,6 +73,12 @@ func validateConfig(t *testing.T, uiClient *Client, cfg *config.Config) {
 	if uiClient.DefaultAction() != rule.Action(cfg.DefaultAction) {
 		t.Errorf("not expected DefaultAction value: %s, expected: %s", clientDisconnectedRule.Action, cfg.DefaultAction)
 	}
+	if uiClient.MonitorMode() != cfg.MonitorMode {
+		t.Errorf("not expected MonitorMode value: %v, expected: %v", uiClient.MonitorMode(), cfg.MonitorMode)
+	}
+	if r := uiClient.MonitorRule(nil); r.Action != uiClient.DefaultAction() || !r.Enabled {
+		t.Errorf("not expected monitor rule: %s, %v, expected: %s", r.Action, r.Enabled, uiClient.DefaultAction())
+	}
 	if uiClient.DefaultDuration() != rule.Duration(cfg.DefaultDuration) {
 		t.Errorf("not expected DefaultDuration value: %s, expected: %s", clientDisconnectedRule.Duration, cfg.DefaultDuration)
 	}
@@ -144,6 +151,7 @@ func TestClientReloadingConfig(t *testing.T) {
 		//reloadConfig.ProcMonitorMethod = procmon.MethodProc
 		reloadConfig.DefaultAction = string(rule.Deny)
 		reloadConfig.InterceptUnknown = true
+		reloadConfig.MonitorMode = true
 		reloadConfig.Firewall = iptables.Name
 		reloadConfig.FwOptions.QueueBypass = true
 		reloadConfig.Server.Address = "unix:///run/user/1000/opensnitch/osui.sock"
@@ -159,6 +167,16 @@ func TestClientReloadingConfig(t *testing.T) {
 		time.Sleep(time.Second * 10)
 
 		validateConfig(t, uiClient, &reloadConfig)
+
+		t.Run("validate-invalid-reload", func(t *testing.T) {
+			if err := ioutil.WriteFile(configFile, []byte("{\"DefaultAction\": "), 0600); err != nil {
+				t.Errorf("error saving config to disk: %s", err)
+			}
+			if _, err := uiClient.ReloadConfiguration(); err == nil {
+				t.Error("invalid configuration reloaded")
+			}
+			validateConfig(t, uiClient, &reloadConfig)
+		})
 	})
 }
 
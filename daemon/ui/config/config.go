@@ -3,15 +3,32 @@ package config
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"reflect"
 
+	"github.com/evilsocket/opensnitch/daemon/alerts"
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/denypage"
+	"github.com/evilsocket/opensnitch/daemon/dns"
+	"github.com/evilsocket/opensnitch/daemon/forwarder"
+	"github.com/evilsocket/opensnitch/daemon/geoip"
+	"github.com/evilsocket/opensnitch/daemon/listeners"
 	"github.com/evilsocket/opensnitch/daemon/log"
 	"github.com/evilsocket/opensnitch/daemon/log/loggers"
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
+	"github.com/evilsocket/opensnitch/daemon/netns"
+	"github.com/evilsocket/opensnitch/daemon/pcap"
+	"github.com/evilsocket/opensnitch/daemon/policyaudit"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
 	"github.com/evilsocket/opensnitch/daemon/procmon/audit"
 	"github.com/evilsocket/opensnitch/daemon/procmon/ebpf"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/rulesync"
 	"github.com/evilsocket/opensnitch/daemon/statistics"
+	"github.com/evilsocket/opensnitch/daemon/suggestions"
+	"github.com/evilsocket/opensnitch/daemon/ui/prompt"
+	"github.com/evilsocket/opensnitch/daemon/updater"
 )
 
 type (
@@ -26,6 +43,10 @@ type (
 		ClientAuthType string `json:"ClientAuthType"`
 		// https://pkg.go.dev/crypto/tls#Config
 		SkipVerify bool `json:"SkipVerify"`
+		// SHA-256 (hex) of the public keys (SubjectPublicKeyInfo, DER) of
+		// the certificates the GUI is allowed to present. If not empty, any
+		// other certificate is rejected, even with SkipVerify.
+		PinnedKeys []string `json:"PinnedKeys"`
 		// https://pkg.go.dev/crypto/tls#Conn.VerifyHostname
 		// VerifyHostname bool
 		// https://pkg.go.dev/crypto/tls#example-Config-VerifyConnection
@@ -38,6 +59,19 @@ type (
 		// token?, google?, simple-tls, mutual-tls
 		Type       string           `json:"Type"`
 		TLSOptions ServerTLSOptions `json:"TLSOptions"`
+		// UIDs allowed to listen on the unix socket of the GUI, checked
+		// with SO_PEERCRED when connecting. Empty to allow root and
+		// GUIUser, or any user if GUIUser is not set either.
+		AllowedUIDs []uint32 `json:"AllowedUIDs"`
+		// User running the GUI (name or UID), allowed to listen on the
+		// unix socket with root if AllowedUIDs is empty.
+		GUIUser string `json:"GUIUser"`
+		// File with a secret shared with the GUI. Every request proves that
+		// the daemon knows it, without sending it, so the GUI can reject
+		// the clients that don't, and every response proves that the GUI
+		// knows it too. Only for GUIs that support it: opensnitch-ui
+		// --token-file, or opensnitch-cli -token-file.
+		TokenFile string `json:"TokenFile"`
 	}
 
 	// ServerConfig struct
@@ -46,12 +80,80 @@ type (
 		Authentication ServerAuth             `json:"Authentication"`
 		LogFile        string                 `json:"LogFile"`
 		Loggers        []loggers.LoggerConfig `json:"Loggers"`
+		AuditLog       loggers.AuditConfig    `json:"AuditLog"`
+		// Send the messages of the alerts as JSON, with the ID of the
+		// message and its parameters, so the GUI can translate them.
+		// Only for GUIs that support it, the rest display the JSON.
+		LocalizedMessages bool `json:"LocalizedMessages"`
+		// Connection events replayed to the GUI when it connects, marked
+		// as historical.
+		History HistoryOptions `json:"History"`
+	}
+
+	// HistoryOptions struct
+	HistoryOptions struct {
+		// Number of events kept. 0 to disable it.
+		MaxEvents int `json:"MaxEvents"`
+		// How long the events are kept (e.g. 10m). Empty to keep them
+		// until they're replaced by newer ones.
+		MaxAge string `json:"MaxAge"`
 	}
 
 	// RulesOptions struct
 	RulesOptions struct {
 		Path            string `json:"Path"`
 		EnableChecksums bool   `json:"EnableChecksums"`
+		// ChecksumBackend is the implementation used to compute the checksums
+		// of the binaries: go (default) or af_alg.
+		ChecksumBackend string `json:"ChecksumBackend"`
+		// ChecksumQuarantine is for how long the connections of a binary are
+		// denied when the only reason why its rule doesn't match them is the
+		// checksum (e.g. 1h). If it's empty, the default action applies.
+		ChecksumQuarantine string `json:"ChecksumQuarantine"`
+		// Scoring evaluates the rules with a score, to decide the verdict of
+		// the connections by thresholds.
+		Scoring rule.ScoringConfig `json:"Scoring"`
+		// Verify the binaries against the dpkg or rpm databases, to use the
+		// operand process.package.status.
+		VerifyPackages bool `json:"VerifyPackages"`
+		// Read the shared libraries mapped by the processes
+		// (/proc/<pid>/maps), to use the operand process.library.path.
+		CaptureLibraries bool `json:"CaptureLibraries"`
+		// ed25519 private key (PEM) to sign the exported bundles.
+		BundleSigningKey string `json:"BundleSigningKey"`
+		// ed25519 public keys (PEM) allowed to sign the imported bundles.
+		// If empty, unsigned bundles are accepted.
+		BundleTrustedKeys []string `json:"BundleTrustedKeys"`
+		// ed25519 public keys (PEM) allowed to sign the lists of the rules
+		// (<list>.sig). If not empty, only signed lists are loaded.
+		ListsTrustedKeys []string `json:"ListsTrustedKeys"`
+		// Cache of the verdicts of the connections of a process to the same
+		// destination.
+		VerdictCache conman.VerdictCacheConfig `json:"VerdictCache"`
+		// Table of the UDP flows already verdicted.
+		UDPFlows conman.FlowTableConfig `json:"UDPFlows"`
+		// Table of the TCP connections denied, to drop the retransmissions
+		// of their SYN packets without evaluating them again.
+		Retransmissions conman.RetransmitTableConfig `json:"Retransmissions"`
+		// Table of the connections allowed, to account the bytes and packets
+		// they carry when they end.
+		Accounting conman.AccountingTableConfig `json:"Accounting"`
+		// Ports of the local proxies, whose connections are attributed to
+		// the apps that connected to them (operand process.origin.path).
+		LocalProxies conman.LocalProxiesConfig `json:"LocalProxies"`
+		// Apply the rules with IPv4 networks (10.0.0.0/8, LAN, ...) to the
+		// IPv6 networks of the same scope (fc00::/7, ...) too.
+		DualStack bool `json:"DualStack"`
+		// Keep the hooks of the rules imported, synced from other hosts or
+		// restored from bundles. They're removed by default.
+		AllowImportedHooks bool `json:"AllowImportedHooks"`
+		// Reject the patterns of the rules of type domain that cover a
+		// public suffix (*.com, +.github.io), which would match the domains
+		// of unrelated owners.
+		RejectPublicSuffixes bool `json:"RejectPublicSuffixes"`
+		// Number of snapshots of the rules kept, to roll back the changes.
+		// 0 disables them.
+		MaxSnapshots int `json:"MaxSnapshots"`
 	}
 
 	// FwOptions struct
@@ -61,6 +163,48 @@ type (
 		MonitorInterval string `json:"MonitorInterval"`
 		QueueNum        uint16 `json:"QueueNum"`
 		QueueBypass     bool   `json:"QueueBypass"`
+		// Number of queues, from QueueNum, where the connections are
+		// balanced to. Every queue is read by its own goroutine.
+		// Changing it requires to restart the daemon.
+		QueueCount uint16 `json:"QueueCount"`
+		// Queue for the DNS responses, in order not to be delayed by the
+		// connections packets. 0 to use QueueNum.
+		// It can't be QueueNum + 1, which is used to repeat packets.
+		DNSQueueNum    uint16 `json:"DNSQueueNum"`
+		DNSQueueBypass bool   `json:"DNSQueueBypass"`
+		// Checks that the queued packets are being processed, and what to do
+		// if they're not.
+		QueueWatchdog netfilter.WatchdogConfig `json:"QueueWatchdog"`
+		// Destinations allowed in panic mode (IPs or networks in CIDR
+		// notation), besides the loopback interface and the UI server.
+		// Applied the next time the panic mode is enabled.
+		PanicAllow []string `json:"PanicAllow"`
+		// Intercepts the connections inside the network namespaces of
+		// the containers, instead of only the host side of their flows.
+		Namespaces netns.Config `json:"Namespaces"`
+	}
+
+	// PromptOptions struct
+	PromptOptions struct {
+		// Terminal where the connections prompts are displayed while the GUI
+		// is not connected, i.e.: /dev/tty12. Empty to disable it.
+		Tty string `json:"Tty"`
+		// Time to wait for an answer before applying the default action.
+		Timeout string `json:"Timeout"`
+		// Only send prompts to the GUI if the process belongs to the same
+		// logind session (user and seat) as the GUI. Otherwise the Tty is
+		// used, or the default action is applied.
+		// It only applies to GUIs listening on unix sockets.
+		SameSession bool `json:"SameSession"`
+		// Timeout and action of the prompts of the connections to classes
+		// of destinations (LAN, ...), evaluated in order. They also apply
+		// when the connections can't be prompted.
+		Policies []prompt.Policy `json:"Policies"`
+		// File where the connections prompted and their answers are saved,
+		// to review them later. Empty to keep them only in memory.
+		DecisionsLog string `json:"DecisionsLog"`
+		// Number of decisions to keep, 1000 by default.
+		MaxDecisions int `json:"MaxDecisions"`
 	}
 
 	TasksOptions struct {
@@ -76,23 +220,54 @@ type (
 
 // Config holds the values loaded from configFile
 type Config struct {
-	LogLevel          *int32                 `json:"LogLevel"`
-	Firewall          string                 `json:"Firewall"`
-	DefaultAction     string                 `json:"DefaultAction"`
-	DefaultDuration   string                 `json:"DefaultDuration"`
-	ProcMonitorMethod string                 `json:"ProcMonitorMethod"`
-	FwOptions         FwOptions              `json:"FwOptions"`
-	Audit             audit.Config           `json:"Audit"`
-	Ebpf              ebpf.Config            `json:"Ebpf"`
-	Server            ServerConfig           `json:"Server"`
-	Rules             RulesOptions           `json:"Rules"`
-	Internal          InternalOptions        `json:"Internal"`
-	Stats             statistics.StatsConfig `json:"Stats"`
-	TasksOptions      TasksOptions           `json:"Tasks"`
+	LogLevel          *int32                    `json:"LogLevel"`
+	Firewall          string                    `json:"Firewall"`
+	DefaultAction     string                    `json:"DefaultAction"`
+	DefaultDuration   string                    `json:"DefaultDuration"`
+	ProcMonitorMethod string                    `json:"ProcMonitorMethod"`
+	FwOptions         FwOptions                 `json:"FwOptions"`
+	Audit             audit.Config              `json:"Audit"`
+	Ebpf              ebpf.Config               `json:"Ebpf"`
+	EventsCache       procmon.EventsCacheConfig `json:"EventsCache"`
+	ProcessEnv        procmon.EnvConfig         `json:"ProcessEnv"`
+	Server            ServerConfig              `json:"Server"`
+	Rules             RulesOptions              `json:"Rules"`
+	Internal          InternalOptions           `json:"Internal"`
+	Stats             statistics.StatsConfig    `json:"Stats"`
+	TasksOptions      TasksOptions              `json:"Tasks"`
+	Prompt            PromptOptions             `json:"Prompt"`
+	Pcap              pcap.Config               `json:"Pcap"`
+	Alerts            alerts.Config             `json:"Alerts"`
+	GeoIP             geoip.Config              `json:"GeoIP"`
+	DNS               dns.Config                `json:"DNS"`
+	PolicyAudit       policyaudit.Config        `json:"PolicyAudit"`
+	RuleSync          rulesync.Config           `json:"RuleSync"`
+	Listeners         listeners.Config          `json:"Listeners"`
+	DenyPage          denypage.Config           `json:"DenyPage"`
+	LogLimits         log.Limits                `json:"LogLimits"`
+	Suggestions       suggestions.Config        `json:"Suggestions"`
+	Forwarder         forwarder.Config          `json:"Forwarder"`
+	Updater           updater.Config            `json:"Updater"`
 
 	InterceptUnknown bool `json:"InterceptUnknown"`
 	LogUTC           bool `json:"LogUTC"`
 	LogMicro         bool `json:"LogMicro"`
+	// Observe and report the connections with the verdicts of the rules,
+	// without enforcing them: nothing is dropped, rejected or prompted.
+	MonitorMode bool `json:"MonitorMode"`
+
+	// Default actions of the classes of protocols (tcp, udp, icmp, igmp,
+	// multicast, broadcast): allow, deny, reject, or ask to prompt them as
+	// the rest of connections. The connections of the classes with an action
+	// other than ask are not prompted.
+	ProtocolDefaults map[string]string `json:"ProtocolDefaults"`
+
+	// Default actions of the connections by the origin of their binaries
+	// (packaged, modified, unpackaged), verified against the package
+	// manager (Rules.VerifyPackages): allow, deny, reject, or ask. For
+	// example, {"packaged": "allow"} prompts only for the binaries not
+	// installed by a package, or modified.
+	OriginDefaults map[string]string `json:"OriginDefaults"`
 }
 
 // Parse determines if the given configuration is ok.
@@ -111,7 +286,7 @@ func Marshal(conf Config) ([]byte, error) {
 
 // Load loads the content of a file from disk.
 func Load(configFile string) ([]byte, error) {
-	raw, err := ioutil.ReadFile(configFile)
+	raw, err := core.ReadSealedFile(configFile)
 	if err != nil || len(raw) == 0 {
 		return nil, err
 	}
@@ -128,7 +303,7 @@ func Save(configFile, rawConfig string) (err error) {
 	if err = os.Chmod(configFile, 0600); err != nil {
 		log.Warning("unable to set permissions to default config: %s", err)
 	}
-	if err = ioutil.WriteFile(configFile, []byte(rawConfig), 0644); err != nil {
+	if err = core.WriteSealedFile(configFile, []byte(rawConfig), 0644); err != nil {
 		log.Error("writing configuration to disk: %s", err)
 		return err
 	}
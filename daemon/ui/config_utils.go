@@ -4,16 +4,34 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"runtime/debug"
 
+	"github.com/evilsocket/opensnitch/daemon/alerts"
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/denypage"
+	"github.com/evilsocket/opensnitch/daemon/dns"
 	"github.com/evilsocket/opensnitch/daemon/firewall"
+	"github.com/evilsocket/opensnitch/daemon/forwarder"
+	"github.com/evilsocket/opensnitch/daemon/geoip"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/listeners"
 	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netfilter"
 	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/netns"
+	"github.com/evilsocket/opensnitch/daemon/pcap"
+	"github.com/evilsocket/opensnitch/daemon/policyaudit"
 	"github.com/evilsocket/opensnitch/daemon/procmon"
 	"github.com/evilsocket/opensnitch/daemon/procmon/monitor"
 	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/rulesync"
+	"github.com/evilsocket/opensnitch/daemon/suggestions"
 	"github.com/evilsocket/opensnitch/daemon/ui/config"
+	"github.com/evilsocket/opensnitch/daemon/ui/prompt"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+	"github.com/evilsocket/opensnitch/daemon/updater"
 )
 
 func (c *Client) getSocketPath(socketPath string) string {
@@ -56,6 +74,71 @@ func (c *Client) isProcMonitorEqual(newMonitorMethod string) bool {
 	return newMonitorMethod == c.config.ProcMonitorMethod
 }
 
+func (c *Client) setProtocolDefaults(cfg map[string]string) {
+	defaults, err := prompt.NewProtocolDefaults(cfg)
+	if err != nil {
+		log.Warning("[config] %s", err)
+	}
+	c.Lock()
+	c.protocolDefaults = defaults
+	c.Unlock()
+}
+
+func (c *Client) setOriginDefaults(cfg map[string]string, verifyPackages bool) {
+	defaults, err := prompt.NewOriginDefaults(cfg)
+	if err != nil {
+		log.Warning("[config] %s", err)
+	}
+	if defaults.Enabled() && !verifyPackages {
+		log.Warning("[config] OriginDefaults configured, but Rules.VerifyPackages is disabled, all the binaries will be prompted")
+	}
+	c.Lock()
+	c.originDefaults = defaults
+	c.Unlock()
+}
+
+func (c *Client) setTtyPrompt(opts config.PromptOptions) {
+	c.Lock()
+	defer c.Unlock()
+
+	policies, err := prompt.NewPolicies(opts.Policies)
+	if err != nil {
+		log.Warning("[config] %s", err)
+	}
+	c.promptPolicy = policies
+
+	if c.decisions == nil ||
+		opts.DecisionsLog != c.config.Prompt.DecisionsLog ||
+		opts.MaxDecisions != c.config.Prompt.MaxDecisions {
+		c.decisions = prompt.NewDecisions(opts.DecisionsLog, opts.MaxDecisions)
+	}
+
+	c.ttyPrompt = nil
+	if opts.Tty == "" {
+		return
+	}
+	tty, err := prompt.NewTty(opts.Tty, opts.Timeout)
+	if err != nil {
+		log.Warning("[config] unable to use %s for prompts: %s", opts.Tty, err)
+		return
+	}
+	log.Info("[config] prompting connections on %s while the GUI is not connected", opts.Tty)
+	c.ttyPrompt = tty
+}
+
+func (c *Client) setChecksumQuarantine(duration string) {
+	if duration == "" {
+		c.rules.SetChecksumQuarantine(0)
+		return
+	}
+	tm, err := time.ParseDuration(duration)
+	if err != nil {
+		log.Warning("[config] invalid Rules.ChecksumQuarantine %s: %s", duration, err)
+		return
+	}
+	c.rules.SetChecksumQuarantine(tm)
+}
+
 func (c *Client) loadDiskConfiguration(reload bool) {
 	// https://pkg.go.dev/github.com/fsnotify/fsnotify#Watcher.Add
 	// "A watch will be automatically removed if the watched path is deleted or renamed"
@@ -79,7 +162,7 @@ func (c *Client) loadDiskConfiguration(reload bool) {
 	err = c.loadConfiguration(reload, raw)
 	if err != nil {
 		log.Error("[client] error loading config file: %s", err.Error())
-		c.SendWarningAlert(err.Error())
+		c.SendWarningAlert(i18n.New(i18n.ConfigLoadError, "error", err))
 		return
 	}
 
@@ -104,9 +187,44 @@ func (c *Client) loadConfiguration(reload bool, rawConfig []byte) (errf error) {
 	c.Lock()
 	c.config = newConfig
 	c.Unlock()
+	if reload {
+		c.SendStateEvent(protocol.StateEvent_CONFIG_CHANGED, rule.SourceFile, strings.Replace(string(rawConfig), "\n", "", -1))
+	}
 	return errf
 }
 
+// ReloadConfiguration loads the configuration from disk and applies it. If
+// it's not valid the current one is kept, and if it can't be applied the
+// previous one is restored.
+// It returns a function to restore the previous configuration, in case
+// reloading other parts of the daemon fails.
+func (c *Client) ReloadConfiguration() (restore func(), err error) {
+	raw, err := config.Load(configFile)
+	if err != nil || len(raw) == 0 {
+		return nil, fmt.Errorf("loading configuration %s: %v", configFile, err)
+	}
+	if _, err := config.Parse(raw); err != nil {
+		return nil, fmt.Errorf("parsing configuration %s: %s", configFile, err)
+	}
+
+	c.RLock()
+	prevConfig := c.config
+	c.RUnlock()
+	restore = func() {
+		log.Important("[config] restoring the previous configuration")
+		c.reloadConfiguration(true, &prevConfig)
+		c.Lock()
+		c.config = prevConfig
+		c.Unlock()
+	}
+
+	if err := c.loadConfiguration(true, raw); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
 func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err *monitor.Error) {
 
 	// firstly load config level, to detect further errors if any
@@ -115,6 +233,9 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 	}
 	log.SetLogUTC(newConfig.LogUTC)
 	log.SetLogMicro(newConfig.LogMicro)
+	if log.GetLimits() != newConfig.LogLimits {
+		log.SetLimits(newConfig.LogLimits)
+	}
 	if newConfig.Server.LogFile != "" {
 		log.Debug("[config] using config.server.logfile: %s", newConfig.Server.LogFile)
 		log.Close()
@@ -129,6 +250,32 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 		log.Debug("[config] config.server.loggers not changed")
 	}
 
+	if !reflect.DeepEqual(c.config.Server.AuditLog, newConfig.Server.AuditLog) {
+		log.Debug("[config] reloading config.server.auditlog")
+		if err := c.loggers.LoadAudit(newConfig.Server.AuditLog); err != nil {
+			log.Error("[config] audit log: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.server.auditlog not changed")
+	}
+
+	if !reflect.DeepEqual(c.config.Server.History, newConfig.Server.History) {
+		log.Debug("[config] reloading config.server.history")
+		if err := c.history.setConfig(newConfig.Server.History); err != nil {
+			log.Error("[config] events history: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.server.history not changed")
+	}
+
+	if c.config.MonitorMode != newConfig.MonitorMode {
+		if newConfig.MonitorMode {
+			log.Important("[config] monitor mode enabled, the verdicts of the rules won't be enforced")
+		} else {
+			log.Important("[config] monitor mode disabled, enforcing the verdicts of the rules")
+		}
+	}
+
 	if !reflect.DeepEqual(newConfig.Stats, c.config.Stats) {
 		log.Debug("[config] reloading config.stats")
 		c.stats.SetLimits(newConfig.Stats)
@@ -139,10 +286,9 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 	// 1. disconnect from the server (GUI) if the new server addr is empty.
 	// 2. connect to the server (GUI) if the new server addr is not empty, and previous addr was empty.
 	// 3. reconnect if:
-	//   - Auth options changed.
+	//   - Auth options changed (type, TLS, allowed UIDs or token).
 	//   - previous addr was not empty, new addr is not empty and new addr has changed.
-	reconnect := newConfig.Server.Authentication.Type != c.config.Server.Authentication.Type ||
-		!reflect.DeepEqual(newConfig.Server.Authentication.TLSOptions, c.config.Server.Authentication.TLSOptions)
+	reconnect := !reflect.DeepEqual(newConfig.Server.Authentication, c.config.Server.Authentication)
 	connect := false
 
 	if newConfig.Server.Address == "" {
@@ -187,6 +333,163 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 		clientErrorRule.Duration = rule.Duration(newConfig.DefaultDuration)
 	}
 
+	if !reflect.DeepEqual(newConfig.ProtocolDefaults, c.config.ProtocolDefaults) {
+		log.Debug("[config] reloading config.ProtocolDefaults")
+		c.setProtocolDefaults(newConfig.ProtocolDefaults)
+	} else {
+		log.Debug("[config] config.ProtocolDefaults not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.OriginDefaults, c.config.OriginDefaults) ||
+		newConfig.Rules.VerifyPackages != c.config.Rules.VerifyPackages {
+		log.Debug("[config] reloading config.OriginDefaults")
+		c.setOriginDefaults(newConfig.OriginDefaults, newConfig.Rules.VerifyPackages)
+	}
+
+	if !reflect.DeepEqual(newConfig.Prompt, c.config.Prompt) {
+		log.Debug("[config] reloading config.Prompt")
+		c.setTtyPrompt(newConfig.Prompt)
+	} else {
+		log.Debug("[config] config.Prompt not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.EventsCache, c.config.EventsCache) {
+		log.Debug("[config] reloading config.EventsCache")
+		procmon.EventsCache.SetConfig(newConfig.EventsCache)
+	} else {
+		log.Debug("[config] config.EventsCache not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.ProcessEnv, c.config.ProcessEnv) {
+		log.Debug("[config] reloading config.ProcessEnv")
+		procmon.SetEnvConfig(newConfig.ProcessEnv)
+	} else {
+		log.Debug("[config] config.ProcessEnv not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.Pcap, c.config.Pcap) {
+		log.Debug("[config] reloading config.Pcap")
+		if err := pcap.Denied.SetConfig(newConfig.Pcap); err != nil {
+			log.Error("[config] pcap: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.Pcap not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.Alerts, c.config.Alerts) {
+		log.Debug("[config] reloading config.Alerts")
+		if err := alerts.Default.SetConfig(newConfig.Alerts); err != nil {
+			log.Error("[config] alerts: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.Alerts not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.PolicyAudit, c.config.PolicyAudit) {
+		log.Debug("[config] reloading config.PolicyAudit")
+		if err := policyaudit.Default.SetConfig(newConfig.PolicyAudit); err != nil {
+			log.Error("[config] policy audit: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.PolicyAudit not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.RuleSync, c.config.RuleSync) {
+		log.Debug("[config] reloading config.RuleSync")
+		if err := rulesync.Default.SetConfig(newConfig.RuleSync); err != nil {
+			log.Error("[config] rules sync: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.RuleSync not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.Suggestions, c.config.Suggestions) {
+		log.Debug("[config] reloading config.Suggestions")
+		if err := suggestions.Default.SetConfig(newConfig.Suggestions); err != nil {
+			log.Error("[config] suggestions: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.Suggestions not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.Listeners, c.config.Listeners) {
+		log.Debug("[config] reloading config.Listeners")
+		if err := listeners.Default.SetConfig(newConfig.Listeners); err != nil {
+			log.Error("[config] listeners: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.Listeners not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.DenyPage, c.config.DenyPage) {
+		log.Debug("[config] reloading config.DenyPage")
+		if err := denypage.Default.SetConfig(newConfig.DenyPage); err != nil {
+			log.Error("[config] deny page: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.DenyPage not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.Forwarder, c.config.Forwarder) {
+		log.Debug("[config] reloading config.Forwarder")
+		if err := forwarder.Default.SetConfig(newConfig.Forwarder); err != nil {
+			log.Error("[config] forwarder: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.Forwarder not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.Updater, c.config.Updater) {
+		log.Debug("[config] reloading config.Updater")
+		if err := updater.Default.SetConfig(newConfig.Updater); err != nil {
+			log.Error("[config] updater: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.Updater not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.GeoIP, c.config.GeoIP) {
+		log.Debug("[config] reloading config.GeoIP")
+		if err := geoip.Default.SetConfig(newConfig.GeoIP); err != nil {
+			log.Error("[config] geoip: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.GeoIP not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.DNS, c.config.DNS) {
+		log.Debug("[config] reloading config.DNS")
+		if err := dns.Pusher.SetConfig(newConfig.DNS); err != nil {
+			log.Error("[config] dns: %s", err)
+		}
+		if err := dns.Cache.SetConfig(newConfig.DNS.Cache); err != nil {
+			log.Error("[config] dns cache: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.DNS not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.FwOptions.Namespaces, c.config.FwOptions.Namespaces) {
+		log.Debug("[config] reloading config.FwOptions.Namespaces")
+		if err := netns.Default.SetConfig(newConfig.FwOptions.Namespaces); err != nil {
+			log.Error("[config] network namespaces: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.FwOptions.Namespaces not changed")
+	}
+
+	if !reflect.DeepEqual(newConfig.FwOptions.QueueWatchdog, c.config.FwOptions.QueueWatchdog) {
+		log.Debug("[config] reloading config.FwOptions.QueueWatchdog")
+		if newConfig.FwOptions.QueueWatchdog.FailPolicy == netfilter.FailClosed && newConfig.FwOptions.QueueBypass {
+			log.Warning("[config] queues fail policy is closed, but QueueBypass is enabled: connections will be allowed if the daemon dies")
+		}
+		if err := netfilter.Watchdog.SetConfig(newConfig.FwOptions.QueueWatchdog); err != nil {
+			log.Error("[config] queues watchdog: %s", err)
+		}
+	} else {
+		log.Debug("[config] config.FwOptions.QueueWatchdog not changed")
+	}
+
 	if newConfig.Internal.GCPercent > 0 && newConfig.Internal.GCPercent != c.config.Internal.GCPercent {
 		oldgcpercent := debug.SetGCPercent(newConfig.Internal.GCPercent)
 		log.Debug("[config] GC percent set to %d, previously was %d", newConfig.Internal.GCPercent, oldgcpercent)
@@ -195,8 +498,72 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 	}
 
 	// 1. load rules
+	if newConfig.Rules.ChecksumBackend != c.config.Rules.ChecksumBackend {
+		log.Debug("[config] reloading config.Rules.ChecksumBackend: %s", newConfig.Rules.ChecksumBackend)
+		if err := procmon.SetHashBackend(newConfig.Rules.ChecksumBackend); err != nil {
+			log.Warning("[config] %s", err)
+		}
+	}
 	c.rules.EnableChecksums(newConfig.Rules.EnableChecksums)
-	if newConfig.Rules.Path == "" || c.config.Rules.Path != newConfig.Rules.Path {
+	c.rules.SetMaxSnapshots(newConfig.Rules.MaxSnapshots)
+	c.setChecksumQuarantine(newConfig.Rules.ChecksumQuarantine)
+	if err := c.rules.SetScoring(newConfig.Rules.Scoring); err != nil {
+		log.Warning("[config] Rules.Scoring: %s", err)
+	}
+	if newConfig.Rules.VerifyPackages != c.config.Rules.VerifyPackages {
+		log.Debug("[config] reloading config.Rules.VerifyPackages: %v", newConfig.Rules.VerifyPackages)
+		procmon.Packages.SetEnabled(newConfig.Rules.VerifyPackages)
+	}
+	if newConfig.Rules.CaptureLibraries != c.config.Rules.CaptureLibraries {
+		log.Debug("[config] reloading config.Rules.CaptureLibraries: %v", newConfig.Rules.CaptureLibraries)
+		procmon.SetCaptureLibraries(newConfig.Rules.CaptureLibraries)
+	}
+	reloadRules := false
+	if !reflect.DeepEqual(newConfig.Rules.VerdictCache, c.config.Rules.VerdictCache) {
+		log.Debug("[config] reloading config.Rules.VerdictCache: %v", newConfig.Rules.VerdictCache)
+		conman.Verdicts.SetConfig(newConfig.Rules.VerdictCache)
+	}
+	if !reflect.DeepEqual(newConfig.Rules.UDPFlows, c.config.Rules.UDPFlows) {
+		log.Debug("[config] reloading config.Rules.UDPFlows: %v", newConfig.Rules.UDPFlows)
+		conman.Flows.SetConfig(newConfig.Rules.UDPFlows)
+	}
+	if !reflect.DeepEqual(newConfig.Rules.Retransmissions, c.config.Rules.Retransmissions) {
+		log.Debug("[config] reloading config.Rules.Retransmissions: %v", newConfig.Rules.Retransmissions)
+		conman.Retransmits.SetConfig(newConfig.Rules.Retransmissions)
+	}
+	if !reflect.DeepEqual(newConfig.Rules.Accounting, c.config.Rules.Accounting) {
+		log.Debug("[config] reloading config.Rules.Accounting: %v", newConfig.Rules.Accounting)
+		conman.Accounting.SetConfig(newConfig.Rules.Accounting)
+	}
+	if !reflect.DeepEqual(newConfig.Rules.LocalProxies, c.config.Rules.LocalProxies) {
+		log.Debug("[config] reloading config.Rules.LocalProxies: %v", newConfig.Rules.LocalProxies)
+		conman.Proxies.SetConfig(newConfig.Rules.LocalProxies)
+	}
+	if !reflect.DeepEqual(newConfig.Rules.ListsTrustedKeys, c.config.Rules.ListsTrustedKeys) {
+		log.Debug("[config] reloading config.Rules.ListsTrustedKeys: %v", newConfig.Rules.ListsTrustedKeys)
+		if err := rule.SetListsTrustedKeys(newConfig.Rules.ListsTrustedKeys); err != nil {
+			log.Warning("[config] error loading lists trusted keys: %s", err)
+		}
+		// the lists already loaded must be verified again.
+		reloadRules = true
+	}
+	if newConfig.Rules.DualStack != c.config.Rules.DualStack {
+		log.Debug("[config] reloading config.Rules.DualStack: %v", newConfig.Rules.DualStack)
+		rule.SetDualStack(newConfig.Rules.DualStack)
+		// the networks of the rules must be expanded again.
+		reloadRules = true
+	}
+	if newConfig.Rules.AllowImportedHooks != c.config.Rules.AllowImportedHooks {
+		log.Debug("[config] reloading config.Rules.AllowImportedHooks: %v", newConfig.Rules.AllowImportedHooks)
+		rule.SetAllowImportedHooks(newConfig.Rules.AllowImportedHooks)
+	}
+	if newConfig.Rules.RejectPublicSuffixes != c.config.Rules.RejectPublicSuffixes {
+		log.Debug("[config] reloading config.Rules.RejectPublicSuffixes: %v", newConfig.Rules.RejectPublicSuffixes)
+		rule.SetRejectPublicSuffixes(newConfig.Rules.RejectPublicSuffixes)
+		// the domains of the rules must be verified again.
+		reloadRules = true
+	}
+	if reloadRules || newConfig.Rules.Path == "" || c.config.Rules.Path != newConfig.Rules.Path {
 		c.rules.Reload(newConfig.Rules.Path)
 		log.Debug("[config] reloading config.rules.path, old: <%s> new: <%s>", c.config.Rules.Path, newConfig.Rules.Path)
 	} else {
@@ -235,10 +602,14 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 		newConfig.FwOptions.ConfigPath != c.config.FwOptions.ConfigPath ||
 		newConfig.FwOptions.QueueNum != c.config.FwOptions.QueueNum ||
 		newConfig.FwOptions.MonitorInterval != c.config.FwOptions.MonitorInterval ||
-		newConfig.FwOptions.QueueBypass != c.config.FwOptions.QueueBypass {
+		newConfig.FwOptions.QueueBypass != c.config.FwOptions.QueueBypass ||
+		newConfig.FwOptions.DNSQueueNum != c.config.FwOptions.DNSQueueNum ||
+		newConfig.FwOptions.DNSQueueBypass != c.config.FwOptions.DNSQueueBypass {
 		log.Debug("[config] reloading config.firewall")
 		reloadFw = true
 
+		firewall.SetDNSQueue(newConfig.FwOptions.DNSQueueNum, newConfig.FwOptions.DNSQueueBypass)
+
 		if err := firewall.Reload(
 			newConfig.Firewall,
 			newConfig.FwOptions.ConfigPath,
@@ -248,6 +619,7 @@ func (c *Client) reloadConfiguration(reload bool, newConfig *config.Config) (err
 		); err != nil {
 			log.Error("[config] firewall reload error: %s", err)
 		}
+		c.sendFirewallChanged(rule.SourceDaemon, newConfig.Firewall)
 	} else {
 		log.Debug("[config] config.firewall not changed")
 	}
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// NotificationError is an error replied to a notification, with a stable code
+// and the objects involved, so the UIs can react to it.
+type NotificationError struct {
+	Err     error
+	Details map[string]string
+	Code    protocol.ErrorCode
+}
+
+func (e *NotificationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NotificationError) Unwrap() error {
+	return e.Err
+}
+
+// newError classifies an error with a code. The details are pairs of keys and
+// values: newError(code, err, "rule", name)
+func newError(code protocol.ErrorCode, err error, details ...string) error {
+	if err == nil {
+		return nil
+	}
+	nerr := &NotificationError{Err: err, Code: code}
+	if len(details) > 1 {
+		nerr.Details = make(map[string]string, len(details)/2)
+		for i := 0; i+1 < len(details); i += 2 {
+			nerr.Details[details[i]] = details[i+1]
+		}
+	}
+	return nerr
+}
+
+// ruleError classifies the errors of the operations on a rule. Errors already
+// classified are returned as is.
+func ruleError(err error, name string) error {
+	var nerr *NotificationError
+	if err == nil || errors.As(err, &nerr) {
+		return err
+	}
+	var details []string
+	if name != "" {
+		details = []string{"rule", name}
+	}
+	switch {
+	case errors.Is(err, rule.ErrInvalidRule):
+		return newError(protocol.ErrorCode_ERR_INVALID_RULE, err, details...)
+	case errors.Is(err, rule.ErrRuleNotFound):
+		return newError(protocol.ErrorCode_ERR_NOT_FOUND, err, details...)
+	case errors.Is(err, rule.ErrUnknownConflict):
+		return newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, details...)
+	}
+	return newError(protocol.ErrorCode_ERR_SAVE, err, details...)
+}
+
+// errorCode returns the code and the details of an error. Errors not
+// classified with newError() are classified by their type, if possible.
+func errorCode(err error) (protocol.ErrorCode, map[string]string) {
+	var nerr *NotificationError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case err == nil:
+		return protocol.ErrorCode_ERR_NONE, nil
+	case errors.As(err, &nerr):
+		return nerr.Code, nerr.Details
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return protocol.ErrorCode_ERR_INVALID_ARGUMENT, nil
+	case errors.Is(err, os.ErrNotExist):
+		return protocol.ErrorCode_ERR_NOT_FOUND, nil
+	case errors.Is(err, os.ErrPermission):
+		return protocol.ErrorCode_ERR_PERMISSION_DENIED, nil
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+		return protocol.ErrorCode_ERR_TIMEOUT, nil
+	}
+	return protocol.ErrorCode_ERR_UNKNOWN, nil
+}
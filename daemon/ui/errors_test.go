@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestErrorCode(t *testing.T) {
+	var v struct{}
+	jsonErr := json.Unmarshal([]byte("{"), &v)
+
+	tests := []struct {
+		err     error
+		code    protocol.ErrorCode
+		details map[string]string
+	}{
+		{nil, protocol.ErrorCode_ERR_NONE, nil},
+		{fmt.Errorf("unclassified"), protocol.ErrorCode_ERR_UNKNOWN, nil},
+		{jsonErr, protocol.ErrorCode_ERR_INVALID_ARGUMENT, nil},
+		{fmt.Errorf("reading: %w", os.ErrNotExist), protocol.ErrorCode_ERR_NOT_FOUND, nil},
+		{newError(protocol.ErrorCode_ERR_FIREWALL, fmt.Errorf("fw")), protocol.ErrorCode_ERR_FIREWALL, nil},
+		{newError(protocol.ErrorCode_ERR_TIMEOUT, fmt.Errorf("timeout"), "pid", "1234"), protocol.ErrorCode_ERR_TIMEOUT, map[string]string{"pid": "1234"}},
+		{ruleError(fmt.Errorf("%w: x", rule.ErrRuleNotFound), "x"), protocol.ErrorCode_ERR_NOT_FOUND, map[string]string{"rule": "x"}},
+		{ruleError(fmt.Errorf("%w, bad regexp", rule.ErrInvalidRule), "y"), protocol.ErrorCode_ERR_INVALID_RULE, map[string]string{"rule": "y"}},
+		{ruleError(fmt.Errorf("%w: z", rule.ErrUnknownConflict), ""), protocol.ErrorCode_ERR_INVALID_ARGUMENT, nil},
+		{ruleError(fmt.Errorf("disk full"), "w"), protocol.ErrorCode_ERR_SAVE, map[string]string{"rule": "w"}},
+		// already classified errors are not reclassified
+		{ruleError(newError(protocol.ErrorCode_ERR_INVALID_RULE, fmt.Errorf("nil rule")), "v"), protocol.ErrorCode_ERR_INVALID_RULE, nil},
+	}
+
+	for i, tt := range tests {
+		code, details := errorCode(tt.err)
+		if code != tt.code {
+			t.Errorf("%d, %v: expected code %s, got %s", i, tt.err, tt.code, code)
+		}
+		if len(details) != len(tt.details) {
+			t.Errorf("%d, %v: expected details %v, got %v", i, tt.err, tt.details, details)
+			continue
+		}
+		for k, v := range tt.details {
+			if details[k] != v {
+				t.Errorf("%d, %v: expected details %v, got %v", i, tt.err, tt.details, details)
+			}
+		}
+	}
+
+	if newError(protocol.ErrorCode_ERR_SAVE, nil) != nil {
+		t.Error("newError(nil) should return nil")
+	}
+}
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall"
+	"github.com/evilsocket/opensnitch/daemon/firewall/iptables"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// SourceWatchdog is the source of the changes made by the watchdog of the
+// queues, when the connections are not being processed.
+const SourceWatchdog = "watchdog"
+
+// max number of state events waiting to be sent to the GUI.
+const maxQueuedEvents = 64
+
+// interceptionState is the data of the INTERCEPTION_CHANGED events.
+type interceptionState struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// firewallState is the data of the FIREWALL_CHANGED events.
+type firewallState struct {
+	Firewall  string `json:"firewall"`
+	Running   bool   `json:"running"`
+	PanicMode bool   `json:"panic_mode"`
+}
+
+// SendStateEvent sends a change of the state of the daemon to the GUI, so it
+// stays in sync without polling. data is sent as JSON, unless it's a string.
+// The events are discarded while the GUI is not connected, it receives the
+// current state when it subscribes again.
+func (c *Client) SendStateEvent(evType protocol.StateEvent_Type, source string, data interface{}) {
+	if !c.Connected() {
+		return
+	}
+	ev, err := newStateEvent(evType, source, data)
+	if err != nil {
+		log.Warning("[events] %s, error serializing data: %s", evType, err)
+		return
+	}
+	select {
+	case c.stateEvents <- ev:
+	default:
+		log.Debug("[events] queue full, discarding event %s", evType)
+	}
+}
+
+func newStateEvent(evType protocol.StateEvent_Type, source string, data interface{}) (*protocol.StateEvent, error) {
+	raw, ok := data.(string)
+	if !ok {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		raw = string(b)
+	}
+	return &protocol.StateEvent{
+		Type:   evType,
+		Time:   time.Now().UnixNano(),
+		Source: source,
+		Data:   raw,
+	}, nil
+}
+
+// SendInterceptionChanged notifies the GUI that the interception of the
+// connections has been enabled or disabled, and why.
+func (c *Client) SendInterceptionChanged(enabled bool, source, reason string) {
+	c.SendStateEvent(protocol.StateEvent_INTERCEPTION_CHANGED, source, interceptionState{Enabled: enabled, Reason: reason})
+}
+
+// sendFirewallChanged notifies the GUI that the firewall has been reloaded,
+// switched, or its panic mode enabled or disabled.
+func (c *Client) sendFirewallChanged(source, fwType string) {
+	if fwType == "" {
+		fwType = iptables.Name
+	}
+	c.SendStateEvent(protocol.StateEvent_FIREWALL_CHANGED, source, firewallState{
+		Firewall:  fwType,
+		Running:   firewall.IsRunning(),
+		PanicMode: firewall.IsPanicMode(),
+	})
+}
+
+// onRulesChange notifies the GUI of the changes of the rules, including the
+// ones made on disk and the temporary rules expired.
+func (c *Client) onRulesChange(s *rule.Snapshot) {
+	c.SendStateEvent(protocol.StateEvent_RULES_CHANGED, s.Source, s)
+}
+
+// eventsDispatcher sends the state events to the GUI, in order, without
+// blocking who caused them.
+func (c *Client) eventsDispatcher() {
+	for {
+		select {
+		case <-c.clientCtx.Done():
+			return
+		case ev := <-c.stateEvents:
+			c.RLock()
+			stream := c.streamNotifications
+			c.RUnlock()
+			if stream == nil {
+				continue
+			}
+			reply := &protocol.NotificationReply{Id: 0, Code: protocol.NotificationReplyCode_OK, Event: ev}
+			if err := c.send(stream, reply); err != nil && err != io.EOF {
+				log.Debug("[events] error sending event %s: %s", ev.Type, err)
+			}
+		}
+	}
+}
+
+// send sends a reply to the GUI. The stream is shared by the notifications,
+// the tasks and the events, and it can't be written concurrently.
+func (c *Client) send(stream protocol.UI_NotificationsClient, reply *protocol.NotificationReply) error {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	return stream.Send(reply)
+}
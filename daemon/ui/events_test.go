@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// eventsStream records the replies sent to the GUI.
+type eventsStream struct {
+	protocol.UI_NotificationsClient
+	sent chan *protocol.NotificationReply
+}
+
+func (s *eventsStream) Send(reply *protocol.NotificationReply) error {
+	s.sent <- reply
+	return nil
+}
+
+func TestNewStateEvent(t *testing.T) {
+	ev, err := newStateEvent(protocol.StateEvent_INTERCEPTION_CHANGED, SourceWatchdog, interceptionState{Reason: "queue stalled"})
+	if err != nil {
+		t.Fatal("newStateEvent() error:", err)
+	}
+	if ev.Type != protocol.StateEvent_INTERCEPTION_CHANGED || ev.Source != SourceWatchdog || ev.Time == 0 ||
+		ev.Data != `{"enabled":false,"reason":"queue stalled"}` {
+		t.Errorf("invalid event: %+v", ev)
+	}
+	// the strings are sent as is.
+	if ev, _ = newStateEvent(protocol.StateEvent_CONFIG_CHANGED, "file", `{"Firewall":"nftables"}`); ev.Data != `{"Firewall":"nftables"}` {
+		t.Errorf("invalid event data: %s", ev.Data)
+	}
+	if _, err = newStateEvent(protocol.StateEvent_RULES_CHANGED, "file", func() {}); err == nil {
+		t.Error("invalid data serialized")
+	}
+}
+
+func TestStateEvents(t *testing.T) {
+	stream := &eventsStream{sent: make(chan *protocol.NotificationReply, 1)}
+	c := &Client{
+		stateEvents:         make(chan *protocol.StateEvent, maxQueuedEvents),
+		streamNotifications: stream,
+	}
+	c.clientCtx, c.clientCancel = context.WithCancel(context.Background())
+	defer c.clientCancel()
+
+	// not connected to the GUI.
+	c.SendStateEvent(protocol.StateEvent_RULES_CHANGED, "file", "{}")
+	if len(c.stateEvents) != 0 {
+		t.Fatal("event queued while disconnected")
+	}
+
+	go c.eventsDispatcher()
+	ev, _ := newStateEvent(protocol.StateEvent_FIREWALL_CHANGED, "daemon", firewallState{Firewall: "nftables"})
+	c.stateEvents <- ev
+	select {
+	case reply := <-stream.sent:
+		if reply.Id != 0 || reply.Code != protocol.NotificationReplyCode_OK || reply.Event != ev {
+			t.Errorf("invalid reply: %+v", reply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not sent")
+	}
+}
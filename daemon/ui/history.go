@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/statistics"
+	"github.com/evilsocket/opensnitch/daemon/ui/config"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// history keeps the last connection events and the rules they matched, in a
+// ring buffer, to replay them to the GUI when it connects, so the user can see
+// what happened before opening it.
+type history struct {
+	events []*statistics.Event
+	// position of the next event, and number of events in the buffer.
+	next  int
+	count int
+
+	maxAge time.Duration
+	sync.Mutex
+}
+
+func newHistory() *history {
+	return &history{}
+}
+
+// setConfig resizes the buffer, keeping the newest events.
+func (h *history) setConfig(cfg config.HistoryOptions) error {
+	var maxAge time.Duration
+	if cfg.MaxAge != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(cfg.MaxAge); err != nil || maxAge < 0 {
+			return fmt.Errorf("invalid events history MaxAge: %s", cfg.MaxAge)
+		}
+	}
+	size := cfg.MaxEvents
+	if size < 0 {
+		size = 0
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	h.maxAge = maxAge
+	if size == len(h.events) {
+		return nil
+	}
+	events := h.ordered()
+	if len(events) > size {
+		events = events[len(events)-size:]
+	}
+	h.events = make([]*statistics.Event, size)
+	h.count = copy(h.events, events)
+	h.next = h.count
+	if size > 0 {
+		h.next %= size
+	}
+	return nil
+}
+
+// add adds an event, replacing the oldest one if the buffer is full.
+func (h *history) add(e *statistics.Event) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.events) == 0 {
+		return
+	}
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	if h.count < len(h.events) {
+		h.count++
+	}
+}
+
+// ordered returns the events from the oldest to the newest. The caller must
+// hold the lock.
+func (h *history) ordered() []*statistics.Event {
+	events := make([]*statistics.Event, 0, h.count)
+	start := h.next - h.count
+	if start < 0 {
+		start += len(h.events)
+	}
+	for i := 0; i < h.count; i++ {
+		events = append(events, h.events[(start+i)%len(h.events)])
+	}
+	return events
+}
+
+// replay returns the events to send to a GUI just connected: the events of
+// the buffer not pending to be sent, marked as historical, followed by the
+// pending ones. The pending events that happened before the GUI connected
+// are also marked as historical.
+func (h *history) replay(pending []*protocol.Event, connected, now time.Time) []*protocol.Event {
+	// the events pending to be sent are the newest ones of the buffer.
+	before := now
+	if len(pending) > 0 {
+		before = time.Unix(0, pending[0].Unixnano)
+	}
+
+	h.Lock()
+	events := h.ordered()
+	maxAge := h.maxAge
+	h.Unlock()
+
+	replayed := make([]*protocol.Event, 0, len(events)+len(pending))
+	for _, e := range events {
+		if !e.Time.Before(before) {
+			break
+		}
+		if maxAge > 0 && now.Sub(e.Time) > maxAge {
+			continue
+		}
+		ev := e.Serialize()
+		ev.Historical = true
+		replayed = append(replayed, ev)
+	}
+	for _, ev := range pending {
+		ev.Historical = ev.Unixnano < connected.UnixNano()
+		replayed = append(replayed, ev)
+	}
+	return replayed
+}
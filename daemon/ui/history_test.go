@@ -0,0 +1,85 @@
+package ui
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/statistics"
+	"github.com/evilsocket/opensnitch/daemon/ui/config"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func newTestEvent(t time.Time, host string) *statistics.Event {
+	return &statistics.Event{
+		Time: t,
+		Connection: &conman.Connection{
+			Protocol: "tcp",
+			SrcIP:    net.ParseIP("127.0.0.1"),
+			DstIP:    net.ParseIP("185.53.178.14"),
+			DstHost:  host,
+			DstPort:  443,
+			Entry:    &netstat.Entry{UserId: 1000},
+			Process:  procmon.NewProcessEmpty(1234, "curl"),
+		},
+		Rule: rule.Create("000-allow-curl", "", true, false, false, rule.Allow, rule.Always, dummyOperator),
+	}
+}
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	h := newHistory()
+	h.add(newTestEvent(now, "disabled.example.com"))
+	if events := h.replay(nil, now, now.Add(time.Second)); len(events) != 0 {
+		t.Fatal("events kept with the history disabled:", len(events))
+	}
+
+	if err := h.setConfig(config.HistoryOptions{MaxEvents: 3, MaxAge: "10m"}); err != nil {
+		t.Fatal("setConfig() error:", err)
+	}
+	if err := h.setConfig(config.HistoryOptions{MaxEvents: 3, MaxAge: "ten minutes"}); err == nil {
+		t.Error("invalid MaxAge accepted")
+	}
+	for i := 0; i < 5; i++ {
+		h.add(newTestEvent(now.Add(-time.Duration(5-i)*4*time.Minute), fmt.Sprint(i, ".example.com")))
+	}
+	// 0, 1 replaced by 2, 3, 4. 2 is older than the MaxAge.
+	connected := now.Add(time.Minute)
+	events := h.replay(nil, connected, connected)
+	if len(events) != 2 || events[0].Connection.DstHost != "3.example.com" || events[1].Connection.DstHost != "4.example.com" {
+		t.Fatalf("unexpected events replayed: %v", events)
+	}
+	for _, ev := range events {
+		if !ev.Historical {
+			t.Error("event replayed not marked as historical:", ev.Connection.DstHost)
+		}
+	}
+
+	// the events pending to be sent are not replayed twice, and the ones
+	// after connecting are not historical.
+	pending := []*protocol.Event{
+		newTestEvent(now.Add(40*time.Second), "5.example.com").Serialize(),
+		newTestEvent(now.Add(80*time.Second), "6.example.com").Serialize(),
+	}
+	for _, ev := range pending {
+		h.add(&statistics.Event{Time: time.Unix(0, ev.Unixnano)})
+	}
+	events = h.replay(pending, connected, now.Add(2*time.Minute))
+	if len(events) != 3 || events[0].Connection.DstHost != "4.example.com" {
+		t.Fatalf("unexpected events replayed: %v", events)
+	}
+	if !events[0].Historical || !events[1].Historical || events[2].Historical {
+		t.Error("events not marked as historical:", events[0].Historical, events[1].Historical, events[2].Historical)
+	}
+
+	// resized, keeping the newest events.
+	h.setConfig(config.HistoryOptions{MaxEvents: 1})
+	if events := h.ordered(); len(events) != 1 || events[0].Time.UnixNano() != pending[1].Unixnano {
+		t.Error("newest event not kept:", events)
+	}
+}
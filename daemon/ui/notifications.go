@@ -1,17 +1,22 @@
 package ui
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/dns"
+	"github.com/evilsocket/opensnitch/daemon/features"
 	"github.com/evilsocket/opensnitch/daemon/firewall"
+	fwConfig "github.com/evilsocket/opensnitch/daemon/firewall/config"
 	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/procmon/ebpf"
 	"github.com/evilsocket/opensnitch/daemon/procmon/monitor"
 	"github.com/evilsocket/opensnitch/daemon/rule"
 	"github.com/evilsocket/opensnitch/daemon/tasks/base"
@@ -20,6 +25,7 @@ import (
 	"github.com/evilsocket/opensnitch/daemon/tasks/socketsmonitor"
 	"github.com/evilsocket/opensnitch/daemon/ui/config"
 	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+	"github.com/evilsocket/opensnitch/daemon/updater"
 	"golang.org/x/net/context"
 )
 
@@ -33,20 +39,19 @@ func NewReply(rID uint64, replyCode protocol.NotificationReplyCode, data string)
 }
 
 func (c *Client) getClientConfig() *protocol.ClientConfig {
-	raw, _ := ioutil.ReadFile(configFile)
+	raw, _ := config.Load(configFile)
 	nodeName := core.GetHostname()
 	nodeVersion := core.GetKernelVersion()
 	var ts time.Time
-	// GetAll() returns a snapshot, so len() and the range below see a
+	// GetOrdered() returns a snapshot, so len() and the range below see a
 	// consistent view even if the loader is concurrently adding rules
 	// (this used to panic with "index out of range" on big rule sets, when
 	// the UI subscribed back during rules.Reload()).
-	rules := c.rules.GetAll()
+	// The rules are sent in the order they're evaluated.
+	rules := c.rules.GetOrdered()
 	ruleList := make([]*protocol.Rule, len(rules))
-	idx := 0
-	for _, r := range rules {
+	for idx, r := range rules {
 		ruleList[idx] = r.Serialize()
-		idx++
 	}
 	sysfw, err := firewall.Serialize()
 	if err != nil {
@@ -70,12 +75,12 @@ func (c *Client) handleActionChangeConfig(stream protocol.UI_NotificationsClient
 	newConf, err := config.Parse(ntf.Data)
 	if err != nil {
 		log.Warning("[notification] error parsing received config: %v", ntf.Data)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_CONFIG, err))
 		return
 	}
 
 	if err := c.reloadConfiguration(true, &newConf); err != nil {
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err.Msg)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_PROC_MONITOR, err.Msg))
 		return
 	}
 
@@ -85,68 +90,543 @@ func (c *Client) handleActionChangeConfig(stream protocol.UI_NotificationsClient
 		log.Warning("[notification] CHANGE_CONFIG not applied %s", err)
 	}
 
-	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_SAVE, err, "path", configFile))
+}
+
+// changeSource returns who has sent a notification that changes the rules,
+// to record it in the snapshots of the rules and in the state events.
+func changeSource(ntf *protocol.Notification) string {
+	if ntf.ClientName != "" {
+		return ntf.ClientName
+	}
+	return rule.SourceGUI
 }
 
 func (c *Client) handleActionEnableRule(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	var err error
-	for _, rul := range ntf.Rules {
-		log.Info("[notification] enable rule: %s", rul.Name)
-		// protocol.Rule(protobuf) != rule.Rule(json)
-		r, _ := rule.Deserialize(rul)
-		r.Enabled = true
-		// save to disk only if the duration is rule.Always
-		err = c.rules.Replace(r, r.Duration == rule.Always)
-	}
+	c.rules.Track(changeSource(ntf), "enable rule", func() error {
+		for _, rul := range ntf.Rules {
+			log.Info("[notification] enable rule: %s", rul.Name)
+			// protocol.Rule(protobuf) != rule.Rule(json)
+			r, e := rule.Deserialize(rul)
+			if r == nil {
+				err = newError(protocol.ErrorCode_ERR_INVALID_RULE, e, "rule", rul.Name)
+				continue
+			}
+			r.Enabled = true
+			// save to disk only if the duration is rule.Always
+			err = ruleError(c.rules.Replace(r, r.Duration == rule.Always), r.Name)
+		}
+		return err
+	})
 	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
 }
 
 func (c *Client) handleActionDisableRule(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	var err error
-	for _, rul := range ntf.Rules {
-		log.Info("[notification] disable rule: %s", rul)
-		r, _ := rule.Deserialize(rul)
-		r.Enabled = false
-		err = c.rules.Replace(r, r.Duration == rule.Always)
-	}
+	c.rules.Track(changeSource(ntf), "disable rule", func() error {
+		for _, rul := range ntf.Rules {
+			log.Info("[notification] disable rule: %s", rul)
+			r, e := rule.Deserialize(rul)
+			if r == nil {
+				err = newError(protocol.ErrorCode_ERR_INVALID_RULE, e, "rule", rul.Name)
+				continue
+			}
+			r.Enabled = false
+			err = ruleError(c.rules.Replace(r, r.Duration == rule.Always), r.Name)
+		}
+		return err
+	})
 	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
 }
 
 func (c *Client) handleActionChangeRule(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	var rErr error
-	for _, rul := range ntf.Rules {
-		r, err := rule.Deserialize(rul)
-		if r == nil {
-			rErr = fmt.Errorf("Invalid rule, %s", err)
-			continue
-		}
-		log.Info("[notification] change rule: %s %d", r, ntf.Id)
-		if err := c.rules.Replace(r, r.Duration == rule.Always); err != nil {
-			log.Warning("[notification] Error changing rule: %s %s", err, r)
-			rErr = err
+	c.rules.Track(changeSource(ntf), "change rule", func() error {
+		for _, rul := range ntf.Rules {
+			r, err := rule.Deserialize(rul)
+			if r == nil {
+				rErr = newError(protocol.ErrorCode_ERR_INVALID_RULE, fmt.Errorf("Invalid rule, %s", err), "rule", rul.Name)
+				continue
+			}
+			log.Info("[notification] change rule: %s %d", r, ntf.Id)
+			if err := c.rules.Replace(r, r.Duration == rule.Always); err != nil {
+				log.Warning("[notification] Error changing rule: %s %s", err, r)
+				rErr = ruleError(err, r.Name)
+			}
 		}
-	}
+		return rErr
+	})
 	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", rErr)
 }
 
 func (c *Client) handleActionDeleteRule(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	var err error
-	for _, rul := range ntf.Rules {
-		log.Info("[notification] delete rule: %s %d", rul.Name, ntf.Id)
-		err = c.rules.Delete(rul.Name)
-		if err != nil {
-			log.Error("[notification] Error deleting rule: %s %s", err, rul)
+	c.rules.Track(changeSource(ntf), "delete rule", func() error {
+		for _, rul := range ntf.Rules {
+			log.Info("[notification] delete rule: %s %d", rul.Name, ntf.Id)
+			err = ruleError(c.rules.Delete(rul.Name), rul.Name)
+			if err != nil {
+				log.Error("[notification] Error deleting rule: %s %s", err, rul)
+			}
 		}
+		return err
+	})
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+}
+
+func (c *Client) handleActionReorderRules(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	names := make([]string, len(ntf.Rules))
+	for i, rul := range ntf.Rules {
+		names[i] = rul.Name
+	}
+	log.Info("[notification] reorder rules: %v %d", names, ntf.Id)
+	err := ruleError(c.rules.Track(changeSource(ntf), "reorder rules", func() error {
+		return c.rules.Reorder(names)
+	}), "")
+	if err != nil {
+		log.Warning("[notification] Error reordering rules: %s", err)
 	}
 	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
 }
 
+func (c *Client) handleActionGetRulesStats(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		Unused bool `json:"unused"`
+	}
+	if ntf.Data != "" {
+		if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+			log.Warning("[notification] invalid rules stats options: %s, %s", err, ntf.Data)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+			return
+		}
+	}
+
+	reply := NewReply(ntf.Id, protocol.NotificationReplyCode_OK, "")
+	reply.RulesStats = c.stats.SerializeRules(opts.Unused)
+	if err := c.send(stream, reply); err != nil && err != io.EOF {
+		log.Error("Error replying to notification, type: %d, id: %d, err: %s", ntf.Type, reply.Id, err)
+	}
+}
+
+func (c *Client) handleActionExportRules(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	opts := struct {
+		Format string `json:"format"`
+	}{Format: rule.BulkJSON}
+	if ntf.Data != "" {
+		if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+			return
+		}
+	}
+	var doc strings.Builder
+	err := c.rules.Export(&doc, opts.Format)
+	if err != nil {
+		log.Warning("[notification] Error exporting rules: %s", err)
+		err = newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "format", opts.Format)
+	}
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, doc.String(), err)
+}
+
+func (c *Client) handleActionImportRules(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	opts := struct {
+		Format   string `json:"format"`
+		Conflict string `json:"conflict"`
+		Document string `json:"document"`
+	}{Format: rule.BulkJSON, Conflict: rule.ConflictSkip}
+	if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	rules, err := rule.ReadRules(strings.NewReader(opts.Document), opts.Format)
+	if err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_RULE, err, "format", opts.Format))
+		return
+	}
+	var result *rule.ImportResult
+	err = c.rules.Track(changeSource(ntf), "import rules", func() (err error) {
+		result, err = c.rules.Import(rules, opts.Conflict)
+		return err
+	})
+	if err != nil {
+		log.Warning("[notification] Error importing rules: %s", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", ruleError(err, ""))
+		return
+	}
+	log.Info("[notification] rules imported, added: %d, replaced: %d, renamed: %d, skipped: %d, errors: %d",
+		len(result.Added), len(result.Replaced), len(result.Renamed), len(result.Skipped), len(result.Errors))
+	raw, err := json.Marshal(result)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionExportBundle(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var key ed25519.PrivateKey
+	var err error
+	if c.config.Rules.BundleSigningKey != "" {
+		if key, err = rule.ReadBundleSigningKey(c.config.Rules.BundleSigningKey); err != nil {
+			log.Warning("[notification] Error exporting bundle: %s", err)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_CONFIG, err, "path", c.config.Rules.BundleSigningKey))
+			return
+		}
+	}
+	// the system firewall may not be initialized, or be disabled.
+	sysfw, err := getSystemFirewallConfig()
+	if err != nil {
+		log.Debug("[notification] exporting bundle without the system firewall configuration: %s", err)
+	}
+	raw, err := rule.NewBundle(c.rules.GetOrdered(), sysfw).Marshal(key)
+	if err != nil {
+		log.Warning("[notification] Error exporting bundle: %s", err)
+	}
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionImportBundle(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		Bundle  string `json:"bundle"`
+		Replace bool   `json:"replace"`
+	}
+	if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	trustedKeys, err := rule.ReadTrustedKeys(c.config.Rules.BundleTrustedKeys)
+	if err != nil {
+		log.Warning("[notification] Error importing bundle: %s", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_CONFIG, err))
+		return
+	}
+	bundle, err := rule.UnmarshalBundle([]byte(opts.Bundle), trustedKeys)
+	if err == nil && len(bundle.SystemFirewall) > 0 {
+		var sysfw fwConfig.SystemConfig
+		if err = json.Unmarshal(bundle.SystemFirewall, &sysfw); err != nil {
+			err = fmt.Errorf("Invalid system firewall configuration: %s", err)
+		}
+	}
+	if err != nil {
+		log.Warning("[notification] Error importing bundle: %s", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_BUNDLE, err))
+		return
+	}
+
+	// the firewall configuration is applied asynchronously, so we wait for
+	// errors before restoring the rules.
+	go func(c *Client) {
+		var prevSysfw []byte
+		if len(bundle.SystemFirewall) > 0 {
+			if prevSysfw, err = getSystemFirewallConfig(); err == nil {
+				err = applySystemFirewallConfig(bundle.SystemFirewall)
+			}
+			if err != nil {
+				if prevSysfw != nil {
+					applySystemFirewallConfig(prevSysfw)
+				}
+				log.Warning("[notification] Error importing bundle, system firewall: %s", err)
+				c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, err))
+				return
+			}
+		}
+		var result *rule.ImportResult
+		err = c.rules.Track(changeSource(ntf), "import bundle", func() (err error) {
+			result, err = c.rules.Restore(bundle.Rules, opts.Replace)
+			return err
+		})
+		if err != nil {
+			if prevSysfw != nil {
+				applySystemFirewallConfig(prevSysfw)
+			}
+			log.Warning("[notification] Error importing bundle: %s", err)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", ruleError(err, ""))
+			return
+		}
+		log.Info("[notification] bundle imported, rules added: %d, replaced: %d, deleted: %d",
+			len(result.Added), len(result.Replaced), len(result.Deleted))
+		raw, err := json.Marshal(result)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+	}(c)
+}
+
+// getSystemFirewallConfig returns the current configuration of the system
+// firewall, in json format.
+func getSystemFirewallConfig() ([]byte, error) {
+	sysfw, err := firewall.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return firewall.Deserialize(sysfw)
+}
+
+// applySystemFirewallConfig saves the configuration of the system firewall,
+// and waits for the errors of reloading it.
+func applySystemFirewallConfig(rawConfig []byte) error {
+	if err := firewall.SaveConfiguration(rawConfig); err != nil {
+		return fmt.Errorf("Error saving system firewall rules: %s", err)
+	}
+	var errors string
+	for {
+		select {
+		case fwerr := <-firewall.ErrorsChan():
+			errors = fmt.Sprint(errors, fwerr, ",")
+			if firewall.ErrChanEmpty() {
+				return fmt.Errorf("%s", errors)
+			}
+		case <-time.After(2 * time.Second):
+			return nil
+		}
+	}
+}
+
+func (c *Client) handleActionGetEbpfStatus(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	status := struct {
+		core.EbpfStatus
+		Stats *ebpf.Stats `json:"stats,omitempty"`
+	}{EbpfStatus: core.GetEbpfStatus()}
+	if ebpf.IsRunning() {
+		stats := ebpf.GetStats()
+		status.Stats = &stats
+	}
+	raw, err := json.Marshal(status)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionGetFeatures(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	raw, err := json.Marshal(features.Last())
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionGetFwObjects(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	objs, err := firewall.GetObjects()
+	if err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, err))
+		return
+	}
+	raw, err := json.Marshal(objs)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionGetDNSCache(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	raw, err := json.Marshal(dns.Cache.Dump())
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionFlushDNSCache(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	n := dns.Cache.Flush()
+	log.Info("[notification] DNS cache flushed, %d records deleted", n)
+	raw, err := json.Marshal(map[string]int{"flushed": n})
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionTuneProcessCache(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	if ntf.Data != "" {
+		// only the options sent are changed.
+		cfg := procmon.EventsCache.Config()
+		err := json.Unmarshal([]byte(ntf.Data), &cfg)
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err != nil {
+			log.Warning("[notification] invalid process cache options: %s, %s", err, ntf.Data)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+			return
+		}
+		log.Info("[notification] tuning the cache of processes: %+v", cfg)
+		procmon.EventsCache.SetConfig(cfg)
+	}
+	raw, err := json.Marshal(struct {
+		Config procmon.EventsCacheConfig `json:"config"`
+		Stats  procmon.EventsCacheStats  `json:"stats"`
+	}{procmon.EventsCache.Config(), procmon.EventsCache.Stats()})
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionGetUpdates(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		Check bool `json:"check"`
+	}
+	if ntf.Data != "" {
+		if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+			log.Warning("[notification] invalid updates options: %s, %s", err, ntf.Data)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+			return
+		}
+	}
+	if !opts.Check {
+		raw, err := json.Marshal(updater.Default.Status())
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+		return
+	}
+	// the binary of a new version may take a while to download.
+	go func() {
+		status, err := updater.Default.Check()
+		if err != nil {
+			log.Warning("[notification] error checking the updates: %s", err)
+		}
+		raw, err := json.Marshal(status)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+	}()
+}
+
+func (c *Client) handleActionGetDecisions(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		Limit int `json:"limit"`
+	}
+	if ntf.Data != "" {
+		if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+			log.Warning("[notification] invalid decisions options: %s, %s", err, ntf.Data)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+			return
+		}
+	}
+	c.RLock()
+	decisions := c.decisions
+	c.RUnlock()
+
+	raw, err := json.Marshal(decisions.List(opts.Limit))
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionPromoteDecision(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		ID     uint64      `json:"id"`
+		Action rule.Action `json:"action"`
+	}
+	if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+		log.Warning("[notification] invalid promote decision options: %s, %s", err, ntf.Data)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	c.RLock()
+	decisions := c.decisions
+	c.RUnlock()
+
+	r, err := decisions.Promote(opts.ID, opts.Action, func(r *rule.Rule) error {
+		if err := rule.Validate(r); err != nil {
+			return err
+		}
+		return c.rules.Track(changeSource(ntf), "promote decision", func() error {
+			return c.rules.Add(r, true)
+		})
+	})
+	if err != nil {
+		log.Warning("[notification] error promoting the decision %d: %s", opts.ID, err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+			newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "id", fmt.Sprint(opts.ID)))
+		return
+	}
+	log.Info("[notification] decision %d promoted to the rule %s", opts.ID, r.Name)
+	raw, err := json.Marshal(r)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionGetRulesSnapshots(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		ID uint64 `json:"id"`
+	}
+	if ntf.Data != "" {
+		if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+			log.Warning("[notification] invalid snapshots options: %s, %s", err, ntf.Data)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+			return
+		}
+	}
+	if opts.ID == 0 {
+		raw, err := json.Marshal(c.rules.Snapshots())
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+		return
+	}
+	snapshot, err := c.rules.GetSnapshot(opts.ID)
+	if err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+			newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "id", fmt.Sprint(opts.ID)))
+		return
+	}
+	raw, err := json.Marshal(snapshot)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionRollbackRules(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		ID uint64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+		log.Warning("[notification] invalid rollback options: %s, %s", err, ntf.Data)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	result, err := c.rules.Rollback(opts.ID, changeSource(ntf))
+	if err != nil {
+		log.Warning("[notification] error rolling back the rules to the snapshot %d: %s", opts.ID, err)
+		if result == nil {
+			err = newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "id", fmt.Sprint(opts.ID))
+		} else {
+			err = ruleError(err, "")
+		}
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+		return
+	}
+	log.Info("[notification] rules rolled back to the snapshot %d, added: %d, replaced: %d, deleted: %d",
+		opts.ID, len(result.Added), len(result.Replaced), len(result.Deleted))
+	raw, err := json.Marshal(result)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
+func (c *Client) handleActionTraceRules(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		rule.TraceRequest
+		Timeout string `json:"timeout"`
+	}
+	if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+		log.Warning("[notification] invalid trace options: %s, %s", err, ntf.Data)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	timeout := 60 * time.Second
+	if opts.Timeout != "" {
+		t, err := time.ParseDuration(opts.Timeout)
+		if err != nil {
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+				newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, fmt.Errorf("invalid trace timeout: %s", err), "timeout", opts.Timeout))
+			return
+		}
+		timeout = t
+	}
+	traces, cancel, err := c.rules.TraceConnections(opts.TraceRequest)
+	if err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	log.Info("[notification] tracing rules, pid: %d, path: %s, connections: %d", opts.PID, opts.ProcessPath, opts.Count)
+
+	go func() {
+		defer cancel()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for {
+			select {
+			case trace, ok := <-traces:
+				if !ok {
+					return
+				}
+				raw, err := json.Marshal(trace)
+				if c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err) != nil {
+					return
+				}
+			case <-timer.C:
+				c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+					newError(protocol.ErrorCode_ERR_TIMEOUT, fmt.Errorf("timeout tracing the connections of %d %s", opts.PID, opts.ProcessPath),
+						"pid", strconv.Itoa(opts.PID), "process_path", opts.ProcessPath))
+				return
+			}
+		}
+	}()
+}
+
 func (c *Client) handleActionTaskStart(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	var taskConf base.TaskNotification
 	err := json.Unmarshal([]byte(ntf.Data), &taskConf)
 	if err != nil {
 		log.Error("parsing TaskStart, err: %s, %s", err, ntf.Data)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
 		return
 	}
 	switch taskConf.Name {
@@ -162,7 +642,7 @@ func (c *Client) handleActionTaskStart(stream protocol.UI_NotificationsClient, n
 		pid, err := strconv.Atoi(conf["pid"].(string))
 		if err != nil {
 			log.Error("[pidmon] TaskStart.Data, PID err: %s, %v", err, taskConf)
-			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "task", taskConf.Name))
 			return
 		}
 		interval, _ := conf["interval"].(string)
@@ -187,7 +667,7 @@ func (c *Client) handleActionTaskStop(stream protocol.UI_NotificationsClient, nt
 	err := json.Unmarshal([]byte(ntf.Data), &taskConf)
 	if err != nil {
 		log.Error("parsing TaskStop, err: %s, %s", err, ntf.Data)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", fmt.Errorf("Error stopping task: %s", ntf.Data))
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, fmt.Errorf("Error stopping task: %s", ntf.Data)))
 		return
 	}
 	switch taskConf.Name {
@@ -200,7 +680,7 @@ func (c *Client) handleActionTaskStop(stream protocol.UI_NotificationsClient, nt
 		pid, err := strconv.Atoi(conf["pid"].(string))
 		if err != nil {
 			log.Error("TaskStop.Data, err: %s, %s, %v+, %q", err, ntf.Data, taskConf.Data, taskConf.Data)
-			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+			c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "task", taskConf.Name))
 			return
 		}
 		TaskMgr.RemoveTask(fmt.Sprint(taskConf.Name, "-", pid))
@@ -226,15 +706,16 @@ func (c *Client) handleActionEnableInterception(stream protocol.UI_Notifications
 	log.Info("[notification] starting interception")
 	if err := monitor.ReconfigureMonitorMethod(c.config.ProcMonitorMethod, c.config.Ebpf, c.config.Audit); err != nil && err.What > monitor.NoError {
 		log.Warning("[notification] error enabling monitor (%s): %s", c.config.ProcMonitorMethod, err.Msg)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err.Msg)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_PROC_MONITOR, err.Msg, "method", c.config.ProcMonitorMethod))
 		return
 	}
 	if err := firewall.EnableInterception(); err != nil {
 		log.Warning("[notification] firewall.EnableInterception() error: %s", err)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, err))
 		return
 	}
 	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", nil)
+	c.SendInterceptionChanged(true, changeSource(ntf), "")
 }
 
 func (c *Client) handleActionDisableInterception(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
@@ -242,29 +723,79 @@ func (c *Client) handleActionDisableInterception(stream protocol.UI_Notification
 	monitor.End()
 	if err := firewall.DisableInterception(); err != nil {
 		log.Warning("firewall.DisableInterception() error: %s", err)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, err))
+		return
+	}
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", nil)
+	c.SendInterceptionChanged(false, changeSource(ntf), "")
+}
+
+func (c *Client) handleActionEnablePanicMode(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	log.Info("[notification] enabling panic mode")
+	if err := c.EnablePanicMode(); err != nil {
+		log.Warning("[notification] error enabling panic mode: %s", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, err))
+		return
+	}
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", nil)
+}
+
+func (c *Client) handleActionDisablePanicMode(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	log.Info("[notification] disabling panic mode")
+	if err := c.DisablePanicMode(); err != nil {
+		log.Warning("[notification] error disabling panic mode: %s", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, err))
 		return
 	}
 	c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", nil)
 }
 
+func (c *Client) handleActionBlockApp(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
+	var opts struct {
+		ProcessPath string `json:"process_path"`
+		Duration    string `json:"duration"`
+	}
+	if err := json.Unmarshal([]byte(ntf.Data), &opts); err != nil {
+		log.Warning("[notification] invalid block app options: %s, %s", err, ntf.Data)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err))
+		return
+	}
+	duration, err := time.ParseDuration(opts.Duration)
+	if err != nil {
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+			newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, fmt.Errorf("invalid duration: %s", err), "duration", opts.Duration))
+		return
+	}
+	log.Info("[notification] blocking %s for %s", opts.ProcessPath, duration)
+	r, err := c.BlockApp(opts.ProcessPath, duration)
+	if err != nil {
+		log.Warning("[notification] error blocking %s: %s", opts.ProcessPath, err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+			newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "process_path", opts.ProcessPath))
+		return
+	}
+	raw, err := json.Marshal(r)
+	c.sendNotificationReply(stream, ntf.Type, ntf.Id, string(raw), err)
+}
+
 func (c *Client) handleActionReloadFw(stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	log.Info("[notification] reloading firewall")
 
 	sysfw, err := firewall.Deserialize(ntf.SysFirewall)
 	if err != nil {
 		log.Warning("firewall.Deserialize() error: %s", err)
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", fmt.Errorf("Error reloading firewall, invalid rules"))
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, fmt.Errorf("Error reloading firewall, invalid rules")))
 		return
 	}
 	if err := firewall.SaveConfiguration(sysfw); err != nil {
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", fmt.Errorf("Error saving system firewall rules: %s", err))
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_SAVE, fmt.Errorf("Error saving system firewall rules: %s", err)))
 		return
 	}
 	// TODO:
 	// - add new API endpoints to delete, add or change rules atomically.
 	// - a global goroutine where errors can be sent to the server (GUI).
 	go func(c *Client) {
+		defer c.sendFirewallChanged(changeSource(ntf), c.GetFirewallType())
 		var errors string
 		for {
 			select {
@@ -283,7 +814,7 @@ func (c *Client) handleActionReloadFw(stream protocol.UI_NotificationsClient, nt
 			}
 		}
 	ExitWithError:
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", fmt.Errorf("%s", errors))
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_FIREWALL, fmt.Errorf("%s", errors)))
 	Exit:
 	}(c)
 
@@ -309,6 +840,15 @@ func (c *Client) handleNotification(stream protocol.UI_NotificationsClient, ntf
 	case ntf.Type == protocol.Action_RELOAD_FW_RULES:
 		c.handleActionReloadFw(stream, ntf)
 
+	case ntf.Type == protocol.Action_ENABLE_PANIC_MODE:
+		c.handleActionEnablePanicMode(stream, ntf)
+
+	case ntf.Type == protocol.Action_DISABLE_PANIC_MODE:
+		c.handleActionDisablePanicMode(stream, ntf)
+
+	case ntf.Type == protocol.Action_BLOCK_APP:
+		c.handleActionBlockApp(stream, ntf)
+
 	// ENABLE_RULE just replaces the rule on disk
 	case ntf.Type == protocol.Action_ENABLE_RULE:
 		c.handleActionEnableRule(stream, ntf)
@@ -322,6 +862,60 @@ func (c *Client) handleNotification(stream protocol.UI_NotificationsClient, ntf
 	// CHANGE_RULE can add() or replace() an existing rule.
 	case ntf.Type == protocol.Action_CHANGE_RULE:
 		c.handleActionChangeRule(stream, ntf)
+
+	case ntf.Type == protocol.Action_REORDER_RULES:
+		c.handleActionReorderRules(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_RULES_STATS:
+		c.handleActionGetRulesStats(stream, ntf)
+
+	case ntf.Type == protocol.Action_EXPORT_RULES:
+		c.handleActionExportRules(stream, ntf)
+
+	case ntf.Type == protocol.Action_IMPORT_RULES:
+		c.handleActionImportRules(stream, ntf)
+
+	case ntf.Type == protocol.Action_EXPORT_BUNDLE:
+		c.handleActionExportBundle(stream, ntf)
+
+	case ntf.Type == protocol.Action_IMPORT_BUNDLE:
+		c.handleActionImportBundle(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_EBPF_STATUS:
+		c.handleActionGetEbpfStatus(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_FEATURES:
+		c.handleActionGetFeatures(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_DECISIONS:
+		c.handleActionGetDecisions(stream, ntf)
+
+	case ntf.Type == protocol.Action_PROMOTE_DECISION:
+		c.handleActionPromoteDecision(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_FW_OBJECTS:
+		c.handleActionGetFwObjects(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_DNS_CACHE:
+		c.handleActionGetDNSCache(stream, ntf)
+
+	case ntf.Type == protocol.Action_FLUSH_DNS_CACHE:
+		c.handleActionFlushDNSCache(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_UPDATES:
+		c.handleActionGetUpdates(stream, ntf)
+
+	case ntf.Type == protocol.Action_TRACE_RULES:
+		c.handleActionTraceRules(stream, ntf)
+
+	case ntf.Type == protocol.Action_GET_RULES_SNAPSHOTS:
+		c.handleActionGetRulesSnapshots(stream, ntf)
+
+	case ntf.Type == protocol.Action_ROLLBACK_RULES:
+		c.handleActionRollbackRules(stream, ntf)
+
+	case ntf.Type == protocol.Action_TUNE_PROCESS_CACHE:
+		c.handleActionTuneProcessCache(stream, ntf)
 	}
 }
 
@@ -330,8 +924,9 @@ func (c *Client) sendNotificationReply(stream protocol.UI_NotificationsClient, n
 	if err != nil {
 		reply.Code = protocol.NotificationReplyCode_ERROR
 		reply.Data = fmt.Sprint(err)
+		reply.ErrorCode, reply.ErrorDetails = errorCode(err)
 	}
-	if err := stream.Send(reply); err != nil {
+	if err := c.send(stream, reply); err != nil {
 		if err == io.EOF {
 			log.Trace("[Notifications] sendNotificationReply, stream channel closed")
 			return nil
@@ -376,21 +971,21 @@ func (c *Client) listenForNotifications() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var err error
 	// open the stream channel
 	streamReply := &protocol.NotificationReply{Id: 0, Code: protocol.NotificationReplyCode_OK}
-	c.Lock()
-	c.streamNotifications, err = c.client.Notifications(ctx)
-	c.Unlock()
+	stream, err := c.client.Notifications(ctx)
 	if err != nil {
 		log.Error("establishing notifications channel %s", err)
 		return
 	}
-	// send the first notification
-	if err := c.streamNotifications.Send(streamReply); err != nil {
+	// send the first notification, before any event.
+	if err := stream.Send(streamReply); err != nil {
 		log.Error("sending notification HELLO %s", err)
 		return
 	}
+	c.Lock()
+	c.streamNotifications = stream
+	c.Unlock()
 	log.Info("Start receiving notifications")
 	for {
 		select {
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/evilsocket/opensnitch/daemon/core"
 	"github.com/evilsocket/opensnitch/daemon/log"
@@ -91,7 +92,7 @@ func (c *Client) monitorSockets(config interface{}, stream protocol.UI_Notificat
 	sockMonTask, err := socketsmonitor.New(socketsmonitor.Name, config, true)
 	sockMonTask.SetID(ntf.Id)
 	if err != nil {
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", err)
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", newError(protocol.ErrorCode_ERR_INVALID_ARGUMENT, err, "task", socketsmonitor.Name))
 		return
 	}
 	_, err = TaskMgr.AddTask(sockMonTask.Name, sockMonTask)
@@ -113,7 +114,8 @@ func (c *Client) monitorNode(node, interval string, stream protocol.UI_Notificat
 
 func (c *Client) monitorProcessDetails(pid int, interval string, stream protocol.UI_NotificationsClient, ntf *protocol.Notification) {
 	if !core.Exists(fmt.Sprint("/proc/", pid)) {
-		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "", fmt.Errorf("The process is no longer running"))
+		c.sendNotificationReply(stream, ntf.Type, ntf.Id, "",
+			newError(protocol.ErrorCode_ERR_NOT_FOUND, fmt.Errorf("The process is no longer running"), "pid", strconv.Itoa(pid)))
 		return
 	}
 
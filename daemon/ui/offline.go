@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/netlink"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// OfflineRulePrefix is the prefix of the names of the rules that block
+// an application.
+const OfflineRulePrefix = "offline-"
+
+// BlockApp denies the connections of a binary and of its children for the
+// given time, with a temporary rule evaluated before the rest, and closes the
+// connections they have established.
+func (c *Client) BlockApp(path string, duration time.Duration) (*rule.Rule, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("invalid process path, it must be absolute: %s", path)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("invalid duration: %s", duration)
+	}
+	op, err := rule.NewOperator(rule.Simple, false, rule.OpProcessTreePath, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := rule.Create(
+		OfflineRulePrefix+filepath.Base(path),
+		fmt.Sprintf("%s offline for %s", path, duration),
+		true, true, false, rule.Deny, rule.Duration(duration.String()), op)
+	r.Priority = math.MinInt32
+	if err := c.rules.Replace(r, false); err != nil {
+		return nil, err
+	}
+
+	inodes := make(map[uint32]bool)
+	for _, pid := range procmon.FindTree(path) {
+		for _, inode := range procmon.SocketInodes(pid) {
+			inodes[inode] = true
+		}
+	}
+	closed := 0
+	if len(inodes) > 0 {
+		closed = netlink.CloseSockets(inodes)
+	}
+
+	msg := i18n.New(i18n.AppOffline, "path", path, "duration", duration, "closed", closed)
+	log.Important("[offline] %s", msg)
+	c.SendWarningAlert(msg)
+	return r, nil
+}
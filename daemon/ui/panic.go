@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"net"
+
+	"github.com/evilsocket/opensnitch/daemon/firewall"
+	"github.com/evilsocket/opensnitch/daemon/i18n"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// EnablePanicMode drops the new outbound connections, except the ones to the
+// loopback interface, to the UI server, and to the destinations configured
+// in FwOptions.PanicAllow.
+func (c *Client) EnablePanicMode() error {
+	allowed, err := c.panicAllowed()
+	if err != nil {
+		return err
+	}
+	if err := firewall.EnablePanicMode(allowed); err != nil {
+		return err
+	}
+	msg := i18n.New(i18n.PanicModeEnabled)
+	log.Important("[panic] %s, allowed: %v", msg, allowed)
+	c.SendWarningAlert(msg)
+	c.sendFirewallChanged(rule.SourceDaemon, c.GetFirewallType())
+	return nil
+}
+
+// DisablePanicMode intercepts again the new outbound connections.
+func (c *Client) DisablePanicMode() error {
+	if err := firewall.DisablePanicMode(); err != nil {
+		return err
+	}
+	msg := i18n.New(i18n.PanicModeDisabled)
+	log.Important("[panic] %s", msg)
+	c.SendInfoAlert(msg)
+	c.sendFirewallChanged(rule.SourceDaemon, c.GetFirewallType())
+	return nil
+}
+
+// panicAllowed returns the destinations allowed in panic mode.
+func (c *Client) panicAllowed() ([]*net.IPNet, error) {
+	c.RLock()
+	allow := c.config.FwOptions.PanicAllow
+	server := c.socketPath
+	isUnixSocket := c.isUnixSocket
+	c.RUnlock()
+
+	allowed, err := firewall.ParseDestinations(allow)
+	if err != nil {
+		return nil, err
+	}
+	if isUnixSocket {
+		return allowed, nil
+	}
+	// the UI may be in other host.
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		log.Warning("[panic] invalid UI server address %s: %s", server, err)
+		return allowed, nil
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = net.LookupIP(host); err != nil {
+			log.Warning("[panic] unable to resolve the UI server %s: %s", host, err)
+		}
+	}
+	for _, ip := range ips {
+		allowed = append(allowed, firewall.HostNet(ip))
+	}
+	return allowed, nil
+}
@@ -0,0 +1,319 @@
+package prompt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+// Outcomes of the prompts.
+const (
+	OutcomeAnswered = "answered"
+	OutcomeTimeout  = "timeout"
+	OutcomeError    = "error"
+)
+
+// where the connections have been prompted.
+const (
+	PromptGUI = "gui"
+)
+
+var defaultMaxDecisions = 1000
+
+// Decision is a connection prompted to the user, and the answer.
+type Decision struct {
+	Time       time.Time            `json:"time"`
+	Connection *protocol.Connection `json:"connection"`
+	// rule answered, nil if the prompt wasn't answered.
+	Rule *protocol.Rule `json:"rule,omitempty"`
+	// gui, or the terminal where the connection was prompted.
+	Prompt  string `json:"prompt"`
+	Outcome string `json:"outcome"`
+	// action applied to the connection when the prompt wasn't answered.
+	DefaultAction string `json:"default_action,omitempty"`
+	// name of the permanent rule created from the decision.
+	Promoted string `json:"promoted,omitempty"`
+	ID       uint64 `json:"id"`
+}
+
+// Decisions is the log of the connections prompted and their answers
+// (including the ones not answered in time), so the one-time decisions can be
+// reviewed later, and converted to permanent rules.
+// If a path is configured, the decisions are appended to it as JSON lines,
+// and read back when the daemon starts. The lines are encrypted if the
+// encryption at rest is enabled.
+type Decisions struct {
+	path string
+	list []*Decision
+	max  int
+	// lines written to the file, to compact it when it grows too much.
+	lines  int
+	nextID uint64
+
+	sync.RWMutex
+}
+
+// NewDecisions creates the log of decisions, keeping up to max decisions
+// (1000 by default), and reads the ones saved to path, if any.
+func NewDecisions(path string, max int) *Decisions {
+	if max <= 0 {
+		max = defaultMaxDecisions
+	}
+	d := &Decisions{
+		path:   path,
+		max:    max,
+		list:   make([]*Decision, 0),
+		nextID: 1,
+	}
+	if path != "" {
+		plain, err := d.load()
+		if err != nil && !os.IsNotExist(err) {
+			log.Warning("[prompt] unable to read the decisions from %s: %s", path, err)
+		}
+		// the decisions written before enabling the encryption.
+		if plain > 0 && core.StorageEncrypted() {
+			log.Info("[prompt] encrypting the decisions of %s", path)
+			d.compact()
+		}
+	}
+	return d
+}
+
+// load reads the decisions of the file, and returns the number of lines not
+// encrypted.
+func (d *Decisions) load() (plain int, err error) {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line, sealed, err := core.UnsealLine(scanner.Bytes())
+		if err != nil {
+			log.Debug("[prompt] invalid decision in %s: %s", d.path, err)
+			continue
+		}
+		if !sealed {
+			plain++
+		}
+		var dec Decision
+		if err := json.Unmarshal(line, &dec); err != nil {
+			log.Debug("[prompt] invalid decision in %s: %s", d.path, err)
+			continue
+		}
+		d.lines++
+		// promoted decisions are appended again with the name of the rule.
+		if old := d.find(dec.ID); old != nil {
+			*old = dec
+			continue
+		}
+		d.append(&dec)
+		if dec.ID >= d.nextID {
+			d.nextID = dec.ID + 1
+		}
+	}
+	return plain, scanner.Err()
+}
+
+func (d *Decisions) append(dec *Decision) {
+	d.list = append(d.list, dec)
+	if len(d.list) > d.max {
+		d.list = d.list[len(d.list)-d.max:]
+	}
+}
+
+func (d *Decisions) find(id uint64) *Decision {
+	for _, dec := range d.list {
+		if dec.ID == id {
+			return dec
+		}
+	}
+	return nil
+}
+
+// Record adds a decision to the log.
+func (d *Decisions) Record(dec *Decision) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	dec.ID = d.nextID
+	d.nextID++
+	d.append(dec)
+	d.save(dec)
+}
+
+// save appends a decision to the file, or rewrites it if it contains too
+// many old decisions.
+func (d *Decisions) save(dec *Decision) {
+	if d.path == "" {
+		return
+	}
+	if d.lines >= 2*d.max {
+		d.compact()
+		return
+	}
+	raw, err := json.Marshal(dec)
+	if err == nil {
+		raw, err = core.SealLine(raw)
+	}
+	if err != nil {
+		log.Warning("[prompt] error saving decision %d: %s", dec.ID, err)
+		return
+	}
+	f, err := os.OpenFile(d.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Warning("[prompt] error saving decision %d: %s", dec.ID, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		log.Warning("[prompt] error saving decision %d: %s", dec.ID, err)
+		return
+	}
+	d.lines++
+}
+
+func (d *Decisions) compact() {
+	tmp := d.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Warning("[prompt] error saving the decisions: %s", err)
+		return
+	}
+	w := bufio.NewWriter(f)
+	for _, dec := range d.list {
+		raw, err := json.Marshal(dec)
+		if err == nil {
+			raw, err = core.SealLine(raw)
+		}
+		if err != nil {
+			log.Warning("[prompt] error saving decision %d: %s", dec.ID, err)
+			continue
+		}
+		w.Write(append(raw, '\n'))
+	}
+	w.Flush()
+	f.Close()
+	if err := os.Rename(tmp, d.path); err != nil {
+		log.Warning("[prompt] error saving the decisions: %s", err)
+		return
+	}
+	d.lines = len(d.list)
+}
+
+// List returns the last decisions, the newest first. 0 returns all of them.
+func (d *Decisions) List(limit int) []Decision {
+	if d == nil {
+		return []Decision{}
+	}
+	d.RLock()
+	defer d.RUnlock()
+
+	if limit <= 0 || limit > len(d.list) {
+		limit = len(d.list)
+	}
+	list := make([]Decision, 0, limit)
+	for i := len(d.list) - 1; i >= len(d.list)-limit; i-- {
+		list = append(list, *d.list[i])
+	}
+	return list
+}
+
+// Promote converts a decision into a permanent rule, with the action
+// answered, or with the given action if it's not empty. The rule of the
+// decisions not answered is created from the connection: binary, destination,
+// port and protocol.
+// The decision is marked as promoted once the rule has been added with add.
+func (d *Decisions) Promote(id uint64, action rule.Action, add func(*rule.Rule) error) (*rule.Rule, error) {
+	if d == nil {
+		return nil, fmt.Errorf("decisions not recorded")
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	dec := d.find(id)
+	if dec == nil {
+		return nil, fmt.Errorf("decision %d not found", id)
+	}
+	r, err := dec.permanentRule(action)
+	if err != nil {
+		return nil, err
+	}
+	if err := add(r); err != nil {
+		return nil, err
+	}
+	dec.Promoted = r.Name
+	d.save(dec)
+	return r, nil
+}
+
+func (dec *Decision) permanentRule(action rule.Action) (*rule.Rule, error) {
+	if dec.Rule != nil {
+		r, err := rule.Deserialize(dec.Rule)
+		if err != nil {
+			return nil, err
+		}
+		if action != "" {
+			r.Action = action
+		}
+		r.Duration = rule.Always
+		data := r.Operator.Data
+		if data == "" {
+			data = dec.summary()
+		}
+		r.Name = ruleName(r.Action, r.Duration, data)
+		return r, nil
+	}
+
+	if action == "" {
+		return nil, fmt.Errorf("decision %d not answered, the action of the rule is required", dec.ID)
+	}
+	con := dec.Connection
+	if con == nil || con.ProcessPath == "" {
+		return nil, fmt.Errorf("decision %d without process", dec.ID)
+	}
+	dstOperand, dst := rule.OpDstIP, con.DstIp
+	if con.DstHost != "" {
+		dstOperand, dst = rule.OpDstHost, con.DstHost
+	}
+	op, err := rule.NewOperator(rule.List, false, rule.OpList, "", []rule.Operator{
+		{Type: rule.Simple, Operand: rule.OpProcessPath, Data: con.ProcessPath},
+		{Type: rule.Simple, Operand: dstOperand, Data: dst},
+		{Type: rule.Simple, Operand: rule.OpDstPort, Data: strconv.FormatUint(uint64(con.DstPort), 10)},
+		{Type: rule.Simple, Operand: rule.OpProto, Data: con.Protocol},
+	})
+	if err != nil {
+		return nil, err
+	}
+	name := ruleName(action, rule.Always, dec.summary())
+	return rule.Create(name, fmt.Sprintf("from the decision %d (%s)", dec.ID, dec.Outcome),
+		true, false, false, action, rule.Always, op), nil
+}
+
+// summary returns the binary, destination and port of the connection.
+func (dec *Decision) summary() string {
+	con := dec.Connection
+	if con == nil {
+		return fmt.Sprint(dec.ID)
+	}
+	dst := con.DstHost
+	if dst == "" {
+		dst = con.DstIp
+	}
+	return fmt.Sprintf("%s %s %d", con.ProcessPath, dst, con.DstPort)
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+func TestDecisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.json")
+	d := NewDecisions(path, 2)
+
+	op, _ := rule.NewOperator(rule.Simple, false, rule.OpProcessPath, "/usr/bin/curl", nil)
+	answered := rule.Create("allow-once-simple-usr-bin-curl", "", true, false, false, rule.Allow, rule.Once, op)
+
+	d.Record(&Decision{Connection: newConn().Serialize(), Prompt: PromptGUI, Outcome: OutcomeAnswered, Rule: answered.Serialize()})
+	d.Record(&Decision{Connection: newConn().Serialize(), Prompt: "/dev/tty12", Outcome: OutcomeTimeout, DefaultAction: string(rule.Deny)})
+	d.Record(&Decision{Connection: newConn().Serialize(), Prompt: "/dev/tty12", Outcome: OutcomeTimeout, DefaultAction: string(rule.Deny)})
+
+	list := d.List(0)
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 2 {
+		t.Fatalf("unexpected decisions, the newest 2 expected: %+v", list)
+	}
+	if list := d.List(1); len(list) != 1 || list[0].ID != 3 {
+		t.Errorf("limit not applied: %+v", list)
+	}
+
+	var added []*rule.Rule
+	add := func(r *rule.Rule) error {
+		added = append(added, r)
+		return nil
+	}
+	if _, err := d.Promote(1, rule.Allow, add); err == nil {
+		t.Error("decision 1 should have been discarded")
+	}
+	if _, err := d.Promote(3, "", add); err == nil {
+		t.Error("decisions not answered can't be promoted without action")
+	}
+	r, err := d.Promote(3, rule.Allow, add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || r.Duration != rule.Always || r.Action != rule.Allow ||
+		r.Operator.Type != rule.List || len(r.Operator.List) != 4 {
+		t.Errorf("unexpected rule: %+v", r)
+	}
+
+	// the decisions and the promotions are read back from disk.
+	d = NewDecisions(path, 2)
+	list = d.List(0)
+	if len(list) != 2 || list[0].ID != 3 || list[0].Promoted != r.Name {
+		t.Fatalf("decisions not restored: %+v", list)
+	}
+	d.Record(&Decision{Connection: newConn().Serialize(), Prompt: PromptGUI, Outcome: OutcomeAnswered, Rule: answered.Serialize()})
+	r, err = d.Promote(4, "", add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Duration != rule.Always || r.Action != rule.Allow || r.Name != "allow-always-simple-usr-bin-curl" {
+		t.Errorf("unexpected rule from the answer: %+v", r)
+	}
+}
+
+func TestDecisionsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "decisions.json")
+	d := NewDecisions(path, 10)
+	d.Record(&Decision{Connection: newConn().Serialize(), Prompt: "/dev/tty12", Outcome: OutcomeTimeout, DefaultAction: string(rule.Deny)})
+
+	keyFile := filepath.Join(dir, "key")
+	ioutil.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef"), 0600)
+	if err := core.LoadStorageKey(keyFile); err != nil {
+		t.Fatal("LoadStorageKey() error:", err)
+	}
+
+	// the decisions recorded before enabling the encryption are encrypted
+	// when they're loaded.
+	d = NewDecisions(path, 10)
+	d.Record(&Decision{Connection: newConn().Serialize(), Prompt: "/dev/tty12", Outcome: OutcomeTimeout, DefaultAction: string(rule.Deny)})
+	raw, _ := ioutil.ReadFile(path)
+	if lines := bytes.Count(raw, []byte("\n")); lines != 2 || bytes.Contains(raw, []byte("tty12")) {
+		t.Fatalf("decisions not encrypted: %s", raw)
+	}
+	if list := NewDecisions(path, 10).List(0); len(list) != 2 || list[0].ID != 2 {
+		t.Errorf("encrypted decisions not restored: %+v", list)
+	}
+}
@@ -0,0 +1,64 @@
+package prompt
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// verifyOrigin returns the origin of the binary of a process: packaged,
+// modified, unpackaged, or "" if it can't be determined.
+var verifyOrigin = procmon.Packages.Verify
+
+// OriginDefaults are the default actions of the connections by the origin of
+// their binaries (packaged, modified, unpackaged), as verified against the
+// package manager (Rules.VerifyPackages).
+// The connections of an origin with an action other than ask are not
+// prompted, the action is applied to them directly. For example, to prompt
+// only for the binaries not installed by a package:
+//
+//	{"packaged": "allow", "modified": "ask", "unpackaged": "ask"}
+//
+// If the origin of a binary can't be determined, its connections are prompted.
+type OriginDefaults struct {
+	actions map[string]rule.Action
+}
+
+// NewOriginDefaults validates the default actions, by origin of the binaries.
+func NewOriginDefaults(cfg map[string]string) (*OriginDefaults, error) {
+	o := &OriginDefaults{actions: make(map[string]rule.Action, len(cfg))}
+	for origin, action := range cfg {
+		switch origin {
+		case procmon.PkgStatusPackaged, procmon.PkgStatusModified, procmon.PkgStatusUnpackaged:
+		default:
+			return nil, fmt.Errorf("origin defaults: invalid origin: %s", origin)
+		}
+		switch rule.Action(action) {
+		case rule.Allow, rule.Deny, rule.Reject, ActionAsk:
+		default:
+			return nil, fmt.Errorf("origin defaults %s: invalid action: %s", origin, action)
+		}
+		if rule.Action(action) != ActionAsk {
+			o.actions[origin] = rule.Action(action)
+		}
+	}
+	return o, nil
+}
+
+// Enabled returns true if the connections of any origin are not prompted.
+func (o *OriginDefaults) Enabled() bool {
+	return o != nil && len(o.actions) > 0
+}
+
+// For returns the default action of the origin of the binary of a connection,
+// and false if it must be prompted as the rest of connections.
+// The binary is only verified if there's any action configured.
+func (o *OriginDefaults) For(con *conman.Connection) (rule.Action, bool) {
+	if !o.Enabled() || con == nil || con.Process == nil {
+		return "", false
+	}
+	action, found := o.actions[verifyOrigin(con.Process)]
+	return action, found
+}
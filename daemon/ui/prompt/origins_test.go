@@ -0,0 +1,77 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestOriginDefaults(t *testing.T) {
+	origins := map[string]string{
+		"/usr/bin/curl":         procmon.PkgStatusPackaged,
+		"/usr/bin/wget":         procmon.PkgStatusModified,
+		"/home/user/bin/script": procmon.PkgStatusUnpackaged,
+		"/opt/app/app":          "",
+	}
+	verified := 0
+	oldVerify := verifyOrigin
+	verifyOrigin = func(p *procmon.Process) string {
+		verified++
+		return origins[p.Path]
+	}
+	defer func() { verifyOrigin = oldVerify }()
+
+	o, err := NewOriginDefaults(map[string]string{
+		"packaged":   "allow",
+		"modified":   "deny",
+		"unpackaged": "ask",
+	})
+	if err != nil {
+		t.Fatal("NewOriginDefaults() error:", err)
+	}
+	tests := []struct {
+		path   string
+		action rule.Action
+		found  bool
+	}{
+		{"/usr/bin/curl", rule.Allow, true},
+		{"/usr/bin/wget", rule.Deny, true},
+		{"/home/user/bin/script", "", false},
+		{"/opt/app/app", "", false},
+	}
+	for _, test := range tests {
+		con := conman.Deserialize(&protocol.Connection{Protocol: "tcp", DstIp: "1.1.1.1", ProcessPath: test.path})
+		action, found := o.For(con)
+		if action != test.action || found != test.found {
+			t.Errorf("%s: unexpected default action: %s, %v", test.path, action, found)
+		}
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		verified = 0
+		con := conman.Deserialize(&protocol.Connection{Protocol: "tcp", DstIp: "1.1.1.1", ProcessPath: "/usr/bin/curl"})
+		o, _ := NewOriginDefaults(map[string]string{"packaged": "ask"})
+		if _, found := o.For(con); found {
+			t.Error("no default action should apply")
+		}
+		var nilDefaults *OriginDefaults
+		if _, found := nilDefaults.For(con); found {
+			t.Error("no default action should apply to nil defaults")
+		}
+		if verified != 0 {
+			t.Error("binaries verified without any action configured:", verified)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewOriginDefaults(map[string]string{"signed": "allow"}); err == nil {
+			t.Error("invalid origin should fail")
+		}
+		if _, err := NewOriginDefaults(map[string]string{"packaged": "accept"}); err == nil {
+			t.Error("invalid action should fail")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package prompt
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// Policy configures the prompts of the connections to a class of
+// destinations: the time to wait for an answer, and the action to apply if
+// they're not answered in time, or if they can't be prompted.
+type Policy struct {
+	// Destination is a network alias (LAN, MULTICAST, ...), a network in
+	// CIDR notation or an IP. Empty to match any destination.
+	Destination string `json:"Destination"`
+	Timeout     string `json:"Timeout"`
+	// Action is allow, deny or reject.
+	Action string `json:"Action"`
+}
+
+type policy struct {
+	alias   string
+	network *net.IPNet
+	timeout time.Duration
+	action  rule.Action
+}
+
+// Policies are the policies of the classes of destinations, evaluated in
+// order: the first one that matches the destination of a connection applies.
+type Policies struct {
+	list []policy
+}
+
+// NewPolicies validates the policies of the classes of destinations.
+// The networks aliases are resolved when the connections are evaluated,
+// because they may be loaded later.
+func NewPolicies(cfg []Policy) (*Policies, error) {
+	p := &Policies{list: make([]policy, 0, len(cfg))}
+	for _, c := range cfg {
+		pol := policy{action: rule.Action(c.Action)}
+		switch pol.action {
+		case rule.Allow, rule.Deny, rule.Reject:
+		default:
+			return nil, fmt.Errorf("prompt policy %s: invalid action: %s", c.Destination, c.Action)
+		}
+		tm, err := time.ParseDuration(c.Timeout)
+		if err != nil || tm <= 0 {
+			return nil, fmt.Errorf("prompt policy %s: invalid timeout: %s", c.Destination, c.Timeout)
+		}
+		pol.timeout = tm
+
+		switch {
+		case c.Destination == "":
+		case strings.Contains(c.Destination, "/"):
+			if _, pol.network, err = net.ParseCIDR(c.Destination); err != nil {
+				return nil, fmt.Errorf("prompt policy %s: %s", c.Destination, err)
+			}
+		case net.ParseIP(c.Destination) != nil:
+			ip := net.ParseIP(c.Destination)
+			pol.network = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+		default:
+			pol.alias = c.Destination
+		}
+		p.list = append(p.list, pol)
+	}
+	return p, nil
+}
+
+// For returns the timeout and the action of the prompts of the connections
+// to a destination, and false if no policy applies to it.
+func (p *Policies) For(ip net.IP) (time.Duration, rule.Action, bool) {
+	if p == nil || ip == nil {
+		return 0, "", false
+	}
+	for _, pol := range p.list {
+		if pol.matches(ip) {
+			return pol.timeout, pol.action, true
+		}
+	}
+	return 0, "", false
+}
+
+func (pol *policy) matches(ip net.IP) bool {
+	switch {
+	case pol.network != nil:
+		return pol.network.Contains(ip)
+	case pol.alias != "":
+		for _, n := range rule.AliasIPCache[pol.alias] {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
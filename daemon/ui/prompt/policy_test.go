@@ -0,0 +1,58 @@
+package prompt
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+func TestPolicies(t *testing.T) {
+	_, lan, _ := net.ParseCIDR("192.168.0.0/16")
+	rule.AliasIPCache["TEST-LAN"] = []*net.IPNet{lan}
+	defer delete(rule.AliasIPCache, "TEST-LAN")
+
+	p, err := NewPolicies([]Policy{
+		{Destination: "TEST-LAN", Timeout: "5s", Action: "allow"},
+		{Destination: "9.9.9.9", Timeout: "30s", Action: "allow"},
+		{Destination: "", Timeout: "15s", Action: "deny"},
+	})
+	if err != nil {
+		t.Fatal("NewPolicies() error:", err)
+	}
+	tests := []struct {
+		ip      string
+		timeout time.Duration
+		action  rule.Action
+	}{
+		{"192.168.1.1", 5 * time.Second, rule.Allow},
+		{"9.9.9.9", 30 * time.Second, rule.Allow},
+		{"1.1.1.1", 15 * time.Second, rule.Deny},
+	}
+	for _, test := range tests {
+		tm, action, found := p.For(net.ParseIP(test.ip))
+		if !found || tm != test.timeout || action != test.action {
+			t.Errorf("%s: invalid policy: %s, %s, %v", test.ip, tm, action, found)
+		}
+	}
+
+	p, _ = NewPolicies([]Policy{{Destination: "10.0.0.0/8", Timeout: "5s", Action: "reject"}})
+	if _, _, found := p.For(net.ParseIP("1.1.1.1")); found {
+		t.Error("policy applied to a destination of another class")
+	}
+	if _, _, found := (*Policies)(nil).For(net.ParseIP("1.1.1.1")); found {
+		t.Error("policy found without policies")
+	}
+
+	for _, invalid := range []Policy{
+		{Timeout: "5s", Action: "audit"},
+		{Timeout: "5x", Action: "allow"},
+		{Timeout: "0s", Action: "allow"},
+		{Destination: "10.0.0.0/33", Timeout: "5s", Action: "allow"},
+	} {
+		if _, err := NewPolicies([]Policy{invalid}); err == nil {
+			t.Errorf("invalid policy accepted: %+v", invalid)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// Classes of protocols of the connections with their own default action.
+const (
+	ClassTCP       = "tcp"
+	ClassUDP       = "udp"
+	ClassICMP      = "icmp"
+	ClassIGMP      = "igmp"
+	ClassMulticast = "multicast"
+	ClassBroadcast = "broadcast"
+)
+
+// ActionAsk is the default action of the classes of protocols whose
+// connections are prompted as the rest of connections.
+const ActionAsk = rule.Action("ask")
+
+// ProtocolDefaults are the default actions of the classes of protocols.
+// The connections of a class with an action other than ask are not prompted,
+// the action is applied to them directly.
+//
+// The multicast and broadcast destinations take precedence over the
+// protocol of the connections.
+type ProtocolDefaults struct {
+	actions map[string]rule.Action
+}
+
+// NewProtocolDefaults validates the default actions, by class of protocols:
+//
+//	{"multicast": "allow", "icmp": "deny", "tcp": "ask"}
+func NewProtocolDefaults(cfg map[string]string) (*ProtocolDefaults, error) {
+	p := &ProtocolDefaults{actions: make(map[string]rule.Action, len(cfg))}
+	for class, action := range cfg {
+		switch class {
+		case ClassTCP, ClassUDP, ClassICMP, ClassIGMP, ClassMulticast, ClassBroadcast:
+		default:
+			return nil, fmt.Errorf("protocol defaults: invalid class: %s", class)
+		}
+		switch rule.Action(action) {
+		case rule.Allow, rule.Deny, rule.Reject, ActionAsk:
+		default:
+			return nil, fmt.Errorf("protocol defaults %s: invalid action: %s", class, action)
+		}
+		p.actions[class] = rule.Action(action)
+	}
+	return p, nil
+}
+
+// For returns the default action of the class of protocols of a connection,
+// and false if it must be prompted as the rest of connections.
+func (p *ProtocolDefaults) For(con *conman.Connection) (rule.Action, bool) {
+	if p == nil || con == nil || len(p.actions) == 0 {
+		return "", false
+	}
+	action, found := p.actions[p.classOf(con)]
+	if !found || action == ActionAsk {
+		return "", false
+	}
+	return action, true
+}
+
+// classOf returns the class of a connection, or "" if it doesn't belong to
+// any class configured.
+func (p *ProtocolDefaults) classOf(con *conman.Connection) string {
+	if _, found := p.actions[ClassMulticast]; found && con.DstIP != nil && con.DstIP.IsMulticast() {
+		return ClassMulticast
+	}
+	// the scope is only resolved if it's needed.
+	if _, found := p.actions[ClassBroadcast]; found && con.DstScope() == conman.ScopeBroadcast {
+		return ClassBroadcast
+	}
+
+	// tcp, tcp6, udp, udp6, udplite, icmp, icmp6, igmp
+	switch {
+	case strings.HasPrefix(con.Protocol, ClassTCP):
+		return ClassTCP
+	case strings.HasPrefix(con.Protocol, ClassUDP):
+		return ClassUDP
+	case strings.HasPrefix(con.Protocol, ClassICMP):
+		return ClassICMP
+	case con.Protocol == ClassIGMP:
+		return ClassIGMP
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+	"github.com/evilsocket/opensnitch/daemon/ui/protocol"
+)
+
+func TestProtocolDefaults(t *testing.T) {
+	p, err := NewProtocolDefaults(map[string]string{
+		"multicast": "allow",
+		"broadcast": "allow",
+		"icmp":      "deny",
+		"igmp":      "allow",
+		"udp":       "reject",
+		"tcp":       "ask",
+	})
+	if err != nil {
+		t.Fatal("NewProtocolDefaults() error:", err)
+	}
+
+	tests := []struct {
+		proto, dst, scope string
+		action            rule.Action
+		found             bool
+	}{
+		{"udp", "224.0.0.251", "multicast", rule.Allow, true},
+		{"udp6", "ff02::fb", "multicast", rule.Allow, true},
+		{"udp", "192.168.1.255", "broadcast", rule.Allow, true},
+		{"icmp6", "2606:4700::1111", "wan", rule.Deny, true},
+		{"igmp", "192.168.1.1", "lan", rule.Allow, true},
+		{"udplite", "1.1.1.1", "wan", rule.Reject, true},
+		{"tcp", "1.1.1.1", "wan", "", false},
+		{"sctp", "1.1.1.1", "wan", "", false},
+	}
+	for _, test := range tests {
+		con := conman.Deserialize(&protocol.Connection{Protocol: test.proto, DstIp: test.dst, DstScope: test.scope})
+		action, found := p.For(con)
+		if action != test.action || found != test.found {
+			t.Errorf("%s %s: unexpected default action: %s, %v", test.proto, test.dst, action, found)
+		}
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		p, _ := NewProtocolDefaults(nil)
+		con := conman.Deserialize(&protocol.Connection{Protocol: "udp", DstIp: "224.0.0.251", DstScope: "multicast"})
+		if _, found := p.For(con); found {
+			t.Error("no default action should apply")
+		}
+		var nilDefaults *ProtocolDefaults
+		if _, found := nilDefaults.For(con); found {
+			t.Error("no default action should apply to nil defaults")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := NewProtocolDefaults(map[string]string{"gre": "allow"}); err == nil {
+			t.Error("invalid class should fail")
+		}
+		if _, err := NewProtocolDefaults(map[string]string{"udp": "drop"}); err == nil {
+			t.Error("invalid action should fail")
+		}
+	})
+}
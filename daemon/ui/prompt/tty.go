@@ -0,0 +1,252 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// ErrTimeout is returned when the user doesn't answer the prompt in time.
+var ErrTimeout = fmt.Errorf("prompt timed out")
+
+var (
+	// default time to wait for an answer
+	defaultTimeout = 15 * time.Second
+
+	// max attempts to answer a question before giving up
+	maxAttempts = 3
+
+	// same durations offered by the GUI
+	durations = []rule.Duration{
+		rule.Once,
+		rule.Duration("30s"),
+		rule.Duration("5m"),
+		rule.Duration("15m"),
+		rule.Duration("30m"),
+		rule.Duration("1h"),
+		rule.Duration("12h"),
+		rule.Restart,
+		rule.Always,
+	}
+
+	slugRe = regexp.MustCompile("[^a-z0-9]+")
+)
+
+type option struct {
+	key   string
+	label string
+}
+
+// Tty displays the connections prompts on a terminal, allowing to answer them
+// on hosts without a GUI.
+type Tty struct {
+	Path    string
+	Timeout time.Duration
+
+	sync.Mutex
+}
+
+// NewTty returns a new prompt for the given terminal.
+// The timeout is the time to wait for an answer, 15s by default.
+func NewTty(path, timeout string) (*Tty, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	t := &Tty{
+		Path:    path,
+		Timeout: defaultTimeout,
+	}
+	if tm, err := time.ParseDuration(timeout); err == nil && tm > 0 {
+		t.Timeout = tm
+	}
+
+	return t, nil
+}
+
+// Ask displays the details of a connection on the terminal, and waits for
+// the user to decide what to do with it. The prompt templates that apply to
+// the connection are offered as targets of the rule.
+// If the user doesn't answer in time, ErrTimeout is returned.
+func (t *Tty) Ask(con *conman.Connection, templates ...*rule.PromptTemplate) (*rule.Rule, error) {
+	return t.AskWithin(t.Timeout, con, templates...)
+}
+
+// AskWithin asks like Ask, waiting for an answer the given time instead of
+// the timeout of the terminal.
+func (t *Tty) AskWithin(timeout time.Duration, con *conman.Connection, templates ...*rule.PromptTemplate) (*rule.Rule, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	f, err := os.OpenFile(t.Path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// not all files support deadlines, in that case we'll wait for the answer
+	// until the connection is closed.
+	if err := f.SetDeadline(time.Now().Add(timeout)); err != nil {
+		log.Debug("[prompt] tty %s does not support timeouts: %s", t.Path, err)
+	}
+
+	r, err := ask(f, con, templates)
+	if os.IsTimeout(err) {
+		fmt.Fprintf(f, "\n%s\n", ErrTimeout)
+		return nil, ErrTimeout
+	}
+	return r, err
+}
+
+// Ask displays the details of a connection, and reads from rw the answers of
+// the user, to build the rule that applies to the connection.
+func Ask(rw io.ReadWriter, con *conman.Connection, templates ...*rule.PromptTemplate) (*rule.Rule, error) {
+	return ask(rw, con, templates)
+}
+
+func ask(rw io.ReadWriter, con *conman.Connection, templates []*rule.PromptTemplate) (*rule.Rule, error) {
+	in := bufio.NewReader(rw)
+
+	host := con.DstIP.String()
+	if con.DstHost != "" {
+		host = fmt.Sprintf("%s (%s)", con.DstHost, host)
+	}
+	fmt.Fprintf(rw, "\n[opensnitch] %s (pid: %d, uid: %d) is connecting to %s on %s port %d\n",
+		con.Process.Path, con.Process.ID, con.Entry.UserId, host, strings.ToUpper(con.Protocol), con.DstPort)
+	if len(con.Process.Args) > 0 {
+		fmt.Fprintf(rw, "  command: %s\n", strings.Join(con.Process.Args, " "))
+	}
+
+	actions := []option{
+		{"a", string(rule.Allow)},
+		{"d", string(rule.Deny)},
+		{"r", string(rule.Reject)},
+		{"l", string(rule.Audit)},
+	}
+	idx, err := question(in, rw, "Action", actions)
+	if err != nil {
+		return nil, err
+	}
+	action := rule.Action(actions[idx].label)
+
+	durOpts := make([]option, len(durations))
+	for i, d := range durations {
+		durOpts[i] = option{strconv.Itoa(i + 1), string(d)}
+	}
+	idx, err = question(in, rw, "Duration", durOpts)
+	if err != nil {
+		return nil, err
+	}
+	duration := durations[idx]
+
+	targets := []option{{"1", "process " + con.Process.Path}}
+	operands := []rule.Operand{rule.OpProcessPath}
+	values := []string{con.Process.Path}
+	if con.DstHost != "" {
+		targets = append(targets, option{strconv.Itoa(len(targets) + 1), "host " + con.DstHost})
+		operands = append(operands, rule.OpDstHost)
+		values = append(values, con.DstHost)
+	}
+	for _, t := range []struct {
+		label   string
+		operand rule.Operand
+		value   string
+	}{
+		{"ip", rule.OpDstIP, con.DstIP.String()},
+		{"port", rule.OpDstPort, strconv.FormatUint(uint64(con.DstPort), 10)},
+		{"user id", rule.OpUserID, strconv.Itoa(con.Entry.UserId)},
+	} {
+		targets = append(targets, option{strconv.Itoa(len(targets) + 1), t.label + " " + t.value})
+		operands = append(operands, t.operand)
+		values = append(values, t.value)
+	}
+	// the templates are expanded before asking, to offer only the ones that
+	// apply to this connection.
+	expanded := make([]*rule.Rule, 0, len(templates))
+	for _, tmpl := range templates {
+		r, err := tmpl.Expand(con, action, duration)
+		if err != nil {
+			log.Debug("[prompt] template not offered: %s", err)
+			continue
+		}
+		label := "template " + tmpl.Name
+		if tmpl.Description != "" {
+			label = fmt.Sprintf("%s (%s)", label, tmpl.Description)
+		}
+		targets = append(targets, option{strconv.Itoa(len(targets) + 1), label})
+		expanded = append(expanded, r)
+	}
+	idx, err = question(in, rw, "Apply to", targets)
+	if err != nil {
+		return nil, err
+	}
+	if idx >= len(operands) {
+		r := expanded[idx-len(operands)]
+		fmt.Fprintf(rw, "  -> %s %s (%s)\n", action, duration, r.Name)
+		return r, nil
+	}
+
+	op, err := rule.NewOperator(rule.Simple, false, operands[idx], values[idx], make([]rule.Operator, 0))
+	if err != nil {
+		return nil, err
+	}
+	name := ruleName(action, duration, values[idx])
+	r := rule.Create(name, "", true, false, false, action, duration, op)
+	fmt.Fprintf(rw, "  -> %s %s\n", action, duration)
+
+	return r, nil
+}
+
+// question prints the options of a question and reads the answer.
+// It returns the index of the option selected. The first option is the default
+// one if the user just presses Enter.
+func question(in *bufio.Reader, w io.Writer, title string, opts []option) (int, error) {
+	labels := make([]string, len(opts))
+	for i, o := range opts {
+		labels[i] = fmt.Sprintf("[%s] %s", o.key, o.label)
+	}
+
+	for i := 0; i < maxAttempts; i++ {
+		fmt.Fprintf(w, "  %s: %s ? ", title, strings.Join(labels, ", "))
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return -1, err
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			return 0, nil
+		}
+		for n, o := range opts {
+			if strings.EqualFold(answer, o.key) {
+				return n, nil
+			}
+		}
+		fmt.Fprintf(w, "  invalid option: %s\n", answer)
+	}
+
+	return -1, fmt.Errorf("too many invalid answers")
+}
+
+// ruleName builds the name of the rule the same way the GUI does:
+// <action>-<duration>-simple-<data>
+func ruleName(action rule.Action, duration rule.Duration, data string) string {
+	name := strings.ToLower(fmt.Sprintf("%s %s simple %s", action, duration, data))
+	name = strings.Trim(slugRe.ReplaceAllString(name, "-"), "-")
+	if len(name) > 128 {
+		name = name[:128]
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package prompt
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/opensnitch/daemon/conman"
+	"github.com/evilsocket/opensnitch/daemon/netstat"
+	"github.com/evilsocket/opensnitch/daemon/procmon"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+type fakeTty struct {
+	in  *strings.Reader
+	out bytes.Buffer
+}
+
+func (f *fakeTty) Read(p []byte) (int, error)  { return f.in.Read(p) }
+func (f *fakeTty) Write(p []byte) (int, error) { return f.out.Write(p) }
+
+func newConn() *conman.Connection {
+	proc := procmon.NewProcessEmpty(1234, "curl")
+	proc.Path = "/usr/bin/curl"
+	return &conman.Connection{
+		Protocol: "tcp",
+		DstHost:  "opensnitch.io",
+		DstIP:    net.ParseIP("185.53.178.14"),
+		DstPort:  443,
+		Process:  proc,
+		Entry:    &netstat.Entry{UserId: 1000},
+	}
+}
+
+func TestAsk(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		action   rule.Action
+		duration rule.Duration
+		operand  rule.Operand
+		data     string
+		rname    string
+	}{
+		{"defaults", "\n\n\n", rule.Allow, rule.Once, rule.OpProcessPath, "/usr/bin/curl", "allow-once-simple-usr-bin-curl"},
+		{"deny host always", "d\n9\n2\n", rule.Deny, rule.Always, rule.OpDstHost, "opensnitch.io", "deny-always-simple-opensnitch-io"},
+		{"invalid option", "x\nr\n2\n4\n", rule.Reject, rule.Duration("30s"), rule.OpDstPort, "443", "reject-30s-simple-443"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tty := &fakeTty{in: strings.NewReader(test.input)}
+			r, err := ask(tty, newConn(), nil)
+			if err != nil {
+				t.Fatal("ask() error:", err, tty.out.String())
+			}
+			if r.Action != test.action || r.Duration != test.duration {
+				t.Error("invalid action or duration:", r.Action, r.Duration)
+			}
+			if r.Operator.Operand != test.operand || r.Operator.Data != test.data {
+				t.Error("invalid operator:", r.Operator.Operand, r.Operator.Data)
+			}
+			if r.Name != test.rname {
+				t.Error("invalid rule name:", r.Name)
+			}
+		})
+	}
+
+	t.Run("no answer", func(t *testing.T) {
+		tty := &fakeTty{in: strings.NewReader("")}
+		if _, err := ask(tty, newConn(), nil); err == nil {
+			t.Error("ask() should fail if there's no answer")
+		}
+	})
+}
+
+func TestAskTemplates(t *testing.T) {
+	host, err := rule.NewPromptTemplate([]byte(`{"name": "tmpl-host", "description": "own domain", "enabled": true,
+		"operator": {"type": "simple", "operand": "dest.host", "data": "$DST_HOST"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := rule.NewPromptTemplate([]byte(`{"name": "tmpl-port-$DST_PORT", "enabled": true,
+		"operator": {"type": "simple", "operand": "dest.port", "data": "$DST_PORT"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := []*rule.PromptTemplate{host, port}
+
+	// 5 targets of the connection, followed by the templates
+	tty := &fakeTty{in: strings.NewReader("d\n9\n7\n")}
+	r, err := ask(tty, newConn(), templates)
+	if err != nil {
+		t.Fatal("ask() error:", err, tty.out.String())
+	}
+	if !strings.Contains(tty.out.String(), "[6] template tmpl-host (own domain)") {
+		t.Error("template not offered:", tty.out.String())
+	}
+	if r.Name != "tmpl-port-443" || r.Action != rule.Deny || r.Duration != rule.Always ||
+		r.Operator.Operand != rule.OpDstPort || r.Operator.Data != "443" {
+		t.Error("invalid rule:", r.Name, r.Action, r.Duration, r.Operator.Operand, r.Operator.Data)
+	}
+
+	// the connection has no host, the first template doesn't apply
+	con := newConn()
+	con.DstHost = ""
+	tty = &fakeTty{in: strings.NewReader("\n\n5\n")}
+	if r, err = ask(tty, con, templates); err != nil {
+		t.Fatal("ask() error:", err, tty.out.String())
+	}
+	if strings.Contains(tty.out.String(), "tmpl-host") || r.Name != "tmpl-port-443" {
+		t.Error("unexpected template offered:", r.Name, tty.out.String())
+	}
+}
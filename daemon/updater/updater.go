@@ -0,0 +1,490 @@
+// Package updater checks periodically if there's a new version of the daemon
+// in a release endpoint, and stages its binary for the hot-upgrade path
+// (SIGUSR2), so the users don't depend on the lag of the distributions.
+//
+// The endpoint publishes a manifest, signed with ed25519 (<manifest>.sig):
+//
+//	{"version": "1.10.0", "notes": "https://...",
+//	 "binaries": {"amd64": {"url": "https://.../opensnitchd", "sha256": "..."}}}
+//
+// The binary is only staged if the signature of the manifest is valid, and
+// its sha256 matches the one of the manifest. It's never installed: the
+// staged binary is executed by the next upgrade, and the package manager
+// keeps owning the installed one.
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/core"
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+const binaryPrefix = "opensnitchd-"
+
+var (
+	defaultInterval   = 24 * time.Hour
+	defaultStagingDir = "/var/lib/opensnitchd/updates"
+	checkTimeout      = 5 * time.Minute
+	// delay of the first check, to let the network come up on boot.
+	firstCheckDelay = time.Minute
+	// max size of the manifest and its signature, and of the binary.
+	maxManifestSize = int64(64 * 1024)
+	maxBinarySize   = int64(256 * 1024 * 1024)
+
+	// max time to get the version of the installed binary.
+	versionTimeout = 5 * time.Second
+
+	currentVersion   = core.Version
+	installedVersion = binaryVersion
+	timeNow          = time.Now
+)
+
+// Config holds the configuration of the updates.
+type Config struct {
+	// URL of the release manifest.
+	URL string `json:"URL"`
+	// SignatureURL of the ed25519 signature of the manifest (raw or base64),
+	// URL.sig by default.
+	SignatureURL string `json:"SignatureURL"`
+	// TrustedKeys are the ed25519 public keys (PEM) allowed to sign the
+	// manifest. At least one is needed.
+	TrustedKeys []string `json:"TrustedKeys"`
+	// Interval between checks (24h by default).
+	Interval string `json:"Interval"`
+	// StagingDir is the directory where the new binaries are downloaded
+	// (/var/lib/opensnitchd/updates by default).
+	StagingDir string `json:"StagingDir"`
+
+	Enabled bool `json:"Enabled"`
+}
+
+// Binary is the binary of a release for an architecture. The URL can be
+// relative to the manifest.
+type Binary struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes the latest release.
+type Manifest struct {
+	Version  string            `json:"version"`
+	Notes    string            `json:"notes"`
+	Binaries map[string]Binary `json:"binaries"`
+}
+
+// Status is the result of the last check.
+type Status struct {
+	Current   string `json:"current"`
+	Available string `json:"available,omitempty"`
+	Notes     string `json:"notes,omitempty"`
+	// path of the binary staged for the next upgrade.
+	Staged  string    `json:"staged,omitempty"`
+	Checked time.Time `json:"checked"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Updater checks the release endpoint periodically.
+type Updater struct {
+	client   *http.Client
+	onUpdate func(s Status)
+	stop     chan struct{}
+
+	cfg    Config
+	keys   []ed25519.PublicKey
+	status Status
+	// serializes the checks.
+	checkLock sync.Mutex
+	sync.Mutex
+}
+
+// Default is the updater of the daemon.
+var Default = New()
+
+// New returns a new updater, disabled until it's configured.
+func New() *Updater {
+	return &Updater{
+		client: &http.Client{Timeout: checkTimeout},
+		status: Status{Current: currentVersion},
+	}
+}
+
+// OnUpdate registers the function to call when a new version is staged.
+func (u *Updater) OnUpdate(cb func(s Status)) {
+	u.Lock()
+	defer u.Unlock()
+	u.onUpdate = cb
+}
+
+// SetConfig applies a new configuration, restarting the checks. The first
+// check is done shortly after.
+func (u *Updater) SetConfig(cfg Config) error {
+	interval := defaultInterval
+	if cfg.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(cfg.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid updates interval '%s'", cfg.Interval)
+		}
+	}
+	var keys []ed25519.PublicKey
+	if cfg.Enabled {
+		if cfg.URL == "" {
+			return fmt.Errorf("updates enabled, but no URL configured")
+		}
+		if len(cfg.TrustedKeys) == 0 {
+			return fmt.Errorf("updates enabled, but no trusted keys configured")
+		}
+		var err error
+		if keys, err = rule.ReadTrustedKeys(cfg.TrustedKeys); err != nil {
+			return err
+		}
+	}
+	if cfg.SignatureURL == "" {
+		cfg.SignatureURL = cfg.URL + ".sig"
+	}
+	if cfg.StagingDir == "" {
+		cfg.StagingDir = defaultStagingDir
+	}
+
+	u.Lock()
+	defer u.Unlock()
+	if u.stop != nil {
+		close(u.stop)
+		u.stop = nil
+	}
+	u.cfg = cfg
+	u.keys = keys
+	if !cfg.Enabled {
+		return nil
+	}
+	log.Info("[updater] checking %s every %s", cfg.URL, interval)
+	u.stop = make(chan struct{})
+	go u.run(u.stop, interval)
+	return nil
+}
+
+func (u *Updater) run(stop chan struct{}, interval time.Duration) {
+	timer := time.NewTimer(firstCheckDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+		if _, err := u.Check(); err != nil {
+			log.Warning("[updater] %s", err)
+		}
+		timer.Reset(interval)
+	}
+}
+
+// Status returns the result of the last check.
+func (u *Updater) Status() Status {
+	u.Lock()
+	defer u.Unlock()
+	return u.status
+}
+
+// Staged returns the path of the binary staged for the next upgrade, or an
+// empty string if there's none.
+// The installed binary may have been upgraded by the package manager after
+// staging a release, so the staged binary is only returned if it's newer than
+// the installed one (exe), and it's discarded otherwise.
+func (u *Updater) Staged(exe string) string {
+	u.Lock()
+	staged, version := u.status.Staged, u.status.Available
+	u.Unlock()
+	if staged == "" {
+		return ""
+	}
+	if _, err := os.Stat(staged); err != nil {
+		return ""
+	}
+	installed, err := installedVersion(exe)
+	if err != nil {
+		log.Warning("[updater] unable to get the version of %s: %s", exe, err)
+		installed = currentVersion
+	}
+	if newerVersion(version, installed) {
+		return staged
+	}
+
+	log.Important("[updater] discarding opensnitchd %s staged at %s, %s is installed", version, staged, installed)
+	os.Remove(staged)
+	u.Lock()
+	if u.status.Staged == staged {
+		u.status.Available, u.status.Notes, u.status.Staged = "", "", ""
+	}
+	u.Unlock()
+	return ""
+}
+
+// binaryVersion returns the version of a daemon binary.
+func binaryVersion(exe string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, exe, "-version").Output()
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(out))
+	if parseVersion(version) == nil {
+		return "", fmt.Errorf("invalid version: %q", version)
+	}
+	return version, nil
+}
+
+// Check downloads the manifest, and if there's a newer version, stages its
+// binary.
+func (u *Updater) Check() (Status, error) {
+	u.checkLock.Lock()
+	defer u.checkLock.Unlock()
+
+	u.Lock()
+	cfg, keys, cb := u.cfg, u.keys, u.onUpdate
+	prev := u.status
+	u.Unlock()
+	if !cfg.Enabled {
+		return prev, fmt.Errorf("updates not enabled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	status := Status{Current: currentVersion, Checked: timeNow()}
+	m, err := u.manifest(ctx, cfg, keys)
+	if err == nil && newerVersion(m.Version, currentVersion) {
+		status.Available, status.Notes = m.Version, m.Notes
+		status.Staged, err = u.stage(ctx, cfg, m)
+	}
+	if err != nil {
+		// the release staged before is still available.
+		status.Available, status.Notes, status.Staged = prev.Available, prev.Notes, prev.Staged
+		status.Error = err.Error()
+	}
+
+	u.Lock()
+	u.status = status
+	u.Unlock()
+	if status.Staged != "" && status.Staged != prev.Staged {
+		log.Important("[updater] opensnitchd %s staged at %s", status.Available, status.Staged)
+		if cb != nil {
+			cb(status)
+		}
+	}
+	return status, err
+}
+
+// manifest downloads the manifest, and verifies its signature.
+func (u *Updater) manifest(ctx context.Context, cfg Config, keys []ed25519.PublicKey) (*Manifest, error) {
+	raw, err := u.get(ctx, cfg.URL, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the manifest: %s", err)
+	}
+	sig, err := u.get(ctx, cfg.SignatureURL, maxManifestSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the signature of the manifest: %s", err)
+	}
+	if sig, err = decodeSignature(sig); err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range keys {
+		if ed25519.Verify(key, raw, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("invalid signature of the manifest %s", cfg.URL)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+	if parseVersion(m.Version) == nil {
+		return nil, fmt.Errorf("invalid version in the manifest: %s", m.Version)
+	}
+	return &m, nil
+}
+
+// stage downloads the binary of the release for this architecture, and
+// returns its path. The binaries staged before are deleted.
+func (u *Updater) stage(ctx context.Context, cfg Config, m *Manifest) (string, error) {
+	bin, found := m.Binaries[runtime.GOARCH]
+	if !found {
+		return "", fmt.Errorf("no binary of %s for %s", m.Version, runtime.GOARCH)
+	}
+	// the URL of the binary can be relative to the manifest.
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	binURL, err := base.Parse(bin.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL of the binary: %s", bin.URL)
+	}
+	bin.URL = binURL.String()
+	dir := cfg.StagingDir
+	if _, err := hex.DecodeString(bin.SHA256); err != nil || len(bin.SHA256) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid sha256 of the binary: %s", bin.SHA256)
+	}
+	path := filepath.Join(dir, binaryPrefix+m.Version)
+	if sum, err := fileSHA256(path); err == nil && strings.EqualFold(sum, bin.SHA256) {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bin.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to download %s: %s", bin.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to download %s: http status %d", bin.URL, resp.StatusCode)
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return "", fmt.Errorf("unable to download %s: %s", bin.URL, err)
+	}
+	if n > maxBinarySize {
+		return "", fmt.Errorf("binary %s too big", bin.URL)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, bin.SHA256) {
+		return "", fmt.Errorf("sha256 mismatch of %s, expected %s, got %s", bin.URL, bin.SHA256, got)
+	}
+	if err := tmp.Chmod(0755); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	olds, _ := filepath.Glob(filepath.Join(dir, binaryPrefix+"*"))
+	for _, old := range olds {
+		if old != path {
+			os.Remove(old)
+		}
+	}
+	return path, nil
+}
+
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// decodeSignature returns an ed25519 signature, raw or base64 encoded.
+func decodeSignature(sig []byte) ([]byte, error) {
+	if len(sig) == ed25519.SignatureSize {
+		return sig, nil
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid signature of the manifest")
+	}
+	return sig, nil
+}
+
+// parseVersion returns the numbers of a version (1.10.0, v1.10.0-rc1), or nil
+// if it's not valid. The suffixes are ignored.
+func parseVersion(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+~"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil
+		}
+		nums[i] = n
+	}
+	return nums
+}
+
+// newerVersion returns true if the version v is newer than the current one.
+func newerVersion(v, current string) bool {
+	a, b := parseVersion(v), parseVersion(current)
+	if a == nil || b == nil {
+		return false
+	}
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
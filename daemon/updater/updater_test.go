@@ -0,0 +1,173 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// release serves a manifest signed with a key, and the binary.
+type release struct {
+	key      ed25519.PrivateKey
+	manifest []byte
+	binary   []byte
+}
+
+func (r *release) publish(t *testing.T, version string, binary []byte, sum []byte) {
+	r.binary = binary
+	if sum == nil {
+		s := sha256.Sum256(binary)
+		sum = s[:]
+	}
+	m := Manifest{
+		Version: version,
+		Binaries: map[string]Binary{
+			runtime.GOARCH: {URL: "/opensnitchd", SHA256: hex.EncodeToString(sum)},
+		},
+	}
+	var err error
+	if r.manifest, err = json.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newRelease(t *testing.T) (*release, *httptest.Server, string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "release.pem")
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+
+	r := &release{key: priv}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/latest.json":
+			w.Write(r.manifest)
+		case "/latest.json.sig":
+			w.Write(ed25519.Sign(r.key, r.manifest))
+		case "/opensnitchd":
+			w.Write(r.binary)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv, keyFile
+}
+
+func TestUpdater(t *testing.T) {
+	origVersion, origDelay, origInstalled := currentVersion, firstCheckDelay, installedVersion
+	currentVersion, firstCheckDelay = "1.9.0", time.Hour
+	installed := "1.9.0"
+	installedVersion = func(exe string) (string, error) { return installed, nil }
+	defer func() {
+		currentVersion, firstCheckDelay, installedVersion = origVersion, origDelay, origInstalled
+	}()
+
+	r, srv, keyFile := newRelease(t)
+	dir := t.TempDir()
+	u := New()
+	if err := u.SetConfig(Config{Enabled: true, URL: srv.URL + "/latest.json"}); err == nil {
+		t.Error("updates enabled without trusted keys")
+	}
+	if _, err := u.Check(); err == nil {
+		t.Error("updates checked while disabled")
+	}
+	staged := 0
+	u.OnUpdate(func(s Status) { staged++ })
+	if err := u.SetConfig(Config{Enabled: true, URL: srv.URL + "/latest.json", TrustedKeys: []string{keyFile}, StagingDir: dir}); err != nil {
+		t.Fatal("SetConfig() error:", err)
+	}
+	defer u.SetConfig(Config{})
+
+	// same version, nothing to stage.
+	r.publish(t, "1.9.0", []byte("opensnitchd 1.9.0"), nil)
+	if s, err := u.Check(); err != nil || s.Available != "" || s.Staged != "" {
+		t.Errorf("current version staged: %+v, %v", s, err)
+	}
+
+	r.publish(t, "1.10.0", []byte("opensnitchd 1.10.0"), nil)
+	s, err := u.Check()
+	if err != nil || s.Available != "1.10.0" || s.Staged != filepath.Join(dir, "opensnitchd-1.10.0") {
+		t.Fatalf("new version not staged: %+v, %v", s, err)
+	}
+	if raw, _ := ioutil.ReadFile(s.Staged); string(raw) != "opensnitchd 1.10.0" {
+		t.Errorf("invalid binary staged: %s", raw)
+	}
+	if fi, _ := os.Stat(s.Staged); fi.Mode().Perm() != 0755 {
+		t.Error("binary staged not executable:", fi.Mode())
+	}
+	if u.Staged("opensnitchd") != s.Staged || staged != 1 {
+		t.Errorf("new version not reported: %s, %d", u.Staged("opensnitchd"), staged)
+	}
+	if _, err := u.Check(); err != nil || staged != 1 {
+		t.Error("same version reported twice:", err, staged)
+	}
+
+	// the binary doesn't match the manifest.
+	r.publish(t, "1.11.0", []byte("opensnitchd 1.11.0"), make([]byte, sha256.Size))
+	if s, err := u.Check(); err == nil || s.Staged != filepath.Join(dir, "opensnitchd-1.10.0") {
+		t.Errorf("binary with invalid sha256 staged: %+v", s)
+	}
+
+	// manifest signed with another key.
+	r.publish(t, "1.12.0", []byte("opensnitchd 1.12.0"), nil)
+	_, r.key, _ = ed25519.GenerateKey(rand.Reader)
+	if s, err := u.Check(); err == nil || s.Available != "1.10.0" {
+		t.Errorf("manifest with invalid signature accepted: %+v", s)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "opensnitchd-1.12.0")); err == nil {
+		t.Error("binary of an unsigned manifest staged")
+	}
+
+	// the package manager installed the same version.
+	installed = "1.10.0"
+	if exe := u.Staged("opensnitchd"); exe != "" {
+		t.Error("staged binary not newer than the installed one returned:", exe)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "opensnitchd-1.10.0")); err == nil {
+		t.Error("staged binary not newer than the installed one not discarded")
+	}
+	if s := u.Status(); s.Staged != "" || s.Available != "" {
+		t.Errorf("discarded binary still reported: %+v", s)
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		version, current string
+		newer            bool
+	}{
+		{"1.10.0", "1.9.0", true},
+		{"v1.9.1", "1.9.0", true},
+		{"1.9.0.1", "1.9.0", true},
+		{"2.0.0-rc1", "1.9.0", true},
+		{"1.9.0", "1.9.0", false},
+		{"1.9", "1.9.0", false},
+		{"1.8.5", "1.9.0", false},
+		{"latest", "1.9.0", false},
+		{"", "1.9.0", false},
+	}
+	for _, test := range tests {
+		if newer := newerVersion(test.version, test.current); newer != test.newer {
+			t.Errorf("%s newer than %s: %v, expected %v", test.version, test.current, newer, test.newer)
+		}
+	}
+}
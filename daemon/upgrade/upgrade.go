@@ -0,0 +1,277 @@
+// Package upgrade hands over the state of the daemon to a new instance of it
+// (usually a new version of the binary installed by a package upgrade).
+//
+// The current instance executes the new one, passing it the netlink sockets of
+// the queues, the eBPF maps and the in-memory caches. The queues remain bound
+// to the same sockets, so connections keep being intercepted while the new
+// instance starts. Once it's ready, the previous one exits without deleting
+// the firewall rules.
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/log"
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+// names of the files handed over to the new instance.
+const (
+	FileQueue       = "queue"
+	FileRepeatQueue = "repeat-queue"
+	FileDNSQueue    = "dns-queue"
+	// prefix of the eBPF maps files.
+	FileEbpfMap = "ebpf-map-"
+
+	fileState = "state"
+	fileReady = "ready"
+)
+
+var (
+	// environment variable with the list of files handed over, name=fd,...
+	envHandover = "OPENSNITCH_HANDOVER"
+
+	// time to wait for the new instance to be ready.
+	readyTimeout = 30 * time.Second
+)
+
+// State holds the in-memory state handed over to the new instance.
+type State struct {
+	// resolved domains
+	DNS map[string]string `json:"dns"`
+	// rules not saved to disk (temporary or until restart)
+	Rules       []*rule.Rule `json:"rules"`
+	QueueNum    uint16       `json:"queue_num"`
+	DNSQueueNum uint16       `json:"dns_queue_num"`
+	// number of queues of the connections, from QueueNum.
+	QueueCount uint16 `json:"queue_count"`
+	// the new outbound connections are being dropped.
+	PanicMode bool `json:"panic_mode"`
+}
+
+// Queues returns the number of queues of the connections. The previous
+// versions only used one queue.
+func (s *State) Queues() uint16 {
+	if s.QueueCount == 0 {
+		return 1
+	}
+	return s.QueueCount
+}
+
+// QueueFile returns the name of the file of the n queue of the connections.
+func QueueFile(n int) string {
+	if n == 0 {
+		return FileQueue
+	}
+	return fmt.Sprint(FileQueue, "-", n)
+}
+
+// Handover holds the state and files received from the previous instance.
+type Handover struct {
+	State *State
+	files map[string]*os.File
+}
+
+// Executable returns the path of the installed binary of the daemon, usually
+// replaced by the package manager.
+func Executable() (string, error) {
+	exe, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return "", fmt.Errorf("unable to find the daemon binary: %s", err)
+	}
+	return exe, nil
+}
+
+// Start executes a new instance of the daemon, with the same arguments,
+// passing it the state and the files.
+// exe is the binary of the new instance (e.g. staged by the updater). If it's
+// empty, the binary of the daemon is executed, usually replaced by the package
+// manager.
+// It returns once the new instance is ready, or an error if it failed to start,
+// in which case this instance must keep running.
+func Start(exe string, state *State, files map[string]*os.File) (*os.Process, error) {
+	if exe == "" {
+		var err error
+		if exe, err = Executable(); err != nil {
+			return nil, err
+		}
+	}
+
+	stateR, stateW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer stateW.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		stateR.Close()
+		return nil, err
+	}
+	defer readyR.Close()
+
+	extraFiles := []*os.File{stateR, readyW}
+	fds := []string{fmt.Sprint(fileState, "=3"), fmt.Sprint(fileReady, "=4")}
+	for name, f := range files {
+		fds = append(fds, fmt.Sprint(name, "=", len(extraFiles)+3))
+		extraFiles = append(extraFiles, f)
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	// keep the name of the daemon binary, so the next upgrades execute it
+	// and not a binary staged before.
+	cmd.Args[0] = os.Args[0]
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = extraFiles
+	cmd.Env = append(os.Environ(), fmt.Sprint(envHandover, "=", strings.Join(fds, ",")))
+	err = cmd.Start()
+	stateR.Close()
+	readyW.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to start %s: %s", exe, err)
+	}
+	log.Info("[upgrade] new instance started, pid %d", cmd.Process.Pid)
+
+	if err := json.NewEncoder(stateW).Encode(state); err != nil {
+		cmd.Process.Kill()
+		return nil, fmt.Errorf("unable to hand over the state: %s", err)
+	}
+	stateW.Close()
+
+	if err := waitReady(readyR, readyTimeout); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+func waitReady(ready *os.File, timeout time.Duration) error {
+	ready.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1)
+	if _, err := ready.Read(buf); err != nil {
+		if os.IsTimeout(err) {
+			return fmt.Errorf("the new instance is not ready after %s", timeout)
+		}
+		// the pipe is closed if the new instance exits.
+		return fmt.Errorf("the new instance exited before being ready: %s", err)
+	}
+	return nil
+}
+
+// Inherit returns the state handed over by the previous instance, or nil if
+// the daemon has not been started by an upgrade.
+func Inherit() (*Handover, error) {
+	env := os.Getenv(envHandover)
+	if env == "" {
+		return nil, nil
+	}
+	os.Unsetenv(envHandover)
+
+	files, err := parseFiles(env)
+	if err != nil {
+		return nil, err
+	}
+	h := &Handover{
+		State: &State{},
+		files: files,
+	}
+	stateFile := h.File(fileState)
+	if stateFile == nil {
+		return nil, fmt.Errorf("state not received")
+	}
+	defer stateFile.Close()
+	if err := json.NewDecoder(stateFile).Decode(h.State); err != nil {
+		return nil, fmt.Errorf("invalid state received: %s", err)
+	}
+
+	return h, nil
+}
+
+func parseFiles(env string) (map[string]*os.File, error) {
+	files := make(map[string]*os.File)
+	for _, item := range strings.Split(env, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid handover file: %s", item)
+		}
+		fd, err := strconv.Atoi(parts[1])
+		if err != nil || fd < 3 {
+			return nil, fmt.Errorf("invalid handover file descriptor: %s", item)
+		}
+		files[parts[0]] = os.NewFile(uintptr(fd), parts[0])
+	}
+	return files, nil
+}
+
+// File returns a file handed over by the previous instance, or nil if it's
+// not available. A file can only be obtained once.
+func (h *Handover) File(name string) *os.File {
+	f, found := h.files[name]
+	if !found {
+		return nil
+	}
+	delete(h.files, name)
+	return f
+}
+
+// Files returns the files handed over whose name starts with the given prefix,
+// without the prefix.
+func (h *Handover) Files(prefix string) map[string]*os.File {
+	files := make(map[string]*os.File)
+	for name := range h.files {
+		if strings.HasPrefix(name, prefix) {
+			files[strings.TrimPrefix(name, prefix)] = h.File(name)
+		}
+	}
+	return files
+}
+
+// Ready notifies the previous instance that this instance is ready to
+// intercept connections, so it can exit.
+// If the daemon is managed by systemd, it's also notified about the new
+// main pid.
+func (h *Handover) Ready() error {
+	ready := h.File(fileReady)
+	if ready == nil {
+		return fmt.Errorf("ready notification file not received")
+	}
+	defer ready.Close()
+	if _, err := ready.Write([]byte{1}); err != nil {
+		return fmt.Errorf("unable to notify the previous instance: %s", err)
+	}
+
+	// unused files
+	for name := range h.files {
+		h.File(name).Close()
+	}
+
+	return notifySystemd(fmt.Sprint("MAINPID=", os.Getpid()))
+}
+
+// notifySystemd sends a notification to the service manager, if any.
+// The service must be configured with NotifyAccess=all to accept
+// notifications from the new instance.
+func notifySystemd(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("unable to notify systemd: %s", err)
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
@@ -0,0 +1,110 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/evilsocket/opensnitch/daemon/rule"
+)
+
+func TestParseFiles(t *testing.T) {
+	files, err := parseFiles("state=1003,ready=1004,queue=1005,ebpf-map-tcpMap=1006")
+	if err != nil {
+		t.Fatal("parseFiles() error:", err)
+	}
+	if len(files) != 4 {
+		t.Fatal("expected 4 files, got:", len(files))
+	}
+	if files[FileQueue].Fd() != 1005 {
+		t.Error("invalid queue fd:", files[FileQueue].Fd())
+	}
+
+	h := &Handover{files: files}
+	maps := h.Files(FileEbpfMap)
+	if len(maps) != 1 || maps["tcpMap"] == nil {
+		t.Error("ebpf maps not found:", maps)
+	}
+	if h.File("ebpf-map-tcpMap") != nil {
+		t.Error("file returned twice")
+	}
+
+	for _, env := range []string{"state", "state=abc", "state=1"} {
+		if _, err := parseFiles(env); err == nil {
+			t.Error("invalid files accepted:", env)
+		}
+	}
+}
+
+func TestInherit(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &State{
+		DNS:      map[string]string{"1.1.1.1": "one.one.one.one"},
+		Rules:    []*rule.Rule{{Name: "000-temporary", Duration: rule.Restart}},
+		QueueNum: 2,
+	}
+	go func() {
+		json.NewEncoder(w).Encode(state)
+		w.Close()
+	}()
+
+	// the state file is closed by Inherit()
+	fd, err := syscall.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	os.Setenv(envHandover, fmt.Sprint(fileState, "=", fd))
+	h, err := Inherit()
+	if err != nil {
+		t.Fatal("Inherit() error:", err)
+	}
+	if os.Getenv(envHandover) != "" {
+		t.Error("handover environment variable not cleared")
+	}
+	if h.State.QueueNum != 2 || h.State.DNS["1.1.1.1"] != "one.one.one.one" {
+		t.Error("invalid state received:", h.State)
+	}
+	if len(h.State.Rules) != 1 || h.State.Rules[0].Name != "000-temporary" {
+		t.Error("invalid rules received:", h.State.Rules)
+	}
+
+	if h, err := Inherit(); h != nil || err != nil {
+		t.Error("Inherit() without handover:", h, err)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waitReady(r, 10*time.Millisecond); err == nil {
+		t.Error("timeout expected")
+	}
+
+	h := &Handover{files: map[string]*os.File{fileReady: w}}
+	if err := h.Ready(); err != nil {
+		t.Error("Ready() error:", err)
+	}
+	if err := waitReady(r, time.Second); err != nil {
+		t.Error("waitReady() error:", err)
+	}
+	r.Close()
+}
+
+func TestQueueFiles(t *testing.T) {
+	state := &State{QueueNum: 10}
+	if state.Queues() != 1 {
+		t.Error("the state of previous versions should have one queue:", state.Queues())
+	}
+	if QueueFile(0) != FileQueue || QueueFile(2) != "queue-2" {
+		t.Error("invalid queue files names:", QueueFile(0), QueueFile(2))
+	}
+}
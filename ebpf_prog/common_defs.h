@@ -1,6 +1,24 @@
 #ifndef OPENSNITCH_COMMON_DEFS_H
 #define OPENSNITCH_COMMON_DEFS_H
 
+#ifdef OPENSNITCH_CORE
+// CO-RE (BTF) build: the kernel types are read from vmlinux.h, generated from
+// the BTF of a kernel, and the fields offsets are relocated when the module
+// is loaded. So the module works across kernel versions.
+#include "vmlinux.h"
+#include "bpf_headers/bpf_helpers.h"
+#include "bpf_headers/bpf_tracing.h"
+#include "bpf_headers/bpf_core_read.h"
+
+#ifndef htonll
+ #if __BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__
+  #define htonll(x) __builtin_bswap64(x)
+ #else
+  #define htonll(x) (x)
+ #endif
+#endif
+
+#else
 #include <linux/sched.h>
 #include <linux/ptrace.h>
 #include <linux/byteorder/generic.h>
@@ -9,12 +27,13 @@
 #include "bpf_headers/bpf_tracing.h"
 //#include <bpf/bpf_core_read.h> 
 
-#define BUF_SIZE_MAP_NS 256
-#define MAPSIZE 12000
-
 #ifndef htonll
   #define htonll(x) cpu_to_be64(x)
 #endif
+#endif
+
+#define BUF_SIZE_MAP_NS 256
+#define MAPSIZE 12000
 
 #define debug(fmt, ...) \
     ( \
@@ -1,8 +1,23 @@
 #define KBUILD_MODNAME "opensnitch-procs"
 
 #include "common.h"
+#ifndef OPENSNITCH_CORE
 #include <net/sock.h>
+#endif
 
+#ifdef OPENSNITCH_PERF_EVENTS
+// Kernels < 5.8, without ring buffers (opensnitch-procs-perf.o).
+// The events are sent to the perf buffer of the CPU where the program runs,
+// so they may be received out of order.
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+    __uint(key_size, sizeof(u32));
+    __uint(value_size, sizeof(u32));
+} events SEC(".maps");
+
+#define send_event(ctx, data) \
+    bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, data, sizeof(*data))
+#else
 struct {
     // Since kernel 5.8
     __uint(type, BPF_MAP_TYPE_RINGBUF);
@@ -13,6 +28,10 @@ struct {
     __uint(max_entries, 1 << 24);
 } events SEC(".maps");
 
+#define send_event(ctx, data) \
+    bpf_ringbuf_output(&events, data, sizeof(*data), 0)
+#endif
+
 struct {
     __uint(type, BPF_MAP_TYPE_HASH);
     __type(key, u64);
@@ -27,12 +46,18 @@ static __always_inline void new_event(struct data_t* data)
     __builtin_memset(&task, 0, sizeof(task));
     __builtin_memset(&parent, 0, sizeof(parent));
     task = (struct task_struct *)bpf_get_current_task();
-    bpf_probe_read(&parent, sizeof(parent), &task->real_parent);
     data->pid = bpf_get_current_pid_tgid() >> 32;
 
+#ifdef OPENSNITCH_CORE
+    // the offsets of real_parent and tgid are relocated on load.
+    parent = BPF_CORE_READ(task, real_parent);
+    data->ppid = BPF_CORE_READ(parent, tgid);
+#else
+    bpf_probe_read(&parent, sizeof(parent), &task->real_parent);
 #if !defined(__arm__) && !defined(__i386__)
     // on i686 -> invalid read from stack
     bpf_probe_read(&data->ppid, sizeof(u32), &parent->tgid);
+#endif
 #endif
     data->uid = bpf_get_current_uid_gid() & 0xffffffff;
     bpf_get_current_comm(&data->comm, sizeof(data->comm));
@@ -53,7 +78,7 @@ static __always_inline void __handle_exit_execve(struct trace_sys_exit_execve *c
     }
     proc->ret_code = ctx->ret;
 
-    int ret = bpf_ringbuf_output(&events, proc, sizeof(*proc), 0);
+    int ret = send_event(ctx, proc);
     if (ret != 0){
         debug("execve send error: %d, %d, %s\n", ret, pid_tgid, proc->filename);
     }
@@ -80,7 +105,7 @@ int tracepoint__sched_sched_process_exit(struct pt_regs *ctx)
 
     new_event(data);
     data->type = EVENT_SCHED_EXIT;
-    bpf_ringbuf_output(&events, data, sizeof(*data), 0);
+    send_event(ctx, data);
 
     bpf_map_delete_elem(&execMap, &pid_tgid);
     return 0;
@@ -115,7 +140,7 @@ int tracepoint__syscalls_sys_enter_execve(struct trace_sys_enter_execve* ctx)
     data->args_count = 0;
     data->args_partial = INCOMPLETE_ARGS;
 #if defined(__arm__) || defined(__i386__) || defined(__aarch64__)
-    bpf_ringbuf_output(&events, data, sizeof(*data), 0);
+    send_event(ctx, data);
     return 0;
 #endif
     bpf_probe_read_user_str(&data->filename, sizeof(data->filename), (const char *)ctx->filename);
@@ -156,7 +181,7 @@ int tracepoint__syscalls_sys_enter_execve(struct trace_sys_enter_execve* ctx)
         // -7 E2BIG (arg list too long) -> too much args?
         // -2 ENOENT (no such file or directory) -> map index not found. on different cpu?
 
-        bpf_ringbuf_output(&events, data, sizeof(*data), 0);
+        send_event(ctx, data);
     }
 
     return 0;
@@ -180,7 +205,7 @@ int tracepoint__syscalls_sys_enter_execveat(struct trace_sys_enter_execveat* ctx
     data->args_count = 0;
     data->args_partial = INCOMPLETE_ARGS;
 #if defined(__arm__) || defined(__i386__) || defined(__aarch64__)
-    bpf_ringbuf_output(&events, data, sizeof(*data), 0);
+    send_event(ctx, data);
     return 0;
 #endif
 
@@ -200,7 +225,7 @@ int tracepoint__syscalls_sys_enter_execveat(struct trace_sys_enter_execveat* ctx
     u64 pid_tgid = bpf_get_current_pid_tgid();
     if (bpf_map_update_elem(&execMap, &pid_tgid, data, BPF_ANY) != 0) {
 
-        bpf_ringbuf_output(&events, data, sizeof(*data), 0);
+        send_event(ctx, data);
     }
 
     return 0;
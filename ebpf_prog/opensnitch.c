@@ -5,6 +5,7 @@
 #include <net/sock.h>
 #include <net/udp_tunnel.h>
 #include <net/inet_sock.h>
+#include <net/tcp_states.h>
 
 struct tcp_key_t {
     u16 sport;
@@ -128,6 +129,26 @@ struct {
     __uint(max_entries, 300);
 } icmpsock SEC(".maps");
 
+// owners of the TCP sockets, by inode, learnt when the sockets are connected.
+// Used when a connection is not found in the maps above, instead of scanning
+// /proc/<pid>/fd. The least recently used entries are evicted when it's full.
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, u64);
+    __type(value, struct tcp_value_t);
+    __uint(max_entries, MAPSIZE+5);
+} sockOwnerMap SEC(".maps");
+
+// format of the tracepoint sock/inet_sock_set_state (kernel >= 4.16):
+// /sys/kernel/tracing/events/sock/inet_sock_set_state/format
+// Only the fields up to the state are used, the rest changed across versions.
+struct inet_sock_set_state_args {
+    u64 __unused_common;
+    const void *skaddr;
+    int oldstate;
+    int newstate;
+};
+
 // initializing variables with __builtin_memset() is required
 // for compatibility with bpf on kernel 4.4
 
@@ -597,6 +618,37 @@ int kprobe__iptunnel_xmit(struct pt_regs *ctx)
     return 0;
 };
 
+// The sockets go from CLOSE to SYN_SENT in the context of the process calling
+// connect(), so the owner of the socket is the current task.
+SEC("tracepoint/sock/inet_sock_set_state")
+int tracepoint__sock_inet_sock_set_state(struct inet_sock_set_state_args *args)
+{
+    if (args->newstate != TCP_SYN_SENT) {return 0;}
+
+    struct sock *sk = (struct sock *)args->skaddr;
+    struct socket *sock = NULL;
+    struct file *file = NULL;
+    struct inode *inode = NULL;
+    unsigned long i_ino = 0;
+    bpf_probe_read(&sock, sizeof(sock), &sk->sk_socket);
+    if (sock == NULL) {return 0;}
+    bpf_probe_read(&file, sizeof(file), &sock->file);
+    // kernel sockets
+    if (file == NULL) {return 0;}
+    bpf_probe_read(&inode, sizeof(inode), &file->f_inode);
+    if (inode == NULL) {return 0;}
+    bpf_probe_read(&i_ino, sizeof(i_ino), &inode->i_ino);
+    u64 ino = i_ino;
+
+    struct tcp_value_t owner;
+    __builtin_memset(&owner, 0, sizeof(owner));
+    owner.pid = bpf_get_current_pid_tgid() >> 32;
+    owner.uid = bpf_get_current_uid_gid() & 0xffffffff;
+    bpf_get_current_comm(&owner.comm, sizeof(owner.comm));
+    bpf_map_update_elem(&sockOwnerMap, &ino, &owner, BPF_ANY);
+
+    return 0;
+};
 
 char _license[] SEC("license") = "GPL";
 // this number will be interpreted by the elf loader
@@ -0,0 +1,127 @@
+import hashlib
+import hmac
+import threading
+import time
+
+import grpc
+
+# metadata of the requests with the proof that the daemon knows the token:
+# <unix time>.<nonce>.<HMAC-SHA256 of the time and nonce>
+# The token itself is never sent.
+TOKEN_HEADER = "x-opensnitch-auth"
+# metadata of the responses with the proof that the GUI knows the token too:
+# the HMAC-SHA256 of "reply." and the proof of the request.
+TOKEN_REPLY_HEADER = "x-opensnitch-auth-reply"
+
+# max difference between the time of the proof and the time of the GUI.
+MAX_TOKEN_SKEW = 5 * 60
+MIN_TOKEN_SIZE = 16
+
+
+def read_token(path):
+    """read the token shared with the daemons (Server.Authentication.TokenFile
+    of the daemon). It must have at least 16 bytes.
+    """
+    with open(path, "rb") as f:
+        token = f.read().strip()
+    if len(token) < MIN_TOKEN_SIZE:
+        raise ValueError("auth token {0}: too short, at least {1} bytes are needed".format(path, MIN_TOKEN_SIZE))
+    return token
+
+
+def token_mac(token, msg):
+    return hmac.new(token, msg.encode(), hashlib.sha256).hexdigest()
+
+
+def token_reply(token, proof):
+    return token_mac(token, "reply." + proof)
+
+
+class TokenVerifier():
+    """TokenVerifier checks the proofs of the token sent by the daemons.
+    Every proof is accepted only once, to reject the requests replayed by a
+    process that captured them.
+    """
+
+    def __init__(self, token):
+        self._token = token
+        self._seen = {}
+        self._lock = threading.Lock()
+
+    def verify(self, proof):
+        """returns None if the proof is valid, or the error otherwise."""
+        if proof is None:
+            return "token required"
+        msg, _, mac = proof.rpartition(".")
+        if msg == "" or not hmac.compare_digest(mac, token_mac(self._token, msg)):
+            return "invalid token"
+        fields = msg.split(".", 1)
+        try:
+            ts = int(fields[0])
+        except ValueError:
+            return "invalid token proof"
+        if len(fields) != 2:
+            return "invalid token proof"
+        now = time.time()
+        if abs(now - ts) > MAX_TOKEN_SKEW:
+            return "token proof expired, check the clock of the hosts"
+
+        with self._lock:
+            # the proofs older than the skew are rejected anyway.
+            for nonce, seen in list(self._seen.items()):
+                if now - seen > MAX_TOKEN_SKEW:
+                    del self._seen[nonce]
+            if fields[1] in self._seen:
+                return "token proof already used"
+            self._seen[fields[1]] = ts
+        return None
+
+
+class TokenInterceptor(grpc.ServerInterceptor):
+    """TokenInterceptor rejects the requests of the daemons that don't prove
+    they know the token, and proves to the daemons that the GUI knows it too.
+    """
+
+    def __init__(self, token):
+        self._token = token
+        self._verifier = TokenVerifier(token)
+
+    def intercept_service(self, continuation, handler_call_details):
+        handler = continuation(handler_call_details)
+        if handler is None:
+            return None
+        proof = dict(handler_call_details.invocation_metadata).get(TOKEN_HEADER)
+        err = self._verifier.verify(proof)
+        if err is not None:
+            def reject(request, context):
+                context.abort(grpc.StatusCode.UNAUTHENTICATED, err)
+            return self._wrap(handler, reject)
+
+        reply = ((TOKEN_REPLY_HEADER, token_reply(self._token, proof)),)
+        return self._wrap(handler, None, reply)
+
+    def _wrap(self, handler, reject, reply=None):
+        """returns a handler of the same type, that rejects the request, or
+        sends the proof of the token before calling the original one.
+        """
+        for kind, new_handler in (
+            ("unary_unary", grpc.unary_unary_rpc_method_handler),
+            ("unary_stream", grpc.unary_stream_rpc_method_handler),
+            ("stream_unary", grpc.stream_unary_rpc_method_handler),
+            ("stream_stream", grpc.stream_stream_rpc_method_handler)):
+            behavior = getattr(handler, kind)
+            if behavior is None:
+                continue
+
+            def wrapped(request, context, behavior=behavior):
+                if reject is not None:
+                    return reject(request, context)
+                context.send_initial_metadata(reply)
+                return behavior(request, context)
+
+            return new_handler(
+                wrapped,
+                request_deserializer=handler.request_deserializer,
+                response_serializer=handler.response_serializer
+            )
+        return handler
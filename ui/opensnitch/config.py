@@ -176,6 +176,7 @@ class Config:
     AUTH_CA_CERT = "auth/cacert"
     AUTH_CERT = "auth/cert"
     AUTH_CERTKEY = "auth/certkey"
+    AUTH_TOKEN_FILE = "auth/token_file"
     # don't translate
 
     @staticmethod
@@ -15,6 +15,7 @@ ui_pb2, ui_pb2_grpc = proto.import_()
 class Nodes(QObject):
     __instance = None
     nodesUpdated = pyqtSignal(int) # total
+    stateChanged = pyqtSignal(str, ui_pb2.StateEvent) # addr, event
 
     LOG_TAG = "[Nodes]: "
     ONLINE = "\u2713 online"
@@ -399,6 +400,37 @@ class Nodes(QObject):
         except Exception as e:
             self.logger.warning("reply notification exception %s: %s", addr, repr(e))
 
+    def update_state(self, addr, reply):
+        """update_state applies the changes of the state of a node, that the
+        daemon streams as replies with id 0 (StateEvent), not related to any
+        notification sent by us.
+        """
+        try:
+            if not reply.HasField("event"):
+                return
+            event = reply.event
+            if addr not in self._nodes:
+                self.logger.debug("state event of an unknown node %s: %s", addr, event.type)
+                return
+            data = {}
+            if event.data != "" and event.type != ui_pb2.StateEvent.CONFIG_CHANGED:
+                data = json.loads(event.data)
+
+            if event.type == ui_pb2.StateEvent.CONFIG_CHANGED:
+                self.save_node_config(addr, event.data)
+            elif event.type == ui_pb2.StateEvent.FIREWALL_CHANGED:
+                self._nodes[addr]['data'].isFirewallRunning = data.get('running', False)
+            elif event.type == ui_pb2.StateEvent.RULES_CHANGED:
+                # the added and modified rules are sent by the daemon on
+                # the next subscription, but the deleted ones must be
+                # removed now, so they're not sent back to the daemon.
+                for name in data.get('deleted', []):
+                    self._db.delete_rule(name, addr)
+
+            self.stateChanged.emit(addr, event)
+        except Exception as e:
+            self.logger.warning("state event exception %s: %s", addr, repr(e))
+
     def stop_notifications(self, addr=None):
         """Send a dummy notification to force Notifications class to exit.
         """
@@ -13,7 +13,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x08ui.proto\x12\x08protocol\"\xcb\x04\n\x05\x41lert\x12\n\n\x02id\x18\x01 \x01(\x04\x12\"\n\x04type\x18\x02 \x01(\x0e\x32\x14.protocol.Alert.Type\x12&\n\x06\x61\x63tion\x18\x03 \x01(\x0e\x32\x16.protocol.Alert.Action\x12*\n\x08priority\x18\x04 \x01(\x0e\x32\x18.protocol.Alert.Priority\x12\"\n\x04what\x18\x05 \x01(\x0e\x32\x14.protocol.Alert.What\x12\x0e\n\x04text\x18\x06 \x01(\tH\x00\x12!\n\x04proc\x18\x08 \x01(\x0b\x32\x11.protocol.ProcessH\x00\x12$\n\x04\x63onn\x18\t \x01(\x0b\x32\x14.protocol.ConnectionH\x00\x12\x1e\n\x04rule\x18\n \x01(\x0b\x32\x0e.protocol.RuleH\x00\x12\"\n\x06\x66wrule\x18\x0b \x01(\x0b\x32\x10.protocol.FwRuleH\x00\")\n\x08Priority\x12\x07\n\x03LOW\x10\x00\x12\n\n\x06MEDIUM\x10\x01\x12\x08\n\x04HIGH\x10\x02\"(\n\x04Type\x12\t\n\x05\x45RROR\x10\x00\x12\x0b\n\x07WARNING\x10\x01\x12\x08\n\x04INFO\x10\x02\"2\n\x06\x41\x63tion\x12\x08\n\x04NONE\x10\x00\x12\x0e\n\nSHOW_ALERT\x10\x01\x12\x0e\n\nSAVE_TO_DB\x10\x02\"l\n\x04What\x12\x0b\n\x07GENERIC\x10\x00\x12\x10\n\x0cPROC_MONITOR\x10\x01\x12\x0c\n\x08\x46IREWALL\x10\x02\x12\x0e\n\nCONNECTION\x10\x03\x12\x08\n\x04RULE\x10\x04\x12\x0b\n\x07NETLINK\x10\x05\x12\x10\n\x0cKERNEL_EVENT\x10\x06\x42\x06\n\x04\x64\x61ta\"\x19\n\x0bMsgResponse\x12\n\n\x02id\x18\x01 \x01(\x04\"o\n\x05\x45vent\x12\x0c\n\x04time\x18\x01 \x01(\t\x12(\n\nconnection\x18\x02 \x01(\x0b\x32\x14.protocol.Connection\x12\x1c\n\x04rule\x18\x03 \x01(\x0b\x32\x0e.protocol.Rule\x12\x10\n\x08unixnano\x18\x04 \x01(\x03\"\xd3\x06\n\nStatistics\x12\x16\n\x0e\x64\x61\x65mon_version\x18\x01 \x01(\t\x12\r\n\x05rules\x18\x02 \x01(\x04\x12\x0e\n\x06uptime\x18\x03 \x01(\x04\x12\x15\n\rdns_responses\x18\x04 \x01(\x04\x12\x13\n\x0b\x63onnections\x18\x05 \x01(\x04\x12\x0f\n\x07ignored\x18\x06 \x01(\x04\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x07 \x01(\x04\x12\x0f\n\x07\x64ropped\x18\x08 \x01(\x04\x12\x11\n\trule_hits\x18\t \x01(\x04\x12\x13\n\x0brule_misses\x18\n \x01(\x04\x12\x33\n\x08\x62y_proto\x18\x0b \x03(\x0b\x32!.protocol.Statistics.ByProtoEntry\x12\x37\n\nby_address\x18\x0c \x03(\x0b\x32#.protocol.Statistics.ByAddressEntry\x12\x31\n\x07\x62y_host\x18\r \x03(\x0b\x32 .protocol.Statistics.ByHostEntry\x12\x31\n\x07\x62y_port\x18\x0e \x03(\x0b\x32 .protocol.Statistics.ByPortEntry\x12/\n\x06\x62y_uid\x18\x0f \x03(\x0b\x32\x1f.protocol.Statistics.ByUidEntry\x12=\n\rby_executable\x18\x10 \x03(\x0b\x32&.protocol.Statistics.ByExecutableEntry\x12\x1f\n\x06\x65vents\x18\x11 \x03(\x0b\x32\x0f.protocol.Event\x1a.\n\x0c\x42yProtoEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a\x30\n\x0e\x42yAddressEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a-\n\x0b\x42yHostEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a-\n\x0b\x42yPortEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a,\n\nByUidEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a\x33\n\x11\x42yExecutableEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\">\n\x0bPingRequest\x12\n\n\x02id\x18\x01 \x01(\x04\x12#\n\x05stats\x18\x02 \x01(\x0b\x32\x14.protocol.Statistics\"\x17\n\tPingReply\x12\n\n\x02id\x18\x01 \x01(\x04\"\'\n\tStringInt\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\r\"\x9b\x03\n\x07Process\x12\x0b\n\x03pid\x18\x01 \x01(\x04\x12\x0c\n\x04ppid\x18\x02 \x01(\x04\x12\x0b\n\x03uid\x18\x03 \x01(\x04\x12\x0c\n\x04\x63omm\x18\x04 \x01(\t\x12\x0c\n\x04path\x18\x05 \x01(\t\x12\x0c\n\x04\x61rgs\x18\x06 \x03(\t\x12\'\n\x03\x65nv\x18\x07 \x03(\x0b\x32\x1a.protocol.Process.EnvEntry\x12\x0b\n\x03\x63wd\x18\x08 \x01(\t\x12\x33\n\tchecksums\x18\t \x03(\x0b\x32 .protocol.Process.ChecksumsEntry\x12\x10\n\x08io_reads\x18\n \x01(\x04\x12\x11\n\tio_writes\x18\x0b \x01(\x04\x12\x11\n\tnet_reads\x18\x0c \x01(\x04\x12\x12\n\nnet_writes\x18\r \x01(\x04\x12)\n\x0cprocess_tree\x18\x0e \x03(\x0b\x32\x13.protocol.StringInt\x1a*\n\x08\x45nvEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\x1a\x30\n\x0e\x43hecksumsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\xf3\x03\n\nConnection\x12\x10\n\x08protocol\x18\x01 \x01(\t\x12\x0e\n\x06src_ip\x18\x02 \x01(\t\x12\x10\n\x08src_port\x18\x03 \x01(\r\x12\x0e\n\x06\x64st_ip\x18\x04 \x01(\t\x12\x10\n\x08\x64st_host\x18\x05 \x01(\t\x12\x10\n\x08\x64st_port\x18\x06 \x01(\r\x12\x0f\n\x07user_id\x18\x07 \x01(\r\x12\x12\n\nprocess_id\x18\x08 \x01(\r\x12\x14\n\x0cprocess_path\x18\t \x01(\t\x12\x13\n\x0bprocess_cwd\x18\n \x01(\t\x12\x14\n\x0cprocess_args\x18\x0b \x03(\t\x12\x39\n\x0bprocess_env\x18\x0c \x03(\x0b\x32$.protocol.Connection.ProcessEnvEntry\x12\x45\n\x11process_checksums\x18\r \x03(\x0b\x32*.protocol.Connection.ProcessChecksumsEntry\x12)\n\x0cprocess_tree\x18\x0e \x03(\x0b\x32\x13.protocol.StringInt\x1a\x31\n\x0fProcessEnvEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\x1a\x37\n\x15ProcessChecksumsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"l\n\x08Operator\x12\x0c\n\x04type\x18\x01 \x01(\t\x12\x0f\n\x07operand\x18\x02 \x01(\t\x12\x0c\n\x04\x64\x61ta\x18\x03 \x01(\t\x12\x11\n\tsensitive\x18\x04 \x01(\x08\x12 \n\x04list\x18\x05 \x03(\x0b\x32\x12.protocol.Operator\"\xb6\x01\n\x04Rule\x12\x0f\n\x07\x63reated\x18\x01 \x01(\x03\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x03 \x01(\t\x12\x0f\n\x07\x65nabled\x18\x04 \x01(\x08\x12\x12\n\nprecedence\x18\x05 \x01(\x08\x12\r\n\x05nolog\x18\x06 \x01(\x08\x12\x0e\n\x06\x61\x63tion\x18\x07 \x01(\t\x12\x10\n\x08\x64uration\x18\x08 \x01(\t\x12$\n\x08operator\x18\t \x01(\x0b\x32\x12.protocol.Operator\"-\n\x0fStatementValues\x12\x0b\n\x03Key\x18\x01 \x01(\t\x12\r\n\x05Value\x18\x02 \x01(\t\"P\n\tStatement\x12\n\n\x02Op\x18\x01 \x01(\t\x12\x0c\n\x04Name\x18\x02 \x01(\t\x12)\n\x06Values\x18\x03 \x03(\x0b\x32\x19.protocol.StatementValues\"5\n\x0b\x45xpressions\x12&\n\tStatement\x18\x01 \x01(\x0b\x32\x13.protocol.Statement\"\xd6\x01\n\x06\x46wRule\x12\r\n\x05Table\x18\x01 \x01(\t\x12\r\n\x05\x43hain\x18\x02 \x01(\t\x12\x0c\n\x04UUID\x18\x03 \x01(\t\x12\x0f\n\x07\x45nabled\x18\x04 \x01(\x08\x12\x10\n\x08Position\x18\x05 \x01(\x04\x12\x13\n\x0b\x44\x65scription\x18\x06 \x01(\t\x12\x12\n\nParameters\x18\x07 \x01(\t\x12*\n\x0b\x45xpressions\x18\x08 \x03(\x0b\x32\x15.protocol.Expressions\x12\x0e\n\x06Target\x18\t \x01(\t\x12\x18\n\x10TargetParameters\x18\n \x01(\t\"\x95\x01\n\x07\x46wChain\x12\x0c\n\x04Name\x18\x01 \x01(\t\x12\r\n\x05Table\x18\x02 \x01(\t\x12\x0e\n\x06\x46\x61mily\x18\x03 \x01(\t\x12\x10\n\x08Priority\x18\x04 \x01(\t\x12\x0c\n\x04Type\x18\x05 \x01(\t\x12\x0c\n\x04Hook\x18\x06 \x01(\t\x12\x0e\n\x06Policy\x18\x07 \x01(\t\x12\x1f\n\x05Rules\x18\x08 \x03(\x0b\x32\x10.protocol.FwRule\"M\n\x08\x46wChains\x12\x1e\n\x04Rule\x18\x01 \x01(\x0b\x32\x10.protocol.FwRule\x12!\n\x06\x43hains\x18\x02 \x03(\x0b\x32\x11.protocol.FwChain\"X\n\x0bSysFirewall\x12\x0f\n\x07\x45nabled\x18\x01 \x01(\x08\x12\x0f\n\x07Version\x18\x02 \x01(\r\x12\'\n\x0bSystemRules\x18\x03 \x03(\x0b\x32\x12.protocol.FwChains\"\xc4\x01\n\x0c\x43lientConfig\x12\n\n\x02id\x18\x01 \x01(\x04\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x0f\n\x07version\x18\x03 \x01(\t\x12\x19\n\x11isFirewallRunning\x18\x04 \x01(\x08\x12\x0e\n\x06\x63onfig\x18\x05 \x01(\t\x12\x10\n\x08logLevel\x18\x06 \x01(\r\x12\x1d\n\x05rules\x18\x07 \x03(\x0b\x32\x0e.protocol.Rule\x12-\n\x0esystemFirewall\x18\x08 \x01(\x0b\x32\x15.protocol.SysFirewall\"\xbb\x01\n\x0cNotification\x12\n\n\x02id\x18\x01 \x01(\x04\x12\x12\n\nclientName\x18\x02 \x01(\t\x12\x12\n\nserverName\x18\x03 \x01(\t\x12\x1e\n\x04type\x18\x04 \x01(\x0e\x32\x10.protocol.Action\x12\x0c\n\x04\x64\x61ta\x18\x05 \x01(\t\x12\x1d\n\x05rules\x18\x06 \x03(\x0b\x32\x0e.protocol.Rule\x12*\n\x0bsysFirewall\x18\x07 \x01(\x0b\x32\x15.protocol.SysFirewall\"\\\n\x11NotificationReply\x12\n\n\x02id\x18\x01 \x01(\x04\x12-\n\x04\x63ode\x18\x02 \x01(\x0e\x32\x1f.protocol.NotificationReplyCode\x12\x0c\n\x04\x64\x61ta\x18\x03 \x01(\t*\x95\x02\n\x06\x41\x63tion\x12\x08\n\x04NONE\x10\x00\x12\x17\n\x13\x45NABLE_INTERCEPTION\x10\x01\x12\x18\n\x14\x44ISABLE_INTERCEPTION\x10\x02\x12\x13\n\x0f\x45NABLE_FIREWALL\x10\x03\x12\x14\n\x10\x44ISABLE_FIREWALL\x10\x04\x12\x13\n\x0fRELOAD_FW_RULES\x10\x05\x12\x11\n\rCHANGE_CONFIG\x10\x06\x12\x0f\n\x0b\x45NABLE_RULE\x10\x07\x12\x10\n\x0c\x44ISABLE_RULE\x10\x08\x12\x0f\n\x0b\x44\x45LETE_RULE\x10\t\x12\x0f\n\x0b\x43HANGE_RULE\x10\n\x12\r\n\tLOG_LEVEL\x10\x0b\x12\x08\n\x04STOP\x10\x0c\x12\x0e\n\nTASK_START\x10\r\x12\r\n\tTASK_STOP\x10\x0e**\n\x15NotificationReplyCode\x12\x06\n\x02OK\x10\x00\x12\t\n\x05\x45RROR\x10\x01\x32\xaf\x02\n\x02UI\x12\x34\n\x04Ping\x12\x15.protocol.PingRequest\x1a\x13.protocol.PingReply\"\x00\x12\x31\n\x07\x41skRule\x12\x14.protocol.Connection\x1a\x0e.protocol.Rule\"\x00\x12=\n\tSubscribe\x12\x16.protocol.ClientConfig\x1a\x16.protocol.ClientConfig\"\x00\x12J\n\rNotifications\x12\x1b.protocol.NotificationReply\x1a\x16.protocol.Notification\"\x00(\x01\x30\x01\x12\x35\n\tPostAlert\x12\x0f.protocol.Alert\x1a\x15.protocol.MsgResponse\"\x00\x42\x35Z3github.com/evilsocket/opensnitch/daemon/ui/protocolb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x08ui.proto\x12\x08protocol\"\x98\x05\n\x05\x41lert\x12\n\n\x02id\x18\x01 \x01(\x04\x12\"\n\x04type\x18\x02 \x01(\x0e\x32\x14.protocol.Alert.Type\x12&\n\x06\x61\x63tion\x18\x03 \x01(\x0e\x32\x16.protocol.Alert.Action\x12*\n\x08priority\x18\x04 \x01(\x0e\x32\x18.protocol.Alert.Priority\x12\"\n\x04what\x18\x05 \x01(\x0e\x32\x14.protocol.Alert.What\x12\x0e\n\x04text\x18\x06 \x01(\tH\x00\x12!\n\x04proc\x18\x08 \x01(\x0b\x32\x11.protocol.ProcessH\x00\x12$\n\x04\x63onn\x18\t \x01(\x0b\x32\x14.protocol.ConnectionH\x00\x12\x1e\n\x04rule\x18\n \x01(\x0b\x32\x0e.protocol.RuleH\x00\x12\"\n\x06\x66wrule\x18\x0b \x01(\x0b\x32\x10.protocol.FwRuleH\x00\x12\x1e\n\x04\x66low\x18\x0c \x01(\x0b\x32\x0e.protocol.FlowH\x00\")\n\x08Priority\x12\x07\n\x03LOW\x10\x00\x12\n\n\x06MEDIUM\x10\x01\x12\x08\n\x04HIGH\x10\x02\"(\n\x04Type\x12\t\n\x05\x45RROR\x10\x00\x12\x0b\n\x07WARNING\x10\x01\x12\x08\n\x04INFO\x10\x02\"2\n\x06\x41\x63tion\x12\x08\n\x04NONE\x10\x00\x12\x0e\n\nSHOW_ALERT\x10\x01\x12\x0e\n\nSAVE_TO_DB\x10\x02\"\x98\x01\n\x04What\x12\x0b\n\x07GENERIC\x10\x00\x12\x10\n\x0cPROC_MONITOR\x10\x01\x12\x0c\n\x08\x46IREWALL\x10\x02\x12\x0e\n\nCONNECTION\x10\x03\x12\x08\n\x04RULE\x10\x04\x12\x0b\n\x07NETLINK\x10\x05\x12\x10\n\x0cKERNEL_EVENT\x10\x06\x12\x13\n\x0fRULE_SUGGESTION\x10\x07\x12\x15\n\x11\x43ONNECTION_CLOSED\x10\x08\x42\x06\n\x04\x64\x61ta\"\x19\n\x0bMsgResponse\x12\n\n\x02id\x18\x01 \x01(\x04\"\x83\x01\n\x05\x45vent\x12\x0c\n\x04time\x18\x01 \x01(\t\x12(\n\nconnection\x18\x02 \x01(\x0b\x32\x14.protocol.Connection\x12\x1c\n\x04rule\x18\x03 \x01(\x0b\x32\x0e.protocol.Rule\x12\x10\n\x08unixnano\x18\x04 \x01(\x03\x12\x12\n\nhistorical\x18\x05 \x01(\x08\"\x8a\n\n\nStatistics\x12\x16\n\x0e\x64\x61\x65mon_version\x18\x01 \x01(\t\x12\r\n\x05rules\x18\x02 \x01(\x04\x12\x0e\n\x06uptime\x18\x03 \x01(\x04\x12\x15\n\rdns_responses\x18\x04 \x01(\x04\x12\x13\n\x0b\x63onnections\x18\x05 \x01(\x04\x12\x0f\n\x07ignored\x18\x06 \x01(\x04\x12\x10\n\x08\x61\x63\x63\x65pted\x18\x07 \x01(\x04\x12\x0f\n\x07\x64ropped\x18\x08 \x01(\x04\x12\x11\n\trule_hits\x18\t \x01(\x04\x12\x13\n\x0brule_misses\x18\n \x01(\x04\x12\x33\n\x08\x62y_proto\x18\x0b \x03(\x0b\x32!.protocol.Statistics.ByProtoEntry\x12\x37\n\nby_address\x18\x0c \x03(\x0b\x32#.protocol.Statistics.ByAddressEntry\x12\x31\n\x07\x62y_host\x18\r \x03(\x0b\x32 .protocol.Statistics.ByHostEntry\x12\x31\n\x07\x62y_port\x18\x0e \x03(\x0b\x32 .protocol.Statistics.ByPortEntry\x12/\n\x06\x62y_uid\x18\x0f \x03(\x0b\x32\x1f.protocol.Statistics.ByUidEntry\x12=\n\rby_executable\x18\x10 \x03(\x0b\x32&.protocol.Statistics.ByExecutableEntry\x12\x1f\n\x06\x65vents\x18\x11 \x03(\x0b\x32\x0f.protocol.Event\x12$\n\x06queues\x18\x12 \x03(\x0b\x32\x14.protocol.QueueStats\x12?\n\x0e\x64ropped_events\x18\x13 \x03(\x0b\x32\'.protocol.Statistics.DroppedEventsEntry\x12/\n\x06\x62y_tag\x18\x14 \x03(\x0b\x32\x1f.protocol.Statistics.ByTagEntry\x12H\n\x13\x62ytes_by_executable\x18\x15 \x03(\x0b\x32+.protocol.Statistics.BytesByExecutableEntry\x12\x35\n\x15traffic_by_executable\x18\x16 \x03(\x0b\x32\x16.protocol.TrafficStats\x1a.\n\x0c\x42yProtoEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a\x30\n\x0e\x42yAddressEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a-\n\x0b\x42yHostEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a-\n\x0b\x42yPortEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a,\n\nByUidEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a\x33\n\x11\x42yExecutableEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a\x34\n\x12\x44roppedEventsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a,\n\nByTagEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\x1a\x38\n\x16\x42ytesByExecutableEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x04:\x02\x38\x01\"\x85\x01\n\x0cTrafficStats\x12\x0c\n\x04path\x18\x01 \x01(\t\x12\x12\n\nbytes_sent\x18\x02 \x01(\x04\x12\x12\n\nbytes_recv\x18\x03 \x01(\x04\x12\x14\n\x0cpackets_sent\x18\x04 \x01(\x04\x12\x14\n\x0cpackets_recv\x18\x05 \x01(\x04\x12\x13\n\x0b\x63onnections\x18\x06 \x01(\x04\"r\n\nQueueStats\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\x0b\n\x03num\x18\x02 \x01(\r\x12\r\n\x05total\x18\x03 \x01(\x04\x12\x0f\n\x07\x64ropped\x18\x04 \x01(\x04\x12\x14\n\x0cuser_dropped\x18\x05 \x01(\x04\x12\x13\n\x0bid_sequence\x18\x06 \x01(\x04\">\n\x0bPingRequest\x12\n\n\x02id\x18\x01 \x01(\x04\x12#\n\x05stats\x18\x02 \x01(\x0b\x32\x14.protocol.Statistics\"\x17\n\tPingReply\x12\n\n\x02id\x18\x01 \x01(\x04\"\'\n\tStringInt\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\r\"\xf5\x03\n\x07Process\x12\x0b\n\x03pid\x18\x01 \x01(\x04\x12\x0c\n\x04ppid\x18\x02 \x01(\x04\x12\x0b\n\x03uid\x18\x03 \x01(\x04\x12\x0c\n\x04\x63omm\x18\x04 \x01(\t\x12\x0c\n\x04path\x18\x05 \x01(\t\x12\x0c\n\x04\x61rgs\x18\x06 \x03(\t\x12\'\n\x03\x65nv\x18\x07 \x03(\x0b\x32\x1a.protocol.Process.EnvEntry\x12\x0b\n\x03\x63wd\x18\x08 \x01(\t\x12\x33\n\tchecksums\x18\t \x03(\x0b\x32 .protocol.Process.ChecksumsEntry\x12\x10\n\x08io_reads\x18\n \x01(\x04\x12\x11\n\tio_writes\x18\x0b \x01(\x04\x12\x11\n\tnet_reads\x18\x0c \x01(\x04\x12\x12\n\nnet_writes\x18\r \x01(\x04\x12)\n\x0cprocess_tree\x18\x0e \x03(\x0b\x32\x13.protocol.StringInt\x12\x0f\n\x07\x63\x61p_eff\x18\x0f \x01(\x04\x12\x0e\n\x06mnt_ns\x18\x10 \x01(\x04\x12\x0e\n\x06net_ns\x18\x11 \x01(\x04\x12\x0f\n\x07user_ns\x18\x12 \x01(\x04\x12\x16\n\x0esecurity_label\x18\x13 \x01(\t\x1a*\n\x08\x45nvEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\x1a\x30\n\x0e\x43hecksumsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\xd1\x07\n\nConnection\x12\x10\n\x08protocol\x18\x01 \x01(\t\x12\x0e\n\x06src_ip\x18\x02 \x01(\t\x12\x10\n\x08src_port\x18\x03 \x01(\r\x12\x0e\n\x06\x64st_ip\x18\x04 \x01(\t\x12\x10\n\x08\x64st_host\x18\x05 \x01(\t\x12\x10\n\x08\x64st_port\x18\x06 \x01(\r\x12\x0f\n\x07user_id\x18\x07 \x01(\r\x12\x12\n\nprocess_id\x18\x08 \x01(\r\x12\x14\n\x0cprocess_path\x18\t \x01(\t\x12\x13\n\x0bprocess_cwd\x18\n \x01(\t\x12\x14\n\x0cprocess_args\x18\x0b \x03(\t\x12\x39\n\x0bprocess_env\x18\x0c \x03(\x0b\x32$.protocol.Connection.ProcessEnvEntry\x12\x45\n\x11process_checksums\x18\r \x03(\x0b\x32*.protocol.Connection.ProcessChecksumsEntry\x12)\n\x0cprocess_tree\x18\x0e \x03(\x0b\x32\x13.protocol.StringInt\x12\x18\n\x10process_app_name\x18\x0f \x01(\t\x12\x18\n\x10process_app_icon\x18\x10 \x01(\t\x12\x1e\n\x16process_package_status\x18\x11 \x01(\t\x12\x17\n\x0fprocess_cap_eff\x18\x12 \x01(\x04\x12\x16\n\x0eprocess_mnt_ns\x18\x13 \x01(\x04\x12\x16\n\x0eprocess_net_ns\x18\x14 \x01(\x04\x12\x17\n\x0fprocess_user_ns\x18\x15 \x01(\x04\x12\x0c\n\x04tags\x18\x16 \x03(\t\x12\x14\n\x0csandbox_type\x18\x17 \x01(\t\x12\x14\n\x0csandbox_name\x18\x18 \x01(\t\x12\x1a\n\x12sandbox_owner_path\x18\x19 \x01(\t\x12\x19\n\x11sandbox_owner_pid\x18\x1a \x01(\r\x12\x1e\n\x16process_security_label\x18\x1b \x01(\t\x12\x11\n\tdst_scope\x18\x1c \x01(\t\x12\x0f\n\x07\x64st_mac\x18\x1d \x01(\t\x12-\n\x0bsuggestions\x18\x1e \x03(\x0b\x32\x18.protocol.RuleSuggestion\x12\x13\n\x0borigin_path\x18\x1f \x01(\t\x12\x12\n\norigin_pid\x18  \x01(\r\x12\x19\n\x11process_libraries\x18! \x03(\t\x1a\x31\n\x0fProcessEnvEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\x1a\x37\n\x15ProcessChecksumsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"Y\n\x0eRuleSuggestion\x12\x13\n\x0b\x64\x65scription\x18\x01 \x01(\t\x12$\n\x08operator\x18\x02 \x01(\x0b\x32\x12.protocol.Operator\x12\x0c\n\x04hits\x18\x03 \x01(\r\"l\n\x08Operator\x12\x0c\n\x04type\x18\x01 \x01(\t\x12\x0f\n\x07operand\x18\x02 \x01(\t\x12\x0c\n\x04\x64\x61ta\x18\x03 \x01(\t\x12\x11\n\tsensitive\x18\x04 \x01(\x08\x12 \n\x04list\x18\x05 \x03(\x0b\x32\x12.protocol.Operator\"\x83\x03\n\x04Rule\x12\x0f\n\x07\x63reated\x18\x01 \x01(\x03\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x13\n\x0b\x64\x65scription\x18\x03 \x01(\t\x12\x0f\n\x07\x65nabled\x18\x04 \x01(\x08\x12\x12\n\nprecedence\x18\x05 \x01(\x08\x12\r\n\x05nolog\x18\x06 \x01(\x08\x12\x0e\n\x06\x61\x63tion\x18\x07 \x01(\t\x12\x10\n\x08\x64uration\x18\x08 \x01(\t\x12$\n\x08operator\x18\t \x01(\x0b\x32\x12.protocol.Operator\x12\x10\n\x08priority\x18\n \x01(\x05\x12\x0c\n\x04tags\x18\x0b \x03(\t\x12\x0c\n\x04kill\x18\x0c \x01(\t\x12\x0c\n\x04jail\x18\r \x01(\x08\x12\r\n\x05score\x18\x0e \x01(\x05\x12\r\n\x05proxy\x18\x0f \x01(\t\x12\x11\n\tlog_level\x18\x10 \x01(\t\x12\x12\n\nlog_target\x18\x11 \x01(\t\x12\x10\n\x08log_file\x18\x12 \x01(\t\x12\x11\n\thook_path\x18\x13 \x01(\t\x12\x0f\n\x07hook_on\x18\x14 \x03(\t\x12\x14\n\x0chook_timeout\x18\x15 \x01(\t\"-\n\x0fStatementValues\x12\x0b\n\x03Key\x18\x01 \x01(\t\x12\r\n\x05Value\x18\x02 \x01(\t\"P\n\tStatement\x12\n\n\x02Op\x18\x01 \x01(\t\x12\x0c\n\x04Name\x18\x02 \x01(\t\x12)\n\x06Values\x18\x03 \x03(\x0b\x32\x19.protocol.StatementValues\"5\n\x0b\x45xpressions\x12&\n\tStatement\x18\x01 \x01(\x0b\x32\x13.protocol.Statement\"\xd6\x01\n\x06\x46wRule\x12\r\n\x05Table\x18\x01 \x01(\t\x12\r\n\x05\x43hain\x18\x02 \x01(\t\x12\x0c\n\x04UUID\x18\x03 \x01(\t\x12\x0f\n\x07\x45nabled\x18\x04 \x01(\x08\x12\x10\n\x08Position\x18\x05 \x01(\x04\x12\x13\n\x0b\x44\x65scription\x18\x06 \x01(\t\x12\x12\n\nParameters\x18\x07 \x01(\t\x12*\n\x0b\x45xpressions\x18\x08 \x03(\x0b\x32\x15.protocol.Expressions\x12\x0e\n\x06Target\x18\t \x01(\t\x12\x18\n\x10TargetParameters\x18\n \x01(\t\"\x95\x01\n\x07\x46wChain\x12\x0c\n\x04Name\x18\x01 \x01(\t\x12\r\n\x05Table\x18\x02 \x01(\t\x12\x0e\n\x06\x46\x61mily\x18\x03 \x01(\t\x12\x10\n\x08Priority\x18\x04 \x01(\t\x12\x0c\n\x04Type\x18\x05 \x01(\t\x12\x0c\n\x04Hook\x18\x06 \x01(\t\x12\x0e\n\x06Policy\x18\x07 \x01(\t\x12\x1f\n\x05Rules\x18\x08 \x03(\x0b\x32\x10.protocol.FwRule\"\x99\x01\n\x05\x46wMap\x12\x0c\n\x04Name\x18\x01 \x01(\t\x12\r\n\x05Table\x18\x02 \x01(\t\x12\x0e\n\x06\x46\x61mily\x18\x03 \x01(\t\x12\x13\n\x0b\x44\x65scription\x18\x04 \x01(\t\x12\x0f\n\x07KeyType\x18\x05 \x01(\t\x12\x10\n\x08\x44\x61taType\x18\x06 \x01(\t\x12+\n\x08\x45lements\x18\x07 \x03(\x0b\x32\x19.protocol.StatementValues\"\x85\x01\n\x08\x46wObject\x12\x0c\n\x04Name\x18\x01 \x01(\t\x12\r\n\x05Table\x18\x02 \x01(\t\x12\x0e\n\x06\x46\x61mily\x18\x03 \x01(\t\x12\x13\n\x0b\x44\x65scription\x18\x04 \x01(\t\x12\x0c\n\x04Type\x18\x05 \x01(\t\x12)\n\x06Values\x18\x06 \x03(\x0b\x32\x19.protocol.StatementValues\"\x91\x01\n\x08\x46wChains\x12\x1e\n\x04Rule\x18\x01 \x01(\x0b\x32\x10.protocol.FwRule\x12!\n\x06\x43hains\x18\x02 \x03(\x0b\x32\x11.protocol.FwChain\x12\x1d\n\x04Maps\x18\x03 \x03(\x0b\x32\x0f.protocol.FwMap\x12#\n\x07Objects\x18\x04 \x03(\x0b\x32\x12.protocol.FwObject\"X\n\x0bSysFirewall\x12\x0f\n\x07\x45nabled\x18\x01 \x01(\x08\x12\x0f\n\x07Version\x18\x02 \x01(\r\x12\'\n\x0bSystemRules\x18\x03 \x03(\x0b\x32\x12.protocol.FwChains\"\xc4\x01\n\x0c\x43lientConfig\x12\n\n\x02id\x18\x01 \x01(\x04\x12\x0c\n\x04name\x18\x02 \x01(\t\x12\x0f\n\x07version\x18\x03 \x01(\t\x12\x19\n\x11isFirewallRunning\x18\x04 \x01(\x08\x12\x0e\n\x06\x63onfig\x18\x05 \x01(\t\x12\x10\n\x08logLevel\x18\x06 \x01(\r\x12\x1d\n\x05rules\x18\x07 \x03(\x0b\x32\x0e.protocol.Rule\x12-\n\x0esystemFirewall\x18\x08 \x01(\x0b\x32\x15.protocol.SysFirewall\"\xbb\x01\n\x0cNotification\x12\n\n\x02id\x18\x01 \x01(\x04\x12\x12\n\nclientName\x18\x02 \x01(\t\x12\x12\n\nserverName\x18\x03 \x01(\t\x12\x1e\n\x04type\x18\x04 \x01(\x0e\x32\x10.protocol.Action\x12\x0c\n\x04\x64\x61ta\x18\x05 \x01(\t\x12\x1d\n\x05rules\x18\x06 \x03(\x0b\x32\x0e.protocol.Rule\x12*\n\x0bsysFirewall\x18\x07 \x01(\x0b\x32\x15.protocol.SysFirewall\"\xcf\x02\n\x11NotificationReply\x12\n\n\x02id\x18\x01 \x01(\x04\x12-\n\x04\x63ode\x18\x02 \x01(\x0e\x32\x1f.protocol.NotificationReplyCode\x12\x0c\n\x04\x64\x61ta\x18\x03 \x01(\t\x12(\n\x0brules_stats\x18\x04 \x03(\x0b\x32\x13.protocol.RuleStats\x12\'\n\nerror_code\x18\x05 \x01(\x0e\x32\x13.protocol.ErrorCode\x12\x44\n\rerror_details\x18\x06 \x03(\x0b\x32-.protocol.NotificationReply.ErrorDetailsEntry\x12#\n\x05\x65vent\x18\x07 \x01(\x0b\x32\x14.protocol.StateEvent\x1a\x33\n\x11\x45rrorDetailsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"\xca\x01\n\nStateEvent\x12\'\n\x04type\x18\x01 \x01(\x0e\x32\x19.protocol.StateEvent.Type\x12\x0c\n\x04time\x18\x02 \x01(\x03\x12\x0e\n\x06source\x18\x03 \x01(\t\x12\x0c\n\x04\x64\x61ta\x18\x04 \x01(\t\"g\n\x04Type\x12\x08\n\x04NONE\x10\x00\x12\x11\n\rRULES_CHANGED\x10\x01\x12\x12\n\x0e\x43ONFIG_CHANGED\x10\x02\x12\x14\n\x10\x46IREWALL_CHANGED\x10\x03\x12\x18\n\x14INTERCEPTION_CHANGED\x10\x04\"\x81\x01\n\tRuleStats\x12\x0c\n\x04name\x18\x01 \x01(\t\x12\x0c\n\x04hits\x18\x02 \x01(\x04\x12\x10\n\x08last_hit\x18\x03 \x01(\x03\x12\r\n\x05\x62ytes\x18\x04 \x01(\x04\x12\x0f\n\x07packets\x18\x05 \x01(\x04\x12\x12\n\nbytes_sent\x18\x06 \x01(\x04\x12\x12\n\nbytes_recv\x18\x07 \x01(\x04\"\xb2\x01\n\x04\x46low\x12(\n\nconnection\x18\x01 \x01(\x0b\x32\x14.protocol.Connection\x12\x0c\n\x04rule\x18\x02 \x01(\t\x12\x0f\n\x07started\x18\x03 \x01(\x03\x12\r\n\x05\x65nded\x18\x04 \x01(\x03\x12\x12\n\nbytes_sent\x18\x05 \x01(\x04\x12\x12\n\nbytes_recv\x18\x06 \x01(\x04\x12\x14\n\x0cpackets_sent\x18\x07 \x01(\x04\x12\x14\n\x0cpackets_recv\x18\x08 \x01(\x04*\xb8\x05\n\x06\x41\x63tion\x12\x08\n\x04NONE\x10\x00\x12\x17\n\x13\x45NABLE_INTERCEPTION\x10\x01\x12\x18\n\x14\x44ISABLE_INTERCEPTION\x10\x02\x12\x13\n\x0f\x45NABLE_FIREWALL\x10\x03\x12\x14\n\x10\x44ISABLE_FIREWALL\x10\x04\x12\x13\n\x0fRELOAD_FW_RULES\x10\x05\x12\x11\n\rCHANGE_CONFIG\x10\x06\x12\x0f\n\x0b\x45NABLE_RULE\x10\x07\x12\x10\n\x0c\x44ISABLE_RULE\x10\x08\x12\x0f\n\x0b\x44\x45LETE_RULE\x10\t\x12\x0f\n\x0b\x43HANGE_RULE\x10\n\x12\r\n\tLOG_LEVEL\x10\x0b\x12\x08\n\x04STOP\x10\x0c\x12\x0e\n\nTASK_START\x10\r\x12\r\n\tTASK_STOP\x10\x0e\x12\x11\n\rREORDER_RULES\x10\x0f\x12\x13\n\x0fGET_RULES_STATS\x10\x10\x12\x10\n\x0c\x45XPORT_RULES\x10\x11\x12\x10\n\x0cIMPORT_RULES\x10\x12\x12\x11\n\rEXPORT_BUNDLE\x10\x13\x12\x11\n\rIMPORT_BUNDLE\x10\x14\x12\x13\n\x0fGET_EBPF_STATUS\x10\x15\x12\x0f\n\x0bTRACE_RULES\x10\x16\x12\x15\n\x11\x45NABLE_PANIC_MODE\x10\x17\x12\x16\n\x12\x44ISABLE_PANIC_MODE\x10\x18\x12\r\n\tBLOCK_APP\x10\x19\x12\x10\n\x0cGET_FEATURES\x10\x1a\x12\x11\n\rGET_DECISIONS\x10\x1b\x12\x14\n\x10PROMOTE_DECISION\x10\x1c\x12\x12\n\x0eGET_FW_OBJECTS\x10\x1d\x12\x11\n\rGET_DNS_CACHE\x10\x1e\x12\x13\n\x0f\x46LUSH_DNS_CACHE\x10\x1f\x12\x0f\n\x0bGET_UPDATES\x10 \x12\x17\n\x13GET_RULES_SNAPSHOTS\x10!\x12\x12\n\x0eROLLBACK_RULES\x10\"\x12\x16\n\x12TUNE_PROCESS_CACHE\x10#*\xff\x01\n\tErrorCode\x12\x0c\n\x08\x45RR_NONE\x10\x00\x12\x0f\n\x0b\x45RR_UNKNOWN\x10\x01\x12\x18\n\x14\x45RR_INVALID_ARGUMENT\x10\x02\x12\x14\n\x10\x45RR_INVALID_RULE\x10\x03\x12\x11\n\rERR_NOT_FOUND\x10\x04\x12\x16\n\x12\x45RR_INVALID_CONFIG\x10\x05\x12\x10\n\x0c\x45RR_FIREWALL\x10\x06\x12\x0c\n\x08\x45RR_SAVE\x10\x07\x12\x14\n\x10\x45RR_PROC_MONITOR\x10\x08\x12\x0f\n\x0b\x45RR_TIMEOUT\x10\t\x12\x16\n\x12\x45RR_INVALID_BUNDLE\x10\n\x12\x19\n\x15\x45RR_PERMISSION_DENIED\x10\x0b**\n\x15NotificationReplyCode\x12\x06\n\x02OK\x10\x00\x12\t\n\x05\x45RROR\x10\x01\x32\xaf\x02\n\x02UI\x12\x34\n\x04Ping\x12\x15.protocol.PingRequest\x1a\x13.protocol.PingReply\"\x00\x12\x31\n\x07\x41skRule\x12\x14.protocol.Connection\x1a\x0e.protocol.Rule\"\x00\x12=\n\tSubscribe\x12\x16.protocol.ClientConfig\x1a\x16.protocol.ClientConfig\"\x00\x12J\n\rNotifications\x12\x1b.protocol.NotificationReply\x1a\x16.protocol.Notification\"\x00(\x01\x30\x01\x12\x35\n\tPostAlert\x12\x0f.protocol.Alert\x1a\x15.protocol.MsgResponse\"\x00\x42\x35Z3github.com/evilsocket/opensnitch/daemon/ui/protocolb\x06proto3')
 
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, globals())
 _builder.BuildTopDescriptorsAndMessages(DESCRIPTOR, 'ui_pb2', globals())
@@ -33,6 +33,12 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   _STATISTICS_BYUIDENTRY._serialized_options = b'8\001'
   _STATISTICS_BYEXECUTABLEENTRY._options = None
   _STATISTICS_BYEXECUTABLEENTRY._serialized_options = b'8\001'
+  _STATISTICS_DROPPEDEVENTSENTRY._options = None
+  _STATISTICS_DROPPEDEVENTSENTRY._serialized_options = b'8\001'
+  _STATISTICS_BYTAGENTRY._options = None
+  _STATISTICS_BYTAGENTRY._serialized_options = b'8\001'
+  _STATISTICS_BYTESBYEXECUTABLEENTRY._options = None
+  _STATISTICS_BYTESBYEXECUTABLEENTRY._serialized_options = b'8\001'
   _PROCESS_ENVENTRY._options = None
   _PROCESS_ENVENTRY._serialized_options = b'8\001'
   _PROCESS_CHECKSUMSENTRY._options = None
@@ -41,80 +47,110 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   _CONNECTION_PROCESSENVENTRY._serialized_options = b'8\001'
   _CONNECTION_PROCESSCHECKSUMSENTRY._options = None
   _CONNECTION_PROCESSCHECKSUMSENTRY._serialized_options = b'8\001'
-  _ACTION._serialized_start=4153
-  _ACTION._serialized_end=4430
-  _NOTIFICATIONREPLYCODE._serialized_start=4432
-  _NOTIFICATIONREPLYCODE._serialized_end=4474
+  _NOTIFICATIONREPLY_ERRORDETAILSENTRY._options = None
+  _NOTIFICATIONREPLY_ERRORDETAILSENTRY._serialized_options = b'8\001'
+  _ACTION._serialized_start=6929
+  _ACTION._serialized_end=7625
+  _ERRORCODE._serialized_start=7628
+  _ERRORCODE._serialized_end=7883
+  _NOTIFICATIONREPLYCODE._serialized_start=7885
+  _NOTIFICATIONREPLYCODE._serialized_end=7927
   _ALERT._serialized_start=23
-  _ALERT._serialized_end=610
-  _ALERT_PRIORITY._serialized_start=357
-  _ALERT_PRIORITY._serialized_end=398
-  _ALERT_TYPE._serialized_start=400
-  _ALERT_TYPE._serialized_end=440
-  _ALERT_ACTION._serialized_start=442
-  _ALERT_ACTION._serialized_end=492
-  _ALERT_WHAT._serialized_start=494
-  _ALERT_WHAT._serialized_end=602
-  _MSGRESPONSE._serialized_start=612
-  _MSGRESPONSE._serialized_end=637
-  _EVENT._serialized_start=639
-  _EVENT._serialized_end=750
-  _STATISTICS._serialized_start=753
-  _STATISTICS._serialized_end=1604
-  _STATISTICS_BYPROTOENTRY._serialized_start=1315
-  _STATISTICS_BYPROTOENTRY._serialized_end=1361
-  _STATISTICS_BYADDRESSENTRY._serialized_start=1363
-  _STATISTICS_BYADDRESSENTRY._serialized_end=1411
-  _STATISTICS_BYHOSTENTRY._serialized_start=1413
-  _STATISTICS_BYHOSTENTRY._serialized_end=1458
-  _STATISTICS_BYPORTENTRY._serialized_start=1460
-  _STATISTICS_BYPORTENTRY._serialized_end=1505
-  _STATISTICS_BYUIDENTRY._serialized_start=1507
-  _STATISTICS_BYUIDENTRY._serialized_end=1551
-  _STATISTICS_BYEXECUTABLEENTRY._serialized_start=1553
-  _STATISTICS_BYEXECUTABLEENTRY._serialized_end=1604
-  _PINGREQUEST._serialized_start=1606
-  _PINGREQUEST._serialized_end=1668
-  _PINGREPLY._serialized_start=1670
-  _PINGREPLY._serialized_end=1693
-  _STRINGINT._serialized_start=1695
-  _STRINGINT._serialized_end=1734
-  _PROCESS._serialized_start=1737
-  _PROCESS._serialized_end=2148
-  _PROCESS_ENVENTRY._serialized_start=2056
-  _PROCESS_ENVENTRY._serialized_end=2098
-  _PROCESS_CHECKSUMSENTRY._serialized_start=2100
-  _PROCESS_CHECKSUMSENTRY._serialized_end=2148
-  _CONNECTION._serialized_start=2151
-  _CONNECTION._serialized_end=2650
-  _CONNECTION_PROCESSENVENTRY._serialized_start=2544
-  _CONNECTION_PROCESSENVENTRY._serialized_end=2593
-  _CONNECTION_PROCESSCHECKSUMSENTRY._serialized_start=2595
-  _CONNECTION_PROCESSCHECKSUMSENTRY._serialized_end=2650
-  _OPERATOR._serialized_start=2652
-  _OPERATOR._serialized_end=2760
-  _RULE._serialized_start=2763
-  _RULE._serialized_end=2945
-  _STATEMENTVALUES._serialized_start=2947
-  _STATEMENTVALUES._serialized_end=2992
-  _STATEMENT._serialized_start=2994
-  _STATEMENT._serialized_end=3074
-  _EXPRESSIONS._serialized_start=3076
-  _EXPRESSIONS._serialized_end=3129
-  _FWRULE._serialized_start=3132
-  _FWRULE._serialized_end=3346
-  _FWCHAIN._serialized_start=3349
-  _FWCHAIN._serialized_end=3498
-  _FWCHAINS._serialized_start=3500
-  _FWCHAINS._serialized_end=3577
-  _SYSFIREWALL._serialized_start=3579
-  _SYSFIREWALL._serialized_end=3667
-  _CLIENTCONFIG._serialized_start=3670
-  _CLIENTCONFIG._serialized_end=3866
-  _NOTIFICATION._serialized_start=3869
-  _NOTIFICATION._serialized_end=4056
-  _NOTIFICATIONREPLY._serialized_start=4058
-  _NOTIFICATIONREPLY._serialized_end=4150
-  _UI._serialized_start=4477
-  _UI._serialized_end=4780
+  _ALERT._serialized_end=687
+  _ALERT_PRIORITY._serialized_start=389
+  _ALERT_PRIORITY._serialized_end=430
+  _ALERT_TYPE._serialized_start=432
+  _ALERT_TYPE._serialized_end=472
+  _ALERT_ACTION._serialized_start=474
+  _ALERT_ACTION._serialized_end=524
+  _ALERT_WHAT._serialized_start=527
+  _ALERT_WHAT._serialized_end=679
+  _MSGRESPONSE._serialized_start=689
+  _MSGRESPONSE._serialized_end=714
+  _EVENT._serialized_start=717
+  _EVENT._serialized_end=848
+  _STATISTICS._serialized_start=851
+  _STATISTICS._serialized_end=2141
+  _STATISTICS_BYPROTOENTRY._serialized_start=1694
+  _STATISTICS_BYPROTOENTRY._serialized_end=1740
+  _STATISTICS_BYADDRESSENTRY._serialized_start=1742
+  _STATISTICS_BYADDRESSENTRY._serialized_end=1790
+  _STATISTICS_BYHOSTENTRY._serialized_start=1792
+  _STATISTICS_BYHOSTENTRY._serialized_end=1837
+  _STATISTICS_BYPORTENTRY._serialized_start=1839
+  _STATISTICS_BYPORTENTRY._serialized_end=1884
+  _STATISTICS_BYUIDENTRY._serialized_start=1886
+  _STATISTICS_BYUIDENTRY._serialized_end=1930
+  _STATISTICS_BYEXECUTABLEENTRY._serialized_start=1932
+  _STATISTICS_BYEXECUTABLEENTRY._serialized_end=1983
+  _STATISTICS_DROPPEDEVENTSENTRY._serialized_start=1985
+  _STATISTICS_DROPPEDEVENTSENTRY._serialized_end=2037
+  _STATISTICS_BYTAGENTRY._serialized_start=2039
+  _STATISTICS_BYTAGENTRY._serialized_end=2083
+  _STATISTICS_BYTESBYEXECUTABLEENTRY._serialized_start=2085
+  _STATISTICS_BYTESBYEXECUTABLEENTRY._serialized_end=2141
+  _TRAFFICSTATS._serialized_start=2144
+  _TRAFFICSTATS._serialized_end=2277
+  _QUEUESTATS._serialized_start=2279
+  _QUEUESTATS._serialized_end=2393
+  _PINGREQUEST._serialized_start=2395
+  _PINGREQUEST._serialized_end=2457
+  _PINGREPLY._serialized_start=2459
+  _PINGREPLY._serialized_end=2482
+  _STRINGINT._serialized_start=2484
+  _STRINGINT._serialized_end=2523
+  _PROCESS._serialized_start=2526
+  _PROCESS._serialized_end=3027
+  _PROCESS_ENVENTRY._serialized_start=2935
+  _PROCESS_ENVENTRY._serialized_end=2977
+  _PROCESS_CHECKSUMSENTRY._serialized_start=2979
+  _PROCESS_CHECKSUMSENTRY._serialized_end=3027
+  _CONNECTION._serialized_start=3030
+  _CONNECTION._serialized_end=4007
+  _CONNECTION_PROCESSENVENTRY._serialized_start=3901
+  _CONNECTION_PROCESSENVENTRY._serialized_end=3950
+  _CONNECTION_PROCESSCHECKSUMSENTRY._serialized_start=3952
+  _CONNECTION_PROCESSCHECKSUMSENTRY._serialized_end=4007
+  _RULESUGGESTION._serialized_start=4009
+  _RULESUGGESTION._serialized_end=4098
+  _OPERATOR._serialized_start=4100
+  _OPERATOR._serialized_end=4208
+  _RULE._serialized_start=4211
+  _RULE._serialized_end=4598
+  _STATEMENTVALUES._serialized_start=4600
+  _STATEMENTVALUES._serialized_end=4645
+  _STATEMENT._serialized_start=4647
+  _STATEMENT._serialized_end=4727
+  _EXPRESSIONS._serialized_start=4729
+  _EXPRESSIONS._serialized_end=4782
+  _FWRULE._serialized_start=4785
+  _FWRULE._serialized_end=4999
+  _FWCHAIN._serialized_start=5002
+  _FWCHAIN._serialized_end=5151
+  _FWMAP._serialized_start=5154
+  _FWMAP._serialized_end=5307
+  _FWOBJECT._serialized_start=5310
+  _FWOBJECT._serialized_end=5443
+  _FWCHAINS._serialized_start=5446
+  _FWCHAINS._serialized_end=5591
+  _SYSFIREWALL._serialized_start=5593
+  _SYSFIREWALL._serialized_end=5681
+  _CLIENTCONFIG._serialized_start=5684
+  _CLIENTCONFIG._serialized_end=5880
+  _NOTIFICATION._serialized_start=5883
+  _NOTIFICATION._serialized_end=6070
+  _NOTIFICATIONREPLY._serialized_start=6073
+  _NOTIFICATIONREPLY._serialized_end=6408
+  _NOTIFICATIONREPLY_ERRORDETAILSENTRY._serialized_start=6357
+  _NOTIFICATIONREPLY_ERRORDETAILSENTRY._serialized_end=6408
+  _STATEEVENT._serialized_start=6411
+  _STATEEVENT._serialized_end=6613
+  _STATEEVENT_TYPE._serialized_start=6510
+  _STATEEVENT_TYPE._serialized_end=6613
+  _RULESTATS._serialized_start=6616
+  _RULESTATS._serialized_end=6745
+  _FLOW._serialized_start=6748
+  _FLOW._serialized_end=6926
+  _UI._serialized_start=7930
+  _UI._serialized_end=8233
 # @@protoc_insertion_point(module_scope)
@@ -129,6 +129,7 @@ class UIService(ui_pb2_grpc.UIServicer, QtWidgets.QGraphicsObject):
 
         self._nodes = Nodes.instance()
         self._nodes.reset_status()
+        self._nodes.stateChanged.connect(self._on_node_state_changed)
 
         self._last_stats = {}
         self._last_items = {
@@ -468,6 +469,28 @@ class UIService(ui_pb2_grpc.UIServicer, QtWidgets.QGraphicsObject):
             else:
                 print("[service] notification reply error:", addr, reply.data)
 
+    @QtCore.pyqtSlot(str, ui_pb2.StateEvent)
+    def _on_node_state_changed(self, addr, event):
+        if event.type != ui_pb2.StateEvent.INTERCEPTION_CHANGED:
+            return
+        # if there're more than one node, the status doesn't depend on the
+        # interception of one of them.
+        if self._nodes.count() > 1:
+            return
+        try:
+            state = json.loads(event.data)
+        except Exception as e:
+            self.logger.warning("invalid interception state %s: %s", addr, repr(e))
+            return
+        self._update_fw_status(state.get('enabled', False))
+        if not state.get('enabled', False) and state.get('reason', "") != "":
+            self._show_message_trigger.emit(
+                QC.translate("stats", "Interception disabled"),
+                state['reason'],
+                QtWidgets.QSystemTrayIcon.MessageIcon.Warning,
+                DesktopNotifications.URGENCY_NORMAL
+            )
+
     def _on_remote_stats_menu(self, address):
         self._remote_stats[address]['dialog'].show()
 
@@ -1104,6 +1127,11 @@ class UIService(ui_pb2_grpc.UIServicer, QtWidgets.QGraphicsObject):
                     if in_message is None:
                         continue
 
+                    # the changes of the state of the daemon are not replies
+                    # to our notifications.
+                    if in_message.id == 0:
+                        self._nodes.update_state(node_addr, in_message)
+                        continue
                     self._nodes.reply_notification(addr, in_message)
                 except StopIteration:
                     self.logger.info("Node %s exited", node_addr)
@@ -435,6 +435,32 @@ class TestNodes():
         assert node is not None
         assert node['data'].config == new_config
 
+    def test_update_state(self, qtbot):
+        """Test the state events streamed by the daemon (replies with id 0)."""
+        self.nodes.add("peer:1.2.3.4", self.daemon_config)
+        events = []
+        self.nodes.stateChanged.connect(lambda addr, event: events.append((addr, event.type)))
+
+        new_config = '{"LogLevel": 2, "DefaultAction": "allow"}'
+        self.nodes.update_state("peer:1.2.3.4", ui_pb2.NotificationReply(
+            id=0,
+            event=ui_pb2.StateEvent(type=ui_pb2.StateEvent.CONFIG_CHANGED, source="file", data=new_config)))
+        assert self.nodes.get_node_config("peer:1.2.3.4") == new_config
+
+        for running in (True, False):
+            self.nodes.update_state("peer:1.2.3.4", ui_pb2.NotificationReply(
+                id=0,
+                event=ui_pb2.StateEvent(type=ui_pb2.StateEvent.FIREWALL_CHANGED, data=json.dumps({"firewall": "nftables", "running": running}))))
+            assert self.nodes.get_node("peer:1.2.3.4")['data'].isFirewallRunning is running
+
+        # replies without events are ignored.
+        self.nodes.update_state("peer:1.2.3.4", ui_pb2.NotificationReply(id=0))
+        assert events == [
+            ("peer:1.2.3.4", ui_pb2.StateEvent.CONFIG_CHANGED),
+            ("peer:1.2.3.4", ui_pb2.StateEvent.FIREWALL_CHANGED),
+            ("peer:1.2.3.4", ui_pb2.StateEvent.FIREWALL_CHANGED)
+        ]
+
     def test_firewall_enable_interception(self, qtbot):
         """Test enabling firewall interception."""
         self.nodes.add("peer:1.2.3.4", self.daemon_config)
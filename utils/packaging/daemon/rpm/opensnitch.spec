@@ -68,6 +68,7 @@ install -m 644 $B daemon/data/network_aliases.json %{buildroot}/etc/opensnitchd/
 install -m 644 ebpf_prog/opensnitch.o %{buildroot}/usr/lib/opensnitchd/ebpf/opensnitch.o
 install -m 644 ebpf_prog/opensnitch-dns.o %{buildroot}/usr/lib/opensnitchd/ebpf/opensnitch-dns.o
 install -m 644 ebpf_prog/opensnitch-procs.o %{buildroot}/usr/lib/opensnitchd/ebpf/opensnitch-procs.o
+install -m 644 ebpf_prog/opensnitch-procs-perf.o %{buildroot}/usr/lib/opensnitchd/ebpf/opensnitch-procs-perf.o
 
 B=""
 r="/etc/opensnitchd/rules/000-allow-localhost.json"
@@ -90,7 +91,9 @@ install -D -m 600 $B daemon/data/tasks/tasks.json %{buildroot}/etc/opensnitchd/t
 
 # upgrade, uninstall
 %preun
-systemctl stop opensnitch.service || true
+if [ $1 -eq 0 ]; then
+    systemctl stop opensnitch.service || true
+fi
 
 %post
 if [ $1 -eq 1 ]; then
@@ -107,9 +110,11 @@ if [ $1 -eq 0 -a -f /etc/logrotate.d/opensnitch ]; then
     rm /etc/logrotate.d/opensnitch
 fi
 
-# postun is the last step after reinstalling
+# postun is the last step after reinstalling.
+# On upgrade, hand over to the new version of the daemon without stopping it.
 if [ $1 -eq 1 ]; then
-    systemctl start opensnitch.service
+    systemctl daemon-reload
+    systemctl reload opensnitch.service || systemctl start opensnitch.service
 fi
 
 %clean
@@ -122,4 +127,5 @@ rm -rf %{buildroot}
 %{_prefix}/lib/opensnitchd/ebpf/opensnitch.o
 %{_prefix}/lib/opensnitchd/ebpf/opensnitch-dns.o
 %{_prefix}/lib/opensnitchd/ebpf/opensnitch-procs.o
+%{_prefix}/lib/opensnitchd/ebpf/opensnitch-procs-perf.o
 %{_sysconfdir}/logrotate.d/opensnitch
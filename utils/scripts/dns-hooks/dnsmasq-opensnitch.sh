@@ -0,0 +1,65 @@
+#!/bin/bash
+# opensnitch - 2026
+#
+# Pushes the domains resolved by dnsmasq to opensnitchd, including the answers
+# served from its cache, which are never seen by the daemon.
+#
+# dnsmasq doesn't run scripts on DNS queries (like --dhcp-script for DHCP
+# leases), so this script follows its log of queries. Add to dnsmasq.conf:
+#
+#   log-queries
+#   log-facility=/var/log/dnsmasq-queries.log
+#
+# and configure the daemon to accept the domains from the user of this script
+# (/etc/opensnitchd/default-config.json):
+#
+#   "DNS": {
+#       "PushSocket": "/run/opensnitchd/dns.sock",
+#       "PushAllowedUsers": ["dnsmasq"]
+#   }
+#
+# Usage: dnsmasq-opensnitch.sh [dnsmasq log] [daemon socket]
+#
+# Requires socat, or nc with unix sockets support (openbsd-netcat).
+
+LOG_FILE="${1:-/var/log/dnsmasq-queries.log}"
+SOCKET="${2:-/run/opensnitchd/dns.sock}"
+
+if command -v socat &>/dev/null; then
+    PUSH=(socat -u - "UNIX-CONNECT:${SOCKET}")
+elif command -v nc &>/dev/null; then
+    PUSH=(nc -U "${SOCKET}")
+else
+    echo "socat or nc not found"
+    exit 1
+fi
+
+# Lines of the log:
+#   dnsmasq[123]: reply www.example.net is <CNAME>
+#   dnsmasq[123]: reply cdn.example.net is 93.184.215.14
+#   dnsmasq[123]: cached example.com is 93.184.215.14
+# are pushed as:
+#   cdn.example.net www.example.net
+#   93.184.215.14 cdn.example.net
+#   93.184.215.14 example.com
+tail -n 0 -F "${LOG_FILE}" 2>/dev/null | awk '
+    $(NF-3) ~ /^(reply|cached)$/ && $(NF-1) == "is" {
+        name = $(NF-2); answer = $NF
+        if (answer == "<CNAME>") {
+            cname = name
+            next
+        }
+        if (cname != "") {
+            print name, cname
+            cname = ""
+        }
+        if (answer !~ /^(NXDOMAIN|NODATA.*|<.*>)$/) {
+            print answer, name
+        }
+        fflush()
+    }
+' | while true; do
+    "${PUSH[@]}"
+    # the daemon has been restarted, reconnect.
+    sleep 1
+done
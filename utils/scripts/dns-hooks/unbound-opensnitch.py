@@ -0,0 +1,119 @@
+# opensnitch - 2026
+#
+# unbound python module that pushes the domains resolved by unbound to
+# opensnitchd. Useful when unbound forwards the queries over DNS-over-TLS, so
+# the daemon can't see the DNS responses.
+#
+# Add to unbound.conf (unbound must be built with --with-pythonmodule):
+#
+#   server:
+#       module-config: "validator python iterator"
+#   python:
+#       python-script: "/usr/lib/opensnitchd/dns-hooks/unbound-opensnitch.py"
+#
+# and configure the daemon to accept the domains from the user unbound runs as
+# (/etc/opensnitchd/default-config.json):
+#
+#   "DNS": {
+#       "PushSocket": "/run/opensnitchd/dns.sock",
+#       "PushAllowedUsers": ["unbound"]
+#   }
+#
+# The answers served from the cache of unbound are not seen by the modules,
+# but they were pushed when they were resolved for the first time.
+
+import socket
+
+SOCKET_PATH = "/run/opensnitchd/dns.sock"
+sock = None
+
+
+def connect():
+    global sock
+    try:
+        sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        sock.connect(SOCKET_PATH)
+    except OSError as e:
+        log_info("opensnitch: unable to connect to %s: %s" % (SOCKET_PATH, e))
+        sock = None
+
+
+def push(records):
+    global sock
+    if not records:
+        return
+    if sock is None:
+        connect()
+    if sock is None:
+        return
+    try:
+        sock.sendall("".join("%s %s\n" % r for r in records).encode())
+    except OSError:
+        # the daemon has been restarted, retry on the next query.
+        sock.close()
+        sock = None
+
+
+def dname_to_str(wire, offset=0):
+    """Decodes a domain name in wire format (uncompressed)."""
+    labels = []
+    while offset < len(wire):
+        length = wire[offset]
+        if length == 0:
+            break
+        labels.append(wire[offset + 1:offset + 1 + length].decode(errors="replace"))
+        offset += 1 + length
+    return ".".join(labels)
+
+
+def answers(rep):
+    """Returns the (resolved, hostname) records of a reply."""
+    records = []
+    for i in range(rep.an_numrrsets):
+        rrset = rep.rrsets[i]
+        name = rrset.rk.dname_str.rstrip(".")
+        rtype = rrset.rk.type_str
+        data = rrset.entry.data
+        for j in range(data.count):
+            # every rr starts with its length (2 bytes)
+            rdata = bytes(data.rr_data[j])[2:]
+            if rtype == "A" and len(rdata) == 4:
+                records.append((socket.inet_ntop(socket.AF_INET, rdata), name))
+            elif rtype == "AAAA" and len(rdata) == 16:
+                records.append((socket.inet_ntop(socket.AF_INET6, rdata), name))
+            elif rtype == "CNAME":
+                records.append((dname_to_str(rdata), name))
+    return records
+
+
+def init_standard(id, env):
+    connect()
+    return True
+
+
+def deinit(id):
+    if sock is not None:
+        sock.close()
+    return True
+
+
+def inform_super(id, qstate, superqstate, qdata):
+    return True
+
+
+def operate(id, event, qstate, qdata):
+    if event in (MODULE_EVENT_NEW, MODULE_EVENT_PASS):
+        qstate.ext_state[id] = MODULE_WAIT_MODULE
+        return True
+
+    if event == MODULE_EVENT_MODDONE:
+        if qstate.return_msg and qstate.return_msg.rep:
+            try:
+                push(answers(qstate.return_msg.rep))
+            except Exception as e:
+                log_info("opensnitch: error pushing answers: %s" % e)
+        qstate.ext_state[id] = MODULE_FINISHED
+        return True
+
+    qstate.ext_state[id] = MODULE_ERROR
+    return True